@@ -3,12 +3,18 @@ extern crate cbindgen;
 
 use std::env;
 
+// The Go binding ships its own copy of the header, so that it can be used without the whole repository.
+const GO_BINDINGS: &str = "callers/go/fdu/bindings.h";
+
 fn main() {
     let crate_dir = env::var("CARGO_MANIFEST_DIR").unwrap();
+    let config = cbindgen::Config::from_root_or_default(&crate_dir);
 
-    cbindgen::Builder::new()
+    let bindings = cbindgen::Builder::new()
         .with_crate(crate_dir)
+        .with_config(config)
         .generate()
-        .expect("Unable to generate bindings")
-        .write_to_file("bindings.h");
-}
\ No newline at end of file
+        .expect("Unable to generate bindings");
+    bindings.write_to_file("bindings.h");
+    bindings.write_to_file(GO_BINDINGS);
+}
@@ -0,0 +1,23 @@
+// Command demo is a minimal program using the fdu package.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+)
+
+func main() {
+	if err := fdu.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer fdu.Shutdown()
+	s, err := fdu.Hello()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(s)
+}
@@ -0,0 +1,74 @@
+// Command login logs in to UIS from the terminal, asking for the captcha if
+// UIS wants one. The captcha image is written to a temporary file to be
+// opened with any image viewer.
+//
+// Usage:
+//
+//	FDU_UID=... FDU_PWD=... go run ./cmd/login
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if err := fdu.Init(); err != nil {
+		return err
+	}
+	defer fdu.Shutdown()
+
+	ctx := context.Background()
+	stdin := bufio.NewReader(os.Stdin)
+
+	s, err := fdu.Login(ctx, os.Getenv("FDU_UID"), os.Getenv("FDU_PWD"))
+	var captcha *fdu.CaptchaRequiredError
+	for errors.As(err, &captcha) {
+		answer, err := askCaptcha(stdin, captcha.Image)
+		if err != nil {
+			return err
+		}
+		s, err = fdu.LoginWithCaptcha(ctx, captcha.Token, answer)
+	}
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	fmt.Println("logged in")
+	return s.Logout(ctx)
+}
+
+func askCaptcha(stdin *bufio.Reader, image []byte) (string, error) {
+	f, err := os.CreateTemp("", "fdu-captcha-*.png")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(image)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("captcha saved to %s, enter the answer: ", f.Name())
+	answer, err := stdin.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
@@ -0,0 +1,352 @@
+package fdu
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/internal/abicheck"
+)
+
+var trackAllocs = flag.Bool("fdu.trackallocs", false,
+	"check that the package frees every pointer it owns per bindings.h exactly once")
+
+func parseBindings() (*abicheck.Header, error) {
+	src, err := os.ReadFile("bindings.h")
+	if err != nil {
+		return nil, err
+	}
+	h, err := abicheck.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("bindings.h: %v", err)
+	}
+	return h, nil
+}
+
+// TestABI checks the exports of libfdu against bindings.h, and the fields
+// of libfdu against the signatures of the exports.
+func TestABI(t *testing.T) {
+	h, err := parseBindings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l libfdu
+	exports := l.exports()
+	bindings := make([]abicheck.Binding, len(exports))
+	for i, e := range exports {
+		bindings[i] = abicheck.Binding{Name: e.name, Signature: e.sig}
+	}
+	for _, err := range abicheck.Check(h, bindings) {
+		t.Error(err)
+	}
+
+	listed := make(map[uintptr]string)
+	for _, e := range exports {
+		field := reflect.ValueOf(e.fptr)
+		if other, ok := listed[field.Pointer()]; ok {
+			t.Errorf("%s: the field of %s", e.name, other)
+		}
+		listed[field.Pointer()] = e.name
+		if f, err := h.ParseSignature(e.name, e.sig); err == nil {
+			checkGoType(t, f, field.Elem().Type())
+		}
+	}
+	v := reflect.ValueOf(&l).Elem()
+	for i := range v.NumField() {
+		if _, ok := listed[v.Field(i).Addr().Pointer()]; !ok {
+			t.Errorf("libfdu.%s is not listed by exports", v.Type().Field(i).Name)
+		}
+	}
+}
+
+// goTypes maps the base types of bindings.h to those of the fields of
+// libfdu passing them. The strings depend on the direction, see goType.
+var goTypes = map[string]reflect.Type{
+	"bool":                  reflect.TypeFor[bool](),
+	"int32_t":               reflect.TypeFor[int32](),
+	"int64_t":               reflect.TypeFor[int64](),
+	"uint8_t":               reflect.TypeFor[uint8](),
+	"uint32_t":              reflect.TypeFor[uint32](),
+	"uint64_t":              reflect.TypeFor[uint64](),
+	"size_t":                reflect.TypeFor[uintptr](),
+	"struct FduBuffer":      reflect.TypeFor[cBuffer](),
+	"struct FduCancelToken": reflect.TypeFor[cCancelToken](),
+	"struct FduCompletion":  reflect.TypeFor[cCompletion](),
+	"struct FduResult":      reflect.TypeFor[cResult](),
+	"struct FduSession":     reflect.TypeFor[cSession](),
+	// The fields take whether to enable the callbacks, see fduSetLogCallback.
+	"FduLogCallback":   reflect.TypeFor[bool](),
+	"FduTraceCallback": reflect.TypeFor[bool](),
+	// No result.
+	"void": nil,
+}
+
+// goType returns the Go type passing t, in a result if result.
+func goType(t abicheck.Type, result bool) (reflect.Type, bool) {
+	if t.Base == "char" && t.Pointers == 1 {
+		if t.Const && !result {
+			// Converted to a C string by the backends.
+			return reflect.TypeFor[string](), true
+		}
+		return reflect.TypeFor[*byte](), true
+	}
+	typ, ok := goTypes[t.Base]
+	if !ok || typ == nil && t.Pointers > 0 {
+		return nil, false
+	}
+	for range t.Pointers {
+		typ = reflect.PointerTo(typ)
+	}
+	return typ, true
+}
+
+// checkGoType checks that typ, the type of a field of libfdu, passes the
+// types of f. The integers may have another type of the same kind, such as
+// call for int32_t.
+func checkGoType(t *testing.T, f abicheck.Func, typ reflect.Type) {
+	t.Helper()
+	same := func(got, want reflect.Type) bool {
+		return got == want || want.PkgPath() == "" && want.Kind() != reflect.Pointer && got.Kind() == want.Kind()
+	}
+	if typ.NumIn() != len(f.Params) {
+		t.Errorf("%s: the field takes %d parameters for %s", f.Name, typ.NumIn(), f.Signature())
+		return
+	}
+	for i, p := range f.Params {
+		want, ok := goType(p.Type, false)
+		if !ok {
+			t.Errorf("%s: no Go type for %s", f.Name, p.Type)
+		} else if got := typ.In(i); !same(got, want) {
+			t.Errorf("%s: parameter %d is %v in the field, want %v for %s", f.Name, i+1, got, want, p.Type)
+		}
+	}
+	want, ok := goType(f.Result, true)
+	switch {
+	case !ok:
+		t.Errorf("%s: no Go type for %s", f.Name, f.Result)
+	case want == nil:
+		if typ.NumOut() != 0 {
+			t.Errorf("%s: the field returns %d values for void", f.Name, typ.NumOut())
+		}
+	case typ.NumOut() != 1 || !same(typ.Out(0), want):
+		t.Errorf("%s: the field returns %v, want %v for %s", f.Name, typ, want, f.Result)
+	}
+}
+
+// tracker tracks the pointers returned by libfdu with -fdu.trackallocs, see
+// trackPointers.
+var tracker *pointerTracker
+
+// pointerTracker tracks the pointers owned by the package per bindings.h,
+// from the call into libfdu returning them to the one freeing them.
+type pointerTracker struct {
+	mu   sync.Mutex
+	live map[unsafe.Pointer]trackedPointer
+	seq  int
+	errs []string
+}
+
+type trackedPointer struct {
+	seq int
+	// from is the export which returned the pointer, and free the one which
+	// must free it.
+	from, free string
+}
+
+func (pt *pointerTracker) add(p unsafe.Pointer, from, free string) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if old, ok := pt.live[p]; ok {
+		pt.errs = append(pt.errs, fmt.Sprintf("%s returned %p, which %s returned and was not freed", from, p, old.from))
+	}
+	pt.seq++
+	pt.live[p] = trackedPointer{pt.seq, from, free}
+}
+
+// remove reports whether free may free p, and forgets it if so.
+func (pt *pointerTracker) remove(p unsafe.Pointer, free string) bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	tp, ok := pt.live[p]
+	switch {
+	case !ok:
+		pt.errs = append(pt.errs, fmt.Sprintf("%s of %p, which libfdu did not return or which was freed", free, p))
+		return false
+	case tp.free != free:
+		pt.errs = append(pt.errs, fmt.Sprintf("%s of %p, returned by %s, which %s frees", free, p, tp.from, tp.free))
+		return false
+	}
+	delete(pt.live, p)
+	return true
+}
+
+// mark returns the position to pass to since.
+func (pt *pointerTracker) mark() (seq, errs int) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.seq, len(pt.errs)
+}
+
+// since returns the pointers returned since mark and not freed yet, and the
+// errors since then.
+func (pt *pointerTracker) since(seq, errs int) (live, newErrs []string) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	for p, tp := range pt.live {
+		if tp.seq > seq {
+			live = append(live, fmt.Sprintf("%p from %s", p, tp.from))
+		}
+	}
+	slices.Sort(live)
+	return live, slices.Clone(pt.errs[errs:])
+}
+
+// trackPointers replaces the fields of lib with shims tracking the pointers
+// libfdu returns, per the ownership of bindings.h, and checking that each is
+// freed once with its free function. A pointer which cannot be freed is not
+// passed to libfdu. It must be called while no call into libfdu is in
+// flight, since it replaces the fields of lib.
+func trackPointers(h *abicheck.Header) *pointerTracker {
+	pt := &pointerTracker{live: make(map[unsafe.Pointer]trackedPointer)}
+	freeOf := func(t abicheck.Type) string {
+		free, _ := h.Free(t)
+		return free.Name
+	}
+	for _, e := range lib.exports() {
+		f, ok := h.Func(e.name)
+		if !ok {
+			continue
+		}
+		field := reflect.ValueOf(e.fptr).Elem()
+		orig := reflect.ValueOf(field.Interface())
+		switch {
+		case e.name == "fdu_poll_completions":
+			// The results of the completions are owned too, but bindings.h
+			// does not say so since they are in a struct.
+			poll := lib.fduPollCompletions
+			lib.fduPollCompletions = func(buf *cCompletion, n uintptr, timeoutMillis uint64) uintptr {
+				got := poll(buf, n, timeoutMillis)
+				for _, c := range unsafe.Slice(buf, min(got, n)) {
+					if c.result != nil {
+						pt.add(unsafe.Pointer(c.result), e.name, "free_result")
+					}
+				}
+				return got
+			}
+		case len(f.Params) == 1 && freeOf(f.Params[0].Type) == f.Name:
+			field.Set(reflect.MakeFunc(field.Type(), func(args []reflect.Value) []reflect.Value {
+				if p := args[0].UnsafePointer(); p != nil && !pt.remove(p, f.Name) {
+					return nil
+				}
+				return orig.Call(args)
+			}))
+		case len(f.Owns()) > 0:
+			field.Set(reflect.MakeFunc(field.Type(), func(args []reflect.Value) []reflect.Value {
+				before := make([]unsafe.Pointer, len(args))
+				for i, p := range f.Params {
+					if p.Owned && !args[i].IsNil() {
+						before[i] = args[i].Elem().UnsafePointer()
+					}
+				}
+				results := orig.Call(args)
+				if f.Owned {
+					if p := results[0].UnsafePointer(); p != nil {
+						pt.add(p, f.Name, freeOf(f.Result))
+					}
+				}
+				for i, p := range f.Params {
+					if !p.Owned || args[i].IsNil() {
+						continue
+					}
+					if out := args[i].Elem().UnsafePointer(); out != nil && out != before[i] {
+						t := p.Type
+						t.Pointers--
+						pt.add(out, f.Name, freeOf(t))
+					}
+				}
+				return results
+			}))
+		}
+	}
+	return pt
+}
+
+// checkTrackedPointers reports what the tracker found during the tests. The
+// results and buffers must all be freed by now, unlike the sessions and the
+// cancel tokens, which finalizers and abandoned calls free later.
+func checkTrackedPointers() error {
+	live, errs := tracker.since(0, 0)
+	for _, l := range live {
+		if strings.Contains(l, "free_result") || strings.Contains(l, "free_buffer") {
+			errs = append(errs, "not freed: "+l)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("-fdu.trackallocs:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
+// TestABIOwnership makes the calls owning each kind of pointer, and checks
+// that they free them with -fdu.trackallocs.
+func TestABIOwnership(t *testing.T) {
+	if tracker == nil {
+		t.Skip("run with -fdu.trackallocs, e.g. with make trackallocs")
+	}
+	seq, errs := tracker.mark()
+	if _, err := Hello(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testError(int32(ErrCodeNetwork)); err == nil {
+		t.Error("testError: got no error")
+	}
+	if _, err := testEcho(context.Background(), "libfdu"); err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"small", strings.Repeat("x", resultBufferSize+1)} {
+		if _, err := testEchoInto(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := testEchoBytes([]byte("libfdu")); err != nil {
+		t.Fatal(err)
+	}
+	if err := testSleep(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.testPing(); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if live, errs := tracker.since(seq, errs); len(live) > 0 || len(errs) > 0 {
+		t.Errorf("not freed: %q, errors: %q", live, errs)
+	}
+
+	// The tracker catches a leak, and a pointer which libfdu did not return.
+	seq, errs = tracker.mark()
+	r := lib.fduTestResult("leaked", 0)
+	live, _ := tracker.since(seq, errs)
+	lib.freeResult(r)
+	lib.freeResult(new(cResult))
+	_, gotErrs := tracker.since(seq, errs)
+	tracker.mu.Lock()
+	tracker.errs = tracker.errs[:errs]
+	tracker.mu.Unlock()
+	if len(live) != 1 || !strings.Contains(live[0], "fdu_test_result") {
+		t.Errorf("leak: got %q", live)
+	}
+	if len(gotErrs) != 1 || !strings.Contains(gotErrs[0], "libfdu did not return") {
+		t.Errorf("invalid free: got %q", gotErrs)
+	}
+}
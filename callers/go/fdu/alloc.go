@@ -0,0 +1,44 @@
+package fdu
+
+import "encoding/json"
+
+// AllocationStats are the allocation statistics of libfdu, which the memory
+// statistics of the runtime package do not see: a C string never freed by
+// this package only shows up here.
+type AllocationStats struct {
+	// LiveAllocations and LiveBytes are the allocations not freed yet.
+	LiveAllocations int64 `json:"live_allocations"`
+	LiveBytes       int64 `json:"live_bytes"`
+	// TotalAllocations counts every allocation so far.
+	TotalAllocations int64 `json:"total_allocations"`
+}
+
+// AllocStats returns the allocation statistics of libfdu, e.g. to check in
+// tests that every allocation of a loop is freed:
+//
+//	before, err := fdu.AllocStats()
+//	...
+//	after, err := fdu.AllocStats()
+//	// after.LiveAllocations == before.LiveAllocations
+//
+// Background work of libfdu, such as the completion of an abandoned call,
+// allocates too, so compare them once the calls are done. AllocStats
+// returns ErrNoAllocStats unless libfdu is built with the alloc-stats cargo
+// feature, which counts every allocation.
+func AllocStats() (AllocationStats, error) {
+	if err := checkInit(); err != nil {
+		return AllocationStats{}, err
+	}
+	v, err := callInto(callAllocStats, nil, "")
+	if err != nil {
+		return AllocationStats{}, err
+	}
+	if v == "" {
+		return AllocationStats{}, ErrNoAllocStats
+	}
+	var stats AllocationStats
+	if err := json.Unmarshal([]byte(v), &stats); err != nil {
+		return AllocationStats{}, parseError("allocation statistics: %v", err)
+	}
+	return stats, nil
+}
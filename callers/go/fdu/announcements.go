@@ -0,0 +1,144 @@
+package fdu
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AnnouncementSource is a public feed of announcements.
+type AnnouncementSource string
+
+const (
+	// SourceJWC is the feed of the academic affairs office (教务处).
+	SourceJWC AnnouncementSource = "jwc"
+	// SourceGS is the feed of the graduate school (研究生院).
+	SourceGS AnnouncementSource = "gs"
+)
+
+// AnnouncementSources returns the valid sources of Announcements.
+func AnnouncementSources() []AnnouncementSource {
+	return []AnnouncementSource{SourceJWC, SourceGS}
+}
+
+// UnknownSourceError is returned by Announcements for a source which is not
+// one of AnnouncementSources. It wraps ErrInvalidArgument.
+type UnknownSourceError struct {
+	Source AnnouncementSource
+	// Valid are the valid sources.
+	Valid []AnnouncementSource
+}
+
+func (e *UnknownSourceError) Error() string {
+	valid := make([]string, len(e.Valid))
+	for i, source := range e.Valid {
+		valid[i] = string(source)
+	}
+	return fmt.Sprintf("fdu: unknown announcement source %q, valid sources are %s", e.Source, strings.Join(valid, ", "))
+}
+
+// Unwrap returns ErrInvalidArgument.
+func (e *UnknownSourceError) Unwrap() error {
+	return ErrInvalidArgument
+}
+
+// Announcement is an announcement of a public feed.
+type Announcement struct {
+	// ID is the number of the announcement in its source. It grows with each
+	// new announcement, and stays the same when one is edited.
+	ID    int64
+	Title string
+	URL   string
+	// Date is the day the announcement was published, at midnight. It
+	// changes when the announcement is edited and re-dated.
+	Date time.Time
+	// Department is the office publishing the announcement, e.g. "教务处".
+	Department string
+}
+
+type rawAnnouncement struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	// Date is e.g. "2024-03-01".
+	Date       string `json:"date"`
+	Department string `json:"department"`
+}
+
+// Announcements returns the announcements of source newer than sinceID,
+// oldest first, so that the last one has the ID to pass the next time. With
+// a sinceID of 0, it returns the latest ones. The feeds are public, so no
+// session is needed.
+//
+// An edited announcement keeps its ID, and is not returned again. If source
+// is not valid, the error is an *UnknownSourceError.
+func Announcements(ctx context.Context, source AnnouncementSource, sinceID int64) ([]Announcement, error) {
+	if !slices.Contains(AnnouncementSources(), source) {
+		return nil, &UnknownSourceError{Source: source, Valid: AnnouncementSources()}
+	}
+	if sinceID < 0 {
+		return nil, argumentError("negative announcement ID %d", sinceID)
+	}
+	if err := checkInit(); err != nil {
+		return nil, err
+	}
+	v, err := runJob(ctx, "", func() {}, func(token *cCancelToken, id uint64) *cResult {
+		return lib.fduAnnouncementsAsync(string(source), uint64(sinceID), token, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseAnnouncements([]byte(v), sinceID)
+}
+
+// AnnouncementFeed keeps the cursor of a source between calls to Poll. Start
+// it with the SinceID saved from the last run, or 0. It must not be used by
+// several goroutines at once.
+type AnnouncementFeed struct {
+	Source AnnouncementSource
+	// SinceID is the largest ID returned so far.
+	SinceID int64
+}
+
+// Poll returns the announcements published since the last call, oldest
+// first, and moves SinceID past them. On error, SinceID is left as is.
+func (f *AnnouncementFeed) Poll(ctx context.Context) ([]Announcement, error) {
+	announcements, err := Announcements(ctx, f.Source, f.SinceID)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(announcements); n > 0 {
+		f.SinceID = announcements[n-1].ID
+	}
+	return announcements, nil
+}
+
+// parseAnnouncements parses the announcements from libfdu, keeping those
+// newer than sinceID once each, sorted by ID.
+func parseAnnouncements(data []byte, sinceID int64) ([]Announcement, error) {
+	var raw []rawAnnouncement
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, parseError("announcements: %v", err)
+	}
+	seen := make(map[int64]bool, len(raw))
+	announcements := make([]Announcement, 0, len(raw))
+	for _, r := range raw {
+		if r.ID <= sinceID || seen[r.ID] {
+			continue
+		}
+		seen[r.ID] = true
+		date, err := parseDate(r.Date)
+		if err != nil {
+			return nil, parseError("announcement %d: invalid date %q", r.ID, r.Date)
+		}
+		announcements = append(announcements, Announcement{ID: r.ID, Title: r.Title, URL: r.URL, Date: date, Department: r.Department})
+	}
+	slices.SortFunc(announcements, func(a, b Announcement) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return announcements, nil
+}
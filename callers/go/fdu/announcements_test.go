@@ -0,0 +1,120 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseAnnouncements(t *testing.T) {
+	data, err := os.ReadFile("testdata/announcements.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	announcements, err := parseAnnouncements(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Oldest first, and the edited one once, as first listed.
+	var ids []int64
+	for _, a := range announcements {
+		ids = append(ids, a.ID)
+	}
+	if want := []int64{482101, 482180, 482210}; !slices.Equal(ids, want) {
+		t.Fatalf("got IDs %v, want %v", ids, want)
+	}
+	edited := announcements[1]
+	if edited.Title != "关于研究生课程补退选的通知（更新）" || edited.Department != "培养办" {
+		t.Errorf("got %+v", edited)
+	}
+	if want := time.Date(2024, 3, 2, 0, 0, 0, 0, chinaTime); !edited.Date.Equal(want) {
+		t.Errorf("got date %v, want %v", edited.Date, want)
+	}
+	if edited.URL != "https://gs.fudan.edu.cn/2024/0301/c13535a482180/page.htm" {
+		t.Errorf("got URL %q", edited.URL)
+	}
+
+	newer, err := parseAnnouncements(data, 482180)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newer) != 1 || newer[0].ID != 482210 {
+		t.Errorf("got %+v newer than 482180", newer)
+	}
+
+	for name, data := range map[string]string{
+		"not json": `<html>`,
+		"bad date": `[{"id": 1, "date": "03-01"}]`,
+	} {
+		if _, err := parseAnnouncements([]byte(data), 0); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func TestAnnouncementsUnknownSource(t *testing.T) {
+	_, err := Announcements(context.Background(), "xxx", 0)
+	var unknown *UnknownSourceError
+	if !errors.As(err, &unknown) || !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("got %v, want an *UnknownSourceError", err)
+	}
+	if !slices.Equal(unknown.Valid, []AnnouncementSource{SourceJWC, SourceGS}) {
+		t.Errorf("got valid sources %v", unknown.Valid)
+	}
+	if want := `fdu: unknown announcement source "xxx", valid sources are jwc, gs`; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
+
+func TestAnnouncementFeed(t *testing.T) {
+	data, err := os.ReadFile("testdata/announcements.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake ignores sinceID and lists everything again, as a feed may.
+	orig := lib.fduAnnouncementsAsync
+	t.Cleanup(func() { lib.fduAnnouncementsAsync = orig })
+	var sources []string
+	lib.fduAnnouncementsAsync = func(source string, _ uint64, token *cCancelToken, requestID uint64) *cResult {
+		sources = append(sources, source)
+		return lib.fduTestResultAsync(string(data), 0, 0, token, requestID)
+	}
+
+	feed := &AnnouncementFeed{Source: SourceGS}
+	first, err := feed.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 3 || feed.SinceID != 482210 {
+		t.Fatalf("got %d announcements and cursor %d, want 3 and 482210", len(first), feed.SinceID)
+	}
+	second, err := feed.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != 0 || feed.SinceID != 482210 {
+		t.Errorf("got %+v and cursor %d on the second poll", second, feed.SinceID)
+	}
+	if !slices.Equal(sources, []string{"gs", "gs"}) {
+		t.Errorf("got sources %v", sources)
+	}
+}
+
+func FuzzParseAnnouncements(f *testing.F) {
+	parse := func(data []byte) ([]Announcement, error) {
+		return parseAnnouncements(data, 0)
+	}
+	fuzzParse(f, []string{"announcements.json"}, nil, parse, func(t *testing.T, announcements []Announcement) {
+		for i, a := range announcements {
+			if a.ID <= 0 {
+				t.Fatalf("accepted the invalid announcement %+v", a)
+			}
+			if i > 0 && a.ID <= announcements[i-1].ID {
+				t.Fatalf("got the announcement %d after %d", a.ID, announcements[i-1].ID)
+			}
+		}
+	})
+}
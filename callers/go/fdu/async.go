@@ -0,0 +1,153 @@
+package fdu
+
+import (
+	"context"
+	"sync"
+)
+
+// The slow calls into libfdu are not made with the blocking exports, which
+// would pin an OS thread for each call in flight, but with their _async
+// variants: libfdu runs the call on threads of its own and returns at once,
+// and a single goroutine polls the completions and hands each result to the
+// goroutine waiting for it. Callers still see blocking methods.
+
+// pollBatch is the number of completions taken from libfdu at once.
+const pollBatch = 64
+
+// pollTimeoutMillis bounds how long the poller waits in libfdu, so that it
+// notices when there is nothing left to wait for.
+const pollTimeoutMillis = 1000
+
+// cCompletion mirrors FduCompletion of bindings.h.
+type cCompletion struct {
+	requestID uint64
+	result    *cResult
+}
+
+// job is a call submitted to libfdu and not completed yet.
+type job struct {
+	// result receives the result of the call, unless the job is abandoned.
+	result chan *cResult
+	// token is the cancellation token of the call, freed on completion.
+	token *cCancelToken
+	// done is called on completion, e.g. to unlock the session of the call.
+	done func()
+	// abandoned is set when the caller gave up on the call: its result is
+	// freed on completion instead of being delivered.
+	abandoned bool
+}
+
+var jobs struct {
+	// mu guards the fields below, and the abandoned field of the jobs.
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]*job
+	// polling is set while the poller runs. It stops once no job is
+	// pending, and is restarted by the next call.
+	polling bool
+}
+
+// runJob submits a call to libfdu with start, which is given the
+// cancellation token and the request id of the call and returns the result of
+// the submission, and waits for the result of the call. done is called once
+// libfdu is done with the call, which may be after runJob returns. The trace
+// events of the call carry traceID, see WithTraceID.
+//
+// If ctx is done before the call completes, the token is cancelled and
+// ctx.Err() is returned immediately. The call is abandoned: its result is
+// freed by the poller when it eventually completes.
+func runJob(ctx context.Context, traceID string, done func(), start func(token *cCancelToken, id uint64) *cResult) (string, error) {
+	if err := ctx.Err(); err != nil {
+		done()
+		return "", err
+	}
+	var token *cCancelToken
+	if ctx.Done() != nil || traceID != "" {
+		token = newCancelToken(ctx)
+	}
+	if traceID != "" {
+		if _, err := takeResult(lib.fduCancelTokenSetTraceID(token, traceID)); err != nil {
+			lib.fduCancelTokenFree(token)
+			done()
+			return "", err
+		}
+	}
+	j := &job{result: make(chan *cResult, 1), token: token, done: done}
+
+	jobs.mu.Lock()
+	jobs.nextID++
+	id := jobs.nextID
+	if jobs.pending == nil {
+		jobs.pending = make(map[uint64]*job)
+	}
+	jobs.pending[id] = j
+	if !jobs.polling {
+		jobs.polling = true
+		go poll()
+	}
+	jobs.mu.Unlock()
+
+	if _, err := takeResult(start(token, id)); err != nil {
+		// The call was not submitted, so it never completes.
+		jobs.mu.Lock()
+		delete(jobs.pending, id)
+		jobs.mu.Unlock()
+		if token != nil {
+			lib.fduCancelTokenFree(token)
+		}
+		done()
+		return "", err
+	}
+
+	select {
+	case r := <-j.result:
+		return takeResult(r)
+	case <-ctx.Done():
+		jobs.mu.Lock()
+		if _, ok := jobs.pending[id]; ok {
+			j.abandoned = true
+			lib.fduCancel(token)
+			jobs.mu.Unlock()
+			return "", ctx.Err()
+		}
+		jobs.mu.Unlock()
+		// The call completed in the meantime.
+		_, _ = takeResult(<-j.result)
+		return "", ctx.Err()
+	}
+}
+
+// poll dispatches the completions of libfdu to their jobs until no job is
+// pending.
+func poll() {
+	var buf [pollBatch]cCompletion
+	for {
+		n := lib.fduPollCompletions(&buf[0], uintptr(len(buf)), pollTimeoutMillis)
+
+		jobs.mu.Lock()
+		for _, c := range buf[:n] {
+			j, ok := jobs.pending[c.requestID]
+			if !ok {
+				// Not ours: there is nothing to do but not leak it.
+				lib.freeResult(c.result)
+				continue
+			}
+			delete(jobs.pending, c.requestID)
+			if j.token != nil {
+				lib.fduCancelTokenFree(j.token)
+			}
+			if j.abandoned {
+				lib.freeResult(c.result)
+			} else {
+				j.result <- c.result
+			}
+			j.done()
+		}
+		if len(jobs.pending) == 0 {
+			jobs.polling = false
+			jobs.mu.Unlock()
+			return
+		}
+		jobs.mu.Unlock()
+	}
+}
@@ -0,0 +1,118 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrently runs n calls of f at the same time and returns the first error.
+func concurrently(n int, f func() error) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func TestAsyncConcurrent(t *testing.T) {
+	base := testLiveTokens()
+	err := concurrently(200, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return testSleepAsync(ctx, 20)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live := testLiveTokens(); live != base {
+		t.Errorf("%d tokens leaked", live-base)
+	}
+}
+
+func TestAsyncAbandoned(t *testing.T) {
+	base := testLiveTokens()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := testSleepAsync(ctx, 10000); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("returned after %v", elapsed)
+	}
+
+	// The job notices the cancellation, and its late completion is dropped.
+	deadline := time.Now().Add(time.Second)
+	for testLiveTokens() != base && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if live := testLiveTokens(); live != base {
+		t.Errorf("%d tokens leaked", live-base)
+	}
+	if err := testSleepAsync(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsyncSessionLocked(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Waiting for the session held by another call is cancellable too.
+	s.lock <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.Valid(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	s.unlock()
+
+	if _, err := s.Valid(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkConcurrentCalls compares 200 concurrent slow calls made with the
+// blocking exports, each pinning an OS thread, to the same calls made as jobs.
+func BenchmarkConcurrentCalls(b *testing.B) {
+	const calls, millis = 200, 10
+	for _, bm := range []struct {
+		name  string
+		sleep func(ctx context.Context, millis uint64) error
+	}{
+		{"blocking", testSleep},
+		{"async", testSleepAsync},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			threads := pprof.Lookup("threadcreate").Count()
+			for i := 0; i < b.N; i++ {
+				err := concurrently(calls, func() error {
+					ctx, cancel := context.WithCancel(context.Background())
+					defer cancel()
+					return bm.sleep(ctx, millis)
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(pprof.Lookup("threadcreate").Count()-threads), "threads")
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+)
+
+// CourseStatus is the status of a course in a DegreeAudit.
+type CourseStatus string
+
+const (
+	CoursePassed     CourseStatus = "passed"      // 已通过
+	CourseInProgress CourseStatus = "in_progress" // 在修, this semester
+	CourseFailed     CourseStatus = "failed"      // 未通过
+)
+
+// DegreeAudit is the progress of an undergraduate in the training plan
+// (培养方案完成度), as shown by jwfw.
+type DegreeAudit struct {
+	// Plan is the whole training plan, e.g. "计算机科学与技术（2021级）",
+	// whose children are its categories, e.g. "一、通识教育课程".
+	Plan AuditCategory
+	// Mismatches are the categories whose children earned more credits
+	// than they did. jwfw shows some, e.g. when it has not yet added the
+	// credits transferred from another major to a category, so they are
+	// reported rather than making DegreeAudit fail.
+	Mismatches []CreditMismatch
+}
+
+// AuditCategory is a category of courses of a training plan, e.g. 通识教育课程
+// or 专业必修课.
+type AuditCategory struct {
+	// ID is made of the names of the categories from the root of the plan,
+	// without their numbering, e.g. "通识教育课程/核心课程" for "（二）核心课程"
+	// of "一、通识教育课程", so that it stays the same from one audit to the
+	// next. It is empty for the root. Under a parent, the second category
+	// of a name gets "#2" appended, and so on.
+	ID string `json:"id"`
+	// Name is e.g. "（二）核心课程", as shown by jwfw.
+	Name              string  `json:"name"`
+	RequiredCredits   float64 `json:"required_credits"`
+	EarnedCredits     float64 `json:"earned_credits"`
+	InProgressCredits float64 `json:"in_progress_credits"`
+	// Courses are the courses counted under the category itself, and not
+	// under one of its children.
+	Courses  []AuditCourse   `json:"courses"`
+	Children []AuditCategory `json:"children"`
+}
+
+// AuditCourse is a course counted in a category of a training plan. A course
+// taken again after failing it is listed once for each attempt.
+type AuditCourse struct {
+	// CourseID is the code of the course, e.g. COMP130004, unlike the one of
+	// Score, which is that of a class of the course.
+	CourseID string  `json:"course_id"`
+	Name     string  `json:"name"`
+	Credit   float64 `json:"credit"`
+	// Semester is e.g. "2022-2023 1", empty for credits transferred from
+	// outside the university.
+	Semester string `json:"semester"`
+	// Grade is e.g. "A-" or "P", empty for a course in progress.
+	Grade  string       `json:"grade"`
+	Status CourseStatus `json:"status"`
+	// Transferred is whether the credits were recognized (学分认定, 学分转换)
+	// from another major or university, e.g. after a change of major or an
+	// exchange, rather than earned in the plan.
+	Transferred bool `json:"transferred"`
+	// CountedIn are the IDs of the categories counting the course, in the
+	// order of the plan. A course counted twice by the rules of the plan is
+	// listed in each of its categories, with all their IDs.
+	CountedIn []string `json:"counted_in"`
+}
+
+// CreditMismatch is a category of a DegreeAudit whose children earned more
+// credits than it did.
+type CreditMismatch struct {
+	// Category is the ID of the category.
+	Category string
+	// EarnedCredits are the credits earned by the category, and
+	// ChildrenCredits those earned by its children, counting once the
+	// courses counted by several of them.
+	EarnedCredits   float64
+	ChildrenCredits float64
+}
+
+// creditTolerance is the difference of credits taken for rounding errors.
+const creditTolerance = 1e-6
+
+// DegreeAudit returns the progress of the student in the training plan. The
+// error wraps ErrInvalidArgument for graduate students, whose progress is the
+// Progress of GPA.
+func (s *Session) DegreeAudit(ctx context.Context, opts ...CallOption) (*DegreeAudit, error) {
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduDegreeAuditAsync(ptr, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parseDegreeAudit([]byte(v))
+}
+
+// Category returns the category of the plan with the id, or nil if there is
+// none.
+func (a *DegreeAudit) Category(id string) *AuditCategory {
+	return a.Plan.find(id)
+}
+
+func (c *AuditCategory) find(id string) *AuditCategory {
+	if c.ID == id {
+		return c
+	}
+	for i := range c.Children {
+		if found := c.Children[i].find(id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// RemainingCredits returns the credits still to earn to complete the plan,
+// see AuditCategory.RemainingCredits.
+func (a *DegreeAudit) RemainingCredits() float64 {
+	return a.Plan.RemainingCredits()
+}
+
+// RemainingCredits returns the credits still to earn to complete c, counting
+// the courses in progress as passed: the required credits of c which are
+// neither earned nor in progress, or the sum of the remaining credits of its
+// children if more, since each of them must be completed too. The credits
+// earned beyond what a category requires do not make up for another one.
+func (c *AuditCategory) RemainingCredits() float64 {
+	remaining := max(0, c.RequiredCredits-c.EarnedCredits-c.InProgressCredits)
+	var children float64
+	for i := range c.Children {
+		children += c.Children[i].RemainingCredits()
+	}
+	return max(remaining, children)
+}
+
+func parseDegreeAudit(data []byte) (*DegreeAudit, error) {
+	var audit DegreeAudit
+	if err := json.Unmarshal(data, &audit.Plan); err != nil {
+		return nil, parseError("degree audit: %v", err)
+	}
+	if audit.Plan.ID != "" {
+		return nil, parseError("degree audit: root category with the ID %q", audit.Plan.ID)
+	}
+	ids := make(map[string]bool)
+	if err := checkCategory(&audit.Plan, ids); err != nil {
+		return nil, err
+	}
+	if err := checkCountedIn(&audit.Plan, ids); err != nil {
+		return nil, err
+	}
+	audit.Mismatches = creditMismatches(&audit.Plan, nil)
+	return &audit, nil
+}
+
+// checkCategory checks the credits and the courses of c and of its children,
+// adding their IDs to ids.
+func checkCategory(c *AuditCategory, ids map[string]bool) error {
+	if ids[c.ID] {
+		return parseError("degree audit: duplicate category %q", c.ID)
+	}
+	ids[c.ID] = true
+	if c.RequiredCredits < 0 || c.EarnedCredits < 0 || c.InProgressCredits < 0 {
+		return parseError("degree audit: category %q: invalid credits %v/%v/%v", c.ID, c.RequiredCredits, c.EarnedCredits, c.InProgressCredits)
+	}
+	for _, course := range c.Courses {
+		if course.Credit < 0 {
+			return parseError("degree audit: course %s: invalid credit %v", course.CourseID, course.Credit)
+		}
+		switch course.Status {
+		case CoursePassed, CourseInProgress, CourseFailed:
+		default:
+			return parseError("degree audit: course %s: unknown status %q", course.CourseID, course.Status)
+		}
+		if !slices.Contains(course.CountedIn, c.ID) {
+			return parseError("degree audit: course %s is not counted in its category %q", course.CourseID, c.ID)
+		}
+	}
+	for i := range c.Children {
+		if c.Children[i].ID == "" {
+			return parseError("degree audit: category %q without an ID", c.Children[i].Name)
+		}
+		if err := checkCategory(&c.Children[i], ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCountedIn checks that the courses of c are counted in categories of
+// ids only.
+func checkCountedIn(c *AuditCategory, ids map[string]bool) error {
+	for _, course := range c.Courses {
+		for _, id := range course.CountedIn {
+			if !ids[id] {
+				return parseError("degree audit: course %s counted in the unknown category %q", course.CourseID, id)
+			}
+		}
+	}
+	for i := range c.Children {
+		if err := checkCountedIn(&c.Children[i], ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// creditMismatches appends the CreditMismatch of c and of its descendants to
+// mismatches.
+func creditMismatches(c *AuditCategory, mismatches []CreditMismatch) []CreditMismatch {
+	if len(c.Children) > 0 {
+		var children float64
+		// counted are the number of children counting each passed course.
+		counted := make(map[string]int)
+		credits := make(map[string]float64)
+		for i := range c.Children {
+			children += c.Children[i].EarnedCredits
+			passed := make(map[string]float64)
+			c.Children[i].passed(passed)
+			for id, credit := range passed {
+				counted[id]++
+				credits[id] = credit
+			}
+		}
+		for id, n := range counted {
+			children -= float64(n-1) * credits[id]
+		}
+		if children > c.EarnedCredits+creditTolerance {
+			mismatches = append(mismatches, CreditMismatch{Category: c.ID, EarnedCredits: c.EarnedCredits, ChildrenCredits: children})
+		}
+	}
+	for i := range c.Children {
+		mismatches = creditMismatches(&c.Children[i], mismatches)
+	}
+	return mismatches
+}
+
+// passed adds the credits of the courses passed in c and its descendants
+// to credits, by course ID.
+func (c *AuditCategory) passed(credits map[string]float64) {
+	for _, course := range c.Courses {
+		if course.Status == CoursePassed {
+			credits[course.CourseID] = course.Credit
+		}
+	}
+	for i := range c.Children {
+		c.Children[i].passed(credits)
+	}
+}
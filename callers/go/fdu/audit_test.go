@@ -0,0 +1,232 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func readDegreeAudit(t *testing.T, fixture string) *DegreeAudit {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, err := parseDegreeAudit(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return audit
+}
+
+func TestParseDegreeAuditFreshman(t *testing.T) {
+	audit := readDegreeAudit(t, "degree_audit_freshman.json")
+	if plan := audit.Plan; plan.Name != "计算机科学与技术（2024级）" || plan.RequiredCredits != 40 || plan.EarnedCredits != 0 || plan.InProgressCredits != 10 {
+		t.Errorf("got plan %s with %v/%v/%v credits", plan.Name, plan.RequiredCredits, plan.EarnedCredits, plan.InProgressCredits)
+	}
+	var ids []string
+	for _, c := range audit.Plan.Children {
+		ids = append(ids, c.ID)
+	}
+	if want := []string{"通识教育课程", "专业教育课程", "任意选修课", "创新创业"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got categories %q, want %q", ids, want)
+	}
+	politics := audit.Category("通识教育课程/思想政治理论课")
+	if politics == nil || politics.Name != "（一）思想政治理论课" {
+		t.Fatalf("got %+v", politics)
+	}
+	want := []AuditCourse{{
+		CourseID:  "MARX110001",
+		Name:      "思想道德与法治",
+		Credit:    3,
+		Semester:  "2024-2025 1",
+		Status:    CourseInProgress,
+		CountedIn: []string{"通识教育课程/思想政治理论课"},
+	}}
+	if !reflect.DeepEqual(politics.Courses, want) {
+		t.Errorf("got courses %+v, want %+v", politics.Courses, want)
+	}
+	if core := audit.Category("通识教育课程/核心课程"); core == nil || len(core.Courses) != 0 || len(core.Children) != 0 {
+		t.Errorf("got %+v", core)
+	}
+	if audit.Category("核心课程") != nil {
+		t.Error("found a category by its name")
+	}
+
+	if len(audit.Mismatches) != 0 {
+		t.Errorf("got mismatches %+v", audit.Mismatches)
+	}
+	// What the courses in progress leave of every category.
+	if got := audit.RemainingCredits(); got != 30 {
+		t.Errorf("got %v remaining credits, want 30", got)
+	}
+	if got := audit.Category("通识教育课程").RemainingCredits(); got != 9 {
+		t.Errorf("got %v remaining credits in 通识教育课程, want 9", got)
+	}
+}
+
+func TestParseDegreeAuditSenior(t *testing.T) {
+	audit := readDegreeAudit(t, "degree_audit_senior.json")
+	required := audit.Category("专业教育课程/专业必修课")
+	var statuses []CourseStatus
+	for _, course := range required.Courses {
+		statuses = append(statuses, course.Status)
+	}
+	if want := []CourseStatus{CoursePassed, CourseFailed, CoursePassed, CoursePassed, CourseInProgress}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("got statuses %v, want %v", statuses, want)
+	}
+	if failed := required.Courses[1]; failed.CourseID != "COMP130004" || failed.Grade != "F" {
+		t.Errorf("got %+v, want the failed attempt of 数据结构", failed)
+	}
+
+	// 人工智能导论 counts in both 专业选修课 and 创新创业, but once in the
+	// earned credits of the plan.
+	twice := []string{"专业教育课程/专业选修课", "创新创业"}
+	for _, id := range twice {
+		course := audit.Category(id).Courses[0]
+		if course.CourseID != "COMP130137" || !reflect.DeepEqual(course.CountedIn, twice) {
+			t.Errorf("%s: got %+v, want 人工智能导论 counted in %q", id, course, twice)
+		}
+	}
+	if len(audit.Mismatches) != 0 {
+		t.Errorf("got mismatches %+v", audit.Mismatches)
+	}
+
+	// Every category is complete with the courses in progress, but the plan
+	// lacks the credits counted twice.
+	for _, c := range audit.Plan.Children {
+		if got := c.RemainingCredits(); got != 0 {
+			t.Errorf("got %v remaining credits in %s, want 0", got, c.ID)
+		}
+	}
+	if got := audit.RemainingCredits(); got != 2 {
+		t.Errorf("got %v remaining credits, want 2", got)
+	}
+}
+
+func TestParseDegreeAuditTransfer(t *testing.T) {
+	audit := readDegreeAudit(t, "degree_audit_transfer.json")
+	type transferred struct{ category, courseID, semester string }
+	var got []transferred
+	var walk func(c *AuditCategory)
+	walk = func(c *AuditCategory) {
+		for _, course := range c.Courses {
+			if course.Transferred {
+				got = append(got, transferred{c.ID, course.CourseID, course.Semester})
+			}
+		}
+		for i := range c.Children {
+			walk(&c.Children[i])
+		}
+	}
+	walk(&audit.Plan)
+	want := []transferred{
+		{"通识教育课程/大学英语", "ENGL110003", ""},
+		{"专业教育课程/专业必修课", "MATH120021", "2022-2023 1"},
+		{"专业教育课程/专业选修课", "MATH130005", "2022-2023 2"},
+		{"任意选修课", "MATH120022", "2022-2023 2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got transferred courses %+v, want %+v", got, want)
+	}
+
+	// jwfw did not add the credits transferred to the children of
+	// 专业教育课程 to its own.
+	wantMismatches := []CreditMismatch{{Category: "专业教育课程", EarnedCredits: 11, ChildrenCredits: 14}}
+	if !reflect.DeepEqual(audit.Mismatches, wantMismatches) {
+		t.Errorf("got mismatches %+v, want %+v", audit.Mismatches, wantMismatches)
+	}
+	// 任意选修课 earned more than it requires, which does not count for
+	// 创新创业.
+	if got := audit.Category("任意选修课").RemainingCredits(); got != 0 {
+		t.Errorf("got %v remaining credits in 任意选修课, want 0", got)
+	}
+	if got := audit.RemainingCredits(); got != 8 {
+		t.Errorf("got %v remaining credits, want 8", got)
+	}
+}
+
+func TestCreditMismatches(t *testing.T) {
+	course := func(id string, credit float64, status CourseStatus, countedIn ...string) AuditCourse {
+		return AuditCourse{CourseID: id, Credit: credit, Status: status, CountedIn: countedIn}
+	}
+	plan := AuditCategory{EarnedCredits: 5, Children: []AuditCategory{
+		{ID: "A", EarnedCredits: 5, Courses: []AuditCourse{
+			course("X", 3, CoursePassed, "A", "B"),
+			course("Y", 2, CoursePassed, "A"),
+			course("Z", 4, CourseFailed, "A", "B"),
+		}},
+		{ID: "B", EarnedCredits: 3, Courses: []AuditCourse{
+			course("X", 3, CoursePassed, "A", "B"),
+			course("Z", 4, CourseFailed, "A", "B"),
+		}},
+	}}
+	if got := creditMismatches(&plan, nil); len(got) != 0 {
+		t.Errorf("got %+v with a course counted twice", got)
+	}
+	plan.EarnedCredits = 4.5
+	want := []CreditMismatch{{Category: "", EarnedCredits: 4.5, ChildrenCredits: 5}}
+	if got := creditMismatches(&plan, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseDegreeAuditInvalid(t *testing.T) {
+	cases := map[string]string{
+		"not json":           `<html>`,
+		"array":              `[]`,
+		"root with an id":    `{"id": "A"}`,
+		"child without id":   `{"children": [{"name": "A"}]}`,
+		"duplicate id":       `{"children": [{"id": "A"}, {"id": "B", "children": [{"id": "A"}]}]}`,
+		"negative credits":   `{"children": [{"id": "A", "earned_credits": -1}]}`,
+		"negative credit":    `{"courses": [{"course_id": "A", "credit": -1, "status": "passed", "counted_in": [""]}]}`,
+		"unknown status":     `{"courses": [{"course_id": "A", "credit": 1, "status": "缓考", "counted_in": [""]}]}`,
+		"not in category":    `{"courses": [{"course_id": "A", "credit": 1, "status": "passed", "counted_in": []}]}`,
+		"unknown counted in": `{"courses": [{"course_id": "A", "credit": 1, "status": "passed", "counted_in": ["", "B"]}]}`,
+	}
+	for name, data := range cases {
+		if _, err := parseDegreeAudit([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func TestDegreeAudit(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	data, err := os.ReadFile("testdata/degree_audit_senior.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := lib.fduDegreeAuditAsync
+	t.Cleanup(func() { lib.fduDegreeAuditAsync = orig })
+	lib.fduDegreeAuditAsync = func(_ *cSession, token *cCancelToken, requestID uint64) *cResult {
+		return lib.fduTestResultAsync(string(data), 0, 0, token, requestID)
+	}
+	audit, err := s.DegreeAudit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audit.Plan.Name != "计算机科学与技术（2021级）" || len(audit.Plan.Children) != 4 {
+		t.Errorf("got %+v", audit.Plan)
+	}
+}
+
+func FuzzParseDegreeAudit(f *testing.F) {
+	fixtures := []string{"degree_audit_freshman.json", "degree_audit_senior.json", "degree_audit_transfer.json"}
+	fuzzParse(f, fixtures, nil, parseDegreeAudit, func(t *testing.T, audit *DegreeAudit) {
+		if remaining := audit.RemainingCredits(); remaining < 0 {
+			t.Fatalf("got %v remaining credits", remaining)
+		}
+		for _, m := range audit.Mismatches {
+			if m.ChildrenCredits <= m.EarnedCredits {
+				t.Fatalf("got the mismatch %+v", m)
+			}
+		}
+	})
+}
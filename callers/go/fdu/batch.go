@@ -0,0 +1,205 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// maxBatchRequests is the most requests fdu_batch accepts at once.
+const maxBatchRequests = 32
+
+// Request is a call to make with Session.Batch, built by one of the
+// functions below, named after the Session method they stand for.
+type Request struct {
+	method string
+	args   any
+	parse  func(data []byte) (any, error)
+	// semester is the semester of the arguments, to map to its ID when it
+	// is a school year and term.
+	semester SemesterID
+	// err is the error of invalid arguments, reported without a call.
+	err error
+}
+
+// Result is the result of a Request. Value is of the type the Session
+// method of the request returns, e.g. []Course for CoursesRequest, or nil
+// if Err is not.
+type Result struct {
+	Value any
+	Err   error
+}
+
+type rawBatchRequest struct {
+	Method string `json:"method"`
+	Args   any    `json:"args,omitempty"`
+}
+
+type rawBatchResult struct {
+	Code    ErrCode         `json:"code"`
+	Value   json.RawMessage `json:"value"`
+	Message string          `json:"message"`
+}
+
+func request[T any](method string, args any, parse func(data []byte) (T, error)) Request {
+	return Request{method: method, args: args, parse: func(data []byte) (any, error) {
+		return parse(data)
+	}}
+}
+
+func semesterRequest[T any](method string, semesterID SemesterID, parse func(data []byte) (T, error)) Request {
+	if err := checkSemesterID(semesterID); err != nil {
+		return Request{err: err}
+	}
+	r := request(method, map[string]SemesterID{"semester_id": semesterID}, parse)
+	r.semester = semesterID
+	return r
+}
+
+// CardBalanceRequest stands for Session.CardBalance.
+func CardBalanceRequest() Request {
+	return request("card_balance", nil, parseCardBalance)
+}
+
+// SemestersRequest stands for Session.Semesters.
+func SemestersRequest() Request {
+	return request("semesters", nil, parseSemesters)
+}
+
+// AcademicCalendarRequest stands for Session.AcademicCalendar.
+func AcademicCalendarRequest() Request {
+	return request("academic_calendar", nil, parseAcademicCalendar)
+}
+
+// CoursesRequest stands for Session.Courses.
+func CoursesRequest(semesterID SemesterID) Request {
+	return semesterRequest("courses", semesterID, parseCourses)
+}
+
+// ExamsRequest stands for Session.Exams.
+func ExamsRequest(semesterID SemesterID) Request {
+	return semesterRequest("exams", semesterID, parseExams)
+}
+
+// ScoresRequest stands for Session.Scores.
+func ScoresRequest(semesterID SemesterID) Request {
+	return semesterRequest("scores", semesterID, parseScores)
+}
+
+// GPARequest stands for Session.GPA.
+func GPARequest() Request {
+	return request("gpa", nil, parseGPA)
+}
+
+// EmptyClassroomsRequest stands for Session.EmptyClassrooms.
+func EmptyClassroomsRequest(campus Campus, date time.Time, startSlot, endSlot TimeSlot) Request {
+	if err := checkClassroomQuery(campus, date, startSlot, endSlot); err != nil {
+		return Request{err: err}
+	}
+	args := map[string]any{
+		"campus":     campus,
+		"date":       date.In(chinaTime).Format(time.DateOnly),
+		"start_slot": startSlot,
+		"end_slot":   endSlot,
+	}
+	return request("empty_classrooms", args, func(data []byte) ([]ClassroomBuilding, error) {
+		return parseClassroomBuildings(data, startSlot, endSlot)
+	})
+}
+
+// LibraryAreasRequest stands for Session.LibraryAreas.
+func LibraryAreasRequest() Request {
+	return request("library_areas", nil, parseLibraryAreas)
+}
+
+// LibrarySeatsRequest stands for Session.LibrarySeats.
+func LibrarySeatsRequest(areaID int64) Request {
+	return request("library_seats", map[string]int64{"area_id": areaID}, parseLibrarySeats)
+}
+
+// PERecordsRequest stands for Session.PERecords.
+func PERecordsRequest() Request {
+	return request("pe_records", nil, parsePERecords)
+}
+
+// PETestScoresRequest stands for Session.PETestScores.
+func PETestScoresRequest() Request {
+	return request("pe_test_scores", nil, parsePETestScores)
+}
+
+// Batch makes the requests at once, which libfdu runs concurrently, and
+// returns their results in the same order. It crosses into libfdu once for
+// up to 32 requests, instead of once per request, e.g. for a widget
+// refreshing several values. A failed request only fails its own result,
+// but every result has the error if the batch as a whole fails, e.g. if ctx
+// is done or s is closed. opts apply to each request, which libfdu retries
+// on its own. A semester given by its school year and term is first mapped
+// to its ID with Semesters.
+func (s *Session) Batch(ctx context.Context, reqs []Request, opts ...CallOption) []Result {
+	results := make([]Result, len(reqs))
+	var raw []rawBatchRequest
+	var index []int
+	for i, r := range reqs {
+		switch {
+		case r.err != nil:
+			results[i].Err = r.err
+		case r.method == "":
+			results[i].Err = argumentError("zero Request")
+		default:
+			args := r.args
+			if _, _, ok := r.semester.Term(); ok {
+				id, err := s.semesterID(ctx, r.semester, opts)
+				if err != nil {
+					results[i].Err = err
+					continue
+				}
+				args = map[string]SemesterID{"semester_id": id}
+			}
+			raw = append(raw, rawBatchRequest{Method: r.method, Args: args})
+			index = append(index, i)
+		}
+	}
+	for len(raw) > 0 {
+		n := min(len(raw), maxBatchRequests)
+		s.batch(ctx, reqs, raw[:n], index[:n], results, opts)
+		raw, index = raw[n:], index[n:]
+	}
+	return results
+}
+
+// batch makes the raw requests, which are reqs[index[i]], and stores their
+// results in results.
+func (s *Session) batch(ctx context.Context, reqs []Request, raw []rawBatchRequest, index []int, results []Result, opts []CallOption) {
+	// The arguments are strings and numbers, which always marshal.
+	data, _ := json.Marshal(raw)
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduBatchAsync(ptr, string(data), token, id)
+	}, opts...)
+	var out []rawBatchResult
+	if err == nil {
+		if jsonErr := json.Unmarshal([]byte(v), &out); jsonErr != nil {
+			err = parseError("batch: %v", jsonErr)
+		} else if len(out) != len(raw) {
+			err = parseError("batch: %d results for %d requests", len(out), len(raw))
+		}
+	}
+	for j, i := range index {
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		r := out[j]
+		switch r.Code {
+		case ErrCodeOK:
+			if value, err := reqs[i].parse(r.Value); err != nil {
+				results[i].Err = err
+			} else {
+				results[i].Value = value
+			}
+		case ErrCodePanic:
+			results[i].Err = &PanicError{Message: r.Message}
+		default:
+			results[i].Err = &Error{Code: r.Code, Message: r.Message}
+		}
+	}
+}
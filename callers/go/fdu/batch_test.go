@@ -0,0 +1,214 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// batchValues are the values of the fake exports, by method.
+var batchValues = map[string]string{
+	"card_balance":  "12345",
+	"courses":       "testdata/courses.json",
+	"library_seats": "testdata/library_seats.json",
+	"pe_records":    "testdata/pe_records.json",
+}
+
+func batchValue(t testing.TB, method string) (string, bool) {
+	v, ok := batchValues[method]
+	if !ok || !strings.HasPrefix(v, "testdata/") {
+		return v, ok
+	}
+	data, err := os.ReadFile(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), true
+}
+
+// fakeBatch makes fdu_batch_async answer from batchValues after millis for
+// the rest of the test, like running the requests concurrently, and counts
+// its calls. Methods without a value fail with ErrCodeNetwork.
+func fakeBatch(t testing.TB, millis uint64) *atomic.Int64 {
+	orig := lib.fduBatchAsync
+	t.Cleanup(func() { lib.fduBatchAsync = orig })
+	calls := new(atomic.Int64)
+	lib.fduBatchAsync = func(_ *cSession, requests string, token *cCancelToken, requestID uint64) *cResult {
+		calls.Add(1)
+		var reqs []rawBatchRequest
+		if err := json.Unmarshal([]byte(requests), &reqs); err != nil {
+			t.Error(err)
+		}
+		results := make([]string, len(reqs))
+		for i, r := range reqs {
+			if v, ok := batchValue(t, r.Method); ok {
+				results[i] = fmt.Sprintf(`{"code":0,"value":%s}`, v)
+			} else {
+				results[i] = fmt.Sprintf(`{"code":%d,"message":"no %s"}`, ErrCodeNetwork, r.Method)
+			}
+		}
+		return lib.fduTestResultAsync("["+strings.Join(results, ",")+"]", 0, millis, token, requestID)
+	}
+	return calls
+}
+
+func TestBatch(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	calls := fakeBatch(t, 0)
+
+	results := s.Batch(context.Background(), []Request{
+		CardBalanceRequest(),
+		CoursesRequest("443"),
+		GPARequest(),
+		EmptyClassroomsRequest("moon", time.Now(), 1, 2),
+		{},
+		LibrarySeatsRequest(7),
+	})
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want 1", calls.Load())
+	}
+	if len(results) != 6 {
+		t.Fatalf("got %d results, want 6", len(results))
+	}
+	if balance, ok := results[0].Value.(int64); !ok || balance != 12345 || results[0].Err != nil {
+		t.Errorf("card balance: %#v", results[0])
+	}
+	if courses, ok := results[1].Value.([]Course); !ok || len(courses) != 5 || results[1].Err != nil {
+		t.Errorf("courses: %#v", results[1])
+	}
+	if !errors.Is(results[2].Err, ErrNetwork) || results[2].Value != nil {
+		t.Errorf("GPA: %#v", results[2])
+	}
+	for _, r := range results[3:5] {
+		if !errors.Is(r.Err, ErrInvalidArgument) {
+			t.Errorf("got %#v, want ErrInvalidArgument", r)
+		}
+	}
+	if seats, ok := results[5].Value.([]LibrarySeat); !ok || len(seats) != 3 {
+		t.Errorf("library seats: %#v", results[5])
+	}
+}
+
+func TestBatchChunks(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	calls := fakeBatch(t, 0)
+
+	reqs := make([]Request, 2*maxBatchRequests+1)
+	for i := range reqs {
+		reqs[i] = CardBalanceRequest()
+	}
+	for i, r := range s.Batch(context.Background(), reqs) {
+		if r.Err != nil || r.Value != int64(12345) {
+			t.Fatalf("result %d: %#v", i, r)
+		}
+	}
+	if calls.Load() != 3 {
+		t.Errorf("%d calls, want 3", calls.Load())
+	}
+	if results := s.Batch(context.Background(), nil); len(results) != 0 || calls.Load() != 3 {
+		t.Errorf("got %v and %d calls for no request", results, calls.Load())
+	}
+}
+
+func TestBatchFails(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeBatch(t, 1000)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	for _, r := range s.Batch(ctx, []Request{CardBalanceRequest(), PERecordsRequest()}) {
+		if !errors.Is(r.Err, context.DeadlineExceeded) {
+			t.Errorf("got %#v, want context.DeadlineExceeded", r)
+		}
+	}
+	s.Close()
+	for _, r := range s.Batch(context.Background(), []Request{CardBalanceRequest()}) {
+		if !errors.Is(r.Err, ErrClosed) {
+			t.Errorf("got %#v, want ErrClosed", r)
+		}
+	}
+}
+
+// BenchmarkBatch compares fetching the values of a widget with a call each
+// to fetching them in a batch, all taking millis like a request to the
+// university would. The batch runs them concurrently, so it takes as long as
+// a single call.
+func BenchmarkBatch(b *testing.B) {
+	const millis = 5
+	s, err := testSession()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	var calls atomic.Int64
+	fake := func(method string, token *cCancelToken, requestID uint64) *cResult {
+		calls.Add(1)
+		v, _ := batchValue(b, method)
+		return lib.fduTestResultAsync(v, 0, millis, token, requestID)
+	}
+	origs := lib
+	b.Cleanup(func() { lib = origs })
+	lib.fduCardBalanceAsync = func(_ *cSession, token *cCancelToken, requestID uint64) *cResult {
+		return fake("card_balance", token, requestID)
+	}
+	lib.fduCoursesAsync = func(_ *cSession, _ string, token *cCancelToken, requestID uint64) *cResult {
+		return fake("courses", token, requestID)
+	}
+	lib.fduPERecordsAsync = func(_ *cSession, token *cCancelToken, requestID uint64) *cResult {
+		return fake("pe_records", token, requestID)
+	}
+	lib.fduLibrarySeatsAsync = func(_ *cSession, _ int64, token *cCancelToken, requestID uint64) *cResult {
+		return fake("library_seats", token, requestID)
+	}
+	batchCalls := fakeBatch(b, millis)
+
+	b.Run("sequential", func(b *testing.B) {
+		calls.Store(0)
+		for i := 0; i < b.N; i++ {
+			if _, err := s.CardBalance(ctx); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := s.Courses(ctx, "443"); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := s.PERecords(ctx); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := s.LibrarySeats(ctx, 7); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(calls.Load())/float64(b.N), "calls/op")
+	})
+	b.Run("batch", func(b *testing.B) {
+		batchCalls.Store(0)
+		reqs := []Request{CardBalanceRequest(), CoursesRequest("443"), PERecordsRequest(), LibrarySeatsRequest(7)}
+		for i := 0; i < b.N; i++ {
+			for _, r := range s.Batch(ctx, reqs) {
+				if r.Err != nil {
+					b.Fatal(r.Err)
+				}
+			}
+		}
+		b.ReportMetric(float64(batchCalls.Load())/float64(b.N), "calls/op")
+	})
+}
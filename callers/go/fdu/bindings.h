@@ -0,0 +1,466 @@
+#ifndef LIBFDU_BINDINGS_H
+#define LIBFDU_BINDINGS_H
+
+/* Warning: this file is autogenerated by cbindgen. Don't modify this manually. */
+
+#include <stdarg.h>
+#include <stdbool.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+#define FDU_ABI_VERSION 1
+
+enum FduCall {
+  FDU_CALL_ALLOC_STATS = 1,
+  FDU_CALL_SESSION_RATE_LIMIT_STATS = 2,
+  FDU_CALL_SESSION_UID = 3,
+  FDU_CALL_TEST_SESSION_PING = 4,
+  FDU_CALL_TEST_ECHO = 5,
+};
+typedef int32_t FduCall;
+
+enum FduErrorCode {
+  FDU_ERROR_CODE_OK = 0,
+  FDU_ERROR_CODE_UNKNOWN = 1,
+  FDU_ERROR_CODE_NETWORK = 2,
+  FDU_ERROR_CODE_AUTH_FAILED = 3,
+  FDU_ERROR_CODE_PARSE = 4,
+  FDU_ERROR_CODE_INVALID_ARGUMENT = 5,
+  FDU_ERROR_CODE_PANIC = 6,
+  FDU_ERROR_CODE_CANCELLED = 7,
+  FDU_ERROR_CODE_CAPTCHA_REQUIRED = 8,
+  FDU_ERROR_CODE_QR_DISABLED = 9,
+  FDU_ERROR_CODE_CERT_PIN_MISMATCH = 10,
+  FDU_ERROR_CODE_RATE_LIMITED = 11,
+};
+typedef int32_t FduErrorCode;
+
+enum FduLogLevel {
+  FDU_LOG_LEVEL_OFF = 0,
+  FDU_LOG_LEVEL_ERROR = 1,
+  FDU_LOG_LEVEL_WARN = 2,
+  FDU_LOG_LEVEL_INFO = 3,
+  FDU_LOG_LEVEL_DEBUG = 4,
+  FDU_LOG_LEVEL_TRACE = 5,
+};
+typedef int32_t FduLogLevel;
+
+enum FduTracePhase {
+  FDU_TRACE_PHASE_DNS = 1,
+  FDU_TRACE_PHASE_CONNECT = 2,
+  FDU_TRACE_PHASE_TLS = 3,
+  FDU_TRACE_PHASE_REQUEST = 4,
+  FDU_TRACE_PHASE_PARSE = 5,
+};
+typedef int32_t FduTracePhase;
+
+typedef struct FduCancelToken FduCancelToken;
+
+typedef struct FduSession FduSession;
+
+typedef struct FduBuffer {
+  uint8_t *data;
+  size_t len;
+} FduBuffer;
+
+typedef struct FduResult {
+  char *value;
+  int32_t code;
+  char *message;
+} FduResult;
+
+typedef struct FduCompletion {
+  uint64_t request_id;
+  struct FduResult *result;
+} FduCompletion;
+
+typedef struct FduTraceEvent {
+  uint64_t request_id;
+  int64_t start_unix_nanos;
+  uint64_t duration_nanos;
+  const char *trace_id;
+  const char *host;
+  int32_t phase;
+  int32_t status;
+} FduTraceEvent;
+
+typedef void (*FduLogCallback)(int32_t level, const char *message);
+
+typedef void (*FduTraceCallback)(const struct FduTraceEvent *event);
+
+int add(int a, int b);
+
+uint32_t fdu_abi_version(void);
+
+struct FduResult *fdu_academic_calendar(const struct FduSession *session,
+                                        const struct FduCancelToken *token);
+
+struct FduResult *fdu_academic_calendar_async(const struct FduSession *session,
+                                              const struct FduCancelToken *token,
+                                              uint64_t request_id);
+
+struct FduResult *fdu_alloc_stats(void);
+
+struct FduResult *fdu_announcements(const char *source,
+                                    uint64_t since_id,
+                                    const struct FduCancelToken *token);
+
+struct FduResult *fdu_announcements_async(const char *source,
+                                          uint64_t since_id,
+                                          const struct FduCancelToken *token,
+                                          uint64_t request_id);
+
+struct FduResult *fdu_batch(const struct FduSession *session,
+                            const char *requests,
+                            const struct FduCancelToken *token);
+
+struct FduResult *fdu_batch_async(const struct FduSession *session,
+                                  const char *requests,
+                                  const struct FduCancelToken *token,
+                                  uint64_t request_id);
+
+size_t fdu_call_into(int32_t call,
+                     const struct FduSession *session,
+                     const char *arg,
+                     uint8_t *buf,
+                     size_t cap,
+                     struct FduResult **out);
+
+void fdu_cancel(const struct FduCancelToken *token);
+
+void fdu_cancel_token_free(struct FduCancelToken *token);
+
+struct FduCancelToken *fdu_cancel_token_new(void);
+
+void fdu_cancel_token_set_deadline(const struct FduCancelToken *token, uint64_t timeout_millis);
+
+struct FduResult *fdu_cancel_token_set_trace_id(const struct FduCancelToken *token, const char *trace_id);
+
+struct FduResult *fdu_captcha_image(const char *continuation, struct FduBuffer **out);
+
+struct FduResult *fdu_card_balance(const struct FduSession *session,
+                                   const struct FduCancelToken *token);
+
+struct FduResult *fdu_card_balance_async(const struct FduSession *session,
+                                         const struct FduCancelToken *token,
+                                         uint64_t request_id);
+
+struct FduResult *fdu_card_payment_code(const struct FduSession *session,
+                                        const struct FduCancelToken *token);
+
+struct FduResult *fdu_card_payment_code_async(const struct FduSession *session,
+                                              const struct FduCancelToken *token,
+                                              uint64_t request_id);
+
+struct FduResult *fdu_card_transactions(const struct FduSession *session,
+                                        const char *start_date,
+                                        const char *end_date,
+                                        size_t page,
+                                        const struct FduCancelToken *token);
+
+struct FduResult *fdu_card_transactions_async(const struct FduSession *session,
+                                              const char *start_date,
+                                              const char *end_date,
+                                              size_t page,
+                                              const struct FduCancelToken *token,
+                                              uint64_t request_id);
+
+struct FduResult *fdu_courses(const struct FduSession *session,
+                              const char *semester_id,
+                              const struct FduCancelToken *token);
+
+struct FduResult *fdu_courses_async(const struct FduSession *session,
+                                    const char *semester_id,
+                                    const struct FduCancelToken *token,
+                                    uint64_t request_id);
+
+struct FduResult *fdu_degree_audit(const struct FduSession *session,
+                                   const struct FduCancelToken *token);
+
+struct FduResult *fdu_degree_audit_async(const struct FduSession *session,
+                                         const struct FduCancelToken *token,
+                                         uint64_t request_id);
+
+struct FduResult *fdu_drop(const struct FduSession *session,
+                           int64_t lesson_id,
+                           const struct FduCancelToken *token);
+
+struct FduResult *fdu_drop_async(const struct FduSession *session,
+                                 int64_t lesson_id,
+                                 const struct FduCancelToken *token,
+                                 uint64_t request_id);
+
+struct FduResult *fdu_electricity_balance(const struct FduSession *session,
+                                          const char *campus,
+                                          const char *building,
+                                          const char *room,
+                                          const struct FduCancelToken *token);
+
+struct FduResult *fdu_electricity_balance_async(const struct FduSession *session,
+                                                const char *campus,
+                                                const char *building,
+                                                const char *room,
+                                                const struct FduCancelToken *token,
+                                                uint64_t request_id);
+
+struct FduResult *fdu_empty_classrooms(const struct FduSession *session,
+                                       const char *campus,
+                                       const char *date,
+                                       int32_t start_slot,
+                                       int32_t end_slot,
+                                       const struct FduCancelToken *token);
+
+struct FduResult *fdu_empty_classrooms_async(const struct FduSession *session,
+                                             const char *campus,
+                                             const char *date,
+                                             int32_t start_slot,
+                                             int32_t end_slot,
+                                             const struct FduCancelToken *token,
+                                             uint64_t request_id);
+
+struct FduResult *fdu_enroll(const struct FduSession *session,
+                             int64_t lesson_id,
+                             const struct FduCancelToken *token);
+
+struct FduResult *fdu_enroll_async(const struct FduSession *session,
+                                   int64_t lesson_id,
+                                   const struct FduCancelToken *token,
+                                   uint64_t request_id);
+
+struct FduResult *fdu_exams(const struct FduSession *session,
+                            const char *semester_id,
+                            const struct FduCancelToken *token);
+
+struct FduResult *fdu_exams_async(const struct FduSession *session,
+                                  const char *semester_id,
+                                  const struct FduCancelToken *token,
+                                  uint64_t request_id);
+
+struct FduResult *fdu_gpa(const struct FduSession *session, const struct FduCancelToken *token);
+
+struct FduResult *fdu_gpa_async(const struct FduSession *session,
+                                const struct FduCancelToken *token,
+                                uint64_t request_id);
+
+bool fdu_in_trace_callback(void);
+
+struct FduResult *fdu_init(void);
+
+struct FduResult *fdu_library_areas(const struct FduSession *session,
+                                    const struct FduCancelToken *token);
+
+struct FduResult *fdu_library_areas_async(const struct FduSession *session,
+                                          const struct FduCancelToken *token,
+                                          uint64_t request_id);
+
+struct FduResult *fdu_library_borrow_history(const struct FduSession *session,
+                                             const char *page_token,
+                                             const struct FduCancelToken *token);
+
+struct FduResult *fdu_library_borrow_history_async(const struct FduSession *session,
+                                                   const char *page_token,
+                                                   const struct FduCancelToken *token,
+                                                   uint64_t request_id);
+
+struct FduResult *fdu_library_loans(const struct FduSession *session, const struct FduCancelToken *token);
+
+struct FduResult *fdu_library_loans_async(const struct FduSession *session,
+                                          const struct FduCancelToken *token,
+                                          uint64_t request_id);
+
+struct FduResult *fdu_library_renew(const struct FduSession *session,
+                                    const char *barcode,
+                                    const struct FduCancelToken *token);
+
+struct FduResult *fdu_library_renew_async(const struct FduSession *session,
+                                          const char *barcode,
+                                          const struct FduCancelToken *token,
+                                          uint64_t request_id);
+
+struct FduResult *fdu_library_seats(const struct FduSession *session,
+                                    int64_t area_id,
+                                    const struct FduCancelToken *token);
+
+struct FduResult *fdu_library_seats_async(const struct FduSession *session,
+                                          int64_t area_id,
+                                          const struct FduCancelToken *token,
+                                          uint64_t request_id);
+
+struct FduResult *fdu_login(const char *username,
+                            const char *password,
+                            const struct FduCancelToken *token,
+                            struct FduSession **out);
+
+struct FduResult *fdu_login_with_captcha(const char *continuation,
+                                         const char *answer,
+                                         const struct FduCancelToken *token,
+                                         struct FduSession **out);
+
+struct FduResult *fdu_pe_records(const struct FduSession *session,
+                                 const struct FduCancelToken *token);
+
+struct FduResult *fdu_pe_records_async(const struct FduSession *session,
+                                       const struct FduCancelToken *token,
+                                       uint64_t request_id);
+
+struct FduResult *fdu_pe_test_scores(const struct FduSession *session,
+                                     const struct FduCancelToken *token);
+
+struct FduResult *fdu_pe_test_scores_async(const struct FduSession *session,
+                                           const struct FduCancelToken *token,
+                                           uint64_t request_id);
+
+size_t fdu_poll_completions(struct FduCompletion *buf, size_t n, uint64_t timeout_millis);
+
+struct FduResult *fdu_probe(const char *services,
+                            uint64_t timeout_millis,
+                            const struct FduCancelToken *token);
+
+struct FduResult *fdu_probe_async(const char *services,
+                                  uint64_t timeout_millis,
+                                  const struct FduCancelToken *token,
+                                  uint64_t request_id);
+
+struct FduResult *fdu_profile(const struct FduSession *session, const struct FduCancelToken *token);
+
+struct FduResult *fdu_profile_async(const struct FduSession *session,
+                                    const struct FduCancelToken *token,
+                                    uint64_t request_id);
+
+struct FduResult *fdu_scores(const struct FduSession *session,
+                             const char *semester_id,
+                             const struct FduCancelToken *token);
+
+struct FduResult *fdu_scores_async(const struct FduSession *session,
+                                   const char *semester_id,
+                                   const struct FduCancelToken *token,
+                                   uint64_t request_id);
+
+struct FduResult *fdu_selectable_courses(const struct FduSession *session,
+                                         const char *query,
+                                         const struct FduCancelToken *token);
+
+struct FduResult *fdu_selectable_courses_async(const struct FduSession *session,
+                                               const char *query,
+                                               const struct FduCancelToken *token,
+                                               uint64_t request_id);
+
+struct FduResult *fdu_semesters(const struct FduSession *session,
+                                const struct FduCancelToken *token);
+
+struct FduResult *fdu_semesters_async(const struct FduSession *session,
+                                      const struct FduCancelToken *token,
+                                      uint64_t request_id);
+
+struct FduResult *fdu_session_do(const struct FduSession *session,
+                                 const char *request,
+                                 const uint8_t *body,
+                                 size_t body_len,
+                                 const struct FduCancelToken *token,
+                                 struct FduBuffer **out);
+
+struct FduResult *fdu_session_export(const struct FduSession *session, struct FduBuffer **out);
+
+void fdu_session_free(struct FduSession *session);
+
+struct FduResult *fdu_session_logout(const struct FduSession *session,
+                                     const struct FduCancelToken *token);
+
+struct FduResult *fdu_session_logout_async(const struct FduSession *session,
+                                           const struct FduCancelToken *token,
+                                           uint64_t request_id);
+
+struct FduResult *fdu_session_rate_limit_stats(const struct FduSession *session);
+
+struct FduResult *fdu_session_restore(const uint8_t *data, size_t len, struct FduSession **out);
+
+struct FduResult *fdu_session_set_student_type(const struct FduSession *session, const char *student_type);
+
+struct FduResult *fdu_session_uid(const struct FduSession *session);
+
+struct FduResult *fdu_session_valid(const struct FduSession *session,
+                                    const struct FduCancelToken *token);
+
+struct FduResult *fdu_session_valid_async(const struct FduSession *session,
+                                          const struct FduCancelToken *token,
+                                          uint64_t request_id);
+
+struct FduResult *fdu_set_base_urls(const char *json);
+
+struct FduResult *fdu_set_http_config(const char *json);
+
+struct FduResult *fdu_set_log_callback(int32_t level, FduLogCallback callback);
+
+struct FduResult *fdu_set_recording(const char *dir, uint32_t redact);
+
+struct FduResult *fdu_set_retry_policy(const struct FduSession *session, const char *json);
+
+struct FduResult *fdu_set_trace_callback(FduTraceCallback callback);
+
+void fdu_shutdown(void);
+
+struct FduResult *fdu_test_echo_bytes(const uint8_t *data, size_t len, struct FduBuffer **out);
+
+struct FduResult *fdu_test_error(int32_t code);
+
+struct FduResult *fdu_test_leak(uint32_t count);
+
+int64_t fdu_test_live_buffers(void);
+
+int64_t fdu_test_live_sessions(void);
+
+int64_t fdu_test_live_tokens(void);
+
+struct FduResult *fdu_test_log(void);
+
+struct FduResult *fdu_test_login_captcha(uint64_t ttl_millis);
+
+struct FduResult *fdu_test_pages(const struct FduSession *session,
+                                 const char *page_token,
+                                 uint32_t fail_page,
+                                 const struct FduCancelToken *token);
+
+struct FduResult *fdu_test_pages_async(const struct FduSession *session,
+                                       const char *page_token,
+                                       uint32_t fail_page,
+                                       const struct FduCancelToken *token,
+                                       uint64_t request_id);
+
+struct FduResult *fdu_test_panic(void);
+
+struct FduResult *fdu_test_result(const char *value, int32_t code);
+
+struct FduResult *fdu_test_result_async(const char *value,
+                                        int32_t code,
+                                        uint64_t millis,
+                                        const struct FduCancelToken *token,
+                                        uint64_t request_id);
+
+struct FduResult *fdu_test_session_new(struct FduSession **out);
+
+struct FduResult *fdu_test_session_ping(const struct FduSession *session);
+
+struct FduResult *fdu_test_sleep(uint64_t millis, const struct FduCancelToken *token);
+
+struct FduResult *fdu_test_sleep_async(uint64_t millis,
+                                       const struct FduCancelToken *token,
+                                       uint64_t request_id);
+
+struct FduResult *fdu_test_trace_async(const char *host,
+                                       const char *value,
+                                       const struct FduCancelToken *token,
+                                       uint64_t request_id);
+
+const char *fdu_version(void);
+
+void free_buffer(struct FduBuffer *buf);
+
+void free_result(struct FduResult *r);
+
+void free_string(char *s);
+
+struct FduResult *get_url(const char *url);
+
+struct FduResult *hello_world(void);
+
+#endif /* LIBFDU_BINDINGS_H */
@@ -0,0 +1,25 @@
+package fdu
+
+import "unsafe"
+
+// takeBuffer copies b into a Go slice and frees it. b must not be used
+// afterwards. A nil b is returned as a nil slice.
+func takeBuffer(b *cBuffer) []byte {
+	if b == nil {
+		return nil
+	}
+	defer lib.freeBuffer(b)
+	data := make([]byte, b.len)
+	copy(data, unsafe.Slice(b.data, b.len))
+	return data
+}
+
+// cBytes returns the pointer and length to pass data to libfdu. The pointer
+// refers to the memory of data, which is fine since libfdu only reads it
+// during the call, and must not be used after the call returns.
+func cBytes(data []byte) (*byte, uintptr) {
+	if len(data) == 0 {
+		return nil, 0
+	}
+	return &data[0], uintptr(len(data))
+}
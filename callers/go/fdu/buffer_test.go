@@ -0,0 +1,53 @@
+package fdu
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func TestBufferRoundTrip(t *testing.T) {
+	big := make([]byte, 10<<20)
+	for i := range big {
+		big[i] = byte(i)
+	}
+	cases := map[string][]byte{
+		"empty":      {},
+		"zero bytes": {0, 0, 'a', 0, 'b', 0},
+		"10MB":       big,
+	}
+	for name, data := range cases {
+		got, err := testEchoBytes(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: got %d bytes, want %d", name, len(got), len(data))
+		}
+	}
+}
+
+func TestBufferMemoryLeak(t *testing.T) {
+	base := testLiveBuffers()
+	data := bytes.Repeat([]byte{0, 1, 2, 3}, 1<<18) // 1MB
+
+	runtime.GC()
+	start := getMemoryUsage()
+	for i := 0; i < 1000; i++ {
+		if _, err := testEchoBytes(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runtime.GC()
+	end := getMemoryUsage()
+	fmt.Printf("Memory usage: %d bytes", int64(end)-int64(start))
+
+	// 1GB has been transferred, the Go heap must have reused its memory.
+	if end > start && end-start > 64<<20 {
+		t.Errorf("memory grew by %d bytes", end-start)
+	}
+	if live := testLiveBuffers(); live != base {
+		t.Errorf("%d buffers leaked", live-base)
+	}
+}
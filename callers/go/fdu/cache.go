@@ -0,0 +1,316 @@
+package fdu
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// cacheVersion is the version of the format of the cache files: the files of
+// other versions are discarded.
+const cacheVersion = 1
+
+// cache is the cache of WithCache, nil if there is none. It is set by Init and
+// cleared by Shutdown.
+var cache atomic.Pointer[diskCache]
+
+// Resource is a kind of data which can be cached with WithCache.
+type Resource string
+
+const (
+	// ResourceSemesters is the data of Session.Semesters.
+	ResourceSemesters Resource = "semesters"
+	// ResourceCourses is the data of Session.Courses, per semester.
+	ResourceCourses Resource = "courses"
+	// ResourceExams is the data of Session.Exams, per semester.
+	ResourceExams Resource = "exams"
+	// ResourceScores is the data of Session.Scores, per semester.
+	ResourceScores Resource = "scores"
+	// ResourceAcademicCalendar is the data of Session.AcademicCalendar.
+	ResourceAcademicCalendar Resource = "academic_calendar"
+)
+
+var allResources = []Resource{
+	ResourceSemesters,
+	ResourceCourses,
+	ResourceExams,
+	ResourceScores,
+	ResourceAcademicCalendar,
+}
+
+// WithCache makes Init set up a cache of the data which change rarely, e.g.
+// the course tables, so that an app launched often does not fetch them on
+// every launch. The data of a resource is cached in dir for its duration in
+// ttl, per account and per semester; the resources not in ttl are not cached.
+//
+// A call is answered from the cache while its data is fresh. Once it is
+// stale, the call fetches the data again, and only rewrites the cache file if
+// the data changed. Calls of Session.Batch are never cached. Use
+// Session.Invalidate to drop the data of a resource which is known to have
+// changed, e.g. the course table after Session.Enroll.
+//
+// The cache holds the data alone, never the credentials or the cookies of the
+// sessions, but the data is personal, e.g. the scores: dir should only be
+// readable by the user. Several processes may share dir. A cache file which
+// cannot be read back, e.g. after a crash, is discarded, with a record at
+// LevelDebug for the logger of SetLogger.
+func WithCache(dir string, ttl map[Resource]time.Duration) Option {
+	return func(o *options) {
+		o.cache = &cacheOptions{dir: dir, ttl: ttl}
+	}
+}
+
+type cacheOptions struct {
+	dir string
+	ttl map[Resource]time.Duration
+}
+
+// diskCache stores the data of a session in the directory of its account
+// under dir, in a file per resource and semester.
+type diskCache struct {
+	dir string
+	ttl map[Resource]time.Duration
+}
+
+// cacheEntry is the content of a cache file.
+type cacheEntry struct {
+	Version int `json:"version"`
+	// ETag is the hash of Data, which tells whether the data fetched again
+	// changed, and whether the file was corrupted.
+	ETag string `json:"etag"`
+	// Data is the result of the call, parsed again on each hit.
+	Data string `json:"data"`
+}
+
+func newDiskCache(o cacheOptions) (*diskCache, error) {
+	if o.dir == "" {
+		return nil, argumentError("the cache directory is empty")
+	}
+	ttl := make(map[Resource]time.Duration, len(o.ttl))
+	for r, d := range o.ttl {
+		if err := checkResource(r); err != nil {
+			return nil, err
+		}
+		if d < 0 {
+			return nil, argumentError("the TTL of %s is negative: %v", r, d)
+		}
+		if d > 0 {
+			ttl[r] = d
+		}
+	}
+	return &diskCache{dir: o.dir, ttl: ttl}, nil
+}
+
+func checkResource(r Resource) error {
+	if !slices.Contains(allResources, r) {
+		return argumentError("unknown resource %q", r)
+	}
+	return nil
+}
+
+// etag returns the ETag of data.
+func etag(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheAccount returns the directory of the cache files of s, named after the
+// hash of its student ID, or "" if s has no student ID, in which case its
+// calls are not cached. The student ID is looked up once.
+func (s *Session) cacheAccount() (string, error) {
+	if account := s.account.Load(); account != nil {
+		return *account, nil
+	}
+	v, err := s.callInto(callSessionUID)
+	if err != nil {
+		return "", err
+	}
+	var uid string
+	if err := json.Unmarshal([]byte(v), &uid); err != nil {
+		return "", parseError("student ID: %v", err)
+	}
+	var account string
+	if uid != "" {
+		sum := sha256.Sum256([]byte(uid))
+		account = hex.EncodeToString(sum[:16])
+	}
+	s.account.Store(&account)
+	return account, nil
+}
+
+// cached returns the data of r for s, key being the semester of the data if
+// it has one: from the cache if it is fresh there, and otherwise from fetch,
+// which is then cached. The data is parsed by parse, on hits as well, so that
+// the cache never returns what the call would have rejected.
+func cached[T any](s *Session, r Resource, key string, fetch func() (string, error), parse func(data []byte) (T, error)) (T, error) {
+	c := cache.Load()
+	path, err := c.path(s, r, key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if path == "" {
+		v, err := fetch()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		return parse([]byte(v))
+	}
+
+	e, fresh, ok := c.load(path, c.ttl[r])
+	if fresh {
+		if data, err := parse([]byte(e.Data)); err == nil {
+			return data, nil
+		}
+		c.discard(path, "its data cannot be parsed")
+		ok = false
+	}
+	v, err := fetch()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	data, err := parse([]byte(v))
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if ok && e.ETag == etag(v) {
+		c.touch(path, v)
+	} else {
+		c.store(path, v)
+	}
+	return data, nil
+}
+
+// path returns the cache file of the data of r for s, or "" if it is not
+// cached. c may be nil.
+func (c *diskCache) path(s *Session, r Resource, key string) (string, error) {
+	if c == nil || c.ttl[r] == 0 {
+		return "", nil
+	}
+	account, err := s.cacheAccount()
+	if err != nil || account == "" {
+		return "", err
+	}
+	name := string(r)
+	if key != "" {
+		name += "@" + key
+	}
+	return filepath.Join(c.dir, account, name+".json"), nil
+}
+
+// load reads the cache file at path, and reports whether it is fresh, i.e.
+// written or revalidated less than ttl ago, and whether it could be read at
+// all. A file which cannot be read back is discarded.
+func (c *diskCache) load(path string, ttl time.Duration) (e cacheEntry, fresh, ok bool) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return e, false, false
+	}
+	if err != nil {
+		logf(LevelDebug, "cache: cannot read %s: %v", path, err)
+		return e, false, false
+	}
+	defer f.Close()
+	// The time of the file which is read, even if another process replaces
+	// it in the meantime.
+	info, err := f.Stat()
+	if err != nil {
+		logf(LevelDebug, "cache: cannot read %s: %v", path, err)
+		return e, false, false
+	}
+	if err := json.NewDecoder(f).Decode(&e); err != nil || e.Version != cacheVersion || e.ETag != etag(e.Data) {
+		c.discard(path, "it is corrupted")
+		return cacheEntry{}, false, false
+	}
+	return e, time.Since(info.ModTime()) < ttl, true
+}
+
+// touch marks the cache file at path, holding v, fresh again.
+func (c *diskCache) touch(path, v string) {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		c.store(path, v)
+	}
+}
+
+// store writes v to the cache file at path. It replaces the file atomically, so
+// that the processes sharing the cache never read a partial file.
+func (c *diskCache) store(path, v string) {
+	data, err := json.Marshal(cacheEntry{Version: cacheVersion, ETag: etag(v), Data: v})
+	if err != nil {
+		logf(LevelDebug, "cache: %v", err)
+		return
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		logf(LevelDebug, "cache: cannot write %s: %v", path, err)
+	}
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// discard removes the cache file at path, which cannot be used for why.
+func (c *diskCache) discard(path, why string) {
+	logf(LevelDebug, "cache: discarding %s: %s", path, why)
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logf(LevelDebug, "cache: cannot remove %s: %v", path, err)
+	}
+}
+
+// Invalidate drops the data of r cached for the account of s by WithCache,
+// for every semester, so that the next calls fetch it again, e.g. the course
+// tables after an enrollment. It also drops the data cached by the other
+// sessions of the account. It does nothing without a cache.
+func (s *Session) Invalidate(r Resource) error {
+	if err := checkResource(r); err != nil {
+		return err
+	}
+	c := cache.Load()
+	if c == nil {
+		return nil
+	}
+	account, err := s.cacheAccount()
+	if err != nil || account == "" {
+		return err
+	}
+	dir := filepath.Join(c.dir, account)
+	paths, err := filepath.Glob(filepath.Join(dir, string(r)+"@*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range append(paths, filepath.Join(dir, string(r)+".json")) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCache sets up a cache of the courses in a temporary directory, and
+// serves the Courses calls with the content of *courses, counting them.
+func testCache(t *testing.T, courses *atomic.Pointer[string]) (dir string, calls *atomic.Int64) {
+	t.Helper()
+	dir = t.TempDir()
+	c, err := newDiskCache(cacheOptions{dir: dir, ttl: map[Resource]time.Duration{ResourceCourses: time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Store(c)
+	t.Cleanup(func() { cache.Store(nil) })
+
+	data, err := os.ReadFile("testdata/courses.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := string(data)
+	courses.Store(&v)
+	calls = new(atomic.Int64)
+	orig := lib.fduCoursesAsync
+	t.Cleanup(func() { lib.fduCoursesAsync = orig })
+	lib.fduCoursesAsync = func(_ *cSession, _ string, token *cCancelToken, requestID uint64) *cResult {
+		calls.Add(1)
+		return lib.fduTestResultAsync(*courses.Load(), 0, 0, token, requestID)
+	}
+	return dir, calls
+}
+
+// cacheSession returns a test session with the cache files of account, since
+// test sessions have no student ID.
+func cacheSession(t *testing.T, account string) *Session {
+	t.Helper()
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.account.Store(&account)
+	return s
+}
+
+func TestCacheHit(t *testing.T) {
+	var courses atomic.Pointer[string]
+	dir, calls := testCache(t, &courses)
+	s := cacheSession(t, "a")
+
+	first, err := s.Courses(context.Background(), "443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Courses(context.Background(), "443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("got %d calls, want 1", n)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("got %+v from the cache, want %+v", second, first)
+	}
+
+	// The file holds the data of the call alone.
+	data, err := os.ReadFile(filepath.Join(dir, "a", "courses@443.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 3 || fields["data"] != *courses.Load() {
+		t.Errorf("got cache file %s", data)
+	}
+}
+
+func TestCacheMiss(t *testing.T) {
+	var courses atomic.Pointer[string]
+	dir, calls := testCache(t, &courses)
+	ctx := context.Background()
+
+	for _, c := range []struct {
+		account  string
+		semester SemesterID
+	}{
+		{"a", "443"},
+		// Another semester.
+		{"a", "444"},
+		// Another account.
+		{"b", "443"},
+	} {
+		if _, err := cacheSession(t, c.account).Courses(ctx, c.semester); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("got %d calls, want 3", n)
+	}
+
+	// Without a student ID, the calls are not cached.
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for range 2 {
+		if _, err := s.Courses(ctx, "443"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := calls.Load(); n != 5 {
+		t.Errorf("got %d calls, want 5", n)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("got %d account directories, want 2", len(entries))
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	var courses atomic.Pointer[string]
+	dir, calls := testCache(t, &courses)
+	s := cacheSession(t, "a")
+	ctx := context.Background()
+	path := filepath.Join(dir, "a", "courses@443.json")
+	expire := func() {
+		t.Helper()
+		past := time.Now().Add(-2 * time.Hour)
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.Courses(ctx, "443"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Revalidated without a change: the file is kept, and fresh again.
+	expire()
+	for range 2 {
+		if _, err := s.Courses(ctx, "443"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("got %d calls, want 2", n)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("got cache file %s after revalidation, want %s", after, before)
+	}
+
+	// Revalidated with a change.
+	expire()
+	changed := `[{"course_id":"COMP130004.03","name":"数据结构","weekday":3,"start_slot":1,"end_slot":2,"weeks":[1]}]`
+	courses.Store(&changed)
+	got, err := s.Courses(ctx, "443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "数据结构" {
+		t.Errorf("got %+v, want the changed courses", got)
+	}
+	if got, err := s.Courses(ctx, "443"); err != nil || len(got) != 1 {
+		t.Errorf("got %+v, %v from the cache, want the changed courses", got, err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("got %d calls, want 3", n)
+	}
+}
+
+func TestCacheCorrupted(t *testing.T) {
+	var courses atomic.Pointer[string]
+	dir, calls := testCache(t, &courses)
+	s := cacheSession(t, "a")
+	ctx := context.Background()
+	path := filepath.Join(dir, "a", "courses@443.json")
+
+	var (
+		mu   sync.Mutex
+		logs []string
+	)
+	SetLogger(func(level Level, msg string) {
+		if level == LevelDebug && strings.HasPrefix(msg, "cache: ") {
+			mu.Lock()
+			logs = append(logs, msg)
+			mu.Unlock()
+		}
+	})
+	t.Cleanup(func() { SetLogger(nil) })
+	if err := SetLogLevel(LevelDebug); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetLogLevel(LevelInfo) })
+
+	if _, err := s.Courses(ctx, "443"); err != nil {
+		t.Fatal(err)
+	}
+	for i, corrupted := range []string{
+		`{"version":1,"etag":`,
+		// The data does not match its ETag.
+		`{"version":1,"etag":"0000","data":"[]"}`,
+		// The data matches, but is not courses.
+		`{"version":1,"etag":"` + etag(`{}`) + `","data":"{}"}`,
+	} {
+		if err := os.WriteFile(path, []byte(corrupted), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.Courses(ctx, "443")
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if len(got) == 0 {
+			t.Errorf("%d: got no courses", i)
+		}
+		if n := calls.Load(); n != int64(i+2) {
+			t.Errorf("%d: got %d calls, want %d", i, n, i+2)
+		}
+		// The file was replaced.
+		if _, err := s.Courses(ctx, "443"); err != nil || calls.Load() != int64(i+2) {
+			t.Errorf("%d: got %v and %d calls after the file was replaced", i, err, calls.Load())
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(logs) != 3 || !strings.Contains(logs[0], "discarding") {
+		t.Errorf("got logs %q, want one per corrupted file", logs)
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	var courses atomic.Pointer[string]
+	_, calls := testCache(t, &courses)
+	want, err := parseCourses([]byte(*courses.Load()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two sessions of the same account share the cache files.
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for range 2 {
+		s := cacheSession(t, "a")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				got, err := s.Courses(context.Background(), "443")
+				if err == nil && !reflect.DeepEqual(got, want) {
+					err = errors.New("got wrong courses")
+				}
+				if err == nil && i%10 == 0 {
+					err = s.Invalidate(ResourceCourses)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := calls.Load(); n < 2 || n > 50 {
+		t.Errorf("got %d calls, want mostly hits", n)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	var courses atomic.Pointer[string]
+	_, calls := testCache(t, &courses)
+	s := cacheSession(t, "a")
+	ctx := context.Background()
+
+	for _, semester := range []SemesterID{"443", "444"} {
+		if _, err := s.Courses(ctx, semester); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Invalidate(ResourceCourses); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing to invalidate.
+	if err := s.Invalidate(ResourceExams); err != nil {
+		t.Fatal(err)
+	}
+	for _, semester := range []SemesterID{"443", "444"} {
+		if _, err := s.Courses(ctx, semester); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("got %d calls, want 4", n)
+	}
+	if err := s.Invalidate("grades"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("got %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestWithCacheInvalid(t *testing.T) {
+	for _, o := range []cacheOptions{
+		{dir: ""},
+		{dir: t.TempDir(), ttl: map[Resource]time.Duration{"grades": time.Hour}},
+		{dir: t.TempDir(), ttl: map[Resource]time.Duration{ResourceCourses: -time.Hour}},
+	} {
+		if _, err := newDiskCache(o); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%+v: got %v, want ErrInvalidArgument", o, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// makeupRange is how far from a makeup day the holiday it makes up for is
+// looked for.
+const makeupRange = 14
+
+// AcademicCalendar is the calendar of the semesters, with the holidays and
+// the makeup days around them (调休). All dates are at midnight, China time.
+type AcademicCalendar struct {
+	Semesters []CalendarSemester
+	// Holidays are the days without classes during semesters.
+	Holidays []Holiday
+	// Adjustments are the makeup days, usually on weekends, with the classes
+	// of another weekday to make up for a holiday.
+	Adjustments []DayAdjustment
+}
+
+// CalendarSemester is a semester of an AcademicCalendar.
+type CalendarSemester struct {
+	// ID, SchoolYear and Name are those of the Semester.
+	ID         SemesterID
+	SchoolYear string
+	Name       string
+	// Start is the first day of week 1, which may not be a Monday: weeks
+	// run from Monday to Sunday, so week 1 is then shorter. End is the last
+	// day of the semester.
+	Start time.Time
+	End   time.Time
+}
+
+// Holiday is a day without classes.
+type Holiday struct {
+	Date time.Time
+	// Name is e.g. "国庆节".
+	Name string
+}
+
+// DayAdjustment is a makeup day, which has the classes of ActsAsWeekday.
+type DayAdjustment struct {
+	Date          time.Time
+	ActsAsWeekday Weekday
+}
+
+type rawAcademicCalendar struct {
+	Semesters []struct {
+		ID         SemesterID `json:"id"`
+		SchoolYear string     `json:"school_year"`
+		Name       string     `json:"name"`
+		// Start and End are e.g. "2023-09-11".
+		Start string `json:"start"`
+		End   string `json:"end"`
+	} `json:"semesters"`
+	Holidays []struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	} `json:"holidays"`
+	Adjustments []struct {
+		Date          string `json:"date"`
+		ActsAsWeekday int    `json:"acts_as_weekday"`
+	} `json:"adjustments"`
+}
+
+// AcademicCalendar returns the academic calendar, see WeekOf and DayOf to
+// find the teaching week of a day.
+func (s *Session) AcademicCalendar(ctx context.Context, opts ...CallOption) (*AcademicCalendar, error) {
+	return cached(s, ResourceAcademicCalendar, "", func() (string, error) {
+		return s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+			return lib.fduAcademicCalendarAsync(ptr, token, id)
+		}, opts...)
+	}, parseAcademicCalendar)
+}
+
+// WeekOf returns the teaching week (第 N 周) of the day of t, counted from 1,
+// and whether the day is in a semester, including holidays. A makeup day is
+// in the week of the holiday it makes up for, which may be the week before
+// or after. Outside semesters, e.g. during the winter break, week is 0 and
+// inSemester false.
+func (c *AcademicCalendar) WeekOf(t time.Time) (week int, inSemester bool) {
+	d := dayOf(t)
+	sem, ok := c.semesterOf(d)
+	if !ok {
+		return 0, false
+	}
+	if a, ok := c.adjustment(d); ok {
+		d = c.madeUpDay(d, a.ActsAsWeekday)
+	}
+	return teachingWeek(sem, d), true
+}
+
+// DayOf returns the teaching week of the day of t, like WeekOf, and the
+// weekday whose courses take place on that day: the weekday of t, or the one
+// a makeup day acts as. ok is false outside
+// semesters and on holidays, when no course takes place.
+//
+// A course takes place on the day if it has the weekday, and week in its
+// Weeks.
+func (c *AcademicCalendar) DayOf(t time.Time) (week int, weekday Weekday, ok bool) {
+	d := dayOf(t)
+	if c.holiday(d) {
+		return 0, 0, false
+	}
+	week, ok = c.WeekOf(t)
+	if !ok {
+		return 0, 0, false
+	}
+	if a, ok := c.adjustment(d); ok {
+		return week, a.ActsAsWeekday, true
+	}
+	return week, weekdayOf(d), true
+}
+
+// Semester returns the calendar of the semester with the given ID, or
+// school year and term, alone, with the holidays and makeup days during it,
+// and whether there is such a semester.
+func (c *AcademicCalendar) Semester(id SemesterID) (AcademicCalendar, bool) {
+	for _, sem := range c.Semesters {
+		if !id.is(sem.ID, sem.SchoolYear, sem.Name) {
+			continue
+		}
+		in := func(t time.Time) bool {
+			d := dayOf(t)
+			return dayOf(sem.Start) <= d && d <= dayOf(sem.End)
+		}
+		only := AcademicCalendar{Semesters: []CalendarSemester{sem}}
+		for _, h := range c.Holidays {
+			if in(h.Date) {
+				only.Holidays = append(only.Holidays, h)
+			}
+		}
+		for _, a := range c.Adjustments {
+			if in(a.Date) {
+				only.Adjustments = append(only.Adjustments, a)
+			}
+		}
+		return only, true
+	}
+	return AcademicCalendar{}, false
+}
+
+// semesterOf returns the semester containing the day d.
+func (c *AcademicCalendar) semesterOf(d int) (CalendarSemester, bool) {
+	for _, sem := range c.Semesters {
+		if dayOf(sem.Start) <= d && d <= dayOf(sem.End) {
+			return sem, true
+		}
+	}
+	return CalendarSemester{}, false
+}
+
+func (c *AcademicCalendar) holiday(d int) bool {
+	for _, h := range c.Holidays {
+		if dayOf(h.Date) == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *AcademicCalendar) adjustment(d int) (DayAdjustment, bool) {
+	for _, a := range c.Adjustments {
+		if dayOf(a.Date) == d {
+			return a, true
+		}
+	}
+	return DayAdjustment{}, false
+}
+
+// madeUpDay returns the holiday with the given weekday nearest to the makeup
+// day d, or d itself if there is none within makeupRange days, in which case
+// the makeup day simply counts in its own week.
+func (c *AcademicCalendar) madeUpDay(d int, weekday Weekday) int {
+	for i := 1; i <= makeupRange; i++ {
+		for _, h := range []int{d - i, d + i} {
+			if weekdayOf(h) == weekday && c.holiday(h) {
+				return h
+			}
+		}
+	}
+	return d
+}
+
+// teachingWeek returns the week of the day d in sem.
+func teachingWeek(sem CalendarSemester, d int) int {
+	return (monday(d)-monday(dayOf(sem.Start)))/7 + 1
+}
+
+// dayOf returns the day of t in China time, counted from 1970-01-01.
+func dayOf(t time.Time) int {
+	y, m, d := t.In(chinaTime).Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60))
+}
+
+// weekdayOf returns the weekday of the day d. 1970-01-01 was a Thursday.
+func weekdayOf(d int) Weekday {
+	return Weekday(((d+3)%7+7)%7 + 1)
+}
+
+// monday returns the Monday on or before the day d.
+func monday(d int) int {
+	return d - int(weekdayOf(d)) + 1
+}
+
+func parseAcademicCalendar(data []byte) (*AcademicCalendar, error) {
+	var raw rawAcademicCalendar
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, parseError("academic calendar: %v", err)
+	}
+	var c AcademicCalendar
+	for _, r := range raw.Semesters {
+		start, err := parseDate(r.Start)
+		if err != nil {
+			return nil, parseError("semester %s: invalid start %q", r.ID, r.Start)
+		}
+		end, err := parseDate(r.End)
+		if err != nil || end.Before(start) {
+			return nil, parseError("semester %s: invalid end %q", r.ID, r.End)
+		}
+		c.Semesters = append(c.Semesters, CalendarSemester{ID: r.ID, SchoolYear: r.SchoolYear, Name: r.Name, Start: start, End: end})
+	}
+	for _, r := range raw.Holidays {
+		date, err := parseDate(r.Date)
+		if err != nil {
+			return nil, parseError("holiday %s: invalid date %q", r.Name, r.Date)
+		}
+		c.Holidays = append(c.Holidays, Holiday{Date: date, Name: r.Name})
+	}
+	for _, r := range raw.Adjustments {
+		date, err := parseDate(r.Date)
+		if err != nil {
+			return nil, parseError("makeup day: invalid date %q", r.Date)
+		}
+		weekday := Weekday(r.ActsAsWeekday)
+		if !weekday.Valid() {
+			return nil, parseError("makeup day %s: invalid weekday %d", r.Date, r.ActsAsWeekday)
+		}
+		c.Adjustments = append(c.Adjustments, DayAdjustment{Date: date, ActsAsWeekday: weekday})
+	}
+	return &c, nil
+}
@@ -0,0 +1,177 @@
+package fdu
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testCalendar(t *testing.T) *AcademicCalendar {
+	t.Helper()
+	data, err := os.ReadFile("testdata/calendar.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := parseAcademicCalendar(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 12, 0, 0, 0, chinaTime)
+}
+
+func TestParseAcademicCalendar(t *testing.T) {
+	c := testCalendar(t)
+	if len(c.Semesters) != 2 || len(c.Holidays) != 13 || len(c.Adjustments) != 5 {
+		t.Fatalf("got %d semesters, %d holidays and %d adjustments", len(c.Semesters), len(c.Holidays), len(c.Adjustments))
+	}
+	autumn := c.Semesters[0]
+	if autumn.ID != "443" || !autumn.Start.Equal(time.Date(2023, 9, 11, 0, 0, 0, 0, chinaTime)) {
+		t.Errorf("got %+v", autumn)
+	}
+	if a := c.Adjustments[0]; a.ActsAsWeekday != 4 || !a.Date.Equal(time.Date(2023, 10, 7, 0, 0, 0, 0, chinaTime)) {
+		t.Errorf("got %+v", a)
+	}
+
+	for _, data := range []string{
+		`{"semesters": [{"id": "1", "start": "2024-01-14", "end": "2023-09-11"}]}`,
+		`{"holidays": [{"date": "10/01"}]}`,
+		`{"adjustments": [{"date": "2023-10-07", "acts_as_weekday": 0}]}`,
+	} {
+		if _, err := parseAcademicCalendar([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", data, err)
+		}
+	}
+}
+
+func TestWeekOf(t *testing.T) {
+	c := testCalendar(t)
+	for _, tc := range []struct {
+		t          time.Time
+		week       int
+		inSemester bool
+	}{
+		{day(2023, 9, 10), 0, false},
+		{day(2023, 9, 11), 1, true},
+		{day(2023, 9, 17), 1, true},
+		{day(2023, 9, 18), 2, true},
+		// The National Day holidays and their makeup weekend are in week 4.
+		{day(2023, 10, 5), 4, true},
+		{day(2023, 10, 7), 4, true},
+		{day(2023, 10, 8), 4, true},
+		{day(2023, 10, 9), 5, true},
+		{day(2024, 1, 14), 18, true},
+		// Winter break.
+		{day(2024, 1, 15), 0, false},
+		{day(2024, 2, 1), 0, false},
+		{day(2024, 2, 25), 0, false},
+		{day(2024, 2, 26), 1, true},
+		// Makeup days count in the week of the holiday they make up for:
+		// the Sunday before the Labor Day week, and the Saturday after it.
+		{day(2024, 4, 28), 10, true},
+		{day(2024, 5, 11), 10, true},
+		{day(2024, 5, 12), 11, true},
+		{day(2024, 6, 30), 18, true},
+		{day(2024, 7, 1), 0, false},
+		// The day is that of China time.
+		{time.Date(2023, 9, 10, 16, 0, 0, 0, time.UTC), 1, true},
+	} {
+		week, inSemester := c.WeekOf(tc.t)
+		if week != tc.week || inSemester != tc.inSemester {
+			t.Errorf("WeekOf(%v) = (%d, %v), want (%d, %v)", tc.t, week, inSemester, tc.week, tc.inSemester)
+		}
+	}
+}
+
+func TestDayOf(t *testing.T) {
+	c := testCalendar(t)
+	for _, tc := range []struct {
+		t       time.Time
+		week    int
+		weekday Weekday
+		ok      bool
+	}{
+		{day(2023, 9, 28), 3, 4, true},
+		{day(2023, 9, 29), 0, 0, false},
+		{day(2023, 10, 5), 0, 0, false},
+		// The makeup weekend has the classes of the last two holidays.
+		{day(2023, 10, 7), 4, 4, true},
+		{day(2023, 10, 8), 4, 5, true},
+		{day(2023, 10, 14), 5, 6, true},
+		{day(2024, 2, 1), 0, 0, false},
+		{day(2024, 4, 28), 10, 4, true},
+	} {
+		week, weekday, ok := c.DayOf(tc.t)
+		if week != tc.week || weekday != tc.weekday || ok != tc.ok {
+			t.Errorf("DayOf(%v) = (%d, %d, %v), want (%d, %d, %v)", tc.t, week, weekday, ok, tc.week, tc.weekday, tc.ok)
+		}
+	}
+}
+
+func TestWeekOfMidWeekStart(t *testing.T) {
+	// A summer term starting on a Wednesday.
+	c := &AcademicCalendar{Semesters: []CalendarSemester{{
+		Start: time.Date(2024, 7, 3, 0, 0, 0, 0, chinaTime),
+		End:   time.Date(2024, 8, 11, 0, 0, 0, 0, chinaTime),
+	}}}
+	for _, tc := range []struct {
+		t          time.Time
+		week       int
+		inSemester bool
+	}{
+		{day(2024, 7, 1), 0, false},
+		{day(2024, 7, 3), 1, true},
+		{day(2024, 7, 7), 1, true},
+		{day(2024, 7, 8), 2, true},
+		{day(2024, 8, 11), 6, true},
+	} {
+		week, inSemester := c.WeekOf(tc.t)
+		if week != tc.week || inSemester != tc.inSemester {
+			t.Errorf("WeekOf(%v) = (%d, %v), want (%d, %v)", tc.t, week, inSemester, tc.week, tc.inSemester)
+		}
+	}
+}
+
+func TestCalendarSemester(t *testing.T) {
+	c := testCalendar(t)
+	spring, ok := c.Semester("444")
+	if !ok {
+		t.Fatal("semester 444 not found")
+	}
+	if len(spring.Semesters) != 1 || spring.Semesters[0].ID != "444" {
+		t.Fatalf("got semesters %+v", spring.Semesters)
+	}
+	// New Year's Day is in the winter break, out of the spring semester.
+	if len(spring.Holidays) != 6 || len(spring.Adjustments) != 3 {
+		t.Errorf("got %d holidays and %d adjustments, want 6 and 3", len(spring.Holidays), len(spring.Adjustments))
+	}
+	if week, ok := spring.WeekOf(day(2024, 4, 28)); !ok || week != 10 {
+		t.Errorf("got week %d, %v", week, ok)
+	}
+	if _, ok := c.Semester("1"); ok {
+		t.Error("found semester 1")
+	}
+	if bySchoolYear, ok := c.Semester("2023-2024-2"); !ok || !reflect.DeepEqual(bySchoolYear, spring) {
+		t.Errorf("got %+v for 2023-2024-2, want semester 444", bySchoolYear)
+	}
+}
+
+func FuzzParseAcademicCalendar(f *testing.F) {
+	fuzzParse(f, []string{"calendar.json"}, nil, parseAcademicCalendar, func(t *testing.T, c *AcademicCalendar) {
+		for _, sem := range c.Semesters {
+			if sem.End.Before(sem.Start) {
+				t.Fatalf("accepted the invalid semester %+v", sem)
+			}
+			// The lookups work on whatever calendar is accepted.
+			for d := sem.Start; !d.After(sem.End) && d.Before(sem.Start.AddDate(0, 0, 400)); d = d.AddDate(0, 0, 1) {
+				c.DayOf(d)
+			}
+			c.Semester(sem.ID)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+// Code generated by internal/gen from bindings.h; DO NOT EDIT.
+
+package fdu
+
+import "strconv"
+
+// call is a value of FduCall.
+type call int32
+
+const (
+	callAllocStats            call = 1 // FDU_CALL_ALLOC_STATS
+	callSessionRateLimitStats call = 2 // FDU_CALL_SESSION_RATE_LIMIT_STATS
+	callSessionUID            call = 3 // FDU_CALL_SESSION_UID
+	callTestSessionPing       call = 4 // FDU_CALL_TEST_SESSION_PING
+	callTestEcho              call = 5 // FDU_CALL_TEST_ECHO
+)
+
+// allcalls lists the call constants in the order of bindings.h.
+var allcalls = []call{
+	callAllocStats,
+	callSessionRateLimitStats,
+	callSessionUID,
+	callTestSessionPing,
+	callTestEcho,
+}
+
+func (c call) String() string {
+	switch c {
+	case callAllocStats:
+		return "ALLOC_STATS"
+	case callSessionRateLimitStats:
+		return "SESSION_RATE_LIMIT_STATS"
+	case callSessionUID:
+		return "SESSION_UID"
+	case callTestSessionPing:
+		return "TEST_SESSION_PING"
+	case callTestEcho:
+		return "TEST_ECHO"
+	}
+	return "call(" + strconv.FormatInt(int64(c), 10) + ")"
+}
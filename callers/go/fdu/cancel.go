@@ -0,0 +1,73 @@
+package fdu
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// callContext runs f, which performs a blocking call into libfdu with the
+// cancellation token it is given, and returns its results.
+//
+// If ctx is done before f returns, the token is cancelled and ctx.Err() is
+// returned immediately. f keeps running in the background until libfdu
+// notices the cancellation, and its results are dropped; f must therefore
+// convert and free everything returned by libfdu itself (e.g. by calling
+// takeResult), so that nothing leaks however the race between completion and
+// cancellation turns out.
+func callContext[T any](ctx context.Context, f func(token *cCancelToken) (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if err := checkInit(); err != nil {
+		return zero, err
+	}
+	if ctx.Done() == nil {
+		// The context can never be cancelled, no need for a goroutine.
+		return f(nil)
+	}
+
+	type outcome struct {
+		value T
+		err   error
+	}
+	var (
+		// mu guards finished, so that the token is never cancelled after
+		// being freed.
+		mu       sync.Mutex
+		finished bool
+	)
+	token := newCancelToken(ctx)
+	done := make(chan outcome, 1)
+	go func() {
+		value, err := f(token)
+		mu.Lock()
+		finished = true
+		lib.fduCancelTokenFree(token)
+		mu.Unlock()
+		done <- outcome{value, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.value, o.err
+	case <-ctx.Done():
+		mu.Lock()
+		if !finished {
+			lib.fduCancel(token)
+		}
+		mu.Unlock()
+		return zero, ctx.Err()
+	}
+}
+
+// newCancelToken creates a cancellation token carrying the deadline of ctx,
+// if any, before which libfdu fails rather than waits for a rate limit.
+func newCancelToken(ctx context.Context) *cCancelToken {
+	token := lib.fduCancelTokenNew()
+	if deadline, ok := ctx.Deadline(); ok {
+		lib.fduCancelTokenSetDeadline(token, uint64(max(0, millis(time.Until(deadline)))))
+	}
+	return token
+}
@@ -0,0 +1,70 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := testSleep(ctx, 10000)
+	elapsed := time.Since(start)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed > 100*time.Millisecond {
+		t.Errorf("returned after %v", elapsed)
+	}
+}
+
+func TestCancelCompleted(t *testing.T) {
+	if err := testSleep(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := testSleep(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if err := testSleep(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestCancelRace(t *testing.T) {
+	base := testLiveTokens()
+
+	// Cancel around the time the calls complete, so that both sides of the
+	// race are hit. Neither must crash or leak.
+	var wg sync.WaitGroup
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(i%5)*time.Millisecond)
+			defer cancel()
+			err := testSleep(ctx, 2)
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Abandoned calls free their tokens in the background.
+	deadline := time.Now().Add(time.Second)
+	for testLiveTokens() != base && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if live := testLiveTokens(); live != base {
+		t.Errorf("%d tokens leaked", live-base)
+	}
+}
@@ -0,0 +1,60 @@
+package fdu
+
+import "context"
+
+// CaptchaRequiredError is returned by Login when UIS asks for a captcha, and
+// by LoginWithCaptcha when the answer is wrong. Show Image to the user and
+// pass their answer to LoginWithCaptcha along with Token:
+//
+//	s, err := fdu.Login(ctx, username, password)
+//	var captcha *fdu.CaptchaRequiredError
+//	for errors.As(err, &captcha) {
+//		answer := ask(captcha.Image)
+//		s, err = fdu.LoginWithCaptcha(ctx, captcha.Token, answer)
+//	}
+type CaptchaRequiredError struct {
+	// Token is the continuation of the login. It can be used only once, and
+	// expires after a few minutes.
+	Token string
+	// Image is the captcha image, usually a PNG or a JPEG.
+	Image []byte
+	// Message is the description provided by the library.
+	Message string
+}
+
+func (e *CaptchaRequiredError) Error() string {
+	return "fdu: captcha required: " + e.Message
+}
+
+// Unwrap returns ErrCaptchaRequired.
+func (e *CaptchaRequiredError) Unwrap() error {
+	return ErrCaptchaRequired
+}
+
+// newCaptchaRequiredError fetches the image of the continuation token to
+// build a *CaptchaRequiredError.
+func newCaptchaRequiredError(token, message string) error {
+	var buf *cBuffer
+	if _, err := takeResult(lib.fduCaptchaImage(token, &buf)); err != nil {
+		return err
+	}
+	return &CaptchaRequiredError{Token: token, Image: takeBuffer(buf), Message: message}
+}
+
+// LoginWithCaptcha finishes a login interrupted by a *CaptchaRequiredError
+// with the answer to its captcha. The token is consumed even if the call
+// fails: a wrong answer returns another *CaptchaRequiredError with a fresh
+// image and token, while an expired or already used token is rejected with an
+// error wrapping ErrInvalidArgument, in which case call Login again.
+func LoginWithCaptcha(ctx context.Context, token, answer string) (*Session, error) {
+	if err := checkCStrings("token", token, "answer", answer); err != nil {
+		return nil, err
+	}
+	return callContext(ctx, func(cancel *cCancelToken) (*Session, error) {
+		var ptr *cSession
+		if _, err := takeResult(lib.fduLoginWithCaptcha(token, answer, cancel, &ptr)); err != nil {
+			return nil, err
+		}
+		return newSession(ptr), nil
+	})
+}
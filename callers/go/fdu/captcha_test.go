@@ -0,0 +1,64 @@
+package fdu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func captchaRequired(t *testing.T, err error) *CaptchaRequiredError {
+	t.Helper()
+	var captcha *CaptchaRequiredError
+	if !errors.As(err, &captcha) {
+		t.Fatalf("got %v, want *CaptchaRequiredError", err)
+	}
+	if !errors.Is(err, ErrCaptchaRequired) {
+		t.Errorf("%v does not wrap ErrCaptchaRequired", err)
+	}
+	if captcha.Token == "" || !bytes.HasPrefix(captcha.Image, []byte("\x89PNG")) {
+		t.Errorf("got token %q and image %q", captcha.Token, captcha.Image)
+	}
+	return captcha
+}
+
+func TestLoginWithCaptcha(t *testing.T) {
+	ctx := context.Background()
+	base := testLiveSessions()
+	captcha := captchaRequired(t, testLoginCaptcha(time.Minute))
+
+	// A wrong answer is retryable with a fresh token.
+	s, err := LoginWithCaptcha(ctx, captcha.Token, "0000")
+	if s != nil {
+		t.Fatalf("got session with wrong answer")
+	}
+	retry := captchaRequired(t, err)
+	if retry.Token == captcha.Token {
+		t.Error("got the same token after a wrong answer")
+	}
+	// The first token is used up.
+	if _, err := LoginWithCaptcha(ctx, captcha.Token, "1234"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("reusing token: got %v, want ErrInvalidArgument", err)
+	}
+
+	s, err = LoginWithCaptcha(ctx, retry.Token, "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if _, err := LoginWithCaptcha(ctx, retry.Token, "1234"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("reusing token: got %v, want ErrInvalidArgument", err)
+	}
+	if live := testLiveSessions(); live != base {
+		t.Errorf("%d sessions leaked", live-base)
+	}
+}
+
+func TestLoginWithCaptchaTimeout(t *testing.T) {
+	captcha := captchaRequired(t, testLoginCaptcha(10*time.Millisecond))
+	time.Sleep(50 * time.Millisecond)
+	if _, err := LoginWithCaptcha(context.Background(), captcha.Token, "1234"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("got %v, want ErrInvalidArgument", err)
+	}
+}
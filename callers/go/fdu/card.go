@@ -0,0 +1,128 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// CardTransaction is a transaction of the campus card (一卡通).
+type CardTransaction struct {
+	Time time.Time
+	// Location is e.g. "北区食堂".
+	Location string
+	// Amount is in cents, negative for spending and positive for top-ups.
+	Amount int64
+	// Balance is the balance after the transaction, in cents.
+	Balance int64
+}
+
+type rawCardTransaction struct {
+	// Time is e.g. "2023-01-03 12:00:00".
+	Time     string `json:"time"`
+	Location string `json:"location"`
+	Amount   int64  `json:"amount"`
+	Balance  int64  `json:"balance"`
+}
+
+type rawCardTransactionPage struct {
+	Page         int                  `json:"page"`
+	TotalPages   int                  `json:"total_pages"`
+	Transactions []rawCardTransaction `json:"transactions"`
+}
+
+// CardBalance returns the balance of the campus card in cents.
+func (s *Session) CardBalance(ctx context.Context, opts ...CallOption) (int64, error) {
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduCardBalanceAsync(ptr, token, id)
+	}, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return parseCardBalance([]byte(v))
+}
+
+// CardTransactions returns the campus card transactions in [from, to), oldest
+// pages last as listed by the card system. See CardTransactionPages to avoid
+// holding all of them at once.
+func (s *Session) CardTransactions(ctx context.Context, from, to time.Time, opts ...CallOption) ([]CardTransaction, error) {
+	var transactions []CardTransaction
+	err := s.CardTransactionPages(ctx, from, to, func(page []CardTransaction) error {
+		transactions = append(transactions, page...)
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// CardTransactionPages calls fn with each page of the campus card
+// transactions in [from, to), which are fetched one at a time. Identical
+// transactions in the same second are distinct payments and reported as is.
+// If fn returns an error, CardTransactionPages stops and returns it.
+func (s *Session) CardTransactionPages(ctx context.Context, from, to time.Time, fn func(page []CardTransaction) error, opts ...CallOption) error {
+	// The card system only filters by day, both inclusive.
+	startDate := from.In(chinaTime).Format(time.DateOnly)
+	endDate := to.In(chinaTime).Format(time.DateOnly)
+
+	for page := 1; ; page++ {
+		v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+			return lib.fduCardTransactionsAsync(ptr, startDate, endDate, uintptr(page), token, id)
+		}, opts...)
+		if err != nil {
+			return err
+		}
+		transactions, totalPages, err := parseCardTransactionPage([]byte(v), from, to)
+		if err != nil {
+			return err
+		}
+		if len(transactions) > 0 {
+			if err := fn(transactions); err != nil {
+				return err
+			}
+		}
+		if page >= totalPages {
+			return nil
+		}
+	}
+}
+
+// parseCardBalance parses the balance of the card, in cents.
+func parseCardBalance(data []byte) (int64, error) {
+	balance, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, parseError("card balance: %v", err)
+	}
+	return balance, nil
+}
+
+// parseCardTransactionPage parses a page of transactions, keeping only those
+// in [from, to), and returns them with the total number of pages.
+func parseCardTransactionPage(data []byte, from, to time.Time) ([]CardTransaction, int, error) {
+	var raw rawCardTransactionPage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, parseError("card transactions: %v", err)
+	}
+	if raw.Page < 1 || raw.TotalPages < raw.Page {
+		return nil, 0, parseError("card transactions: invalid page %d/%d", raw.Page, raw.TotalPages)
+	}
+	transactions := make([]CardTransaction, 0, len(raw.Transactions))
+	for _, t := range raw.Transactions {
+		tm, err := parseChinaTime(time.DateTime, t.Time)
+		if err != nil {
+			return nil, 0, parseError("card transaction: invalid time %q", t.Time)
+		}
+		if tm.Before(from) || !tm.Before(to) {
+			continue
+		}
+		transactions = append(transactions, CardTransaction{
+			Time:     tm,
+			Location: t.Location,
+			Amount:   t.Amount,
+			Balance:  t.Balance,
+		})
+	}
+	return transactions, raw.TotalPages, nil
+}
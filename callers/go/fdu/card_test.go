@@ -0,0 +1,78 @@
+package fdu
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseCardTransactionPage(t *testing.T) {
+	data, err := os.ReadFile("testdata/card_transactions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, chinaTime)
+	to := time.Date(2023, 2, 1, 0, 0, 0, 0, chinaTime)
+	transactions, totalPages, err := parseCardTransactionPage(data, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totalPages != 3 {
+		t.Errorf("got %d pages, want 3", totalPages)
+	}
+	// The one on 2022-12-31 is out of range.
+	if len(transactions) != 4 {
+		t.Fatalf("got %d transactions, want 4", len(transactions))
+	}
+
+	if got := transactions[0]; got.Amount != -1250 || got.Balance != 8750 || got.Location != "北区食堂" {
+		t.Errorf("got %+v", got)
+	}
+	if want := time.Date(2023, 1, 3, 18, 2, 11, 0, chinaTime); !transactions[0].Time.Equal(want) {
+		t.Errorf("got time %v, want %v", transactions[0].Time, want)
+	}
+	// Two payments in the same second are both kept.
+	if a, b := transactions[1], transactions[2]; !a.Time.Equal(b.Time) || a.Amount != b.Amount || a.Balance == b.Balance {
+		t.Errorf("got %+v and %+v", a, b)
+	}
+	if topUp := transactions[3]; topUp.Amount != 10000 {
+		t.Errorf("got top-up %+v", topUp)
+	}
+}
+
+func TestParseCardTransactionPageInvalid(t *testing.T) {
+	from, to := time.Time{}, time.Now()
+	cases := map[string]string{
+		"not json":     `<html>`,
+		"page 0":       `{"page": 0, "total_pages": 1, "transactions": []}`,
+		"past the end": `{"page": 2, "total_pages": 1, "transactions": []}`,
+		"bad time":     `{"page": 1, "total_pages": 1, "transactions": [{"time": "2023.01.03 12:00"}]}`,
+		"float amount": `{"page": 1, "total_pages": 1, "transactions": [{"time": "2023-01-03 12:00:00", "amount": -1.5}]}`,
+	}
+	for name, data := range cases {
+		if _, _, err := parseCardTransactionPage([]byte(data), from, to); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func FuzzParseTransactions(f *testing.F) {
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, chinaTime)
+	to := time.Date(2030, 1, 1, 0, 0, 0, 0, chinaTime)
+	parse := func(data []byte) ([]CardTransaction, error) {
+		transactions, _, err := parseCardTransactionPage(data, from, to)
+		return transactions, err
+	}
+	fuzzParse(f, []string{"card_transactions.json"}, nil, parse, func(t *testing.T, transactions []CardTransaction) {
+		for _, tr := range transactions {
+			if tr.Time.Before(from) || !tr.Time.Before(to) {
+				t.Fatalf("got the transaction %+v out of the range", tr)
+			}
+		}
+	})
+}
+
+func FuzzParseCardBalance(f *testing.F) {
+	fuzzParse(f, nil, []string{"12345", "-1", "1.5"}, parseCardBalance, nil)
+}
@@ -0,0 +1,87 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// ClassroomBuilding is a building with free classrooms.
+type ClassroomBuilding struct {
+	// Name is e.g. "HGX" or "H2".
+	Name  string      `json:"name"`
+	Rooms []Classroom `json:"rooms"`
+}
+
+// Classroom is a free classroom.
+type Classroom struct {
+	// Name is the room number, e.g. "HGX101".
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+	// FreeSlots lists the queried slots when the room is free, in ascending
+	// order. It has fewer elements than the query if the room is free for only
+	// part of it.
+	FreeSlots []TimeSlot `json:"free_slots"`
+}
+
+// EmptyClassrooms returns the buildings of campus with classrooms free on
+// date in any slot from startSlot to endSlot, both inclusive. Pass the same
+// slot twice to query a single one.
+func (s *Session) EmptyClassrooms(ctx context.Context, campus Campus, date time.Time, startSlot, endSlot TimeSlot, opts ...CallOption) ([]ClassroomBuilding, error) {
+	if err := checkClassroomQuery(campus, date, startSlot, endSlot); err != nil {
+		return nil, err
+	}
+
+	day := date.In(chinaTime).Format(time.DateOnly)
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduEmptyClassroomsAsync(ptr, string(campus), day, int32(startSlot), int32(endSlot), token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parseClassroomBuildings([]byte(v), startSlot, endSlot)
+}
+
+// EmptyClassroomsInt is EmptyClassrooms with the slots as ints.
+//
+// Deprecated: EmptyClassrooms takes TimeSlots, see ParseTimeSlot.
+// EmptyClassroomsInt will be removed in the next release.
+func (s *Session) EmptyClassroomsInt(ctx context.Context, campus Campus, date time.Time, startSlot, endSlot int) ([]ClassroomBuilding, error) {
+	return s.EmptyClassrooms(ctx, campus, date, TimeSlot(startSlot), TimeSlot(endSlot))
+}
+
+func checkClassroomQuery(campus Campus, date time.Time, startSlot, endSlot TimeSlot) error {
+	if !campus.Valid() {
+		return argumentError("unknown campus %q", campus)
+	}
+	if date.IsZero() {
+		return argumentError("zero date")
+	}
+	if !startSlot.Valid() || !endSlot.Valid() || startSlot > endSlot {
+		return argumentError("invalid slots %d-%d", startSlot, endSlot)
+	}
+	return nil
+}
+
+func parseClassroomBuildings(data []byte, startSlot, endSlot TimeSlot) ([]ClassroomBuilding, error) {
+	var buildings []ClassroomBuilding
+	if err := json.Unmarshal(data, &buildings); err != nil {
+		return nil, parseError("classrooms: %v", err)
+	}
+	for _, building := range buildings {
+		for _, room := range building.Rooms {
+			if room.Capacity < 0 {
+				return nil, parseError("classroom %s: invalid capacity %d", room.Name, room.Capacity)
+			}
+			if len(room.FreeSlots) == 0 {
+				return nil, parseError("classroom %s: no free slot", room.Name)
+			}
+			for _, slot := range room.FreeSlots {
+				if slot < startSlot || slot > endSlot {
+					return nil, parseError("classroom %s: slot %d out of %d-%d", room.Name, slot, startSlot, endSlot)
+				}
+			}
+		}
+	}
+	return buildings, nil
+}
@@ -0,0 +1,131 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseClassroomBuildings(t *testing.T) {
+	data, err := os.ReadFile("testdata/classrooms.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildings, err := parseClassroomBuildings(data, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buildings) != 2 || buildings[0].Name != "HGX" || buildings[1].Name != "H2" {
+		t.Fatalf("got %+v", buildings)
+	}
+	want := Classroom{Name: "HGX205", Capacity: 60, FreeSlots: []TimeSlot{4}}
+	if !reflect.DeepEqual(buildings[0].Rooms[1], want) {
+		t.Errorf("got %+v, want %+v", buildings[0].Rooms[1], want)
+	}
+
+	cases := map[string]string{
+		"not json":      `<html>`,
+		"no free slot":  `[{"name": "HGX", "rooms": [{"name": "HGX101", "free_slots": []}]}]`,
+		"out of query":  `[{"name": "HGX", "rooms": [{"name": "HGX101", "free_slots": [5]}]}]`,
+		"before query":  `[{"name": "HGX", "rooms": [{"name": "HGX101", "free_slots": [2, 3]}]}]`,
+		"rooms as dict": `[{"name": "HGX", "rooms": {"HGX101": [3]}}]`,
+	}
+	for name, data := range cases {
+		if _, err := parseClassroomBuildings([]byte(data), 3, 4); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func TestEmptyClassroomsInvalid(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	date := time.Now()
+	cases := map[string]func() error{
+		"campus":    func() error { _, err := s.EmptyClassrooms(ctx, "邯郸", date, 3, 4); return err },
+		"zero date": func() error { _, err := s.EmptyClassrooms(ctx, CampusHandan, time.Time{}, 3, 4); return err },
+		"slot 0":    func() error { _, err := s.EmptyClassrooms(ctx, CampusHandan, date, 0, 4); return err },
+		"slot 15":   func() error { _, err := s.EmptyClassrooms(ctx, CampusHandan, date, 3, MaxSlot+1); return err },
+		"reversed":  func() error { _, err := s.EmptyClassrooms(ctx, CampusHandan, date, 4, 3); return err },
+		"int slot -1": func() error {
+			start, end := -1, 4
+			_, err := s.EmptyClassroomsInt(ctx, CampusHandan, date, start, end)
+			return err
+		},
+	}
+	for name, f := range cases {
+		if err := f(); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: got %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
+
+func TestEmptyClassroomsInt(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	data, err := os.ReadFile("testdata/classrooms.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := lib.fduEmptyClassroomsAsync
+	t.Cleanup(func() { lib.fduEmptyClassroomsAsync = orig })
+	var slots [2]int32
+	lib.fduEmptyClassroomsAsync = func(_ *cSession, _, _ string, startSlot, endSlot int32, token *cCancelToken, requestID uint64) *cResult {
+		slots = [2]int32{startSlot, endSlot}
+		return lib.fduTestResultAsync(string(data), 0, 0, token, requestID)
+	}
+	start, end := 3, 4
+	got, err := s.EmptyClassroomsInt(context.Background(), CampusHandan, time.Now(), start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := parseClassroomBuildings(data, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots != [2]int32{3, 4} || !reflect.DeepEqual(got, want) {
+		t.Errorf("got slots %v and %+v, want 3-4 and %+v", slots, got, want)
+	}
+}
+
+func TestEmptyClassroomsLive(t *testing.T) {
+	s := liveSession(t)
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	buildings, err := s.EmptyClassrooms(context.Background(), CampusHandan, tomorrow, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buildings) == 0 {
+		t.Error("no free classroom in 邯郸")
+	}
+	for _, building := range buildings {
+		t.Logf("%s: %d rooms", building.Name, len(building.Rooms))
+	}
+}
+
+func FuzzParseClassrooms(f *testing.F) {
+	parse := func(data []byte) ([]ClassroomBuilding, error) {
+		return parseClassroomBuildings(data, 3, 4)
+	}
+	fuzzParse(f, []string{"classrooms.json"}, nil, parse, func(t *testing.T, buildings []ClassroomBuilding) {
+		for _, building := range buildings {
+			for _, room := range building.Rooms {
+				if room.Capacity < 0 || len(room.FreeSlots) == 0 || slices.ContainsFunc(room.FreeSlots, func(slot TimeSlot) bool { return slot < 3 || slot > 4 }) {
+					t.Fatalf("accepted the invalid classroom %+v", room)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package fdu
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+)
+
+// hammer runs n goroutines, each calling f m times, and returns the first error.
+func hammer(n, m int, f func() error) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < m; j++ {
+				if err := f(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func ping(s *Session) func() error {
+	return func() error {
+		_, err := s.testPing()
+		return err
+	}
+}
+
+func TestConcurrentSession(t *testing.T) {
+	const goroutines, calls = 64, 10000
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := hammer(goroutines, calls, ping(s)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The counter is not atomic on the Rust side, so any unserialized call
+	// would have lost an update.
+	if n, err := s.testPing(); err != nil || n != goroutines*calls+1 {
+		t.Errorf("got (%d, %v), want %d", n, err, goroutines*calls+1)
+	}
+}
+
+func TestConcurrentSessions(t *testing.T) {
+	const goroutines, calls = 32, 10000
+	var sessions [2]*Session
+	for i := range sessions {
+		s, err := testSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		sessions[i] = s
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range sessions {
+		wg.Add(1)
+		go func(s *Session) {
+			defer wg.Done()
+			if err := hammer(goroutines, calls, ping(s)); err != nil {
+				t.Error(err)
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	for i, s := range sessions {
+		if n, err := s.testPing(); err != nil || n != goroutines*calls+1 {
+			t.Errorf("session %d: got (%d, %v), want %d", i, n, err, goroutines*calls+1)
+		}
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := s.testPing(); err != nil && err != ErrClosed {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	s.Close()
+	wg.Wait()
+}
+
+func TestConcurrentMemoryLeak(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	start := getMemoryUsage()
+	err = hammer(16, 100000, func() error {
+		if _, err := Hello(); err != nil {
+			return err
+		}
+		_, err := s.testPing()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := getMemoryUsage()
+	usage := int(math.Abs(float64(end - start)))
+	fmt.Printf("Memory usage: %d bytes", usage)
+}
@@ -0,0 +1,259 @@
+package fdu
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Config is the HTTP behavior of libfdu. The zero value keeps the defaults
+// of the library.
+//
+// The config applies to the sessions created after it is set, by Login,
+// LoginWithCaptcha or Restore, and to each GetURL. Sessions created before
+// keep the config they were created with, so calls in flight, and the later
+// calls on the same session, are not affected by a change.
+type Config struct {
+	// ConnectTimeout bounds the time to connect to a server. Zero means no
+	// timeout other than RequestTimeout.
+	ConnectTimeout time.Duration
+	// RequestTimeout bounds each HTTP request, from connecting to reading
+	// the whole response. Zero means the default of libfdu, 30 seconds. A
+	// call on a session may make several requests.
+	RequestTimeout time.Duration
+	// ProxyURL is the proxy for all requests, e.g. "http://proxy:3128" or
+	// "socks5://127.0.0.1:1080". Empty means the proxy set by the
+	// environment, e.g. HTTPS_PROXY, if any.
+	ProxyURL string
+	// UserAgent replaces the User-Agent of libfdu, which mimics a browser.
+	UserAgent string
+	// MaxRetries is the number of times a request failing to connect, or
+	// timing out if it is a GET, is sent again by the methods of a session
+	// (but not by Login and GetURL). See RetryPolicy to retry whole calls.
+	MaxRetries int
+
+	// CACertPEM are PEM certificates trusted as roots besides those of the
+	// system, e.g. the one of a proxy re-signing TLS.
+	CACertPEM []byte
+	// InsecureSkipVerify accepts any certificate, so that anyone on the
+	// network can read the password: for debugging only. libfdu logs a
+	// warning when it is set.
+	InsecureSkipVerify bool
+	// PinnedSPKIHashes pin the public keys of the servers, as "sha256/"
+	// followed by the SHA-256 hash of a DER SubjectPublicKeyInfo in base64,
+	// e.g. from SPKIHash. A server must have one of them in the chain it
+	// sends, or the request fails with ErrCertPinMismatch; pinning the key
+	// of an intermediate authority lets the server renew its certificate.
+	// The chain is verified against the roots too, so pins cannot be used
+	// with InsecureSkipVerify.
+	PinnedSPKIHashes []string
+
+	// RateLimits are the rate limits of the requests of a session to each
+	// host, replacing the defaults of libfdu for the hosts present, see Rate.
+	RateLimits map[Host]Rate
+
+	// RawAllowedHosts are the hosts which Session.Do may send requests to,
+	// besides fudan.edu.cn and its subdomains, e.g. "api.example.com", or
+	// "*.example.com" for the subdomains of example.com. Do sends them the
+	// cookies they set and whatever the caller gives, so only list hosts
+	// you trust: libfdu logs a warning when it is set.
+	RawAllowedHosts []string
+	// RawMaxBodySize bounds the body of a response to Session.Do, in bytes.
+	// Zero means the default of libfdu, 8 MiB.
+	RawMaxBodySize int64
+}
+
+// proxySchemes are the schemes of Config.ProxyURL supported by libfdu.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// rawConfig is the JSON form of Config for fdu_set_http_config.
+type rawConfig struct {
+	ConnectTimeoutMillis int64  `json:"connect_timeout_millis"`
+	RequestTimeoutMillis int64  `json:"request_timeout_millis"`
+	ProxyURL             string `json:"proxy_url"`
+	UserAgent            string `json:"user_agent"`
+	MaxRetries           int    `json:"max_retries"`
+	CACertPEM            string `json:"ca_cert_pem"`
+	InsecureSkipVerify   bool   `json:"insecure_skip_verify"`
+	// libfdu takes a missing list for empty, but not null.
+	PinnedSPKIHashes []string      `json:"pinned_spki_hashes,omitempty"`
+	RateLimits       map[Host]Rate `json:"rate_limits,omitempty"`
+	RawAllowedHosts  []string      `json:"raw_allowed_hosts,omitempty"`
+	RawMaxBodyBytes  int64         `json:"raw_max_body_bytes"`
+}
+
+// SPKIHash returns the pin of the public key of cert for
+// Config.PinnedSPKIHashes.
+func SPKIHash(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return spkiPrefix + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+const spkiPrefix = "sha256/"
+
+// WithConfig makes Init set the HTTP config of libfdu, like SetConfig.
+func WithConfig(cfg Config) Option {
+	return func(o *options) {
+		o.config = &cfg
+	}
+}
+
+// SetConfig sets the HTTP config of libfdu, see Config. An invalid field is
+// reported by an error wrapping ErrInvalidArgument, which names the field,
+// and the previous config stays.
+func SetConfig(cfg Config) error {
+	if err := checkInit(); err != nil {
+		return err
+	}
+	return setConfig(cfg)
+}
+
+func setConfig(cfg Config) error {
+	raw, err := cfg.raw()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	_, err = takeResult(lib.fduSetHTTPConfig(string(data)))
+	return err
+}
+
+// SetBaseURLs points hosts of the university at other servers, mapping each
+// host to the base URL replacing "https://<host>", e.g. "uis.fudan.edu.cn"
+// to "http://127.0.0.1:8080", so that tests run against fake servers like
+// those of package fdutest. The base URLs apply to the requests sent
+// afterwards by all sessions, and nil restores the real servers.
+//
+// SetBaseURLs is a test hook of debug builds of libfdu, and returns
+// ErrReleaseBuild with a release build.
+func SetBaseURLs(urls map[string]string) error {
+	if err := checkInit(); err != nil {
+		return err
+	}
+	if urls == nil {
+		urls = map[string]string{}
+	}
+	data, err := json.Marshal(urls)
+	if err != nil {
+		return err
+	}
+	_, err = takeResult(lib.fduSetBaseURLs(string(data)))
+	if err != nil && lib.fduTestLiveSessions() < 0 {
+		// The test hooks of release builds all fail with ErrUnknown.
+		return ErrReleaseBuild
+	}
+	return err
+}
+
+// raw checks cfg and converts it to its JSON form.
+func (cfg Config) raw() (rawConfig, error) {
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"ConnectTimeout", cfg.ConnectTimeout},
+		{"RequestTimeout", cfg.RequestTimeout},
+	} {
+		if d.value < 0 {
+			return rawConfig{}, fmt.Errorf("fdu: %w: Config.%s is negative: %v", ErrInvalidArgument, d.name, d.value)
+		}
+	}
+	if cfg.MaxRetries < 0 {
+		return rawConfig{}, fmt.Errorf("fdu: %w: Config.MaxRetries is negative: %d", ErrInvalidArgument, cfg.MaxRetries)
+	}
+	if cfg.ProxyURL != "" {
+		u, err := url.Parse(cfg.ProxyURL)
+		if err == nil && !slices.Contains(proxySchemes, u.Scheme) {
+			err = fmt.Errorf("unsupported scheme %q", u.Scheme)
+		} else if err == nil && u.Host == "" {
+			err = errors.New("missing host")
+		}
+		if err != nil {
+			return rawConfig{}, fmt.Errorf("fdu: %w: Config.ProxyURL %q: %v", ErrInvalidArgument, cfg.ProxyURL, err)
+		}
+	}
+	if len(cfg.CACertPEM) > 0 {
+		if err := checkPEM(cfg.CACertPEM); err != nil {
+			return rawConfig{}, fmt.Errorf("fdu: %w: Config.CACertPEM: %v", ErrInvalidArgument, err)
+		}
+	}
+	for _, pin := range cfg.PinnedSPKIHashes {
+		hash, ok := strings.CutPrefix(pin, spkiPrefix)
+		if b, err := base64.StdEncoding.DecodeString(hash); !ok || err != nil || len(b) != sha256.Size {
+			return rawConfig{}, fmt.Errorf("fdu: %w: Config.PinnedSPKIHashes: %q is not sha256/ and a SHA-256 hash in base64",
+				ErrInvalidArgument, pin)
+		}
+	}
+	if cfg.InsecureSkipVerify && len(cfg.PinnedSPKIHashes) > 0 {
+		return rawConfig{}, fmt.Errorf("fdu: %w: Config.InsecureSkipVerify with PinnedSPKIHashes", ErrInvalidArgument)
+	}
+	if err := checkRateLimits(cfg.RateLimits); err != nil {
+		return rawConfig{}, err
+	}
+	for _, host := range cfg.RawAllowedHosts {
+		if name := strings.TrimPrefix(host, "*."); name == "" || strings.ContainsAny(name, "/:*\x00 ") {
+			return rawConfig{}, fmt.Errorf("fdu: %w: Config.RawAllowedHosts: invalid host %q", ErrInvalidArgument, host)
+		}
+	}
+	if cfg.RawMaxBodySize < 0 {
+		return rawConfig{}, fmt.Errorf("fdu: %w: Config.RawMaxBodySize is negative: %d", ErrInvalidArgument, cfg.RawMaxBodySize)
+	}
+	return rawConfig{
+		ConnectTimeoutMillis: millis(cfg.ConnectTimeout),
+		RequestTimeoutMillis: millis(cfg.RequestTimeout),
+		ProxyURL:             cfg.ProxyURL,
+		UserAgent:            cfg.UserAgent,
+		MaxRetries:           cfg.MaxRetries,
+		CACertPEM:            string(cfg.CACertPEM),
+		InsecureSkipVerify:   cfg.InsecureSkipVerify,
+		PinnedSPKIHashes:     cfg.PinnedSPKIHashes,
+		RateLimits:           cfg.RateLimits,
+		RawAllowedHosts:      cfg.RawAllowedHosts,
+		RawMaxBodyBytes:      cfg.RawMaxBodySize,
+	}, nil
+}
+
+// checkPEM checks that data holds at least one certificate, and no other
+// block. It goes to libfdu as a string.
+func checkPEM(data []byte) error {
+	if !utf8.Valid(data) {
+		return errors.New("invalid UTF-8")
+	}
+	n := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("%s block, not CERTIFICATE", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return err
+		}
+		n++
+	}
+	if n == 0 {
+		return errors.New("no certificate")
+	}
+	return nil
+}
+
+// millis converts d to milliseconds, rounding up so that a short positive
+// timeout does not become zero, i.e. the default.
+func millis(d time.Duration) int64 {
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
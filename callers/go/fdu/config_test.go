@@ -0,0 +1,279 @@
+package fdu
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProxy is an HTTP proxy answering every request with its name after
+// delay, and recording the last request.
+type testProxy struct {
+	*httptest.Server
+	requests chan *http.Request
+}
+
+func newTestProxy(t *testing.T, name string, delay time.Duration) *testProxy {
+	p := &testProxy{requests: make(chan *http.Request, 16)}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.requests <- r
+		select {
+		case <-time.After(delay):
+			fmt.Fprint(w, name)
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// setTestConfig sets cfg until the end of the test.
+func setTestConfig(t *testing.T, cfg Config) {
+	t.Helper()
+	if err := SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := SetConfig(Config{}); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestConfigInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		cfg   Config
+		field string
+	}{
+		{Config{ConnectTimeout: -time.Second}, "ConnectTimeout"},
+		{Config{RequestTimeout: -time.Second}, "RequestTimeout"},
+		{Config{MaxRetries: -1}, "MaxRetries"},
+		{Config{ProxyURL: "http://[::1"}, "ProxyURL"},
+		{Config{ProxyURL: "proxy:3128"}, "ProxyURL"},
+		{Config{ProxyURL: "ftp://proxy"}, "ProxyURL"},
+		{Config{ProxyURL: "http://"}, "ProxyURL"},
+		{Config{CACertPEM: []byte("not a certificate")}, "CACertPEM"},
+		{Config{CACertPEM: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1}})}, "CACertPEM"},
+		{Config{PinnedSPKIHashes: []string{"sha1/AAAA"}}, "PinnedSPKIHashes"},
+		{Config{PinnedSPKIHashes: []string{"sha256/AAAA"}}, "PinnedSPKIHashes"},
+		{Config{InsecureSkipVerify: true, PinnedSPKIHashes: []string{"sha256/" + strings.Repeat("A", 43) + "="}}, "InsecureSkipVerify"},
+		{Config{RateLimits: map[Host]Rate{"": {PerSecond: 1}}}, "RateLimits"},
+		{Config{RateLimits: map[Host]Rate{"127.0.0.1:8080": {PerSecond: 1}}}, "RateLimits"},
+		{Config{RateLimits: map[Host]Rate{HostJwfw: {PerSecond: -1}}}, "RateLimits"},
+		{Config{RateLimits: map[Host]Rate{HostJwfw: {PerSecond: math.Inf(1)}}}, "RateLimits"},
+		{Config{RateLimits: map[Host]Rate{HostJwfw: {PerSecond: 1, Burst: -1}}}, "RateLimits"},
+		{Config{RawAllowedHosts: []string{"*."}}, "RawAllowedHosts"},
+		{Config{RawAllowedHosts: []string{"https://example.com"}}, "RawAllowedHosts"},
+		{Config{RawAllowedHosts: []string{"*.*.example.com"}}, "RawAllowedHosts"},
+		{Config{RawMaxBodySize: -1}, "RawMaxBodySize"},
+	} {
+		err := SetConfig(tc.cfg)
+		if !errors.Is(err, ErrInvalidArgument) || !strings.Contains(err.Error(), "Config."+tc.field) {
+			t.Errorf("%+v: got %v, want ErrInvalidArgument naming %s", tc.cfg, err, tc.field)
+		}
+	}
+}
+
+func TestConfigProxy(t *testing.T) {
+	proxy := newTestProxy(t, "proxied", 0)
+	setTestConfig(t, Config{ProxyURL: proxy.URL, UserAgent: "fdu-test"})
+
+	v, err := GetURL("http://fdu.invalid/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "proxied" {
+		t.Errorf("got %q, want the response of the proxy", v)
+	}
+	r := <-proxy.requests
+	if r.Host != "fdu.invalid" || r.URL.Path != "/hello" {
+		t.Errorf("proxy got a request for %s%s", r.Host, r.URL.Path)
+	}
+	if ua := r.Header.Get("User-Agent"); ua != "fdu-test" {
+		t.Errorf("got User-Agent %q", ua)
+	}
+}
+
+func TestConfigTimeout(t *testing.T) {
+	const timeout = 300 * time.Millisecond
+	proxy := newTestProxy(t, "late", 10*time.Second)
+	setTestConfig(t, Config{ProxyURL: proxy.URL, RequestTimeout: timeout})
+
+	start := time.Now()
+	_, err := GetURL("http://fdu.invalid/")
+	elapsed := time.Since(start)
+	if !errors.Is(err, ErrNetwork) {
+		t.Errorf("got %v, want ErrNetwork", err)
+	}
+	if elapsed < timeout-100*time.Millisecond || elapsed > timeout+100*time.Millisecond {
+		t.Errorf("timed out after %v, want %v", elapsed, timeout)
+	}
+}
+
+func TestConfigInFlight(t *testing.T) {
+	before := newTestProxy(t, "before", 200*time.Millisecond)
+	after := newTestProxy(t, "after", 0)
+	setTestConfig(t, Config{ProxyURL: before.URL})
+
+	type outcome struct {
+		value string
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		v, err := GetURL("http://fdu.invalid/")
+		done <- outcome{v, err}
+	}()
+	<-before.requests
+
+	// The call in flight keeps the config it started with.
+	if err := SetConfig(Config{ProxyURL: after.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if o := <-done; o.err != nil || o.value != "before" {
+		t.Errorf("in flight: got (%q, %v), want \"before\"", o.value, o.err)
+	}
+	if v, err := GetURL("http://fdu.invalid/"); err != nil || v != "after" {
+		t.Errorf("after the change: got (%q, %v), want \"after\"", v, err)
+	}
+}
+
+// testTLSServer is an HTTPS server at 127.0.0.1 answering "secret", with a
+// certificate issued by its own root.
+type testTLSServer struct {
+	*httptest.Server
+	// rootPEM is the root in PEM, and leaf the certificate of the server.
+	rootPEM []byte
+	leaf    *x509.Certificate
+}
+
+func newTestTLSServer(t *testing.T) *testTLSServer {
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	// A nil parent makes a self-signed certificate.
+	newCert := func(template, parent *x509.Certificate, key, signer *ecdsa.PrivateKey) *x509.Certificate {
+		if parent == nil {
+			parent = template
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	now := time.Now()
+	rootKey, leafKey := newKey(), newKey()
+	root := newCert(&x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fdu test root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, rootKey, rootKey)
+	leaf := newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, root, leafKey, rootKey)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secret")
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.Raw}, PrivateKey: leafKey, Leaf: leaf}}}
+	// The server logs the handshakes it fails on purpose.
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return &testTLSServer{
+		Server:  srv,
+		rootPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}),
+		leaf:    leaf,
+	}
+}
+
+func TestConfigTLS(t *testing.T) {
+	srv := newTestTLSServer(t)
+	other := newTestTLSServer(t)
+
+	if _, err := GetURL(srv.URL); !errors.Is(err, ErrNetwork) {
+		t.Errorf("default config: got %v, want ErrNetwork", err)
+	}
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+	}{
+		{"CACertPEM", Config{CACertPEM: srv.rootPEM}},
+		{"CACertPEM of both", Config{CACertPEM: append(other.rootPEM, srv.rootPEM...)}},
+		{"InsecureSkipVerify", Config{InsecureSkipVerify: true}},
+		{"PinnedSPKIHashes", Config{CACertPEM: srv.rootPEM, PinnedSPKIHashes: []string{SPKIHash(other.leaf), SPKIHash(srv.leaf)}}},
+	} {
+		setTestConfig(t, tc.cfg)
+		if v, err := GetURL(srv.URL); err != nil || v != "secret" {
+			t.Errorf("%s: got (%q, %v), want the response of the server", tc.name, v, err)
+		}
+	}
+
+	setTestConfig(t, Config{CACertPEM: other.rootPEM})
+	if _, err := GetURL(srv.URL); !errors.Is(err, ErrNetwork) {
+		t.Errorf("another root: got %v, want ErrNetwork", err)
+	}
+	setTestConfig(t, Config{CACertPEM: srv.rootPEM, PinnedSPKIHashes: []string{SPKIHash(other.leaf)}})
+	_, err := GetURL(srv.URL)
+	if !errors.Is(err, ErrCertPinMismatch) || !strings.Contains(err.Error(), "127.0.0.1") {
+		t.Errorf("wrong pin: got %v, want ErrCertPinMismatch naming the host", err)
+	}
+	// The roots are still checked along with the pins.
+	setTestConfig(t, Config{CACertPEM: other.rootPEM, PinnedSPKIHashes: []string{SPKIHash(srv.leaf)}})
+	if _, err := GetURL(srv.URL); !errors.Is(err, ErrNetwork) {
+		t.Errorf("pinned without the root: got %v, want ErrNetwork", err)
+	}
+}
+
+func TestSPKIHash(t *testing.T) {
+	// The pin of the certificate from openssl, as in the tests of libfdu.
+	const want = "sha256/m/W2MyS7KIxN1OSYHY5ni+WJtsuVhj3+gHZ8SWaW3AA="
+	data, err := os.ReadFile("testdata/tls_cert.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := SPKIHash(cert); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// MaxSlot is the number of slots (节) in a day.
+const MaxSlot = 14
+
+// Course is a lesson in the course table: the course takes place on Weekday,
+// from StartSlot to EndSlot, in each of Weeks. A course taking place several
+// times a week appears once per lesson.
+type Course struct {
+	// CourseID is the id of the class, e.g. COMP130004.03.
+	CourseID string `json:"course_id"`
+	Name     string `json:"name"`
+	Teacher  string `json:"teacher"`
+	// Location is empty if the course has no fixed classroom.
+	Location string  `json:"location"`
+	Weekday  Weekday `json:"weekday"`
+	// StartSlot and EndSlot are both inclusive.
+	StartSlot TimeSlot `json:"start_slot"`
+	EndSlot   TimeSlot `json:"end_slot"`
+	// Weeks lists the teaching weeks, counted from 1. Irregular courses
+	// (e.g. 单周/双周) simply skip some weeks.
+	Weeks []int `json:"weeks"`
+}
+
+// Semester is a semester of the academic system.
+type Semester struct {
+	// ID is the value to pass to Session.Courses, e.g. "385", or "20231"
+	// for graduate students.
+	ID SemesterID `json:"id"`
+	// SchoolYear is e.g. "2022-2023".
+	SchoolYear string `json:"school_year"`
+	// Name is e.g. "1", "2", "暑期" or "寒假".
+	Name string `json:"name"`
+}
+
+// Semesters returns the semesters known by the academic system.
+func (s *Session) Semesters(ctx context.Context, opts ...CallOption) ([]Semester, error) {
+	return cached(s, ResourceSemesters, "", func() (string, error) {
+		return s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+			return lib.fduSemestersAsync(ptr, token, id)
+		}, opts...)
+	}, parseSemesters)
+}
+
+// Courses returns the course table of the semester, given by one of the IDs
+// of Semesters or by its school year and term.
+func (s *Session) Courses(ctx context.Context, semesterID SemesterID, opts ...CallOption) ([]Course, error) {
+	semesterID, err := s.semesterID(ctx, semesterID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return cached(s, ResourceCourses, string(semesterID), func() (string, error) {
+		return s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+			return lib.fduCoursesAsync(ptr, string(semesterID), token, id)
+		}, opts...)
+	}, parseCourses)
+}
+
+func parseSemesters(data []byte) ([]Semester, error) {
+	var semesters []Semester
+	if err := json.Unmarshal(data, &semesters); err != nil {
+		return nil, parseError("semesters: %v", err)
+	}
+	for _, semester := range semesters {
+		if !semester.ID.isSystemID() {
+			return nil, parseError("semester without id: %+v", semester)
+		}
+	}
+	return semesters, nil
+}
+
+func parseCourses(data []byte) ([]Course, error) {
+	var courses []Course
+	if err := json.Unmarshal(data, &courses); err != nil {
+		return nil, parseError("courses: %v", err)
+	}
+	for _, course := range courses {
+		if !course.Weekday.Valid() {
+			return nil, parseError("course %s: invalid weekday %d", course.CourseID, course.Weekday)
+		}
+		if !course.StartSlot.Valid() || !course.EndSlot.Valid() || course.StartSlot > course.EndSlot {
+			return nil, parseError("course %s: invalid slots %d-%d", course.CourseID, course.StartSlot, course.EndSlot)
+		}
+		for _, week := range course.Weeks {
+			if week < 1 {
+				return nil, parseError("course %s: invalid week %d in weeks", course.CourseID, week)
+			}
+		}
+	}
+	return courses, nil
+}
+
+// CoursesString is Courses with the semester ID as a string.
+//
+// Deprecated: Courses takes a SemesterID, see ParseSemesterID. CoursesString
+// will be removed in the next release.
+func (s *Session) CoursesString(ctx context.Context, semesterID string) ([]Course, error) {
+	return s.Courses(ctx, SemesterID(semesterID))
+}
+
+// checkSemesterID returns the error of an invalid semester ID, which would
+// otherwise only fail at the server.
+func checkSemesterID(id SemesterID) error {
+	if err := checkCString("semester ID", string(id)); err != nil {
+		return err
+	}
+	_, err := ParseSemesterID(string(id))
+	return err
+}
+
+// semesterID returns the ID in the academic system of the semester id,
+// looked up in Semesters if id is a school year and term.
+func (s *Session) semesterID(ctx context.Context, id SemesterID, opts []CallOption) (SemesterID, error) {
+	if err := checkSemesterID(id); err != nil {
+		return "", err
+	}
+	if _, _, ok := id.Term(); !ok {
+		return id, nil
+	}
+	semesters, err := s.Semesters(ctx, opts...)
+	if err != nil {
+		return "", err
+	}
+	for _, semester := range semesters {
+		if id.is(semester.ID, semester.SchoolYear, semester.Name) {
+			return semester.ID, nil
+		}
+	}
+	return "", argumentError("no semester %s in the academic system", id)
+}
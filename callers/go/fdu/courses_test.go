@@ -0,0 +1,98 @@
+package fdu
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseCourses(t *testing.T) {
+	data, err := os.ReadFile("testdata/courses.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	courses, err := parseCourses(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(courses) != 5 {
+		t.Fatalf("got %d courses, want 5", len(courses))
+	}
+
+	want := Course{
+		CourseID:  "PHYS120013.05",
+		Name:      "大学物理实验",
+		Teacher:   "王煜,乐永康",
+		Location:  "JB201",
+		Weekday:   4,
+		StartSlot: 6,
+		EndSlot:   8,
+		Weeks:     []int{1, 3, 5, 7, 9, 11, 13, 15},
+	}
+	if !reflect.DeepEqual(courses[2], want) {
+		t.Errorf("got %+v, want %+v", courses[2], want)
+	}
+	// Courses without a fixed room are kept.
+	if pe := courses[4]; pe.Name != "体育" || pe.Location != "" {
+		t.Errorf("got %+v", pe)
+	}
+	// So are single-week lessons.
+	if !reflect.DeepEqual(courses[1].Weeks, []int{11}) {
+		t.Errorf("got weeks %v", courses[1].Weeks)
+	}
+}
+
+func TestParseCoursesInvalid(t *testing.T) {
+	cases := map[string]string{
+		"not json":       `<html>`,
+		"weekday 0":      `[{"course_id": "A", "weekday": 0, "start_slot": 1, "end_slot": 2}]`,
+		"weekday 8":      `[{"course_id": "A", "weekday": 8, "start_slot": 1, "end_slot": 2}]`,
+		"slot 0":         `[{"course_id": "A", "weekday": 1, "start_slot": 0, "end_slot": 2}]`,
+		"slot 15":        `[{"course_id": "A", "weekday": 1, "start_slot": 14, "end_slot": 15}]`,
+		"reversed slots": `[{"course_id": "A", "weekday": 1, "start_slot": 3, "end_slot": 2}]`,
+	}
+	for name, data := range cases {
+		if _, err := parseCourses([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func TestParseSemesters(t *testing.T) {
+	semesters, err := parseSemesters([]byte(`[{"id": "384", "school_year": "2022-2023", "name": "1"}, {"id": "405", "school_year": "2022-2023", "name": "暑期"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Semester{{"384", "2022-2023", "1"}, {"405", "2022-2023", "暑期"}}
+	if !reflect.DeepEqual(semesters, want) {
+		t.Errorf("got %+v, want %+v", semesters, want)
+	}
+	if _, err := parseSemesters([]byte(`[{"school_year": "2022-2023"}]`)); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v, want ErrParse", err)
+	}
+}
+
+func FuzzParseCourses(f *testing.F) {
+	fuzzParse(f, []string{"courses.json", "courses_graduate.json"}, []string{
+		`[{"course_id": "A", "weekday": 1, "start_slot": 1, "end_slot": 2, "weeks": [0, 1]}]`,
+	}, parseCourses, func(t *testing.T, courses []Course) {
+		for _, c := range courses {
+			if !c.Weekday.Valid() || !c.StartSlot.Valid() || !c.EndSlot.Valid() || c.StartSlot > c.EndSlot ||
+				slices.ContainsFunc(c.Weeks, func(week int) bool { return week < 1 }) {
+				t.Fatalf("accepted the invalid course %+v", c)
+			}
+		}
+	})
+}
+
+func FuzzParseSemesters(f *testing.F) {
+	fuzzParse(f, nil, []string{`[{"id": "384", "school_year": "2022-2023", "name": "1"}]`}, parseSemesters, func(t *testing.T, semesters []Semester) {
+		for _, semester := range semesters {
+			if !semester.ID.Valid() {
+				t.Fatalf("accepted the invalid semester %+v", semester)
+			}
+		}
+	})
+}
@@ -0,0 +1,113 @@
+// Package credentials stores the UIS passwords of accounts, keyed by student
+// ID, so that a tool asks for a password once instead of keeping it in a
+// dotfile:
+//
+//	store := credentials.NewKeychain("")
+//	if err := store.Save(studentID, password); err != nil {
+//		...
+//	}
+//	pool := fdu.NewSessionPool(0, nil).WithStore(store)
+//
+// There are three stores: Keychain, in the keychain of the system, File, in
+// a file encrypted with a passphrase, e.g. on a server without a keychain,
+// and Memory, for tests.
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Store holds the passwords of accounts. The stores of the package are safe
+// for concurrent use by multiple goroutines, and File and Keychain by
+// multiple processes.
+type Store interface {
+	// Save sets the password of studentID, replacing any previous one.
+	Save(studentID, password string) error
+	// Load returns the password of studentID, or an error wrapping
+	// ErrNotFound.
+	Load(studentID string) (string, error)
+	// Delete removes the password of studentID. Deleting a student ID
+	// without a password is not an error.
+	Delete(studentID string) error
+}
+
+var (
+	// ErrNotFound is returned by Store.Load for a student ID without a
+	// password.
+	ErrNotFound = errors.New("credentials: not found")
+	// ErrUnavailable is returned by Keychain when the system has no
+	// keychain, e.g. on Linux without a Secret Service (GNOME Keyring,
+	// KWallet...) or secret-tool.
+	ErrUnavailable = errors.New("credentials: keychain unavailable")
+	// ErrWrongPassphrase is returned by File when the file is encrypted
+	// with another passphrase.
+	ErrWrongPassphrase = errors.New("credentials: wrong passphrase")
+	// ErrCorrupted is returned by File when the file is not a credential
+	// file, or was modified.
+	ErrCorrupted = errors.New("credentials: corrupted file")
+)
+
+// checkID rejects the student IDs which are not 1 to 32 ASCII letters and
+// digits, which every account of UIS has, so that they can be used as is in
+// the names of the keychains.
+func checkID(studentID string) error {
+	if studentID == "" || len(studentID) > 32 {
+		return fmt.Errorf("credentials: invalid student ID %q", studentID)
+	}
+	for _, c := range studentID {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return fmt.Errorf("credentials: invalid student ID %q", studentID)
+		}
+	}
+	return nil
+}
+
+// Memory is a Store which keeps the passwords in memory, e.g. for tests.
+// The zero value is an empty store.
+type Memory struct {
+	mu        sync.Mutex
+	passwords map[string]string
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Save(studentID, password string) error {
+	if err := checkID(studentID); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.passwords == nil {
+		m.passwords = make(map[string]string)
+	}
+	m.passwords[studentID] = password
+	return nil
+}
+
+func (m *Memory) Load(studentID string) (string, error) {
+	if err := checkID(studentID); err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	password, ok := m.passwords[studentID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, studentID)
+	}
+	return password, nil
+}
+
+func (m *Memory) Delete(studentID string) error {
+	if err := checkID(studentID); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.passwords, studentID)
+	return nil
+}
@@ -0,0 +1,94 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// testStore checks the basics of store, which must be empty.
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	if _, err := store.Load("21300000001"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load of a new store: got %v, want ErrNotFound", err)
+	}
+	for id, password := range map[string]string{
+		"21300000001": "hunter2",
+		"21300000002": "密码 with spaces\nand a newline",
+		"zhangsan":    "",
+	} {
+		if err := store.Save(id, password); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.Load(id); err != nil || got != password {
+			t.Errorf("Load(%q) = %q, %v, want %q", id, got, err, password)
+		}
+	}
+	if err := store.Save("21300000001", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.Load("21300000001"); err != nil || got != "correct horse" {
+		t.Errorf("Load after a new Save = %q, %v", got, err)
+	}
+
+	if err := store.Delete("21300000001"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("21300000001"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load after Delete: got %v, want ErrNotFound", err)
+	}
+	if err := store.Delete("21300000001"); err != nil {
+		t.Errorf("Delete again: %v", err)
+	}
+	if got, err := store.Load("21300000002"); err != nil || got != "密码 with spaces\nand a newline" {
+		t.Errorf("Delete removed another password: %q, %v", got, err)
+	}
+
+	for _, id := range []string{"", "2130 0000001", "../21300000001", "张三", "a'b", "123456789012345678901234567890123"} {
+		if err := store.Save(id, "x"); err == nil {
+			t.Errorf("Save(%q): got no error", id)
+		}
+		if _, err := store.Load(id); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Load(%q): got %v, want an invalid ID", id, err)
+		}
+		if err := store.Delete(id); err == nil {
+			t.Errorf("Delete(%q): got no error", id)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+	testStore(t, &Memory{})
+}
+
+func TestMemoryConcurrent(t *testing.T) {
+	store := NewMemory()
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprint(21300000000 + i)
+			for range 100 {
+				if err := store.Save(id, id); err != nil {
+					t.Error(err)
+				}
+				if _, err := store.Load(id); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestKeychainInvalidService(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+	NewKeychain("libfdu test")
+}
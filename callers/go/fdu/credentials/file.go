@@ -0,0 +1,262 @@
+package credentials
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// The format of a file, all integers little-endian:
+//
+//	magic "FDUC", version 1
+//	time u32, memory u32 (KiB), threads u8: the parameters of Argon2id
+//	salt [16]byte
+//	check [16]byte: the bytes of the key after those of AES
+//	nonce [12]byte
+//	the JSON object of the passwords by student ID, sealed with AES-256-GCM
+//	with everything before as additional data
+const (
+	fileMagic   = "FDUC\x01"
+	saltSize    = 16
+	checkSize   = 16
+	keySize     = 32
+	headerSize  = len(fileMagic) + 9 + saltSize + checkSize
+	nonceSize   = 12
+	maxFileSize = 1 << 20
+)
+
+// kdfParams are the parameters of Argon2id.
+type kdfParams struct {
+	time, memory uint32
+	threads      uint8
+}
+
+// defaultParams are the second recommended option of RFC 9106, which takes
+// 64 MiB.
+var defaultParams = kdfParams{time: 3, memory: 64 << 10, threads: 4}
+
+// File is a Store which keeps the passwords in a file, encrypted with a key
+// derived from a passphrase with Argon2id. The file is created by the first
+// Save, readable and writable by its owner only.
+//
+// Deriving the key takes a fraction of a second and 64 MiB of memory, which
+// is the point, so the key is kept in memory once derived. The file is locked
+// while saving, and replaced atomically, so that processes saving at the same
+// time do not lose each other's passwords.
+type File struct {
+	path       string
+	passphrase []byte
+	// params are those of new files.
+	params kdfParams
+
+	// mu guards the fields below.
+	mu sync.Mutex
+	// salt and derived are those of the last file read or written, and key
+	// and check are derived with them.
+	salt       []byte
+	derived    kdfParams
+	key, check []byte
+}
+
+// NewFile returns the File at path, encrypted with passphrase. It does not
+// touch the file: a wrong passphrase is reported by the first call, with an
+// error wrapping ErrWrongPassphrase.
+func NewFile(path, passphrase string) *File {
+	return &File{path: path, passphrase: []byte(passphrase), params: defaultParams}
+}
+
+func (f *File) Save(studentID, password string) error {
+	if err := checkID(studentID); err != nil {
+		return err
+	}
+	return f.update(func(passwords map[string]string) bool {
+		if p, ok := passwords[studentID]; ok && p == password {
+			return false
+		}
+		passwords[studentID] = password
+		return true
+	})
+}
+
+func (f *File) Load(studentID string) (string, error) {
+	if err := checkID(studentID); err != nil {
+		return "", err
+	}
+	passwords, _, err := f.read()
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, studentID)
+	}
+	if err != nil {
+		return "", err
+	}
+	password, ok := passwords[studentID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, studentID)
+	}
+	return password, nil
+}
+
+func (f *File) Delete(studentID string) error {
+	if err := checkID(studentID); err != nil {
+		return err
+	}
+	return f.update(func(passwords map[string]string) bool {
+		if _, ok := passwords[studentID]; !ok {
+			return false
+		}
+		delete(passwords, studentID)
+		return true
+	})
+}
+
+// update changes the passwords of the file with change, which reports
+// whether it changed anything, and writes them back, holding the lock of the
+// file.
+func (f *File) update(change func(passwords map[string]string) bool) error {
+	unlock, err := lockFile(f.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("credentials: locking %s: %w", f.path, err)
+	}
+	defer unlock()
+	passwords, params, err := f.read()
+	if errors.Is(err, fs.ErrNotExist) {
+		passwords, params, err = make(map[string]string), f.params, nil
+	}
+	if err != nil {
+		return err
+	}
+	if !change(passwords) {
+		return nil
+	}
+	return f.write(passwords, params)
+}
+
+// read returns the passwords of the file, and its parameters. The error
+// wraps fs.ErrNotExist if there is no file.
+func (f *File) read() (map[string]string, kdfParams, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, kdfParams{}, fmt.Errorf("credentials: %w", err)
+	}
+	if len(data) < headerSize+nonceSize || len(data) > maxFileSize || !bytes.HasPrefix(data, []byte(fileMagic)) {
+		return nil, kdfParams{}, fmt.Errorf("%w: %s is not a credential file", ErrCorrupted, f.path)
+	}
+	header := data[len(fileMagic):headerSize]
+	params := kdfParams{
+		time:    binary.LittleEndian.Uint32(header[0:]),
+		memory:  binary.LittleEndian.Uint32(header[4:]),
+		threads: header[8],
+	}
+	// Bounded, so that a damaged file does not take all the memory.
+	if params.time < 1 || params.time > 64 || params.memory > 1<<20 || params.threads < 1 {
+		return nil, kdfParams{}, fmt.Errorf("%w: %s has invalid parameters", ErrCorrupted, f.path)
+	}
+	salt, check := header[9:9+saltSize], header[9+saltSize:]
+
+	aead, wantCheck, err := f.cipher(salt, params)
+	if err != nil {
+		return nil, kdfParams{}, err
+	}
+	if subtle.ConstantTimeCompare(check, wantCheck) != 1 {
+		return nil, kdfParams{}, fmt.Errorf("%w for %s", ErrWrongPassphrase, f.path)
+	}
+	nonce, sealed := data[headerSize:headerSize+nonceSize], data[headerSize+nonceSize:]
+	plain, err := aead.Open(nil, nonce, sealed, data[:headerSize])
+	if err != nil {
+		return nil, kdfParams{}, fmt.Errorf("%w: %s was modified", ErrCorrupted, f.path)
+	}
+	var passwords map[string]string
+	if err := json.Unmarshal(plain, &passwords); err != nil || passwords == nil {
+		return nil, kdfParams{}, fmt.Errorf("%w: %s has invalid passwords", ErrCorrupted, f.path)
+	}
+	return passwords, params, nil
+}
+
+// write replaces the file with passwords, encrypted with a new key if there
+// is none for the file yet.
+func (f *File) write(passwords map[string]string, params kdfParams) error {
+	f.mu.Lock()
+	salt := f.salt
+	f.mu.Unlock()
+	if salt == nil {
+		salt = make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+	}
+	aead, check, err := f.cipher(salt, params)
+	if err != nil {
+		return err
+	}
+
+	data := []byte(fileMagic)
+	data = binary.LittleEndian.AppendUint32(data, params.time)
+	data = binary.LittleEndian.AppendUint32(data, params.memory)
+	data = append(data, params.threads)
+	data = append(data, salt...)
+	data = append(data, check...)
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data = append(data, nonce...)
+	plain, err := json.Marshal(passwords)
+	if err != nil {
+		return err
+	}
+	data = aead.Seal(data, nonce, plain, data[:headerSize])
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("credentials: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("credentials: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("credentials: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("credentials: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("credentials: %w", err)
+	}
+	return nil
+}
+
+// cipher returns the AES-GCM of the key derived from the passphrase and salt,
+// and the check of the key, deriving them unless salt and params are the last
+// ones.
+func (f *File) cipher(salt []byte, params kdfParams) (cipher.AEAD, []byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !bytes.Equal(salt, f.salt) || params != f.derived {
+		key := argon2.IDKey(f.passphrase, salt, params.time, params.memory, params.threads, keySize+checkSize)
+		f.salt, f.derived, f.key, f.check = bytes.Clone(salt), params, key[:keySize], key[keySize:]
+	}
+	block, err := aes.NewCipher(f.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, f.check, nil
+}
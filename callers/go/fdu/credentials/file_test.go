@@ -0,0 +1,169 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// testParams derive the keys of the tests quickly.
+var testParams = kdfParams{time: 1, memory: 64, threads: 1}
+
+func newTestFile(path, passphrase string) *File {
+	f := NewFile(path, passphrase)
+	f.params = testParams
+	return f
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	testStore(t, newTestFile(path, "passphrase"))
+
+	// Another instance, e.g. another process, reads the same passwords.
+	if got, err := newTestFile(path, "passphrase").Load("21300000002"); err != nil || got != "密码 with spaces\nand a newline" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if runtime.GOOS != "windows" {
+		if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+			t.Errorf("got mode %v, %v, want 0600", fi.Mode(), err)
+		}
+	}
+	if matches, _ := filepath.Glob(path + ".*.tmp"); len(matches) > 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+}
+
+func TestFileDefaultParams(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the default parameters of Argon2id in short mode")
+	}
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := NewFile(path, "passphrase").Save("21300000001", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	// Whatever the parameters of the instance, those of the file are used.
+	if got, err := newTestFile(path, "passphrase").Load("21300000001"); err != nil || got != "hunter2" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestFileWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := newTestFile(path, "passphrase").Save("21300000001", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	wrong := newTestFile(path, "Passphrase")
+	if _, err := wrong.Load("21300000001"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Load: got %v, want ErrWrongPassphrase", err)
+	}
+	// Saving with a wrong passphrase must not replace the file.
+	if err := wrong.Save("21300000002", "x"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Save: got %v, want ErrWrongPassphrase", err)
+	}
+	if err := wrong.Delete("21300000001"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Delete: got %v, want ErrWrongPassphrase", err)
+	}
+	if got, err := newTestFile(path, "passphrase").Load("21300000001"); err != nil || got != "hunter2" {
+		t.Errorf("got %q, %v after the wrong passphrase", got, err)
+	}
+}
+
+func TestFileTampered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := newTestFile(path, "passphrase").Save("21300000001", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip := func(i int) []byte {
+		d := append([]byte(nil), data...)
+		d[i] ^= 2
+		return d
+	}
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrCorrupted},
+		{"truncated header", data[:headerSize], ErrCorrupted},
+		{"truncated", data[:len(data)-1], ErrCorrupted},
+		{"appended", append(append([]byte(nil), data...), 0), ErrCorrupted},
+		{"magic", flip(0), ErrCorrupted},
+		{"version", flip(4), ErrCorrupted},
+		{"no passes", append(append([]byte(fileMagic), 0, 0, 0, 0), data[len(fileMagic)+4:]...), ErrCorrupted},
+		{"huge memory", append(append([]byte(fileMagic), data[5:9]...), append([]byte{0, 0, 0, 0x80}, data[13:]...)...), ErrCorrupted},
+		// A key derived with other parameters or salt, or another check,
+		// does not match: the passphrase looks wrong.
+		{"time", flip(len(fileMagic)), ErrWrongPassphrase},
+		{"memory", flip(len(fileMagic) + 4), ErrWrongPassphrase},
+		{"salt", flip(len(fileMagic) + 9), ErrWrongPassphrase},
+		{"check", flip(headerSize - 1), ErrWrongPassphrase},
+		{"nonce", flip(headerSize), ErrCorrupted},
+		{"ciphertext", flip(headerSize + nonceSize), ErrCorrupted},
+		{"tag", flip(len(data) - 1), ErrCorrupted},
+	} {
+		if err := os.WriteFile(path, tc.data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		f := newTestFile(path, "passphrase")
+		if _, err := f.Load("21300000001"); !errors.Is(err, tc.want) {
+			t.Errorf("%s: Load got %v, want %v", tc.name, err, tc.want)
+		}
+		if err := f.Save("21300000002", "x"); !errors.Is(err, tc.want) {
+			t.Errorf("%s: Save got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestFileConcurrentProcesses(t *testing.T) {
+	const processes, ids = 2, 10
+	path := filepath.Join(t.TempDir(), "credentials")
+	cmds := make([]*exec.Cmd, processes)
+	for i := range cmds {
+		cmds[i] = exec.Command(os.Args[0], "-test.run=^TestSaveProcess$")
+		cmds[i].Env = append(os.Environ(), "FDU_CREDENTIALS_FILE="+path, fmt.Sprint("FDU_CREDENTIALS_PROCESS=", i))
+		if err := cmds[i].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := newTestFile(path, "passphrase")
+	for i := range processes {
+		for j := range ids {
+			id := fmt.Sprint(21300000000 + 100*i + j)
+			if got, err := f.Load(id); err != nil || got != "password "+id {
+				t.Errorf("Load(%s) = %q, %v: a Save was lost", id, got, err)
+			}
+		}
+	}
+}
+
+// TestSaveProcess is run by TestFileConcurrentProcesses in each process,
+// saving passwords at the same time as the other ones.
+func TestSaveProcess(t *testing.T) {
+	path := os.Getenv("FDU_CREDENTIALS_FILE")
+	if path == "" {
+		t.Skip("run by TestFileConcurrentProcesses")
+	}
+	var i int
+	fmt.Sscan(os.Getenv("FDU_CREDENTIALS_PROCESS"), &i)
+	f := newTestFile(path, "passphrase")
+	for j := range 10 {
+		id := fmt.Sprint(21300000000 + 100*i + j)
+		if err := f.Save(id, "password "+id); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
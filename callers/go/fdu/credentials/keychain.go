@@ -0,0 +1,60 @@
+package credentials
+
+import "fmt"
+
+// DefaultService is the service of the passwords of NewKeychain(""), e.g. the
+// name of the items in Keychain Access.
+const DefaultService = "libfdu"
+
+// Keychain is a Store which keeps the passwords in the keychain of the
+// system, under a service and the student ID:
+//
+//   - on Windows, as generic credentials of the Credential Manager, named
+//     "<service>:<student ID>";
+//   - on macOS, as generic passwords of the default keychain, with the
+//     security tool;
+//   - on Linux, in the Secret Service, e.g. GNOME Keyring or KWallet, with the
+//     secret-tool of libsecret.
+//
+// Elsewhere, or when the keychain cannot be reached, e.g. in a Linux session
+// without D-Bus, the methods return an error wrapping ErrUnavailable: use a
+// File instead.
+type Keychain struct {
+	service string
+}
+
+// NewKeychain returns the Keychain of service, DefaultService if empty. The
+// service is made of ASCII letters, digits, '.', '-' and '_'; NewKeychain
+// panics otherwise.
+func NewKeychain(service string) *Keychain {
+	if service == "" {
+		service = DefaultService
+	}
+	for _, c := range service {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '.' || c == '-' || c == '_') {
+			panic(fmt.Sprintf("credentials: invalid keychain service %q", service))
+		}
+	}
+	return &Keychain{service: service}
+}
+
+func (k *Keychain) Save(studentID, password string) error {
+	if err := checkID(studentID); err != nil {
+		return err
+	}
+	return keychainSave(k.service, studentID, password)
+}
+
+func (k *Keychain) Load(studentID string) (string, error) {
+	if err := checkID(studentID); err != nil {
+		return "", err
+	}
+	return keychainLoad(k.service, studentID)
+}
+
+func (k *Keychain) Delete(studentID string) error {
+	if err := checkID(studentID); err != nil {
+		return err
+	}
+	return keychainDelete(k.service, studentID)
+}
@@ -0,0 +1,70 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// errSecItemNotFound is the exit status of the security tool when there is
+// no such item.
+const errSecItemNotFound = 44
+
+// The passwords are saved in base64, since security prints the passwords
+// which are not ASCII in hexadecimal, and they are then written to the
+// standard input of security -i, so that they never appear in the arguments
+// of a process. The service and the student ID need no quoting there.
+
+func keychainSave(service, studentID, password string) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		service, studentID, base64.StdEncoding.EncodeToString([]byte(password))))
+	_, err := security(cmd)
+	return err
+}
+
+func keychainLoad(service, studentID string) (string, error) {
+	out, err := security(exec.Command("security", "find-generic-password", "-s", service, "-a", studentID, "-w"))
+	if errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, studentID)
+	}
+	if err != nil {
+		return "", err
+	}
+	password, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(out)))
+	if err != nil {
+		return "", fmt.Errorf("credentials: the password of %s in the keychain is not from this package", studentID)
+	}
+	return string(password), nil
+}
+
+func keychainDelete(service, studentID string) error {
+	_, err := security(exec.Command("security", "delete-generic-password", "-s", service, "-a", studentID))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// security runs cmd, the security tool, and returns its output. security -i
+// exits with 0 whatever its commands do, but reports their errors.
+func security(cmd *exec.Cmd) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exit *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	case errors.As(err, &exit) && exit.ExitCode() == errSecItemNotFound:
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, fmt.Errorf("%w: security: %v: %s", ErrUnavailable, err, bytes.TrimSpace(stderr.Bytes()))
+	case stderr.Len() > 0:
+		return nil, fmt.Errorf("%w: security: %s", ErrUnavailable, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
@@ -0,0 +1,57 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The passwords are given to secret-tool on its standard input, so that they
+// never appear in the arguments of a process.
+
+func keychainSave(service, studentID, password string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", service+": "+studentID, "service", service, "account", studentID)
+	cmd.Stdin = strings.NewReader(password)
+	_, err := secretTool(cmd)
+	return err
+}
+
+func keychainLoad(service, studentID string) (string, error) {
+	out, err := secretTool(exec.Command("secret-tool", "lookup", "service", service, "account", studentID))
+	if errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, studentID)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func keychainDelete(service, studentID string) error {
+	_, err := secretTool(exec.Command("secret-tool", "clear", "service", service, "account", studentID))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// secretTool runs cmd, secret-tool, and returns its output. secret-tool
+// fails without a message when no item matches, and with one when the
+// Secret Service cannot be reached.
+func secretTool(cmd *exec.Cmd) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exit *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, fmt.Errorf("%w: %v, install libsecret-tools or libsecret", ErrUnavailable, err)
+	case errors.As(err, &exit) && stderr.Len() == 0:
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, fmt.Errorf("%w: secret-tool: %s", ErrUnavailable, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
@@ -0,0 +1,66 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSecretTool puts first in PATH a secret-tool keeping the items in
+// files, or failing like without a Secret Service if unavailable.
+func fakeSecretTool(t *testing.T, unavailable bool) {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+if [ -n "$FAKE_UNAVAILABLE" ]; then
+	echo "secret-tool: Cannot autolaunch D-Bus without X11 \$DISPLAY" >&2
+	exit 1
+fi
+cmd=$1
+shift
+if [ "$cmd" = store ]; then
+	[ "$1" = --label ] || exit 2
+	shift 2
+fi
+[ "$1" = service ] && [ "$3" = account ] || exit 2
+item="` + dir + `/$2.$4"
+case $cmd in
+store) cat > "$item" ;;
+lookup) [ -f "$item" ] || exit 1; cat "$item" ;;
+clear) rm -f "$item" ;;
+*) exit 2 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if unavailable {
+		t.Setenv("FAKE_UNAVAILABLE", "1")
+	}
+}
+
+func TestKeychainSecretTool(t *testing.T) {
+	fakeSecretTool(t, false)
+	testStore(t, NewKeychain(""))
+}
+
+func TestKeychainSecretToolUnavailable(t *testing.T) {
+	fakeSecretTool(t, true)
+	store := NewKeychain("")
+	if err := store.Save("21300000001", "hunter2"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Save: got %v, want ErrUnavailable", err)
+	}
+	if _, err := store.Load("21300000001"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Load: got %v, want ErrUnavailable", err)
+	}
+	if err := store.Delete("21300000001"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Delete: got %v, want ErrUnavailable", err)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := store.Load("21300000001"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("without secret-tool: got %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !(darwin || linux || windows)
+
+package credentials
+
+import "fmt"
+
+func keychainSave(service, studentID, password string) error {
+	return fmt.Errorf("%w on this system", ErrUnavailable)
+}
+
+func keychainLoad(service, studentID string) (string, error) {
+	return "", fmt.Errorf("%w on this system", ErrUnavailable)
+}
+
+func keychainDelete(service, studentID string) error {
+	return fmt.Errorf("%w on this system", ErrUnavailable)
+}
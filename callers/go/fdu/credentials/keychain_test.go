@@ -0,0 +1,41 @@
+//go:build fdu_keychain
+
+package credentials
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestKeychain saves, loads and deletes passwords in the keychain of the
+// system, under the service libfdu-test. It runs with the fdu_keychain build
+// tag only, since the keychain may ask the user to unlock it, and skips when
+// there is no keychain:
+//
+//	go test -tags fdu_keychain ./fdu/credentials
+func TestKeychain(t *testing.T) {
+	store := NewKeychain("libfdu-test")
+	if _, err := store.Load("21300000001"); errors.Is(err, ErrUnavailable) {
+		t.Skip(err)
+	}
+	for _, id := range []string{"21300000001", "21300000002", "zhangsan"} {
+		if err := store.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Delete(id) })
+	}
+	testStore(t, store)
+}
+
+func TestKeychainServices(t *testing.T) {
+	a, b := NewKeychain("libfdu-test"), NewKeychain("libfdu-test.other")
+	if err := a.Save("21300000001", "hunter2"); errors.Is(err, ErrUnavailable) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Delete("21300000001") })
+	if _, err := b.Load("21300000001"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v from another service, want ErrNotFound", err)
+	}
+}
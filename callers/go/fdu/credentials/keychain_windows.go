@@ -0,0 +1,99 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+
+	errorNotFound           syscall.Errno = 1168
+	errorNoSuchLogonSession syscall.Errno = 1312
+)
+
+// credential is CREDENTIALW.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+func keychainSave(service, studentID, password string) error {
+	if err := advapi32.Load(); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	target, _ := syscall.UTF16PtrFromString(service + ":" + studentID)
+	user, _ := syscall.UTF16PtrFromString(studentID)
+	blob := []byte(password)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return credError("CredWriteW", err)
+	}
+	return nil
+}
+
+func keychainLoad(service, studentID string) (string, error) {
+	if err := advapi32.Load(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	target, _ := syscall.UTF16PtrFromString(service + ":" + studentID)
+	var cred *credential
+	if r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); r == 0 {
+		if errors.Is(err, errorNotFound) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, studentID)
+		}
+		return "", credError("CredReadW", err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func keychainDelete(service, studentID string) error {
+	if err := advapi32.Load(); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	target, _ := syscall.UTF16PtrFromString(service + ":" + studentID)
+	if r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); r == 0 && !errors.Is(err, errorNotFound) {
+		return credError("CredDeleteW", err)
+	}
+	return nil
+}
+
+// credError returns the error of a call to the Credential Manager, which is
+// unavailable without a logon session, e.g. for some services.
+func credError(call string, err error) error {
+	if errors.Is(err, errorNoSuchLogonSession) {
+		return fmt.Errorf("%w: %s: %v", ErrUnavailable, call, err)
+	}
+	return fmt.Errorf("credentials: %s: %v", call, err)
+}
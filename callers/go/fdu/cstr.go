@@ -0,0 +1,32 @@
+package fdu
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// checkCString returns an error wrapping ErrInvalidArgument if s cannot be
+// passed to libfdu as is: a NUL byte would end the C string early, e.g. a
+// password pasted with a trailing NUL would log in with a truncated one, and
+// libfdu only takes UTF-8. name is only used in the error, which does not
+// contain s since it may be a password.
+func checkCString(name, s string) error {
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		return argumentError("%s contains a NUL byte at byte %d", name, i)
+	}
+	if !utf8.ValidString(s) {
+		return argumentError("%s is not valid UTF-8", name)
+	}
+	return nil
+}
+
+// checkCStrings is checkCString for several strings, given as pairs of a
+// name and a string.
+func checkCStrings(namesAndStrings ...string) error {
+	for i := 0; i+1 < len(namesAndStrings); i += 2 {
+		if err := checkCString(namesAndStrings[i], namesAndStrings[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestCStringRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, s := range []string{
+		"",
+		"数据结构与算法",
+		"👩‍💻🎉 emoji, ZWJ included",
+		"mixed 中文 and ASCII, 한국어, العربية",
+		strings.Repeat("长🎉x", 1<<20/8),
+	} {
+		got, err := testEcho(ctx, s)
+		if err != nil {
+			t.Fatalf("%.20q...: %v", s, err)
+		}
+		if got != s {
+			t.Errorf("%.20q... came back as %.20q... (%d bytes, want %d)", s, got, len(got), len(s))
+		}
+	}
+
+	// Strings of random runes, any of which may be NUL.
+	err := quick.Check(func(s string) bool {
+		got, err := testEcho(ctx, s)
+		if strings.IndexByte(s, 0) >= 0 {
+			return errors.Is(err, ErrInvalidArgument)
+		}
+		return err == nil && got == s
+	}, &quick.Config{MaxCount: 200})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCStringRejected(t *testing.T) {
+	for _, s := range []string{"pass\x00word", "\x00", "password\x00", "\xff\xfe", "ok\xe4\xb8"} {
+		if _, err := testEcho(context.Background(), s); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%q: got %v, want ErrInvalidArgument", s, err)
+		}
+	}
+
+	// Rejected before the call: a truncated password must not reach UIS.
+	_, err := Login(context.Background(), "20300000001", "secret\x00pasted")
+	if !errors.Is(err, ErrInvalidArgument) || !strings.Contains(err.Error(), "password contains a NUL byte at byte 6") {
+		t.Errorf("got %v, want ErrInvalidArgument", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("the error leaks the password: %v", err)
+	}
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Courses(context.Background(), "443\x00"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("courses: got %v, want ErrInvalidArgument", err)
+	}
+	if r := s.Batch(context.Background(), []Request{ScoresRequest("\xff")}); !errors.Is(r[0].Err, ErrInvalidArgument) {
+		t.Errorf("batch: got %v, want ErrInvalidArgument", r[0].Err)
+	}
+}
@@ -0,0 +1,182 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Room is a dormitory room, as parsed by ParseRoom.
+type Room struct {
+	// Campus is CampusHandan or CampusJiangwan, the only ones with the
+	// electricity of their dormitories in the card system.
+	Campus Campus
+	// Building is e.g. "南区10号楼" or "江湾5号楼".
+	Building string
+	// Room is e.g. "302", or "A1203" in Jiangwan.
+	Room string
+}
+
+// String returns r as written on the door, e.g. "南区10号楼302".
+func (r Room) String() string {
+	return r.Building + r.Room
+}
+
+var (
+	// e.g. 南区10号楼302, 邯郸北区 3-215 or 东区12号1102室.
+	handanRoomRe = regexp.MustCompile(`^(?:邯郸\s*)?(南区|北区|东区)\s*(\d{1,3})\s*(?:号楼|号|-)\s*(\d{3,4})\s*室?$`)
+	// e.g. 江湾5号楼A1203, 江湾 5-b1203.
+	jiangwanRoomRe = regexp.MustCompile(`^江湾\s*(\d{1,2})\s*(?:号楼|号|-)\s*([ABab])\s*(\d{3,4})\s*室?$`)
+)
+
+// ParseRoom parses a dormitory room of Handan, like "南区10号楼302" or
+// "北区3-215", optionally prefixed with "邯郸", or of Jiangwan, like
+// "江湾5号楼A1203" where the wing is A or B. The result is normalized, e.g.
+// "北区3-215" and "北区3号楼215" parse to the same Room.
+func ParseRoom(s string) (Room, error) {
+	s = strings.TrimSpace(s)
+	if m := handanRoomRe.FindStringSubmatch(s); m != nil {
+		return Room{Campus: CampusHandan, Building: m[1] + trimZeros(m[2]) + "号楼", Room: m[3]}, nil
+	}
+	if m := jiangwanRoomRe.FindStringSubmatch(s); m != nil {
+		return Room{Campus: CampusJiangwan, Building: "江湾" + trimZeros(m[1]) + "号楼", Room: strings.ToUpper(m[2]) + m[3]}, nil
+	}
+	return Room{}, argumentError("invalid dormitory room %q", s)
+}
+
+// trimZeros trims the leading zeros of a building number, e.g. "05".
+func trimZeros(n string) string {
+	if trimmed := strings.TrimLeft(n, "0"); trimmed != "" {
+		return trimmed
+	}
+	return "0"
+}
+
+// Electricity is the electricity left in a dormitory room (寝室电费).
+type Electricity struct {
+	Room Room
+	// KWh is the electricity left, in kWh.
+	KWh float64
+	// Balance is the money left, in cents.
+	Balance int64
+	// LastTopUp is the time of the last top-up, zero if the room was never
+	// topped up.
+	LastTopUp time.Time
+}
+
+type rawElectricity struct {
+	KWh     float64 `json:"kwh"`
+	Balance int64   `json:"balance"`
+	// LastTopUp is e.g. "2024-03-01 12:30:05", or empty.
+	LastTopUp string `json:"last_top_up"`
+}
+
+// ElectricityBalance returns the electricity left in room of building on
+// campus, CampusHandan or CampusJiangwan, e.g. building "南区10号楼" and room
+// "302": see ParseRoom to get them from what a user writes. If the card
+// system does not know the room, the error wraps ErrInvalidArgument.
+func (s *Session) ElectricityBalance(ctx context.Context, campus Campus, building, room string, opts ...CallOption) (*Electricity, error) {
+	r := Room{Campus: campus, Building: building, Room: room}
+	if campus != CampusHandan && campus != CampusJiangwan {
+		return nil, argumentError("no dormitory electricity for campus %q", campus)
+	}
+	if building == "" || room == "" {
+		return nil, argumentError("empty building or room")
+	}
+	if err := checkCString("building", building); err != nil {
+		return nil, err
+	}
+	if err := checkCString("room", room); err != nil {
+		return nil, err
+	}
+
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduElectricityBalanceAsync(ptr, string(campus), building, room, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parseElectricity([]byte(v), r)
+}
+
+func parseElectricity(data []byte, room Room) (*Electricity, error) {
+	var raw rawElectricity
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, parseError("electricity: %v", err)
+	}
+	e := &Electricity{Room: room, KWh: raw.KWh, Balance: raw.Balance}
+	if raw.LastTopUp != "" {
+		t, err := parseChinaTime(time.DateTime, raw.LastTopUp)
+		if err != nil {
+			return nil, parseError("electricity: invalid top-up time %q", raw.LastTopUp)
+		}
+		e.LastTopUp = t
+	}
+	return e, nil
+}
+
+// Alert is delivered by WatchElectricity when the electricity of a room
+// falls below the threshold.
+type Alert struct {
+	Electricity Electricity
+	// Threshold is the threshold passed to WatchElectricity, in kWh.
+	Threshold float64
+	// Time is when the electricity was queried.
+	Time time.Time
+}
+
+// WatchElectricity queries the electricity left in room every interval until
+// ctx is done, and delivers an Alert on the returned channel when it falls
+// below threshold kWh. It alerts once, not at every query, until the room is
+// topped up to threshold or more, after which it alerts again the next time
+// the electricity falls below threshold.
+//
+// The first query is immediate. A failed query is retried at the next tick,
+// except when session is closed or the room unknown, which stops the watch.
+// The channel is closed once the watch stops. WatchElectricity panics if
+// interval is not positive, like time.NewTicker.
+func WatchElectricity(ctx context.Context, session *Session, room Room, threshold float64, interval time.Duration) <-chan Alert {
+	if interval <= 0 {
+		panic("fdu: non-positive interval for WatchElectricity")
+	}
+	alerts := make(chan Alert)
+	go watchElectricity(ctx, session, room, threshold, interval, alerts)
+	return alerts
+}
+
+func watchElectricity(ctx context.Context, session *Session, room Room, threshold float64, interval time.Duration, alerts chan<- Alert) {
+	defer close(alerts)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	alerted := false
+	for {
+		e, err := session.ElectricityBalance(ctx, room.Campus, room.Building, room.Room)
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case errors.Is(err, ErrClosed) || errors.Is(err, ErrInvalidArgument):
+			return
+		case err != nil:
+			// Retried at the next tick.
+		case e.KWh >= threshold:
+			alerted = false
+		case !alerted:
+			select {
+			case alerts <- Alert{Electricity: *e, Threshold: threshold, Time: time.Now()}:
+				alerted = true
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func TestParseRoom(t *testing.T) {
+	handan := Room{Campus: CampusHandan, Building: "南区10号楼", Room: "302"}
+	jiangwan := Room{Campus: CampusJiangwan, Building: "江湾5号楼", Room: "A1203"}
+	for s, want := range map[string]Room{
+		"南区10号楼302":    handan,
+		"邯郸南区10号楼302室": handan,
+		" 南区 10-302 ":  handan,
+		"南区010号302":    handan,
+		"北区3号楼1215":    {Campus: CampusHandan, Building: "北区3号楼", Room: "1215"},
+		"江湾5号楼A1203":   jiangwan,
+		"江湾 5-a1203":   jiangwan,
+		"江湾05号B301室":   {Campus: CampusJiangwan, Building: "江湾5号楼", Room: "B301"},
+	} {
+		got, err := ParseRoom(s)
+		if err != nil || got != want {
+			t.Errorf("ParseRoom(%q) = %+v, %v, want %+v", s, got, err, want)
+		}
+	}
+	if got := handan.String(); got != "南区10号楼302" {
+		t.Errorf("got %q", got)
+	}
+
+	for _, s := range []string{
+		"",
+		"302",
+		"西区10号楼302",
+		"南区10号楼30",
+		"南区10号楼30201",
+		"江湾5号楼1203",
+		"江湾5号楼C1203",
+		"5号楼A1203",
+		"枫林10号楼302",
+	} {
+		if _, err := ParseRoom(s); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ParseRoom(%q): got %v, want ErrInvalidArgument", s, err)
+		}
+	}
+}
+
+// fakeElectricity answers the n-th query of the electricity, from 0, with the
+// value and the error code of answer.
+func fakeElectricity(t *testing.T, answer func(n int) (string, int32)) *atomic.Int32 {
+	t.Helper()
+	orig := lib.fduElectricityBalanceAsync
+	t.Cleanup(func() { lib.fduElectricityBalanceAsync = orig })
+	calls := new(atomic.Int32)
+	lib.fduElectricityBalanceAsync = func(_ *cSession, _, _, _ string, token *cCancelToken, requestID uint64) *cResult {
+		value, code := answer(int(calls.Add(1) - 1))
+		return lib.fduTestResultAsync(value, code, 0, token, requestID)
+	}
+	return calls
+}
+
+func electricityJSON(kwh float64) string {
+	return fmt.Sprintf(`{"kwh": %g, "balance": %d, "last_top_up": ""}`, kwh, int64(kwh*60))
+}
+
+func TestElectricityBalance(t *testing.T) {
+	data, err := os.ReadFile("testdata/electricity.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	calls := fakeElectricity(t, func(int) (string, int32) { return string(data), 0 })
+	ctx := context.Background()
+
+	e, err := s.ElectricityBalance(ctx, CampusHandan, "南区10号楼", "302")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Electricity{
+		Room:      Room{Campus: CampusHandan, Building: "南区10号楼", Room: "302"},
+		KWh:       123.45,
+		Balance:   6790,
+		LastTopUp: time.Date(2024, 3, 1, 12, 30, 5, 0, chinaTime),
+	}
+	if e.Room != want.Room || e.KWh != want.KWh || e.Balance != want.Balance || !e.LastTopUp.Equal(want.LastTopUp) {
+		t.Errorf("got %+v, want %+v", e, want)
+	}
+
+	for _, tc := range []struct {
+		campus         Campus
+		building, room string
+	}{
+		{CampusFenglin, "南区10号楼", "302"},
+		{"", "南区10号楼", "302"},
+		{CampusHandan, "", "302"},
+		{CampusJiangwan, "江湾5号楼", ""},
+		{CampusHandan, "南区\x0010号楼", "302"},
+	} {
+		if _, err := s.ElectricityBalance(ctx, tc.campus, tc.building, tc.room); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ElectricityBalance(%q, %q, %q): got %v, want ErrInvalidArgument", tc.campus, tc.building, tc.room, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("got %d calls, want 1", n)
+	}
+}
+
+func TestParseElectricity(t *testing.T) {
+	e, err := parseElectricity([]byte(`{"kwh": 0, "balance": 0, "last_top_up": ""}`), Room{})
+	if err != nil || e.KWh != 0 || e.Balance != 0 || !e.LastTopUp.IsZero() {
+		t.Errorf("got (%+v, %v), want an empty room never topped up", e, err)
+	}
+	for name, data := range map[string]string{
+		"not json":      `<html>`,
+		"bad time":      `{"kwh": 1, "balance": 60, "last_top_up": "2024/03/01"}`,
+		"float balance": `{"kwh": 1, "balance": 0.6, "last_top_up": ""}`,
+	} {
+		if _, err := parseElectricity([]byte(data), Room{}); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func FuzzParseElectricity(f *testing.F) {
+	parse := func(data []byte) (*Electricity, error) { return parseElectricity(data, Room{}) }
+	fuzzParse(f, []string{"electricity.json"}, nil, parse, nil)
+}
+
+// ignorePoller ignores the goroutine polling the completions of libfdu,
+// which outlives the last job by up to pollTimeoutMillis.
+var ignorePoller = goleak.IgnoreAnyFunction("github.com/DanXi-Dev/libfdu/callers/go/fdu.poll")
+
+func TestWatchElectricity(t *testing.T) {
+	leaks := goleak.IgnoreCurrent()
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// Below 10 at 1 and 2, topped up at 3, below again from 4, with a
+	// network error at 5.
+	levels := []float64{20, 8, 5, 50, 9, -1, 7}
+	done := make(chan struct{})
+	fakeElectricity(t, func(n int) (string, int32) {
+		if n >= len(levels) {
+			n = len(levels) - 1
+			select {
+			case <-done:
+			default:
+				close(done)
+			}
+		}
+		if levels[n] < 0 {
+			return "", int32(ErrCodeNetwork)
+		}
+		return electricityJSON(levels[n]), 0
+	})
+
+	room := Room{Campus: CampusHandan, Building: "南区10号楼", Room: "302"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	alerts := WatchElectricity(ctx, s, room, 10, time.Millisecond)
+	var got []float64
+	for len(got) < 2 {
+		a := <-alerts
+		if a.Threshold != 10 || a.Electricity.Room != room || a.Time.IsZero() {
+			t.Errorf("got alert %+v", a)
+		}
+		got = append(got, a.Electricity.KWh)
+	}
+	if got[0] != 8 || got[1] != 9 {
+		t.Errorf("got alerts at %v, want [8 9]", got)
+	}
+	// The last level, still below the threshold, is queried again without
+	// an alert.
+	<-done
+	select {
+	case a := <-alerts:
+		t.Errorf("got alert %+v again", a)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel()
+	for range alerts {
+	}
+	goleak.VerifyNone(t, leaks, ignorePoller)
+}
+
+func TestWatchElectricityStop(t *testing.T) {
+	leaks := goleak.IgnoreCurrent()
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	room := Room{Campus: CampusJiangwan, Building: "江湾5号楼", Room: "A1203"}
+
+	// The room is unknown.
+	fakeElectricity(t, func(int) (string, int32) { return "", int32(ErrCodeInvalidArgument) })
+	for range WatchElectricity(context.Background(), s, room, 10, time.Millisecond) {
+		t.Error("got an alert for an unknown room")
+	}
+	goleak.VerifyNone(t, leaks, ignorePoller)
+
+	// A pending alert is dropped once ctx is done.
+	fakeElectricity(t, func(int) (string, int32) { return electricityJSON(1), 0 })
+	ctx, cancel := context.WithCancel(context.Background())
+	WatchElectricity(ctx, s, room, 10, time.Hour)
+	cancel()
+	goleak.VerifyNone(t, leaks, ignorePoller)
+
+	// The session is closed.
+	s.Close()
+	for range WatchElectricity(context.Background(), s, room, 10, time.Millisecond) {
+		t.Error("got an alert for a closed session")
+	}
+	goleak.VerifyNone(t, leaks, ignorePoller)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for a zero interval")
+		}
+	}()
+	WatchElectricity(context.Background(), s, room, 10, 0)
+}
@@ -0,0 +1,69 @@
+// Code generated by internal/gen from bindings.h; DO NOT EDIT.
+
+package fdu
+
+import "strconv"
+
+// ErrCode is a value of FduErrorCode.
+type ErrCode int32
+
+const (
+	ErrCodeOK              ErrCode = 0  // FDU_ERROR_CODE_OK
+	ErrCodeUnknown         ErrCode = 1  // FDU_ERROR_CODE_UNKNOWN
+	ErrCodeNetwork         ErrCode = 2  // FDU_ERROR_CODE_NETWORK
+	ErrCodeAuthFailed      ErrCode = 3  // FDU_ERROR_CODE_AUTH_FAILED
+	ErrCodeParse           ErrCode = 4  // FDU_ERROR_CODE_PARSE
+	ErrCodeInvalidArgument ErrCode = 5  // FDU_ERROR_CODE_INVALID_ARGUMENT
+	ErrCodePanic           ErrCode = 6  // FDU_ERROR_CODE_PANIC
+	ErrCodeCancelled       ErrCode = 7  // FDU_ERROR_CODE_CANCELLED
+	ErrCodeCaptchaRequired ErrCode = 8  // FDU_ERROR_CODE_CAPTCHA_REQUIRED
+	ErrCodeQRDisabled      ErrCode = 9  // FDU_ERROR_CODE_QR_DISABLED
+	ErrCodeCertPinMismatch ErrCode = 10 // FDU_ERROR_CODE_CERT_PIN_MISMATCH
+	ErrCodeRateLimited     ErrCode = 11 // FDU_ERROR_CODE_RATE_LIMITED
+)
+
+// allErrCodes lists the ErrCode constants in the order of bindings.h.
+var allErrCodes = []ErrCode{
+	ErrCodeOK,
+	ErrCodeUnknown,
+	ErrCodeNetwork,
+	ErrCodeAuthFailed,
+	ErrCodeParse,
+	ErrCodeInvalidArgument,
+	ErrCodePanic,
+	ErrCodeCancelled,
+	ErrCodeCaptchaRequired,
+	ErrCodeQRDisabled,
+	ErrCodeCertPinMismatch,
+	ErrCodeRateLimited,
+}
+
+func (c ErrCode) String() string {
+	switch c {
+	case ErrCodeOK:
+		return "OK"
+	case ErrCodeUnknown:
+		return "UNKNOWN"
+	case ErrCodeNetwork:
+		return "NETWORK"
+	case ErrCodeAuthFailed:
+		return "AUTH_FAILED"
+	case ErrCodeParse:
+		return "PARSE"
+	case ErrCodeInvalidArgument:
+		return "INVALID_ARGUMENT"
+	case ErrCodePanic:
+		return "PANIC"
+	case ErrCodeCancelled:
+		return "CANCELLED"
+	case ErrCodeCaptchaRequired:
+		return "CAPTCHA_REQUIRED"
+	case ErrCodeQRDisabled:
+		return "QR_DISABLED"
+	case ErrCodeCertPinMismatch:
+		return "CERT_PIN_MISMATCH"
+	case ErrCodeRateLimited:
+		return "RATE_LIMITED"
+	}
+	return "ErrCode(" + strconv.FormatInt(int64(c), 10) + ")"
+}
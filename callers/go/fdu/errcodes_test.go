@@ -0,0 +1,57 @@
+package fdu
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// TestErrCodesInSync fails if bindings.h has changed without running
+// go generate, or if a new code has no sentinel error.
+func TestErrCodesInSync(t *testing.T) {
+	header, err := os.ReadFile("bindings.h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := regexp.MustCompile(`(?m)^\s*FDU_ERROR_CODE_(\w+) = (-?\w+),`).FindAllSubmatch(header, -1)
+	if len(matches) == 0 {
+		t.Fatal("no error code found in bindings.h")
+	}
+	if len(matches) != len(allErrCodes) {
+		t.Errorf("bindings.h has %d error codes, Go has %d; run go generate", len(matches), len(allErrCodes))
+	}
+
+	byName := make(map[string]ErrCode)
+	for _, code := range allErrCodes {
+		byName[code.String()] = code
+	}
+	for _, m := range matches {
+		name := string(m[1])
+		value, err := strconv.ParseInt(string(m[2]), 0, 32)
+		if err != nil {
+			t.Errorf("FDU_ERROR_CODE_%s: %v", name, err)
+			continue
+		}
+		code, ok := byName[name]
+		if !ok {
+			t.Errorf("FDU_ERROR_CODE_%s has no Go constant; run go generate", name)
+			continue
+		}
+		if int64(code) != value {
+			t.Errorf("FDU_ERROR_CODE_%s = %d, but Go has %d; run go generate", name, value, code)
+		}
+		if _, ok := errorsByCode[code]; !ok && code != ErrCodeOK && code != ErrCodePanic {
+			t.Errorf("%v has no sentinel error in errorsByCode", code)
+		}
+	}
+}
+
+func TestErrCodeString(t *testing.T) {
+	if s := ErrCodeAuthFailed.String(); s != "AUTH_FAILED" {
+		t.Errorf("got %q", s)
+	}
+	if s := ErrCode(10000).String(); s != "ErrCode(10000)" {
+		t.Errorf("got %q", s)
+	}
+}
@@ -0,0 +1,149 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors for the well-known error codes of libfdu. Use errors.Is to
+// check against them, since the actual error returned is an *Error.
+var (
+	ErrUnknown    = errors.New("unknown error")
+	ErrNetwork    = errors.New("network error")
+	ErrAuthFailed = errors.New("authentication failed")
+	ErrParse      = errors.New("parse error")
+	// ErrInvalidArgument is returned when an argument is rejected before or
+	// by the library, e.g. a NULL pointer or invalid UTF-8.
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrCaptchaRequired is wrapped by *CaptchaRequiredError.
+	ErrCaptchaRequired = errors.New("captcha required")
+	// ErrQRDisabled is returned by Session.PaymentQR when the account has QR
+	// payment disabled.
+	ErrQRDisabled = errors.New("QR payment disabled")
+	// ErrCertPinMismatch is returned when a server has none of the keys of
+	// Config.PinnedSPKIHashes in its chain, which may mean that someone on
+	// the network is intercepting the connection: the message names the
+	// host, and retrying does not help.
+	ErrCertPinMismatch = errors.New("certificate pin mismatch")
+	// ErrRateLimited is wrapped by *RateLimitedError.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrClosed is returned when a method is called on a closed Session.
+	ErrClosed = errors.New("fdu: session closed")
+	// ErrPoolClosed is returned by SessionPool.Acquire after
+	// SessionPool.Close.
+	ErrPoolClosed = errors.New("fdu: session pool closed")
+	// ErrNotInitialized is returned by every call before Init, or after
+	// Shutdown.
+	ErrNotInitialized = errors.New("fdu: not initialized")
+	// ErrIncompatibleLibrary is wrapped by the error of Init when the loaded
+	// libfdu does not have the ABI this package is built against.
+	ErrIncompatibleLibrary = errors.New("fdu: incompatible libfdu")
+	// ErrReleaseBuild is returned by the test hooks, e.g. SetBaseURLs, with a
+	// release build of libfdu.
+	ErrReleaseBuild = errors.New("fdu: test hooks need a debug build of libfdu or the test-exports feature")
+	// ErrWritesNotAllowed is returned by the methods changing the account,
+	// e.g. Session.Enroll, unless Init is called with AllowWrites.
+	ErrWritesNotAllowed = errors.New("fdu: writes not allowed, see AllowWrites")
+	// ErrRenewRefused is wrapped by *RenewError.
+	ErrRenewRefused = errors.New("fdu: renewal refused")
+	// ErrNoAllocStats is returned by AllocStats when libfdu is built without
+	// the alloc-stats feature.
+	ErrNoAllocStats = errors.New("fdu: libfdu built without the alloc-stats feature")
+)
+
+//go:generate go run ../internal/gen -enum FduErrorCode -prefix FDU_ERROR_CODE_ -type ErrCode -pkg fdu -o errcodes_gen.go bindings.h
+
+// errorsByCode maps the codes to their sentinel errors. Every code but
+// ErrCodeOK and ErrCodePanic, which is reported as a *PanicError, must have
+// one; TestErrCodesInSync checks this against bindings.h.
+var errorsByCode = map[ErrCode]error{
+	ErrCodeUnknown:         ErrUnknown,
+	ErrCodeNetwork:         ErrNetwork,
+	ErrCodeAuthFailed:      ErrAuthFailed,
+	ErrCodeParse:           ErrParse,
+	ErrCodeInvalidArgument: ErrInvalidArgument,
+	ErrCodeCancelled:       context.Canceled,
+	ErrCodeCaptchaRequired: ErrCaptchaRequired,
+	ErrCodeQRDisabled:      ErrQRDisabled,
+	ErrCodeCertPinMismatch: ErrCertPinMismatch,
+	ErrCodeRateLimited:     ErrRateLimited,
+}
+
+// Error is an error reported by libfdu.
+type Error struct {
+	// Code is the FduErrorCode returned by the library.
+	Code ErrCode
+	// Message is the description provided by the library.
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("fdu: %v", e.Unwrap())
+	}
+	return fmt.Sprintf("fdu: %v: %s", e.Unwrap(), e.Message)
+}
+
+// Unwrap returns the sentinel error matching e.Code, or ErrUnknown for codes
+// this package does not know about.
+func (e *Error) Unwrap() error {
+	if err, ok := errorsByCode[e.Code]; ok {
+		return err
+	}
+	return ErrUnknown
+}
+
+// PanicError is returned when libfdu panicked while handling a call. The
+// library is still usable afterwards, but the session involved in the call may
+// be in an inconsistent state and should be discarded.
+type PanicError struct {
+	// Message is the panic message.
+	Message string
+}
+
+func (e *PanicError) Error() string {
+	return "fdu: libfdu panicked: " + e.Message
+}
+
+// LoadError is returned when libfdu cannot be loaded at runtime, which only
+// happens with the fdu_purego build tag.
+type LoadError struct {
+	// Tried lists the paths tried, in order.
+	Tried []string
+	// Errs are the errors of the paths tried, in the same order. A missing
+	// file is fs.ErrNotExist.
+	Errs []error
+	// Err is the error of the last path tried.
+	Err error
+}
+
+func (e *LoadError) Error() string {
+	var b strings.Builder
+	b.WriteString("fdu: cannot load libfdu, set FDU_LIBRARY_PATH to its path or call Load; tried:")
+	for i, path := range e.Tried {
+		err := e.Err
+		if i < len(e.Errs) {
+			err = e.Errs[i]
+		}
+		fmt.Fprintf(&b, "\n\t%s: %v", path, err)
+	}
+	return b.String()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// parseError returns an error wrapping ErrParse, for payloads from libfdu
+// which cannot be decoded or fail validation.
+func parseError(format string, args ...any) error {
+	return &Error{Code: ErrCodeParse, Message: fmt.Sprintf(format, args...)}
+}
+
+// argumentError returns an error wrapping ErrInvalidArgument, for arguments
+// rejected before calling into libfdu.
+func argumentError(format string, args ...any) error {
+	return &Error{Code: ErrCodeInvalidArgument, Message: fmt.Sprintf(format, args...)}
+}
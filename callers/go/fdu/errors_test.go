@@ -0,0 +1,108 @@
+package fdu
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorCodes(t *testing.T) {
+	cases := []struct {
+		code ErrCode
+		want error
+	}{
+		{1, ErrUnknown},
+		{2, ErrNetwork},
+		{3, ErrAuthFailed},
+		{4, ErrParse},
+		{10000, ErrUnknown},
+	}
+	for _, c := range cases {
+		_, err := testError(int32(c.code))
+		if !errors.Is(err, c.want) {
+			t.Errorf("code %d: got %v, want %v", c.code, err, c.want)
+		}
+		var fduErr *Error
+		if !errors.As(err, &fduErr) || fduErr.Code != c.code || fduErr.Message == "" {
+			t.Errorf("code %d: got %#v", c.code, err)
+		}
+	}
+}
+
+func TestEmptyResult(t *testing.T) {
+	// Code 0 with all fields NULL: no error, and freeing it must not crash.
+	s, err := testError(0)
+	if err != nil || s != "" {
+		t.Errorf("got (%q, %v), want empty success", s, err)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	err := testPanic()
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("got %v, want *PanicError", err)
+	}
+	if panicErr.Message != "test panic" {
+		t.Errorf("got message %q", panicErr.Message)
+	}
+	// The library must still work after a panic.
+	if s, err := Hello(); err != nil || s != "hello world" {
+		t.Errorf("Hello() = (%q, %v) after panic", s, err)
+	}
+}
+
+// parseErrorOf returns the error of parse for data.
+func parseErrorOf[T any](parse func(data []byte) (T, error)) func(data string) error {
+	return func(data string) error {
+		_, err := parse([]byte(data))
+		return err
+	}
+}
+
+// TestParseErrorField checks that the values out of range are rejected with
+// the name of their field.
+func TestParseErrorField(t *testing.T) {
+	courses := parseErrorOf(parseCourses)
+	exams := parseErrorOf(parseExams)
+	gpa := parseErrorOf(parseGPA)
+	selectable := parseErrorOf(parseSelectableCourses)
+	peScores := parseErrorOf(parsePETestScores)
+	stats := parseErrorOf(parseRateLimitStats)
+	transactions := func(data string) error {
+		_, _, err := parseCardTransactionPage([]byte(data), time.Time{}, time.Now())
+		return err
+	}
+	borrowed := func(data string) error {
+		returned := "2023-10-01"
+		_, err := parseBorrowRecord(rawBorrowRecord{Barcode: "0001", Borrowed: data, Returned: &returned})
+		return err
+	}
+	for _, c := range []struct {
+		parse func(data string) error
+		data  string
+		field string
+	}{
+		{courses, `[{"course_id": "A", "weekday": 1, "start_slot": 1, "end_slot": 2, "weeks": [0, 1]}]`, "weeks"},
+		{courses, `[{"course_id": "A", "weekday": 1, "start_slot": 1, "end_slot": 15}]`, "slot"},
+		{exams, `[{"course_id": "A", "date": "0001-01-01"}]`, "date"},
+		{transactions, `{"page": 1, "total_pages": 1, "transactions": [{"time": "0001-01-01 00:00:00"}]}`, "time"},
+		{borrowed, "2023-10-08", "returned"},
+		{borrowed, "0001-01-01", "borrowed"},
+		{gpa, `{"gpa": 4.5, "credits": 10, "ranking": 1, "total": 2}`, "gpa"},
+		{gpa, `{"gpa": 3.5, "credits": -1, "ranking": 1, "total": 2}`, "credits"},
+		{selectable, `[{"lesson_id": 1, "course_id": "A", "credits": -3}]`, "credits"},
+		{selectable, `[{"lesson_id": 0, "course_id": "A", "credits": 3}]`, "lesson_id"},
+		{selectable, `[{"lesson_id": 1, "course_id": "A", "capacity": -1}]`, "capacity"},
+		{peScores, `{"year": "2023", "total": -1}`, "total"},
+		{peScores, `{"year": "2023", "items": [{"name": "50米跑", "score": -5}]}`, "score"},
+		{stats, `{"hosts": {}, "throttled_requests": -1}`, "throttled_requests"},
+		{stats, `{"hosts": {"jwfw.fudan.edu.cn": {"per_second": -2}}}`, "per_second"},
+	} {
+		err := c.parse(c.data)
+		if !errors.Is(err, ErrParse) || !strings.Contains(err.Error(), c.field) {
+			t.Errorf("%s: got %v, want ErrParse naming %s", c.data, err, c.field)
+		}
+	}
+}
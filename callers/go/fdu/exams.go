@@ -0,0 +1,139 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// chinaTime is the time zone of all times reported by the university.
+var chinaTime = time.FixedZone("CST", 8*60*60)
+
+// minYear is the earliest year of the dates accepted from libfdu: the
+// university was founded in 1905, so an earlier date is bogus, e.g. the zero
+// date of a server.
+const minYear = 1905
+
+// Exam is an exam in a semester.
+type Exam struct {
+	// CourseID is the id of the class, e.g. COMP130004.03.
+	CourseID string
+	Name     string
+	// Type is e.g. "期末考试".
+	Type string
+	// Start and End are zero if the exam is not scheduled yet. If only the
+	// date is known, End is zero and Start is midnight of that day.
+	Start    time.Time
+	End      time.Time
+	Location string
+	Seat     string
+	Note     string
+}
+
+// Scheduled reports whether the date of the exam is known.
+func (e Exam) Scheduled() bool {
+	return !e.Start.IsZero()
+}
+
+type rawExam struct {
+	CourseID string `json:"course_id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	// Date is e.g. "2023-01-03".
+	Date string `json:"date"`
+	// Time is e.g. "08:30~10:30".
+	Time     string `json:"time"`
+	Location string `json:"location"`
+	Seat     string `json:"seat"`
+	Note     string `json:"note"`
+}
+
+// Exams returns the exams of the semester, given by one of the IDs of
+// Semesters or by its school year and term.
+func (s *Session) Exams(ctx context.Context, semesterID SemesterID, opts ...CallOption) ([]Exam, error) {
+	semesterID, err := s.semesterID(ctx, semesterID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return cached(s, ResourceExams, string(semesterID), func() (string, error) {
+		return s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+			return lib.fduExamsAsync(ptr, string(semesterID), token, id)
+		}, opts...)
+	}, parseExams)
+}
+
+// ExamsString is Exams with the semester ID as a string.
+//
+// Deprecated: Exams takes a SemesterID, see ParseSemesterID. ExamsString
+// will be removed in the next release.
+func (s *Session) ExamsString(ctx context.Context, semesterID string) ([]Exam, error) {
+	return s.Exams(ctx, SemesterID(semesterID))
+}
+
+func parseExams(data []byte) ([]Exam, error) {
+	var raws []rawExam
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, parseError("exams: %v", err)
+	}
+	exams := make([]Exam, 0, len(raws))
+	for _, raw := range raws {
+		exam := Exam{
+			CourseID: raw.CourseID,
+			Name:     raw.Name,
+			Type:     raw.Type,
+			Location: raw.Location,
+			Seat:     raw.Seat,
+			Note:     raw.Note,
+		}
+		if raw.Date != "" {
+			date, err := parseDate(raw.Date)
+			if err != nil {
+				return nil, parseError("exam %s: invalid date %q", raw.CourseID, raw.Date)
+			}
+			exam.Start = date
+			if raw.Time != "" {
+				start, end, ok := strings.Cut(raw.Time, "~")
+				if !ok {
+					return nil, parseError("exam %s: invalid time %q", raw.CourseID, raw.Time)
+				}
+				if exam.Start, err = atClock(date, start); err != nil {
+					return nil, parseError("exam %s: invalid time %q", raw.CourseID, raw.Time)
+				}
+				if exam.End, err = atClock(date, end); err != nil || exam.End.Before(exam.Start) {
+					return nil, parseError("exam %s: invalid time %q", raw.CourseID, raw.Time)
+				}
+			}
+		}
+		exams = append(exams, exam)
+	}
+	return exams, nil
+}
+
+// parseChinaTime parses value, in China time, like time.ParseInLocation, and
+// rejects the dates before minYear.
+func parseChinaTime(layout, value string) (time.Time, error) {
+	t, err := time.ParseInLocation(layout, value, chinaTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t.Year() < minYear {
+		return time.Time{}, fmt.Errorf("%q is before %d", value, minYear)
+	}
+	return t, nil
+}
+
+// parseDate parses a date, e.g. "2023-01-03", at midnight, China time.
+func parseDate(date string) (time.Time, error) {
+	return parseChinaTime(time.DateOnly, date)
+}
+
+// atClock returns the time of the day at clock, e.g. "08:30".
+func atClock(day time.Time, clock string) (time.Time, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), nil
+}
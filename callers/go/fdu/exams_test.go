@@ -0,0 +1,67 @@
+package fdu
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseExams(t *testing.T) {
+	data, err := os.ReadFile("testdata/exams.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exams, err := parseExams(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exams) != 3 {
+		t.Fatalf("got %d exams, want 3", len(exams))
+	}
+
+	ds := exams[0]
+	if want := time.Date(2023, 1, 3, 8, 30, 0, 0, chinaTime); !ds.Start.Equal(want) {
+		t.Errorf("got start %v, want %v", ds.Start, want)
+	}
+	if want := time.Date(2023, 1, 3, 10, 30, 0, 0, chinaTime); !ds.End.Equal(want) {
+		t.Errorf("got end %v, want %v", ds.End, want)
+	}
+	if ds.Location != "H3109" || ds.Seat != "27" || ds.Name != "数据结构" {
+		t.Errorf("got %+v", ds)
+	}
+	// Only the date is known.
+	if phys := exams[1]; !phys.Scheduled() || !phys.End.IsZero() {
+		t.Errorf("got %+v", phys)
+	}
+	// Not scheduled at all.
+	if pe := exams[2]; pe.Scheduled() || pe.Note != "论文" {
+		t.Errorf("got %+v", pe)
+	}
+}
+
+func TestParseExamsInvalid(t *testing.T) {
+	cases := map[string]string{
+		"not json":      `<html>`,
+		"bad date":      `[{"course_id": "A", "date": "2023/01/03"}]`,
+		"bad time":      `[{"course_id": "A", "date": "2023-01-03", "time": "08:30"}]`,
+		"reversed time": `[{"course_id": "A", "date": "2023-01-03", "time": "10:30~08:30"}]`,
+	}
+	for name, data := range cases {
+		if _, err := parseExams([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func FuzzParseExams(f *testing.F) {
+	fuzzParse(f, []string{"exams.json", "exams_graduate.json"}, []string{
+		`[{"course_id": "A", "date": "0001-01-01"}]`,
+	}, parseExams, func(t *testing.T, exams []Exam) {
+		for _, exam := range exams {
+			if !exam.Start.IsZero() && exam.Start.Year() < minYear || !exam.End.IsZero() && exam.End.Before(exam.Start) {
+				t.Fatalf("accepted the invalid exam %+v", exam)
+			}
+		}
+	})
+}
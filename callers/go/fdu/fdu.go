@@ -0,0 +1,101 @@
+// Package fdu is the Go binding of libfdu.
+//
+// Call Init before anything else of the package, to load libfdu and check
+// that it matches the package:
+//
+//	if err := fdu.Init(); err != nil {
+//		log.Fatal(err)
+//	}
+//	defer fdu.Shutdown()
+//
+// # Linking with cgo
+//
+// By default, the package links against the libfdu shared library built by
+// `cargo build` in the repository root. It looks for the library in
+// target/debug and then target/release of the repository that contains this
+// package, which is what you get when you build from a checkout.
+//
+// When the package is used from the module cache or vendored into another
+// project, those relative paths do not exist. In that case, point the go tool
+// to your own build of libfdu with the standard cgo environment variables,
+// which take precedence over the defaults:
+//
+//	CGO_LDFLAGS="-L/path/to/libfdu/dir" go build
+//
+// The C header bindings.h is shipped alongside this package, so no include
+// path is needed. If you want to build against another header (e.g. the one
+// freshly generated in the repository root), prepend it with CGO_CFLAGS="-I...".
+// The ErrCode constants are generated from bindings.h: run `go generate` in
+// this directory after the header changes.
+//
+// At runtime the dynamic loader must be able to find the library as well.
+// On Linux, it is also found next to the executable; otherwise set
+// LD_LIBRARY_PATH (Linux) or DYLD_LIBRARY_PATH (macOS), or put the .dll next
+// to the executable (Windows). With cgo, a missing library stops the program
+// before main, so prefer the fdu_purego build tag to report it as an error.
+//
+// # Linking statically
+//
+// With the fdu_static build tag, the package links against the static
+// library built by `cargo build --release` instead, target/release/libfdu.a,
+// so that the executable runs without libfdu next to it, e.g. to deploy a
+// single file to a Raspberry Pi:
+//
+//	cargo build --release
+//	go build -tags fdu_static
+//
+// The tests of the package need the test hooks of libfdu, which release
+// builds only have with the test-exports feature:
+//
+//	cargo build --release --features test-exports
+//	go test -tags fdu_static ./...
+//
+// The static library is only looked for in the repository, and is built for
+// the host: to cross-compile, build libfdu for the target with cargo and
+// point CGO_LDFLAGS to it, e.g. CGO_LDFLAGS="/path/to/libfdu.a".
+//
+// # Loading at runtime
+//
+// With the fdu_purego build tag, the package does not use cgo: it loads
+// libfdu at runtime with purego instead, so programs build with
+// CGO_ENABLED=0 and cross-compile like pure Go ones:
+//
+//	CGO_ENABLED=0 GOOS=windows go build -tags fdu_purego
+//
+// Init then loads the library from the path given to WithLibraryPath or Load,
+// or else from FDU_LIBRARY_PATH, which names the library or the directory
+// holding it, or else from the directory of the executable, the target
+// directories of the repository as above, and finally the default search path
+// of the OS. An explicit path or FDU_LIBRARY_PATH is the only one tried. If
+// nothing loads, the *LoadError lists every path tried with the error of the
+// OS. On Windows, the DLLs libfdu depends on are searched in its own
+// directory and the system directories rather than those of the executable
+// and the working directory. On macOS, a library downloaded with a browser
+// must be cleared of its quarantine attribute first.
+//
+// # Concurrency
+//
+// All functions of this package may be called from multiple goroutines.
+// Calls on the same Session are serialized by the Session, while calls on
+// different sessions run in parallel.
+package fdu
+
+// Hello returns the greeting from libfdu. It is mainly used to check that the
+// library is linked correctly.
+func Hello() (string, error) {
+	if err := checkInit(); err != nil {
+		return "", err
+	}
+	return takeResult(lib.helloWorld())
+}
+
+// GetURL fetches url with a plain GET request and returns the response body.
+func GetURL(url string) (string, error) {
+	if err := checkInit(); err != nil {
+		return "", err
+	}
+	if err := checkCString("url", url); err != nil {
+		return "", err
+	}
+	return takeResult(lib.getURL(url))
+}
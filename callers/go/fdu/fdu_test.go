@@ -0,0 +1,118 @@
+package fdu
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestMain initializes libfdu up front, so that the tests run the same
+// against either backend and a missing library fails them all with a clear
+// error. Tests calling Shutdown must call Init again before returning.
+func TestMain(m *testing.M) {
+	if err := Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// Most tests need the test hooks, so fail once rather than in each of
+	// them.
+	if testLiveSessions() < 0 {
+		fmt.Fprintln(os.Stderr, ErrReleaseBuild, "(cargo build --release --features test-exports)")
+		os.Exit(1)
+	}
+	// With -fdu.trackallocs, the pointers of libfdu are tracked once purego
+	// has bound lib, and before the first test.
+	flag.Parse()
+	if *trackAllocs {
+		h, err := parseBindings()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		tracker = trackPointers(h)
+	}
+	fmt.Printf("libfdu backend: %s\n", backend)
+	code := m.Run()
+	if tracker != nil && code == 0 {
+		if err := checkTrackedPointers(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+func TestHello(t *testing.T) {
+	if s, err := Hello(); err != nil || s != "hello world" {
+		t.Fail()
+	}
+}
+
+// TestMemoryLeak checks that the allocations of libfdu for calls are all
+// freed, which the memory statistics of the runtime package cannot see.
+func TestMemoryLeak(t *testing.T) {
+	iterations := 100_000
+	if testing.Short() {
+		iterations = 1_000
+	}
+	base := allocStats(t)
+	for i := 0; i < iterations; i++ {
+		if _, err := Hello(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testError(int32(ErrCodeNetwork)); !errors.Is(err, ErrNetwork) {
+			t.Fatalf("got %v, want ErrNetwork", err)
+		}
+		if _, err := testEchoBytes([]byte("libfdu")); err != nil {
+			t.Fatal(err)
+		}
+		s, err := testSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Close()
+	}
+	if leaked := leakedSince(t, base); leaked != 0 {
+		t.Errorf("%d allocations leaked in %d iterations", leaked, iterations)
+	}
+}
+
+// TestMemoryLeakDetected checks that TestMemoryLeak would catch a leak.
+func TestMemoryLeakDetected(t *testing.T) {
+	base := allocStats(t)
+	if err := testLeak(10); err != nil {
+		t.Fatal(err)
+	}
+	if leaked := leakedSince(t, base); leaked < 10 {
+		t.Errorf("%d allocations leaked, want at least 10", leaked)
+	}
+}
+
+func allocStats(t *testing.T) AllocationStats {
+	t.Helper()
+	stats, err := AllocStats()
+	if errors.Is(err, ErrNoAllocStats) {
+		t.Skip("build libfdu with cargo build --features alloc-stats to check for leaks")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+// leakedSince returns how many more allocations are live than at base. It
+// waits a little for the background work of libfdu to free its allocations.
+func leakedSince(t *testing.T, base AllocationStats) int64 {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		leaked := allocStats(t).LiveAllocations - base.LiveAllocations
+		if leaked <= 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,604 @@
+// Package fdutest runs fake servers of the university, so that code using
+// package fdu is tested end to end, through libfdu and its parsers, without
+// a student account or access to fudan.edu.cn:
+//
+//	func TestCourses(t *testing.T) {
+//		fdutest.NewServer(t)
+//		s, err := fdu.Login(ctx, fdutest.Username, fdutest.Password)
+//		...
+//		courses, err := s.Courses(ctx, fdutest.SemesterID)
+//		// courses are fdutest.Courses
+//	}
+//
+// The server fakes the login of UIS, the profile, the course table and the
+// scores of jwfw, the balance of ecard, the course selection of xk, and the
+// profile, the course table, the scores and the GPA of yjsxt for the
+// graduate account, with pages mimicking those of the real sites. It points libfdu at itself with fdu.SetBaseURLs, so it needs a
+// debug build of libfdu, initialized by fdu.Init, and tests using it must
+// not run in parallel with tests using the real servers.
+package fdutest
+
+import (
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+)
+
+// The account and the data of the server.
+const (
+	Username = "20300000001"
+	Password = "fdutest-password"
+	// SemesterID is the semester of the course table and of the scores.
+	// The other semesters have neither courses nor scores.
+	SemesterID = "443"
+	// CardBalance is the balance of the campus card, in cents.
+	CardBalance = 12345
+
+	// GraduateUsername is the account of a graduate student, with Password
+	// too, whose academic system is yjsxt.
+	GraduateUsername = "23210240001"
+	// GraduateSemesterID is the semester of the course table and of the
+	// scores of the graduate student.
+	GraduateSemesterID = "20231"
+)
+
+// Profile is the profile of Username, and GraduateProfile the one of
+// GraduateUsername, as returned by Session.Profile.
+var (
+	Profile         = fdu.Profile{Name: "张三", StudentID: Username, Department: "计算机科学技术学院", StudentType: fdu.StudentTypeUndergraduate}
+	GraduateProfile = fdu.Profile{Name: "李四", StudentID: GraduateUsername, Department: "计算机科学技术学院", StudentType: fdu.StudentTypeGraduate}
+)
+
+// Courses are the courses of SemesterID, as returned by Session.Courses.
+var Courses = []fdu.Course{
+	{CourseID: "COMP130004.03", Name: "数据结构", Teacher: "王老师", Location: "H3109", Weekday: 1, StartSlot: 3, EndSlot: 4, Weeks: weeks(1, 16, 1)},
+	{CourseID: "PHYS120013.01", Name: "大学物理", Teacher: "李老师", Location: "H2115", Weekday: 4, StartSlot: 6, EndSlot: 7, Weeks: weeks(1, 15, 2)},
+}
+
+// Semesters are the semesters of jwfw, as returned by Session.Semesters.
+var Semesters = []fdu.Semester{
+	{ID: "441", SchoolYear: "2022-2023", Name: "1"},
+	{ID: "442", SchoolYear: "2022-2023", Name: "2"},
+	{ID: "443", SchoolYear: "2023-2024", Name: "1"},
+}
+
+// Scores are the scores of SemesterID, as returned by Session.Scores.
+var Scores = []fdu.Score{
+	{Semester: "2023-2024 1", CourseID: "COMP130004.03", Name: "数据结构", Credit: 3, Grade: "A-", Point: point(3.7)},
+	{Semester: "2023-2024 1", CourseID: "PHYS120013.01", Name: "大学物理", Credit: 4, Grade: "B+", Point: point(3.3)},
+	{Semester: "2023-2024 1", CourseID: "PEDU110001.12", Name: "体育", Credit: 1, Grade: "P"},
+}
+
+// GraduateCourses are the courses of GraduateSemesterID, as returned by
+// Session.Courses for the graduate student.
+var GraduateCourses = []fdu.Course{
+	{CourseID: "COMP620001.01", Name: "高级算法", Teacher: "张老师", Location: "H3209", Weekday: 2, StartSlot: 6, EndSlot: 8, Weeks: weeks(1, 16, 1)},
+	{CourseID: "ENGL620002.05", Name: "研究生英语", Teacher: "Smith", Location: "", Weekday: 5, StartSlot: 1, EndSlot: 2, Weeks: weeks(1, 15, 2)},
+}
+
+// GraduateSemesters are the semesters of yjsxt.
+var GraduateSemesters = []fdu.Semester{
+	{ID: "20231", SchoolYear: "2023-2024", Name: "1"},
+	{ID: "20232", SchoolYear: "2023-2024", Name: "2"},
+}
+
+// GraduateScores are the scores of GraduateSemesterID, as returned by
+// Session.Scores for the graduate student.
+var GraduateScores = []fdu.Score{
+	{Semester: "2023-2024 1", CourseID: "COMP620001.01", Name: "高级算法", Credit: 3, Grade: "A", Point: point(4)},
+	{Semester: "2023-2024 1", CourseID: "ENGL620002.05", Name: "研究生英语", Credit: 2, Grade: "B+", Point: point(3.3)},
+	{Semester: "2023-2024 1", CourseID: "MARX610001.02", Name: "新时代中国特色社会主义理论与实践", Credit: 2, Grade: "P"},
+}
+
+// GraduateProgress is the progress in the training plan of the graduate
+// student, in the GPAReport of Session.GPA.
+var GraduateProgress = fdu.PlanProgress{RequiredCredits: 32, EarnedCredits: 21}
+
+// SelectableCourses are the courses open to selection, as returned by
+// Session.SelectableCourses with the zero CourseQuery.
+var SelectableCourses = []fdu.SelectableCourse{
+	{LessonID: 698241, CourseID: "ECON130003.01", Name: "国际金融", Department: "经济学院", Teachers: "郑辉", Credits: 3, Campus: "邯郸校区", Capacity: 100, Enrolled: 70},
+	{LessonID: 698246, CourseID: "ECON130004.02", Name: "国际贸易", Department: "经济学院", Teachers: "程大中", Credits: 3, Campus: "邯郸校区", Capacity: 100, Enrolled: 89},
+	{LessonID: 698257, CourseID: "ECON130022.01", Name: "货币经济学", Department: "经济学院", Teachers: "田素华", Credits: 3, Campus: "邯郸校区", Capacity: 85, Enrolled: 85},
+	{LessonID: 698251, CourseID: "ECON130010.01", Name: "当代中国经济", Department: "经济学院", Teachers: "陈钊,王永钦,张晏", Credits: 3, Campus: "邯郸校区", Capacity: 85, Enrolled: 84},
+	{LessonID: 698260, CourseID: "ECON130042.01", Name: "税收学", Department: "经济学院", Teachers: "余显财", Credits: 3, Campus: "邯郸校区", Capacity: 40, Enrolled: 39},
+	{LessonID: 698275, CourseID: "ECON130128.01", Name: "制度经济学", Department: "经济学院", Teachers: "方钦", Credits: 2, Campus: "邯郸校区", Capacity: 32, Enrolled: 32},
+	{LessonID: 698280, CourseID: "MATH120044.02", Name: "数学分析BII", Department: "数学科学学院", Teachers: "严金海", Credits: 5, Campus: "邯郸校区", Capacity: 150, Enrolled: 120},
+}
+
+// Outcomes are the outcomes of Session.Enroll for SelectableCourses, by
+// lesson. Only the lessons enrolled in can be dropped, with OutcomeDropped.
+var Outcomes = map[int64]fdu.SelectionOutcome{
+	698241: fdu.OutcomeEnrolled,
+	698246: fdu.OutcomeWaitlisted,
+	698257: fdu.OutcomeFull,
+	// Full with Selection.RetryLater, as the quota is refreshing.
+	698251: fdu.OutcomeFull,
+	698260: fdu.OutcomeTimeConflict,
+	698275: fdu.OutcomeFull,
+	698280: fdu.OutcomeCreditLimit,
+}
+
+// xkPages are the answers of xk to an enrollment, by lesson.
+var xkPages = map[int64]string{
+	698241: "xk_enrolled.html",
+	698246: "xk_waitlisted.html",
+	698257: "xk_full.html",
+	698251: "xk_full_refreshing.html",
+	698260: "xk_time_conflict.html",
+	698275: "xk_full.html",
+	698280: "xk_credit_limit.html",
+}
+
+// xkProfile is the profile of the course selection.
+const xkProfile = "2071"
+
+// hosts are the sites served by the server. Their paths do not overlap, so
+// that they share a handler, but each has its own listener, so that libfdu
+// tells them apart, e.g. for their rate limits.
+var hosts = []string{"uis.fudan.edu.cn", "jwfw.fudan.edu.cn", "ecard.fudan.edu.cn", "xk.fudan.edu.cn", "yjsxt.fudan.edu.cn"}
+
+// ticketCookie is the cookie of a logged in session, set by UIS for all
+// sites: they share the host of the server, cookies ignoring ports. xk sets its own cookie when
+// logged in by UIS.
+const (
+	ticketCookie = "CASTGC"
+	xkCookie     = "JSESSIONID"
+)
+
+// The hidden fields of the login form, which a login must send back.
+const (
+	loginTicket = "LT-fdutest"
+	execution   = "e1s1"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+var loginPage = template.Must(template.ParseFS(fixtures, "fixtures/login.html"))
+
+var (
+	scoresPage = template.Must(template.New("scores.html").Funcs(template.FuncMap{
+		"code": func(courseID string) string {
+			code, _, _ := strings.Cut(courseID, ".")
+			return code
+		},
+	}).ParseFS(fixtures, "fixtures/scores.html"))
+	cardPage = template.Must(template.New("card.html").Funcs(template.FuncMap{
+		"yuan": func(cents int64) string {
+			return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+		},
+	}).ParseFS(fixtures, "fixtures/card.html"))
+)
+
+// Server is a fake UIS, jwfw, ecard, xk and yjsxt. The embedded server is the one
+// of UIS, but serves the other sites too.
+type Server struct {
+	*httptest.Server
+	// sites are the servers of the other hosts.
+	sites map[string]*httptest.Server
+
+	mu      sync.Mutex
+	tickets map[string]bool
+	logins  int
+	// xkSessions are the sessions logged in xk, and enrolled the lessons
+	// enrolled in.
+	xkSessions map[string]bool
+	enrolled   map[int64]bool
+	// failures are the numbers of requests left to fail, by path.
+	failures map[string]failure
+	// requests are the times of the requests, by path.
+	requests map[string][]time.Time
+	// scores and balances are served in turn, the last one for good.
+	scores   [][]fdu.Score
+	balances []int64
+}
+
+type failure struct {
+	n      int
+	status int
+}
+
+// NewServer starts a server and points libfdu at it until the end of the
+// test, when the server is closed and the real servers are restored. It
+// skips the test with a release build of libfdu.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := newServer()
+	t.Cleanup(s.Close)
+
+	urls := map[string]string{hosts[0]: s.URL}
+	for host, site := range s.sites {
+		urls[host] = site.URL
+	}
+	if err := fdu.SetBaseURLs(urls); errors.Is(err, fdu.ErrReleaseBuild) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := fdu.SetBaseURLs(nil); err != nil {
+			t.Error(err)
+		}
+	})
+	return s
+}
+
+func newServer() *Server {
+	s := &Server{
+		tickets:    make(map[string]bool),
+		failures:   make(map[string]failure),
+		xkSessions: make(map[string]bool),
+		enrolled:   make(map[int64]bool),
+		requests:   make(map[string][]time.Time),
+		sites:      make(map[string]*httptest.Server),
+		scores:     [][]fdu.Score{Scores},
+		balances:   []int64{CardBalance},
+	}
+	handler := s.handler()
+	s.Server = httptest.NewServer(handler)
+	for _, host := range hosts[1:] {
+		s.sites[host] = httptest.NewServer(handler)
+	}
+	return s
+}
+
+// Close closes the servers of all the sites.
+func (s *Server) Close() {
+	s.Server.Close()
+	for _, site := range s.sites {
+		site.Close()
+	}
+}
+
+// Requests returns the times of the requests to path so far, in order, e.g.
+// to check the rate limits of libfdu.
+func (s *Server) Requests(path string) []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests[path])
+}
+
+// Logins returns the number of successful logins so far.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// Enrolled returns the lessons enrolled in so far, in no particular order.
+func (s *Server) Enrolled() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lessons := make([]int64, 0, len(s.enrolled))
+	for lesson := range s.enrolled {
+		lessons = append(lessons, lesson)
+	}
+	return lessons
+}
+
+// Expire logs out every session, as when UIS expires them, so that the
+// sites redirect them to the login page.
+func (s *Server) Expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.tickets)
+}
+
+// ExpireXk logs out every session of xk, as when its token expires before
+// the one of UIS, so that xk logs them in again and redirects them to its
+// home page.
+func (s *Server) ExpireXk() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.xkSessions)
+}
+
+// Fail makes the next n requests to path fail with the HTTP status, e.g.
+// http.StatusBadGateway as when a site is overloaded, before they reach the
+// page.
+func (s *Server) Fail(path string, n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = failure{n: n, status: status}
+}
+
+// ServeScores makes the next pages of scores of SemesterID show pages, one
+// page each, and the last one after them, instead of Scores, e.g. to publish
+// a score between two polls. Only the pages served count, not the requests
+// failed by Fail. The points of the scores are computed by libfdu from their
+// grades.
+func (s *Server) ServeScores(pages ...[]fdu.Score) {
+	if len(pages) == 0 {
+		panic("fdutest: ServeScores without scores")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scores = pages
+}
+
+// ServeCardBalances is ServeScores for the balance of the card, in cents,
+// instead of CardBalance.
+func (s *Server) ServeCardBalances(balances ...int64) {
+	if len(balances) == 0 {
+		panic("fdutest: ServeCardBalances without balances")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances = balances
+}
+
+// failing records a request to path, and reports whether it fails, and with
+// which status.
+func (s *Server) failing(path string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[path] = append(s.requests[path], time.Now())
+	f := s.failures[path]
+	if f.n <= 0 {
+		return 0, false
+	}
+	f.n--
+	s.failures[path] = f
+	return f.status, true
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /authserver/login", func(w http.ResponseWriter, r *http.Request) {
+		showLogin(w, "")
+	})
+	mux.HandleFunc("POST /authserver/login", s.login)
+	mux.HandleFunc("GET /authserver/needCaptcha.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("false"))
+	})
+	mux.Handle("GET /authserver/index.do", s.loggedIn(page("index.html")))
+	mux.HandleFunc("GET /authserver/logout", s.logout)
+
+	mux.Handle("GET /eams/courseTableForStd.action", s.loggedIn(page("course_table.html")))
+	mux.Handle("POST /eams/dataQuery.action", s.loggedIn(page("semesters.js")))
+	mux.Handle("POST /eams/courseTableForStd!courseTable.action", s.loggedIn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("ids") != "1234567" {
+			http.Error(w, "ids mismatch", http.StatusBadRequest)
+			return
+		}
+		if r.PostFormValue("semester.id") != SemesterID {
+			// An empty course table.
+			return
+		}
+		page("course_table_query.html").ServeHTTP(w, r)
+	})))
+	mux.Handle("GET /eams/teach/grade/course/person!search.action", s.loggedIn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("semesterId") != SemesterID {
+			w.Write([]byte("<table><tbody></tbody></table>"))
+			return
+		}
+		s.mu.Lock()
+		scores := next(&s.scores)
+		s.mu.Unlock()
+		render(w, scoresPage, scores)
+	})))
+
+	mux.Handle("GET /eams/stdDetail.action", s.loggedIn(page("jwfw_profile.html")))
+
+	mux.Handle("GET /epay/myepay/index", s.loggedIn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		balance := next(&s.balances)
+		s.mu.Unlock()
+		render(w, cardPage, balance)
+	})))
+
+	mux.Handle("GET /xk/login.action", s.loggedIn(http.HandlerFunc(s.xkLogin)))
+	mux.Handle("GET /xk/home.action", s.inXk(page("xk_home.html")))
+	mux.Handle("GET /xk/stdElectCourse!defaultPage.action", s.inXk(page("xk_elect.html")))
+	mux.Handle("POST /xk/stdElectCourse!defaultPage.action", s.inXk(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("electionProfile.id") != xkProfile {
+			http.Error(w, "profile mismatch", http.StatusBadRequest)
+			return
+		}
+		page("xk_elect.html").ServeHTTP(w, r)
+	})))
+	mux.Handle("POST /xk/stdElectCourse!queryLesson.action", s.inXk(s.inProfile(page("xk_lessons.js"))))
+	mux.Handle("POST /xk/stdElectCourse!batchOperator.action", s.inXk(s.inProfile(http.HandlerFunc(s.operate))))
+
+	mux.Handle("GET /gsapp/sys/yjsemaphome/portal/index.do", s.loggedIn(page("index.html")))
+	mux.Handle("POST /gsapp/sys/yjsemaphome/modules/pubWork/getUserInfo.do", s.loggedIn(page("yjsxt_profile.json")))
+	mux.Handle("POST /gsapp/sys/wdkbapp/modules/xskcb/xnxqcx.do", s.loggedIn(page("yjsxt_semesters.json")))
+	mux.Handle("POST /gsapp/sys/wdkbapp/modules/xskcb/xspkjgcx.do", s.loggedIn(inSemester(page("yjsxt_courses.json"))))
+	mux.Handle("POST /gsapp/sys/wdksapp/modules/wdks/wdkscx.do", s.loggedIn(inSemester(page("yjsxt_exams.json"))))
+	mux.Handle("POST /gsapp/sys/wdcjapp/modules/wdcj/xscjcx.do", s.loggedIn(inSemester(page("yjsxt_scores.json"))))
+	mux.Handle("POST /gsapp/sys/pyjhapp/modules/pyjhwcqk/xspyjhwcqk.do", s.loggedIn(page("yjsxt_progress.json")))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status, ok := s.failing(r.URL.Path); ok {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("lt") != loginTicket || r.PostFormValue("execution") != execution {
+		http.Error(w, "invalid login form", http.StatusBadRequest)
+		return
+	}
+	if username := r.PostFormValue("username"); username != Username && username != GraduateUsername || r.PostFormValue("password") != Password {
+		showLogin(w, "您提供的用户名或者密码有误")
+		return
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	ticket := "TGT-" + hex.EncodeToString(b[:])
+	s.mu.Lock()
+	s.tickets[ticket] = true
+	s.logins++
+	s.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: ticketCookie, Value: ticket, Path: "/", HttpOnly: true})
+	http.Redirect(w, r, "/authserver/index.do", http.StatusFound)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(ticketCookie); err == nil {
+		s.mu.Lock()
+		delete(s.tickets, c.Value)
+		s.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: ticketCookie, Path: "/", MaxAge: -1})
+	showLogin(w, "")
+}
+
+// xkLogin logs a session of UIS in xk, as xk does when UIS sends it back
+// with a ticket.
+func (s *Server) xkLogin(w http.ResponseWriter, r *http.Request) {
+	var b [16]byte
+	rand.Read(b[:])
+	session := hex.EncodeToString(b[:])
+	s.mu.Lock()
+	s.xkSessions[session] = true
+	s.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: xkCookie, Value: session, Path: "/xk", HttpOnly: true})
+	http.Redirect(w, r, "/xk/home.action", http.StatusFound)
+}
+
+// operate enrolls in a lesson or drops it, answering with the fixture of its
+// outcome.
+func (s *Server) operate(w http.ResponseWriter, r *http.Request) {
+	var lesson int64
+	var enroll bool
+	if _, err := fmt.Sscanf(r.PostFormValue("operator0"), "%d:%t", &lesson, &enroll); err != nil {
+		http.Error(w, "invalid operator", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := "xk_not_enrolled.html"
+	switch {
+	case enroll && xkPages[lesson] == "":
+		http.Error(w, "no such lesson", http.StatusBadRequest)
+		return
+	case enroll:
+		name = xkPages[lesson]
+		if Outcomes[lesson] == fdu.OutcomeEnrolled {
+			s.enrolled[lesson] = true
+		}
+	case s.enrolled[lesson]:
+		name = "xk_dropped.html"
+		delete(s.enrolled, lesson)
+	}
+	page(name).ServeHTTP(w, r)
+}
+
+// loggedIn serves the page to logged in sessions, and redirects the others
+// to the login page.
+func (s *Server) loggedIn(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(ticketCookie)
+		s.mu.Lock()
+		ok := err == nil && s.tickets[c.Value]
+		s.mu.Unlock()
+		if !ok {
+			http.Redirect(w, r, "/authserver/login?service="+url.QueryEscape(r.URL.Path), http.StatusFound)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// inXk serves the page to the sessions logged in xk, and redirects the
+// others to the login page of xk.
+func (s *Server) inXk(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(xkCookie)
+		s.mu.Lock()
+		ok := err == nil && s.xkSessions[c.Value]
+		s.mu.Unlock()
+		if !ok {
+			http.Redirect(w, r, "/xk/login.action", http.StatusFound)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// inProfile serves the queries of the profile of the course selection.
+func (s *Server) inProfile(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("profileId") != xkProfile {
+			http.Error(w, "profile mismatch", http.StatusBadRequest)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// inSemester serves the queries of yjsxt for GraduateSemesterID, and no rows
+// for the other semesters.
+func inSemester(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("XNXQDM") != GraduateSemesterID {
+			w.Write([]byte(`{"code":"0","datas":{"query":{"totalSize":0,"rows":[]}}}`))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func showLogin(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	loginPage.Execute(w, struct{ Message string }{message})
+}
+
+// page serves a fixture.
+func page(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := fixtures.ReadFile("fixtures/" + name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(data)
+	})
+}
+
+// render serves a fixture executed with data.
+func render(w http.ResponseWriter, t *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// next returns the first of values, and drops it unless it is the last.
+func next[T any](values *[]T) T {
+	v := (*values)[0]
+	if len(*values) > 1 {
+		*values = (*values)[1:]
+	}
+	return v
+}
+
+func weeks(from, to, step int) []int {
+	var weeks []int
+	for week := from; week <= to; week += step {
+		weeks = append(weeks, week)
+	}
+	return weeks
+}
+
+func point(p float64) *float64 {
+	return &p
+}
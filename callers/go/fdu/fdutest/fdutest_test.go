@@ -0,0 +1,457 @@
+package fdutest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+)
+
+func TestMain(m *testing.M) {
+	if err := fdu.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// TestServer drives the server like libfdu does, without libfdu.
+func TestServer(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	get := func(path string) (string, string) {
+		t.Helper()
+		res, err := client.Get(s.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.Request.URL.Path, string(body)
+	}
+	login := func(password string) (string, string) {
+		t.Helper()
+		res, err := client.PostForm(s.URL+"/authserver/login", url.Values{
+			"username": {Username}, "password": {password}, "lt": {loginTicket}, "execution": {execution},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.Request.URL.Path, string(body)
+	}
+
+	if path, body := get("/authserver/login"); path != "/authserver/login" || !strings.Contains(body, `name="lt" value="LT-fdutest"`) {
+		t.Errorf("login page at %s:\n%s", path, body)
+	}
+	if path, body := login("wrong"); path != "/authserver/login" || !strings.Contains(body, "密码有误") {
+		t.Errorf("wrong password at %s:\n%s", path, body)
+	}
+	if path, _ := get("/eams/courseTableForStd.action"); path != "/authserver/login" {
+		t.Errorf("not logged in, got %s", path)
+	}
+	if path, _ := login(Password); path != "/authserver/index.do" || s.Logins() != 1 {
+		t.Errorf("login at %s, %d logins", path, s.Logins())
+	}
+	if _, body := get("/eams/courseTableForStd.action"); !strings.Contains(body, `"ids","1234567"`) {
+		t.Errorf("course table page:\n%s", body)
+	}
+	if _, body := get("/epay/myepay/index"); !strings.Contains(body, "<p>123.45</p>") {
+		t.Errorf("card page:\n%s", body)
+	}
+	query := func(path, semester string) string {
+		t.Helper()
+		res, err := client.PostForm(s.URL+path, url.Values{"XNXQDM": {semester}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return string(body)
+	}
+	if body := query("/gsapp/sys/wdkbapp/modules/xskcb/xspkjgcx.do", GraduateSemesterID); !strings.Contains(body, "COMP620001.01") {
+		t.Errorf("yjsxt course table:\n%s", body)
+	}
+	if body := query("/gsapp/sys/wdkbapp/modules/xskcb/xspkjgcx.do", "20232"); !strings.Contains(body, `"rows":[]`) {
+		t.Errorf("yjsxt course table of another semester:\n%s", body)
+	}
+	s.Fail("/epay/myepay/index", 1, http.StatusBadGateway)
+	if _, body := get("/epay/myepay/index"); !strings.Contains(body, "Bad Gateway") {
+		t.Errorf("failing card page:\n%s", body)
+	}
+	if _, body := get("/epay/myepay/index"); !strings.Contains(body, "<p>123.45</p>") {
+		t.Errorf("card page after the failure:\n%s", body)
+	}
+	s.ServeCardBalances(80_00, 5)
+	for _, want := range []string{"80.00", "0.05", "0.05"} {
+		if _, body := get("/epay/myepay/index"); !strings.Contains(body, "<p>"+want+"</p>") {
+			t.Errorf("card page, want %s:\n%s", want, body)
+		}
+	}
+	if _, body := get("/eams/teach/grade/course/person!search.action?semesterId=" + SemesterID); !strings.Contains(body, "<td>COMP130004</td><td>COMP130004.03</td><td>数据结构</td><td></td><td>3</td><td>A-</td><td>3.7</td>") {
+		t.Errorf("scores page:\n%s", body)
+	}
+	s.ServeScores(Scores[:1], nil)
+	for _, want := range []int{1, 0, 0} {
+		if _, body := get("/eams/teach/grade/course/person!search.action?semesterId=" + SemesterID); strings.Count(body, "<tr><td>") != want {
+			t.Errorf("scores page, want %d scores:\n%s", want, body)
+		}
+	}
+	if path, _ := get("/xk/login.action"); path != "/xk/home.action" {
+		t.Errorf("xk login at %s", path)
+	}
+	if _, body := get("/xk/stdElectCourse!defaultPage.action"); !strings.Contains(body, `name="electionProfile.id" value="2071"`) {
+		t.Errorf("xk elect page:\n%s", body)
+	}
+	operate := func(operator string) string {
+		t.Helper()
+		res, err := client.PostForm(s.URL+"/xk/stdElectCourse!batchOperator.action?profileId=2071", url.Values{"operator0": {operator}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return string(body)
+	}
+	if body := operate("698241:true:0"); !strings.Contains(body, "选课成功") || len(s.Enrolled()) != 1 {
+		t.Errorf("enroll, enrolled in %v:\n%s", s.Enrolled(), body)
+	}
+	if body := operate("698241:false"); !strings.Contains(body, "退课成功") || len(s.Enrolled()) != 0 {
+		t.Errorf("drop, enrolled in %v:\n%s", s.Enrolled(), body)
+	}
+	s.ExpireXk()
+	if path, _ := get("/xk/stdElectCourse!defaultPage.action"); path != "/xk/home.action" {
+		t.Errorf("expired xk session at %s", path)
+	}
+
+	s.Expire()
+	if path, _ := get("/authserver/index.do"); path != "/authserver/login" {
+		t.Errorf("expired session at %s", path)
+	}
+}
+
+// TestEndToEnd goes through libfdu, which needs a debug build.
+func TestEndToEnd(t *testing.T) {
+	srv := NewServer(t)
+	ctx := context.Background()
+
+	if _, err := fdu.Login(ctx, Username, "wrong"); !errors.Is(err, fdu.ErrAuthFailed) {
+		t.Errorf("wrong password: %v", err)
+	}
+	s, err := fdu.Login(ctx, Username, Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if profile, err := s.Profile(ctx); err != nil || *profile != Profile {
+		t.Errorf("profile: %v, %+v", err, profile)
+	}
+	semesters, err := s.Semesters(ctx)
+	if err != nil || !reflect.DeepEqual(semesters, Semesters) {
+		t.Errorf("semesters: %v, %+v", err, semesters)
+	}
+	courses, err := s.Courses(ctx, SemesterID)
+	if err != nil || !reflect.DeepEqual(courses, Courses) {
+		t.Errorf("courses: %v, %+v", err, courses)
+	}
+	if courses, err := s.Courses(ctx, "441"); err != nil || len(courses) != 0 {
+		t.Errorf("courses of 441: %v, %+v", err, courses)
+	}
+	scores, err := s.Scores(ctx, SemesterID)
+	if err != nil || !reflect.DeepEqual(scores, Scores) {
+		t.Errorf("scores: %v, %+v", err, scores)
+	}
+	if balance, err := s.CardBalance(ctx); err != nil || balance != CardBalance {
+		t.Errorf("card balance: %v, %d", err, balance)
+	}
+
+	if ok, err := s.Valid(ctx); err != nil || !ok {
+		t.Errorf("valid before expiry: %v, %v", ok, err)
+	}
+	srv.Expire()
+	if ok, err := s.Valid(ctx); err != nil || ok {
+		t.Errorf("valid after expiry: %v, %v", ok, err)
+	}
+	if srv.Logins() != 1 {
+		t.Errorf("%d logins, want 1", srv.Logins())
+	}
+}
+
+// TestRecordReplay records a login, the profile and the course table,
+// checks that the recording gives away neither the credentials nor the
+// name, and replays it without the server.
+func TestRecordReplay(t *testing.T) {
+	srv := NewServer(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := fdu.EnableRecording(dir, fdu.RedactStandard); err != nil {
+		t.Fatal(err)
+	}
+	s, err := fdu.Login(ctx, Username, Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile, err := s.Profile(ctx); err != nil || *profile != Profile {
+		t.Errorf("profile: %v, %+v", err, profile)
+	}
+	if courses, err := s.Courses(ctx, SemesterID); err != nil || !reflect.DeepEqual(courses, Courses) {
+		t.Errorf("courses: %v, %+v", err, courses)
+	}
+	s.Close()
+	if err := fdu.DisableRecording(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no recording: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{Password, url.QueryEscape(Password), Username, Profile.Name, "TGT-"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("%s has %q:\n%s", file.Name(), secret, data)
+			}
+		}
+	}
+
+	srv.Close()
+	stop, err := fdu.Replay(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+	s, err = fdu.Login(ctx, Username, Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if courses, err := s.Courses(ctx, SemesterID); err != nil || !reflect.DeepEqual(courses, Courses) {
+		t.Errorf("replayed courses: %v, %+v", err, courses)
+	}
+}
+
+// TestGraduate goes through yjsxt, for the student type told by the student
+// ID or set on the session.
+func TestGraduate(t *testing.T) {
+	NewServer(t)
+	ctx := context.Background()
+	s, err := fdu.Login(ctx, GraduateUsername, Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if profile, err := s.Profile(ctx); err != nil || *profile != GraduateProfile {
+		t.Errorf("profile: %v, %+v", err, profile)
+	}
+	semesters, err := s.Semesters(ctx)
+	if err != nil || !reflect.DeepEqual(semesters, GraduateSemesters) {
+		t.Errorf("semesters: %v, %+v", err, semesters)
+	}
+	courses, err := s.Courses(ctx, GraduateSemesterID)
+	if err != nil || !reflect.DeepEqual(courses, GraduateCourses) {
+		t.Errorf("courses: %v, %+v", err, courses)
+	}
+	if courses, err := s.Courses(ctx, "20232"); err != nil || len(courses) != 0 {
+		t.Errorf("courses of 20232: %v, %+v", err, courses)
+	}
+	scores, err := s.Scores(ctx, GraduateSemesterID)
+	if err != nil || !reflect.DeepEqual(scores, GraduateScores) {
+		t.Errorf("scores: %v, %+v", err, scores)
+	}
+	report, err := s.GPA(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Ranking != nil || report.Progress == nil || *report.Progress != GraduateProgress {
+		t.Errorf("gpa: %+v, want progress %+v without ranking", report, GraduateProgress)
+	}
+
+	// The student type of the ID is overridden.
+	if err := s.SetStudentType(fdu.StudentTypeUndergraduate); err != nil {
+		t.Fatal(err)
+	}
+	if profile, err := s.Profile(ctx); err != nil || profile.StudentType != fdu.StudentTypeUndergraduate {
+		t.Errorf("profile as an undergraduate: %v, %+v", err, profile)
+	}
+}
+
+// TestRetry makes the card page fail twice with 502 Bad Gateway, which
+// libfdu retries after the delays of the policy.
+func TestRetry(t *testing.T) {
+	srv := NewServer(t)
+	ctx := context.Background()
+	s, err := fdu.Login(ctx, Username, Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	policy := fdu.RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	srv.Fail("/epay/myepay/index", 2, http.StatusBadGateway)
+	start := time.Now()
+	balance, err := s.CardBalance(ctx, fdu.RetryWith(policy))
+	if err != nil || balance != CardBalance {
+		t.Fatalf("card balance: %v, %d", err, balance)
+	}
+	// The delays are drawn from [50ms, 100ms] and [100ms, 200ms].
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("retried after %v, want at least 150ms", elapsed)
+	}
+
+	// Not retried by the policy of the session.
+	srv.Fail("/epay/myepay/index", 1, http.StatusBadGateway)
+	if _, err := s.CardBalance(ctx); !errors.Is(err, fdu.ErrNetwork) {
+		t.Errorf("without a policy: got %v, want ErrNetwork", err)
+	}
+	srv.Fail("/epay/myepay/index", 3, http.StatusBadGateway)
+	_, err = s.CardBalance(ctx, fdu.RetryWith(policy))
+	if !errors.Is(err, fdu.ErrNetwork) || !strings.Contains(err.Error(), "failed after 3 attempts") {
+		t.Errorf("out of attempts: got %v", err)
+	}
+}
+
+// TestCourseSelection enrolls in a course of each outcome, and drops one,
+// through xk, logged in lazily and again once its token expires.
+func TestCourseSelection(t *testing.T) {
+	srv := NewServer(t)
+	ctx := context.Background()
+	s, err := fdu.Login(ctx, Username, Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	courses, err := s.SelectableCourses(ctx, fdu.CourseQuery{})
+	if err != nil || !reflect.DeepEqual(courses, SelectableCourses) {
+		t.Errorf("selectable courses: %v, %+v", err, courses)
+	}
+	courses, err = s.SelectableCourses(ctx, fdu.CourseQuery{Department: "经济学院", MaxCredits: 2})
+	if err != nil || len(courses) != 1 || courses[0].LessonID != 698275 {
+		t.Errorf("selectable courses of 经济学院 up to 2 credits: %v, %+v", err, courses)
+	}
+
+	if _, err := s.Enroll(ctx, 698241); !errors.Is(err, fdu.ErrWritesNotAllowed) {
+		t.Errorf("enroll without AllowWrites: %v", err)
+	}
+	if err := fdu.Init(fdu.AllowWrites()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		fdu.Shutdown()
+		if err := fdu.Init(); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, course := range SelectableCourses {
+		selection, err := s.Enroll(ctx, course.LessonID)
+		if err != nil || selection.Outcome != Outcomes[course.LessonID] {
+			t.Errorf("enroll in %s: got (%+v, %v), want %s", course.Name, selection, err, Outcomes[course.LessonID])
+			continue
+		}
+		if retry := course.LessonID == 698251; selection.RetryLater != retry || !strings.Contains(selection.Message, course.CourseID) {
+			t.Errorf("enroll in %s: got %+v", course.Name, selection)
+		}
+	}
+	if enrolled := srv.Enrolled(); !reflect.DeepEqual(enrolled, []int64{698241}) {
+		t.Errorf("enrolled in %v", enrolled)
+	}
+
+	srv.ExpireXk()
+	if selection, err := s.Drop(ctx, 698241); err != nil || selection.Outcome != fdu.OutcomeDropped {
+		t.Errorf("drop after xk expired: %+v, %v", selection, err)
+	}
+	if _, err := s.Drop(ctx, 698241); !errors.Is(err, fdu.ErrUnknown) || !strings.Contains(err.Error(), "未选该课程") {
+		t.Errorf("drop again: %v", err)
+	}
+	if len(srv.Enrolled()) != 0 || srv.Logins() != 1 {
+		t.Errorf("enrolled in %v after %d logins", srv.Enrolled(), srv.Logins())
+	}
+}
+
+// TestRateLimit sends rapid calls to a rate limited host, which libfdu
+// spaces out, unless a call would miss its deadline.
+func TestRateLimit(t *testing.T) {
+	srv := NewServer(t)
+	const rate = 10
+	if err := fdu.SetConfig(fdu.Config{RateLimits: map[fdu.Host]fdu.Rate{fdu.HostEcard: {PerSecond: rate, Burst: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := fdu.SetConfig(fdu.Config{}); err != nil {
+			t.Error(err)
+		}
+	})
+	ctx := context.Background()
+	s, err := fdu.Login(ctx, Username, Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	const calls = 20
+	for range calls {
+		if _, err := s.CardBalance(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	times := srv.Requests("/epay/myepay/index")
+	if len(times) != calls {
+		t.Fatalf("got %d requests, want %d", len(times), calls)
+	}
+	for i := 1; i < calls; i++ {
+		// A little early, for the clocks of the server and of libfdu.
+		if gap := times[i].Sub(times[i-1]); gap < time.Second/rate-10*time.Millisecond {
+			t.Errorf("request %d sent %v after the previous one, want %v", i, gap, time.Second/rate)
+		}
+	}
+	stats, err := s.RateLimitStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.ThrottledRequests != calls-1 || stats.ThrottledTime < (calls-1)*time.Second/rate/2 {
+		t.Errorf("got %d requests throttled for %v, want %d", stats.ThrottledRequests, stats.ThrottledTime, calls-1)
+	}
+	// The other hosts keep the defaults.
+	if got := stats.Hosts[fdu.HostUIS]; got.PerSecond != 0.2 || got.Burst != 5 {
+		t.Errorf("got %+v for UIS, want the defaults", got)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = s.CardBalance(ctx)
+	var limited *fdu.RateLimitedError
+	if !errors.As(err, &limited) || limited.Host != fdu.HostEcard {
+		t.Fatalf("with a short deadline: got %v, want a *RateLimitedError for %s", err, fdu.HostEcard)
+	}
+	if limited.RetryAt.Before(start.Add(20*time.Millisecond)) || limited.RetryAt.After(start.Add(time.Second/rate+50*time.Millisecond)) {
+		t.Errorf("got RetryAt %v after the call, want the next token", limited.RetryAt.Sub(start))
+	}
+	if n := len(srv.Requests("/epay/myepay/index")); n != calls {
+		t.Errorf("got %d requests, want a rate limited call not to send one", n)
+	}
+}
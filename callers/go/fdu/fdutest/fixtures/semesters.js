@@ -0,0 +1 @@
+{yearDom:"<tr><td class='calendar-bar-td-blankBorder' index='0'>2022-2023</td><td class='calendar-bar-td-blankBorder' index='1'>2023-2024</td></tr>",termDom:"<tr><td class='calendar-bar-td-blankBorder'>1</td></tr>",semesters:{y0:[{id:441,schoolYear:"2022-2023",name:"1"},{id:442,schoolYear:"2022-2023",name:"2"}],y1:[{id:443,schoolYear:"2023-2024",name:"1"}]},yearIndex:"1",termIndex:"0",semesterId:"443"}
@@ -0,0 +1,264 @@
+// Package ical exports course tables and exams as iCalendar (RFC 5545)
+// files, to import into calendar apps:
+//
+//	cal, err := s.AcademicCalendar(ctx)
+//	...
+//	sem, _ := cal.Semester(semesterID)
+//	data, err := ical.Build(courses, exams, sem)
+//
+// A course becomes a weekly event, every other week for courses of odd or
+// even weeks (单双周), with the holidays excluded and the makeup days (调休)
+// added. Courses on irregular weeks are listed by date instead. Times are in
+// Asia/Shanghai, with the slots (节) of the Handan campus.
+package ical
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+)
+
+// TZID is the time zone of all events.
+const TZID = "Asia/Shanghai"
+
+// maxExdates is how many weeks a weekly course may skip and still be a
+// recurring event, e.g. a week off for midterms. Courses skipping more are
+// listed by date.
+const maxExdates = 2
+
+// now returns the time of DTSTAMP. It is a variable for the tests.
+var now = time.Now
+
+// shanghai is the fixed offset of Asia/Shanghai, which has had no DST since
+// 1991.
+var shanghai = time.FixedZone("CST", 8*60*60)
+
+// slotTimes are the start and end of the slots, in minutes from midnight.
+var slotTimes = [fdu.MaxSlot][2]int{
+	{8 * 60, 8*60 + 45},
+	{8*60 + 55, 9*60 + 40},
+	{9*60 + 55, 10*60 + 40},
+	{10*60 + 50, 11*60 + 35},
+	{11*60 + 45, 12*60 + 30},
+	{13*60 + 30, 14*60 + 15},
+	{14*60 + 25, 15*60 + 10},
+	{15*60 + 25, 16*60 + 10},
+	{16*60 + 20, 17*60 + 5},
+	{17*60 + 15, 18 * 60},
+	{18*60 + 30, 19*60 + 15},
+	{19*60 + 25, 20*60 + 10},
+	{20*60 + 20, 21*60 + 5},
+	{21*60 + 15, 22 * 60},
+}
+
+// FromCourses returns the calendar of the courses, whose weeks are those of
+// the only semester of cal, see fdu.AcademicCalendar.Semester.
+func FromCourses(courses []fdu.Course, cal fdu.AcademicCalendar) ([]byte, error) {
+	return Build(courses, nil, cal)
+}
+
+// FromExams returns the calendar of the exams. Exams not scheduled yet are
+// left out, and those whose time is not known are all-day events.
+func FromExams(exams []fdu.Exam) ([]byte, error) {
+	return Build(nil, exams, fdu.AcademicCalendar{})
+}
+
+// Build returns the calendar of both the courses, as in FromCourses, and
+// the exams, as in FromExams. cal is only needed for courses.
+func Build(courses []fdu.Course, exams []fdu.Exam, cal fdu.AcademicCalendar) ([]byte, error) {
+	var w writer
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//DanXi-Dev//libfdu//ZH")
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("X-WR-TIMEZONE", TZID)
+	w.line("BEGIN", "VTIMEZONE")
+	w.line("TZID", TZID)
+	w.line("BEGIN", "STANDARD")
+	w.line("DTSTART", "19700101T000000")
+	w.line("TZOFFSETFROM", "+0800")
+	w.line("TZOFFSETTO", "+0800")
+	w.line("TZNAME", "CST")
+	w.line("END", "STANDARD")
+	w.line("END", "VTIMEZONE")
+
+	stamp := now().UTC().Format(utcLayout)
+	if len(courses) > 0 {
+		if len(cal.Semesters) != 1 {
+			return nil, fmt.Errorf("ical: the calendar has %d semesters, want the one of the courses", len(cal.Semesters))
+		}
+		for _, c := range courses {
+			if err := writeCourse(&w, c, cal, stamp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, e := range exams {
+		writeExam(&w, e, stamp)
+	}
+	w.line("END", "VCALENDAR")
+	return []byte(w.String()), nil
+}
+
+func writeCourse(w *writer, c fdu.Course, cal fdu.AcademicCalendar, stamp string) error {
+	if !c.Weekday.Valid() || !c.StartSlot.Valid() || !c.EndSlot.Valid() || c.StartSlot > c.EndSlot {
+		return fmt.Errorf("ical: course %s: invalid weekday %d or slots %d-%d", c.CourseID, c.Weekday, c.StartSlot, c.EndSlot)
+	}
+	sem := cal.Semesters[0]
+	weeks := slices.Clone(c.Weeks)
+	slices.Sort(weeks)
+	weeks = slices.Compact(weeks)
+	for len(weeks) > 0 && weeks[0] < 1 {
+		weeks = weeks[1:]
+	}
+	if len(weeks) == 0 {
+		return nil
+	}
+
+	// The day of the course in a week, which may fall out of the semester
+	// or on a holiday, and the makeup days running its classes.
+	dayIn := func(week int) time.Time {
+		start := sem.Start.In(shanghai)
+		monday := start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+		return monday.AddDate(0, 0, 7*(week-1)+int(c.Weekday)-1)
+	}
+	held := func(d time.Time) bool {
+		_, weekday, ok := cal.DayOf(d)
+		return ok && weekday == c.Weekday
+	}
+	var makeups []time.Time
+	for _, a := range cal.Adjustments {
+		if week, ok := cal.WeekOf(a.Date); ok && a.ActsAsWeekday == c.Weekday && slices.Contains(weeks, week) {
+			makeups = append(makeups, a.Date)
+		}
+	}
+
+	uid := fmt.Sprintf("%s-%s-%d-%d@libfdu", sem.ID, c.CourseID, c.Weekday, c.StartSlot)
+	step, skipped := pattern(weeks)
+	if len(weeks) > 1 && len(skipped) <= maxExdates {
+		var exdates []time.Time
+		for week := weeks[0]; week <= weeks[len(weeks)-1]; week += step {
+			if d := dayIn(week); slices.Contains(skipped, week) || !held(d) {
+				exdates = append(exdates, d)
+			}
+		}
+		first, last := dayIn(weeks[0]), dayIn(weeks[len(weeks)-1])
+		rule := "FREQ=WEEKLY"
+		if step > 1 {
+			rule += fmt.Sprintf(";INTERVAL=%d", step)
+		}
+		// UNTIL is in UTC, since DTSTART has a time zone.
+		rule += ";UNTIL=" + at(last, c.StartSlot, 0).UTC().Format(utcLayout)
+
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", uid)
+		w.line("DTSTAMP", stamp)
+		w.localTimes("DTSTART", at(first, c.StartSlot, 0))
+		w.localTimes("DTEND", at(first, c.EndSlot, 1))
+		w.line("RRULE", rule)
+		w.localTimes("EXDATE", starts(exdates, c.StartSlot)...)
+		w.localTimes("RDATE", starts(makeups, c.StartSlot)...)
+	} else {
+		var dates []time.Time
+		for _, week := range weeks {
+			if d := dayIn(week); held(d) {
+				dates = append(dates, d)
+			}
+		}
+		dates = append(dates, makeups...)
+		if len(dates) == 0 {
+			// Every lesson falls on a holiday.
+			return nil
+		}
+		slices.SortFunc(dates, time.Time.Compare)
+
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", uid)
+		w.line("DTSTAMP", stamp)
+		w.localTimes("DTSTART", at(dates[0], c.StartSlot, 0))
+		w.localTimes("DTEND", at(dates[0], c.EndSlot, 1))
+		w.localTimes("RDATE", starts(dates[1:], c.StartSlot)...)
+	}
+
+	w.text("SUMMARY", c.Name)
+	if c.Location != "" {
+		w.text("LOCATION", c.Location)
+	}
+	description := fmt.Sprintf("第%d-%d节\n课程号：%s", c.StartSlot, c.EndSlot, c.CourseID)
+	if c.Teacher != "" {
+		description = "教师：" + c.Teacher + "\n" + description
+	}
+	w.text("DESCRIPTION", description)
+	w.line("END", "VEVENT")
+	return nil
+}
+
+// pattern returns the interval of the weeks, 2 if they are all odd or all
+// even, 1 otherwise, and the weeks skipped between the first and last of
+// them at that interval.
+func pattern(weeks []int) (step int, skipped []int) {
+	step = 2
+	for _, week := range weeks {
+		if (week-weeks[0])%2 != 0 {
+			step = 1
+		}
+	}
+	for week := weeks[0]; week <= weeks[len(weeks)-1]; week += step {
+		if !slices.Contains(weeks, week) {
+			skipped = append(skipped, week)
+		}
+	}
+	return step, skipped
+}
+
+func writeExam(w *writer, e fdu.Exam, stamp string) {
+	if !e.Scheduled() {
+		return
+	}
+	w.line("BEGIN", "VEVENT")
+	w.line("UID", fmt.Sprintf("exam-%s-%s@libfdu", e.CourseID, e.Start.In(shanghai).Format(dateLayout)))
+	w.line("DTSTAMP", stamp)
+	if e.End.IsZero() {
+		start := e.Start.In(shanghai)
+		w.line("DTSTART;VALUE=DATE", start.Format(dateLayout))
+		w.line("DTEND;VALUE=DATE", start.AddDate(0, 0, 1).Format(dateLayout))
+	} else {
+		w.localTimes("DTSTART", e.Start)
+		w.localTimes("DTEND", e.End)
+	}
+	w.text("SUMMARY", strings.TrimSpace(e.Name+" "+e.Type))
+	if e.Location != "" {
+		w.text("LOCATION", e.Location)
+	}
+	var description []string
+	if e.Location != "" {
+		description = append(description, "地点："+e.Location)
+	}
+	if e.Seat != "" {
+		description = append(description, "座位号："+e.Seat)
+	}
+	if e.Note != "" {
+		description = append(description, "备注："+e.Note)
+	}
+	description = append(description, "课程号："+e.CourseID)
+	w.text("DESCRIPTION", strings.Join(description, "\n"))
+	w.line("END", "VEVENT")
+}
+
+// starts returns the starts of slot on the days.
+func starts(days []time.Time, slot fdu.TimeSlot) []time.Time {
+	times := make([]time.Time, len(days))
+	for i, d := range days {
+		times[i] = at(d, slot, 0)
+	}
+	return times
+}
+
+// at returns the start (end 0) or the end (end 1) of slot on the day d.
+func at(d time.Time, slot fdu.TimeSlot, end int) time.Time {
+	y, m, day := d.In(shanghai).Date()
+	return time.Date(y, m, day, 0, slotTimes[slot-1][end], 0, 0, shanghai)
+}
@@ -0,0 +1,180 @@
+package ical
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func date(month time.Month, day int) time.Time {
+	year := 2023
+	if month < time.September {
+		year = 2024
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, shanghai)
+}
+
+// autumn is the autumn semester of 2023, with the holidays of the National
+// Day: 10/7 and 10/8 run the classes of 10/5 and 10/6.
+var autumn = fdu.AcademicCalendar{
+	Semesters: []fdu.CalendarSemester{{ID: "443", SchoolYear: "2023-2024", Name: "1", Start: date(9, 11), End: date(1, 14)}},
+	Holidays: []fdu.Holiday{
+		{Date: date(9, 29), Name: "中秋节"},
+		{Date: date(10, 2), Name: "国庆节"},
+		{Date: date(10, 3), Name: "国庆节"},
+		{Date: date(10, 4), Name: "国庆节"},
+		{Date: date(10, 5), Name: "国庆节"},
+		{Date: date(10, 6), Name: "国庆节"},
+		{Date: date(1, 1), Name: "元旦"},
+	},
+	Adjustments: []fdu.DayAdjustment{
+		{Date: date(10, 7), ActsAsWeekday: 4},
+		{Date: date(10, 8), ActsAsWeekday: 5},
+	},
+}
+
+func weeks(from, to, step int, skip ...int) []int {
+	var weeks []int
+	for week := from; week <= to; week += step {
+		if !slices.Contains(skip, week) {
+			weeks = append(weeks, week)
+		}
+	}
+	return weeks
+}
+
+var testCourses = []fdu.Course{
+	// Weekly, the Monday of week 4 is the National Day.
+	{CourseID: "COMP130004.03", Name: "数据结构", Teacher: "王老师", Location: "H3109", Weekday: 1, StartSlot: 3, EndSlot: 4, Weeks: weeks(1, 16, 1)},
+	// Odd weeks: the Thursday of week 4, made up on 10/7, is not one.
+	{CourseID: "PHYS120013.01", Name: "大学物理", Teacher: "李老师", Location: "H2115", Weekday: 4, StartSlot: 6, EndSlot: 7, Weeks: weeks(1, 15, 2)},
+	// Even weeks: the Friday of week 4 is made up on 10/8.
+	{CourseID: "PEDU110001.12", Name: "体育", Teacher: "赵老师", Location: "正大体育馆", Weekday: 5, StartSlot: 1, EndSlot: 2, Weeks: weeks(2, 16, 2)},
+	// Irregular weeks, listed by date.
+	{CourseID: "COMP130010.01", Name: "研讨课", Weekday: 3, StartSlot: 11, EndSlot: 12, Weeks: []int{1, 2, 3, 8, 9, 14}},
+	// A week off for midterms, and text to escape and fold.
+	{CourseID: "PTSS110039.05", Name: "马克思主义基本原理（含实践课，线上;线下结合）", Teacher: "张老师,李老师", Location: "光华楼西辅楼\\102", Weekday: 2, StartSlot: 1, EndSlot: 3, Weeks: weeks(1, 17, 1, 9)},
+	// A single lecture.
+	{CourseID: "COMP130099.01", Name: "讲座", Weekday: 6, StartSlot: 6, EndSlot: 8, Weeks: []int{5}},
+}
+
+var testExams = []fdu.Exam{
+	{CourseID: "COMP130004.03", Name: "数据结构", Type: "期末考试", Start: time.Date(2024, 1, 3, 8, 30, 0, 0, shanghai), End: time.Date(2024, 1, 3, 10, 30, 0, 0, shanghai), Location: "H3109", Seat: "27"},
+	{CourseID: "PHYS120013.01", Name: "大学物理", Type: "期末考试", Start: date(1, 5), Note: "带计算器"},
+	{CourseID: "PTSS110039.05", Name: "马克思主义基本原理", Type: "期末考试", Note: "论文"},
+}
+
+func TestBuild(t *testing.T) {
+	now = func() time.Time { return time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	got, err := Build(testCourses, testExams, autumn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const golden = "testdata/autumn.ics"
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s, run with -update to see the diff:\n%s", golden, got)
+	}
+}
+
+func TestFolding(t *testing.T) {
+	got, err := FromCourses(testCourses, autumn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(got, []byte("END:VCALENDAR\r\n")) {
+		t.Error("no CRLF after the last line")
+	}
+	var unfolded []string
+	folded := false
+	for _, line := range strings.Split(strings.TrimSuffix(string(got), "\r\n"), "\r\n") {
+		if len(line) > maxLine {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("UTF-8 sequence split in %q", line)
+		}
+		if strings.HasPrefix(line, " ") {
+			folded = true
+			unfolded[len(unfolded)-1] += line[1:]
+			continue
+		}
+		unfolded = append(unfolded, line)
+	}
+	if !folded {
+		t.Error("no line folded")
+	}
+	want := `DESCRIPTION:教师：张老师\,李老师\n第1-3节\n课程号：PTSS110039.05`
+	if !slices.Contains(unfolded, want) {
+		t.Errorf("no line %q once unfolded", want)
+	}
+	if want := `LOCATION:光华楼西辅楼\\102`; !slices.Contains(unfolded, want) {
+		t.Errorf("no line %q once unfolded", want)
+	}
+}
+
+func TestLine(t *testing.T) {
+	var w writer
+	w.line("X", strings.Repeat("a", 73))
+	w.line("X", strings.Repeat("a", 74))
+	w.line("X", strings.Repeat("中", 40))
+	want := "X:" + strings.Repeat("a", 73) + "\r\n" +
+		"X:" + strings.Repeat("a", 73) + "\r\n a\r\n" +
+		// 2 octets and 24 characters of 3 octets, then 24 after the space.
+		"X:" + strings.Repeat("中", 24) + "\r\n " + strings.Repeat("中", 16) + "\r\n"
+	if w.String() != want {
+		t.Errorf("got %q, want %q", w.String(), want)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	for _, tc := range []struct {
+		weeks   []int
+		step    int
+		skipped []int
+	}{
+		{weeks(1, 16, 1), 1, nil},
+		{weeks(1, 15, 2), 2, nil},
+		{weeks(2, 16, 2), 2, nil},
+		{[]int{1, 3, 7}, 2, []int{5}},
+		{[]int{1, 2, 5}, 1, []int{3, 4}},
+	} {
+		step, skipped := pattern(tc.weeks)
+		if step != tc.step || !slices.Equal(skipped, tc.skipped) {
+			t.Errorf("%v: got %d and %v, want %d and %v", tc.weeks, step, skipped, tc.step, tc.skipped)
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	if _, err := FromCourses(testCourses, fdu.AcademicCalendar{}); err == nil {
+		t.Error("no error without a semester")
+	}
+	bad := []fdu.Course{{CourseID: "X", Weekday: 8, StartSlot: 1, EndSlot: 2, Weeks: []int{1}}}
+	if _, err := FromCourses(bad, autumn); err == nil {
+		t.Error("no error for weekday 8")
+	}
+	// Exams alone need no calendar.
+	if _, err := FromExams(testExams); err != nil {
+		t.Error(err)
+	}
+}
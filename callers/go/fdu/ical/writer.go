@@ -0,0 +1,69 @@
+package ical
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateLayout  = "20060102"
+	localLayout = "20060102T150405"
+	utcLayout   = "20060102T150405Z"
+	// maxLine is the length of a line in octets, without the line break,
+	// above which lines are folded.
+	maxLine = 75
+)
+
+// writer writes the content lines of a calendar, folded and ending with
+// CRLF as required by RFC 5545.
+type writer struct {
+	strings.Builder
+}
+
+// line writes the property name, which may include parameters, with the
+// value, which must be escaped already. Long lines are folded between
+// characters, never inside a UTF-8 sequence, into lines of at most maxLine
+// octets, the continuations starting with a space.
+func (w *writer) line(name, value string) {
+	line := name + ":" + value
+	limit := maxLine
+	for len(line) > limit {
+		n := limit
+		for !utf8.RuneStart(line[n]) {
+			n--
+		}
+		w.WriteString(line[:n])
+		w.WriteString("\r\n ")
+		line = line[n:]
+		limit = maxLine - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// text writes a property of type TEXT.
+func (w *writer) text(name, value string) {
+	w.line(name, escape(value))
+}
+
+// localTimes writes a property with the times in the time zone TZID, or
+// nothing if there is no time.
+func (w *writer) localTimes(name string, times ...time.Time) {
+	if len(times) == 0 {
+		return
+	}
+	values := make([]string, len(times))
+	for i, t := range times {
+		values[i] = t.In(shanghai).Format(localLayout)
+	}
+	w.line(name+";TZID="+TZID, strings.Join(values, ","))
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escape escapes the backslashes, semicolons, commas and line breaks of a
+// TEXT value.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
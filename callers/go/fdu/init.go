@@ -0,0 +1,128 @@
+package fdu
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// abiVersion is FDU_ABI_VERSION of bindings.h, i.e. the ABI of libfdu this
+// package is built against. TestABIVersionInSync checks it.
+const abiVersion = 1
+
+var (
+	// initMu serializes Init and Shutdown.
+	initMu      sync.Mutex
+	initialized atomic.Bool
+)
+
+// Option configures Init.
+type Option func(*options)
+
+type options struct {
+	libraryPath string
+	config      *Config
+	retryPolicy *RetryPolicy
+	allowWrites bool
+	cache       *cacheOptions
+}
+
+// WithLibraryPath makes Init load libfdu from path, like Load.
+func WithLibraryPath(path string) Option {
+	return func(o *options) {
+		o.libraryPath = path
+	}
+}
+
+// Init loads libfdu, checks that its ABI is the one this package is built
+// against, and sets up its global state. It must be called before anything
+// else of the package, which otherwise returns ErrNotInitialized.
+//
+// Calling Init again is a no-op until Shutdown, after which Init sets up the
+// library again. The library is loaded only once per process, so the options
+// of later calls must not ask for another library.
+func Init(opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	initMu.Lock()
+	defer initMu.Unlock()
+	if o.allowWrites {
+		writesAllowed.Store(true)
+	}
+	if initialized.Load() {
+		return nil
+	}
+	if o.libraryPath != "" {
+		if err := Load(o.libraryPath); err != nil {
+			return err
+		}
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	if v := lib.fduAbiVersion(); v != abiVersion {
+		return fmt.Errorf("%w: libfdu %s has ABI version %d, but this package needs %d: "+
+			"rebuild libfdu or update the package to match", ErrIncompatibleLibrary, goString(lib.fduVersion()), v, abiVersion)
+	}
+	applyLogger()
+	applyTracer()
+	if _, err := takeResult(lib.fduInit()); err != nil {
+		return err
+	}
+	if o.config != nil {
+		if err := setConfig(*o.config); err != nil {
+			return err
+		}
+	}
+	if o.retryPolicy != nil {
+		if err := setRetryPolicy(nil, o.retryPolicy); err != nil {
+			return err
+		}
+	}
+	if o.cache != nil {
+		c, err := newDiskCache(*o.cache)
+		if err != nil {
+			return err
+		}
+		cache.Store(c)
+	}
+	initialized.Store(true)
+	return nil
+}
+
+// Shutdown tears down the global state of libfdu: logins waiting for a
+// captcha are dropped, the cache of WithCache is no longer used but its files
+// are kept, and every call but Session.Close returns ErrNotInitialized until
+// Init is called again. Sessions are not closed, and stay usable after the
+// next Init. Shutdown must not be called concurrently with other calls into
+// the package.
+func Shutdown() {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if !initialized.Load() {
+		return
+	}
+	initialized.Store(false)
+	writesAllowed.Store(false)
+	cache.Store(nil)
+	lib.fduShutdown()
+}
+
+// Version returns the version of libfdu, e.g. "0.1.0".
+func Version() (string, error) {
+	if err := checkInit(); err != nil {
+		return "", err
+	}
+	return goString(lib.fduVersion()), nil
+}
+
+// checkInit returns ErrNotInitialized unless Init has been called.
+func checkInit() error {
+	if !initialized.Load() {
+		return ErrNotInitialized
+	}
+	return nil
+}
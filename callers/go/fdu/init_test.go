@@ -0,0 +1,104 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestABIVersionInSync fails if FDU_ABI_VERSION of bindings.h has been bumped
+// without abiVersion, or if the library tested is not built from the header.
+func TestABIVersionInSync(t *testing.T) {
+	header, err := os.ReadFile("bindings.h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`(?m)^#define FDU_ABI_VERSION (\d+)$`).FindSubmatch(header)
+	if m == nil {
+		t.Fatal("FDU_ABI_VERSION not found in bindings.h")
+	}
+	if v, _ := strconv.Atoi(string(m[1])); v != abiVersion {
+		t.Errorf("bindings.h has ABI version %d, abiVersion is %d", v, abiVersion)
+	}
+	if v := lib.fduAbiVersion(); v != abiVersion {
+		t.Errorf("libfdu has ABI version %d, abiVersion is %d", v, abiVersion)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	if v, err := Version(); err != nil || v == "" {
+		t.Errorf("Version() = (%q, %v)", v, err)
+	}
+}
+
+func TestInitTwice(t *testing.T) {
+	// TestMain has already called Init.
+	if err := Init(); err != nil {
+		t.Fatalf("second Init() = %v", err)
+	}
+	if _, err := Hello(); err != nil {
+		t.Errorf("Hello() after second Init = %v", err)
+	}
+}
+
+func TestNotInitialized(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	Shutdown()
+	defer func() {
+		if err := Init(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Shutdown twice is a no-op.
+	Shutdown()
+
+	if _, err := Hello(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("Hello() after Shutdown = %v, want ErrNotInitialized", err)
+	}
+	if _, err := Login(context.Background(), "user", "password"); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("Login() after Shutdown = %v, want ErrNotInitialized", err)
+	}
+	if _, err := Restore([]byte("blob")); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("Restore() after Shutdown = %v, want ErrNotInitialized", err)
+	}
+	if _, err := Version(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("Version() after Shutdown = %v, want ErrNotInitialized", err)
+	}
+	if _, err := s.testPing(); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("testPing() after Shutdown = %v, want ErrNotInitialized", err)
+	}
+}
+
+func TestInitAfterShutdown(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	captcha := captchaRequired(t, testLoginCaptcha(time.Minute))
+
+	Shutdown()
+	if err := Init(); err != nil {
+		t.Fatalf("Init() after Shutdown = %v", err)
+	}
+
+	if _, err := Hello(); err != nil {
+		t.Errorf("Hello() after restart = %v", err)
+	}
+	// Sessions survive the restart, pending logins do not.
+	if _, err := s.testPing(); err != nil {
+		t.Errorf("testPing() after restart = %v", err)
+	}
+	if _, err := LoginWithCaptcha(context.Background(), captcha.Token, "1234"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("LoginWithCaptcha() with a token from before Shutdown = %v, want ErrInvalidArgument", err)
+	}
+}
@@ -0,0 +1,237 @@
+package fdu
+
+import "unsafe"
+
+// The functions of libfdu are called through lib, which is bound to the
+// library by the backend selected at build time:
+//
+//   - lib_cgo.go, the default, links against libfdu with cgo: the shared
+//     library, or the static one with the fdu_static build tag.
+//   - lib_purego.go, with the fdu_purego build tag, loads libfdu at runtime
+//     with purego, so that the package builds with CGO_ENABLED=0 and
+//     cross-compiles.
+//
+// The rest of the package only uses lib and the types below, so it is the
+// same for both backends. Strings are passed as Go strings, which the backend
+// converts to C strings for the duration of the call.
+
+// cSession and cCancelToken are the opaque FduSession and FduCancelToken of
+// bindings.h, only handled by pointer.
+type (
+	cSession     struct{}
+	cCancelToken struct{}
+)
+
+// cResult mirrors FduResult of bindings.h.
+type cResult struct {
+	value   *byte
+	code    int32
+	message *byte
+}
+
+// cBuffer mirrors FduBuffer of bindings.h.
+type cBuffer struct {
+	data *byte
+	len  uintptr
+}
+
+// libfdu is the table of the libfdu functions, named after the exports in
+// bindings.h.
+type libfdu struct {
+	freeBuffer func(buf *cBuffer)
+	freeResult func(r *cResult)
+	getURL     func(url string) *cResult
+	helloWorld func() *cResult
+
+	fduAbiVersion                func() uint32
+	fduAcademicCalendarAsync     func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduAllocStats                func() *cResult
+	fduAnnouncementsAsync        func(source string, sinceID uint64, token *cCancelToken, requestID uint64) *cResult
+	fduBatchAsync                func(session *cSession, requests string, token *cCancelToken, requestID uint64) *cResult
+	fduCallInto                  func(c call, session *cSession, arg string, buf *byte, cap uintptr, out **cResult) uintptr
+	fduCancel                    func(token *cCancelToken)
+	fduCancelTokenFree           func(token *cCancelToken)
+	fduCancelTokenNew            func() *cCancelToken
+	fduCancelTokenSetDeadline    func(token *cCancelToken, timeoutMillis uint64)
+	fduCancelTokenSetTraceID     func(token *cCancelToken, traceID string) *cResult
+	fduCaptchaImage              func(continuation string, out **cBuffer) *cResult
+	fduCardBalanceAsync          func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduCardPaymentCodeAsync      func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduCardTransactionsAsync     func(session *cSession, startDate, endDate string, page uintptr, token *cCancelToken, requestID uint64) *cResult
+	fduCoursesAsync              func(session *cSession, semesterID string, token *cCancelToken, requestID uint64) *cResult
+	fduDegreeAuditAsync          func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduDropAsync                 func(session *cSession, lessonID int64, token *cCancelToken, requestID uint64) *cResult
+	fduElectricityBalanceAsync   func(session *cSession, campus, building, room string, token *cCancelToken, requestID uint64) *cResult
+	fduEmptyClassroomsAsync      func(session *cSession, campus, date string, startSlot, endSlot int32, token *cCancelToken, requestID uint64) *cResult
+	fduEnrollAsync               func(session *cSession, lessonID int64, token *cCancelToken, requestID uint64) *cResult
+	fduExamsAsync                func(session *cSession, semesterID string, token *cCancelToken, requestID uint64) *cResult
+	fduGPAAsync                  func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduInTraceCallback           func() bool
+	fduInit                      func() *cResult
+	fduLibraryAreasAsync         func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduLibraryBorrowHistoryAsync func(session *cSession, pageToken string, token *cCancelToken, requestID uint64) *cResult
+	fduLibraryLoansAsync         func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduLibraryRenewAsync         func(session *cSession, barcode string, token *cCancelToken, requestID uint64) *cResult
+	fduLibrarySeatsAsync         func(session *cSession, areaID int64, token *cCancelToken, requestID uint64) *cResult
+	fduLogin                     func(username, password string, token *cCancelToken, out **cSession) *cResult
+	fduLoginWithCaptcha          func(continuation, answer string, token *cCancelToken, out **cSession) *cResult
+	fduPERecordsAsync            func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduPETestScoresAsync         func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduPollCompletions           func(buf *cCompletion, n uintptr, timeoutMillis uint64) uintptr
+	fduProbeAsync                func(services string, timeoutMillis uint64, token *cCancelToken, requestID uint64) *cResult
+	fduProfileAsync              func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduScoresAsync               func(session *cSession, semesterID string, token *cCancelToken, requestID uint64) *cResult
+	fduSelectableCoursesAsync    func(session *cSession, query string, token *cCancelToken, requestID uint64) *cResult
+	fduSemestersAsync            func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduSessionDo                 func(session *cSession, request string, body *byte, bodyLen uintptr, token *cCancelToken, out **cBuffer) *cResult
+	fduSessionExport             func(session *cSession, out **cBuffer) *cResult
+	fduSessionFree               func(session *cSession)
+	fduSessionLogoutAsync        func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduSessionRateLimitStats     func(session *cSession) *cResult
+	fduSessionRestore            func(data *byte, len uintptr, out **cSession) *cResult
+	fduSessionSetStudentType     func(session *cSession, studentType string) *cResult
+	fduSessionUID                func(session *cSession) *cResult
+	fduSessionValidAsync         func(session *cSession, token *cCancelToken, requestID uint64) *cResult
+	fduSetBaseURLs               func(json string) *cResult
+	fduSetHTTPConfig             func(json string) *cResult
+	// fduSetLogCallback takes whether to enable the log callback of the
+	// backend, which calls dispatchLog, instead of the callback itself.
+	fduSetLogCallback func(level int32, enabled bool) *cResult
+	fduSetRecording   func(dir string, redact uint32) *cResult
+	// fduSetRetryPolicy takes an empty json for NULL.
+	fduSetRetryPolicy func(session *cSession, json string) *cResult
+	// fduSetTraceCallback takes whether to enable the trace callback of the
+	// backend, which calls dispatchTrace, like fduSetLogCallback.
+	fduSetTraceCallback func(enabled bool) *cResult
+	fduShutdown         func()
+	fduTestEchoBytes    func(data *byte, len uintptr, out **cBuffer) *cResult
+	fduTestError        func(code int32) *cResult
+	fduTestLeak         func(count uint32) *cResult
+	fduTestLiveBuffers  func() int64
+	fduTestLiveSessions func() int64
+	fduTestLiveTokens   func() int64
+	fduTestLog          func() *cResult
+	fduTestLoginCaptcha func(ttlMillis uint64) *cResult
+	fduTestPagesAsync   func(session *cSession, pageToken string, failPage uint32, token *cCancelToken, requestID uint64) *cResult
+	fduTestPanic        func() *cResult
+	fduTestResult       func(value string, code int32) *cResult
+	fduTestResultAsync  func(value string, code int32, millis uint64, token *cCancelToken, requestID uint64) *cResult
+	fduTestSessionNew   func(out **cSession) *cResult
+	fduTestSessionPing  func(session *cSession) *cResult
+	fduTestSleep        func(millis uint64, token *cCancelToken) *cResult
+	fduTestSleepAsync   func(millis uint64, token *cCancelToken, requestID uint64) *cResult
+	fduTestTraceAsync   func(host, value string, token *cCancelToken, requestID uint64) *cResult
+	fduVersion          func() *byte
+}
+
+var lib libfdu
+
+// export is a function of libfdu called through a field of libfdu.
+type export struct {
+	// fptr points to the field.
+	fptr any
+	name string
+	// sig is the signature of the function the backends call, checked
+	// against bindings.h by TestABI.
+	sig string
+}
+
+// exports lists the functions of libfdu which l calls with the signatures
+// they expect, in the format of abicheck. It is the contract of the package
+// with libfdu: a new field of libfdu must be listed, and a pointer it
+// returns must be freed, see TestABIOwnership.
+func (l *libfdu) exports() []export {
+	return []export{
+		{&l.freeBuffer, "free_buffer", "void (struct FduBuffer *)"},
+		{&l.freeResult, "free_result", "void (struct FduResult *)"},
+		{&l.getURL, "get_url", "struct FduResult *(const char *)"},
+		{&l.helloWorld, "hello_world", "struct FduResult *(void)"},
+
+		{&l.fduAbiVersion, "fdu_abi_version", "uint32_t (void)"},
+		{&l.fduAcademicCalendarAsync, "fdu_academic_calendar_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduAllocStats, "fdu_alloc_stats", "struct FduResult *(void)"},
+		{&l.fduAnnouncementsAsync, "fdu_announcements_async", "struct FduResult *(const char *, uint64_t, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduBatchAsync, "fdu_batch_async", "struct FduResult *(const struct FduSession *, const char *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduCallInto, "fdu_call_into", "size_t (int32_t, const struct FduSession *, const char *, uint8_t *, size_t, struct FduResult **)"},
+		{&l.fduCancel, "fdu_cancel", "void (const struct FduCancelToken *)"},
+		{&l.fduCancelTokenFree, "fdu_cancel_token_free", "void (struct FduCancelToken *)"},
+		{&l.fduCancelTokenNew, "fdu_cancel_token_new", "struct FduCancelToken *(void)"},
+		{&l.fduCancelTokenSetDeadline, "fdu_cancel_token_set_deadline", "void (const struct FduCancelToken *, uint64_t)"},
+		{&l.fduCancelTokenSetTraceID, "fdu_cancel_token_set_trace_id", "struct FduResult *(const struct FduCancelToken *, const char *)"},
+		{&l.fduCaptchaImage, "fdu_captcha_image", "struct FduResult *(const char *, struct FduBuffer **)"},
+		{&l.fduCardBalanceAsync, "fdu_card_balance_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduCardPaymentCodeAsync, "fdu_card_payment_code_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduCardTransactionsAsync, "fdu_card_transactions_async", "struct FduResult *(const struct FduSession *, const char *, const char *, size_t, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduCoursesAsync, "fdu_courses_async", "struct FduResult *(const struct FduSession *, const char *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduDegreeAuditAsync, "fdu_degree_audit_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduDropAsync, "fdu_drop_async", "struct FduResult *(const struct FduSession *, int64_t, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduElectricityBalanceAsync, "fdu_electricity_balance_async", "struct FduResult *(const struct FduSession *, const char *, const char *, const char *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduEmptyClassroomsAsync, "fdu_empty_classrooms_async", "struct FduResult *(const struct FduSession *, const char *, const char *, int32_t, int32_t, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduEnrollAsync, "fdu_enroll_async", "struct FduResult *(const struct FduSession *, int64_t, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduExamsAsync, "fdu_exams_async", "struct FduResult *(const struct FduSession *, const char *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduGPAAsync, "fdu_gpa_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduInTraceCallback, "fdu_in_trace_callback", "bool (void)"},
+		{&l.fduInit, "fdu_init", "struct FduResult *(void)"},
+		{&l.fduLibraryAreasAsync, "fdu_library_areas_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduLibraryBorrowHistoryAsync, "fdu_library_borrow_history_async", "struct FduResult *(const struct FduSession *, const char *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduLibraryLoansAsync, "fdu_library_loans_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduLibraryRenewAsync, "fdu_library_renew_async", "struct FduResult *(const struct FduSession *, const char *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduLibrarySeatsAsync, "fdu_library_seats_async", "struct FduResult *(const struct FduSession *, int64_t, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduLogin, "fdu_login", "struct FduResult *(const char *, const char *, const struct FduCancelToken *, struct FduSession **)"},
+		{&l.fduLoginWithCaptcha, "fdu_login_with_captcha", "struct FduResult *(const char *, const char *, const struct FduCancelToken *, struct FduSession **)"},
+		{&l.fduPERecordsAsync, "fdu_pe_records_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduPETestScoresAsync, "fdu_pe_test_scores_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduPollCompletions, "fdu_poll_completions", "size_t (struct FduCompletion *, size_t, uint64_t)"},
+		{&l.fduProbeAsync, "fdu_probe_async", "struct FduResult *(const char *, uint64_t, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduProfileAsync, "fdu_profile_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduScoresAsync, "fdu_scores_async", "struct FduResult *(const struct FduSession *, const char *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduSelectableCoursesAsync, "fdu_selectable_courses_async", "struct FduResult *(const struct FduSession *, const char *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduSemestersAsync, "fdu_semesters_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduSessionDo, "fdu_session_do", "struct FduResult *(const struct FduSession *, const char *, const uint8_t *, size_t, const struct FduCancelToken *, struct FduBuffer **)"},
+		{&l.fduSessionExport, "fdu_session_export", "struct FduResult *(const struct FduSession *, struct FduBuffer **)"},
+		{&l.fduSessionFree, "fdu_session_free", "void (struct FduSession *)"},
+		{&l.fduSessionLogoutAsync, "fdu_session_logout_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduSessionRateLimitStats, "fdu_session_rate_limit_stats", "struct FduResult *(const struct FduSession *)"},
+		{&l.fduSessionRestore, "fdu_session_restore", "struct FduResult *(const uint8_t *, size_t, struct FduSession **)"},
+		{&l.fduSessionSetStudentType, "fdu_session_set_student_type", "struct FduResult *(const struct FduSession *, const char *)"},
+		{&l.fduSessionUID, "fdu_session_uid", "struct FduResult *(const struct FduSession *)"},
+		{&l.fduSessionValidAsync, "fdu_session_valid_async", "struct FduResult *(const struct FduSession *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduSetBaseURLs, "fdu_set_base_urls", "struct FduResult *(const char *)"},
+		{&l.fduSetHTTPConfig, "fdu_set_http_config", "struct FduResult *(const char *)"},
+		{&l.fduSetLogCallback, "fdu_set_log_callback", "struct FduResult *(int32_t, FduLogCallback)"},
+		{&l.fduSetRecording, "fdu_set_recording", "struct FduResult *(const char *, uint32_t)"},
+		{&l.fduSetRetryPolicy, "fdu_set_retry_policy", "struct FduResult *(const struct FduSession *, const char *)"},
+		{&l.fduSetTraceCallback, "fdu_set_trace_callback", "struct FduResult *(FduTraceCallback)"},
+		{&l.fduShutdown, "fdu_shutdown", "void (void)"},
+		{&l.fduTestEchoBytes, "fdu_test_echo_bytes", "struct FduResult *(const uint8_t *, size_t, struct FduBuffer **)"},
+		{&l.fduTestError, "fdu_test_error", "struct FduResult *(int32_t)"},
+		{&l.fduTestLeak, "fdu_test_leak", "struct FduResult *(uint32_t)"},
+		{&l.fduTestLiveBuffers, "fdu_test_live_buffers", "int64_t (void)"},
+		{&l.fduTestLiveSessions, "fdu_test_live_sessions", "int64_t (void)"},
+		{&l.fduTestLiveTokens, "fdu_test_live_tokens", "int64_t (void)"},
+		{&l.fduTestLog, "fdu_test_log", "struct FduResult *(void)"},
+		{&l.fduTestLoginCaptcha, "fdu_test_login_captcha", "struct FduResult *(uint64_t)"},
+		{&l.fduTestPagesAsync, "fdu_test_pages_async", "struct FduResult *(const struct FduSession *, const char *, uint32_t, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduTestPanic, "fdu_test_panic", "struct FduResult *(void)"},
+		{&l.fduTestResult, "fdu_test_result", "struct FduResult *(const char *, int32_t)"},
+		{&l.fduTestResultAsync, "fdu_test_result_async", "struct FduResult *(const char *, int32_t, uint64_t, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduTestSessionNew, "fdu_test_session_new", "struct FduResult *(struct FduSession **)"},
+		{&l.fduTestSessionPing, "fdu_test_session_ping", "struct FduResult *(const struct FduSession *)"},
+		{&l.fduTestSleep, "fdu_test_sleep", "struct FduResult *(uint64_t, const struct FduCancelToken *)"},
+		{&l.fduTestSleepAsync, "fdu_test_sleep_async", "struct FduResult *(uint64_t, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduTestTraceAsync, "fdu_test_trace_async", "struct FduResult *(const char *, const char *, const struct FduCancelToken *, uint64_t)"},
+		{&l.fduVersion, "fdu_version", "const char *(void)"},
+	}
+}
+
+// goString copies the NUL-terminated string at p, which belongs to libfdu.
+func goString(p *byte) string {
+	if p == nil {
+		return ""
+	}
+	n := 0
+	for *(*byte)(unsafe.Add(unsafe.Pointer(p), n)) != 0 {
+		n++
+	}
+	return string(unsafe.Slice(p, n))
+}
@@ -0,0 +1,375 @@
+//go:build !fdu_purego
+
+package fdu
+
+// The flags linking libfdu are in link_dynamic.go, or link_static_$GOOS.go
+// with the fdu_static build tag.
+
+/*
+#include "bindings.h"
+
+extern void goLogCallback(int32_t level, char *message);
+extern void goTraceCallback(void *event);
+*/
+import "C"
+
+import "unsafe"
+
+// backend is the name of the backend calling into libfdu, "cgo" or "purego".
+const backend = "cgo"
+
+// Load loads libfdu from path. With cgo, the library is linked when the
+// program is built and loaded by the dynamic loader at startup, so Load does
+// nothing: it only exists to build the same code with the fdu_purego tag.
+func Load(path string) error {
+	return nil
+}
+
+// load loads libfdu from the default paths unless Load was called.
+func load() error {
+	return nil
+}
+
+func init() {
+	lib = libfdu{
+		freeBuffer: func(buf *cBuffer) {
+			C.free_buffer((*C.FduBuffer)(unsafe.Pointer(buf)))
+		},
+		freeResult: func(r *cResult) {
+			C.free_result((*C.FduResult)(unsafe.Pointer(r)))
+		},
+		getURL: func(url string) *cResult {
+			cURL := C.CString(url)
+			defer C.free(unsafe.Pointer(cURL))
+			return result(C.get_url(cURL))
+		},
+		helloWorld: func() *cResult {
+			return result(C.hello_world())
+		},
+
+		fduAbiVersion: func() uint32 {
+			return uint32(C.fdu_abi_version())
+		},
+		fduAcademicCalendarAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_academic_calendar_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduAllocStats: func() *cResult {
+			return result(C.fdu_alloc_stats())
+		},
+		fduAnnouncementsAsync: func(source string, sinceID uint64, token *cCancelToken, requestID uint64) *cResult {
+			cSource := C.CString(source)
+			defer C.free(unsafe.Pointer(cSource))
+			return result(C.fdu_announcements_async(cSource, C.uint64_t(sinceID), cToken(token), C.uint64_t(requestID)))
+		},
+		fduBatchAsync: func(session *cSession, requests string, token *cCancelToken, requestID uint64) *cResult {
+			cRequests := C.CString(requests)
+			defer C.free(unsafe.Pointer(cRequests))
+			return result(C.fdu_batch_async(cSess(session), cRequests, cToken(token), C.uint64_t(requestID)))
+		},
+		fduCallInto: func(c call, session *cSession, arg string, buf *byte, cap uintptr, out **cResult) uintptr {
+			var cArg *C.char
+			if arg != "" {
+				cArg = C.CString(arg)
+				defer C.free(unsafe.Pointer(cArg))
+			}
+			return uintptr(C.fdu_call_into(C.int32_t(c), cSess(session), cArg, (*C.uint8_t)(buf), C.size_t(cap), (**C.FduResult)(unsafe.Pointer(out))))
+		},
+		fduCancel: func(token *cCancelToken) {
+			C.fdu_cancel(cToken(token))
+		},
+		fduCancelTokenFree: func(token *cCancelToken) {
+			C.fdu_cancel_token_free(cToken(token))
+		},
+		fduCancelTokenNew: func() *cCancelToken {
+			return (*cCancelToken)(unsafe.Pointer(C.fdu_cancel_token_new()))
+		},
+		fduCancelTokenSetDeadline: func(token *cCancelToken, timeoutMillis uint64) {
+			C.fdu_cancel_token_set_deadline(cToken(token), C.uint64_t(timeoutMillis))
+		},
+		fduCancelTokenSetTraceID: func(token *cCancelToken, traceID string) *cResult {
+			cTraceID := C.CString(traceID)
+			defer C.free(unsafe.Pointer(cTraceID))
+			return result(C.fdu_cancel_token_set_trace_id(cToken(token), cTraceID))
+		},
+		fduCaptchaImage: func(continuation string, out **cBuffer) *cResult {
+			cContinuation := C.CString(continuation)
+			defer C.free(unsafe.Pointer(cContinuation))
+			return result(C.fdu_captcha_image(cContinuation, cBufferOut(out)))
+		},
+		fduCardBalanceAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_card_balance_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduCardPaymentCodeAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_card_payment_code_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduCardTransactionsAsync: func(session *cSession, startDate, endDate string, page uintptr, token *cCancelToken, requestID uint64) *cResult {
+			cStartDate := C.CString(startDate)
+			defer C.free(unsafe.Pointer(cStartDate))
+			cEndDate := C.CString(endDate)
+			defer C.free(unsafe.Pointer(cEndDate))
+			return result(C.fdu_card_transactions_async(cSess(session), cStartDate, cEndDate, C.size_t(page), cToken(token), C.uint64_t(requestID)))
+		},
+		fduCoursesAsync: func(session *cSession, semesterID string, token *cCancelToken, requestID uint64) *cResult {
+			cSemesterID := C.CString(semesterID)
+			defer C.free(unsafe.Pointer(cSemesterID))
+			return result(C.fdu_courses_async(cSess(session), cSemesterID, cToken(token), C.uint64_t(requestID)))
+		},
+		fduDegreeAuditAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_degree_audit_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduDropAsync: func(session *cSession, lessonID int64, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_drop_async(cSess(session), C.int64_t(lessonID), cToken(token), C.uint64_t(requestID)))
+		},
+		fduElectricityBalanceAsync: func(session *cSession, campus, building, room string, token *cCancelToken, requestID uint64) *cResult {
+			cCampus := C.CString(campus)
+			defer C.free(unsafe.Pointer(cCampus))
+			cBuilding := C.CString(building)
+			defer C.free(unsafe.Pointer(cBuilding))
+			cRoom := C.CString(room)
+			defer C.free(unsafe.Pointer(cRoom))
+			return result(C.fdu_electricity_balance_async(cSess(session), cCampus, cBuilding, cRoom, cToken(token), C.uint64_t(requestID)))
+		},
+		fduEmptyClassroomsAsync: func(session *cSession, campus, date string, startSlot, endSlot int32, token *cCancelToken, requestID uint64) *cResult {
+			cCampus := C.CString(campus)
+			defer C.free(unsafe.Pointer(cCampus))
+			cDate := C.CString(date)
+			defer C.free(unsafe.Pointer(cDate))
+			return result(C.fdu_empty_classrooms_async(cSess(session), cCampus, cDate, C.int32_t(startSlot), C.int32_t(endSlot), cToken(token), C.uint64_t(requestID)))
+		},
+		fduEnrollAsync: func(session *cSession, lessonID int64, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_enroll_async(cSess(session), C.int64_t(lessonID), cToken(token), C.uint64_t(requestID)))
+		},
+		fduExamsAsync: func(session *cSession, semesterID string, token *cCancelToken, requestID uint64) *cResult {
+			cSemesterID := C.CString(semesterID)
+			defer C.free(unsafe.Pointer(cSemesterID))
+			return result(C.fdu_exams_async(cSess(session), cSemesterID, cToken(token), C.uint64_t(requestID)))
+		},
+		fduGPAAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_gpa_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduInTraceCallback: func() bool {
+			return bool(C.fdu_in_trace_callback())
+		},
+		fduInit: func() *cResult {
+			return result(C.fdu_init())
+		},
+		fduLibraryAreasAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_library_areas_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduLibraryBorrowHistoryAsync: func(session *cSession, pageToken string, token *cCancelToken, requestID uint64) *cResult {
+			cPageToken := C.CString(pageToken)
+			defer C.free(unsafe.Pointer(cPageToken))
+			return result(C.fdu_library_borrow_history_async(cSess(session), cPageToken, cToken(token), C.uint64_t(requestID)))
+		},
+		fduLibraryLoansAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_library_loans_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduLibraryRenewAsync: func(session *cSession, barcode string, token *cCancelToken, requestID uint64) *cResult {
+			cBarcode := C.CString(barcode)
+			defer C.free(unsafe.Pointer(cBarcode))
+			return result(C.fdu_library_renew_async(cSess(session), cBarcode, cToken(token), C.uint64_t(requestID)))
+		},
+		fduLibrarySeatsAsync: func(session *cSession, areaID int64, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_library_seats_async(cSess(session), C.int64_t(areaID), cToken(token), C.uint64_t(requestID)))
+		},
+		fduLogin: func(username, password string, token *cCancelToken, out **cSession) *cResult {
+			cUsername := C.CString(username)
+			defer C.free(unsafe.Pointer(cUsername))
+			cPassword := C.CString(password)
+			defer C.free(unsafe.Pointer(cPassword))
+			return result(C.fdu_login(cUsername, cPassword, cToken(token), cSessionOut(out)))
+		},
+		fduLoginWithCaptcha: func(continuation, answer string, token *cCancelToken, out **cSession) *cResult {
+			cContinuation := C.CString(continuation)
+			defer C.free(unsafe.Pointer(cContinuation))
+			cAnswer := C.CString(answer)
+			defer C.free(unsafe.Pointer(cAnswer))
+			return result(C.fdu_login_with_captcha(cContinuation, cAnswer, cToken(token), cSessionOut(out)))
+		},
+		fduPERecordsAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_pe_records_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduPETestScoresAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_pe_test_scores_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduPollCompletions: func(buf *cCompletion, n uintptr, timeoutMillis uint64) uintptr {
+			return uintptr(C.fdu_poll_completions((*C.FduCompletion)(unsafe.Pointer(buf)), C.size_t(n), C.uint64_t(timeoutMillis)))
+		},
+		fduProbeAsync: func(services string, timeoutMillis uint64, token *cCancelToken, requestID uint64) *cResult {
+			cServices := C.CString(services)
+			defer C.free(unsafe.Pointer(cServices))
+			return result(C.fdu_probe_async(cServices, C.uint64_t(timeoutMillis), cToken(token), C.uint64_t(requestID)))
+		},
+		fduProfileAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_profile_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduScoresAsync: func(session *cSession, semesterID string, token *cCancelToken, requestID uint64) *cResult {
+			cSemesterID := C.CString(semesterID)
+			defer C.free(unsafe.Pointer(cSemesterID))
+			return result(C.fdu_scores_async(cSess(session), cSemesterID, cToken(token), C.uint64_t(requestID)))
+		},
+		fduSelectableCoursesAsync: func(session *cSession, query string, token *cCancelToken, requestID uint64) *cResult {
+			cQuery := C.CString(query)
+			defer C.free(unsafe.Pointer(cQuery))
+			return result(C.fdu_selectable_courses_async(cSess(session), cQuery, cToken(token), C.uint64_t(requestID)))
+		},
+		fduSemestersAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_semesters_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduSessionDo: func(session *cSession, request string, body *byte, bodyLen uintptr, token *cCancelToken, out **cBuffer) *cResult {
+			cRequest := C.CString(request)
+			defer C.free(unsafe.Pointer(cRequest))
+			return result(C.fdu_session_do(cSess(session), cRequest, (*C.uint8_t)(unsafe.Pointer(body)), C.size_t(bodyLen), cToken(token), cBufferOut(out)))
+		},
+		fduSessionExport: func(session *cSession, out **cBuffer) *cResult {
+			return result(C.fdu_session_export(cSess(session), cBufferOut(out)))
+		},
+		fduSessionFree: func(session *cSession) {
+			C.fdu_session_free(cSess(session))
+		},
+		fduSessionLogoutAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_session_logout_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduSessionRateLimitStats: func(session *cSession) *cResult {
+			return result(C.fdu_session_rate_limit_stats(cSess(session)))
+		},
+		fduSessionRestore: func(data *byte, len uintptr, out **cSession) *cResult {
+			return result(C.fdu_session_restore((*C.uint8_t)(unsafe.Pointer(data)), C.size_t(len), cSessionOut(out)))
+		},
+		fduSessionSetStudentType: func(session *cSession, studentType string) *cResult {
+			cStudentType := C.CString(studentType)
+			defer C.free(unsafe.Pointer(cStudentType))
+			return result(C.fdu_session_set_student_type(cSess(session), cStudentType))
+		},
+		fduSessionUID: func(session *cSession) *cResult {
+			return result(C.fdu_session_uid(cSess(session)))
+		},
+		fduSessionValidAsync: func(session *cSession, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_session_valid_async(cSess(session), cToken(token), C.uint64_t(requestID)))
+		},
+		fduSetBaseURLs: func(json string) *cResult {
+			cJSON := C.CString(json)
+			defer C.free(unsafe.Pointer(cJSON))
+			return result(C.fdu_set_base_urls(cJSON))
+		},
+		fduSetHTTPConfig: func(json string) *cResult {
+			cJSON := C.CString(json)
+			defer C.free(unsafe.Pointer(cJSON))
+			return result(C.fdu_set_http_config(cJSON))
+		},
+		fduSetLogCallback: func(level int32, enabled bool) *cResult {
+			var callback C.FduLogCallback
+			if enabled {
+				callback = C.FduLogCallback(C.goLogCallback)
+			}
+			return result(C.fdu_set_log_callback(C.int32_t(level), callback))
+		},
+		fduSetRecording: func(dir string, redact uint32) *cResult {
+			cDir := C.CString(dir)
+			defer C.free(unsafe.Pointer(cDir))
+			return result(C.fdu_set_recording(cDir, C.uint32_t(redact)))
+		},
+		fduSetRetryPolicy: func(session *cSession, json string) *cResult {
+			cJSON := C.CString(json)
+			defer C.free(unsafe.Pointer(cJSON))
+			return result(C.fdu_set_retry_policy(cSess(session), cJSON))
+		},
+		fduSetTraceCallback: func(enabled bool) *cResult {
+			var callback C.FduTraceCallback
+			if enabled {
+				callback = C.FduTraceCallback(C.goTraceCallback)
+			}
+			return result(C.fdu_set_trace_callback(callback))
+		},
+		fduShutdown: func() {
+			C.fdu_shutdown()
+		},
+		fduTestEchoBytes: func(data *byte, len uintptr, out **cBuffer) *cResult {
+			return result(C.fdu_test_echo_bytes((*C.uint8_t)(unsafe.Pointer(data)), C.size_t(len), cBufferOut(out)))
+		},
+		fduTestError: func(code int32) *cResult {
+			return result(C.fdu_test_error(C.int32_t(code)))
+		},
+		fduTestLeak: func(count uint32) *cResult {
+			return result(C.fdu_test_leak(C.uint32_t(count)))
+		},
+		fduTestLiveBuffers: func() int64 {
+			return int64(C.fdu_test_live_buffers())
+		},
+		fduTestLiveSessions: func() int64 {
+			return int64(C.fdu_test_live_sessions())
+		},
+		fduTestLiveTokens: func() int64 {
+			return int64(C.fdu_test_live_tokens())
+		},
+		fduTestLog: func() *cResult {
+			return result(C.fdu_test_log())
+		},
+		fduTestLoginCaptcha: func(ttlMillis uint64) *cResult {
+			return result(C.fdu_test_login_captcha(C.uint64_t(ttlMillis)))
+		},
+		fduTestPagesAsync: func(session *cSession, pageToken string, failPage uint32, token *cCancelToken, requestID uint64) *cResult {
+			cPageToken := C.CString(pageToken)
+			defer C.free(unsafe.Pointer(cPageToken))
+			return result(C.fdu_test_pages_async(cSess(session), cPageToken, C.uint32_t(failPage), cToken(token), C.uint64_t(requestID)))
+		},
+		fduTestPanic: func() *cResult {
+			return result(C.fdu_test_panic())
+		},
+		fduTestResult: func(value string, code int32) *cResult {
+			cValue := C.CString(value)
+			defer C.free(unsafe.Pointer(cValue))
+			return result(C.fdu_test_result(cValue, C.int32_t(code)))
+		},
+		fduTestResultAsync: func(value string, code int32, millis uint64, token *cCancelToken, requestID uint64) *cResult {
+			cValue := C.CString(value)
+			defer C.free(unsafe.Pointer(cValue))
+			return result(C.fdu_test_result_async(cValue, C.int32_t(code), C.uint64_t(millis), cToken(token), C.uint64_t(requestID)))
+		},
+		fduTestSessionNew: func(out **cSession) *cResult {
+			return result(C.fdu_test_session_new(cSessionOut(out)))
+		},
+		fduTestSessionPing: func(session *cSession) *cResult {
+			return result(C.fdu_test_session_ping(cSess(session)))
+		},
+		fduTestSleep: func(millis uint64, token *cCancelToken) *cResult {
+			return result(C.fdu_test_sleep(C.uint64_t(millis), cToken(token)))
+		},
+		fduTestSleepAsync: func(millis uint64, token *cCancelToken, requestID uint64) *cResult {
+			return result(C.fdu_test_sleep_async(C.uint64_t(millis), cToken(token), C.uint64_t(requestID)))
+		},
+		fduTestTraceAsync: func(host, value string, token *cCancelToken, requestID uint64) *cResult {
+			cHost := C.CString(host)
+			defer C.free(unsafe.Pointer(cHost))
+			cValue := C.CString(value)
+			defer C.free(unsafe.Pointer(cValue))
+			return result(C.fdu_test_trace_async(cHost, cValue, cToken(token), C.uint64_t(requestID)))
+		},
+		fduVersion: func() *byte {
+			return (*byte)(unsafe.Pointer(C.fdu_version()))
+		},
+	}
+}
+
+// The types of lib.go have the same layout as their C counterparts, so the
+// pointers are simply converted.
+
+func result(r *C.FduResult) *cResult {
+	return (*cResult)(unsafe.Pointer(r))
+}
+
+func cSess(session *cSession) *C.FduSession {
+	return (*C.FduSession)(unsafe.Pointer(session))
+}
+
+func cToken(token *cCancelToken) *C.FduCancelToken {
+	return (*C.FduCancelToken)(unsafe.Pointer(token))
+}
+
+func cSessionOut(out **cSession) **C.FduSession {
+	return (**C.FduSession)(unsafe.Pointer(out))
+}
+
+func cBufferOut(out **cBuffer) **C.FduBuffer {
+	return (**C.FduBuffer)(unsafe.Pointer(out))
+}
@@ -0,0 +1,210 @@
+//go:build fdu_purego
+
+package fdu
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ebitengine/purego"
+)
+
+// backend is the name of the backend calling into libfdu, "cgo" or "purego".
+const backend = "purego"
+
+// libraryPathEnv is the environment variable naming the path of libfdu, or
+// of the directory holding it, so that programs can find it without Load.
+const libraryPathEnv = "FDU_LIBRARY_PATH"
+
+// libraryName is the file name of libfdu built by cargo.
+var libraryName = func() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "fdu.dll"
+	case "darwin":
+		return "libfdu.dylib"
+	}
+	return "libfdu.so"
+}()
+
+var (
+	// loadMu serializes the loading of the library, and guards loadedPath.
+	loadMu     sync.Mutex
+	loadedPath string
+	// loaded is set once lib is bound, so that load does not need the lock.
+	loaded atomic.Bool
+)
+
+// Load loads libfdu from path, e.g. to ship the library in a directory of
+// its own. It must be called before Init, which otherwise loads the library
+// from FDU_LIBRARY_PATH or the default paths described in the package
+// documentation. Once loaded, the library cannot be replaced: Load returns
+// nil for the same path and an error for any other.
+func Load(path string) error {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	if loaded.Load() {
+		if path == loadedPath {
+			return nil
+		}
+		return fmt.Errorf("fdu: libfdu already loaded from %s", loadedPath)
+	}
+	return open([]string{path})
+}
+
+// load loads libfdu from FDU_LIBRARY_PATH or the default paths unless Load
+// was called. A failure is not remembered, so that Load may still be called
+// afterwards.
+func load() error {
+	if loaded.Load() {
+		return nil
+	}
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	if loaded.Load() {
+		return nil
+	}
+	return open(searchPaths())
+}
+
+// open binds lib to the first of paths which can be loaded. loadMu must be
+// held.
+func open(paths []string) error {
+	errs := make([]error, 0, len(paths))
+	for _, path := range paths {
+		handle, err := openLibrary(path)
+		if err != nil {
+			errs = append(errs, explainLoadError(path, err))
+			continue
+		}
+		var l libfdu
+		if err := bind(&l, handle); err != nil {
+			// A library without the symbols is outdated, not missing: do
+			// not fall back to another one silently.
+			return fmt.Errorf("%w: %s: %v", ErrIncompatibleLibrary, path, err)
+		}
+		lib = l
+		loadedPath = path
+		loaded.Store(true)
+		return nil
+	}
+	return &LoadError{Tried: paths, Errs: errs, Err: errs[len(errs)-1]}
+}
+
+// explainLoadError returns what is wrong with path, which the OS failed to
+// load with err. The messages of dlopen and LoadLibrary are cryptic for the
+// two common causes: a missing file, and on macOS a downloaded library which
+// Gatekeeper refuses to load.
+func explainLoadError(path string, err error) error {
+	if filepath.Base(path) != path {
+		if _, statErr := os.Stat(path); errors.Is(statErr, fs.ErrNotExist) {
+			return fs.ErrNotExist
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		msg := err.Error()
+		if strings.Contains(msg, "quarantine") || strings.Contains(msg, "code signature") ||
+			strings.Contains(msg, "not valid for use in process") {
+			return fmt.Errorf("%w (if the library was downloaded, clear its quarantine with "+
+				"`xattr -d com.apple.quarantine %s`)", err, path)
+		}
+	}
+	return err
+}
+
+// searchPaths returns the paths to load libfdu from when Load is not called:
+// FDU_LIBRARY_PATH alone if set, since falling back to another library would
+// hide a mistake in it, or else the default paths. FDU_LIBRARY_PATH may name
+// the library or the directory holding it.
+func searchPaths() []string {
+	path := os.Getenv(libraryPathEnv)
+	if path == "" {
+		return defaultPaths()
+	}
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, libraryName)
+	}
+	return []string{path}
+}
+
+// defaultPaths returns the paths to load libfdu from when Load is not called:
+// next to the executable, in the target directories of the repository when
+// built from a checkout, and then by name only, from the search path of the
+// OS (e.g. LD_LIBRARY_PATH).
+func defaultPaths() []string {
+	var paths []string
+	if exe, err := os.Executable(); err == nil {
+		paths = append(paths, filepath.Join(filepath.Dir(exe), libraryName))
+	}
+	if _, file, _, ok := runtime.Caller(0); ok && filepath.IsAbs(file) {
+		root := filepath.Join(filepath.Dir(file), "..", "..", "..")
+		paths = append(paths,
+			filepath.Join(root, "target", "debug", libraryName),
+			filepath.Join(root, "target", "release", libraryName))
+	}
+	return append(paths, libraryName)
+}
+
+// logCallback is the FduLogCallback handed to libfdu. purego callbacks cannot
+// be freed, so there is only one. Its arguments are uintptr-sized for
+// Windows, and its result is ignored.
+var logCallback = sync.OnceValue(func() uintptr {
+	return purego.NewCallback(func(level uintptr, message *byte) uintptr {
+		dispatchLog(int32(level), message)
+		return 0
+	})
+})
+
+// traceCallback is the FduTraceCallback handed to libfdu, like logCallback.
+var traceCallback = sync.OnceValue(func() uintptr {
+	return purego.NewCallback(func(event *cTraceEvent) uintptr {
+		dispatchTrace(event)
+		return 0
+	})
+})
+
+// bind looks up every function of l in the library.
+func bind(l *libfdu, handle uintptr) error {
+	var (
+		setLogCallback   func(level int32, callback uintptr) *cResult
+		setTraceCallback func(callback uintptr) *cResult
+	)
+	for _, e := range l.exports() {
+		fptr := e.fptr
+		// The fields for the callbacks take whether to enable them, and are
+		// set below.
+		switch e.name {
+		case "fdu_set_log_callback":
+			fptr = &setLogCallback
+		case "fdu_set_trace_callback":
+			fptr = &setTraceCallback
+		}
+		addr, err := lookupSymbol(handle, e.name)
+		if err != nil {
+			return fmt.Errorf("missing symbol %s: %w", e.name, err)
+		}
+		purego.RegisterFunc(fptr, addr)
+	}
+	l.fduSetLogCallback = func(level int32, enabled bool) *cResult {
+		var callback uintptr
+		if enabled {
+			callback = logCallback()
+		}
+		return setLogCallback(level, callback)
+	}
+	l.fduSetTraceCallback = func(enabled bool) *cResult {
+		var callback uintptr
+		if enabled {
+			callback = traceCallback()
+		}
+		return setTraceCallback(callback)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+//go:build fdu_purego
+
+package fdu
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadMissing(t *testing.T) {
+	paths := []string{filepath.Join(t.TempDir(), libraryName), "libfdu-missing"}
+	loadMu.Lock()
+	err := open(paths)
+	loadMu.Unlock()
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("open(%q) = %v, want *LoadError", paths, err)
+	}
+	if !slices.Equal(loadErr.Tried, paths) || loadErr.Err == nil {
+		t.Errorf("LoadError = %+v, want every path tried", loadErr)
+	}
+}
+
+func TestLoadAgain(t *testing.T) {
+	// TestMain has already loaded the library.
+	if err := Load(loadedPath); err != nil {
+		t.Errorf("Load(%q) again = %v", loadedPath, err)
+	}
+	other := filepath.Join(t.TempDir(), libraryName)
+	if err := Load(other); err == nil {
+		t.Errorf("Load(%q) after Load(%q) = nil, want error", other, loadedPath)
+	}
+}
+
+func TestDefaultPaths(t *testing.T) {
+	paths := defaultPaths()
+	if len(paths) < 2 || paths[len(paths)-1] != libraryName {
+		t.Errorf("defaultPaths() = %q, want the executable directory first and the bare name last", paths)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	// A file which is not a library fails with the error of the OS, unlike
+	// a missing one.
+	fake := filepath.Join(t.TempDir(), libraryName)
+	if err := os.WriteFile(fake, []byte("not a library"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), libraryName)
+	paths := []string{fake, missing, "libfdu-missing"}
+	loadMu.Lock()
+	err := open(paths)
+	loadMu.Unlock()
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) || len(loadErr.Errs) != len(paths) {
+		t.Fatalf("open(%q) = %#v, want a *LoadError with an error per path", paths, err)
+	}
+	if loadErr.Errs[0] == nil || errors.Is(loadErr.Errs[0], fs.ErrNotExist) {
+		t.Errorf("fake library: %v, want the error of the OS", loadErr.Errs[0])
+	}
+	if !errors.Is(loadErr.Errs[1], fs.ErrNotExist) {
+		t.Errorf("missing library: %v, want fs.ErrNotExist", loadErr.Errs[1])
+	}
+	msg := err.Error()
+	for i, path := range paths {
+		if !strings.Contains(msg, path+": "+loadErr.Errs[i].Error()) {
+			t.Errorf("%q does not list %s with its error", msg, path)
+		}
+	}
+	if !strings.Contains(msg, libraryPathEnv) {
+		t.Errorf("%q does not mention %s", msg, libraryPathEnv)
+	}
+}
+
+func TestSearchPaths(t *testing.T) {
+	t.Setenv(libraryPathEnv, "")
+	if paths := searchPaths(); !slices.Equal(paths, defaultPaths()) {
+		t.Errorf("searchPaths() = %q without %s, want the default paths", paths, libraryPathEnv)
+	}
+
+	dir := t.TempDir()
+	t.Setenv(libraryPathEnv, dir)
+	if paths, want := searchPaths(), []string{filepath.Join(dir, libraryName)}; !slices.Equal(paths, want) {
+		t.Errorf("searchPaths() = %q for a directory, want %q", paths, want)
+	}
+	// The path is taken as is, even missing: it is reported rather than
+	// falling back to another library.
+	file := filepath.Join(dir, "custom", "libfdu-1.so")
+	t.Setenv(libraryPathEnv, file)
+	if paths, want := searchPaths(), []string{file}; !slices.Equal(paths, want) {
+		t.Errorf("searchPaths() = %q for a file, want %q", paths, want)
+	}
+}
+
+func TestLoadFromDirectory(t *testing.T) {
+	// A copy of the library loaded by TestMain, in a directory which is
+	// neither the working directory nor on the search path of the OS.
+	data, err := os.ReadFile(loadedPath)
+	if err != nil {
+		t.Skipf("libfdu loaded from the search path of the OS: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "lib", libraryName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	handle, err := openLibrary(path)
+	if err != nil {
+		t.Fatalf("openLibrary(%q) = %v", path, err)
+	}
+	if _, err := lookupSymbol(handle, "fdu_abi_version"); err != nil {
+		t.Errorf("lookupSymbol(fdu_abi_version) = %v", err)
+	}
+}
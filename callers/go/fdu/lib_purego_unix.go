@@ -0,0 +1,13 @@
+//go:build fdu_purego && !windows
+
+package fdu
+
+import "github.com/ebitengine/purego"
+
+func openLibrary(path string) (uintptr, error) {
+	return purego.Dlopen(path, purego.RTLD_NOW|purego.RTLD_LOCAL)
+}
+
+func lookupSymbol(handle uintptr, name string) (uintptr, error) {
+	return purego.Dlsym(handle, name)
+}
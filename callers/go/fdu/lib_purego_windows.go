@@ -0,0 +1,48 @@
+//go:build fdu_purego && windows
+
+package fdu
+
+import (
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	loadLibrarySearchDLLLoadDir  = 0x00000100
+	loadLibrarySearchDefaultDirs = 0x00001000
+)
+
+var loadLibraryExW = syscall.NewLazyDLL("kernel32.dll").NewProc("LoadLibraryExW")
+
+// openLibrary loads the DLL at path. For a path with a directory, the DLLs it depends on
+// are searched in its directory and the default directories, i.e. those of
+// SetDefaultDllDirectories and AddDllDirectory, so that the library may live
+// in a directory of its own and a DLL in the working directory is never
+// picked up. The flags only apply to this call, leaving the search of the
+// rest of the process alone. A bare name uses the standard search.
+func openLibrary(path string) (uintptr, error) {
+	var flags uintptr
+	if filepath.Base(path) != path {
+		// LOAD_LIBRARY_SEARCH_DLL_LOAD_DIR takes a full path only.
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return 0, err
+		}
+		path = abs
+		flags = loadLibrarySearchDLLLoadDir | loadLibrarySearchDefaultDirs
+	}
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	handle, _, err := loadLibraryExW.Call(uintptr(unsafe.Pointer(name)), 0, flags)
+	if handle == 0 {
+		return 0, err
+	}
+	return handle, nil
+}
+
+func lookupSymbol(handle uintptr, name string) (uintptr, error) {
+	return syscall.GetProcAddress(syscall.Handle(handle), name)
+}
@@ -0,0 +1,263 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"slices"
+	"time"
+)
+
+// LibraryArea is a floor or room of the library seat system.
+type LibraryArea struct {
+	ID int64 `json:"id"`
+	// Name is e.g. "三楼".
+	Name string `json:"name"`
+	// Building is e.g. "文科馆".
+	Building string `json:"building"`
+	Total    int    `json:"total"`
+	Free     int    `json:"free"`
+	// Closed reports whether the area is under maintenance, in which case it
+	// has no seat.
+	Closed bool `json:"closed"`
+}
+
+// LibrarySeat is a seat in a LibraryArea.
+type LibrarySeat struct {
+	ID int64 `json:"id"`
+	// Name is the number of the seat, e.g. "001".
+	Name     string `json:"name"`
+	Occupied bool   `json:"occupied"`
+}
+
+// BorrowRecord is a book borrowed from the library.
+type BorrowRecord struct {
+	Barcode string
+	Title   string
+	Author  string
+	// Borrowed is the day the book was borrowed, at midnight.
+	Borrowed time.Time
+	// Returned is the day the book was returned, at midnight, or zero if it
+	// is not returned yet.
+	Returned time.Time
+}
+
+type rawBorrowRecord struct {
+	Barcode string `json:"barcode"`
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+	// Borrowed and Returned are e.g. "2023-10-08".
+	Borrowed string  `json:"borrowed"`
+	Returned *string `json:"returned"`
+}
+
+// Loan is a book borrowed and not returned yet.
+type Loan struct {
+	Barcode string
+	Title   string
+	Author  string
+	// Due is the day the book must be returned by, at midnight.
+	Due time.Time
+	// Renewals is how many times the loan was renewed.
+	Renewals int
+}
+
+type rawLoan struct {
+	Barcode string `json:"barcode"`
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+	// Due is e.g. "2024-03-15", whatever the format of the OPAC.
+	Due      string `json:"due"`
+	Renewals int    `json:"renewals"`
+}
+
+// RenewRefusal is why the library refused to renew a loan.
+type RenewRefusal string
+
+const (
+	// RenewMaxRenewals is a loan renewed as many times as the library
+	// allows.
+	RenewMaxRenewals RenewRefusal = "max_renewals"
+	// RenewReserved is a book reserved by another reader.
+	RenewReserved RenewRefusal = "reserved"
+	// RenewOverdue is an overdue loan, which must be returned.
+	RenewOverdue RenewRefusal = "overdue"
+)
+
+// renewRefusals are the refusals reported by libfdu.
+var renewRefusals = []RenewRefusal{RenewMaxRenewals, RenewReserved, RenewOverdue}
+
+// RenewError is returned by Session.Renew when the library refuses to renew
+// the loan. It wraps ErrRenewRefused.
+type RenewError struct {
+	Barcode string
+	Reason  RenewRefusal
+	// Message is the answer of the library, e.g. "已达到最大续借次数".
+	Message string
+}
+
+func (e *RenewError) Error() string {
+	return "fdu: cannot renew " + e.Barcode + ": " + e.Message
+}
+
+// Unwrap returns ErrRenewRefused.
+func (e *RenewError) Unwrap() error {
+	return ErrRenewRefused
+}
+
+type rawRenewal struct {
+	// Outcome is "renewed" or one of the refusals.
+	Outcome string `json:"outcome"`
+	// Due is e.g. "2024-04-15" if renewed.
+	Due     string `json:"due"`
+	Message string `json:"message"`
+}
+
+// LibraryAreas returns the areas of the library seat system, including
+// closed ones.
+func (s *Session) LibraryAreas(ctx context.Context, opts ...CallOption) ([]LibraryArea, error) {
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduLibraryAreasAsync(ptr, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLibraryAreas([]byte(v))
+}
+
+// LibrarySeats returns the seats of the area today.
+func (s *Session) LibrarySeats(ctx context.Context, areaID int64, opts ...CallOption) ([]LibrarySeat, error) {
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduLibrarySeatsAsync(ptr, areaID, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLibrarySeats([]byte(v))
+}
+
+// BorrowHistory returns an iterator over the books borrowed, the most
+// recent first. The history is fetched a page at a time as the loop goes, and
+// stopping the loop early stops fetching:
+//
+//	for record, err := range s.BorrowHistory(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The iterator stops after yielding an error.
+func (s *Session) BorrowHistory(ctx context.Context, opts ...CallOption) iter.Seq2[BorrowRecord, error] {
+	return paged(ctx, s, "borrow history", lib.fduLibraryBorrowHistoryAsync, parseBorrowRecord, opts...)
+}
+
+// Borrowed returns the books borrowed and not returned yet. The loans are in
+// the OPAC (馆藏目录) of the library, which has its own login: each call of
+// Borrowed or Renew logs in it through the UIS session first.
+func (s *Session) Borrowed(ctx context.Context, opts ...CallOption) ([]Loan, error) {
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduLibraryLoansAsync(ptr, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLoans([]byte(v))
+}
+
+// Renew renews the loan of the book with barcode, and returns its new due
+// date. If the library refuses, e.g. because another reader reserved the
+// book, the error is a *RenewError; other failures, e.g. an unknown barcode,
+// are other errors. Renew needs AllowWrites, and is never retried.
+func (s *Session) Renew(ctx context.Context, barcode string, opts ...CallOption) (time.Time, error) {
+	if err := checkWrites(); err != nil {
+		return time.Time{}, err
+	}
+	if barcode == "" {
+		return time.Time{}, argumentError("empty barcode")
+	}
+	if err := checkCString("barcode", barcode); err != nil {
+		return time.Time{}, err
+	}
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduLibraryRenewAsync(ptr, barcode, token, id)
+	}, opts...)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseRenewal([]byte(v), barcode)
+}
+
+func parseLibraryAreas(data []byte) ([]LibraryArea, error) {
+	var areas []LibraryArea
+	if err := json.Unmarshal(data, &areas); err != nil {
+		return nil, parseError("library areas: %v", err)
+	}
+	for _, area := range areas {
+		if area.Free < 0 || area.Free > area.Total {
+			return nil, parseError("library area %d: invalid seat count %d/%d", area.ID, area.Free, area.Total)
+		}
+	}
+	return areas, nil
+}
+
+func parseLibrarySeats(data []byte) ([]LibrarySeat, error) {
+	var seats []LibrarySeat
+	if err := json.Unmarshal(data, &seats); err != nil {
+		return nil, parseError("library seats: %v", err)
+	}
+	return seats, nil
+}
+
+func parseBorrowRecord(raw rawBorrowRecord) (BorrowRecord, error) {
+	record := BorrowRecord{Barcode: raw.Barcode, Title: raw.Title, Author: raw.Author}
+	var err error
+	if record.Borrowed, err = parseDate(raw.Borrowed); err != nil {
+		return BorrowRecord{}, parseError("borrow record %s: invalid borrowed date %q", raw.Barcode, raw.Borrowed)
+	}
+	if raw.Returned != nil {
+		if record.Returned, err = parseDate(*raw.Returned); err != nil || record.Returned.Before(record.Borrowed) {
+			return BorrowRecord{}, parseError("borrow record %s: invalid returned date %q", raw.Barcode, *raw.Returned)
+		}
+	}
+	return record, nil
+}
+
+func parseLoans(data []byte) ([]Loan, error) {
+	var raws []rawLoan
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, parseError("loans: %v", err)
+	}
+	loans := make([]Loan, 0, len(raws))
+	for _, raw := range raws {
+		due, err := parseDate(raw.Due)
+		if err != nil {
+			return nil, parseError("loan %s: invalid due date %q", raw.Barcode, raw.Due)
+		}
+		if raw.Renewals < 0 {
+			return nil, parseError("loan %s: invalid renewals %d", raw.Barcode, raw.Renewals)
+		}
+		loans = append(loans, Loan{Barcode: raw.Barcode, Title: raw.Title, Author: raw.Author, Due: due, Renewals: raw.Renewals})
+	}
+	return loans, nil
+}
+
+// parseRenewal returns the new due date of a renewal of barcode, or a
+// *RenewError if it was refused.
+func parseRenewal(data []byte, barcode string) (time.Time, error) {
+	var raw rawRenewal
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return time.Time{}, parseError("renewal: %v", err)
+	}
+	if raw.Outcome != "renewed" {
+		if !slices.Contains(renewRefusals, RenewRefusal(raw.Outcome)) {
+			return time.Time{}, parseError("renewal: unknown outcome %q", raw.Outcome)
+		}
+		return time.Time{}, &RenewError{Barcode: barcode, Reason: RenewRefusal(raw.Outcome), Message: raw.Message}
+	}
+	due, err := parseDate(raw.Due)
+	if err != nil {
+		return time.Time{}, parseError("renewal: invalid due date %q", raw.Due)
+	}
+	return due, nil
+}
@@ -0,0 +1,235 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLibraryAreas(t *testing.T) {
+	data, err := os.ReadFile("testdata/library_areas.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	areas, err := parseLibraryAreas(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(areas) != 3 {
+		t.Fatalf("got %d areas, want 3", len(areas))
+	}
+	want := LibraryArea{ID: 5, Name: "三楼", Building: "文科馆", Total: 120, Free: 90}
+	if areas[0] != want {
+		t.Errorf("got %+v, want %+v", areas[0], want)
+	}
+	// Areas under maintenance are kept.
+	if closed := areas[1]; !closed.Closed || closed.Total != 0 {
+		t.Errorf("got %+v", closed)
+	}
+	// A full area is not closed.
+	if full := areas[2]; full.Closed || full.Free != 0 {
+		t.Errorf("got %+v", full)
+	}
+
+	if _, err := parseLibraryAreas([]byte(`[{"id": 1, "total": 10, "free": 11}]`)); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v, want ErrParse", err)
+	}
+}
+
+func TestParseLibrarySeats(t *testing.T) {
+	data, err := os.ReadFile("testdata/library_seats.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seats, err := parseLibrarySeats(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LibrarySeat{{101, "001", false}, {102, "002", true}, {103, "003", true}}
+	if !reflect.DeepEqual(seats, want) {
+		t.Errorf("got %+v, want %+v", seats, want)
+	}
+	if _, err := parseLibrarySeats([]byte(`{"id": 1}`)); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v, want ErrParse", err)
+	}
+}
+
+func TestParseBorrowRecord(t *testing.T) {
+	returned := "2023-11-02"
+	record, err := parseBorrowRecord(rawBorrowRecord{Barcode: "0001", Title: "数学分析", Borrowed: "2023-10-08", Returned: &returned})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2023, 10, 8, 0, 0, 0, 0, chinaTime); !record.Borrowed.Equal(want) {
+		t.Errorf("got borrowed %v, want %v", record.Borrowed, want)
+	}
+	if want := time.Date(2023, 11, 2, 0, 0, 0, 0, chinaTime); !record.Returned.Equal(want) {
+		t.Errorf("got returned %v, want %v", record.Returned, want)
+	}
+
+	// Not returned yet.
+	record, err = parseBorrowRecord(rawBorrowRecord{Barcode: "0002", Borrowed: "2023-10-09"})
+	if err != nil || !record.Returned.IsZero() {
+		t.Errorf("got (%+v, %v), want a zero Returned", record, err)
+	}
+	if _, err := parseBorrowRecord(rawBorrowRecord{Barcode: "0003", Borrowed: "10/09"}); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v, want ErrParse", err)
+	}
+}
+
+func TestBorrowed(t *testing.T) {
+	data, err := os.ReadFile("testdata/loans.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	orig := lib.fduLibraryLoansAsync
+	t.Cleanup(func() { lib.fduLibraryLoansAsync = orig })
+	lib.fduLibraryLoansAsync = func(_ *cSession, token *cCancelToken, requestID uint64) *cResult {
+		return lib.fduTestResultAsync(string(data), 0, 0, token, requestID)
+	}
+
+	loans, err := s.Borrowed(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Loan{
+		{Barcode: "0001", Title: "数学分析", Author: "陈纪修", Due: time.Date(2024, 3, 15, 0, 0, 0, 0, chinaTime), Renewals: 1},
+		{Barcode: "0002", Title: "线性代数", Due: time.Date(2024, 4, 2, 0, 0, 0, 0, chinaTime)},
+	}
+	if !reflect.DeepEqual(loans, want) {
+		t.Errorf("got %+v, want %+v", loans, want)
+	}
+
+	for name, data := range map[string]string{
+		"not json":          `<html>`,
+		"unnormalized date": `[{"barcode": "0001", "due": "2024年3月15日"}]`,
+		"negative renewals": `[{"barcode": "0001", "due": "2024-03-15", "renewals": -1}]`,
+	} {
+		if _, err := parseLoans([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func TestRenew(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	orig := lib.fduLibraryRenewAsync
+	t.Cleanup(func() { lib.fduLibraryRenewAsync = orig })
+	var answer string
+	calls := 0
+	lib.fduLibraryRenewAsync = func(_ *cSession, _ string, token *cCancelToken, requestID uint64) *cResult {
+		calls++
+		return lib.fduTestResultAsync(answer, 0, 0, token, requestID)
+	}
+	ctx := context.Background()
+
+	if _, err := s.Renew(ctx, "0001"); !errors.Is(err, ErrWritesNotAllowed) || calls != 0 {
+		t.Fatalf("got %v after %d calls, want ErrWritesNotAllowed", err, calls)
+	}
+	allowTestWrites(t)
+	if _, err := s.Renew(ctx, ""); !errors.Is(err, ErrInvalidArgument) || calls != 0 {
+		t.Fatalf("got %v after %d calls, want ErrInvalidArgument", err, calls)
+	}
+
+	answer = `{"outcome": "renewed", "due": "2024-04-15", "message": "续借成功"}`
+	due, err := s.Renew(ctx, "0001")
+	if want := time.Date(2024, 4, 15, 0, 0, 0, 0, chinaTime); err != nil || !due.Equal(want) {
+		t.Errorf("got (%v, %v), want %v", due, err, want)
+	}
+
+	for reason, message := range map[RenewRefusal]string{
+		RenewMaxRenewals: "已达到最大续借次数",
+		RenewReserved:    "该书已被他人预约，不能续借",
+		RenewOverdue:     "图书已超期，请先归还",
+	} {
+		answer = `{"outcome": "` + string(reason) + `", "due": "", "message": "` + message + `"}`
+		due, err := s.Renew(ctx, "0001")
+		var refused *RenewError
+		if !errors.As(err, &refused) || !errors.Is(err, ErrRenewRefused) || !due.IsZero() {
+			t.Fatalf("%s: got (%v, %v), want a *RenewError", reason, due, err)
+		}
+		if want := (RenewError{Barcode: "0001", Reason: reason, Message: message}); *refused != want {
+			t.Errorf("got %+v, want %+v", *refused, want)
+		}
+	}
+
+	for name, data := range map[string]string{
+		"unknown outcome": `{"outcome": "lost"}`,
+		"no due date":     `{"outcome": "renewed", "due": ""}`,
+	} {
+		if _, err := parseRenewal([]byte(data), "0001"); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func FuzzParseLibraryAreas(f *testing.F) {
+	fuzzParse(f, []string{"library_areas.json"}, nil, parseLibraryAreas, func(t *testing.T, areas []LibraryArea) {
+		for _, area := range areas {
+			if area.Free < 0 || area.Free > area.Total {
+				t.Fatalf("accepted the invalid area %+v", area)
+			}
+		}
+	})
+}
+
+func FuzzParseLibrarySeats(f *testing.F) {
+	fuzzParse(f, []string{"library_seats.json"}, nil, parseLibrarySeats, nil)
+}
+
+func FuzzParseBorrowRecord(f *testing.F) {
+	// As the items of a page of the borrow history, see paged.
+	parse := func(data []byte) (BorrowRecord, error) {
+		var raw rawBorrowRecord
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return BorrowRecord{}, parseError("borrow history: %v", err)
+		}
+		return parseBorrowRecord(raw)
+	}
+	fuzzParse(f, nil, []string{
+		`{"barcode": "0001", "title": "数学分析", "author": "陈纪修", "borrowed": "2023-10-08", "returned": "2023-11-02"}`,
+		`{"barcode": "0002", "borrowed": "2023-10-09", "returned": null}`,
+	}, parse, func(t *testing.T, record BorrowRecord) {
+		if record.Borrowed.Year() < minYear || !record.Returned.IsZero() && record.Returned.Before(record.Borrowed) {
+			t.Fatalf("accepted the invalid record %+v", record)
+		}
+	})
+}
+
+func FuzzParseLoans(f *testing.F) {
+	fuzzParse(f, []string{"loans.json"}, nil, parseLoans, func(t *testing.T, loans []Loan) {
+		for _, loan := range loans {
+			if loan.Due.Year() < minYear || loan.Renewals < 0 {
+				t.Fatalf("accepted the invalid loan %+v", loan)
+			}
+		}
+	})
+}
+
+func FuzzParseRenewal(f *testing.F) {
+	parse := func(data []byte) (time.Time, error) {
+		due, err := parseRenewal(data, "0001")
+		var refused *RenewError
+		if errors.As(err, &refused) {
+			return time.Time{}, nil
+		}
+		return due, err
+	}
+	fuzzParse(f, nil, []string{
+		`{"outcome": "renewed", "due": "2024-04-15", "message": "续借成功"}`,
+		`{"outcome": "reserved", "due": "", "message": "该书已被他人预约"}`,
+	}, parse, nil)
+}
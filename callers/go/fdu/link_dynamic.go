@@ -0,0 +1,12 @@
+//go:build !fdu_purego && !fdu_static
+
+package fdu
+
+// Link against the shared library, see the package documentation.
+
+/*
+#cgo LDFLAGS: -L${SRCDIR}/../../../target/debug -L${SRCDIR}/../../../target/release -lfdu
+#cgo linux darwin LDFLAGS: -Wl,-rpath,${SRCDIR}/../../../target/debug -Wl,-rpath,${SRCDIR}/../../../target/release
+#cgo linux LDFLAGS: -Wl,-rpath,$ORIGIN
+*/
+import "C"
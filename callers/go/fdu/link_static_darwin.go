@@ -0,0 +1,13 @@
+//go:build fdu_static && !fdu_purego
+
+package fdu
+
+// Link against target/release/libfdu.a, with the frameworks of the system
+// used by reqwest, e.g. for the proxy settings, and the libraries of the Rust
+// standard library on macOS.
+
+/*
+#cgo LDFLAGS: ${SRCDIR}/../../../target/release/libfdu.a
+#cgo LDFLAGS: -framework CoreFoundation -framework Security -framework SystemConfiguration -liconv -lm
+*/
+import "C"
@@ -0,0 +1,11 @@
+//go:build fdu_static && !fdu_purego
+
+package fdu
+
+// Link against target/release/libfdu.a, with the system libraries the Rust
+// standard library needs on Linux.
+
+/*
+#cgo LDFLAGS: ${SRCDIR}/../../../target/release/libfdu.a -lpthread -ldl -lm
+*/
+import "C"
@@ -0,0 +1,89 @@
+//go:build fdu_static && !fdu_purego
+
+package fdu
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestStaticLink checks that the test executable does not load the shared
+// library, and logs how it compares with the one linking the shared library.
+func TestStaticLink(t *testing.T) {
+	if maps, err := os.ReadFile("/proc/self/maps"); err == nil && bytes.Contains(maps, []byte("/libfdu.")) {
+		t.Fatal("the shared library of libfdu is loaded")
+	}
+	if testing.Short() {
+		t.Skip("skipping the comparison with the dynamic build in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go tool to build the dynamic test executable")
+	}
+	// Build both the same way, since go test strips the executable it runs.
+	dir := t.TempDir()
+	static, dynamic := filepath.Join(dir, "static.test"), filepath.Join(dir, "dynamic.test")
+	if out, err := exec.Command(goTool, "test", "-c", "-tags", "fdu_static", "-o", static, ".").CombinedOutput(); err != nil {
+		t.Fatalf("cannot build the static test executable: %v\n%s", err, out)
+	}
+	if out, err := exec.Command(goTool, "test", "-c", "-o", dynamic, ".").CombinedOutput(); err != nil {
+		t.Skipf("cannot build the dynamic test executable, is the shared library built? %v\n%s", err, out)
+	}
+
+	t.Logf("static:  %6.1f MB, starts in %v", megabytes(t, static), startup(t, static))
+	library := "the shared library"
+	if path := sharedLibrary(); path != "" {
+		library = filepath.Base(path) + fmt.Sprintf(" (%.1f MB)", megabytes(t, path))
+	}
+	t.Logf("dynamic: %6.1f MB + %s, starts in %v", megabytes(t, dynamic), library, startup(t, dynamic))
+}
+
+func megabytes(t *testing.T, path string) float64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return float64(info.Size()) / (1 << 20)
+}
+
+// startup returns the shortest time, out of a few runs, the test executable
+// at path takes to start, run TestMain, which calls Init, and exit.
+func startup(t *testing.T, path string) time.Duration {
+	t.Helper()
+	best := time.Duration(math.MaxInt64)
+	for range 5 {
+		start := time.Now()
+		if out, err := exec.Command(path, "-test.run=^$").CombinedOutput(); err != nil {
+			t.Fatalf("%s: %v\n%s", path, err, out)
+		}
+		best = min(best, time.Since(start))
+	}
+	return best
+}
+
+// sharedLibrary returns the path of the shared library in the target
+// directories, as linked by link_dynamic.go, or "" if there is none there.
+func sharedLibrary() string {
+	name := "libfdu.so"
+	switch runtime.GOOS {
+	case "darwin":
+		name = "libfdu.dylib"
+	case "windows":
+		name = "fdu.dll"
+	}
+	for _, dir := range []string{"debug", "release"} {
+		path := filepath.Join("..", "..", "..", "target", dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
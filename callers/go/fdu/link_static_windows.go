@@ -0,0 +1,13 @@
+//go:build fdu_static && !fdu_purego
+
+package fdu
+
+// Link against target/release/libfdu.a, built for the windows-gnu target
+// since cgo uses MinGW, with the system libraries of the Rust standard library
+// and of the sockets and certificates of reqwest.
+
+/*
+#cgo LDFLAGS: ${SRCDIR}/../../../target/release/libfdu.a
+#cgo LDFLAGS: -lws2_32 -lbcrypt -luserenv -lntdll -ladvapi32 -lcrypt32 -lsecur32 -liphlpapi
+*/
+import "C"
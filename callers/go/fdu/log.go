@@ -0,0 +1,90 @@
+package fdu
+
+import (
+	"fmt"
+	"slices"
+	"sync/atomic"
+)
+
+//go:generate go run ../internal/gen -enum FduLogLevel -prefix FDU_LOG_LEVEL_ -type Level -pkg fdu -o loglevel_gen.go bindings.h
+
+var (
+	logger atomic.Pointer[func(level Level, msg string)]
+	// logLevel is guarded by initMu, like the state of the library.
+	logLevel = LevelInfo
+)
+
+// SetLogger sends the log records of libfdu, among which those of UIS logins,
+// to fn, or stops them if fn is nil. Only the records up to the level set by
+// SetLogLevel are sent, LevelInfo by default. For example, with log/slog:
+//
+//	fdu.SetLogger(func(level fdu.Level, msg string) {
+//		slog.Info(msg, "source", "libfdu", "level", level)
+//	})
+//
+// fn is called synchronously from the threads of libfdu, possibly from several
+// at the same time, so it must be safe for concurrent use, return quickly and
+// not panic. It may be called before Init, in which case the logger is set up
+// by Init.
+func SetLogger(fn func(level Level, msg string)) {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if fn == nil {
+		logger.Store(nil)
+	} else {
+		logger.Store(&fn)
+	}
+	if initialized.Load() {
+		applyLogger()
+	}
+}
+
+// SetLogLevel sets the most verbose level sent to the logger of SetLogger,
+// or stops the logs with LevelOff.
+func SetLogLevel(level Level) error {
+	if !slices.Contains(allLevels, level) {
+		return argumentError("invalid log level %d", level)
+	}
+	initMu.Lock()
+	defer initMu.Unlock()
+	logLevel = level
+	if initialized.Load() {
+		applyLogger()
+	}
+	return nil
+}
+
+// applyLogger hands the current logger and level to libfdu. initMu must be
+// held.
+func applyLogger() {
+	enabled := logger.Load() != nil
+	level := logLevel
+	if !enabled {
+		level = LevelOff
+	}
+	// It only fails on an invalid level, which SetLogLevel rejects.
+	_, _ = takeResult(lib.fduSetLogCallback(int32(level), enabled))
+}
+
+// dispatchLog is called by the log callback of the backend with a record of
+// libfdu. message is only valid during the call, so it is copied.
+func dispatchLog(level int32, message *byte) {
+	if fn := logger.Load(); fn != nil {
+		(*fn)(Level(level), goString(message))
+	}
+}
+
+// logf sends a record of the package itself to the logger of SetLogger, like
+// those of libfdu.
+func logf(level Level, format string, args ...any) {
+	fn := logger.Load()
+	if fn == nil {
+		return
+	}
+	initMu.Lock()
+	enabled := level <= logLevel
+	initMu.Unlock()
+	if enabled {
+		(*fn)(level, fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !fdu_purego
+
+package fdu
+
+// #include <stdint.h>
+import "C"
+
+import "unsafe"
+
+// goLogCallback is the FduLogCallback handed to libfdu. cgo makes it callable
+// from the threads of libfdu, which are not created by Go.
+//
+//export goLogCallback
+func goLogCallback(level C.int32_t, message *C.char) {
+	dispatchLog(int32(level), (*byte)(unsafe.Pointer(message)))
+}
@@ -0,0 +1,87 @@
+package fdu
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type logRecord struct {
+	level Level
+	msg   string
+}
+
+// captureLogs sets a logger keeping the records of testLog, until the end of
+// the test.
+func captureLogs(t *testing.T) func() []logRecord {
+	var (
+		mu      sync.Mutex
+		records []logRecord
+	)
+	SetLogger(func(level Level, msg string) {
+		// Other records may come from the library, e.g. from reqwest.
+		if !strings.Contains(msg, ": test ") {
+			return
+		}
+		mu.Lock()
+		records = append(records, logRecord{level, msg[strings.LastIndex(msg, ": ")+2:]})
+		mu.Unlock()
+	})
+	t.Cleanup(func() {
+		SetLogger(nil)
+		_ = SetLogLevel(LevelInfo)
+	})
+	return func() []logRecord {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(records)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	records := captureLogs(t)
+	if err := SetLogLevel(LevelDebug); err != nil {
+		t.Fatal(err)
+	}
+	if err := testLog(); err != nil {
+		t.Fatal(err)
+	}
+	want := []logRecord{
+		{LevelError, "test error"},
+		{LevelWarn, "test warn"},
+		{LevelInfo, "test info"},
+		{LevelDebug, "test debug"},
+	}
+	if got := records(); !slices.Equal(got, want) {
+		t.Errorf("records at LevelDebug = %v, want %v", got, want)
+	}
+
+	if err := SetLogLevel(LevelOff); err != nil {
+		t.Fatal(err)
+	}
+	if err := testLog(); err != nil {
+		t.Fatal(err)
+	}
+	if got := records(); len(got) != len(want) {
+		t.Errorf("records at LevelOff = %v, want none", got[len(want):])
+	}
+}
+
+func TestLoggerRemoved(t *testing.T) {
+	records := captureLogs(t)
+	SetLogger(nil)
+	if err := testLog(); err != nil {
+		t.Fatal(err)
+	}
+	if got := records(); len(got) != 0 {
+		t.Errorf("records without a logger = %v, want none", got)
+	}
+}
+
+func TestSetLogLevelInvalid(t *testing.T) {
+	if err := SetLogLevel(LevelTrace + 1); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("SetLogLevel(%d) = %v, want ErrInvalidArgument", LevelTrace+1, err)
+	}
+}
@@ -0,0 +1,45 @@
+// Code generated by internal/gen from bindings.h; DO NOT EDIT.
+
+package fdu
+
+import "strconv"
+
+// Level is a value of FduLogLevel.
+type Level int32
+
+const (
+	LevelOff   Level = 0 // FDU_LOG_LEVEL_OFF
+	LevelError Level = 1 // FDU_LOG_LEVEL_ERROR
+	LevelWarn  Level = 2 // FDU_LOG_LEVEL_WARN
+	LevelInfo  Level = 3 // FDU_LOG_LEVEL_INFO
+	LevelDebug Level = 4 // FDU_LOG_LEVEL_DEBUG
+	LevelTrace Level = 5 // FDU_LOG_LEVEL_TRACE
+)
+
+// allLevels lists the Level constants in the order of bindings.h.
+var allLevels = []Level{
+	LevelOff,
+	LevelError,
+	LevelWarn,
+	LevelInfo,
+	LevelDebug,
+	LevelTrace,
+}
+
+func (c Level) String() string {
+	switch c {
+	case LevelOff:
+		return "OFF"
+	case LevelError:
+		return "ERROR"
+	case LevelWarn:
+		return "WARN"
+	case LevelInfo:
+		return "INFO"
+	case LevelDebug:
+		return "DEBUG"
+	case LevelTrace:
+		return "TRACE"
+	}
+	return "Level(" + strconv.FormatInt(int64(c), 10) + ")"
+}
@@ -0,0 +1,62 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+)
+
+// rawPage is a page of a listing returned by the paged exports of libfdu.
+// Next is the token of the next page, empty after the last one. The token of
+// a page is all libfdu needs to fetch it, so a listing read partially holds
+// nothing on the side of libfdu.
+type rawPage[R any] struct {
+	Items []R    `json:"items"`
+	Next  string `json:"next"`
+}
+
+// paged returns an iterator over the items of a listing, whose pages are
+// fetched by fetch given the token of the page, starting with an empty one,
+// and whose items are converted by convert. what names the listing in
+// errors, and opts apply to the fetch of each page.
+//
+// A page is only fetched once the items of the previous one are consumed, and
+// the session is only locked while fetching, so that the loop body may call
+// other methods of the session. The iterator stops after yielding an error.
+func paged[R, T any](ctx context.Context, s *Session, what string,
+	fetch func(ptr *cSession, pageToken string, token *cCancelToken, id uint64) *cResult,
+	convert func(raw R) (T, error), opts ...CallOption) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		pageToken := ""
+		for {
+			v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+				return fetch(ptr, pageToken, token, id)
+			}, opts...)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			var page rawPage[R]
+			if err := json.Unmarshal([]byte(v), &page); err != nil {
+				yield(zero, parseError("%s: %v", what, err))
+				return
+			}
+			for _, raw := range page.Items {
+				item, err := convert(raw)
+				if !yield(item, err) || err != nil {
+					return
+				}
+			}
+			if page.Next == "" {
+				return
+			}
+			// A listing whose next page is itself would never end.
+			if page.Next == pageToken {
+				yield(zero, parseError("%s: page %q is its own next page", what, pageToken))
+				return
+			}
+			pageToken = page.Next
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// checkNoJobs checks that the jobs of the test completed, and that their
+// tokens were freed.
+func checkNoJobs(t *testing.T, baseTokens int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		jobs.mu.Lock()
+		pending := len(jobs.pending)
+		jobs.mu.Unlock()
+		live := testLiveTokens()
+		if pending == 0 && live == baseTokens {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("%d jobs pending, %d tokens leaked", pending, live-baseTokens)
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPaged(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	want := 0
+	for id, err := range s.testPages(context.Background(), 0) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Fatalf("got item %d, want %d", id, want)
+		}
+		want++
+		// The session is not locked between pages.
+		if _, err := s.testPing(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want != 100 {
+		t.Errorf("got %d items, want 100", want)
+	}
+}
+
+func TestPagedError(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	n, errs := 0, 0
+	for _, err := range s.testPages(context.Background(), 3) {
+		if err != nil {
+			errs++
+			if !errors.Is(err, ErrNetwork) {
+				t.Errorf("got %v, want ErrNetwork", err)
+			}
+			continue
+		}
+		n++
+	}
+	if n != 40 || errs != 1 {
+		t.Errorf("got %d items and %d errors, want the 2 pages before the error, then the error", n, errs)
+	}
+}
+
+func TestPagedBreak(t *testing.T) {
+	base := testLiveTokens()
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 0
+	for _, err := range s.testPages(ctx, 0) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n++; n == 30 {
+			break
+		}
+	}
+	checkNoJobs(t, base)
+	if _, err := s.testPing(); err != nil {
+		t.Errorf("session unusable after breaking: %v", err)
+	}
+}
+
+func TestPagedCancel(t *testing.T) {
+	base := testLiveTokens()
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 0
+	var last error
+	for id, err := range s.testPages(ctx, 0) {
+		if err != nil {
+			last = err
+			continue
+		}
+		n++
+		// Cancel while the second page is being fetched.
+		if id == 19 {
+			time.AfterFunc(3*time.Millisecond, cancel)
+		}
+	}
+	if n != 20 || !errors.Is(last, context.Canceled) {
+		t.Errorf("got %d items and %v, want the first page and context.Canceled", n, last)
+	}
+	checkNoJobs(t, base)
+	if _, err := s.testPing(); err != nil {
+		t.Errorf("session unusable after cancelling: %v", err)
+	}
+}
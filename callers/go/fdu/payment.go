@@ -0,0 +1,141 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/internal/qr"
+)
+
+// PaymentQRScale is the number of pixels per module of PaymentQR.PNG.
+const PaymentQRScale = 4
+
+// paymentQRRetryDelay is how long PaymentQRStream waits after a network
+// error before fetching a code again. It is a variable for the tests.
+var paymentQRRetryDelay = 5 * time.Second
+
+// PaymentQR is a payment code of the campus card (一卡通付款码), to show to
+// card readers.
+type PaymentQR struct {
+	// Code is the content of the QR code, e.g. "SWL2...".
+	Code string
+	// PNG is the QR code of Code, with PaymentQRScale pixels per module. Use
+	// Image for another size.
+	PNG []byte
+	// ExpiresAt is when card readers stop accepting the code, about a
+	// minute after it is fetched.
+	ExpiresAt time.Time
+	// validity is how long the code is valid from the time it is fetched.
+	validity time.Duration
+}
+
+type rawPaymentCode struct {
+	Code        string `json:"code"`
+	ValidMillis int64  `json:"valid_millis"`
+}
+
+// Image renders q as a PNG with scale pixels per module, including the quiet
+// zone of 4 modules around the code.
+func (q *PaymentQR) Image(scale int) ([]byte, error) {
+	c, err := qr.Encode(q.Code, qr.M)
+	if err != nil {
+		return nil, err
+	}
+	return c.PNG(scale)
+}
+
+// PaymentQR returns the current payment code. If the account has QR payment
+// disabled, the error wraps ErrQRDisabled.
+func (s *Session) PaymentQR(ctx context.Context, opts ...CallOption) (*PaymentQR, error) {
+	// The validity is counted from before the call, to be on the safe side.
+	start := time.Now()
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduCardPaymentCodeAsync(ptr, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parsePaymentQR([]byte(v), start)
+}
+
+// PaymentQRUpdate is a payment code delivered by PaymentQRStream, or the
+// error fetching it.
+type PaymentQRUpdate struct {
+	QR  *PaymentQR
+	Err error
+}
+
+// PaymentQRStream fetches payment codes until ctx is done, and delivers each
+// one on the returned channel. A code is refreshed shortly before it expires,
+// at a random time to spread the requests of many clients. A code not
+// received by then is dropped for the next one.
+//
+// Network errors are delivered and the code fetched again after a few
+// seconds. Other errors, e.g. ErrQRDisabled, are delivered last. The channel
+// is closed after the last update, or once ctx is done.
+func (s *Session) PaymentQRStream(ctx context.Context, opts ...CallOption) <-chan PaymentQRUpdate {
+	updates := make(chan PaymentQRUpdate)
+	go func() {
+		defer close(updates)
+		for {
+			q, err := s.PaymentQR(ctx, opts...)
+			if ctx.Err() != nil {
+				return
+			}
+			next := time.Now().Add(paymentQRRetryDelay)
+			if err == nil {
+				next = paymentQRRefreshAt(q, rand.Float64())
+			} else if !errors.Is(err, ErrNetwork) {
+				select {
+				case updates <- PaymentQRUpdate{Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case updates <- PaymentQRUpdate{QR: q, Err: err}:
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				}
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	return updates
+}
+
+// paymentQRRefreshAt returns when to refresh q: between 20% and 10% of its
+// validity before it expires, depending on jitter, from 0 to 1.
+func paymentQRRefreshAt(q *PaymentQR, jitter float64) time.Time {
+	lead := q.validity/10 + time.Duration(jitter*float64(q.validity/10))
+	return q.ExpiresAt.Add(-lead)
+}
+
+func parsePaymentQR(data []byte, fetched time.Time) (*PaymentQR, error) {
+	var raw rawPaymentCode
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, parseError("payment code: %v", err)
+	}
+	if raw.Code == "" || raw.ValidMillis <= 0 {
+		return nil, parseError("payment code: invalid code %q valid for %dms", raw.Code, raw.ValidMillis)
+	}
+	validity := time.Duration(raw.ValidMillis) * time.Millisecond
+	q := &PaymentQR{Code: raw.Code, ExpiresAt: fetched.Add(validity), validity: validity}
+	png, err := q.Image(PaymentQRScale)
+	if err != nil {
+		return nil, parseError("payment code %q: %v", raw.Code, err)
+	}
+	q.PNG = png
+	return q, nil
+}
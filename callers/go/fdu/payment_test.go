@@ -0,0 +1,184 @@
+package fdu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakePaymentCodes makes the payment codes come from fdu_test_result_async
+// for the rest of the test: reply returns the result of the nth call,
+// counted from 0, and how long it takes.
+func fakePaymentCodes(t *testing.T, reply func(n int) (value string, code int32, millis uint64)) {
+	orig := lib.fduCardPaymentCodeAsync
+	t.Cleanup(func() { lib.fduCardPaymentCodeAsync = orig })
+	var calls atomic.Int64
+	lib.fduCardPaymentCodeAsync = func(_ *cSession, token *cCancelToken, requestID uint64) *cResult {
+		value, code, millis := reply(int(calls.Add(1) - 1))
+		return lib.fduTestResultAsync(value, code, millis, token, requestID)
+	}
+}
+
+func paymentCode(code string, validMillis int) string {
+	return fmt.Sprintf(`{"code":%q,"valid_millis":%d}`, code, validMillis)
+}
+
+func TestPaymentQR(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	fakePaymentCodes(t, func(int) (string, int32, uint64) {
+		return paymentCode("SWL2000123456789", 60_000), 0, 10
+	})
+
+	start := time.Now()
+	q, err := s.PaymentQR(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := time.Now()
+	if q.Code != "SWL2000123456789" {
+		t.Errorf("got code %q", q.Code)
+	}
+	// The validity is counted from some time during the call.
+	if q.ExpiresAt.Before(start.Add(time.Minute)) || q.ExpiresAt.After(end.Add(time.Minute)) {
+		t.Errorf("expires %v after the call, want a minute", q.ExpiresAt.Sub(start))
+	}
+	img, err := png.Decode(bytes.NewReader(q.PNG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := q.Image(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := img.Bounds().Dx(), small.Bounds().Dx()*PaymentQRScale; got != want {
+		t.Errorf("got a PNG of width %d, want %d", got, want)
+	}
+}
+
+func TestPaymentQRDisabled(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	fakePaymentCodes(t, func(int) (string, int32, uint64) {
+		return "", int32(ErrCodeQRDisabled), 0
+	})
+
+	if _, err := s.PaymentQR(context.Background()); !errors.Is(err, ErrQRDisabled) {
+		t.Fatalf("got %v, want ErrQRDisabled", err)
+	}
+	updates := s.PaymentQRStream(context.Background())
+	if u := <-updates; !errors.Is(u.Err, ErrQRDisabled) {
+		t.Errorf("got update %+v, want ErrQRDisabled", u)
+	}
+	if u, ok := <-updates; ok {
+		t.Errorf("got update %+v after the error", u)
+	}
+}
+
+func TestPaymentQRStream(t *testing.T) {
+	baseTokens := testLiveTokens()
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	fakePaymentCodes(t, func(n int) (string, int32, uint64) {
+		return paymentCode(fmt.Sprintf("SWL2%04d", n), 200), 0, 5
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := s.PaymentQRStream(ctx)
+	var prev *PaymentQR
+	for i := 0; i < 3; i++ {
+		u := <-updates
+		if u.Err != nil {
+			t.Fatal(u.Err)
+		}
+		if prev != nil {
+			if u.QR.Code == prev.Code {
+				t.Errorf("got code %q twice", u.QR.Code)
+			}
+			// The next code is there before the previous one expires.
+			if now := time.Now(); now.After(prev.ExpiresAt) {
+				t.Errorf("got code %q %v after the previous one expired", u.QR.Code, now.Sub(prev.ExpiresAt))
+			}
+		}
+		prev = u.QR
+	}
+	cancel()
+	for range updates {
+	}
+	checkNoJobs(t, baseTokens)
+}
+
+func TestPaymentQRStreamRetry(t *testing.T) {
+	defer func(d time.Duration) { paymentQRRetryDelay = d }(paymentQRRetryDelay)
+	paymentQRRetryDelay = 10 * time.Millisecond
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	fakePaymentCodes(t, func(n int) (string, int32, uint64) {
+		if n == 0 {
+			return "", int32(ErrCodeNetwork), 0
+		}
+		return paymentCode("SWL2", 60_000), 0, 0
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := s.PaymentQRStream(ctx)
+	if u := <-updates; !errors.Is(u.Err, ErrNetwork) {
+		t.Fatalf("got update %+v, want ErrNetwork", u)
+	}
+	if u := <-updates; u.Err != nil || u.QR.Code != "SWL2" {
+		t.Fatalf("got update %+v after retrying", u)
+	}
+	cancel()
+	for range updates {
+	}
+}
+
+func TestPaymentQRRefreshAt(t *testing.T) {
+	expires := time.Date(2024, 3, 1, 12, 0, 0, 0, chinaTime)
+	q := &PaymentQR{ExpiresAt: expires, validity: time.Minute}
+	for _, tc := range []struct {
+		jitter float64
+		want   time.Duration
+	}{
+		{0, 6 * time.Second},
+		{0.5, 9 * time.Second},
+		{1, 12 * time.Second},
+	} {
+		if got := expires.Sub(paymentQRRefreshAt(q, tc.jitter)); got != tc.want {
+			t.Errorf("jitter %v: refresh %v before expiry, want %v", tc.jitter, got, tc.want)
+		}
+	}
+}
+
+func FuzzParsePaymentQR(f *testing.F) {
+	parse := func(data []byte) (*PaymentQR, error) {
+		return parsePaymentQR(data, time.Now())
+	}
+	fuzzParse(f, nil, []string{paymentCode("2089476612345678", 30000), paymentCode("", 30000)}, parse, func(t *testing.T, q *PaymentQR) {
+		if q.Code == "" || len(q.PNG) == 0 {
+			t.Fatalf("accepted the invalid code %+v", q)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// CheckInKind is the kind of a PECheckIn.
+type CheckInKind string
+
+const (
+	// CheckInMorning is a morning exercise (早锻炼).
+	CheckInMorning CheckInKind = "morning"
+	// CheckInGym is a session at a gym (场馆).
+	CheckInGym CheckInKind = "gym"
+	// CheckInOther is any other kind the PE system may add.
+	CheckInOther CheckInKind = "other"
+)
+
+// PERecords are the PE check-ins (体锻打卡) of this semester.
+type PERecords struct {
+	// Morning and Gym are the numbers of valid check-ins of each kind, as
+	// counted by the PE system.
+	Morning int
+	Gym     int
+	// CheckIns include the invalid ones.
+	CheckIns []PECheckIn
+}
+
+// PECheckIn is a check-in at a morning exercise or a gym.
+type PECheckIn struct {
+	Kind CheckInKind
+	// Place is e.g. "江湾体育馆".
+	Place string
+	Time  time.Time
+	// Valid is false for check-ins not counted, e.g. too short ones.
+	Valid bool
+}
+
+// PETestScores are the scores of the latest fitness test (体测).
+type PETestScores struct {
+	// Year is the school year of the test, e.g. "2023".
+	Year string
+	// Exempt reports whether the student is exempted from the test (免测),
+	// in which case Total is 0 and Items is nil. A student not tested yet
+	// is not exempt, and has no item either.
+	Exempt bool
+	Total  float64
+	// Level is e.g. "良好".
+	Level string
+	Items []FitnessItem
+}
+
+// FitnessItem is an item of the fitness test.
+type FitnessItem struct {
+	// Name is e.g. "50米跑".
+	Name string
+	// Result is the measure in Unit, e.g. "7.2" and "秒".
+	Result string
+	Unit   string
+	Score  float64
+	Level  string
+}
+
+type rawPERecords struct {
+	Morning  int `json:"morning"`
+	Gym      int `json:"gym"`
+	CheckIns []struct {
+		Kind  CheckInKind `json:"kind"`
+		Place string      `json:"place"`
+		// Time is e.g. "2023-10-09 07:12:33".
+		Time  string `json:"time"`
+		Valid bool   `json:"valid"`
+	} `json:"check_ins"`
+}
+
+type rawPETestScores struct {
+	Year   string   `json:"year"`
+	Exempt bool     `json:"exempt"`
+	Total  *float64 `json:"total"`
+	Level  string   `json:"level"`
+	Items  []struct {
+		Name   string  `json:"name"`
+		Result string  `json:"result"`
+		Unit   string  `json:"unit"`
+		Score  float64 `json:"score"`
+		Level  string  `json:"level"`
+	} `json:"items"`
+}
+
+// PERecords returns the PE check-ins of this semester.
+func (s *Session) PERecords(ctx context.Context, opts ...CallOption) (*PERecords, error) {
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduPERecordsAsync(ptr, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parsePERecords([]byte(v))
+}
+
+// PETestScores returns the scores of the latest fitness test. See
+// PETestScores.Exempt for exempted students.
+func (s *Session) PETestScores(ctx context.Context, opts ...CallOption) (*PETestScores, error) {
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduPETestScoresAsync(ptr, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parsePETestScores([]byte(v))
+}
+
+func parsePERecords(data []byte) (*PERecords, error) {
+	var raw rawPERecords
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, parseError("PE records: %v", err)
+	}
+	if raw.Morning < 0 || raw.Gym < 0 {
+		return nil, parseError("PE records: invalid counts %d and %d", raw.Morning, raw.Gym)
+	}
+	records := &PERecords{Morning: raw.Morning, Gym: raw.Gym, CheckIns: make([]PECheckIn, 0, len(raw.CheckIns))}
+	for _, r := range raw.CheckIns {
+		switch r.Kind {
+		case CheckInMorning, CheckInGym, CheckInOther:
+		default:
+			return nil, parseError("PE check-in: invalid kind %q", r.Kind)
+		}
+		tm, err := parseChinaTime(time.DateTime, r.Time)
+		if err != nil {
+			return nil, parseError("PE check-in: invalid time %q", r.Time)
+		}
+		records.CheckIns = append(records.CheckIns, PECheckIn{Kind: r.Kind, Place: r.Place, Time: tm, Valid: r.Valid})
+	}
+	return records, nil
+}
+
+func parsePETestScores(data []byte) (*PETestScores, error) {
+	var raw rawPETestScores
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, parseError("PE test scores: %v", err)
+	}
+	scores := &PETestScores{Year: raw.Year, Exempt: raw.Exempt, Level: raw.Level}
+	if raw.Exempt {
+		if raw.Total != nil || len(raw.Items) > 0 {
+			return nil, parseError("PE test scores %s: scores of an exempted student", raw.Year)
+		}
+		return scores, nil
+	}
+	if raw.Total != nil {
+		if *raw.Total < 0 {
+			return nil, parseError("PE test scores %s: invalid total %v", raw.Year, *raw.Total)
+		}
+		scores.Total = *raw.Total
+	}
+	scores.Items = make([]FitnessItem, 0, len(raw.Items))
+	for _, item := range raw.Items {
+		if item.Score < 0 {
+			return nil, parseError("PE test item %s: invalid score %v", item.Name, item.Score)
+		}
+		scores.Items = append(scores.Items, FitnessItem(item))
+	}
+	return scores, nil
+}
@@ -0,0 +1,122 @@
+package fdu
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParsePERecords(t *testing.T) {
+	data, err := os.ReadFile("testdata/pe_records.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := parsePERecords(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records.Morning != 1 || records.Gym != 1 {
+		t.Errorf("got %d morning and %d gym check-ins, want 1 and 1", records.Morning, records.Gym)
+	}
+	if len(records.CheckIns) != 4 {
+		t.Fatalf("got %d check-ins, want 4", len(records.CheckIns))
+	}
+	morning := records.CheckIns[0]
+	if morning.Kind != CheckInMorning || morning.Place != "江湾体育馆" || !morning.Valid {
+		t.Errorf("got %+v", morning)
+	}
+	if want := time.Date(2023, 10, 9, 7, 12, 33, 0, chinaTime); !morning.Time.Equal(want) {
+		t.Errorf("got time %v, want %v", morning.Time, want)
+	}
+	// Invalid check-ins are listed but not counted.
+	if gym := records.CheckIns[2]; gym.Kind != CheckInGym || gym.Valid {
+		t.Errorf("got %+v", gym)
+	}
+	if other := records.CheckIns[3]; other.Kind != CheckInOther {
+		t.Errorf("got %+v", other)
+	}
+}
+
+func TestParsePETestScores(t *testing.T) {
+	data, err := os.ReadFile("testdata/pe_test_scores.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores, err := parsePETestScores(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores.Exempt || scores.Year != "2023" || scores.Total != 82.5 || scores.Level != "良好" {
+		t.Errorf("got %+v", scores)
+	}
+	if len(scores.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(scores.Items))
+	}
+	want := FitnessItem{Name: "50米跑", Result: "7.2", Unit: "秒", Score: 80, Level: "良好"}
+	if scores.Items[0] != want {
+		t.Errorf("got %+v, want %+v", scores.Items[0], want)
+	}
+}
+
+func TestParsePETestScoresExempt(t *testing.T) {
+	data, err := os.ReadFile("testdata/pe_test_scores_exempt.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores, err := parsePETestScores(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scores.Exempt || scores.Total != 0 || scores.Items != nil {
+		t.Errorf("got %+v", scores)
+	}
+
+	// Not tested yet, which is not exempt.
+	scores, err = parsePETestScores([]byte(`{"year": "2024", "exempt": false, "total": null, "level": "", "items": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores.Exempt || scores.Items == nil || len(scores.Items) != 0 {
+		t.Errorf("got %+v", scores)
+	}
+}
+
+func TestParsePEInvalid(t *testing.T) {
+	records := map[string]string{
+		"not json":       `<html>`,
+		"negative count": `{"morning": -1, "gym": 0, "check_ins": []}`,
+		"bad kind":       `{"morning": 0, "gym": 0, "check_ins": [{"kind": "swim", "time": "2023-10-09 07:12:33"}]}`,
+		"bad time":       `{"morning": 0, "gym": 0, "check_ins": [{"kind": "gym", "time": "2023/10/09"}]}`,
+	}
+	for name, data := range records {
+		if _, err := parsePERecords([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("records %s: got %v, want ErrParse", name, err)
+		}
+	}
+	scores := map[string]string{
+		"not json":        `<html>`,
+		"exempt and item": `{"year": "2023", "exempt": true, "items": [{"name": "50米跑"}]}`,
+	}
+	for name, data := range scores {
+		if _, err := parsePETestScores([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("scores %s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func FuzzParsePERecords(f *testing.F) {
+	fuzzParse(f, []string{"pe_records.json"}, nil, parsePERecords, func(t *testing.T, records *PERecords) {
+		if records.Morning < 0 || records.Gym < 0 {
+			t.Fatalf("accepted the invalid records %+v", records)
+		}
+	})
+}
+
+func FuzzParsePETestScores(f *testing.F) {
+	fuzzParse(f, []string{"pe_test_scores.json", "pe_test_scores_exempt.json"}, nil, parsePETestScores, func(t *testing.T, scores *PETestScores) {
+		if scores.Total < 0 || scores.Exempt && len(scores.Items) > 0 {
+			t.Fatalf("accepted the invalid scores %+v", scores)
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package fdu
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultValidateAfter is the default SessionPool.ValidateAfter.
+const DefaultValidateAfter = 10 * time.Minute
+
+// SessionPool holds the sessions of several accounts, e.g. for a bot acting
+// for many students. It logs in to an account on first use, logs in again
+// when the session has expired, and keeps at most a given number of
+// sessions, closing the least recently used ones.
+//
+// A session is reference counted: one evicted while borrowed is only closed
+// once the last borrower releases it.
+//
+// A SessionPool is safe for concurrent use by multiple goroutines.
+type SessionPool struct {
+	// ValidateAfter is how long a session may stay unused before the pool
+	// checks it with Session.Valid when it is acquired again. Zero or
+	// negative disables the check. It must not be changed once the pool is
+	// in use.
+	ValidateAfter time.Duration
+
+	login       func(ctx context.Context, account string) (*Session, error)
+	maxSessions int
+
+	// mu guards the fields below and the fields of the entries.
+	mu      sync.Mutex
+	entries map[string]*poolEntry
+	// lru holds the entries of entries, the most recently used first.
+	lru    *list.List
+	closed bool
+	// closing are the sessions to close once mu is released, since Close
+	// waits for the call in flight on the session, if any.
+	closing []*Session
+}
+
+type poolEntry struct {
+	account string
+	elem    *list.Element
+	// ready is closed once the login is done, setting session or err.
+	ready   chan struct{}
+	session *Session
+	err     error
+	// refs is the number of borrowers, including those waiting for ready.
+	refs int
+	// removed is set once the entry is no longer in the pool: its session
+	// is closed by the last borrower.
+	removed bool
+	// checked is when the session was last known to be valid, and checking
+	// is set while a borrower checks it.
+	checked  time.Time
+	checking bool
+}
+
+// NewSessionPool returns a pool of at most maxSessions sessions, or
+// unbounded if maxSessions is 0, which logs in to an account with login,
+// e.g. by calling Login with the credentials of the account.
+func NewSessionPool(maxSessions int, login func(ctx context.Context, account string) (*Session, error)) *SessionPool {
+	return &SessionPool{
+		ValidateAfter: DefaultValidateAfter,
+		login:         login,
+		maxSessions:   maxSessions,
+		entries:       make(map[string]*poolEntry),
+		lru:           list.New(),
+	}
+}
+
+// CredentialStore holds the passwords of accounts by student ID, e.g. the
+// stores of the package credentials, see SessionPool.WithStore.
+type CredentialStore interface {
+	Load(studentID string) (password string, err error)
+}
+
+// WithStore makes p log in to an account, a student ID, with Login and the
+// password of the account in store, instead of the login function given to
+// NewSessionPool, which may then be nil, and returns p:
+//
+//	pool := fdu.NewSessionPool(0, nil).WithStore(credentials.NewKeychain(""))
+//
+// so that the caller only handles the password once, to save it in store.
+// The error of store, e.g. wrapping credentials.ErrNotFound for an account
+// without a password, is returned by Acquire. It must be called before the
+// pool is used.
+func (p *SessionPool) WithStore(store CredentialStore) *SessionPool {
+	p.login = func(ctx context.Context, account string) (*Session, error) {
+		password, err := store.Load(account)
+		if err != nil {
+			return nil, fmt.Errorf("fdu: password of %s: %w", account, err)
+		}
+		return Login(ctx, account, password)
+	}
+	return p
+}
+
+// Acquire returns the session of account, logging in if needed, and a
+// function to call when done with it. The session must not be used or
+// closed after release is called.
+func (p *SessionPool) Acquire(ctx context.Context, account string) (s *Session, release func(), err error) {
+	for {
+		e, first, err := p.borrow(account)
+		if err != nil {
+			return nil, nil, err
+		}
+		if first {
+			s, err := p.login(ctx, account)
+			p.mu.Lock()
+			e.session, e.err, e.checked = s, err, time.Now()
+			if err != nil {
+				p.remove(e)
+			}
+			close(e.ready)
+			p.unlock()
+		}
+
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			p.release(e)
+			return nil, nil, ctx.Err()
+		}
+		if e.err != nil {
+			p.release(e)
+			// The login of another borrower may have been cancelled by its
+			// own context: try again with ours.
+			if !first && ctx.Err() == nil && (errors.Is(e.err, context.Canceled) || errors.Is(e.err, context.DeadlineExceeded)) {
+				continue
+			}
+			return nil, nil, e.err
+		}
+
+		// A session logged in by this call is fresh enough.
+		if !first && p.shouldCheck(e) {
+			valid, err := e.session.Valid(ctx)
+			p.mu.Lock()
+			e.checking = false
+			if valid {
+				e.checked = time.Now()
+			} else if err == nil {
+				// Expired: log in again with a new entry.
+				p.remove(e)
+			}
+			p.unlock()
+			if !valid && err == nil {
+				p.release(e)
+				continue
+			}
+		}
+		return e.session, sync.OnceFunc(func() { p.release(e) }), nil
+	}
+}
+
+// Do calls fn with the session of account, like Acquire. If fn fails with
+// ErrAuthFailed, e.g. because the session was logged out elsewhere, the
+// session is dropped and fn is called once again with a new login.
+func (p *SessionPool) Do(ctx context.Context, account string, fn func(s *Session) error) error {
+	for retry := true; ; retry = false {
+		s, release, err := p.Acquire(ctx, account)
+		if err != nil {
+			return err
+		}
+		err = fn(s)
+		if retry && errors.Is(err, ErrAuthFailed) {
+			p.drop(account, s)
+			release()
+			continue
+		}
+		release()
+		return err
+	}
+}
+
+// Close closes the sessions of the pool, and makes Acquire return
+// ErrPoolClosed. Sessions still borrowed are closed when released.
+func (p *SessionPool) Close() error {
+	p.mu.Lock()
+	defer p.unlock()
+	p.closed = true
+	for _, e := range p.entries {
+		p.remove(e)
+	}
+	return nil
+}
+
+// Len returns the number of sessions in the pool, including those logging
+// in.
+func (p *SessionPool) Len() int {
+	p.mu.Lock()
+	defer p.unlock()
+	return len(p.entries)
+}
+
+// borrow returns the entry of account with a new reference, creating it if
+// needed, in which case first is set and the caller must log in.
+func (p *SessionPool) borrow(account string) (e *poolEntry, first bool, err error) {
+	p.mu.Lock()
+	defer p.unlock()
+	if p.closed {
+		return nil, false, ErrPoolClosed
+	}
+	e, ok := p.entries[account]
+	if ok {
+		p.lru.MoveToFront(e.elem)
+	} else {
+		e = &poolEntry{account: account, ready: make(chan struct{})}
+		e.elem = p.lru.PushFront(e)
+		p.entries[account] = e
+		for p.maxSessions > 0 && p.lru.Len() > p.maxSessions {
+			p.remove(p.lru.Back().Value.(*poolEntry))
+		}
+	}
+	e.refs++
+	return e, !ok, nil
+}
+
+// shouldCheck reports whether the caller must check the session of e, which
+// is ready, and marks it as being checked if so.
+func (p *SessionPool) shouldCheck(e *poolEntry) bool {
+	if p.ValidateAfter <= 0 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.unlock()
+	if e.checking || e.removed || time.Since(e.checked) < p.ValidateAfter {
+		return false
+	}
+	e.checking = true
+	return true
+}
+
+// drop removes the entry of account if it still holds s.
+func (p *SessionPool) drop(account string, s *Session) {
+	p.mu.Lock()
+	defer p.unlock()
+	if e, ok := p.entries[account]; ok && e.session == s {
+		p.remove(e)
+	}
+}
+
+// remove takes e out of the pool, closing its session if it is not
+// borrowed. p.mu must be held, and released with unlock.
+func (p *SessionPool) remove(e *poolEntry) {
+	if e.removed {
+		return
+	}
+	e.removed = true
+	delete(p.entries, e.account)
+	p.lru.Remove(e.elem)
+	if e.refs == 0 && e.session != nil {
+		p.closing = append(p.closing, e.session)
+	}
+}
+
+// release drops a reference to e, closing its session if it was the last one
+// and e is no longer in the pool.
+func (p *SessionPool) release(e *poolEntry) {
+	p.mu.Lock()
+	defer p.unlock()
+	e.refs--
+	if e.refs == 0 && e.removed && e.session != nil {
+		p.closing = append(p.closing, e.session)
+	}
+}
+
+// unlock releases p.mu and closes the sessions removed in the meantime.
+func (p *SessionPool) unlock() {
+	closing := p.closing
+	p.closing = nil
+	p.mu.Unlock()
+	for _, s := range closing {
+		s.Close()
+	}
+}
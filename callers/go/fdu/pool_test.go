@@ -0,0 +1,209 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu/credentials"
+)
+
+// newTestPool returns a pool logging in with testSession, and the number of
+// logins so far.
+func newTestPool(t *testing.T, maxSessions int) (*SessionPool, *atomic.Int64) {
+	base := testLiveSessions()
+	var logins atomic.Int64
+	p := NewSessionPool(maxSessions, func(ctx context.Context, account string) (*Session, error) {
+		logins.Add(1)
+		return testSession()
+	})
+	t.Cleanup(func() {
+		p.Close()
+		if live := testLiveSessions(); live != base {
+			t.Errorf("%d sessions leaked", live-base)
+		}
+	})
+	return p, &logins
+}
+
+func TestSessionPoolConcurrent(t *testing.T) {
+	const accounts, goroutines, calls = 10, 50, 20
+	p, logins := newTestPool(t, accounts)
+
+	var wg sync.WaitGroup
+	for i := 0; i < accounts; i++ {
+		account := fmt.Sprint("account", i)
+		for j := 0; j < goroutines; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := 0; k < calls; k++ {
+					err := p.Do(context.Background(), account, func(s *Session) error {
+						_, err := s.testPing()
+						return err
+					})
+					if err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if n := logins.Load(); n != accounts {
+		t.Errorf("logged in %d times, want %d", n, accounts)
+	}
+	for i := 0; i < accounts; i++ {
+		err := p.Do(context.Background(), fmt.Sprint("account", i), func(s *Session) error {
+			if n, err := s.testPing(); err != nil || n != goroutines*calls+1 {
+				return fmt.Errorf("got (%d, %v), want %d", n, err, goroutines*calls+1)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("account %d: %v", i, err)
+		}
+	}
+}
+
+func TestSessionPoolEviction(t *testing.T) {
+	p, logins := newTestPool(t, 2)
+	ctx := context.Background()
+
+	a, releaseA, err := p.Acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, releaseB, err := p.Acquire(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseB()
+	_, releaseC, err := p.Acquire(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseC()
+	if n := p.Len(); n != 2 {
+		t.Errorf("pool has %d sessions, want 2", n)
+	}
+
+	// a is evicted, but still borrowed.
+	if _, err := a.testPing(); err != nil {
+		t.Errorf("evicted session closed while borrowed: %v", err)
+	}
+	releaseA()
+	releaseA()
+	if _, err := a.testPing(); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want ErrClosed after the last release", err)
+	}
+
+	// Getting a back evicts b, which is not borrowed.
+	_, releaseA, err = p.Acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseA()
+	if _, err := b.testPing(); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want ErrClosed after eviction", err)
+	}
+	if n := logins.Load(); n != 4 {
+		t.Errorf("logged in %d times, want 4", n)
+	}
+}
+
+func TestSessionPoolRefresh(t *testing.T) {
+	p, logins := newTestPool(t, 0)
+	p.ValidateAfter = time.Nanosecond
+	ctx := context.Background()
+
+	old, release, err := p.Acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	time.Sleep(time.Millisecond)
+
+	// The test sessions are never valid, so the pool logs in again.
+	s, release, err := p.Acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if s == old || logins.Load() != 2 {
+		t.Errorf("expired session not replaced")
+	}
+	if _, err := old.testPing(); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
+
+func TestSessionPoolDoRetry(t *testing.T) {
+	p, logins := newTestPool(t, 0)
+	calls := 0
+	err := p.Do(context.Background(), "a", func(s *Session) error {
+		calls++
+		if calls == 1 {
+			return &Error{Code: ErrCodeAuthFailed}
+		}
+		return nil
+	})
+	if err != nil || calls != 2 || logins.Load() != 2 {
+		t.Errorf("got (%v, %d calls, %d logins), want a retry with a new login", err, calls, logins.Load())
+	}
+}
+
+func TestSessionPoolLoginError(t *testing.T) {
+	login := errors.New("login failed")
+	p := NewSessionPool(0, func(ctx context.Context, account string) (*Session, error) {
+		return nil, login
+	})
+	if _, _, err := p.Acquire(context.Background(), "a"); !errors.Is(err, login) {
+		t.Errorf("got %v, want the login error", err)
+	}
+	if n := p.Len(); n != 0 {
+		t.Errorf("failed login kept in the pool")
+	}
+
+	p.Close()
+	if _, _, err := p.Acquire(context.Background(), "a"); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("got %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestSessionPoolWithStore(t *testing.T) {
+	orig := lib.fduLogin
+	t.Cleanup(func() { lib.fduLogin = orig })
+	var mu sync.Mutex
+	var got []string
+	lib.fduLogin = func(username, password string, _ *cCancelToken, out **cSession) *cResult {
+		mu.Lock()
+		got = append(got, username+":"+password)
+		mu.Unlock()
+		return lib.fduTestSessionNew(out)
+	}
+	store := credentials.NewMemory()
+	if err := store.Save("21300000001", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	p := NewSessionPool(0, nil).WithStore(store)
+	defer p.Close()
+
+	_, release, err := p.Acquire(context.Background(), "21300000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if _, _, err := p.Acquire(context.Background(), "21300000002"); !errors.Is(err, credentials.ErrNotFound) {
+		t.Errorf("got %v, want credentials.ErrNotFound", err)
+	}
+	if len(got) != 1 || got[0] != "21300000001:hunter2" {
+		t.Errorf("got logins %q", got)
+	}
+}
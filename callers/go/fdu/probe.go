@@ -0,0 +1,125 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Service is a service of the university which Probe checks.
+type Service string
+
+const (
+	// ServiceUIS is the login of the university (统一身份认证).
+	ServiceUIS Service = "uis"
+	// ServiceJWFW is the academic affairs system (教务系统), of the courses,
+	// the exams and the scores.
+	ServiceJWFW Service = "jwfw"
+	// ServiceECard is the system of the campus card.
+	ServiceECard Service = "ecard"
+	// ServiceLibrary is the seat reservation system of the library.
+	ServiceLibrary Service = "library"
+)
+
+// Services returns the services Probe checks.
+func Services() []Service {
+	return []Service{ServiceUIS, ServiceJWFW, ServiceECard, ServiceLibrary}
+}
+
+// ProbeResult is how a service did in a probe.
+type ProbeResult struct {
+	Service Service
+	// Reachable reports whether the service answered, whatever the status:
+	// a service answering 503, or with its maintenance page, is reachable.
+	Reachable bool
+	// LatencyMS is the time to the answer in milliseconds, or until the
+	// probe failed.
+	LatencyMS int64
+	// HTTPStatus is 0 if the service did not answer. Redirects are not
+	// followed, so e.g. jwfw answers 302 to the login page.
+	HTTPStatus int
+	// MaintenancePage reports whether the service answered with the notice
+	// the services show while under maintenance.
+	MaintenancePage bool
+	// Error is why the service did not answer, e.g. "timed out".
+	Error string
+}
+
+// Up reports whether the service answered without a server error or its
+// maintenance page, so that it is worth logging in to.
+func (r ProbeResult) Up() bool {
+	return r.Reachable && r.HTTPStatus < 500 && !r.MaintenancePage
+}
+
+type rawProbeResult struct {
+	Service         Service `json:"service"`
+	Reachable       bool    `json:"reachable"`
+	LatencyMS       int64   `json:"latency_ms"`
+	HTTPStatus      int     `json:"http_status"`
+	MaintenancePage bool    `json:"maintenance_page"`
+	Error           string  `json:"error"`
+}
+
+// Probe checks concurrently whether services, all of Services if there is
+// none, are reachable, and returns a result per service in the same order.
+// No session is needed, so it tells e.g. a bot whether to start a run at all.
+//
+// Each probe lasts 5 seconds at most. If ctx has a deadline, the probes end
+// a little before it, so that the results arrive by then, and the services
+// which did not answer in time are unreachable. The error is only about the
+// call itself: an unknown service is rejected before any request is sent.
+func Probe(ctx context.Context, services ...Service) ([]ProbeResult, error) {
+	for _, service := range services {
+		if !slices.Contains(Services(), service) {
+			valid := make([]string, 0, len(Services()))
+			for _, s := range Services() {
+				valid = append(valid, string(s))
+			}
+			return nil, argumentError("unknown service %q, valid services are %s", service, strings.Join(valid, ", "))
+		}
+	}
+	if err := checkInit(); err != nil {
+		return nil, err
+	}
+	// An empty array, not null, for all the services.
+	data, err := json.Marshal(append([]Service{}, services...))
+	if err != nil {
+		return nil, err
+	}
+	// 0 for the timeout of libfdu.
+	var timeout uint64
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		timeout = uint64(max(1, millis(remaining-remaining/10)))
+	}
+	v, err := runJob(ctx, "", func() {}, func(token *cCancelToken, id uint64) *cResult {
+		return lib.fduProbeAsync(string(data), timeout, token, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseProbeResults([]byte(v))
+}
+
+func parseProbeResults(data []byte) ([]ProbeResult, error) {
+	var raws []rawProbeResult
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, parseError("probe results: %v", err)
+	}
+	results := make([]ProbeResult, 0, len(raws))
+	for _, raw := range raws {
+		if !slices.Contains(Services(), raw.Service) {
+			return nil, parseError("probe result: unknown service %q", raw.Service)
+		}
+		if raw.LatencyMS < 0 {
+			return nil, parseError("probe result %s: invalid latency_ms %d", raw.Service, raw.LatencyMS)
+		}
+		if raw.HTTPStatus < 0 || raw.HTTPStatus > 999 || raw.Reachable != (raw.HTTPStatus != 0) {
+			return nil, parseError("probe result %s: invalid http_status %d", raw.Service, raw.HTTPStatus)
+		}
+		results = append(results, ProbeResult(raw))
+	}
+	return results, nil
+}
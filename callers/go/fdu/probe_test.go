@@ -0,0 +1,115 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// stubProbe serves the Probe calls with testdata/probe.json, recording the
+// services and the timeout of the last call.
+func stubProbe(t *testing.T) (services *string, timeout *uint64) {
+	t.Helper()
+	data, err := os.ReadFile("testdata/probe.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	services, timeout = new(string), new(uint64)
+	orig := lib.fduProbeAsync
+	t.Cleanup(func() { lib.fduProbeAsync = orig })
+	lib.fduProbeAsync = func(s string, millis uint64, token *cCancelToken, requestID uint64) *cResult {
+		*services, *timeout = s, millis
+		return lib.fduTestResultAsync(string(data), 0, 0, token, requestID)
+	}
+	return services, timeout
+}
+
+func TestProbe(t *testing.T) {
+	services, timeout := stubProbe(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	results, err := Probe(ctx, ServiceUIS, ServiceJWFW, ServiceECard, ServiceLibrary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *services != `["uis","jwfw","ecard","library"]` {
+		t.Errorf("got services %s", *services)
+	}
+	// The probes end before ctx.
+	if *timeout == 0 || *timeout >= 2000 {
+		t.Errorf("got a timeout of %d ms, want less than the 2000 ms of ctx", *timeout)
+	}
+
+	want := []ProbeResult{
+		{Service: ServiceUIS, Reachable: true, LatencyMS: 42, HTTPStatus: 200},
+		{Service: ServiceJWFW, Reachable: true, LatencyMS: 18, HTTPStatus: 503},
+		{Service: ServiceECard, LatencyMS: 4500, Error: "timed out"},
+		{Service: ServiceLibrary, Reachable: true, LatencyMS: 25, HTTPStatus: 200, MaintenancePage: true},
+	}
+	if !slices.Equal(results, want) {
+		t.Fatalf("got %+v, want %+v", results, want)
+	}
+	var up []bool
+	for _, r := range results {
+		up = append(up, r.Up())
+	}
+	// Healthy, 503, timing out and under maintenance.
+	if want := []bool{true, false, false, false}; !slices.Equal(up, want) {
+		t.Errorf("got Up %v, want %v", up, want)
+	}
+}
+
+func TestProbeAll(t *testing.T) {
+	services, timeout := stubProbe(t)
+	if _, err := Probe(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// All the services, for the timeout of libfdu.
+	if *services != "[]" || *timeout != 0 {
+		t.Errorf("got services %s and a timeout of %d ms", *services, *timeout)
+	}
+}
+
+func TestProbeUnknownService(t *testing.T) {
+	orig := lib.fduProbeAsync
+	t.Cleanup(func() { lib.fduProbeAsync = orig })
+	lib.fduProbeAsync = func(string, uint64, *cCancelToken, uint64) *cResult {
+		t.Error("probed with an unknown service")
+		return nil
+	}
+	_, err := Probe(context.Background(), ServiceUIS, "wlan")
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("got %v, want ErrInvalidArgument", err)
+	}
+	if want := `fdu: invalid argument: unknown service "wlan", valid services are uis, jwfw, ecard, library`; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
+
+func TestParseProbeResultsInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"not json":              `<html>`,
+		"unknown service":       `[{"service": "wlan", "reachable": true, "http_status": 200}]`,
+		"negative latency":      `[{"service": "uis", "reachable": true, "latency_ms": -1, "http_status": 200}]`,
+		"no status":             `[{"service": "uis", "reachable": true}]`,
+		"status if unreachable": `[{"service": "uis", "http_status": 200}]`,
+	} {
+		if _, err := parseProbeResults([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func FuzzParseProbeResults(f *testing.F) {
+	fuzzParse(f, []string{"probe.json"}, nil, parseProbeResults, func(t *testing.T, results []ProbeResult) {
+		for _, r := range results {
+			if !slices.Contains(Services(), r.Service) || r.LatencyMS < 0 || r.Reachable != (r.HTTPStatus != 0) {
+				t.Fatalf("accepted the invalid result %+v", r)
+			}
+		}
+	})
+}
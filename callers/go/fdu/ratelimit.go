@@ -0,0 +1,158 @@
+package fdu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Host is a server of the university, e.g. "jwfw.fudan.edu.cn".
+type Host string
+
+const (
+	HostUIS     Host = "uis.fudan.edu.cn"
+	HostJwfw    Host = "jwfw.fudan.edu.cn"
+	HostEcard   Host = "ecard.fudan.edu.cn"
+	HostMy      Host = "my.fudan.edu.cn"
+	HostLibrary Host = "seat.lib.fudan.edu.cn"
+	HostOPAC    Host = "opac.fudan.edu.cn"
+	HostPE      Host = "tyb.fudan.edu.cn"
+	HostXk      Host = "xk.fudan.edu.cn"
+	HostYjsxt   Host = "yjsxt.fudan.edu.cn"
+)
+
+// Rate is the rate limit of the requests of a session to a host, enforced
+// by libfdu with a token bucket: the session sends up to Burst requests at
+// once, then PerSecond requests per second. A request over the limit waits,
+// unless it would wait past the deadline of the context of the call, which
+// then fails at once with a *RateLimitedError.
+//
+// Unless Config.RateLimits says otherwise, libfdu limits uis.fudan.edu.cn to
+// 0.2 requests per second with a burst of 5, about a login every 20 seconds,
+// and jwfw.fudan.edu.cn to 2 requests per second with a burst of 4, since
+// both flag the accounts of clients going faster. Other hosts are not
+// limited.
+type Rate struct {
+	// PerSecond is the number of requests per second. Zero lifts the limit
+	// of the host.
+	PerSecond float64 `json:"per_second"`
+	// Burst is the number of requests sent at once after a pause. Zero
+	// means 1.
+	Burst int `json:"burst"`
+}
+
+// RateLimitedError is returned by a call whose request would wait for the
+// rate limit of its host past the deadline of the context of the call.
+type RateLimitedError struct {
+	Host Host
+	// RetryAt is the earliest time at which the request would be sent.
+	RetryAt time.Time
+	// Message is the description provided by the library.
+	Message string
+}
+
+func (e *RateLimitedError) Error() string {
+	return "fdu: rate limited: " + e.Message
+}
+
+// Unwrap returns ErrRateLimited.
+func (e *RateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// newRateLimitedError builds a *RateLimitedError from the value of a result
+// with ErrCodeRateLimited, a JSON object {"host", "retry_after_millis"}.
+func newRateLimitedError(value, message string) error {
+	var raw struct {
+		Host             Host  `json:"host"`
+		RetryAfterMillis int64 `json:"retry_after_millis"`
+	}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return &Error{Code: ErrCodeRateLimited, Message: message}
+	}
+	retryAt := time.Now().Add(time.Duration(raw.RetryAfterMillis) * time.Millisecond)
+	return &RateLimitedError{Host: raw.Host, RetryAt: retryAt, Message: message}
+}
+
+// HostRateLimit is the state of the rate limit of a host.
+type HostRateLimit struct {
+	Rate
+	// Tokens is the number of requests the host can be sent at once. It is
+	// negative while requests wait for the host.
+	Tokens float64 `json:"tokens"`
+}
+
+// RateLimitStats is the state of the rate limits of a session.
+type RateLimitStats struct {
+	// Hosts are the limited hosts.
+	Hosts map[Host]HostRateLimit
+	// ThrottledRequests is the number of requests which waited for a rate
+	// limit, and ThrottledTime how long they waited in total.
+	ThrottledRequests int
+	ThrottledTime     time.Duration
+}
+
+// RateLimitStats returns the state of the rate limits of s. Unlike the other
+// methods, it does not wait for the call in flight on s, if any, so that it
+// can tell why the call is slow.
+func (s *Session) RateLimitStats() (*RateLimitStats, error) {
+	if err := checkInit(); err != nil {
+		return nil, err
+	}
+	s.freeing.RLock()
+	defer s.freeing.RUnlock()
+	if s.ptr == nil {
+		return nil, ErrClosed
+	}
+	v, err := callInto(callSessionRateLimitStats, s.ptr, "")
+	if err != nil {
+		return nil, err
+	}
+	return parseRateLimitStats([]byte(v))
+}
+
+func parseRateLimitStats(data []byte) (*RateLimitStats, error) {
+	var raw struct {
+		Hosts             map[Host]HostRateLimit `json:"hosts"`
+		ThrottledRequests int                    `json:"throttled_requests"`
+		ThrottledMillis   int64                  `json:"throttled_millis"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, parseError("rate limit stats: %v", err)
+	}
+	if raw.ThrottledRequests < 0 || raw.ThrottledMillis < 0 {
+		return nil, parseError("rate limit stats: invalid throttled_requests %d or throttled_millis %d", raw.ThrottledRequests, raw.ThrottledMillis)
+	}
+	for host, limit := range raw.Hosts {
+		if limit.PerSecond < 0 || limit.Burst < 0 {
+			return nil, parseError("rate limit of %s: invalid per_second %v or burst %d", host, limit.PerSecond, limit.Burst)
+		}
+	}
+	return &RateLimitStats{
+		Hosts:             raw.Hosts,
+		ThrottledRequests: raw.ThrottledRequests,
+		ThrottledTime:     time.Duration(raw.ThrottledMillis) * time.Millisecond,
+	}, nil
+}
+
+// checkRateLimits checks Config.RateLimits.
+func checkRateLimits(limits map[Host]Rate) error {
+	for host, rate := range limits {
+		var err error
+		switch {
+		case host == "" || strings.ContainsAny(string(host), "/:\x00"):
+			err = errors.New("invalid host")
+		case rate.PerSecond < 0 || math.IsNaN(rate.PerSecond) || math.IsInf(rate.PerSecond, 0):
+			err = fmt.Errorf("invalid PerSecond %v", rate.PerSecond)
+		case rate.Burst < 0 || int64(rate.Burst) > math.MaxUint32:
+			err = fmt.Errorf("invalid Burst %d", rate.Burst)
+		}
+		if err != nil {
+			return fmt.Errorf("fdu: %w: Config.RateLimits[%q]: %v", ErrInvalidArgument, host, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitStats(t *testing.T) {
+	stats, err := parseRateLimitStats([]byte(`{"hosts":{"jwfw.fudan.edu.cn":{"tokens":-1.5,"per_second":2.0,"burst":4}},` +
+		`"throttled_requests":3,"throttled_millis":2500}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := HostRateLimit{Rate: Rate{PerSecond: 2, Burst: 4}, Tokens: -1.5}
+	if got := stats.Hosts[HostJwfw]; got != want || len(stats.Hosts) != 1 {
+		t.Errorf("got hosts %+v, want %s: %+v", stats.Hosts, HostJwfw, want)
+	}
+	if stats.ThrottledRequests != 3 || stats.ThrottledTime != 2500*time.Millisecond {
+		t.Errorf("got %d requests throttled for %v, want 3 for 2.5s", stats.ThrottledRequests, stats.ThrottledTime)
+	}
+	if _, err := parseRateLimitStats([]byte(`{"hosts":[]}`)); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v, want ErrParse", err)
+	}
+}
+
+func TestRateLimitedError(t *testing.T) {
+	before := time.Now()
+	err := newRateLimitedError(`{"host":"uis.fudan.edu.cn","retry_after_millis":4000}`, "rate limited")
+	var limited *RateLimitedError
+	if !errors.As(err, &limited) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want a *RateLimitedError", err)
+	}
+	if limited.Host != HostUIS {
+		t.Errorf("got host %q, want %q", limited.Host, HostUIS)
+	}
+	if d := limited.RetryAt.Sub(before); d < 4*time.Second || d > 5*time.Second {
+		t.Errorf("got RetryAt %v after the call, want 4s", d)
+	}
+	// An unexpected value still reports the code.
+	err = newRateLimitedError("{", "rate limited")
+	if !errors.Is(err, ErrRateLimited) || errors.As(err, &limited) {
+		t.Errorf("got %#v, want an *Error wrapping ErrRateLimited", err)
+	}
+}
+
+func TestRateLimitStats(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := s.RateLimitStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The defaults of libfdu.
+	for host, rate := range map[Host]Rate{HostUIS: {PerSecond: 0.2, Burst: 5}, HostJwfw: {PerSecond: 2, Burst: 4}} {
+		if got := stats.Hosts[host]; got.Rate != rate || got.Tokens != float64(rate.Burst) {
+			t.Errorf("%s: got %+v, want %+v with a full bucket", host, got, rate)
+		}
+	}
+	if stats.ThrottledRequests != 0 || stats.ThrottledTime != 0 {
+		t.Errorf("got %+v, want nothing throttled", stats)
+	}
+
+	s.Close()
+	if _, err := s.RateLimitStats(); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v after Close, want ErrClosed", err)
+	}
+}
+
+func TestRateLimitStatsDuringCall(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	started := make(chan struct{})
+	orig := lib.fduSessionValidAsync
+	t.Cleanup(func() { lib.fduSessionValidAsync = orig })
+	lib.fduSessionValidAsync = func(_ *cSession, token *cCancelToken, requestID uint64) *cResult {
+		close(started)
+		return lib.fduTestResultAsync("true", 0, 200, token, requestID)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := s.Valid(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-started
+	start := time.Now()
+	if _, err := s.RateLimitStats(); err != nil {
+		t.Error(err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("RateLimitStats took %v, want it not to wait for the call", d)
+	}
+	wg.Wait()
+}
+
+func TestCancelTokenDeadline(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	orig := lib.fduCancelTokenSetDeadline
+	t.Cleanup(func() { lib.fduCancelTokenSetDeadline = orig })
+	var deadlines []uint64
+	lib.fduCancelTokenSetDeadline = func(token *cCancelToken, timeoutMillis uint64) {
+		deadlines = append(deadlines, timeoutMillis)
+		orig(token, timeoutMillis)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := s.Valid(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(deadlines) != 0 {
+		t.Errorf("got deadlines %v without a deadline", deadlines)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := s.Valid(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(deadlines) != 1 || deadlines[0] < 9000 || deadlines[0] > 10000 {
+		t.Errorf("got deadlines %v, want one of 10s", deadlines)
+	}
+}
+
+func TestRateLimitsJSON(t *testing.T) {
+	raw, err := Config{RateLimits: map[Host]Rate{HostJwfw: {PerSecond: 1, Burst: 2}, HostUIS: {}}}.raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(raw.RateLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"jwfw.fudan.edu.cn":{"per_second":1,"burst":2},"uis.fudan.edu.cn":{"per_second":0,"burst":0}}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+	if err := SetConfig(Config{RateLimits: map[Host]Rate{HostJwfw: {PerSecond: 1, Burst: 2}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetConfig(Config{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func FuzzParseRateLimitStats(f *testing.F) {
+	fuzzParse(f, nil, []string{
+		`{"hosts":{"jwfw.fudan.edu.cn":{"tokens":-1.5,"per_second":2.0,"burst":4}},"throttled_requests":3,"throttled_millis":2500}`,
+	}, parseRateLimitStats, func(t *testing.T, stats *RateLimitStats) {
+		if stats.ThrottledRequests < 0 || stats.ThrottledTime < 0 {
+			t.Fatalf("accepted the invalid stats %+v", stats)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// RawRequest is an HTTP request sent by Session.Do.
+type RawRequest struct {
+	// Method is e.g. "POST". Empty means GET.
+	Method string
+	// URL is the absolute http or https URL of the request.
+	URL string
+	// Headers are sent after the default headers of the session, which they
+	// do not replace: set e.g. Referer, or Content-Type along with Body.
+	Headers http.Header
+	Body    []byte
+}
+
+// RawResponse is the response to a RawRequest, after the redirects.
+type RawResponse struct {
+	Status int
+	// URL is the URL of the response, the one of the request unless it was
+	// redirected.
+	URL     string
+	Headers http.Header
+	Body    []byte
+}
+
+type rawRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers [][2]string `json:"headers"`
+}
+
+type rawResponse struct {
+	Status  int         `json:"status"`
+	URL     string      `json:"url"`
+	Headers [][2]string `json:"headers"`
+}
+
+// Do sends req with the cookies of the session, e.g. to prototype against a
+// service libfdu has no binding for yet, and returns the response whatever
+// its status, except 502, 503 and 504, which fail with an error wrapping
+// ErrNetwork like the other calls. The request is not retried.
+//
+// The redirects are followed, up to 10, including those through UIS, which
+// log in to the service with the session. Only fudan.edu.cn, its subdomains
+// and Config.RawAllowedHosts are allowed, for the request and each redirect:
+// another host fails with an error wrapping ErrInvalidArgument before
+// anything is sent to it. A body over Config.RawMaxBodySize, 8 MiB by
+// default, fails with an error wrapping ErrNetwork.
+func (s *Session) Do(ctx context.Context, req RawRequest) (RawResponse, error) {
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return RawResponse{}, argumentError("invalid url %q", req.URL)
+	}
+	if err := checkCStrings("method", req.Method, "url", req.URL); err != nil {
+		return RawResponse{}, err
+	}
+	raw := rawRequest{Method: req.Method, URL: req.URL, Headers: [][2]string{}}
+	for _, name := range slices.Sorted(maps.Keys(req.Headers)) {
+		for _, value := range req.Headers[name] {
+			if err := checkCStrings("header name", name, "header "+name, value); err != nil {
+				return RawResponse{}, err
+			}
+			raw.Headers = append(raw.Headers, [2]string{name, value})
+		}
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return RawResponse{}, err
+	}
+
+	return callContext(ctx, func(token *cCancelToken) (RawResponse, error) {
+		var buf *cBuffer
+		v, err := s.locked(func(ptr *cSession) (string, error) {
+			body, n := cBytes(req.Body)
+			return takeResult(lib.fduSessionDo(ptr, string(data), body, n, token, &buf))
+		})
+		body := takeBuffer(buf)
+		if err != nil {
+			return RawResponse{}, err
+		}
+		return parseRawResponse([]byte(v), body)
+	})
+}
+
+func parseRawResponse(data, body []byte) (RawResponse, error) {
+	var raw rawResponse
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return RawResponse{}, parseError("raw response: %v", err)
+	}
+	r := RawResponse{Status: raw.Status, URL: raw.URL, Headers: make(http.Header, len(raw.Headers)), Body: body}
+	if r.Body == nil {
+		r.Body = []byte{}
+	}
+	for _, h := range raw.Headers {
+		r.Headers.Add(h[0], h[1])
+	}
+	return r, nil
+}
@@ -0,0 +1,146 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+// fakeDo answers the requests of Session.Do with the response JSON, the body
+// and the error code of answer, the body only if the code is 0. It returns
+// the request JSON and body of the last call.
+func fakeDo(t *testing.T, answer func() (string, []byte, int32)) (request *string, requestBody *[]byte) {
+	t.Helper()
+	orig := lib.fduSessionDo
+	t.Cleanup(func() { lib.fduSessionDo = orig })
+	request, requestBody = new(string), new([]byte)
+	lib.fduSessionDo = func(_ *cSession, req string, data *byte, n uintptr, _ *cCancelToken, out **cBuffer) *cResult {
+		*request = req
+		*requestBody = append([]byte(nil), unsafe.Slice(data, n)...)
+		*out = nil
+		value, body, code := answer()
+		if code == 0 {
+			ptr, n := cBytes(body)
+			if _, err := takeResult(lib.fduTestEchoBytes(ptr, n, out)); err != nil {
+				t.Error(err)
+			}
+		}
+		return lib.fduTestResult(value, code)
+	}
+	return request, requestBody
+}
+
+func TestDo(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	buffers := testLiveBuffers()
+	response := `{"status": 200, "url": "https://jwfw.fudan.edu.cn/eams/home.action", "headers": [` +
+		`["content-type", "text/html"], ["set-cookie", "a=1"], ["set-cookie", "b=2"]]}`
+	body := []byte("<html>")
+	request, requestBody := fakeDo(t, func() (string, []byte, int32) { return response, body, 0 })
+
+	r, err := s.Do(context.Background(), RawRequest{
+		Method: "POST",
+		URL:    "https://jwfw.fudan.edu.cn/eams/home.action",
+		Headers: http.Header{
+			"X-Requested-With": {"XMLHttpRequest"},
+			"Accept":           {"text/html", "application/json"},
+		},
+		Body: []byte("a=1&b=2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RawResponse{
+		Status:  200,
+		URL:     "https://jwfw.fudan.edu.cn/eams/home.action",
+		Headers: http.Header{"Content-Type": {"text/html"}, "Set-Cookie": {"a=1", "b=2"}},
+		Body:    []byte("<html>"),
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("got %+v, want %+v", r, want)
+	}
+	wantRequest := `{"method":"POST","url":"https://jwfw.fudan.edu.cn/eams/home.action","headers":[` +
+		`["Accept","text/html"],["Accept","application/json"],["X-Requested-With","XMLHttpRequest"]]}`
+	if *request != wantRequest {
+		t.Errorf("got request %s, want %s", *request, wantRequest)
+	}
+	if string(*requestBody) != "a=1&b=2" {
+		t.Errorf("got body %q", *requestBody)
+	}
+
+	// No headers nor body, and an empty response.
+	response, body = `{"status": 204, "url": "https://my.fudan.edu.cn/", "headers": []}`, nil
+	r, err = s.Do(context.Background(), RawRequest{URL: "https://my.fudan.edu.cn/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != 204 || len(r.Headers) != 0 || r.Body == nil || len(r.Body) != 0 {
+		t.Errorf("got %+v", r)
+	}
+	if want := `{"method":"","url":"https://my.fudan.edu.cn/","headers":[]}`; *request != want || len(*requestBody) != 0 {
+		t.Errorf("got request %s with body %q, want %s", *request, *requestBody, want)
+	}
+	if n := testLiveBuffers(); n != buffers {
+		t.Errorf("%d buffers leaked", n-buffers)
+	}
+}
+
+func TestDoErrors(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var code int32
+	response := `{"status": 200, "url": "https://my.fudan.edu.cn/", "headers": []}`
+	fakeDo(t, func() (string, []byte, int32) { return response, []byte("ok"), code })
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name string
+		code ErrCode
+		want error
+	}{
+		// A host, or a redirect to a host, out of the allowlist.
+		{"host not allowed", ErrCodeInvalidArgument, ErrInvalidArgument},
+		// A body over Config.RawMaxBodySize, or too many redirects.
+		{"body too large", ErrCodeNetwork, ErrNetwork},
+	} {
+		code = int32(tc.code)
+		if _, err := s.Do(ctx, RawRequest{URL: "https://my.fudan.edu.cn/"}); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	code = 0
+	response = `<html>`
+	if _, err := s.Do(ctx, RawRequest{URL: "https://my.fudan.edu.cn/"}); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v, want ErrParse", err)
+	}
+
+	for _, req := range []RawRequest{
+		{},
+		{URL: "/eams/home.action"},
+		{URL: "ftp://ftp.fudan.edu.cn/"},
+		{URL: "https:///"},
+		{URL: "https://my.fudan.edu.cn/", Method: "GET\x00"},
+		{URL: "https://my.fudan.edu.cn/", Headers: http.Header{"X-A\x00": {"a"}}},
+		{URL: "https://my.fudan.edu.cn/", Headers: http.Header{"X-A": {"\xff"}}},
+	} {
+		if _, err := s.Do(ctx, req); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%+v: got %v, want ErrInvalidArgument", req, err)
+		}
+	}
+
+	s.Close()
+	if _, err := s.Do(ctx, RawRequest{URL: "https://my.fudan.edu.cn/"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,56 @@
+package fdu
+
+// RedactLevel is how much of the exchanges recorded by EnableRecording is
+// replaced by placeholders. Each value has the same placeholder in all the
+// files, e.g. a cookie set by one exchange and sent by the next, so that
+// Replay behaves like the exchanges recorded.
+type RedactLevel int
+
+const (
+	// RedactStandard, the default, replaces the password and the student ID
+	// given to Login, the cookies, the CAS tickets, and the student IDs and
+	// the names in the pages.
+	RedactStandard RedactLevel = iota
+	// RedactStrict also replaces every number of 8 digits or more, e.g. a
+	// phone number, and the email addresses, and drops the bodies of the
+	// requests.
+	RedactStrict
+	// RedactNone records the exchanges as they are, for recordings which
+	// never leave the machine: they hold the password.
+	RedactNone
+)
+
+// EnableRecording records every HTTP exchange of the sessions, the ones
+// alive included, into a JSON file of dir, created if needed, to reproduce a
+// bug offline, see Replay. The files are numbered in the order of the
+// requests, after those already in dir.
+//
+// A file has the request and the response of one exchange, with the URLs of
+// the real servers, like an entry of a HAR file. Check them before sharing:
+// the redaction only replaces the values it knows of.
+func EnableRecording(dir string, redact RedactLevel) error {
+	if dir == "" {
+		return argumentError("empty recording directory")
+	}
+	if redact < RedactStandard || redact > RedactNone {
+		return argumentError("invalid RedactLevel %d", redact)
+	}
+	if err := checkCString("dir", dir); err != nil {
+		return err
+	}
+	if err := checkInit(); err != nil {
+		return err
+	}
+	_, err := takeResult(lib.fduSetRecording(dir, uint32(redact)))
+	return err
+}
+
+// DisableRecording stops the recording of EnableRecording. The exchanges in
+// flight are still recorded.
+func DisableRecording() error {
+	if err := checkInit(); err != nil {
+		return err
+	}
+	_, err := takeResult(lib.fduSetRecording("", uint32(RedactStandard)))
+	return err
+}
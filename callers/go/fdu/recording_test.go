@@ -0,0 +1,61 @@
+package fdu
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type recordingCall struct {
+	dir    string
+	redact uint32
+}
+
+// recordRecordings records the calls of fdu_set_recording for the rest of
+// the test, which still reach libfdu.
+func recordRecordings(t *testing.T) *[]recordingCall {
+	t.Helper()
+	orig := lib.fduSetRecording
+	t.Cleanup(func() { lib.fduSetRecording = orig })
+	calls := new([]recordingCall)
+	lib.fduSetRecording = func(dir string, redact uint32) *cResult {
+		*calls = append(*calls, recordingCall{dir, redact})
+		return orig(dir, redact)
+	}
+	return calls
+}
+
+func TestEnableRecording(t *testing.T) {
+	calls := recordRecordings(t)
+	dir := filepath.Join(t.TempDir(), "recording")
+	if err := EnableRecording(dir, RedactStrict); err != nil {
+		t.Fatal(err)
+	}
+	if err := DisableRecording(); err != nil {
+		t.Fatal(err)
+	}
+	want := []recordingCall{{dir, 1}, {"", 0}}
+	if len(*calls) != len(want) || (*calls)[0] != want[0] || (*calls)[1] != want[1] {
+		t.Fatalf("got calls %+v, want %+v", *calls, want)
+	}
+}
+
+func TestEnableRecordingInvalid(t *testing.T) {
+	calls := recordRecordings(t)
+	for _, tc := range []struct {
+		dir    string
+		redact RedactLevel
+	}{
+		{"", RedactStandard},
+		{t.TempDir(), RedactNone + 1},
+		{t.TempDir(), -1},
+		{"rec\x00ording", RedactStandard},
+	} {
+		if err := EnableRecording(tc.dir, tc.redact); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("EnableRecording(%q, %d): got %v, want ErrInvalidArgument", tc.dir, tc.redact, err)
+		}
+	}
+	if len(*calls) != 0 {
+		t.Errorf("got calls %+v, want none", *calls)
+	}
+}
@@ -0,0 +1,311 @@
+package fdu
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// recordedExchange is a file written by EnableRecording.
+type recordedExchange struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	// Response is nil if the request failed, with Error.
+	Response *recordedResponse `json:"response"`
+	Error    string            `json:"error"`
+
+	url  *url.URL
+	used bool
+}
+
+type recordedResponse struct {
+	Status int `json:"status"`
+	// URL is the URL of the response, after the redirects.
+	URL     string `json:"url"`
+	Headers []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"headers"`
+	Body string `json:"body"`
+	// Encoding is "base64" if Body is, e.g. for an image.
+	Encoding string `json:"encoding"`
+
+	url  *url.URL
+	body []byte
+}
+
+// Replay answers the requests of libfdu with the exchanges recorded by
+// EnableRecording in dir, from a local server for each host recorded, which
+// it points libfdu at with SetBaseURLs: the calls which made the recording
+// then run the same offline, e.g. to reproduce a bug and turn the recording
+// into a test. stop closes the servers and restores the real servers.
+//
+// A request is answered with the first exchange not replayed yet with the
+// same method and URL, or else the same path, since the query may have e.g.
+// a time stamp, or else with the last of them. An exchange whose request
+// failed fails the connection, and a response reached through redirects is
+// replayed as a redirect to the last URL, answered with the response.
+//
+// Replay uses SetBaseURLs, so it needs a debug build of libfdu and returns
+// ErrReleaseBuild otherwise.
+func Replay(dir string) (stop func() error, err error) {
+	exchanges, err := loadRecording(dir)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newReplayer(exchanges)
+	if err != nil {
+		return nil, err
+	}
+	if err := SetBaseURLs(r.baseURLs); err != nil {
+		r.close()
+		return nil, err
+	}
+	return func() error {
+		r.close()
+		return SetBaseURLs(nil)
+	}, nil
+}
+
+// loadRecording reads the exchanges in dir, in the order of their files.
+func loadRecording(dir string) ([]*recordedExchange, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var exchanges []*recordedExchange
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		e, err := parseRecordedExchange(data)
+		if err != nil {
+			return nil, parseError("recording %s: %v", entry.Name(), err)
+		}
+		exchanges = append(exchanges, e)
+	}
+	if len(exchanges) == 0 {
+		return nil, argumentError("no recorded exchange in %s", dir)
+	}
+	return exchanges, nil
+}
+
+func parseRecordedExchange(data []byte) (*recordedExchange, error) {
+	var e recordedExchange
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	var err error
+	if e.url, err = absoluteURL(e.Request.URL); err != nil || e.Request.Method == "" {
+		return nil, fmt.Errorf("invalid request %s %q", e.Request.Method, e.Request.URL)
+	}
+	res := e.Response
+	if res == nil {
+		if e.Error == "" {
+			return nil, fmt.Errorf("neither a response nor an error")
+		}
+		return &e, nil
+	}
+	if res.Status < 100 || res.Status > 999 {
+		return nil, fmt.Errorf("invalid status %d", res.Status)
+	}
+	if res.url, err = absoluteURL(res.URL); err != nil {
+		return nil, fmt.Errorf("invalid response URL %q", res.URL)
+	}
+	switch res.Encoding {
+	case "":
+		res.body = []byte(res.Body)
+	case "base64":
+		if res.body, err = base64.StdEncoding.DecodeString(res.Body); err != nil {
+			return nil, fmt.Errorf("invalid body: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", res.Encoding)
+	}
+	return &e, nil
+}
+
+func absoluteURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q is not absolute", s)
+	}
+	return u, nil
+}
+
+// replayer serves the exchanges of a recording, see Replay.
+type replayer struct {
+	// baseURLs are the base URLs of the servers, by host, for SetBaseURLs.
+	baseURLs map[string]string
+	servers  []*http.Server
+
+	mu        sync.Mutex
+	exchanges []*recordedExchange
+	// landings are the exchanges replayed as a redirect, by the host and the
+	// request URI it redirected to.
+	landings map[string]*recordedExchange
+}
+
+// newReplayer starts the servers of the hosts of exchanges.
+func newReplayer(exchanges []*recordedExchange) (*replayer, error) {
+	r := &replayer{
+		baseURLs:  make(map[string]string),
+		exchanges: exchanges,
+		landings:  make(map[string]*recordedExchange),
+	}
+	for _, e := range exchanges {
+		hosts := []string{e.url.Host}
+		if e.Response != nil {
+			hosts = append(hosts, e.Response.url.Host)
+		}
+		for _, host := range hosts {
+			if _, ok := r.baseURLs[host]; ok {
+				continue
+			}
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				r.close()
+				return nil, err
+			}
+			server := &http.Server{Handler: r.handler(host)}
+			go server.Serve(l)
+			r.servers = append(r.servers, server)
+			r.baseURLs[host] = "http://" + l.Addr().String()
+		}
+	}
+	return r, nil
+}
+
+func (r *replayer) close() {
+	for _, server := range r.servers {
+		server.Close()
+	}
+}
+
+func (r *replayer) handler(host string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		e, landing := r.match(host, req)
+		switch {
+		case e == nil:
+			http.Error(w, fmt.Sprintf("fdu: no recorded exchange for %s https://%s%s", req.Method, host, req.URL.RequestURI()), http.StatusNotFound)
+		case e.Response == nil:
+			// The request failed: so does the connection, or the response
+			// if it cannot be hijacked.
+			if hijacker, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hijacker.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+			http.Error(w, e.Error, http.StatusBadGateway)
+		case !landing && landingKey(e.Response.url) != landingKey(e.url):
+			r.mu.Lock()
+			r.landings[landingKey(e.Response.url)] = e
+			r.mu.Unlock()
+			http.Redirect(w, req, r.rewrite(e.Response.URL), http.StatusFound)
+		default:
+			r.write(w, e.Response)
+		}
+	})
+}
+
+func landingKey(u *url.URL) string {
+	return u.Host + u.RequestURI()
+}
+
+// match returns the exchange answering req, and whether it is the end of a
+// redirect.
+func (r *replayer) match(host string, req *http.Request) (*recordedExchange, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := host + req.URL.RequestURI()
+	if e, ok := r.landings[key]; ok {
+		delete(r.landings, key)
+		return e, true
+	}
+	// In order of preference.
+	var unusedQuery, unusedPath, lastQuery, lastPath *recordedExchange
+	for _, e := range r.exchanges {
+		if e.Request.Method != req.Method || e.url.Host != host || e.url.Path != req.URL.Path {
+			continue
+		}
+		sameQuery := e.url.RawQuery == req.URL.RawQuery
+		if !e.used && sameQuery && unusedQuery == nil {
+			unusedQuery = e
+		}
+		if !e.used && unusedPath == nil {
+			unusedPath = e
+		}
+		if sameQuery {
+			lastQuery = e
+		}
+		lastPath = e
+	}
+	for _, e := range []*recordedExchange{unusedQuery, unusedPath, lastQuery, lastPath} {
+		if e != nil {
+			e.used = true
+			return e, false
+		}
+	}
+	return nil, false
+}
+
+func (r *replayer) write(w http.ResponseWriter, res *recordedResponse) {
+	h := w.Header()
+	for _, header := range res.Headers {
+		switch strings.ToLower(header.Name) {
+		case "content-length", "transfer-encoding", "connection":
+			// Set by the server for the body as replayed.
+		case "location":
+			h.Add(header.Name, r.rewrite(header.Value))
+		case "set-cookie":
+			h.Add(header.Name, replayCookie(header.Value))
+		default:
+			h.Add(header.Name, header.Value)
+		}
+	}
+	w.WriteHeader(res.Status)
+	w.Write(res.body)
+}
+
+// rewrite returns u on the server replaying its host, if any.
+func (r *replayer) rewrite(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	if base, ok := r.baseURLs[parsed.Host]; ok {
+		return base + parsed.RequestURI()
+	}
+	return u
+}
+
+// replayCookie drops the attributes of cookie which would keep the client
+// from sending it to a local server over HTTP.
+func replayCookie(cookie string) string {
+	parts := strings.Split(cookie, ";")
+	kept := []string{parts[0]}
+	for _, part := range parts[1:] {
+		name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if !strings.EqualFold(name, "domain") && !strings.EqualFold(name, "secure") {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, ";")
+}
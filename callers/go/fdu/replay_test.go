@@ -0,0 +1,144 @@
+package fdu
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// startReplayer serves the exchanges of testdata/recording until the end of
+// the test.
+func startReplayer(t *testing.T) *replayer {
+	t.Helper()
+	exchanges, err := loadRecording("testdata/recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newReplayer(exchanges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.close)
+	return r
+}
+
+func TestReplayer(t *testing.T) {
+	r := startReplayer(t)
+	for _, host := range []string{"uis.fudan.edu.cn", "jwfw.fudan.edu.cn", "ecard.fudan.edu.cn"} {
+		if r.baseURLs[host] == "" {
+			t.Fatalf("no server for %s in %v", host, r.baseURLs)
+		}
+	}
+	uis, jwfw := r.baseURLs["uis.fudan.edu.cn"], r.baseURLs["jwfw.fudan.edu.cn"]
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	get := func(u string) (*http.Response, string) {
+		t.Helper()
+		res, err := client.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, string(body)
+	}
+
+	res, body := get(uis + "/authserver/login")
+	if res.StatusCode != http.StatusOK || !strings.Contains(body, "LT-REDACTED-1") {
+		t.Fatalf("login page: got %d %q", res.StatusCode, body)
+	}
+	// The cookie of uis.fudan.edu.cn, Secure, is sent back to the server over
+	// HTTP.
+	loginURL, _ := url.Parse(uis + "/authserver/login")
+	if cookies := jar.Cookies(loginURL); len(cookies) != 1 || cookies[0].Value != "REDACTED-COOKIE-1" {
+		t.Errorf("got cookies %v", cookies)
+	}
+
+	// The login was redirected to jwfw, with the ticket.
+	res, err := client.PostForm(uis+"/authserver/login", url.Values{"username": {"20300000001"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body2, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if got, want := res.Request.URL.String(), jwfw+"/eams/home.action?ticket=ST-REDACTED-1"; got != want {
+		t.Errorf("login redirected to %s, want %s", got, want)
+	}
+	if !strings.Contains(string(body2), "欢迎使用教务系统") {
+		t.Errorf("got home page %q", body2)
+	}
+
+	// The exchanges of a path are replayed in order whatever their query,
+	// except one with the same query, and the last one repeats.
+	table := jwfw + "/eams/courseTableForStd.action"
+	for i, tc := range []struct{ query, want string }{
+		{"?_=1709512209000", "course table 1"},
+		{"?_=1709512202000", "course table 2"},
+		{"?_=1709512209000", "course table 2"},
+		{"?_=1709512201000", "course table 1"},
+	} {
+		if _, body := get(table + tc.query); !strings.Contains(body, tc.want) {
+			t.Errorf("request %d: got %q, want %q", i, body, tc.want)
+		}
+	}
+
+	res, body = get(uis + "/authserver/captcha.html")
+	if res.Header.Get("Content-Type") != "image/jpeg" || !bytes.HasPrefix([]byte(body), []byte("\x89PNG")) {
+		t.Errorf("got captcha %s %q", res.Header.Get("Content-Type"), body)
+	}
+
+	if _, err := client.Get(r.baseURLs["ecard.fudan.edu.cn"] + "/epay/myepay/index"); err == nil {
+		t.Error("got a response for a failed request")
+	}
+	if res, _ := get(uis + "/authserver/logout"); res.StatusCode != http.StatusNotFound {
+		t.Errorf("got %d for a request not recorded, want 404", res.StatusCode)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	stop, err := Replay("testdata/recording")
+	if errors.Is(err, ErrReleaseBuild) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadRecordingInvalid(t *testing.T) {
+	if _, err := loadRecording(t.TempDir()); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("empty directory: got %v, want ErrInvalidArgument", err)
+	}
+	if _, err := loadRecording(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing directory: got %v, want os.ErrNotExist", err)
+	}
+	for _, data := range []string{
+		`{`,
+		`{"request":{"method":"GET","url":"/authserver/login"},"error":"x"}`,
+		`{"request":{"method":"GET","url":"https://uis.fudan.edu.cn/"}}`,
+		`{"request":{"method":"GET","url":"https://uis.fudan.edu.cn/"},"response":{"status":42,"url":"https://uis.fudan.edu.cn/"}}`,
+		`{"request":{"method":"GET","url":"https://uis.fudan.edu.cn/"},"response":{"status":200,"url":"https://uis.fudan.edu.cn/","body":"?","encoding":"base64"}}`,
+		`{"request":{"method":"GET","url":"https://uis.fudan.edu.cn/"},"response":{"status":200,"url":"https://uis.fudan.edu.cn/","encoding":"gzip"}}`,
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "000001.json"), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadRecording(dir); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", data, err)
+		}
+	}
+}
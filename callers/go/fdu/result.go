@@ -0,0 +1,70 @@
+package fdu
+
+import "sync"
+
+//go:generate go run ../internal/gen -enum FduCall -prefix FDU_CALL_ -type call -pkg fdu -o calls_gen.go bindings.h
+
+// resultBufferSize is the size of the buffers of callInto, which most values
+// fit in.
+const resultBufferSize = 4 << 10
+
+// resultBuffer is a buffer of callInto, and where fdu_call_into returns the
+// result of the fallback, which would otherwise be allocated for each call.
+type resultBuffer struct {
+	data [resultBufferSize]byte
+	out  *cResult
+}
+
+var resultBuffers = sync.Pool{
+	New: func() any { return new(resultBuffer) },
+}
+
+// takeResult converts r into Go values and frees it. r must not be used
+// afterwards.
+func takeResult(r *cResult) (string, error) {
+	if r == nil {
+		return "", &Error{Code: ErrCodeUnknown, Message: "libfdu returned no result"}
+	}
+	defer lib.freeResult(r)
+
+	if code := ErrCode(r.code); code != ErrCodeOK {
+		message := goString(r.message)
+		switch code {
+		case ErrCodePanic:
+			return "", &PanicError{Message: message}
+		case ErrCodeCaptchaRequired:
+			// The value is the continuation token, unlike other errors.
+			if r.value != nil {
+				return "", newCaptchaRequiredError(goString(r.value), message)
+			}
+		case ErrCodeRateLimited:
+			if r.value != nil {
+				return "", newRateLimitedError(goString(r.value), message)
+			}
+		}
+		return "", &Error{Code: code, Message: message}
+	}
+	if r.value == nil {
+		return "", nil
+	}
+	return goString(r.value), nil
+}
+
+// callInto makes c, e.g. callSessionUID, with fdu_call_into, which writes
+// the value into a buffer of resultBuffers: a single call into libfdu, which
+// allocates nothing, instead of the export of c and freeing its result with
+// takeResult. A value larger than the buffer is returned as a result, which
+// is taken instead. session and arg are only used by the calls taking them.
+func callInto(c call, session *cSession, arg string) (string, error) {
+	buf := resultBuffers.Get().(*resultBuffer)
+	defer resultBuffers.Put(buf)
+	n := lib.fduCallInto(c, session, arg, &buf.data[0], uintptr(len(buf.data)), &buf.out)
+	if r := buf.out; r != nil {
+		buf.out = nil
+		return takeResult(r)
+	}
+	if n > uintptr(len(buf.data)) {
+		return "", &Error{Code: ErrCodeUnknown, Message: "libfdu wrote past the buffer"}
+	}
+	return string(buf.data[:n]), nil
+}
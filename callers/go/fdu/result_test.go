@@ -0,0 +1,173 @@
+package fdu
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countFreedResults counts the results freed for the rest of the test, which
+// callInto only frees on the fallback.
+func countFreedResults(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	orig := lib.freeResult
+	t.Cleanup(func() { lib.freeResult = orig })
+	freed := new(atomic.Int32)
+	lib.freeResult = func(r *cResult) {
+		freed.Add(1)
+		orig(r)
+	}
+	return freed
+}
+
+func TestCallInto(t *testing.T) {
+	freed := countFreedResults(t)
+	for _, tc := range []struct {
+		name     string
+		value    string
+		fallback bool
+	}{
+		{"empty", "", false},
+		{"small", `{"uid":"20300000001"}`, false},
+		{"exactly the buffer", strings.Repeat("x", resultBufferSize), false},
+		{"one byte more", strings.Repeat("y", resultBufferSize+1), true},
+		{"64KB", strings.Repeat("z", 64<<10), true},
+	} {
+		freed.Store(0)
+		got, err := testEchoInto(tc.value)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.value {
+			t.Errorf("%s: got %d bytes, want %d", tc.name, len(got), len(tc.value))
+		}
+		if want := map[bool]int{false: 0, true: 1}[tc.fallback]; int(freed.Load()) != want {
+			t.Errorf("%s: freed %d results, want %d", tc.name, freed.Load(), want)
+		}
+	}
+
+	// A buffer reused after a larger value only returns the new one.
+	if got, err := testEchoInto("ab"); err != nil || got != "ab" {
+		t.Errorf("got (%q, %v), want ab", got, err)
+	}
+	if _, err := callInto(42, nil, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("unknown call: got %v, want ErrInvalidArgument", err)
+	}
+	if _, err := callInto(callTestSessionPing, nil, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("NULL session: got %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestCallIntoSession(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	freed := countFreedResults(t)
+	for want := uint64(1); want <= 3; want++ {
+		if n, err := s.testPing(); err != nil || n != want {
+			t.Fatalf("got ping (%d, %v), want %d", n, err, want)
+		}
+	}
+	// The slow path sees the same counter.
+	v, err := s.call(func(ptr *cSession) *cResult {
+		return lib.fduTestSessionPing(ptr)
+	})
+	if err != nil || v != "4" {
+		t.Fatalf("got ping (%q, %v) from the export, want 4", v, err)
+	}
+	if n := freed.Load(); n != 1 {
+		t.Errorf("freed %d results, want only the one of the export", n)
+	}
+	s.Close()
+	if _, err := s.callInto(callTestSessionPing); err != ErrClosed {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
+
+func TestCallIntoConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				value := strings.Repeat(strconv.Itoa(i), j*37%(resultBufferSize+64))
+				if got, err := testEchoInto(value); err != nil || got != value {
+					errs <- fmt.Errorf("goroutine %d: got (%d bytes, %v), want %d bytes", i, len(got), err, len(value))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// BenchmarkSmallResult compares a call returning a small value through a
+// result, freed by an extra call, with the same call through fdu_call_into,
+// which only allocates the Go string.
+func BenchmarkSmallResult(b *testing.B) {
+	s, err := testSession()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.Close()
+	for _, bm := range []struct {
+		name string
+		ping func() (string, error)
+	}{
+		{"result", func() (string, error) {
+			return s.call(func(ptr *cSession) *cResult {
+				return lib.fduTestSessionPing(ptr)
+			})
+		}},
+		{"into", func() (string, error) {
+			return s.callInto(callTestSessionPing)
+		}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			before, statsErr := AllocStats()
+			for i := 0; i < b.N; i++ {
+				if _, err := bm.ping(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			// The allocations of libfdu, which allocs/op does not see,
+			// with the alloc-stats feature.
+			if after, err := AllocStats(); statsErr == nil && err == nil {
+				b.ReportMetric(float64(after.TotalAllocations-before.TotalAllocations)/float64(b.N), "libfdu-allocs/op")
+			}
+		})
+	}
+}
+
+// BenchmarkCallInto measures fdu_call_into for values up to the buffer, and
+// past it, which takes the fallback.
+func BenchmarkCallInto(b *testing.B) {
+	if err := checkInit(); err != nil {
+		b.Fatal(err)
+	}
+	for _, size := range []int{16, 1 << 10, resultBufferSize, 16 << 10} {
+		value := strings.Repeat("x", size)
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				if _, err := testEchoInto(value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package fdu
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// maxRetryAttempts is the most attempts libfdu allows a RetryPolicy.
+const maxRetryAttempts = 10
+
+// retryableCodes are the codes of the errors a RetryPolicy may retry: a call
+// cancelled, or with invalid arguments, would fail the same way again.
+var retryableCodes = []ErrCode{ErrCodeUnknown, ErrCodeNetwork, ErrCodeAuthFailed, ErrCodeParse, ErrCodeQRDisabled}
+
+// RetryPolicy is how libfdu makes a call on a session again when it fails,
+// e.g. when jwfw answers 502 Bad Gateway during course selection. The zero
+// value makes each call once.
+//
+// Only the calls reading from the servers are retried, since sending them
+// twice is harmless: Login, Logout, PaymentQR and the calls changing the
+// account are made once, whatever the policy. When a call still fails after
+// several attempts, its error says how many, e.g. "failed after 4 attempts:
+// 502 Bad Gateway from https://...".
+//
+// Unlike Config.MaxRetries, which sends a single request failing to connect
+// again at once, a policy retries the whole call, after a delay.
+type RetryPolicy struct {
+	// MaxAttempts is the most attempts of a call, including the first one,
+	// at most 10. Zero and one mean no retry.
+	MaxAttempts int
+	// BaseDelay is the delay before the second attempt, doubled before each
+	// of the next ones up to MaxDelay, or without bound if MaxDelay is zero.
+	// Each delay is drawn at random between its half and itself, so that
+	// clients failing together do not retry together.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryOn are the codes of the errors to retry, ErrCodeNetwork only if
+	// empty. ErrCodeInvalidArgument, ErrCodeCancelled, ErrCodePanic,
+	// ErrCodeCaptchaRequired, ErrCodeCertPinMismatch and ErrCodeRateLimited
+	// cannot be retried.
+	RetryOn []ErrCode
+}
+
+// rawRetryPolicy is the JSON form of RetryPolicy for fdu_set_retry_policy,
+// which takes a missing retry_on for empty, but not null.
+type rawRetryPolicy struct {
+	MaxAttempts     int       `json:"max_attempts"`
+	BaseDelayMillis int64     `json:"base_delay_millis"`
+	MaxDelayMillis  int64     `json:"max_delay_millis"`
+	RetryOn         []ErrCode `json:"retry_on,omitempty"`
+}
+
+// WithRetryPolicy makes Init set the default retry policy, like
+// SetRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = &p
+	}
+}
+
+// SetRetryPolicy sets the retry policy of the sessions created afterwards,
+// like SetConfig, and of Announcements. See RetryWith to retry a single call
+// otherwise. An invalid field is reported by an error wrapping
+// ErrInvalidArgument, and the previous policy stays.
+func SetRetryPolicy(p RetryPolicy) error {
+	if err := checkInit(); err != nil {
+		return err
+	}
+	return setRetryPolicy(nil, &p)
+}
+
+// setRetryPolicy sets the policy of session, or the default one if session
+// is nil. A nil p restores the policy session was created with.
+func setRetryPolicy(session *cSession, p *RetryPolicy) error {
+	data := ""
+	if p != nil {
+		raw, err := p.raw()
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	_, err := takeResult(lib.fduSetRetryPolicy(session, data))
+	return err
+}
+
+// raw checks p and converts it to its JSON form.
+func (p RetryPolicy) raw() (rawRetryPolicy, error) {
+	if p.MaxAttempts < 0 || p.MaxAttempts > maxRetryAttempts {
+		return rawRetryPolicy{}, fmt.Errorf("fdu: %w: RetryPolicy.MaxAttempts is %d, not in [0, %d]",
+			ErrInvalidArgument, p.MaxAttempts, maxRetryAttempts)
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"BaseDelay", p.BaseDelay},
+		{"MaxDelay", p.MaxDelay},
+	} {
+		if d.value < 0 {
+			return rawRetryPolicy{}, fmt.Errorf("fdu: %w: RetryPolicy.%s is negative: %v", ErrInvalidArgument, d.name, d.value)
+		}
+	}
+	for _, code := range p.RetryOn {
+		if !slices.Contains(retryableCodes, code) {
+			return rawRetryPolicy{}, fmt.Errorf("fdu: %w: RetryPolicy.RetryOn: %v cannot be retried", ErrInvalidArgument, code)
+		}
+	}
+	return rawRetryPolicy{
+		MaxAttempts:     p.MaxAttempts,
+		BaseDelayMillis: millis(p.BaseDelay),
+		MaxDelayMillis:  millis(p.MaxDelay),
+		RetryOn:         p.RetryOn,
+	}, nil
+}
+
+// CallOption configures a single call on a Session.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	retryPolicy *RetryPolicy
+	traceID     string
+}
+
+// RetryWith makes a call retry with p instead of the policy of the session,
+// e.g. to retry the calls of a course selection harder than the others. It
+// has no effect on the calls which are never retried, see RetryPolicy.
+func RetryWith(p RetryPolicy) CallOption {
+	return func(o *callOptions) {
+		o.retryPolicy = &p
+	}
+}
+
+// applyCallOptions sets the retry policy of the next call on s as asked by
+// opts, holding the lock of s: the policy given by RetryWith, or the one of
+// the session if a previous call replaced it. It returns the options for the
+// job of the call.
+func (s *Session) applyCallOptions(opts []CallOption) (callOptions, error) {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := checkCString("trace ID", o.traceID); err != nil {
+		return o, err
+	}
+	if o.retryPolicy == nil && !s.retryReplaced {
+		return o, nil
+	}
+	if err := setRetryPolicy(s.ptr, o.retryPolicy); err != nil {
+		return o, err
+	}
+	s.retryReplaced = o.retryPolicy != nil
+	return o, nil
+}
@@ -0,0 +1,112 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// retryCall is a call of fdu_set_retry_policy.
+type retryCall struct {
+	session bool
+	policy  string
+}
+
+// recordRetryPolicies records the calls of fdu_set_retry_policy for the rest
+// of the test, which still reach libfdu.
+func recordRetryPolicies(t *testing.T) *[]retryCall {
+	orig := lib.fduSetRetryPolicy
+	t.Cleanup(func() { lib.fduSetRetryPolicy = orig })
+	calls := new([]retryCall)
+	lib.fduSetRetryPolicy = func(session *cSession, policy string) *cResult {
+		*calls = append(*calls, retryCall{session != nil, policy})
+		return orig(session, policy)
+	}
+	return calls
+}
+
+func TestRetryPolicyInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		policy RetryPolicy
+		field  string
+	}{
+		{RetryPolicy{MaxAttempts: -1}, "MaxAttempts"},
+		{RetryPolicy{MaxAttempts: 11}, "MaxAttempts"},
+		{RetryPolicy{BaseDelay: -time.Second}, "BaseDelay"},
+		{RetryPolicy{MaxDelay: -time.Second}, "MaxDelay"},
+		{RetryPolicy{RetryOn: []ErrCode{ErrCodeNetwork, ErrCodeCancelled}}, "RetryOn"},
+		{RetryPolicy{RetryOn: []ErrCode{ErrCodeInvalidArgument}}, "RetryOn"},
+	} {
+		err := SetRetryPolicy(tc.policy)
+		if !errors.Is(err, ErrInvalidArgument) || !strings.Contains(err.Error(), "RetryPolicy."+tc.field) {
+			t.Errorf("%+v: got %v, want ErrInvalidArgument naming %s", tc.policy, err, tc.field)
+		}
+	}
+}
+
+func TestSetRetryPolicy(t *testing.T) {
+	calls := recordRetryPolicies(t)
+	policy := RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    1500 * time.Microsecond,
+		RetryOn:     []ErrCode{ErrCodeNetwork, ErrCodeParse},
+	}
+	if err := SetRetryPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+	defer SetRetryPolicy(RetryPolicy{})
+	if len(*calls) != 1 || (*calls)[0].session {
+		t.Fatalf("got calls %+v, want one for the default policy", *calls)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal([]byte((*calls)[0].policy), &raw); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"max_attempts": 4.0, "base_delay_millis": 100.0, "max_delay_millis": 2.0, "retry_on": []any{2.0, 4.0}}
+	if !reflect.DeepEqual(raw, want) {
+		t.Errorf("got %s, want %v", (*calls)[0].policy, want)
+	}
+}
+
+func TestRetryWith(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	orig := lib.fduCardBalanceAsync
+	t.Cleanup(func() { lib.fduCardBalanceAsync = orig })
+	lib.fduCardBalanceAsync = func(_ *cSession, token *cCancelToken, requestID uint64) *cResult {
+		return lib.fduTestResultAsync("1", 0, 0, token, requestID)
+	}
+	calls := recordRetryPolicies(t)
+	ctx := context.Background()
+
+	// The first call replaces the policy of the session, the second one
+	// restores it, and the third one leaves it alone.
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second}
+	for _, opts := range [][]CallOption{{RetryWith(policy)}, nil, nil} {
+		if _, err := s.CardBalance(ctx, opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []retryCall{
+		{true, `{"max_attempts":3,"base_delay_millis":1000,"max_delay_millis":0}`},
+		{true, ""},
+	}
+	if !reflect.DeepEqual(*calls, want) {
+		t.Errorf("got calls %+v, want %+v", *calls, want)
+	}
+
+	// An invalid policy fails the call before it is made.
+	*calls = nil
+	_, err = s.CardBalance(ctx, RetryWith(RetryPolicy{MaxAttempts: 100}))
+	if !errors.Is(err, ErrInvalidArgument) || len(*calls) != 0 {
+		t.Errorf("got %v and calls %+v, want ErrInvalidArgument", err, *calls)
+	}
+}
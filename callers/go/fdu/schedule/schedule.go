@@ -0,0 +1,172 @@
+// Package schedule compares course tables, e.g. before and after the add and
+// drop week (补退选), and finds the lessons overlapping in a table:
+//
+//	changes := schedule.Diff(before, after)
+//	for _, m := range changes.Moved {
+//		...
+//	}
+//	for _, c := range schedule.Conflicts(after) {
+//		...
+//	}
+//
+// Both work on the lessons of fdu.Course, one per weekday and slots of a
+// course, and take the weeks into account: two courses of odd and even weeks
+// (单双周) in the same slots do not conflict.
+package schedule
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+)
+
+// Changes are the lessons which differ between two course tables, see Diff.
+// Each list is sorted by weekday, slot and CourseID.
+type Changes struct {
+	// Added are the lessons only in the new table.
+	Added []fdu.Course
+	// Removed are the lessons only in the old table.
+	Removed []fdu.Course
+	// Moved are the lessons of the same course in both tables, but at
+	// another time or in another room.
+	Moved []Move
+}
+
+// Move is a lesson moved between two course tables.
+type Move struct {
+	Old, New fdu.Course
+}
+
+// Empty reports whether c has no changes.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Moved) == 0
+}
+
+// Diff returns the changes from the course table old to new.
+//
+// A lesson of new with the same CourseID, Weekday, slots, Weeks and Location
+// as one of old is unchanged, whatever its Name and Teacher. The lessons left
+// of a course are moved: first between those left on the same weekday and
+// slots, which changed their room or weeks, and then in the order of the
+// week. The lessons still left are added or removed, e.g. when a course gets
+// a second lesson each week.
+func Diff(old, new []fdu.Course) Changes {
+	var c Changes
+	oldLeft := make(map[string][]fdu.Course)
+	for _, course := range old {
+		oldLeft[course.CourseID] = append(oldLeft[course.CourseID], course)
+	}
+	newLeft := make(map[string][]fdu.Course)
+	for _, course := range new {
+		lessons := oldLeft[course.CourseID]
+		if i := slices.IndexFunc(lessons, func(l fdu.Course) bool { return sameLesson(l, course) }); i >= 0 {
+			oldLeft[course.CourseID] = slices.Delete(lessons, i, i+1)
+			continue
+		}
+		newLeft[course.CourseID] = append(newLeft[course.CourseID], course)
+	}
+
+	for id, added := range newLeft {
+		removed := oldLeft[id]
+		slices.SortFunc(removed, compareLessons)
+		slices.SortFunc(added, compareLessons)
+		// Same time first, in another room or weeks.
+		added = slices.DeleteFunc(added, func(a fdu.Course) bool {
+			i := slices.IndexFunc(removed, func(r fdu.Course) bool { return sameTime(r, a) })
+			if i < 0 {
+				return false
+			}
+			c.Moved = append(c.Moved, Move{Old: removed[i], New: a})
+			removed = slices.Delete(removed, i, i+1)
+			return true
+		})
+		n := min(len(added), len(removed))
+		for i := range n {
+			c.Moved = append(c.Moved, Move{Old: removed[i], New: added[i]})
+		}
+		c.Added = append(c.Added, added[n:]...)
+		oldLeft[id] = removed[n:]
+	}
+	for _, removed := range oldLeft {
+		c.Removed = append(c.Removed, removed...)
+	}
+
+	slices.SortFunc(c.Added, compareLessons)
+	slices.SortFunc(c.Removed, compareLessons)
+	slices.SortFunc(c.Moved, func(a, b Move) int { return compareLessons(a.Old, b.Old) })
+	return c
+}
+
+// sameTime reports whether a and b are on the same weekday and slots.
+func sameTime(a, b fdu.Course) bool {
+	return a.Weekday == b.Weekday && a.StartSlot == b.StartSlot && a.EndSlot == b.EndSlot
+}
+
+// sameLesson reports whether a and b are the same lesson of the same course.
+func sameLesson(a, b fdu.Course) bool {
+	return a.CourseID == b.CourseID && sameTime(a, b) && a.Location == b.Location && slices.Equal(a.Weeks, b.Weeks)
+}
+
+func compareLessons(a, b fdu.Course) int {
+	return cmp.Or(
+		cmp.Compare(a.Weekday, b.Weekday),
+		cmp.Compare(a.StartSlot, b.StartSlot),
+		cmp.Compare(a.EndSlot, b.EndSlot),
+		cmp.Compare(a.CourseID, b.CourseID),
+		cmp.Compare(a.Location, b.Location),
+		slices.Compare(a.Weeks, b.Weeks),
+	)
+}
+
+// Conflict is two lessons taking place at the same time, see Conflicts.
+type Conflict struct {
+	// A and B are the lessons, A first in the table.
+	A, B fdu.Course
+	// StartSlot and EndSlot are the slots of both, inclusive, on the weekday
+	// of A and B.
+	StartSlot, EndSlot fdu.TimeSlot
+	// Weeks are the weeks of both, in ascending order.
+	Weeks []int
+}
+
+// Conflicts returns the pairs of lessons of courses which take place on the
+// same weekday, in overlapping slots, in at least one common week, in the
+// order of the table. Back-to-back lessons, like slots 1-2 and 3-4, do not
+// conflict, nor do lessons of the same CourseID: a course listed twice, e.g.
+// in two rooms, is a single course.
+func Conflicts(courses []fdu.Course) []Conflict {
+	var conflicts []Conflict
+	for i, a := range courses {
+		for _, b := range courses[i+1:] {
+			if a.CourseID == b.CourseID || a.Weekday != b.Weekday {
+				continue
+			}
+			start, end := max(a.StartSlot, b.StartSlot), min(a.EndSlot, b.EndSlot)
+			if start > end {
+				continue
+			}
+			if weeks := commonWeeks(a.Weeks, b.Weeks); len(weeks) > 0 {
+				conflicts = append(conflicts, Conflict{A: a, B: b, StartSlot: start, EndSlot: end, Weeks: weeks})
+			}
+		}
+	}
+	return conflicts
+}
+
+// commonWeeks returns the weeks in both a and b, in ascending order.
+func commonWeeks(a, b []int) []int {
+	in := make(map[int]bool, len(a))
+	for _, w := range a {
+		in[w] = true
+	}
+	var weeks []int
+	for _, w := range b {
+		if in[w] {
+			weeks = append(weeks, w)
+			delete(in, w)
+		}
+	}
+	slices.Sort(weeks)
+	return weeks
+}
@@ -0,0 +1,223 @@
+package schedule
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+)
+
+func weeks(from, to, step int) []int {
+	var w []int
+	for i := from; i <= to; i += step {
+		w = append(w, i)
+	}
+	return w
+}
+
+var (
+	allWeeks  = weeks(1, 16, 1)
+	oddWeeks  = weeks(1, 15, 2)
+	evenWeeks = weeks(2, 16, 2)
+
+	calculus = fdu.Course{CourseID: "MATH120016.01", Name: "数学分析", Teacher: "张三", Location: "H3108",
+		Weekday: fdu.Monday, StartSlot: 1, EndSlot: 2, Weeks: allWeeks}
+	calculus2 = fdu.Course{CourseID: "MATH120016.01", Name: "数学分析", Teacher: "张三", Location: "H3108",
+		Weekday: fdu.Thursday, StartSlot: 3, EndSlot: 4, Weeks: allWeeks}
+	physics = fdu.Course{CourseID: "PHYS120013.02", Name: "大学物理", Teacher: "李四", Location: "H2220",
+		Weekday: fdu.Monday, StartSlot: 2, EndSlot: 3, Weeks: allWeeks}
+	english = fdu.Course{CourseID: "ENGL110001.05", Name: "大学英语", Teacher: "王五", Location: "H4101",
+		Weekday: fdu.Tuesday, StartSlot: 6, EndSlot: 7, Weeks: oddWeeks}
+	politics = fdu.Course{CourseID: "PTSS110072.10", Name: "思想道德与法治", Teacher: "赵六", Location: "HGX301",
+		Weekday: fdu.Tuesday, StartSlot: 6, EndSlot: 7, Weeks: evenWeeks}
+)
+
+// with returns c changed by f.
+func with(c fdu.Course, f func(*fdu.Course)) fdu.Course {
+	f(&c)
+	return c
+}
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		old, new []fdu.Course
+		want     Changes
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "unchanged",
+			old:  []fdu.Course{calculus, physics, english},
+			new:  []fdu.Course{english, calculus, physics},
+		},
+		{
+			name: "new teacher",
+			old:  []fdu.Course{calculus},
+			new:  []fdu.Course{with(calculus, func(c *fdu.Course) { c.Teacher = "钱七" })},
+		},
+		{
+			name: "added",
+			old:  []fdu.Course{calculus},
+			new:  []fdu.Course{calculus, english, physics},
+			want: Changes{Added: []fdu.Course{physics, english}},
+		},
+		{
+			name: "removed",
+			old:  []fdu.Course{english, calculus, calculus2},
+			new:  []fdu.Course{calculus2},
+			want: Changes{Removed: []fdu.Course{calculus, english}},
+		},
+		{
+			name: "new room",
+			old:  []fdu.Course{calculus, physics},
+			new:  []fdu.Course{with(calculus, func(c *fdu.Course) { c.Location = "HGX508" }), physics},
+			want: Changes{Moved: []Move{{calculus, with(calculus, func(c *fdu.Course) { c.Location = "HGX508" })}}},
+		},
+		{
+			name: "new slots",
+			old:  []fdu.Course{physics},
+			new:  []fdu.Course{with(physics, func(c *fdu.Course) { c.Weekday, c.StartSlot, c.EndSlot = fdu.Friday, 11, 13 })},
+			want: Changes{Moved: []Move{{physics, with(physics, func(c *fdu.Course) { c.Weekday, c.StartSlot, c.EndSlot = fdu.Friday, 11, 13 })}}},
+		},
+		{
+			name: "new weeks",
+			old:  []fdu.Course{english},
+			new:  []fdu.Course{with(english, func(c *fdu.Course) { c.Weeks = allWeeks })},
+			want: Changes{Moved: []Move{{english, with(english, func(c *fdu.Course) { c.Weeks = allWeeks })}}},
+		},
+		{
+			// The lesson of Thursday moved to Friday, the one of Monday did
+			// not.
+			name: "one lesson of two moved",
+			old:  []fdu.Course{calculus, calculus2},
+			new:  []fdu.Course{with(calculus2, func(c *fdu.Course) { c.Weekday = fdu.Friday }), calculus},
+			want: Changes{Moved: []Move{{calculus2, with(calculus2, func(c *fdu.Course) { c.Weekday = fdu.Friday })}}},
+		},
+		{
+			// Both lessons moved: the one which kept its slots changed its
+			// room, and the other one moved.
+			name: "two lessons moved",
+			old:  []fdu.Course{calculus, calculus2},
+			new: []fdu.Course{
+				with(calculus2, func(c *fdu.Course) { c.Weekday = fdu.Wednesday }),
+				with(calculus, func(c *fdu.Course) { c.Location = "H6101" }),
+			},
+			want: Changes{Moved: []Move{
+				{calculus, with(calculus, func(c *fdu.Course) { c.Location = "H6101" })},
+				{calculus2, with(calculus2, func(c *fdu.Course) { c.Weekday = fdu.Wednesday })},
+			}},
+		},
+		{
+			name: "second lesson added",
+			old:  []fdu.Course{calculus},
+			new:  []fdu.Course{calculus, calculus2},
+			want: Changes{Added: []fdu.Course{calculus2}},
+		},
+		{
+			name: "swapped section",
+			old:  []fdu.Course{english},
+			new:  []fdu.Course{with(english, func(c *fdu.Course) { c.CourseID = "ENGL110001.06" })},
+			want: Changes{
+				Added:   []fdu.Course{with(english, func(c *fdu.Course) { c.CourseID = "ENGL110001.06" })},
+				Removed: []fdu.Course{english},
+			},
+		},
+		{
+			name: "duplicate lesson removed",
+			old:  []fdu.Course{physics, physics},
+			new:  []fdu.Course{physics},
+			want: Changes{Removed: []fdu.Course{physics}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Diff(tc.old, tc.new)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+			if got.Empty() != tc.want.Empty() {
+				t.Errorf("Empty() = %v", got.Empty())
+			}
+		})
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	physicsAt := func(weekday fdu.Weekday, start, end fdu.TimeSlot) fdu.Course {
+		return with(physics, func(c *fdu.Course) { c.Weekday, c.StartSlot, c.EndSlot = weekday, start, end })
+	}
+	englishWeekly := with(english, func(c *fdu.Course) { c.Weeks = allWeeks })
+	calculusShuffled := with(calculus, func(c *fdu.Course) { c.Weeks = []int{9, 3, 1} })
+	physicsShuffled := with(physics, func(c *fdu.Course) { c.Weeks = []int{1, 9, 4} })
+	englishFirstHalf := with(english, func(c *fdu.Course) { c.Weeks = weeks(1, 8, 1) })
+	calculusLong := with(calculus, func(c *fdu.Course) { c.EndSlot = 5 })
+	for _, tc := range []struct {
+		name    string
+		courses []fdu.Course
+		want    []Conflict
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "overlapping slots",
+			courses: []fdu.Course{calculus, physics},
+			want:    []Conflict{{A: calculus, B: physics, StartSlot: 2, EndSlot: 2, Weeks: allWeeks}},
+		},
+		{
+			name:    "back to back",
+			courses: []fdu.Course{calculus, physicsAt(fdu.Monday, 3, 4)},
+		},
+		{
+			name:    "other weekday",
+			courses: []fdu.Course{calculus, physicsAt(fdu.Tuesday, 2, 3)},
+		},
+		{
+			name:    "odd and even weeks",
+			courses: []fdu.Course{english, politics},
+		},
+		{
+			name:    "partial week overlap",
+			courses: []fdu.Course{englishFirstHalf, politics},
+			want:    []Conflict{{A: englishFirstHalf, B: politics, StartSlot: 6, EndSlot: 7, Weeks: []int{2, 4, 6, 8}}},
+		},
+		{
+			name:    "weeks out of order",
+			courses: []fdu.Course{calculusShuffled, physicsShuffled},
+			want:    []Conflict{{A: calculusShuffled, B: physicsShuffled, StartSlot: 2, EndSlot: 2, Weeks: []int{1, 9}}},
+		},
+		{
+			name:    "identical courses",
+			courses: []fdu.Course{physics, physics},
+		},
+		{
+			name:    "same course in two rooms",
+			courses: []fdu.Course{calculus, with(calculus, func(c *fdu.Course) { c.Location = "H3109" })},
+		},
+		{
+			name:    "nested slots",
+			courses: []fdu.Course{calculusLong, physics},
+			want:    []Conflict{{A: calculusLong, B: physics, StartSlot: 2, EndSlot: 3, Weeks: allWeeks}},
+		},
+		{
+			name:    "no weeks",
+			courses: []fdu.Course{calculus, with(physics, func(c *fdu.Course) { c.Weeks = nil })},
+		},
+		{
+			name:    "three at once",
+			courses: []fdu.Course{englishWeekly, politics, physicsAt(fdu.Tuesday, 7, 8)},
+			want: []Conflict{
+				{A: englishWeekly, B: politics, StartSlot: 6, EndSlot: 7, Weeks: evenWeeks},
+				{A: englishWeekly, B: physicsAt(fdu.Tuesday, 7, 8), StartSlot: 7, EndSlot: 7, Weeks: allWeeks},
+				{A: politics, B: physicsAt(fdu.Tuesday, 7, 8), StartSlot: 7, EndSlot: 7, Weeks: evenWeeks},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Conflicts(tc.courses); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
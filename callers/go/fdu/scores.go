@@ -0,0 +1,137 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Score is the final grade of a course.
+type Score struct {
+	// Semester is e.g. "2022-2023 1".
+	Semester string `json:"semester"`
+	// CourseID is the id of the class, e.g. COMP130004.03.
+	CourseID string  `json:"course_id"`
+	Name     string  `json:"name"`
+	Credit   float64 `json:"credit"`
+	// Grade is e.g. "A-", "P" or "NP".
+	Grade string `json:"grade"`
+	// Point is the GPA point of Grade, or nil if the grade has none, e.g.
+	// pass/fail (P/NP) courses, which are not counted in GPA.
+	Point *float64 `json:"point"`
+}
+
+// GPAReport is the GPA of the student, with the ranking within the major
+// for undergraduates, and the progress in the training plan for graduate
+// students.
+type GPAReport struct {
+	GPA     float64 `json:"gpa"`
+	Credits float64 `json:"credits"`
+	Major   string  `json:"major"`
+	// Ranking is nil for graduate students, who are not ranked.
+	Ranking *GPARanking `json:"-"`
+	// Progress is the progress in the training plan (培养计划), nil for
+	// undergraduates.
+	Progress *PlanProgress `json:"progress"`
+	// Rank and Total are those of Ranking, or zero if it is nil.
+	//
+	// Deprecated: use Ranking, which tells graduate students apart.
+	Rank  int `json:"ranking"`
+	Total int `json:"total"`
+}
+
+// GPARanking is the ranking of an undergraduate within the major.
+type GPARanking struct {
+	// Rank is counted from 1 among the Total students of the major.
+	Rank  int
+	Total int
+}
+
+// PlanProgress is the progress of a graduate student in the training plan.
+type PlanProgress struct {
+	// RequiredCredits are the credits required by the plan, and
+	// EarnedCredits those earned so far.
+	RequiredCredits float64 `json:"required_credits"`
+	EarnedCredits   float64 `json:"earned_credits"`
+}
+
+// Scores returns the scores of the semester, given by one of the IDs of
+// Semesters or by its school year and term.
+func (s *Session) Scores(ctx context.Context, semesterID SemesterID, opts ...CallOption) ([]Score, error) {
+	semesterID, err := s.semesterID(ctx, semesterID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return cached(s, ResourceScores, string(semesterID), func() (string, error) {
+		return s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+			return lib.fduScoresAsync(ptr, string(semesterID), token, id)
+		}, opts...)
+	}, parseScores)
+}
+
+// ScoresString is Scores with the semester ID as a string.
+//
+// Deprecated: Scores takes a SemesterID, see ParseSemesterID. ScoresString
+// will be removed in the next release.
+func (s *Session) ScoresString(ctx context.Context, semesterID string) ([]Score, error) {
+	return s.Scores(ctx, SemesterID(semesterID))
+}
+
+// GPA returns the GPA of the student, with the ranking or the progress in
+// the training plan depending on the StudentType.
+func (s *Session) GPA(ctx context.Context, opts ...CallOption) (*GPAReport, error) {
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduGPAAsync(ptr, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parseGPA([]byte(v))
+}
+
+func parseScores(data []byte) ([]Score, error) {
+	var scores []Score
+	if err := json.Unmarshal(data, &scores); err != nil {
+		return nil, parseError("scores: %v", err)
+	}
+	for _, score := range scores {
+		if score.Credit < 0 {
+			return nil, parseError("score %s: invalid credit %v", score.CourseID, score.Credit)
+		}
+		if score.Point != nil && (*score.Point < 0 || *score.Point > 4) {
+			return nil, parseError("score %s: invalid point %v", score.CourseID, *score.Point)
+		}
+	}
+	return scores, nil
+}
+
+func parseGPA(data []byte) (*GPAReport, error) {
+	var report GPAReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, parseError("gpa: %v", err)
+	}
+	// A null ranking leaves Rank zero.
+	var ranking struct {
+		Rank *int `json:"ranking"`
+	}
+	if err := json.Unmarshal(data, &ranking); err != nil {
+		return nil, parseError("gpa: %v", err)
+	}
+	if report.GPA < 0 || report.GPA > 4 {
+		return nil, parseError("gpa: invalid gpa %v", report.GPA)
+	}
+	if report.Credits < 0 {
+		return nil, parseError("gpa: invalid credits %v", report.Credits)
+	}
+	switch {
+	case ranking.Rank == nil && report.Progress != nil:
+		// A graduate student.
+	case report.Rank < 1 || report.Rank > report.Total:
+		return nil, parseError("gpa: invalid ranking %d/%d", report.Rank, report.Total)
+	default:
+		report.Ranking = &GPARanking{Rank: report.Rank, Total: report.Total}
+	}
+	if p := report.Progress; p != nil && (p.RequiredCredits < 0 || p.EarnedCredits < 0) {
+		return nil, parseError("gpa: invalid progress %+v", *p)
+	}
+	return &report, nil
+}
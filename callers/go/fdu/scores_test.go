@@ -0,0 +1,90 @@
+package fdu
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestParseScores(t *testing.T) {
+	data, err := os.ReadFile("testdata/scores.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores, err := parseScores(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 4 {
+		t.Fatalf("got %d scores, want 4", len(scores))
+	}
+
+	if ds := scores[0]; ds.Grade != "A-" || ds.Credit != 3 || ds.Point == nil || *ds.Point != 3.7 {
+		t.Errorf("got %+v", ds)
+	}
+	// F counts as 0 in GPA, unlike P/NP.
+	if phys := scores[1]; phys.Point == nil || *phys.Point != 0 {
+		t.Errorf("got %+v", phys)
+	}
+	for _, score := range scores[2:] {
+		if score.Point != nil {
+			t.Errorf("%s: got point %v for grade %s, want nil", score.Name, *score.Point, score.Grade)
+		}
+	}
+}
+
+func TestParseScoresInvalid(t *testing.T) {
+	cases := map[string]string{
+		"not json":        `<html>`,
+		"negative credit": `[{"course_id": "A", "credit": -1, "grade": "A", "point": 4}]`,
+		"point too large": `[{"course_id": "A", "credit": 1, "grade": "A", "point": 5}]`,
+	}
+	for name, data := range cases {
+		if _, err := parseScores([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", name, err)
+		}
+	}
+}
+
+func TestParseGPA(t *testing.T) {
+	data, err := os.ReadFile("testdata/gpa.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := parseGPA(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GPAReport{GPA: 3.52, Credits: 62.5, Major: "计算机科学与技术", Rank: 12, Total: 130}
+	if ranking := report.Ranking; ranking == nil || *ranking != (GPARanking{Rank: 12, Total: 130}) {
+		t.Errorf("got ranking %+v, want 12/130", ranking)
+	}
+	report.Ranking = nil
+	if *report != want {
+		t.Errorf("got %+v, want %+v", *report, want)
+	}
+	if _, err := parseGPA([]byte(`{"gpa": 3.5, "ranking": 0, "total": 0}`)); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v, want ErrParse", err)
+	}
+}
+
+func FuzzParseScores(f *testing.F) {
+	fuzzParse(f, []string{"scores.json", "scores_graduate.json"}, nil, parseScores, func(t *testing.T, scores []Score) {
+		for _, score := range scores {
+			if score.Credit < 0 || score.Point != nil && (*score.Point < 0 || *score.Point > 4) {
+				t.Fatalf("accepted the invalid score %+v", score)
+			}
+		}
+	})
+}
+
+func FuzzParseGPA(f *testing.F) {
+	fuzzParse(f, []string{"gpa.json", "gpa_graduate.json"}, nil, parseGPA, func(t *testing.T, report *GPAReport) {
+		if report.GPA < 0 || report.GPA > 4 || report.Credits < 0 {
+			t.Fatalf("accepted the invalid GPA %+v", report)
+		}
+		if r := report.Ranking; r != nil && (r.Rank < 1 || r.Rank > r.Total) {
+			t.Fatalf("accepted the invalid ranking %+v", *r)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"slices"
+	"sync/atomic"
+)
+
+// writesAllowed is set by Init with AllowWrites.
+var writesAllowed atomic.Bool
+
+// AllowWrites makes Init enable the methods changing the account, e.g.
+// Session.Enroll and Session.Drop, which otherwise return
+// ErrWritesNotAllowed, so that a program does not change the enrollment of a
+// student by mistake. Unlike the other options, it applies even if the
+// library is already initialized, until Shutdown.
+func AllowWrites() Option {
+	return func(o *options) {
+		o.allowWrites = true
+	}
+}
+
+// checkWrites returns ErrWritesNotAllowed unless Init was called with
+// AllowWrites.
+func checkWrites() error {
+	if err := checkInit(); err != nil {
+		return err
+	}
+	if !writesAllowed.Load() {
+		return ErrWritesNotAllowed
+	}
+	return nil
+}
+
+// SelectableCourse is a lesson open to course selection (选课).
+type SelectableCourse struct {
+	// LessonID is the id to pass to Session.Enroll, e.g. 698241.
+	LessonID int64 `json:"lesson_id"`
+	// CourseID is the id of the class, e.g. ECON130003.01.
+	CourseID string `json:"course_id"`
+	Name     string `json:"name"`
+	// Department is e.g. "经济学院".
+	Department string `json:"department"`
+	// Teachers are separated by commas, e.g. "陈钊,王永钦".
+	Teachers string  `json:"teachers"`
+	Credits  float64 `json:"credits"`
+	// Campus is e.g. "邯郸校区".
+	Campus string `json:"campus"`
+	// Capacity is the number of seats, of which Enrolled are taken. xk
+	// refreshes them lazily, see Selection.RetryLater.
+	Capacity int `json:"capacity"`
+	Enrolled int `json:"enrolled"`
+}
+
+// CourseQuery filters the courses of Session.SelectableCourses. The zero
+// value matches every course.
+type CourseQuery struct {
+	// Department is the exact name of the department, e.g. "经济学院".
+	Department string `json:"department,omitempty"`
+	// Keyword is part of the name, the course id or the teachers.
+	Keyword string `json:"keyword,omitempty"`
+	// MinCredits and MaxCredits bound the credits, both inclusive. A zero
+	// MaxCredits is unbounded.
+	MinCredits float64 `json:"min_credits,omitempty"`
+	MaxCredits float64 `json:"max_credits,omitempty"`
+}
+
+// SelectionOutcome is how xk answered an enrollment or a drop.
+type SelectionOutcome string
+
+const (
+	OutcomeEnrolled SelectionOutcome = "enrolled"
+	// OutcomeWaitlisted is an enrollment on the waiting list of the course,
+	// or in its lottery.
+	OutcomeWaitlisted   SelectionOutcome = "waitlisted"
+	OutcomeFull         SelectionOutcome = "full"
+	OutcomeTimeConflict SelectionOutcome = "time_conflict"
+	OutcomeCreditLimit  SelectionOutcome = "credit_limit"
+	OutcomeDropped      SelectionOutcome = "dropped"
+)
+
+// selectionOutcomes are the outcomes reported by libfdu.
+var selectionOutcomes = []SelectionOutcome{
+	OutcomeEnrolled, OutcomeWaitlisted, OutcomeFull, OutcomeTimeConflict, OutcomeCreditLimit, OutcomeDropped,
+}
+
+// Selection is the result of Session.Enroll or Session.Drop. Only
+// OutcomeEnrolled, OutcomeWaitlisted and OutcomeDropped change the
+// enrollment.
+type Selection struct {
+	Outcome SelectionOutcome `json:"outcome"`
+	// Message is the answer of xk, e.g. "国际金融(ECON130003.01)选课成功".
+	Message string `json:"message"`
+	// RetryLater is set with OutcomeFull when xk is refreshing the quota of
+	// the course, during which it reports every course full even though
+	// SelectableCourse.Enrolled may be below the capacity: seats may free up
+	// shortly.
+	RetryLater bool `json:"retry_later"`
+}
+
+// SelectableCourses returns the courses open to selection matching query.
+// The first call of SelectableCourses, Enroll or Drop on a session logs in xk
+// (选课系统), whose token is kept by the session and renewed when it expires.
+func (s *Session) SelectableCourses(ctx context.Context, query CourseQuery, opts ...CallOption) ([]SelectableCourse, error) {
+	if err := checkCourseQuery(query); err != nil {
+		return nil, err
+	}
+	// The strings are those of query, which always marshal.
+	data, _ := json.Marshal(query)
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduSelectableCoursesAsync(ptr, string(data), token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parseSelectableCourses([]byte(v))
+}
+
+// Enroll enrolls the student in the lesson of a SelectableCourse. The
+// answers of xk about the course are reported by the outcome of the
+// Selection, e.g. OutcomeFull, and other failures, e.g. outside the selection
+// period, by an error. Enroll needs AllowWrites, and is never retried.
+func (s *Session) Enroll(ctx context.Context, lessonID int64, opts ...CallOption) (*Selection, error) {
+	return s.selectCourse(ctx, lessonID, lib.fduEnrollAsync, opts)
+}
+
+// Drop drops the lesson of a SelectableCourse, like Enroll, with
+// OutcomeDropped on success.
+func (s *Session) Drop(ctx context.Context, lessonID int64, opts ...CallOption) (*Selection, error) {
+	return s.selectCourse(ctx, lessonID, lib.fduDropAsync, opts)
+}
+
+func (s *Session) selectCourse(ctx context.Context, lessonID int64,
+	export func(session *cSession, lessonID int64, token *cCancelToken, requestID uint64) *cResult, opts []CallOption) (*Selection, error) {
+	if err := checkWrites(); err != nil {
+		return nil, err
+	}
+	if lessonID <= 0 {
+		return nil, argumentError("invalid lesson id %d", lessonID)
+	}
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return export(ptr, lessonID, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parseSelection([]byte(v))
+}
+
+func checkCourseQuery(query CourseQuery) error {
+	if err := checkCStrings("department", query.Department, "keyword", query.Keyword); err != nil {
+		return err
+	}
+	for _, c := range []float64{query.MinCredits, query.MaxCredits} {
+		if c < 0 || math.IsNaN(c) || math.IsInf(c, 0) {
+			return argumentError("invalid credits %v", c)
+		}
+	}
+	if query.MaxCredits != 0 && query.MinCredits > query.MaxCredits {
+		return argumentError("MinCredits %v above MaxCredits %v", query.MinCredits, query.MaxCredits)
+	}
+	return nil
+}
+
+func parseSelectableCourses(data []byte) ([]SelectableCourse, error) {
+	var courses []SelectableCourse
+	if err := json.Unmarshal(data, &courses); err != nil {
+		return nil, parseError("selectable courses: %v", err)
+	}
+	for _, course := range courses {
+		switch {
+		case course.LessonID <= 0:
+			return nil, parseError("selectable course %s: invalid lesson_id %d", course.CourseID, course.LessonID)
+		case course.Credits < 0:
+			return nil, parseError("selectable course %s: invalid credits %v", course.CourseID, course.Credits)
+		case course.Capacity < 0 || course.Enrolled < 0:
+			return nil, parseError("selectable course %s: invalid enrolled/capacity %d/%d", course.CourseID, course.Enrolled, course.Capacity)
+		}
+	}
+	return courses, nil
+}
+
+func parseSelection(data []byte) (*Selection, error) {
+	var selection Selection
+	if err := json.Unmarshal(data, &selection); err != nil {
+		return nil, parseError("selection: %v", err)
+	}
+	if !slices.Contains(selectionOutcomes, selection.Outcome) {
+		return nil, parseError("selection: unknown outcome %q", selection.Outcome)
+	}
+	return &selection, nil
+}
@@ -0,0 +1,186 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+// allowTestWrites sets AllowWrites until the end of the test.
+func allowTestWrites(t *testing.T) {
+	writesAllowed.Store(true)
+	t.Cleanup(func() { writesAllowed.Store(false) })
+}
+
+func TestParseSelectableCourses(t *testing.T) {
+	data, err := os.ReadFile("testdata/selectable_courses.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	courses, err := parseSelectableCourses(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(courses) != 3 {
+		t.Fatalf("got %d courses, want 3", len(courses))
+	}
+	want := SelectableCourse{
+		LessonID: 698241, CourseID: "ECON130003.01", Name: "国际金融", Department: "经济学院", Teachers: "郑辉",
+		Credits: 3, Campus: "邯郸校区", Capacity: 100, Enrolled: 70,
+	}
+	if courses[0] != want {
+		t.Errorf("got %+v, want %+v", courses[0], want)
+	}
+	if _, err := parseSelectableCourses([]byte(`{"lesson_id": 1}`)); !errors.Is(err, ErrParse) {
+		t.Errorf("got %v, want ErrParse", err)
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		want Selection
+	}{
+		{`{"outcome":"enrolled","message":"选课成功","retry_later":false}`, Selection{OutcomeEnrolled, "选课成功", false}},
+		{`{"outcome":"waitlisted","message":"已进入候补名单","retry_later":false}`, Selection{OutcomeWaitlisted, "已进入候补名单", false}},
+		{`{"outcome":"full","message":"人数已满,名额刷新中","retry_later":true}`, Selection{OutcomeFull, "人数已满,名额刷新中", true}},
+		{`{"outcome":"time_conflict","message":"时间冲突","retry_later":false}`, Selection{OutcomeTimeConflict, "时间冲突", false}},
+		{`{"outcome":"credit_limit","message":"超过学分上限","retry_later":false}`, Selection{OutcomeCreditLimit, "超过学分上限", false}},
+		{`{"outcome":"dropped","message":"退课成功","retry_later":false}`, Selection{OutcomeDropped, "退课成功", false}},
+	} {
+		got, err := parseSelection([]byte(tc.data))
+		if err != nil || *got != tc.want {
+			t.Errorf("%s: got (%+v, %v), want %+v", tc.data, got, err, tc.want)
+		}
+	}
+	for _, data := range []string{`{"outcome":"maybe"}`, `{}`, `[]`} {
+		if _, err := parseSelection([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", data, err)
+		}
+	}
+}
+
+func TestSelectableCoursesQuery(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	data, err := os.ReadFile("testdata/selectable_courses.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := lib.fduSelectableCoursesAsync
+	t.Cleanup(func() { lib.fduSelectableCoursesAsync = orig })
+	var got string
+	lib.fduSelectableCoursesAsync = func(_ *cSession, query string, token *cCancelToken, requestID uint64) *cResult {
+		got = query
+		return lib.fduTestResultAsync(string(data), 0, 0, token, requestID)
+	}
+	ctx := context.Background()
+
+	courses, err := s.SelectableCourses(ctx, CourseQuery{Department: "经济学院", MinCredits: 3})
+	if err != nil || len(courses) != 3 {
+		t.Fatalf("got (%+v, %v)", courses, err)
+	}
+	if want := `{"department":"经济学院","min_credits":3}`; got != want {
+		t.Errorf("got query %s, want %s", got, want)
+	}
+	if _, err := s.SelectableCourses(ctx, CourseQuery{}); err != nil || got != "{}" {
+		t.Errorf("got query %s and %v for the zero query", got, err)
+	}
+
+	got = ""
+	for _, query := range []CourseQuery{
+		{MinCredits: -1},
+		{MinCredits: 4, MaxCredits: 2},
+		{Keyword: "数学\x00"},
+	} {
+		if _, err := s.SelectableCourses(ctx, query); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%+v: got %v, want ErrInvalidArgument", query, err)
+		}
+	}
+	if got != "" {
+		t.Errorf("an invalid query reached libfdu: %s", got)
+	}
+}
+
+func TestEnrollNeedsAllowWrites(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	origs := lib
+	t.Cleanup(func() { lib = origs })
+	calls := 0
+	answer := func(value string) func(*cSession, int64, *cCancelToken, uint64) *cResult {
+		return func(_ *cSession, _ int64, token *cCancelToken, requestID uint64) *cResult {
+			calls++
+			return lib.fduTestResultAsync(value, 0, 0, token, requestID)
+		}
+	}
+	lib.fduEnrollAsync = answer(`{"outcome":"full","message":"人数已满,名额刷新中","retry_later":true}`)
+	lib.fduDropAsync = answer(`{"outcome":"dropped","message":"退课成功","retry_later":false}`)
+	ctx := context.Background()
+
+	if _, err := s.Enroll(ctx, 698241); !errors.Is(err, ErrWritesNotAllowed) {
+		t.Errorf("enroll: got %v, want ErrWritesNotAllowed", err)
+	}
+	if _, err := s.Drop(ctx, 698241); !errors.Is(err, ErrWritesNotAllowed) {
+		t.Errorf("drop: got %v, want ErrWritesNotAllowed", err)
+	}
+	if calls != 0 {
+		t.Fatalf("%d calls reached libfdu without AllowWrites", calls)
+	}
+
+	// AllowWrites applies although the library is initialized.
+	t.Cleanup(func() { writesAllowed.Store(false) })
+	if err := Init(AllowWrites()); err != nil {
+		t.Fatal(err)
+	}
+	selection, err := s.Enroll(ctx, 698241)
+	if err != nil || selection.Outcome != OutcomeFull || !selection.RetryLater {
+		t.Errorf("enroll: got (%+v, %v)", selection, err)
+	}
+	selection, err = s.Drop(ctx, 698241)
+	if err != nil || selection.Outcome != OutcomeDropped {
+		t.Errorf("drop: got (%+v, %v)", selection, err)
+	}
+	if _, err := s.Enroll(ctx, 0); !errors.Is(err, ErrInvalidArgument) || calls != 2 {
+		t.Errorf("got %v after %d calls, want ErrInvalidArgument", err, calls)
+	}
+}
+
+func TestEnrollFails(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	allowTestWrites(t)
+	orig := lib.fduEnrollAsync
+	t.Cleanup(func() { lib.fduEnrollAsync = orig })
+	lib.fduEnrollAsync = func(_ *cSession, _ int64, token *cCancelToken, requestID uint64) *cResult {
+		return lib.fduTestResultAsync("", int32(ErrCodeUnknown), 0, token, requestID)
+	}
+	selection, err := s.Enroll(context.Background(), 698241)
+	if !errors.Is(err, ErrUnknown) || selection != nil {
+		t.Errorf("got (%+v, %v), want ErrUnknown", selection, err)
+	}
+}
+
+func FuzzParseSelectableCourses(f *testing.F) {
+	fuzzParse(f, []string{"selectable_courses.json"}, nil, parseSelectableCourses, func(t *testing.T, courses []SelectableCourse) {
+		for _, c := range courses {
+			if c.LessonID <= 0 || c.Credits < 0 || c.Capacity < 0 || c.Enrolled < 0 {
+				t.Fatalf("accepted the invalid course %+v", c)
+			}
+		}
+	})
+}
+
+func FuzzParseSelection(f *testing.F) {
+	fuzzParse(f, nil, []string{`{"outcome":"enrolled","message":"选课成功","retry_later":false}`}, parseSelection, nil)
+}
@@ -0,0 +1,193 @@
+package fdu
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Session is a logged-in UIS session, backed by a handle owned by libfdu.
+//
+// Call Close when the session is no longer needed. A finalizer frees the
+// handle if Close is never called, but relying on it delays the release of
+// the memory held by the library until the next garbage collection.
+//
+// A Session is safe for concurrent use by multiple goroutines. The handle
+// itself is not thread safe on the Rust side, so the calls on the same
+// Session are serialized; use several sessions to run calls in parallel.
+type Session struct {
+	// lock is held (i.e. full) during a call on ptr. It is a channel rather
+	// than a mutex so that waiting for it can be cancelled, and so that the
+	// poller can release it when an abandoned call completes.
+	lock chan struct{}
+	// freeing is held for writing while Close frees ptr, which is thus read
+	// under lock, or under freeing by the methods which do not wait for the
+	// call in flight, e.g. RateLimitStats.
+	freeing sync.RWMutex
+	ptr     *cSession
+	// retryReplaced is set, under lock, while the retry policy of ptr is the
+	// one of a call made with RetryWith.
+	retryReplaced bool
+	// account is the directory of the cache files of the session, once
+	// looked up by cacheAccount.
+	account atomic.Pointer[string]
+}
+
+// Login logs in to UIS with the given credentials. A wrong username or
+// password is reported as an error wrapping ErrAuthFailed, and one with a NUL
+// byte or invalid UTF-8 is rejected with an error wrapping ErrInvalidArgument
+// before reaching UIS. If UIS asks for a captcha, Login returns a
+// *CaptchaRequiredError: see LoginWithCaptcha.
+func Login(ctx context.Context, username, password string) (*Session, error) {
+	if err := checkCStrings("username", username, "password", password); err != nil {
+		return nil, err
+	}
+	return callContext(ctx, func(token *cCancelToken) (*Session, error) {
+		var ptr *cSession
+		if _, err := takeResult(lib.fduLogin(username, password, token, &ptr)); err != nil {
+			return nil, err
+		}
+		// If ctx is done in the meantime, the finalizer frees the session.
+		return newSession(ptr), nil
+	})
+}
+
+// Restore reconstructs a session exported by Session.Export, without logging
+// in to UIS again. A truncated or corrupted blob is rejected with an error
+// wrapping ErrInvalidArgument. An expired session is restored successfully,
+// but reported as invalid by Session.Valid.
+func Restore(blob []byte) (*Session, error) {
+	if err := checkInit(); err != nil {
+		return nil, err
+	}
+	data, n := cBytes(blob)
+	var ptr *cSession
+	if _, err := takeResult(lib.fduSessionRestore(data, n, &ptr)); err != nil {
+		return nil, err
+	}
+	return newSession(ptr), nil
+}
+
+func newSession(ptr *cSession) *Session {
+	s := &Session{lock: make(chan struct{}, 1), ptr: ptr}
+	runtime.SetFinalizer(s, (*Session).Close)
+	return s
+}
+
+// call runs f with the handle of s, holding the lock of s, and converts the
+// result returned by f.
+func (s *Session) call(f func(ptr *cSession) *cResult) (string, error) {
+	return s.locked(func(ptr *cSession) (string, error) {
+		return takeResult(f(ptr))
+	})
+}
+
+// callInto is like call for c, made with callInto.
+func (s *Session) callInto(c call) (string, error) {
+	return s.locked(func(ptr *cSession) (string, error) {
+		return callInto(c, ptr, "")
+	})
+}
+
+// locked runs f with the handle of s, holding the lock of s.
+func (s *Session) locked(f func(ptr *cSession) (string, error)) (string, error) {
+	if err := checkTraceCallback(); err != nil {
+		return "", err
+	}
+	s.lock <- struct{}{}
+	defer s.unlock()
+	if s.ptr == nil {
+		return "", ErrClosed
+	}
+	if err := checkInit(); err != nil {
+		return "", err
+	}
+	return f(s.ptr)
+}
+
+func (s *Session) unlock() {
+	<-s.lock
+}
+
+// callContext is like call, but f submits a job to an _async export with the
+// cancellation token and request id it is given, and the lock of s is held
+// until the job completes. See runJob. opts apply to the job.
+func (s *Session) callContext(ctx context.Context, f func(ptr *cSession, token *cCancelToken, id uint64) *cResult, opts ...CallOption) (string, error) {
+	if err := checkTraceCallback(); err != nil {
+		return "", err
+	}
+	select {
+	case s.lock <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if s.ptr == nil {
+		s.unlock()
+		return "", ErrClosed
+	}
+	if err := checkInit(); err != nil {
+		s.unlock()
+		return "", err
+	}
+	o, err := s.applyCallOptions(opts)
+	if err != nil {
+		s.unlock()
+		return "", err
+	}
+	ptr := s.ptr
+	return runJob(ctx, o.traceID, s.unlock, func(token *cCancelToken, id uint64) *cResult {
+		return f(ptr, token, id)
+	})
+}
+
+// Logout logs the session out of UIS. The session still needs to be closed
+// afterwards.
+func (s *Session) Logout(ctx context.Context, opts ...CallOption) error {
+	_, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduSessionLogoutAsync(ptr, token, id)
+	}, opts...)
+	return err
+}
+
+// Export serializes the session into an opaque blob for Restore, e.g. to
+// save it on disk so that a CLI does not log in on every run, which may get
+// the account locked by UIS. The blob grants access to the account like the
+// password does: keep it secret. It does not contain the password.
+func (s *Session) Export() ([]byte, error) {
+	var buf *cBuffer
+	_, err := s.call(func(ptr *cSession) *cResult {
+		return lib.fduSessionExport(ptr, &buf)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return takeBuffer(buf), nil
+}
+
+// Valid reports whether the session is still logged in, with a cheap
+// request to UIS. Callers can log in again if it is not.
+func (s *Session) Valid(ctx context.Context, opts ...CallOption) (bool, error) {
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduSessionValidAsync(ptr, token, id)
+	}, opts...)
+	if err != nil {
+		return false, err
+	}
+	return v == "true", nil
+}
+
+// Close frees the session. It is safe to call Close more than once.
+func (s *Session) Close() error {
+	s.lock <- struct{}{}
+	defer s.unlock()
+	if s.ptr == nil {
+		return nil
+	}
+	s.freeing.Lock()
+	defer s.freeing.Unlock()
+	lib.fduSessionFree(s.ptr)
+	s.ptr = nil
+	runtime.SetFinalizer(s, nil)
+	return nil
+}
@@ -0,0 +1,170 @@
+package fdu
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWrongLogin(t *testing.T) {
+	s, err := Login(context.Background(), "123", "123")
+	if err == nil {
+		s.Close()
+		t.Fatal("expect error")
+	}
+	if s != nil {
+		t.Errorf("got session %v with error %v", s, err)
+	}
+	if !errors.Is(err, ErrAuthFailed) && !errors.Is(err, ErrNetwork) {
+		t.Errorf("got %v, want ErrAuthFailed", err)
+	}
+}
+
+func TestSessionClose(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Logout(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
+
+func TestSessionFinalizer(t *testing.T) {
+	base := testLiveSessions()
+	start := getMemoryUsage()
+	for i := 0; i < 100000; i++ {
+		if _, err := testSession(); err != nil {
+			t.Fatal(err)
+		}
+		if i%1000 == 0 {
+			runtime.GC()
+		}
+	}
+
+	// Finalizers run in a separate goroutine, give them some time.
+	deadline := time.Now().Add(10 * time.Second)
+	for testLiveSessions() != base && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	end := getMemoryUsage()
+	usage := int(math.Abs(float64(end - start)))
+	fmt.Printf("Memory usage: %d bytes", usage)
+
+	if live := testLiveSessions(); live != base {
+		t.Errorf("%d sessions leaked", live-base)
+	}
+}
+
+func TestSessionExportRestore(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	blob, err := s.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blob) == 0 {
+		t.Fatal("empty blob")
+	}
+
+	restored, err := Restore(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	again, err := restored.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, blob) {
+		t.Errorf("got %q after a round trip, want %q", again, blob)
+	}
+
+	// The test session has never logged in, like an expired one: it is
+	// restored fine but not valid.
+	if ok, err := restored.Valid(context.Background()); ok {
+		t.Errorf("got valid session, err %v", err)
+	}
+
+	s.Close()
+	if _, err := s.Export(); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
+
+func TestSessionRestoreInvalid(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := s.Export()
+	s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupted := bytes.Clone(blob)
+	corrupted[len(corrupted)-1] ^= 0xff
+	cases := map[string][]byte{
+		"nil":       nil,
+		"garbage":   []byte("not a session"),
+		"truncated": blob[:len(blob)-1],
+		"header":    blob[:5],
+		"corrupted": corrupted,
+	}
+	for name, blob := range cases {
+		restored, err := Restore(blob)
+		if !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: got %v, want ErrInvalidArgument", name, err)
+		}
+		if restored != nil {
+			restored.Close()
+			t.Errorf("%s: got a session", name)
+		}
+	}
+}
+
+func TestSessionRestoreMemoryLeak(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := s.Export()
+	s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baseSessions, baseBuffers := testLiveSessions(), testLiveBuffers()
+	for i := 0; i < 100000; i++ {
+		restored, err := Restore(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := restored.Export(); err != nil {
+			t.Fatal(err)
+		}
+		restored.Close()
+	}
+	if live := testLiveSessions(); live != baseSessions {
+		t.Errorf("%d sessions leaked", live-baseSessions)
+	}
+	if live := testLiveBuffers(); live != baseBuffers {
+		t.Errorf("%d buffers leaked", live-baseBuffers)
+	}
+}
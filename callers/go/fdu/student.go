@@ -0,0 +1,78 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// StudentType tells the academic system of a student: jwfw for
+// undergraduates, yjsxt (研究生系统) for graduate students. Session.Courses,
+// Session.Exams, Session.Scores and Session.GPA query the one of the
+// session, with the same results for both, except for the fields which
+// only one of them has, e.g. GPAReport.Ranking. The semester IDs of the
+// systems differ: use those of Session.Semesters.
+type StudentType string
+
+const (
+	StudentTypeUndergraduate StudentType = "undergraduate" // 本科生
+	StudentTypeGraduate      StudentType = "graduate"      // 研究生, master and doctoral students alike
+)
+
+// Valid reports whether t is one of the StudentType constants.
+func (t StudentType) Valid() bool {
+	return t == StudentTypeUndergraduate || t == StudentTypeGraduate
+}
+
+// String returns the name libfdu takes for t, e.g. "graduate".
+func (t StudentType) String() string {
+	return string(t)
+}
+
+// Profile is the identity of the student of a session.
+type Profile struct {
+	Name      string `json:"name"`
+	StudentID string `json:"student_id"`
+	// Department is e.g. "计算机科学技术学院".
+	Department  string      `json:"department"`
+	StudentType StudentType `json:"student_type"`
+}
+
+// Profile returns the profile of the student, from the academic system of
+// the StudentType of s.
+func (s *Session) Profile(ctx context.Context, opts ...CallOption) (*Profile, error) {
+	v, err := s.callContext(ctx, func(ptr *cSession, token *cCancelToken, id uint64) *cResult {
+		return lib.fduProfileAsync(ptr, token, id)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return parseProfile([]byte(v))
+}
+
+// SetStudentType sets the StudentType of s. libfdu tells it from the
+// student ID the session logged in with, which suffices for the IDs of
+// regular students, so this is only needed for the others, e.g. exchange
+// students. The empty StudentType goes back to telling it from the ID.
+//
+// The StudentType is not part of Session.Export: set it again on the
+// restored session.
+func (s *Session) SetStudentType(t StudentType) error {
+	if t != "" && !t.Valid() {
+		return argumentError("unknown student type %q", string(t))
+	}
+	_, err := s.call(func(ptr *cSession) *cResult {
+		return lib.fduSessionSetStudentType(ptr, string(t))
+	})
+	return err
+}
+
+func parseProfile(data []byte) (*Profile, error) {
+	var profile Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, parseError("profile: %v", err)
+	}
+	if profile.StudentID == "" || !profile.StudentType.Valid() {
+		return nil, parseError("invalid profile %+v", profile)
+	}
+	return &profile, nil
+}
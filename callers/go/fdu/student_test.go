@@ -0,0 +1,135 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseProfile(t *testing.T) {
+	for file, want := range map[string]Profile{
+		"testdata/profile.json":          {Name: "张三", StudentID: "20300000001", Department: "计算机科学技术学院", StudentType: StudentTypeUndergraduate},
+		"testdata/profile_graduate.json": {Name: "李四", StudentID: "23210240001", Department: "计算机科学技术学院", StudentType: StudentTypeGraduate},
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		profile, err := parseProfile(data)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if *profile != want {
+			t.Errorf("%s: got %+v, want %+v", file, *profile, want)
+		}
+	}
+	for _, data := range []string{`[]`, `{"student_id": "20300000001", "student_type": "teacher"}`, `{"student_type": "graduate"}`} {
+		if _, err := parseProfile([]byte(data)); !errors.Is(err, ErrParse) {
+			t.Errorf("%s: got %v, want ErrParse", data, err)
+		}
+	}
+}
+
+// TestParseGraduate checks that the results of yjsxt fill the same structs
+// as those of jwfw, see the fixtures without the _graduate suffix.
+func TestParseGraduate(t *testing.T) {
+	read := func(name string) []byte {
+		data, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	courses, err := parseCourses(read("courses_graduate.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Course{CourseID: "ENGL620002.05", Name: "研究生英语", Teacher: "Smith", Weekday: Friday, StartSlot: 1, EndSlot: 2,
+		Weeks: []int{1, 3, 5, 7, 9, 11, 13, 15}}
+	if len(courses) != 2 || !reflect.DeepEqual(courses[1], want) {
+		t.Errorf("got courses %+v, want the second %+v", courses, want)
+	}
+
+	exams, err := parseExams(read("exams_graduate.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 10, 8, 30, 0, 0, chinaTime)
+	if len(exams) != 2 || !exams[0].Start.Equal(start) || !exams[0].End.Equal(start.Add(2*time.Hour)) || exams[1].Scheduled() {
+		t.Errorf("got exams %+v", exams)
+	}
+
+	scores, err := parseScores(read("scores_graduate.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 3 || scores[0].Semester != "2023-2024 1" || scores[0].Point == nil || *scores[0].Point != 4 || scores[2].Point != nil {
+		t.Errorf("got scores %+v", scores)
+	}
+
+	report, err := parseGPA(read("gpa_graduate.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.GPA != 3.71 || report.Credits != 21 || report.Ranking != nil || report.Rank != 0 {
+		t.Errorf("got %+v, want a GPA of 3.71 without ranking", report)
+	}
+	if want := (PlanProgress{RequiredCredits: 32, EarnedCredits: 21}); report.Progress == nil || *report.Progress != want {
+		t.Errorf("got progress %+v, want %+v", report.Progress, want)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	data, err := os.ReadFile("testdata/profile_graduate.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := lib.fduProfileAsync
+	t.Cleanup(func() { lib.fduProfileAsync = orig })
+	lib.fduProfileAsync = func(_ *cSession, token *cCancelToken, requestID uint64) *cResult {
+		return lib.fduTestResultAsync(string(data), 0, 0, token, requestID)
+	}
+	profile, err := s.Profile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.StudentType != StudentTypeGraduate || profile.Name != "李四" {
+		t.Errorf("got %+v", profile)
+	}
+}
+
+func TestSetStudentType(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []StudentType{StudentTypeGraduate, StudentTypeUndergraduate, ""} {
+		if err := s.SetStudentType(st); err != nil {
+			t.Errorf("%q: %v", st, err)
+		}
+	}
+	if err := s.SetStudentType("master"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("got %v, want ErrInvalidArgument", err)
+	}
+	s.Close()
+	if err := s.SetStudentType(StudentTypeGraduate); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v after Close, want ErrClosed", err)
+	}
+}
+
+func FuzzParseProfile(f *testing.F) {
+	fuzzParse(f, []string{"profile.json", "profile_graduate.json"}, nil, parseProfile, func(t *testing.T, profile *Profile) {
+		if profile.StudentID == "" || !profile.StudentType.Valid() {
+			t.Fatalf("accepted the invalid profile %+v", profile)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package fdu
+
+// Wrappers of the fdu_test_* exports, used by the tests of this package.
+// libfdu only implements them in debug builds, or with the test-exports
+// feature. The tests load the library in TestMain, so the wrappers do not.
+
+import (
+	"context"
+	"iter"
+	"strconv"
+	"time"
+)
+
+func testError(code int32) (string, error) {
+	return takeResult(lib.fduTestError(code))
+}
+
+func testPanic() error {
+	_, err := takeResult(lib.fduTestPanic())
+	return err
+}
+
+func testSession() (*Session, error) {
+	var ptr *cSession
+	if _, err := takeResult(lib.fduTestSessionNew(&ptr)); err != nil {
+		return nil, err
+	}
+	return newSession(ptr), nil
+}
+
+func testLiveSessions() int64 {
+	return lib.fduTestLiveSessions()
+}
+
+func (s *Session) testPing() (uint64, error) {
+	v, err := s.callInto(callTestSessionPing)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// testEchoInto sends value to libfdu and back through fdu_call_into, see
+// callInto.
+func testEchoInto(value string) (string, error) {
+	if err := checkInit(); err != nil {
+		return "", err
+	}
+	return callInto(callTestEcho, nil, value)
+}
+
+func testSleep(ctx context.Context, millis uint64) error {
+	_, err := callContext(ctx, func(token *cCancelToken) (string, error) {
+		return takeResult(lib.fduTestSleep(millis, token))
+	})
+	return err
+}
+
+// testSleepAsync is like testSleep, but runs as a job of libfdu, see runJob.
+func testSleepAsync(ctx context.Context, millis uint64) error {
+	if err := checkInit(); err != nil {
+		return err
+	}
+	_, err := runJob(ctx, "", func() {}, func(token *cCancelToken, id uint64) *cResult {
+		return lib.fduTestSleepAsync(millis, token, id)
+	})
+	return err
+}
+
+// testPages returns an iterator over the fake listing of libfdu, the ids 0
+// to 99 in 5 pages taking 10ms each. Fetching page failPage fails with
+// ErrNetwork, unless failPage is 0.
+func (s *Session) testPages(ctx context.Context, failPage uint32) iter.Seq2[int, error] {
+	fetch := func(ptr *cSession, pageToken string, token *cCancelToken, id uint64) *cResult {
+		return lib.fduTestPagesAsync(ptr, pageToken, failPage, token, id)
+	}
+	return paged(ctx, s, "test pages", fetch, func(raw struct{ ID int }) (int, error) {
+		return raw.ID, nil
+	})
+}
+
+func testLiveTokens() int64 {
+	return lib.fduTestLiveTokens()
+}
+
+func testEchoBytes(data []byte) ([]byte, error) {
+	var out *cBuffer
+	ptr, n := cBytes(data)
+	if _, err := takeResult(lib.fduTestEchoBytes(ptr, n, &out)); err != nil {
+		return nil, err
+	}
+	return takeBuffer(out), nil
+}
+
+func testLiveBuffers() int64 {
+	return lib.fduTestLiveBuffers()
+}
+
+// testLog makes libfdu log "test error", "test warn"... at each level from
+// LevelError to LevelTrace.
+func testLog() error {
+	_, err := takeResult(lib.fduTestLog())
+	return err
+}
+
+// testLoginCaptcha starts a fake login which always asks for a captcha,
+// whose answer is "1234" and whose token expires after ttl.
+func testLoginCaptcha(ttl time.Duration) error {
+	_, err := takeResult(lib.fduTestLoginCaptcha(uint64(ttl.Milliseconds())))
+	return err
+}
+
+// testEcho sends s to libfdu and back, through a job completing at once.
+func testEcho(ctx context.Context, s string) (string, error) {
+	if err := checkInit(); err != nil {
+		return "", err
+	}
+	if err := checkCString("value", s); err != nil {
+		return "", err
+	}
+	return runJob(ctx, "", func() {}, func(token *cCancelToken, id uint64) *cResult {
+		return lib.fduTestResultAsync(s, 0, 0, token, id)
+	})
+}
+
+func testLeak(count uint32) error {
+	_, err := takeResult(lib.fduTestLeak(count))
+	return err
+}
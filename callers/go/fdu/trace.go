@@ -0,0 +1,120 @@
+package fdu
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//go:generate go run ../internal/gen -enum FduTracePhase -prefix FDU_TRACE_PHASE_ -type TracePhase -pkg fdu -o tracephase_gen.go bindings.h
+
+var (
+	tracer atomic.Pointer[func(ev TraceEvent)]
+	// tracing counts the callbacks running, so that the calls from outside
+	// of a callback only pay for the check of checkTraceCallback while one
+	// runs.
+	tracing atomic.Int32
+)
+
+// TraceEvent times a step of a request of a call, see SetTraceCallback.
+type TraceEvent struct {
+	// RequestID tells apart the calls in flight: the events of a call have
+	// the same one. It is 0 for the calls not made by a Session method.
+	RequestID uint64
+	// TraceID is the ID given to the call with WithTraceID, if any.
+	TraceID string
+	// Phase is TracePhaseDNS for the lookups of new connections,
+	// TracePhaseRequest from sending a request to the headers of its
+	// response, and TracePhaseParse from the last response of a call to its
+	// result, reading the body included. TracePhaseConnect and
+	// TracePhaseTLS are not reported yet: the TCP and TLS handshakes are part
+	// of the request opening the connection.
+	Phase TracePhase
+	// Host is the server of the request, e.g. HostJwfw.
+	Host     Host
+	Start    time.Time
+	Duration time.Duration
+	// Status is the HTTP status of a TracePhaseRequest, 0 if there was no
+	// response, the number of addresses found by a TracePhaseDNS lookup, 0
+	// if it failed, and the ErrCode of the result of the call for
+	// TracePhaseParse.
+	Status int
+}
+
+// cTraceEvent mirrors FduTraceEvent of bindings.h.
+type cTraceEvent struct {
+	requestID      uint64
+	startUnixNanos int64
+	durationNanos  uint64
+	traceID        *byte
+	host           *byte
+	phase          int32
+	status         int32
+}
+
+// SetTraceCallback sends the trace events of the requests of libfdu to fn,
+// or stops them if fn is nil, e.g. to export the calls as spans of
+// OpenTelemetry, with the IDs of WithTraceID linking them to the requests of
+// a service.
+//
+// fn is called synchronously from the threads of libfdu, possibly from
+// several at the same time, so it must be safe for concurrent use, return
+// quickly and not panic, like the logger of SetLogger. It must not call into
+// the package: such calls fail with ErrInvalidArgument, since the call being
+// traced holds the locks they need. It may be called before Init, in which
+// case the callback is set up by Init.
+func SetTraceCallback(fn func(ev TraceEvent)) {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if fn == nil {
+		tracer.Store(nil)
+	} else {
+		tracer.Store(&fn)
+	}
+	if initialized.Load() {
+		applyTracer()
+	}
+}
+
+// WithTraceID sets the TraceID of the trace events of a call.
+func WithTraceID(id string) CallOption {
+	return func(o *callOptions) {
+		o.traceID = id
+	}
+}
+
+// applyTracer hands the current trace callback to libfdu. initMu must be
+// held.
+func applyTracer() {
+	// It only fails when called from the trace callback.
+	_, _ = takeResult(lib.fduSetTraceCallback(tracer.Load() != nil))
+}
+
+// checkTraceCallback returns ErrInvalidArgument if called from the trace
+// callback, before the call takes locks which the call being traced holds.
+func checkTraceCallback() error {
+	if tracing.Load() > 0 && lib.fduInTraceCallback() {
+		return argumentError("the trace callback must not call libfdu")
+	}
+	return nil
+}
+
+// dispatchTrace is called by the trace callback of the backend with an event
+// of libfdu. The strings of ev are only valid during the call, so they are
+// copied.
+func dispatchTrace(ev *cTraceEvent) {
+	fn := tracer.Load()
+	if fn == nil {
+		return
+	}
+	tracing.Add(1)
+	defer tracing.Add(-1)
+	(*fn)(TraceEvent{
+		RequestID: ev.requestID,
+		TraceID:   goString(ev.traceID),
+		Phase:     TracePhase(ev.phase),
+		Host:      Host(goString(ev.host)),
+		Start:     time.Unix(0, ev.startUnixNanos),
+		Duration:  time.Duration(ev.durationNanos),
+		Status:    int(ev.status),
+	})
+}
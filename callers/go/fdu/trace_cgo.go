@@ -0,0 +1,16 @@
+//go:build !fdu_purego
+
+package fdu
+
+import "C"
+
+import "unsafe"
+
+// goTraceCallback is the FduTraceCallback handed to libfdu, like
+// goLogCallback. The event is passed as a void pointer, so that the
+// declaration in lib_cgo.go needs no type of bindings.h.
+//
+//export goTraceCallback
+func goTraceCallback(event unsafe.Pointer) {
+	dispatchTrace((*cTraceEvent)(event))
+}
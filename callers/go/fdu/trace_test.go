@@ -0,0 +1,132 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// traceCollector collects the trace events of the calls with TraceID id, as
+// Courses calls are stubbed with fdu_test_trace_async.
+func traceCollector(t *testing.T, id string, fn func(ev TraceEvent)) func() []TraceEvent {
+	t.Helper()
+	data, err := os.ReadFile("testdata/courses.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := lib.fduCoursesAsync
+	t.Cleanup(func() { lib.fduCoursesAsync = orig })
+	lib.fduCoursesAsync = func(_ *cSession, _ string, token *cCancelToken, requestID uint64) *cResult {
+		return lib.fduTestTraceAsync(string(HostJwfw), string(data), token, requestID)
+	}
+
+	var (
+		mu     sync.Mutex
+		events []TraceEvent
+	)
+	SetTraceCallback(func(ev TraceEvent) {
+		// Keep only the events of the calls of the test.
+		if ev.TraceID != id {
+			return
+		}
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+		if fn != nil {
+			fn(ev)
+		}
+	})
+	t.Cleanup(func() { SetTraceCallback(nil) })
+	return func() []TraceEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(events)
+	}
+}
+
+func TestTraceCallback(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	events := traceCollector(t, "trace-courses", nil)
+
+	if _, err := s.Courses(context.Background(), "443", WithTraceID("trace-courses")); err != nil {
+		t.Fatal(err)
+	}
+	got := events()
+	var phases []TracePhase
+	for _, ev := range got {
+		phases = append(phases, ev.Phase)
+	}
+	if want := []TracePhase{TracePhaseDNS, TracePhaseRequest, TracePhaseParse}; !slices.Equal(phases, want) {
+		t.Fatalf("got phases %v, want %v", phases, want)
+	}
+	for i, ev := range got {
+		if ev.RequestID == 0 || ev.RequestID != got[0].RequestID || ev.Host != HostJwfw {
+			t.Errorf("event %d: got %+v, want the request ID of the others and %s", i, ev, HostJwfw)
+		}
+		if ev.Duration <= 0 {
+			t.Errorf("event %d: got a duration of %v", i, ev.Duration)
+		}
+		if i > 0 && ev.Start.Before(got[i-1].Start) {
+			t.Errorf("event %d starts at %v, before the previous one at %v", i, ev.Start, got[i-1].Start)
+		}
+	}
+	if got[1].Status != 200 || got[2].Status != int(ErrCodeOK) {
+		t.Errorf("got statuses %d and %d, want 200 and %d", got[1].Status, got[2].Status, ErrCodeOK)
+	}
+
+	// Without a trace ID, the events are not ours.
+	if _, err := s.Courses(context.Background(), "443"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(events()); n != 3 {
+		t.Errorf("got %d events, want the 3 of the first call", n)
+	}
+}
+
+func TestTraceCallbackReentrant(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	errs := make(chan error, 3)
+	events := traceCollector(t, "trace-reentrant", func(TraceEvent) {
+		// The call being traced holds the lock of s: this would deadlock.
+		_, err := s.Valid(context.Background())
+		errs <- err
+	})
+
+	if _, err := s.Courses(context.Background(), "443", WithTraceID("trace-reentrant")); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(events()); n != 3 {
+		t.Fatalf("got %d events, want 3", n)
+	}
+	for range 3 {
+		if err := <-errs; !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("got %v from the callback, want ErrInvalidArgument", err)
+		}
+	}
+	// Outside of the callback, calls work as usual.
+	if _, err := s.Valid(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithTraceIDInvalid(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Courses(context.Background(), "443", WithTraceID("a\x00b")); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("got %v, want ErrInvalidArgument", err)
+	}
+}
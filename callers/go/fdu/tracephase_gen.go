@@ -0,0 +1,41 @@
+// Code generated by internal/gen from bindings.h; DO NOT EDIT.
+
+package fdu
+
+import "strconv"
+
+// TracePhase is a value of FduTracePhase.
+type TracePhase int32
+
+const (
+	TracePhaseDNS     TracePhase = 1 // FDU_TRACE_PHASE_DNS
+	TracePhaseConnect TracePhase = 2 // FDU_TRACE_PHASE_CONNECT
+	TracePhaseTLS     TracePhase = 3 // FDU_TRACE_PHASE_TLS
+	TracePhaseRequest TracePhase = 4 // FDU_TRACE_PHASE_REQUEST
+	TracePhaseParse   TracePhase = 5 // FDU_TRACE_PHASE_PARSE
+)
+
+// allTracePhases lists the TracePhase constants in the order of bindings.h.
+var allTracePhases = []TracePhase{
+	TracePhaseDNS,
+	TracePhaseConnect,
+	TracePhaseTLS,
+	TracePhaseRequest,
+	TracePhaseParse,
+}
+
+func (c TracePhase) String() string {
+	switch c {
+	case TracePhaseDNS:
+		return "DNS"
+	case TracePhaseConnect:
+		return "CONNECT"
+	case TracePhaseTLS:
+		return "TLS"
+	case TracePhaseRequest:
+		return "REQUEST"
+	case TracePhaseParse:
+		return "PARSE"
+	}
+	return "TracePhase(" + strconv.FormatInt(int64(c), 10) + ")"
+}
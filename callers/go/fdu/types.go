@@ -0,0 +1,256 @@
+package fdu
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Campus is a campus of the university.
+type Campus string
+
+const (
+	CampusHandan     Campus = "handan"     // 邯郸
+	CampusJiangwan   Campus = "jiangwan"   // 江湾
+	CampusFenglin    Campus = "fenglin"    // 枫林
+	CampusZhangjiang Campus = "zhangjiang" // 张江
+)
+
+// campuses lists the Campus constants.
+var campuses = []Campus{CampusHandan, CampusJiangwan, CampusFenglin, CampusZhangjiang}
+
+// ParseCampus returns the campus named s, as returned by Campus.String,
+// or an error wrapping ErrInvalidArgument.
+func ParseCampus(s string) (Campus, error) {
+	if c := Campus(s); c.Valid() {
+		return c, nil
+	}
+	return "", argumentError("unknown campus %q", s)
+}
+
+// Valid reports whether c is one of the Campus constants.
+func (c Campus) Valid() bool {
+	switch c {
+	case CampusHandan, CampusJiangwan, CampusFenglin, CampusZhangjiang:
+		return true
+	}
+	return false
+}
+
+// String returns the name libfdu takes for c, e.g. "handan".
+func (c Campus) String() string {
+	return string(c)
+}
+
+func (c Campus) MarshalText() ([]byte, error) {
+	if !c.Valid() {
+		return nil, argumentError("unknown campus %q", string(c))
+	}
+	return []byte(c), nil
+}
+
+func (c *Campus) UnmarshalText(text []byte) error {
+	v, err := ParseCampus(string(text))
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+// Weekday is a day of the week, counted from 1 for Monday to 7 for Sunday,
+// like libfdu, unlike time.Weekday.
+type Weekday int
+
+const (
+	Monday Weekday = iota + 1
+	Tuesday
+	Wednesday
+	Thursday
+	Friday
+	Saturday
+	Sunday
+)
+
+// ParseWeekday returns the weekday s, as returned by Weekday.String, or an
+// error wrapping ErrInvalidArgument.
+func ParseWeekday(s string) (Weekday, error) {
+	n, err := strconv.Atoi(s)
+	if d := Weekday(n); err == nil && d.Valid() && d.String() == s {
+		return d, nil
+	}
+	return 0, argumentError("invalid weekday %q", s)
+}
+
+// WeekdayOf returns the Weekday of d.
+func WeekdayOf(d time.Weekday) Weekday {
+	return Weekday((int(d)+6)%7 + 1)
+}
+
+// Valid reports whether d is one of the Weekday constants.
+func (d Weekday) Valid() bool {
+	return Monday <= d && d <= Sunday
+}
+
+// String returns the number libfdu uses for d, e.g. "1" for Monday. Use
+// Time for the English name.
+func (d Weekday) String() string {
+	return strconv.Itoa(int(d))
+}
+
+// Time returns d as a time.Weekday.
+func (d Weekday) Time() time.Weekday {
+	return time.Weekday(int(d) % 7)
+}
+
+func (d Weekday) MarshalJSON() ([]byte, error) {
+	if !d.Valid() {
+		return nil, argumentError("invalid weekday %d", int(d))
+	}
+	return []byte(d.String()), nil
+}
+
+func (d *Weekday) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	if !Weekday(n).Valid() {
+		return fmt.Errorf("invalid weekday %d", n)
+	}
+	*d = Weekday(n)
+	return nil
+}
+
+// TimeSlot is a slot (节) of a day, counted from 1 to MaxSlot.
+type TimeSlot int
+
+// ParseTimeSlot returns the slot s, as returned by TimeSlot.String, or an
+// error wrapping ErrInvalidArgument.
+func ParseTimeSlot(s string) (TimeSlot, error) {
+	n, err := strconv.Atoi(s)
+	if slot := TimeSlot(n); err == nil && slot.Valid() && slot.String() == s {
+		return slot, nil
+	}
+	return 0, argumentError("invalid slot %q", s)
+}
+
+// Valid reports whether slot is between 1 and MaxSlot.
+func (slot TimeSlot) Valid() bool {
+	return 1 <= slot && slot <= MaxSlot
+}
+
+// String returns the number libfdu uses for slot, e.g. "3".
+func (slot TimeSlot) String() string {
+	return strconv.Itoa(int(slot))
+}
+
+func (slot TimeSlot) MarshalJSON() ([]byte, error) {
+	if !slot.Valid() {
+		return nil, argumentError("invalid slot %d", int(slot))
+	}
+	return []byte(slot.String()), nil
+}
+
+func (slot *TimeSlot) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	if !TimeSlot(n).Valid() {
+		return fmt.Errorf("invalid slot %d", n)
+	}
+	*slot = TimeSlot(n)
+	return nil
+}
+
+// SemesterID is a semester of the academic system: either its ID there, e.g.
+// "385" in jwfw or "20231" in yjsxt, see StudentType, as found in
+// Semester.ID, or its school year and term, e.g. "2023-2024-1", see Term.
+// The methods of Session map the latter to the ID with Semesters.
+type SemesterID string
+
+// termNames are the Names of the Semesters of the terms of a SemesterID, e.g.
+// "暑期" for "2023-2024-3".
+var termNames = [...]string{1: "1", 2: "2", 3: "暑期", 4: "寒假"}
+
+// ParseSemesterID returns the semester ID s, or an error wrapping
+// ErrInvalidArgument unless s is either a positive decimal number without
+// leading zeros, like the IDs of the academic system, or a school year and a
+// term, e.g. "2023-2024-1": two consecutive years of four digits and a term
+// from 1 to 4, see Term.
+func ParseSemesterID(s string) (SemesterID, error) {
+	if id := SemesterID(s); id.Valid() {
+		return id, nil
+	}
+	if strings.Contains(s, "-") {
+		return "", argumentError("invalid semester %q: want an ID or a school year and term like 2023-2024-1", s)
+	}
+	return "", argumentError("invalid semester ID %q", s)
+}
+
+// Valid reports whether id is either a positive decimal number without
+// leading zeros, of at most 9 digits, or a school year and term.
+func (id SemesterID) Valid() bool {
+	_, _, ok := id.Term()
+	return ok || id.isSystemID()
+}
+
+func (id SemesterID) isSystemID() bool {
+	if len(id) == 0 || len(id) > 9 || id[0] == '0' {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Term returns the school year of id, e.g. "2023-2024", and its term: 1 and
+// 2 for the first and second terms, 3 for the summer term (暑期) and 4 for
+// the winter one (寒假). ok is false unless id is of the form "2023-2024-1",
+// with the years from 1000 to 9999.
+func (id SemesterID) Term() (schoolYear string, term int, ok bool) {
+	if len(id) != len("2023-2024-1") || id[4] != '-' || id[9] != '-' || id[0] == '0' {
+		return "", 0, false
+	}
+	year, err1 := strconv.ParseUint(string(id[:4]), 10, 16)
+	next, err2 := strconv.ParseUint(string(id[5:9]), 10, 16)
+	if err1 != nil || err2 != nil || next != year+1 || id[10] < '1' || id[10] > '4' {
+		return "", 0, false
+	}
+	return string(id[:9]), int(id[10] - '0'), true
+}
+
+// is reports whether id is the semester with the ID, school year and name of
+// a Semester.
+func (id SemesterID) is(semID SemesterID, schoolYear, name string) bool {
+	if year, term, ok := id.Term(); ok {
+		return year == schoolYear && termNames[term] == name
+	}
+	return id == semID
+}
+
+// String returns id as given, e.g. "385" or "2023-2024-1".
+func (id SemesterID) String() string {
+	return string(id)
+}
+
+func (id SemesterID) MarshalText() ([]byte, error) {
+	if !id.Valid() {
+		return nil, argumentError("invalid semester ID %q", string(id))
+	}
+	return []byte(id), nil
+}
+
+func (id *SemesterID) UnmarshalText(text []byte) error {
+	if !SemesterID(text).Valid() {
+		return fmt.Errorf("invalid semester ID %q", text)
+	}
+	*id = SemesterID(text)
+	return nil
+}
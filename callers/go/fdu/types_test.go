@@ -0,0 +1,264 @@
+package fdu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCampus(t *testing.T) {
+	for _, c := range campuses {
+		if got, err := ParseCampus(c.String()); got != c || err != nil {
+			t.Errorf("ParseCampus(%q) = (%q, %v)", c.String(), got, err)
+		}
+		testJSONRoundTrip(t, c, `"`+c.String()+`"`)
+	}
+	for _, s := range []string{"", "Handan", "邯郸", "handan ", "h"} {
+		if _, err := ParseCampus(s); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ParseCampus(%q) = %v, want ErrInvalidArgument", s, err)
+		}
+	}
+	if _, err := json.Marshal(Campus("moon")); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("marshaling an unknown campus: %v, want ErrInvalidArgument", err)
+	}
+}
+
+// TestCampusRust checks the campuses against those libfdu maps to the
+// academic system, when built from a checkout.
+func TestCampusRust(t *testing.T) {
+	src, err := os.ReadFile("../../../src/fdu/jwfw.rs")
+	if err != nil {
+		t.Skip(err)
+	}
+	body := regexp.MustCompile(`(?s)fn campus_id\(.*?\n}`).Find(src)
+	var rust []Campus
+	for _, m := range regexp.MustCompile(`"(\w+)" => Ok`).FindAllSubmatch(body, -1) {
+		rust = append(rust, Campus(m[1]))
+	}
+	if len(rust) == 0 {
+		t.Fatal("campus_id not found in src/fdu/jwfw.rs")
+	}
+	slices.Sort(rust)
+	if want := slices.Sorted(slices.Values(campuses)); !slices.Equal(rust, want) {
+		t.Errorf("libfdu takes the campuses %q, the Campus constants are %q", rust, want)
+	}
+}
+
+func TestWeekday(t *testing.T) {
+	names := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+	for i, name := range names {
+		d := Monday + Weekday(i)
+		if got, err := ParseWeekday(d.String()); got != d || err != nil {
+			t.Errorf("ParseWeekday(%q) = (%d, %v)", d.String(), got, err)
+		}
+		if d.Time() != name || WeekdayOf(name) != d {
+			t.Errorf("%d is %v, WeekdayOf(%v) = %d", d, d.Time(), name, WeekdayOf(name))
+		}
+		testJSONRoundTrip(t, d, d.String())
+	}
+	for _, s := range []string{"", "0", "8", "-1", "01", "+1", " 1", "Monday", "一"} {
+		if _, err := ParseWeekday(s); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ParseWeekday(%q) = %v, want ErrInvalidArgument", s, err)
+		}
+	}
+	for _, d := range []Weekday{0, 8} {
+		if _, err := json.Marshal(d); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("marshaling weekday %d: %v, want ErrInvalidArgument", d, err)
+		}
+		var got Weekday
+		if err := json.Unmarshal([]byte(d.String()), &got); err == nil {
+			t.Errorf("unmarshaling weekday %d: no error", d)
+		}
+	}
+}
+
+func TestTimeSlot(t *testing.T) {
+	for slot := TimeSlot(1); slot <= MaxSlot; slot++ {
+		if got, err := ParseTimeSlot(slot.String()); got != slot || err != nil {
+			t.Errorf("ParseTimeSlot(%q) = (%d, %v)", slot.String(), got, err)
+		}
+		testJSONRoundTrip(t, slot, slot.String())
+	}
+	for _, s := range []string{"", "0", "15", "-3", "03", "3 ", "III"} {
+		if _, err := ParseTimeSlot(s); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ParseTimeSlot(%q) = %v, want ErrInvalidArgument", s, err)
+		}
+	}
+	if _, err := json.Marshal(TimeSlot(MaxSlot + 1)); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("marshaling slot %d: %v, want ErrInvalidArgument", MaxSlot+1, err)
+	}
+}
+
+func TestSemesterID(t *testing.T) {
+	for _, s := range []string{"1", "385", "443", "999999999", "2023-2024-1", "2023-2024-4", "1000-1001-2", "9998-9999-3"} {
+		if id, err := ParseSemesterID(s); id.String() != s || err != nil {
+			t.Errorf("ParseSemesterID(%q) = (%q, %v)", s, id, err)
+		}
+		testJSONRoundTrip(t, SemesterID(s), `"`+s+`"`)
+	}
+	invalid := []string{
+		"", "0", "0443", "-443", "44 3", "443\x00", "1234567890", "4e2",
+		// Missing dashes, years not following each other, year 0, terms
+		// out of 1 to 4, and other digits.
+		"20232024-1", "2023-20241", "2023-2024", "2023-2024-", "2023-2025-1", "2024-2023-1",
+		"0-1-1", "0000-0001-1", "0999-1000-1", "2023-2024-0", "2023-2024-5", "2023-2024-10",
+		"+023-2024-1", "2023-+024-1", "２０２３-2024-1", " 2023-2024-1", "2023-2024-1 ",
+	}
+	for _, s := range invalid {
+		if _, err := ParseSemesterID(s); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ParseSemesterID(%q) = %v, want ErrInvalidArgument", s, err)
+		}
+	}
+	var id SemesterID
+	if err := json.Unmarshal([]byte(`"2023-2024-5"`), &id); err == nil {
+		t.Errorf("unmarshaling 2023-2024-5: no error")
+	}
+
+	terms := map[SemesterID]struct {
+		year string
+		term int
+		name string
+	}{
+		"2023-2024-1": {"2023-2024", 1, "1"},
+		"2023-2024-2": {"2023-2024", 2, "2"},
+		"2022-2023-3": {"2022-2023", 3, "暑期"},
+		"2022-2023-4": {"2022-2023", 4, "寒假"},
+	}
+	for id, want := range terms {
+		if year, term, ok := id.Term(); year != want.year || term != want.term || !ok {
+			t.Errorf("%s.Term() = (%q, %d, %v)", id, year, term, ok)
+		}
+		if !id.is("385", want.year, want.name) || id.is("385", "2021-2022", want.name) {
+			t.Errorf("%s is not the semester %s %s", id, want.year, want.name)
+		}
+	}
+	if _, _, ok := SemesterID("443").Term(); ok {
+		t.Error("443 has a term")
+	}
+
+	// Rejected before reaching the academic system, also through the
+	// deprecated string variants.
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	if _, err := s.Courses(ctx, "2023-2024-5"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Courses: %v, want ErrInvalidArgument", err)
+	}
+	if _, err := s.ExamsString(ctx, "0443"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("ExamsString: %v, want ErrInvalidArgument", err)
+	}
+	if r := s.Batch(ctx, []Request{ScoresRequest("")}); !errors.Is(r[0].Err, ErrInvalidArgument) {
+		t.Errorf("ScoresRequest: %v, want ErrInvalidArgument", r[0].Err)
+	}
+}
+
+// TestSemesterIDTerm checks that the methods of Session take a semester by
+// its school year and term, which reaches libfdu as its ID.
+func TestSemesterIDTerm(t *testing.T) {
+	s, err := testSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	semesters := `[{"id": "384", "school_year": "2022-2023", "name": "1"}, {"id": "405", "school_year": "2022-2023", "name": "暑期"}]`
+	origs := lib
+	t.Cleanup(func() { lib = origs })
+	lib.fduSemestersAsync = func(_ *cSession, token *cCancelToken, requestID uint64) *cResult {
+		return lib.fduTestResultAsync(semesters, 0, 0, token, requestID)
+	}
+	var got []string
+	lib.fduScoresAsync = func(_ *cSession, semesterID string, token *cCancelToken, requestID uint64) *cResult {
+		got = append(got, semesterID)
+		return lib.fduTestResultAsync("[]", 0, 0, token, requestID)
+	}
+	lib.fduBatchAsync = func(_ *cSession, requests string, token *cCancelToken, requestID uint64) *cResult {
+		got = append(got, requests)
+		return lib.fduTestResultAsync(`[{"code":0,"value":[]}]`, 0, 0, token, requestID)
+	}
+
+	if _, err := s.Scores(ctx, "2022-2023-3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Scores(ctx, "384"); err != nil {
+		t.Fatal(err)
+	}
+	if r := s.Batch(ctx, []Request{ScoresRequest("2022-2023-1")}); r[0].Err != nil {
+		t.Fatal(r[0].Err)
+	}
+	want := []string{"405", "384", `[{"method":"scores","args":{"semester_id":"384"}}]`}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The second term of 2022-2023 is not in Semesters.
+	if _, err := s.Scores(ctx, "2022-2023-2"); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("Scores: %v, want ErrInvalidArgument", err)
+	}
+	if r := s.Batch(ctx, []Request{ScoresRequest("2022-2023-2")}); !errors.Is(r[0].Err, ErrInvalidArgument) {
+		t.Errorf("ScoresRequest: %v, want ErrInvalidArgument", r[0].Err)
+	}
+	if len(got) != len(want) {
+		t.Errorf("called libfdu with %q", got[len(want):])
+	}
+}
+
+// semesterTerm matches the school year and term form of SemesterID.
+var semesterTerm = regexp.MustCompile(`^([1-9][0-9]{3})-([0-9]{4})-([1-4])$`)
+
+func FuzzParseSemesterID(f *testing.F) {
+	for _, s := range []string{"443", "2023-2024-1", "20232024-1", "2023-20241", "0-1-1", "0000-0001-1", "2023-2024-5", "2023-2025-1", "2023-2024-", "-", "0", "01", ""} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		id, err := ParseSemesterID(s)
+		number := s != "" && len(s) <= 9 && strings.Trim(s, "0123456789") == "" && s[0] != '0'
+		term := false
+		if m := semesterTerm.FindStringSubmatch(s); m != nil {
+			year, _ := strconv.Atoi(m[1])
+			next, _ := strconv.Atoi(m[2])
+			term = next == year+1
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidArgument) || id != "" {
+				t.Fatalf("ParseSemesterID(%q) = (%q, %v), want ErrInvalidArgument", s, id, err)
+			}
+			if number || term {
+				t.Fatalf("ParseSemesterID(%q) = %v", s, err)
+			}
+			return
+		}
+		if id.String() != s || !id.Valid() || !number && !term {
+			t.Fatalf("ParseSemesterID(%q) = %q, want a number or a school year and term", s, id)
+		}
+		if year, n, ok := id.Term(); ok != term || ok && (year != s[:9] || n != int(s[10]-'0')) {
+			t.Fatalf("%q.Term() = (%q, %d, %v)", s, year, n, ok)
+		}
+		testJSONRoundTrip(t, id, `"`+s+`"`)
+	})
+}
+
+// testJSONRoundTrip checks that v marshals to want, in a request to libfdu,
+// and back.
+func testJSONRoundTrip[T comparable](t *testing.T, v T, want string) {
+	t.Helper()
+	data, err := json.Marshal(map[string]T{"v": v})
+	if err != nil || string(data) != `{"v":`+want+`}` {
+		t.Errorf("%v marshals to (%s, %v), want %s", v, data, err, want)
+		return
+	}
+	var got map[string]T
+	if err := json.Unmarshal(data, &got); err != nil || got["v"] != v {
+		t.Errorf("%s unmarshals to (%v, %v), want %v", data, got["v"], err, v)
+	}
+}
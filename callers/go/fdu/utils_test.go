@@ -0,0 +1,65 @@
+package fdu
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+)
+
+var m runtime.MemStats
+
+func getMemoryUsage() uint64 {
+	runtime.ReadMemStats(&m)
+	return m.Sys
+}
+
+// liveSession logs in with FDU_UID and FDU_PWD for tests against the real
+// servers, which only run with FDU_LIVE_TEST=1.
+func liveSession(t *testing.T) *Session {
+	t.Helper()
+	if os.Getenv("FDU_LIVE_TEST") != "1" {
+		t.Skip("set FDU_LIVE_TEST=1 to run tests against the real servers")
+	}
+	s, err := Login(context.Background(), os.Getenv("FDU_UID"), os.Getenv("FDU_PWD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = s.Logout(context.Background())
+		s.Close()
+	})
+	return s
+}
+
+// fuzzParse fuzzes parse, a parser of the results of libfdu, seeded with the
+// given fixtures of testdata and seeds: whatever the result, parse must
+// return what it accepts, checked by check if not nil, or an error wrapping
+// ErrParse, and never panic. Without -fuzz, go test only runs the seeds,
+// which is fast; run make fuzz for longer sessions.
+func fuzzParse[T any](f *testing.F, fixtures, seeds []string, parse func(data []byte) (T, error), check func(t *testing.T, v T)) {
+	f.Helper()
+	for _, fixture := range fixtures {
+		data, err := os.ReadFile("testdata/" + fixture)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	for _, seed := range append(seeds, "", "null", "{}", "[]", "[{}]", "[null]", "<html>") {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := parse(data)
+		if err != nil {
+			if !errors.Is(err, ErrParse) {
+				t.Fatalf("got %v, want ErrParse", err)
+			}
+			return
+		}
+		if check != nil {
+			check(t, v)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+)
+
+// stateVersion is the version of the state file, to change with its format.
+const stateVersion = 1
+
+// state is what a watcher has seen, saved in Options.StateFile.
+type state struct {
+	Version int `json:"version"`
+	// Scores are the grades by scoreKey, or nil before the first poll.
+	Scores map[string]string `json:"scores"`
+	// Announcements are the IDs of the last announcements by source, which
+	// are missing before their first poll.
+	Announcements map[fdu.AnnouncementSource]int64 `json:"announcements"`
+	// BalanceAlerted is whether BalanceBelow was delivered for the current
+	// balance.
+	BalanceAlerted bool `json:"balance_alerted"`
+
+	// dirty is whether the state changed since it was saved.
+	dirty bool
+}
+
+// loadState reads the state file at path, returning an empty state if there
+// is none or path is empty.
+func loadState(path string) (*state, error) {
+	st := &state{Version: stateVersion}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("watcher: %w", err)
+		default:
+			if err := json.Unmarshal(data, st); err != nil {
+				return nil, fmt.Errorf("watcher: invalid state file %s: %w", path, err)
+			}
+			if st.Version != stateVersion {
+				return nil, fmt.Errorf("watcher: state file %s has version %d, want %d", path, st.Version, stateVersion)
+			}
+		}
+	}
+	if st.Announcements == nil {
+		st.Announcements = make(map[fdu.AnnouncementSource]int64)
+	}
+	return st, nil
+}
+
+// save writes st to the state file at path, if any. It replaces the file
+// atomically, so that a watcher killed while saving keeps the last state.
+func (st *state) save(path string) error {
+	if path == "" {
+		st.dirty = false
+		return nil
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("watcher: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("watcher: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("watcher: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("watcher: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("watcher: %w", err)
+	}
+	st.dirty = false
+	return nil
+}
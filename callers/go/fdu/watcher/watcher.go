@@ -0,0 +1,431 @@
+// Package watcher polls the scores, the announcements and the balance of the
+// campus card, and delivers their changes as events, e.g. for a bot pushing
+// notifications:
+//
+//	events, err := watcher.New(ctx, session, watcher.Options{
+//		Resources:        []watcher.Resource{watcher.Scores, watcher.Announcements, watcher.CardBalance},
+//		Interval:         15 * time.Minute,
+//		Jitter:           time.Minute,
+//		StateFile:        "watcher.json",
+//		BalanceThreshold: 50_00,
+//	})
+//	...
+//	for event := range events {
+//		switch e := event.(type) {
+//		case watcher.ScoreAdded:
+//			notify("%s: %s", e.Course, e.Grade)
+//		case watcher.PollFailed:
+//			log.Print(e.Err)
+//		...
+//		}
+//	}
+//
+// The state seen so far is saved in the state file, so that a restarted
+// watcher only delivers what changed since the last event it delivered. The
+// first poll without a state only records what is there.
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+)
+
+// DefaultMaxBackoff is the default Options.MaxBackoff.
+const DefaultMaxBackoff = time.Hour
+
+// Resource is what a watcher polls.
+type Resource string
+
+const (
+	// Scores are the scores of the semester, see Options.Semester.
+	Scores Resource = "scores"
+	// Announcements are the announcements of the sources of
+	// Options.Sources, which need no login.
+	Announcements Resource = "announcements"
+	// CardBalance is the balance of the campus card, see
+	// Options.BalanceThreshold.
+	CardBalance Resource = "card_balance"
+)
+
+// Options configure New.
+type Options struct {
+	Resources []Resource
+	// Interval is the time between two polls of the resources, plus a
+	// random duration up to Jitter, so that the watchers started at the
+	// same time, e.g. by cron, do not poll at once.
+	Interval time.Duration
+	Jitter   time.Duration
+	// MaxBackoff caps the time between two polls after authentication
+	// failures, which doubles with each failed poll from Interval so as not
+	// to get the account locked by UIS. Zero means DefaultMaxBackoff.
+	MaxBackoff time.Duration
+	// StateFile is the file keeping what the watcher has seen, created if
+	// needed. Without one, the state is lost when the watcher stops.
+	StateFile string
+	// Semester is the semester of Scores. If empty, it is the latest
+	// semester of Session.Semesters, looked up at each poll.
+	Semester fdu.SemesterID
+	// Sources are the sources of Announcements, all of
+	// fdu.AnnouncementSources if empty.
+	Sources []fdu.AnnouncementSource
+	// BalanceThreshold is the balance of the campus card, in cents, under
+	// which CardBalance delivers BalanceBelow. It must be positive if
+	// Resources has CardBalance.
+	BalanceThreshold int64
+}
+
+// Event is one of ScoreAdded, ScoreChanged, AnnouncementNew, BalanceBelow
+// and PollFailed.
+type Event interface {
+	event()
+}
+
+// ScoreAdded is a score published since the last poll.
+type ScoreAdded struct {
+	// Course is the name of the course, and Grade those of Score.
+	Course string
+	Grade  string
+	Score  fdu.Score
+}
+
+// ScoreChanged is a score whose grade changed since the last poll, e.g.
+// from 缓考 (deferred exam) to the grade of the exam.
+type ScoreChanged struct {
+	Course string
+	// OldGrade is the grade of the last poll, and Grade the one of Score.
+	OldGrade string
+	Grade    string
+	Score    fdu.Score
+}
+
+// AnnouncementNew is an announcement published since the last poll. An
+// edited announcement is not delivered again.
+type AnnouncementNew struct {
+	Announcement fdu.Announcement
+}
+
+// BalanceBelow is delivered when the balance of the campus card falls below
+// the threshold. It is delivered once, not at every poll, until the card is
+// topped up to the threshold or more.
+type BalanceBelow struct {
+	// Balance and Threshold are in cents.
+	Balance   int64
+	Threshold int64
+}
+
+// PollFailed is a poll of Resource which failed, or whose state could not be
+// saved. The resource is polled again at the next poll.
+type PollFailed struct {
+	Resource Resource
+	Err      error
+}
+
+func (ScoreAdded) event()      {}
+func (ScoreChanged) event()    {}
+func (AnnouncementNew) event() {}
+func (BalanceBelow) event()    {}
+func (PollFailed) event()      {}
+
+// source is what a watcher polls, a session in New.
+type source interface {
+	Semesters(ctx context.Context) ([]fdu.Semester, error)
+	Scores(ctx context.Context, semesterID fdu.SemesterID) ([]fdu.Score, error)
+	CardBalance(ctx context.Context) (int64, error)
+	Announcements(ctx context.Context, source fdu.AnnouncementSource, sinceID int64) ([]fdu.Announcement, error)
+}
+
+type sessionSource struct {
+	session *fdu.Session
+}
+
+func (s sessionSource) Semesters(ctx context.Context) ([]fdu.Semester, error) {
+	return s.session.Semesters(ctx)
+}
+
+func (s sessionSource) Scores(ctx context.Context, semesterID fdu.SemesterID) ([]fdu.Score, error) {
+	return s.session.Scores(ctx, semesterID)
+}
+
+func (s sessionSource) CardBalance(ctx context.Context) (int64, error) {
+	return s.session.CardBalance(ctx)
+}
+
+func (s sessionSource) Announcements(ctx context.Context, source fdu.AnnouncementSource, sinceID int64) ([]fdu.Announcement, error) {
+	return fdu.Announcements(ctx, source, sinceID)
+}
+
+// New polls the resources of opts with session every interval until ctx is
+// done or session is closed, and delivers their changes on the returned
+// channel, which is closed once the watcher stops. The first poll is
+// immediate.
+//
+// The calls go through session, with its retry policy and the rate limits of
+// libfdu: a rate limited poll waits until the limit allows it, and a failed
+// one is delivered as PollFailed. A cache of fdu.WithCache for the scores
+// delays their events by its TTL.
+//
+// New returns an error for invalid options, or a state file which cannot be
+// read.
+func New(ctx context.Context, session *fdu.Session, opts Options) (<-chan Event, error) {
+	w, err := newWatcher(sessionSource{session}, opts)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan Event)
+	go w.run(ctx, events)
+	return events, nil
+}
+
+type watcher struct {
+	src   source
+	opts  Options
+	state *state
+	// after and jitter are time.After and a random jitter but in tests.
+	after  func(d time.Duration) <-chan time.Time
+	jitter func() time.Duration
+}
+
+func newWatcher(src source, opts Options) (*watcher, error) {
+	if len(opts.Resources) == 0 {
+		return nil, errors.New("watcher: no resource to watch")
+	}
+	for i, r := range opts.Resources {
+		switch {
+		case r != Scores && r != Announcements && r != CardBalance:
+			return nil, fmt.Errorf("watcher: unknown resource %q", r)
+		case slices.Contains(opts.Resources[:i], r):
+			return nil, fmt.Errorf("watcher: resource %s given twice", r)
+		}
+	}
+	if opts.Interval <= 0 || opts.Jitter < 0 || opts.MaxBackoff < 0 {
+		return nil, fmt.Errorf("watcher: invalid interval %v, jitter %v or maximum backoff %v", opts.Interval, opts.Jitter, opts.MaxBackoff)
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.Semester != "" && !opts.Semester.Valid() {
+		return nil, fmt.Errorf("watcher: invalid semester ID %q", opts.Semester)
+	}
+	if len(opts.Sources) == 0 {
+		opts.Sources = fdu.AnnouncementSources()
+	}
+	for _, s := range opts.Sources {
+		if !slices.Contains(fdu.AnnouncementSources(), s) {
+			return nil, &fdu.UnknownSourceError{Source: s, Valid: fdu.AnnouncementSources()}
+		}
+	}
+	if slices.Contains(opts.Resources, CardBalance) && opts.BalanceThreshold <= 0 {
+		return nil, errors.New("watcher: watching the card balance needs a positive BalanceThreshold")
+	}
+
+	st, err := loadState(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{src: src, opts: opts, state: st, after: time.After}
+	w.jitter = func() time.Duration {
+		if w.opts.Jitter == 0 {
+			return 0
+		}
+		return rand.N(w.opts.Jitter)
+	}
+	return w, nil
+}
+
+func (w *watcher) run(ctx context.Context, events chan<- Event) {
+	defer close(events)
+	// failures counts the polls in a row with an authentication failure.
+	failures := 0
+	for {
+		wait := w.opts.Interval
+		authFailed := false
+		for _, r := range w.opts.Resources {
+			err := w.poll(ctx, r, events)
+			if w.state.dirty {
+				if saveErr := w.state.save(w.opts.StateFile); saveErr != nil && err == nil {
+					err = saveErr
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				continue
+			}
+			if errors.Is(err, fdu.ErrClosed) {
+				return
+			}
+			if errors.Is(err, fdu.ErrAuthFailed) {
+				authFailed = true
+			}
+			var limited *fdu.RateLimitedError
+			if errors.As(err, &limited) {
+				wait = max(wait, time.Until(limited.RetryAt))
+			}
+			if !send(ctx, events, PollFailed{Resource: r, Err: err}) {
+				return
+			}
+		}
+
+		if authFailed {
+			failures++
+			backoff := w.opts.Interval
+			for i := 0; i < failures && backoff < w.opts.MaxBackoff; i++ {
+				backoff *= 2
+			}
+			wait = max(wait, min(backoff, w.opts.MaxBackoff))
+		} else {
+			failures = 0
+		}
+		select {
+		case <-w.after(wait + w.jitter()):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// send delivers e, unless ctx is done first.
+func send(ctx context.Context, events chan<- Event, e Event) bool {
+	select {
+	case events <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// poll polls r and delivers its changes, recording each in the state once
+// delivered.
+func (w *watcher) poll(ctx context.Context, r Resource, events chan<- Event) error {
+	switch r {
+	case Scores:
+		return w.pollScores(ctx, events)
+	case Announcements:
+		for _, source := range w.opts.Sources {
+			if err := w.pollAnnouncements(ctx, source, events); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return w.pollBalance(ctx, events)
+	}
+}
+
+func (w *watcher) pollScores(ctx context.Context, events chan<- Event) error {
+	semester := w.opts.Semester
+	if semester == "" {
+		semesters, err := w.src.Semesters(ctx)
+		if err != nil {
+			return err
+		}
+		if semester = latest(semesters); semester == "" {
+			return nil
+		}
+	}
+	scores, err := w.src.Scores(ctx, semester)
+	if err != nil {
+		return err
+	}
+	st := w.state
+	if st.Scores == nil {
+		st.Scores = make(map[string]string, len(scores))
+		for _, s := range scores {
+			st.Scores[scoreKey(s)] = s.Grade
+		}
+		st.dirty = true
+		return nil
+	}
+	for _, s := range scores {
+		var e Event
+		switch old, ok := st.Scores[scoreKey(s)]; {
+		case !ok:
+			e = ScoreAdded{Course: s.Name, Grade: s.Grade, Score: s}
+		case old != s.Grade:
+			e = ScoreChanged{Course: s.Name, OldGrade: old, Grade: s.Grade, Score: s}
+		default:
+			continue
+		}
+		if !send(ctx, events, e) {
+			return ctx.Err()
+		}
+		st.Scores[scoreKey(s)] = s.Grade
+		st.dirty = true
+	}
+	return nil
+}
+
+// latest returns the latest of semesters, the one with the largest ID, or
+// "" if there is none.
+func latest(semesters []fdu.Semester) fdu.SemesterID {
+	var id fdu.SemesterID
+	for _, s := range semesters {
+		if len(s.ID) > len(id) || len(s.ID) == len(id) && s.ID > id {
+			id = s.ID
+		}
+	}
+	return id
+}
+
+// scoreKey is the key of s in the state: a course ID is only unique within
+// a semester.
+func scoreKey(s fdu.Score) string {
+	return s.Semester + "/" + s.CourseID
+}
+
+func (w *watcher) pollAnnouncements(ctx context.Context, source fdu.AnnouncementSource, events chan<- Event) error {
+	st := w.state
+	sinceID, seen := st.Announcements[source]
+	announcements, err := w.src.Announcements(ctx, source, sinceID)
+	if err != nil {
+		return err
+	}
+	if !seen {
+		for _, a := range announcements {
+			sinceID = max(sinceID, a.ID)
+		}
+		st.Announcements[source] = sinceID
+		st.dirty = true
+		return nil
+	}
+	for _, a := range announcements {
+		if a.ID <= st.Announcements[source] {
+			continue
+		}
+		if !send(ctx, events, AnnouncementNew{Announcement: a}) {
+			return ctx.Err()
+		}
+		st.Announcements[source] = a.ID
+		st.dirty = true
+	}
+	return nil
+}
+
+func (w *watcher) pollBalance(ctx context.Context, events chan<- Event) error {
+	balance, err := w.src.CardBalance(ctx)
+	if err != nil {
+		return err
+	}
+	st := w.state
+	switch {
+	case balance >= w.opts.BalanceThreshold:
+		if st.BalanceAlerted {
+			st.BalanceAlerted = false
+			st.dirty = true
+		}
+	case !st.BalanceAlerted:
+		if !send(ctx, events, BalanceBelow{Balance: balance, Threshold: w.opts.BalanceThreshold}) {
+			return ctx.Err()
+		}
+		st.BalanceAlerted = true
+		st.dirty = true
+	}
+	return nil
+}
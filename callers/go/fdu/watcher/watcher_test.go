@@ -0,0 +1,519 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu"
+	"github.com/DanXi-Dev/libfdu/callers/go/fdu/fdutest"
+	"go.uber.org/goleak"
+)
+
+// TestMain initializes libfdu for TestWatcherSession, with a retry policy
+// for the sessions.
+func TestMain(m *testing.M) {
+	policy := fdu.RetryPolicy{MaxAttempts: 2, BaseDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	if err := fdu.Init(fdu.WithRetryPolicy(policy)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeSource is a scripted source, whose data the tests change between the
+// polls.
+type fakeSource struct {
+	mu            sync.Mutex
+	semesters     []fdu.Semester
+	scores        map[fdu.SemesterID][]fdu.Score
+	announcements map[fdu.AnnouncementSource][]fdu.Announcement
+	balance       int64
+	// err fails the calls for a resource.
+	err map[Resource]error
+}
+
+func (f *fakeSource) update(fn func(f *fakeSource)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn(f)
+}
+
+func (f *fakeSource) Semesters(ctx context.Context) ([]fdu.Semester, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.err[Scores]; err != nil {
+		return nil, err
+	}
+	return f.semesters, nil
+}
+
+func (f *fakeSource) Scores(ctx context.Context, semesterID fdu.SemesterID) ([]fdu.Score, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.scores[semesterID], nil
+}
+
+func (f *fakeSource) CardBalance(ctx context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.err[CardBalance]; err != nil {
+		return 0, err
+	}
+	return f.balance, nil
+}
+
+func (f *fakeSource) Announcements(ctx context.Context, source fdu.AnnouncementSource, sinceID int64) ([]fdu.Announcement, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.err[Announcements]; err != nil {
+		return nil, err
+	}
+	var got []fdu.Announcement
+	for _, a := range f.announcements[source] {
+		if a.ID > sinceID {
+			got = append(got, a)
+		}
+	}
+	return got, nil
+}
+
+// testWatcher runs a watcher of src until the end of the test. Each poll
+// ends with a wait, which the test ends with next.
+type testWatcher struct {
+	events <-chan Event
+	waits  chan time.Duration
+	ticks  chan time.Time
+	cancel context.CancelFunc
+	// leaks ignores the goroutines running before the watcher.
+	leaks goleak.Option
+}
+
+func startWatcher(t *testing.T, src source, opts Options) *testWatcher {
+	t.Helper()
+	w, err := newWatcher(src, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := &testWatcher{
+		waits: make(chan time.Duration),
+		ticks: make(chan time.Time),
+		leaks: goleak.IgnoreCurrent(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	tw.cancel = cancel
+	w.after = func(d time.Duration) <-chan time.Time {
+		select {
+		case tw.waits <- d:
+		case <-ctx.Done():
+		}
+		return tw.ticks
+	}
+	w.jitter = func() time.Duration { return 0 }
+	events := make(chan Event)
+	tw.events = events
+	go w.run(ctx, events)
+	t.Cleanup(func() {
+		tw.stop(t)
+	})
+	return tw
+}
+
+// poll returns the events of a poll, and the wait after it.
+func (tw *testWatcher) poll(t *testing.T) ([]Event, time.Duration) {
+	t.Helper()
+	var events []Event
+	for {
+		select {
+		case e, ok := <-tw.events:
+			if !ok {
+				t.Fatalf("the watcher stopped after %v", events)
+			}
+			events = append(events, e)
+		case d := <-tw.waits:
+			return events, d
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timeout after %v", events)
+		}
+	}
+}
+
+// next starts the next poll.
+func (tw *testWatcher) next() {
+	tw.ticks <- time.Time{}
+}
+
+// stop cancels the watcher, and checks that it stops without leaving a
+// goroutine behind.
+func (tw *testWatcher) stop(t *testing.T) {
+	t.Helper()
+	tw.cancel()
+	for range tw.events {
+	}
+	goleak.VerifyNone(t, tw.leaks)
+}
+
+func score(id, name, grade string) fdu.Score {
+	return fdu.Score{Semester: "2023-2024 2", CourseID: id, Name: name, Credit: 3, Grade: grade}
+}
+
+func point(p float64) *float64 {
+	return &p
+}
+
+func announcement(source fdu.AnnouncementSource, id int64, title string) fdu.Announcement {
+	return fdu.Announcement{ID: id, Title: title, URL: "https://" + string(source) + ".fudan.edu.cn/" + title, Department: string(source)}
+}
+
+// newFakeSource returns the data of the first poll of the tests.
+func newFakeSource() *fakeSource {
+	return &fakeSource{
+		semesters: []fdu.Semester{{ID: "385"}, {ID: "403"}, {ID: "386"}},
+		scores: map[fdu.SemesterID][]fdu.Score{
+			"385": {score("COMP130001.01", "程序设计", "D")},
+			"403": {
+				score("COMP130004.03", "数据结构", "A"),
+				score("MATH120016.01", "数学分析", "缓考"),
+			},
+		},
+		announcements: map[fdu.AnnouncementSource][]fdu.Announcement{
+			fdu.SourceJWC: {announcement(fdu.SourceJWC, 1, "选课"), announcement(fdu.SourceJWC, 2, "考试")},
+		},
+		balance: 100_00,
+	}
+}
+
+var allResources = []Resource{Scores, Announcements, CardBalance}
+
+func TestWatcher(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	src := newFakeSource()
+	opts := Options{
+		Resources:        allResources,
+		Interval:         time.Minute,
+		StateFile:        stateFile,
+		BalanceThreshold: 50_00,
+	}
+	tw := startWatcher(t, src, opts)
+
+	// The first poll only records what is there.
+	if events, wait := tw.poll(t); len(events) != 0 || wait != time.Minute {
+		t.Fatalf("first poll: got %v, wait %v", events, wait)
+	}
+
+	src.update(func(f *fakeSource) {
+		f.scores["403"] = append(f.scores["403"], score("PHYS120013.02", "大学物理", "B+"))
+		f.announcements[fdu.SourceJWC] = append(f.announcements[fdu.SourceJWC], announcement(fdu.SourceJWC, 3, "放假"))
+		f.balance = 30_00
+	})
+	tw.next()
+	events, _ := tw.poll(t)
+	want := []Event{
+		ScoreAdded{Course: "大学物理", Grade: "B+", Score: score("PHYS120013.02", "大学物理", "B+")},
+		AnnouncementNew{Announcement: announcement(fdu.SourceJWC, 3, "放假")},
+		BalanceBelow{Balance: 30_00, Threshold: 50_00},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("second poll: got %v, want %v", events, want)
+	}
+
+	// The deferred exam gets a grade, and the balance stays low.
+	src.update(func(f *fakeSource) {
+		f.scores["403"][1].Grade = "A-"
+		f.announcements[fdu.SourceGS] = []fdu.Announcement{announcement(fdu.SourceGS, 7, "答辩")}
+		f.balance = 20_00
+	})
+	tw.next()
+	events, _ = tw.poll(t)
+	want = []Event{
+		ScoreChanged{Course: "数学分析", OldGrade: "缓考", Grade: "A-", Score: score("MATH120016.01", "数学分析", "A-")},
+		AnnouncementNew{Announcement: announcement(fdu.SourceGS, 7, "答辩")},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("third poll: got %v, want %v", events, want)
+	}
+
+	// Topped up, then low again.
+	src.update(func(f *fakeSource) { f.balance = 80_00 })
+	tw.next()
+	if events, _ := tw.poll(t); len(events) != 0 {
+		t.Errorf("fourth poll: got %v", events)
+	}
+	src.update(func(f *fakeSource) { f.balance = 10_00 })
+	tw.next()
+	events, _ = tw.poll(t)
+	if want := []Event{BalanceBelow{Balance: 10_00, Threshold: 50_00}}; !reflect.DeepEqual(events, want) {
+		t.Errorf("fifth poll: got %v, want %v", events, want)
+	}
+	tw.stop(t)
+
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		t.Fatal(err)
+	}
+	wantState := state{
+		Version: stateVersion,
+		Scores: map[string]string{
+			"2023-2024 2/COMP130004.03": "A",
+			"2023-2024 2/MATH120016.01": "A-",
+			"2023-2024 2/PHYS120013.02": "B+",
+		},
+		Announcements:  map[fdu.AnnouncementSource]int64{fdu.SourceJWC: 3, fdu.SourceGS: 7},
+		BalanceAlerted: true,
+	}
+	if !reflect.DeepEqual(st, wantState) {
+		t.Errorf("got state %+v, want %+v", st, wantState)
+	}
+}
+
+// TestWatcherSession polls a session of the fdutest server, through libfdu,
+// its retry policy and its rate limits, which needs a debug build.
+func TestWatcherSession(t *testing.T) {
+	leaks := goleak.IgnoreCurrent()
+	srv := fdutest.NewServer(t)
+	added := fdu.Score{Semester: "2023-2024 1", CourseID: "COMP130137.01", Name: "人工智能导论", Credit: 2, Grade: "A", Point: point(4)}
+	changed := slices.Clone(fdutest.Scores)
+	changed[1].Grade, changed[1].Point = "A-", point(3.7)
+	srv.ServeScores(fdutest.Scores, append(slices.Clone(fdutest.Scores), added), append(changed, added))
+	srv.ServeCardBalances(fdutest.CardBalance, 80_00, 50_00)
+	// The first poll fails without the retry policy, and would record the
+	// scores of the second one.
+	srv.Fail("/eams/teach/grade/course/person!search.action", 1, http.StatusBadGateway)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := fdu.Login(ctx, fdutest.Username, fdutest.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := New(ctx, s, Options{
+		Resources:        []Resource{Scores, CardBalance},
+		Interval:         50 * time.Millisecond,
+		Semester:         fdutest.SemesterID,
+		BalanceThreshold: 100_00,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Event{
+		ScoreAdded{Course: "人工智能导论", Grade: "A", Score: added},
+		BalanceBelow{Balance: 80_00, Threshold: 100_00},
+		ScoreChanged{Course: "大学物理", OldGrade: "B+", Grade: "A-", Score: changed[1]},
+	}
+	var got []Event
+	for len(got) < len(want) {
+		select {
+		case e := <-events:
+			got = append(got, e)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timeout after %v", got)
+		}
+	}
+	// The polls after the third see the same data.
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	for e := range events {
+		got = append(got, e)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if n := len(srv.Requests("/epay/myepay/index")); n < 3 {
+		t.Errorf("got %d requests of the balance, want at least 3", n)
+	}
+
+	s.Close()
+	srv.Close()
+	goleak.VerifyNone(t, leaks, goleak.IgnoreAnyFunction("github.com/DanXi-Dev/libfdu/callers/go/fdu.poll"))
+}
+
+func TestWatcherRestart(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	src := newFakeSource()
+	opts := Options{
+		Resources:        allResources,
+		Interval:         time.Minute,
+		StateFile:        stateFile,
+		BalanceThreshold: 50_00,
+	}
+	tw := startWatcher(t, src, opts)
+	tw.poll(t)
+	src.update(func(f *fakeSource) {
+		f.scores["403"][1].Grade = "B"
+		f.balance = 30_00
+	})
+	tw.next()
+	if events, _ := tw.poll(t); len(events) != 2 {
+		t.Fatalf("got %v, want a score and a balance", events)
+	}
+	tw.stop(t)
+
+	// Nothing changed while the watcher was stopped.
+	tw = startWatcher(t, src, opts)
+	if events, _ := tw.poll(t); len(events) != 0 {
+		t.Errorf("after a restart: got %v", events)
+	}
+	tw.stop(t)
+
+	src.update(func(f *fakeSource) {
+		f.scores["403"][0].Grade = "A-"
+		f.announcements[fdu.SourceJWC] = append(f.announcements[fdu.SourceJWC], announcement(fdu.SourceJWC, 4, "补考"))
+	})
+	tw = startWatcher(t, src, opts)
+	events, _ := tw.poll(t)
+	want := []Event{
+		ScoreChanged{Course: "数据结构", OldGrade: "A", Grade: "A-", Score: score("COMP130004.03", "数据结构", "A-")},
+		AnnouncementNew{Announcement: announcement(fdu.SourceJWC, 4, "补考")},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("after a change while stopped: got %v, want %v", events, want)
+	}
+}
+
+func TestWatcherStopWhileSending(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	src := newFakeSource()
+	opts := Options{Resources: []Resource{Scores}, Interval: time.Minute, StateFile: stateFile}
+	tw := startWatcher(t, src, opts)
+	tw.poll(t)
+	src.update(func(f *fakeSource) {
+		f.scores["403"][0].Grade = "A-"
+		f.scores["403"][1].Grade = "B"
+	})
+	tw.next()
+	// Stop with the first event delivered, and the second one pending.
+	<-tw.events
+	tw.stop(t)
+
+	tw = startWatcher(t, src, opts)
+	events, _ := tw.poll(t)
+	want := []Event{ScoreChanged{Course: "数学分析", OldGrade: "缓考", Grade: "B", Score: score("MATH120016.01", "数学分析", "B")}}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("after a restart: got %v, want %v", events, want)
+	}
+}
+
+func TestWatcherSemester(t *testing.T) {
+	src := newFakeSource()
+	tw := startWatcher(t, src, Options{Resources: []Resource{Scores}, Interval: time.Minute, Semester: "385"})
+	tw.poll(t)
+	src.update(func(f *fakeSource) {
+		f.scores["385"][0].Grade = "C"
+		f.scores["403"][0].Grade = "B"
+	})
+	tw.next()
+	events, _ := tw.poll(t)
+	want := []Event{ScoreChanged{Course: "程序设计", OldGrade: "D", Grade: "C", Score: score("COMP130001.01", "程序设计", "C")}}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got %v, want %v", events, want)
+	}
+}
+
+func TestWatcherAuthBackoff(t *testing.T) {
+	src := newFakeSource()
+	src.err = map[Resource]error{Scores: fmt.Errorf("login: %w", fdu.ErrAuthFailed)}
+	tw := startWatcher(t, src, Options{
+		Resources:        allResources,
+		Interval:         time.Minute,
+		MaxBackoff:       5 * time.Minute,
+		BalanceThreshold: 50_00,
+	})
+	for i, want := range []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		if i > 0 {
+			tw.next()
+		}
+		events, wait := tw.poll(t)
+		if len(events) != 1 || !errors.Is(events[0].(PollFailed).Err, fdu.ErrAuthFailed) || events[0].(PollFailed).Resource != Scores {
+			t.Errorf("poll %d: got %v, want a failed poll of the scores", i, events)
+		}
+		if wait != want {
+			t.Errorf("poll %d: got a wait of %v, want %v", i, wait, want)
+		}
+	}
+
+	src.update(func(f *fakeSource) { f.err = nil })
+	tw.next()
+	if events, wait := tw.poll(t); len(events) != 0 || wait != time.Minute {
+		t.Errorf("after a login: got %v, wait %v", events, wait)
+	}
+}
+
+func TestWatcherRateLimited(t *testing.T) {
+	src := newFakeSource()
+	src.err = map[Resource]error{Announcements: &fdu.RateLimitedError{Host: "jwc.fudan.edu.cn", RetryAt: time.Now().Add(time.Hour)}}
+	tw := startWatcher(t, src, Options{Resources: allResources, Interval: time.Minute, BalanceThreshold: 50_00})
+	events, wait := tw.poll(t)
+	if len(events) != 1 || !errors.Is(events[0].(PollFailed).Err, fdu.ErrRateLimited) {
+		t.Errorf("got %v, want a rate limited poll", events)
+	}
+	if wait < 59*time.Minute || wait > time.Hour {
+		t.Errorf("got a wait of %v, want an hour", wait)
+	}
+
+	src.update(func(f *fakeSource) { f.err = nil })
+	tw.next()
+	if _, wait := tw.poll(t); wait != time.Minute {
+		t.Errorf("got a wait of %v, want a minute", wait)
+	}
+}
+
+func TestWatcherClosed(t *testing.T) {
+	src := newFakeSource()
+	src.err = map[Resource]error{CardBalance: fdu.ErrClosed}
+	tw := startWatcher(t, src, Options{Resources: allResources, Interval: time.Minute, BalanceThreshold: 50_00})
+	for e := range tw.events {
+		t.Errorf("got %v", e)
+	}
+	goleak.VerifyNone(t, tw.leaks)
+}
+
+func TestWatcherStateFailure(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "missing", "state.json")
+	tw := startWatcher(t, newFakeSource(), Options{Resources: []Resource{Scores}, Interval: time.Minute, StateFile: stateFile})
+	events, _ := tw.poll(t)
+	if len(events) != 1 || events[0].(PollFailed).Resource != Scores {
+		t.Errorf("got %v, want a failed poll", events)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	broken := filepath.Join(t.TempDir(), "broken.json")
+	if err := os.WriteFile(broken, []byte(`{"version": 1, "scores": [}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := filepath.Join(t.TempDir(), "future.json")
+	if err := os.WriteFile(future, []byte(`{"version": 2}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []Options{
+		{Interval: time.Minute},
+		{Resources: []Resource{"grades"}, Interval: time.Minute},
+		{Resources: []Resource{Scores, Scores}, Interval: time.Minute},
+		{Resources: []Resource{Scores}},
+		{Resources: []Resource{Scores}, Interval: time.Minute, Jitter: -time.Second},
+		{Resources: []Resource{Scores}, Interval: time.Minute, MaxBackoff: -time.Second},
+		{Resources: []Resource{Scores}, Interval: time.Minute, Semester: "2023 spring"},
+		{Resources: []Resource{Announcements}, Interval: time.Minute, Sources: []fdu.AnnouncementSource{"news"}},
+		{Resources: []Resource{CardBalance}, Interval: time.Minute},
+		{Resources: []Resource{Scores}, Interval: time.Minute, StateFile: broken},
+		{Resources: []Resource{Scores}, Interval: time.Minute, StateFile: future},
+	} {
+		if _, err := New(context.Background(), nil, opts); err == nil {
+			t.Errorf("New(%+v): got no error", opts)
+		}
+	}
+}
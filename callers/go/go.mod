@@ -0,0 +1,11 @@
+module github.com/DanXi-Dev/libfdu/callers/go
+
+go 1.23.0
+
+require (
+	github.com/ebitengine/purego v0.8.2
+	go.uber.org/goleak v1.3.0
+	golang.org/x/crypto v0.36.0
+)
+
+require golang.org/x/sys v0.31.0 // indirect
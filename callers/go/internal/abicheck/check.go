@@ -0,0 +1,81 @@
+package abicheck
+
+import "fmt"
+
+// Binding is a function of the header called by a wrapper, with the
+// signature the wrapper expects, as returned by Func.Signature, e.g.
+//
+//	Binding{"fdu_session_free", "void (struct FduSession *)"}
+//
+// Its parameters may carry annotations, which must then match the header.
+type Binding struct {
+	Name      string
+	Signature string
+}
+
+// Check returns what makes bindings disagree with h:
+//
+//   - a binding missing from h, or with another signature;
+//   - a pointer owned by the caller per the binding but not per h, or the
+//     other way around;
+//   - an owned pointer without a free function in h, for any function of h;
+//   - an owned pointer returned by a binding whose free function is not
+//     bound, so that the wrapper cannot free it.
+func Check(h *Header, bindings []Binding) []error {
+	var errs []error
+	for _, f := range h.Funcs {
+		for _, t := range f.Owns() {
+			if _, ok := h.Free(t); !ok {
+				errs = append(errs, fmt.Errorf("%s: the caller owns %s, which has no free function", f.Name, t))
+			}
+		}
+	}
+
+	bound := make(map[string]bool)
+	for _, b := range bindings {
+		if bound[b.Name] {
+			errs = append(errs, fmt.Errorf("%s: bound twice", b.Name))
+		}
+		bound[b.Name] = true
+	}
+	for _, b := range bindings {
+		want, err := h.ParseSignature(b.Name, b.Signature)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		got, ok := h.Func(b.Name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: not in the header", b.Name))
+			continue
+		}
+		if got.Signature() != want.Signature() {
+			errs = append(errs, fmt.Errorf("%s: the wrapper expects %s, the header declares %s (line %d)",
+				b.Name, want.Signature(), got.Signature(), got.Line))
+			continue
+		}
+		if got.Owned != want.Owned {
+			errs = append(errs, ownershipError(b.Name, "the result", got.Owned))
+		}
+		for i, p := range got.Params {
+			if p.Owned != want.Params[i].Owned {
+				errs = append(errs, ownershipError(b.Name, fmt.Sprintf("parameter %d", i+1), p.Owned))
+			}
+		}
+		for _, t := range got.Owns() {
+			if free, ok := h.Free(t); ok && !bound[free.Name] {
+				errs = append(errs, fmt.Errorf("%s: the caller owns %s, but %s is not bound to free it", b.Name, t, free.Name))
+			}
+		}
+	}
+	return errs
+}
+
+// ownershipError reports that the header and a binding of name disagree on
+// whether the caller owns what, which it does per the header if inHeader.
+func ownershipError(name, what string, inHeader bool) error {
+	if inHeader {
+		return fmt.Errorf("%s: the caller owns %s per the header, but not per the wrapper", name, what)
+	}
+	return fmt.Errorf("%s: the caller owns %s per the wrapper, but not per the header", name, what)
+}
@@ -0,0 +1,90 @@
+package abicheck
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	h := parseFile(t, "testdata/valid.h")
+	bindings := []Binding{
+		{"fdu_abi_version", "uint32_t (void)"},
+		{"fdu_login", "struct FduResult *(const char *, const char *, struct FduSession **)"},
+		{"fdu_session_export", "struct FduResult *(const struct FduSession *, struct FduBuffer **)"},
+		{"fdu_session_peek", "struct FduResult *(const struct FduSession *, struct FduBuffer ** /* borrowed */)"},
+		{"fdu_session_free", "void (struct FduSession *)"},
+		{"fdu_version", "const char *(void)"},
+		{"free_buffer", "void (struct FduBuffer *)"},
+		{"free_result", "void (struct FduResult *)"},
+	}
+	if errs := Check(h, bindings); len(errs) > 0 {
+		t.Errorf("got %v", errs)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		bindings []Binding
+		want     string
+	}{
+		{"missing", []Binding{{"fdu_cancel", "void (void)"}},
+			"fdu_cancel: not in the header"},
+		{"twice", []Binding{{"fdu_abi_version", "uint32_t (void)"}, {"fdu_abi_version", "uint32_t (void)"}},
+			"fdu_abi_version: bound twice"},
+		{"invalid", []Binding{{"fdu_abi_version", "uint32_t (int"}},
+			`signature of fdu_abi_version: line 1: expected "," or ")", got ";"`},
+		{"result", []Binding{{"fdu_abi_version", "uint64_t (void)"}},
+			"fdu_abi_version: the wrapper expects uint64_t (void), the header declares uint32_t (void) (line 40)"},
+		{"const", []Binding{{"fdu_session_free", "void (const struct FduSession *)"}},
+			"fdu_session_free: the wrapper expects void (const struct FduSession *), the header declares void (struct FduSession *) (line 56)"},
+		{"parameters", []Binding{{"add", "int (int)"}},
+			"add: the wrapper expects int (int), the header declares int (int, int) (line 38)"},
+		{"borrowed result", []Binding{{"fdu_static_name", "char * /* owned */ (void)"}, {"free_string", "void (char *)"}},
+			"fdu_static_name: the caller owns the result per the wrapper, but not per the header"},
+		{"owned result", []Binding{{"fdu_copy_name", "const char *(void)"}, {"free_string", "void (char *)"}},
+			"fdu_copy_name: the caller owns the result per the header, but not per the wrapper"},
+		{"owned parameter", []Binding{{"fdu_session_export", "struct FduResult *(const struct FduSession *, struct FduBuffer ** /* borrowed */)"},
+			{"free_result", "void (struct FduResult *)"}},
+			"fdu_session_export: the caller owns parameter 2 per the header, but not per the wrapper"},
+		// The string returned is never freed: the case this package is for.
+		{"free not bound", []Binding{{"fdu_copy_name", "const char * /* owned */ (void)"}},
+			"fdu_copy_name: the caller owns const char *, but free_string is not bound to free it"},
+		{"free of a parameter not bound", []Binding{{"fdu_call_into", "size_t (FduCall, const struct FduSession *, uint8_t *, size_t, struct FduResult **)"}},
+			"fdu_call_into: the caller owns struct FduResult *, but free_result is not bound to free it"},
+	} {
+		var errs []string
+		for _, err := range Check(h, tc.bindings) {
+			errs = append(errs, err.Error())
+		}
+		if !slices.Contains(errs, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, errs, tc.want)
+		}
+	}
+}
+
+func TestCheckNoFree(t *testing.T) {
+	h := parseFile(t, "testdata/nofree.h")
+	var errs []string
+	for _, err := range Check(h, nil) {
+		errs = append(errs, err.Error())
+	}
+	// free_result frees strings, whatever its name.
+	want := []string{
+		"fdu_login: the caller owns struct FduSession *, which has no free function",
+	}
+	if !slices.Equal(errs, want) {
+		t.Errorf("got %q, want %q", errs, want)
+	}
+}
+
+func TestCheckBindingsH(t *testing.T) {
+	h := parseFile(t, "../../fdu/bindings.h")
+	if len(h.Funcs) < 50 {
+		t.Fatalf("parsed %d functions of bindings.h", len(h.Funcs))
+	}
+	for _, err := range Check(h, nil) {
+		if strings.Contains(err.Error(), "no free function") {
+			t.Error(err)
+		}
+	}
+}
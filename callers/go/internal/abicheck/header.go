@@ -0,0 +1,606 @@
+// Package abicheck checks that a wrapper of libfdu agrees with bindings.h on
+// the functions it calls: their signatures, and who frees the pointers they
+// return. The wrapper lists the functions it calls with the signatures it
+// expects, see Check.
+//
+// Only the subset of C emitted by cbindgen for bindings.h is parsed: enums,
+// opaque and plain structs, typedefs of integers and of function pointers,
+// and prototypes whose types are a base type, optionally const, behind
+// pointers.
+//
+// A pointer returned by a function, or stored through a pointer to pointer
+// parameter such as struct FduSession **out, is owned by the caller unless
+// it is const: the caller must free it with the free function of its type,
+// a function whose name contains "free" taking that pointer alone, such as
+// free_result. An annotation /* owned */ or /* borrowed */ in the result or
+// the parameter overrides this, e.g.
+//
+//	char * /* borrowed */ fdu_static_name(void);
+package abicheck
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is a C type: a base type, optionally const, behind Pointers pointers,
+// e.g. const struct FduSession *. The struct names of typedefs, such as
+// FduBuffer, are resolved to the struct, struct FduBuffer.
+type Type struct {
+	Const    bool
+	Base     string
+	Pointers int
+}
+
+// String returns t as written in C, e.g. "const char *".
+func (t Type) String() string {
+	var b strings.Builder
+	if t.Const {
+		b.WriteString("const ")
+	}
+	b.WriteString(t.Base)
+	if t.Pointers > 0 {
+		b.WriteString(" ")
+		b.WriteString(strings.Repeat("*", t.Pointers))
+	}
+	return b.String()
+}
+
+// Param is a parameter of a function.
+type Param struct {
+	// Name is empty in the signatures of Check, which need none.
+	Name string
+	Type Type
+	// Owned is whether the caller owns the pointer the function stores
+	// through the parameter.
+	Owned bool
+}
+
+// Func is a function declared by a header.
+type Func struct {
+	Name   string
+	Result Type
+	// Owned is whether the caller owns the pointer returned.
+	Owned  bool
+	Params []Param
+	// Line is the line of the declaration in the header.
+	Line int
+}
+
+// Signature returns the type of f as written in C, without the names of the
+// parameters, e.g. "struct FduResult *(const char *, uint64_t)".
+func (f Func) Signature() string {
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = p.Type.String()
+	}
+	if len(params) == 0 {
+		params = []string{"void"}
+	}
+	result := f.Result.String()
+	if f.Result.Pointers == 0 {
+		result += " "
+	}
+	return result + "(" + strings.Join(params, ", ") + ")"
+}
+
+// Owns returns the pointers the caller of f owns, the result first and then
+// those stored through parameters, e.g. struct FduSession * for a parameter
+// struct FduSession **out.
+func (f Func) Owns() []Type {
+	var owned []Type
+	if f.Owned {
+		owned = append(owned, f.Result)
+	}
+	for _, p := range f.Params {
+		if p.Owned {
+			t := p.Type
+			t.Pointers--
+			owned = append(owned, t)
+		}
+	}
+	return owned
+}
+
+// Header is a parsed header.
+type Header struct {
+	// Funcs are the functions, in declaration order.
+	Funcs  []Func
+	byName map[string]int
+	// structs are the names of the structs declared, e.g. "FduResult".
+	structs map[string]bool
+	// typedefs maps the names of typedefs to their types, the struct for
+	// those of a struct.
+	typedefs map[string]Type
+}
+
+// builtins are the base types of C known without a declaration.
+var builtins = map[string]bool{
+	"void": true, "bool": true, "char": true, "int": true, "float": true, "double": true,
+	"int8_t": true, "int16_t": true, "int32_t": true, "int64_t": true,
+	"uint8_t": true, "uint16_t": true, "uint32_t": true, "uint64_t": true,
+	"size_t": true, "intptr_t": true, "uintptr_t": true,
+}
+
+// Func returns the function named name.
+func (h *Header) Func(name string) (Func, bool) {
+	i, ok := h.byName[name]
+	if !ok {
+		return Func{}, false
+	}
+	return h.Funcs[i], true
+}
+
+// Free returns the free function of the pointers of type t, ignoring
+// whether t is const.
+func (h *Header) Free(t Type) (Func, bool) {
+	t.Const = false
+	for _, f := range h.Funcs {
+		if isFree(f) && f.Params[0].Type == t {
+			return f, true
+		}
+	}
+	return Func{}, false
+}
+
+// isFree reports whether f is a free function: it returns nothing and takes
+// a single pointer, which it frees.
+func isFree(f Func) bool {
+	return strings.Contains(f.Name, "free") && f.Result == Type{Base: "void"} &&
+		len(f.Params) == 1 && f.Params[0].Type.Pointers == 1 && !f.Params[0].Type.Const
+}
+
+// Parse parses a header. The errors give the line of the declaration at
+// fault.
+func Parse(src []byte) (*Header, error) {
+	toks, err := lex(string(src))
+	if err != nil {
+		return nil, err
+	}
+	h := &Header{
+		byName:   make(map[string]int),
+		structs:  make(map[string]bool),
+		typedefs: make(map[string]Type),
+	}
+	p := &parser{h: h, toks: toks}
+	for p.peek().kind != tokEOF {
+		if err := p.declaration(); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// ParseSignature parses sig, the signature of the function name as returned
+// by Func.Signature, with the types declared by h.
+func (h *Header) ParseSignature(name, sig string) (Func, error) {
+	i := strings.Index(sig, "(")
+	if i < 0 {
+		return Func{}, fmt.Errorf("signature of %s: no parameters in %q", name, sig)
+	}
+	toks, err := lex(sig[:i] + " " + name + sig[i:] + ";")
+	if err != nil {
+		return Func{}, fmt.Errorf("signature of %s: %v", name, err)
+	}
+	p := &parser{h: h, toks: toks}
+	f, err := p.function()
+	if err == nil && p.peek().kind != tokEOF {
+		err = p.errorf("unexpected %s after the signature", p.peek())
+	}
+	if err != nil {
+		return Func{}, fmt.Errorf("signature of %s: %v", name, err)
+	}
+	return f, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokPunct
+	// tokAnnotation is an ownership annotation, /* owned */ or /* borrowed */.
+	tokAnnotation
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of file"
+	case tokAnnotation:
+		return "/* " + t.text + " */"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits src into tokens, dropping the preprocessor directives and the
+// comments but the annotations.
+func lex(src string) ([]token, error) {
+	var toks []token
+	line := 1
+	lineStart := true
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			lineStart = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == '#' && lineStart:
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		}
+		lineStart = false
+		switch {
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			body := src[i+2 : i+2+end]
+			if text := strings.TrimSpace(body); text == "owned" || text == "borrowed" {
+				toks = append(toks, token{tokAnnotation, text, line})
+			}
+			line += strings.Count(body, "\n")
+			i += end + 4
+		case isIdentStart(c) || isDigit(c):
+			j := i + 1
+			for j < len(src) && (isIdentStart(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			kind := tokIdent
+			if isDigit(c) {
+				kind = tokNumber
+			}
+			toks = append(toks, token{kind, src[i:j], line})
+			i = j
+		case strings.HasPrefix(src[i:], "..."):
+			toks = append(toks, token{tokPunct, "...", line})
+			i += 3
+		case strings.IndexByte("*(),;{}=-", c) >= 0:
+			toks = append(toks, token{tokPunct, string(c), line})
+			i++
+		default:
+			return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+		}
+	}
+	return append(toks, token{tokEOF, "", line}), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+type parser struct {
+	h    *Header
+	toks []token
+	pos  int
+	// annotations are those skipped since the last call to take.
+	annotations []token
+}
+
+// peek returns the next token which is not an annotation.
+func (p *parser) peek() token {
+	for p.toks[p.pos].kind == tokAnnotation {
+		p.annotations = append(p.annotations, p.toks[p.pos])
+		p.pos++
+	}
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is text.
+func (p *parser) accept(text string) bool {
+	if t := p.peek(); t.kind != tokEOF && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.errorf("expected %q, got %s", text, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", p.errorf("expected an identifier, got %s", t)
+	}
+	return t.text, nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.toks[p.pos].line, fmt.Sprintf(format, args...))
+}
+
+// take returns the ownership of a pointer of type t: the annotation skipped
+// since the last call, if any, or else def.
+func (p *parser) take(t Type, def bool) (bool, error) {
+	annotations := p.annotations
+	p.annotations = nil
+	if len(annotations) == 0 {
+		return def, nil
+	}
+	if len(annotations) > 1 {
+		return false, fmt.Errorf("line %d: more than one annotation", annotations[1].line)
+	}
+	if t.Pointers == 0 {
+		return false, fmt.Errorf("line %d: %s on %s, which is not a pointer", annotations[0].line, annotations[0], t)
+	}
+	return annotations[0].text == "owned", nil
+}
+
+// declaration parses a declaration at the top level.
+func (p *parser) declaration() error {
+	line := p.peek().line
+	switch {
+	case p.accept("enum"):
+		if _, err := p.ident(); err != nil {
+			return err
+		}
+		if err := p.skipBraces(); err != nil {
+			return err
+		}
+		if err := p.expect(";"); err != nil {
+			return err
+		}
+	case p.accept("typedef"):
+		if err := p.typedef(); err != nil {
+			return err
+		}
+	default:
+		f, err := p.function()
+		if err != nil {
+			return err
+		}
+		if i, ok := p.h.byName[f.Name]; ok {
+			return fmt.Errorf("line %d: %s redeclared, first declared on line %d", f.Line, f.Name, p.h.Funcs[i].Line)
+		}
+		p.h.byName[f.Name] = len(p.h.Funcs)
+		p.h.Funcs = append(p.h.Funcs, f)
+		return nil
+	}
+	if len(p.annotations) > 0 {
+		return fmt.Errorf("line %d: %s outside of a function", line, p.annotations[0])
+	}
+	return nil
+}
+
+// skipBraces skips a block in braces, e.g. the enumerators of an enum.
+func (p *parser) skipBraces() error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for depth := 1; depth > 0; {
+		switch t := p.next(); {
+		case t.kind == tokEOF:
+			return p.errorf("unterminated block")
+		case t.text == "{":
+			depth++
+		case t.text == "}":
+			depth--
+		}
+	}
+	return nil
+}
+
+// typedef parses a typedef after the keyword.
+func (p *parser) typedef() error {
+	var t Type
+	if p.accept("struct") {
+		tag, err := p.ident()
+		if err != nil {
+			return err
+		}
+		p.h.structs[tag] = true
+		if p.peek().text == "{" {
+			if err := p.fields(); err != nil {
+				return err
+			}
+		}
+		t = Type{Base: "struct " + tag}
+	} else {
+		var err error
+		if t, err = p.typ(); err != nil {
+			return err
+		}
+	}
+
+	if p.accept("(") {
+		// A function pointer: typedef void (*FduLogCallback)(int32_t level, ...);
+		if err := p.expect("*"); err != nil {
+			return err
+		}
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(")"); err != nil {
+			return err
+		}
+		if _, err := p.params(); err != nil {
+			return err
+		}
+		p.annotations = nil
+		p.h.typedefs[name] = Type{Base: name}
+		return p.expect(";")
+	}
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if _, ok := p.h.typedefs[name]; ok || builtins[name] {
+		return p.errorf("type %s redeclared", name)
+	}
+	if strings.HasPrefix(t.Base, "struct ") {
+		if t.Pointers > 0 || t.Const {
+			return p.errorf("typedef %s of %s is not supported", name, t)
+		}
+		p.h.typedefs[name] = t
+	} else {
+		// Integers stay named after the typedef, e.g. FduCall.
+		p.h.typedefs[name] = Type{Base: name}
+	}
+	return p.expect(";")
+}
+
+// fields parses the fields of a struct, only checking their types.
+func (p *parser) fields() error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for !p.accept("}") {
+		if p.peek().kind == tokEOF {
+			return p.errorf("unterminated struct")
+		}
+		if _, err := p.typ(); err != nil {
+			return err
+		}
+		if _, err := p.ident(); err != nil {
+			return err
+		}
+		if err := p.expect(";"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typ parses a type, e.g. const struct FduSession *.
+func (p *parser) typ() (Type, error) {
+	var t Type
+	t.Const = p.accept("const")
+	if p.accept("struct") {
+		tag, err := p.ident()
+		if err != nil {
+			return Type{}, err
+		}
+		if !p.h.structs[tag] {
+			return Type{}, p.errorf("unknown type struct %s", tag)
+		}
+		t.Base = "struct " + tag
+	} else {
+		name, err := p.ident()
+		if err != nil {
+			return Type{}, err
+		}
+		switch td, ok := p.h.typedefs[name]; {
+		case builtins[name]:
+			t.Base = name
+		case ok:
+			t.Base = td.Base
+		default:
+			return Type{}, p.errorf("unknown type %s", name)
+		}
+	}
+	for p.accept("*") {
+		t.Pointers++
+	}
+	if p.peek().text == "const" {
+		return Type{}, p.errorf("const pointers are not supported")
+	}
+	return t, nil
+}
+
+// function parses a prototype.
+func (p *parser) function() (Func, error) {
+	p.peek()
+	result, err := p.typ()
+	if err != nil {
+		return Func{}, err
+	}
+	line := p.peek().line
+	name, err := p.ident()
+	if err != nil {
+		return Func{}, err
+	}
+	f := Func{Name: name, Result: result, Line: line}
+	if f.Owned, err = p.take(result, result.Pointers > 0 && !result.Const); err != nil {
+		return Func{}, err
+	}
+	if f.Params, err = p.params(); err != nil {
+		return Func{}, err
+	}
+	if err := p.expect(";"); err != nil {
+		return Func{}, err
+	}
+	if len(p.annotations) > 0 {
+		return Func{}, fmt.Errorf("line %d: %s annotates no result or parameter", p.annotations[0].line, p.annotations[0])
+	}
+	return f, nil
+}
+
+// params parses the parameters of a function in parentheses.
+func (p *parser) params() ([]Param, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if p.peek().text == "void" && p.toks[p.pos+1].text == ")" {
+		p.pos += 2
+		p.annotations = nil
+		return nil, nil
+	}
+	var params []Param
+	for {
+		if p.peek().text == "..." {
+			return nil, p.errorf("variadic functions are not supported")
+		}
+		t, err := p.typ()
+		if err != nil {
+			return nil, err
+		}
+		if t == (Type{Base: "void"}) {
+			return nil, p.errorf("void parameter")
+		}
+		param := Param{Type: t}
+		if p.peek().kind == tokIdent {
+			param.Name = p.next().text
+		}
+		p.peek()
+		if param.Owned, err = p.take(t, t.Pointers >= 2 && !t.Const); err != nil {
+			return nil, err
+		}
+		if param.Owned && t.Pointers < 2 {
+			return nil, p.errorf("/* owned */ on %s, which is not a pointer to a pointer", t)
+		}
+		params = append(params, param)
+		if p.accept(")") {
+			return params, nil
+		}
+		if !p.accept(",") {
+			return nil, p.errorf(`expected "," or ")", got %s`, p.peek())
+		}
+	}
+}
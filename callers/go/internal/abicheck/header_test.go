@@ -0,0 +1,165 @@
+package abicheck
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseFile(t *testing.T, path string) *Header {
+	t.Helper()
+	src, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := Parse(src)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return h
+}
+
+func TestParse(t *testing.T) {
+	h := parseFile(t, "testdata/valid.h")
+	var names []string
+	for _, f := range h.Funcs {
+		names = append(names, f.Name)
+	}
+	want := []string{
+		"add", "fdu_abi_version", "fdu_login", "fdu_call_into", "fdu_poll_completions",
+		"fdu_session_export", "fdu_session_free", "fdu_set_log_callback", "fdu_version",
+		"fdu_static_name", "fdu_session_peek", "fdu_copy_name", "free_buffer", "free_result", "free_string",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got functions %q, want %q", names, want)
+	}
+
+	for _, tc := range []struct {
+		name, sig string
+		owns      []string
+	}{
+		{"add", "int (int, int)", nil},
+		{"fdu_abi_version", "uint32_t (void)", nil},
+		{"fdu_login", "struct FduResult *(const char *, const char *, struct FduSession **)",
+			[]string{"struct FduResult *", "struct FduSession *"}},
+		// FduCall stays named after the typedef, unlike the structs.
+		{"fdu_call_into", "size_t (FduCall, const struct FduSession *, uint8_t *, size_t, struct FduResult **)",
+			[]string{"struct FduResult *"}},
+		{"fdu_poll_completions", "size_t (struct FduCompletion *, size_t, uint64_t)", nil},
+		{"fdu_session_export", "struct FduResult *(const struct FduSession *, struct FduBuffer **)",
+			[]string{"struct FduResult *", "struct FduBuffer *"}},
+		{"fdu_session_free", "void (struct FduSession *)", nil},
+		{"fdu_set_log_callback", "struct FduResult *(int32_t, FduLogCallback)", []string{"struct FduResult *"}},
+		{"fdu_version", "const char *(void)", nil},
+		{"fdu_static_name", "char *(void)", nil},
+		{"fdu_session_peek", "struct FduResult *(const struct FduSession *, struct FduBuffer **)",
+			[]string{"struct FduResult *"}},
+		{"fdu_copy_name", "const char *(void)", []string{"const char *"}},
+	} {
+		f, ok := h.Func(tc.name)
+		if !ok {
+			t.Errorf("%s not found", tc.name)
+			continue
+		}
+		if got := f.Signature(); got != tc.sig {
+			t.Errorf("%s: got signature %q, want %q", tc.name, got, tc.sig)
+		}
+		var owns []string
+		for _, typ := range f.Owns() {
+			owns = append(owns, typ.String())
+		}
+		if !reflect.DeepEqual(owns, tc.owns) {
+			t.Errorf("%s: owns %q, want %q", tc.name, owns, tc.owns)
+		}
+	}
+
+	if f, _ := h.Func("fdu_login"); f.Line != 42 || f.Params[2].Name != "out" {
+		t.Errorf("fdu_login: got line %d and parameters %+v", f.Line, f.Params)
+	}
+	if _, ok := h.Func("fdu_cancel"); ok {
+		t.Error("found an undeclared function")
+	}
+}
+
+func TestFree(t *testing.T) {
+	h := parseFile(t, "testdata/valid.h")
+	for typ, want := range map[Type]string{
+		{Base: "struct FduResult", Pointers: 1}:  "free_result",
+		{Base: "struct FduBuffer", Pointers: 1}:  "free_buffer",
+		{Base: "struct FduSession", Pointers: 1}: "fdu_session_free",
+		{Base: "char", Pointers: 1}:              "free_string",
+		// The result of fdu_copy_name is const, but owned all the same.
+		{Const: true, Base: "char", Pointers: 1}: "free_string",
+	} {
+		if f, ok := h.Free(typ); !ok || f.Name != want {
+			t.Errorf("Free(%v) = %s, %v, want %s", typ, f.Name, ok, want)
+		}
+	}
+	for _, typ := range []Type{
+		{Base: "struct FduCompletion", Pointers: 1},
+		{Base: "struct FduResult", Pointers: 2},
+		{Base: "uint8_t", Pointers: 1},
+	} {
+		if f, ok := h.Free(typ); ok {
+			t.Errorf("Free(%v) = %s, want none", typ, f.Name)
+		}
+	}
+}
+
+// TestParseBroken parses the headers of testdata/broken, each of which fails
+// with the error in its first line, // want: error.
+func TestParseBroken(t *testing.T) {
+	paths, err := filepath.Glob("testdata/broken/*.h")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no broken header: %v", err)
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first, _, _ := strings.Cut(string(src), "\n")
+		want, ok := strings.CutPrefix(first, "// want: ")
+		if !ok {
+			t.Errorf("%s: no // want: line", path)
+			continue
+		}
+		if h, err := Parse(src); err == nil {
+			t.Errorf("%s: parsed %d functions, want %q", path, len(h.Funcs), want)
+		} else if err.Error() != want {
+			t.Errorf("%s: got %q, want %q", path, err, want)
+		}
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	h := parseFile(t, "testdata/valid.h")
+	for _, f := range h.Funcs {
+		got, err := h.ParseSignature(f.Name, f.Signature())
+		if err != nil {
+			t.Errorf("%s: %v", f.Name, err)
+			continue
+		}
+		if got.Signature() != f.Signature() {
+			t.Errorf("%s: got %q back from %q", f.Name, got.Signature(), f.Signature())
+		}
+	}
+
+	if f, err := h.ParseSignature("f", "struct FduResult *(struct FduBuffer ** /* borrowed */)"); err != nil || f.Params[0].Owned {
+		t.Errorf("annotated: got %+v, %v", f, err)
+	}
+	for _, sig := range []string{
+		"",
+		"void",
+		"void (FduBuffer *",
+		"void (struct FduCancelToken *)",
+		"void (int); int",
+		"void () extra",
+	} {
+		if f, err := h.ParseSignature("f", sig); err == nil {
+			t.Errorf("ParseSignature(%q) = %s, want error", sig, f.Signature())
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// want: line 3: /* owned */ on uint32_t, which is not a pointer
+#include <stdint.h>
+uint32_t /* owned */ fdu_abi_version(void);
@@ -0,0 +1,2 @@
+// want: line 2: /* owned */ on char *, which is not a pointer to a pointer
+void fdu_fill(char *buf /* owned */);
@@ -0,0 +1,3 @@
+// want: line 3: /* owned */ outside of a function
+#include <stdint.h>
+typedef int32_t /* owned */ FduCall;
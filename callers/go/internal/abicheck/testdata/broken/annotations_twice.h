@@ -0,0 +1,2 @@
+// want: line 2: more than one annotation
+char * /* owned */ /* borrowed */ fdu_name(void);
@@ -0,0 +1,3 @@
+// want: line 3: unexpected character '['
+#include <stdint.h>
+void fdu_fill(uint8_t buf[16]);
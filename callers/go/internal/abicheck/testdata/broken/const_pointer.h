@@ -0,0 +1,2 @@
+// want: line 2: const pointers are not supported
+void fdu_print(const char *const s);
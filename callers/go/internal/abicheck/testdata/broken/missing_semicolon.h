@@ -0,0 +1,4 @@
+// want: line 4: expected ";", got "void"
+#include <stdint.h>
+uint32_t fdu_abi_version(void)
+void fdu_shutdown(void);
@@ -0,0 +1,4 @@
+// want: line 4: fdu_shutdown redeclared, first declared on line 2
+void fdu_shutdown(void);
+
+void fdu_shutdown(void);
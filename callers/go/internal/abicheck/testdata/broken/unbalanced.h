@@ -0,0 +1,3 @@
+// want: line 3: expected "," or ")", got ";"
+void fdu_cancel(const char *a,
+                const char *b;
@@ -0,0 +1,2 @@
+// want: line 2: unknown type struct FduSession
+void fdu_session_free(struct FduSession *session);
@@ -0,0 +1,3 @@
+// want: line 3: unknown type FduSession
+/* The typedef is missing. */
+void fdu_session_free(FduSession *session);
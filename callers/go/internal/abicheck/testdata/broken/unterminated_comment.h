@@ -0,0 +1,4 @@
+// want: line 3: unterminated comment
+void fdu_shutdown(void);
+/* The end of the header is lost.
+void free_string(char *s);
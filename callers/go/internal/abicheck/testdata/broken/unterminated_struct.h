@@ -0,0 +1,5 @@
+// want: line 6: unterminated struct
+#include <stdint.h>
+typedef struct FduBuffer {
+  uint8_t *data;
+  size_t len;
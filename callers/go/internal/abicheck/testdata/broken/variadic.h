@@ -0,0 +1,2 @@
+// want: line 2: variadic functions are not supported
+void fdu_log(const char *format, ...);
@@ -0,0 +1,2 @@
+// want: line 2: void parameter
+void fdu_shutdown(int a, void);
@@ -0,0 +1,11 @@
+/* A header whose strings and sessions cannot be freed. */
+
+#include <stdint.h>
+
+typedef struct FduSession FduSession;
+
+char *fdu_name(void);
+
+void fdu_login(const char *username, struct FduSession **out);
+
+void free_result(char *s);
@@ -0,0 +1,75 @@
+#ifndef FIXTURE_H
+#define FIXTURE_H
+
+/* What cbindgen emits for bindings.h, plus the annotations. */
+
+#include <stdbool.h>
+#include <stdint.h>
+
+#define FDU_ABI_VERSION 1
+
+enum FduCall {
+  FDU_CALL_ALLOC_STATS = 1,
+  FDU_CALL_SESSION_UID = 3,
+};
+typedef int32_t FduCall;
+
+typedef struct FduSession FduSession;
+
+typedef struct FduBuffer {
+  uint8_t *data;
+  size_t len;
+} FduBuffer;
+
+typedef struct FduResult {
+  char *value;
+  int32_t code;
+  char *message;
+} FduResult;
+
+typedef struct FduCompletion {
+  uint64_t request_id;
+  struct FduResult *result;
+} FduCompletion;
+
+typedef void (*FduLogCallback)(int32_t level, const char *message);
+
+// A plain function.
+int add(int a, int b);
+
+uint32_t fdu_abi_version(void);
+
+struct FduResult *fdu_login(const char *username,
+                            const char *password,
+                            struct FduSession **out);
+
+size_t fdu_call_into(FduCall call,
+                     const struct FduSession *session,
+                     uint8_t *buf,
+                     size_t cap,
+                     struct FduResult **out);
+
+size_t fdu_poll_completions(struct FduCompletion *buf, size_t n, uint64_t timeout_millis);
+
+struct FduResult *fdu_session_export(const struct FduSession *session, FduBuffer **out);
+
+void fdu_session_free(struct FduSession *session);
+
+struct FduResult *fdu_set_log_callback(int32_t level, FduLogCallback callback);
+
+/* The version is static. */
+const char *fdu_version(void);
+
+char * /* borrowed */ fdu_static_name(void);
+
+struct FduResult *fdu_session_peek(const struct FduSession *session, struct FduBuffer **out /* borrowed */);
+
+/* owned */ const char *fdu_copy_name(void);
+
+void free_buffer(struct FduBuffer *buf);
+
+void free_result(struct FduResult *r);
+
+void free_string(char *s);
+
+#endif /* FIXTURE_H */
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// enumValue is an enumerator of a C enum.
+type enumValue struct {
+	Name  string
+	Value int64
+}
+
+var (
+	blockComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	lineComment  = regexp.MustCompile(`//[^\n]*`)
+	identifier   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// parseEnum returns the enumerators of the C enum named name in src, in
+// declaration order. Enumerators without an explicit value follow the
+// previous one, as in C. Values may be written in decimal, hex, octal or
+// binary, optionally negative.
+func parseEnum(src []byte, name string) ([]enumValue, error) {
+	text := lineComment.ReplaceAllString(blockComment.ReplaceAllString(string(src), " "), " ")
+	re := regexp.MustCompile(`\benum\s+` + regexp.QuoteMeta(name) + `\s*\{([^}]*)\}`)
+	m := re.FindStringSubmatch(text)
+	if m == nil {
+		return nil, fmt.Errorf("enum %s not found", name)
+	}
+
+	var values []enumValue
+	seen := make(map[string]bool)
+	next := int64(0)
+	for _, item := range strings.Split(m[1], ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		ident, expr, explicit := strings.Cut(item, "=")
+		ident = strings.TrimSpace(ident)
+		if !identifier.MatchString(ident) {
+			return nil, fmt.Errorf("enum %s: invalid enumerator %q", name, item)
+		}
+		if seen[ident] {
+			return nil, fmt.Errorf("enum %s: duplicate enumerator %s", name, ident)
+		}
+		seen[ident] = true
+		if explicit {
+			v, err := parseInt(strings.TrimSpace(expr))
+			if err != nil {
+				return nil, fmt.Errorf("enum %s: %s: %w", name, ident, err)
+			}
+			next = v
+		}
+		values = append(values, enumValue{ident, next})
+		next++
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("enum %s is empty", name)
+	}
+	return values, nil
+}
+
+// parseInt parses a C integer literal, ignoring integer suffixes like U or L.
+func parseInt(s string) (int64, error) {
+	s = strings.TrimPrefix(strings.TrimSuffix(s, ")"), "(")
+	s = strings.TrimRight(s, "uUlL")
+	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported value %q", s)
+	}
+	return v, nil
+}
+
+// initialisms are kept upper case in Go names, e.g. QR_DISABLED becomes
+// QRDisabled.
+var initialisms = map[string]bool{
+	"CA": true, "DNS": true, "HTTP": true, "ID": true, "OK": true, "QR": true, "TLS": true, "UID": true, "UIS": true, "URL": true,
+}
+
+// goName converts an enumerator without its prefix, e.g. AUTH_FAILED, to a
+// Go name, e.g. AuthFailed.
+func goName(s string) string {
+	var b strings.Builder
+	for _, word := range strings.Split(s, "_") {
+		if word == "" {
+			continue
+		}
+		if initialisms[word] {
+			b.WriteString(word)
+			continue
+		}
+		b.WriteString(word[:1])
+		b.WriteString(strings.ToLower(word[1:]))
+	}
+	return b.String()
+}
+
+type generateConfig struct {
+	// Source is the name of the header, mentioned in the generated code.
+	Source string
+	Enum   string
+	Prefix string
+	Type   string
+	Pkg    string
+}
+
+type constant struct {
+	GoName string
+	CName  string
+	Short  string
+	Value  int64
+}
+
+var codeTemplate = template.Must(template.New("").Parse(`// Code generated by internal/gen from {{.Source}}; DO NOT EDIT.
+
+package {{.Pkg}}
+
+import "strconv"
+
+// {{.Type}} is a value of {{.Enum}}.
+type {{.Type}} int32
+
+const (
+{{- range .Constants}}
+	{{.GoName}} {{$.Type}} = {{.Value}} // {{.CName}}
+{{- end}}
+)
+
+// all{{.Type}}s lists the {{.Type}} constants in the order of {{.Source}}.
+var all{{.Type}}s = []{{.Type}}{
+{{- range .Constants}}
+	{{.GoName}},
+{{- end}}
+}
+
+func (c {{.Type}}) String() string {
+	switch c {
+{{- range .Constants}}
+	case {{.GoName}}:
+		return "{{.Short}}"
+{{- end}}
+	}
+	return "{{.Type}}(" + strconv.FormatInt(int64(c), 10) + ")"
+}
+`))
+
+// generate returns the formatted Go source for values.
+func generate(values []enumValue, config generateConfig) ([]byte, error) {
+	var constants []constant
+	seen := make(map[string]string)
+	for _, v := range values {
+		short, ok := strings.CutPrefix(v.Name, config.Prefix)
+		if !ok {
+			return nil, fmt.Errorf("enumerator %s does not start with %s", v.Name, config.Prefix)
+		}
+		name := config.Type + goName(short)
+		if other, ok := seen[name]; ok {
+			return nil, fmt.Errorf("enumerators %s and %s both map to %s", other, v.Name, name)
+		}
+		seen[name] = v.Name
+		if v.Value < -1<<31 || v.Value > 1<<31-1 {
+			return nil, fmt.Errorf("enumerator %s: value %d overflows int32", v.Name, v.Value)
+		}
+		constants = append(constants, constant{GoName: name, CName: v.Name, Short: short, Value: v.Value})
+	}
+
+	var buf bytes.Buffer
+	err := codeTemplate.Execute(&buf, struct {
+		generateConfig
+		Constants []constant
+	}{config, constants})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestParseEnum(t *testing.T) {
+	src, err := os.ReadFile("testdata/enum.h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := parseEnum(src, "FduErrorCode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []enumValue{
+		{"FDU_ERROR_CODE_OK", 0},
+		{"FDU_ERROR_CODE_UNKNOWN", 1},
+		{"FDU_ERROR_CODE_NETWORK", 2},
+		{"FDU_ERROR_CODE_AUTH_FAILED", 3},
+		{"FDU_ERROR_CODE_QR_DISABLED", 0x10},
+		{"FDU_ERROR_CODE_CERT_PIN_MISMATCH", 0x11},
+		{"FDU_ERROR_CODE_RATE_LIMITED", 0x20},
+		{"FDU_ERROR_CODE_INTERNAL", -1},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+}
+
+func TestParseEnumInvalid(t *testing.T) {
+	cases := map[string]string{
+		"missing":    `enum Other { A = 1 };`,
+		"empty":      `enum FduErrorCode { };`,
+		"expression": `enum FduErrorCode { A = 1 << 2 };`,
+		"identifier": `enum FduErrorCode { 1A = 1 };`,
+		"duplicate":  `enum FduErrorCode { A = 1, A = 2 };`,
+	}
+	for name, src := range cases {
+		if values, err := parseEnum([]byte(src), "FduErrorCode"); err == nil {
+			t.Errorf("%s: got %v, want error", name, values)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	cases := map[string]string{
+		"OK":                "OK",
+		"AUTH_FAILED":       "AuthFailed",
+		"INVALID_ARGUMENT":  "InvalidArgument",
+		"QR_DISABLED":       "QRDisabled",
+		"CERT_PIN_MISMATCH": "CertPinMismatch",
+		"SESSION_UID":       "SessionUID",
+	}
+	for in, want := range cases {
+		if got := goName(in); got != want {
+			t.Errorf("goName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := os.ReadFile("testdata/enum.h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := parseEnum(src, "FduErrorCode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := generate(values, generateConfig{
+		Source: "enum.h",
+		Enum:   "FduErrorCode",
+		Prefix: "FDU_ERROR_CODE_",
+		Type:   "ErrCode",
+		Pkg:    "fdu",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`^// Code generated by internal/gen from enum\.h; DO NOT EDIT\.$`,
+		`^package fdu$`,
+		`^\tErrCodeAuthFailed\s+ErrCode = 3 `,
+		`^\tErrCodeQRDisabled\s+ErrCode = 16 `,
+		`^\tErrCodeInternal\s+ErrCode = -1 `,
+		`^\t\treturn "CERT_PIN_MISMATCH"$`,
+	} {
+		if !regexp.MustCompile("(?m)" + want).Match(code) {
+			t.Errorf("generated code does not match %q:\n%s", want, code)
+		}
+	}
+
+	if _, err := generate(values, generateConfig{Prefix: "FDU_", Type: "ErrCode", Pkg: "fdu"}); err != nil {
+		t.Errorf("prefix FDU_: %v", err)
+	}
+	if _, err := generate(values, generateConfig{Prefix: "OTHER_", Type: "ErrCode", Pkg: "fdu"}); err == nil {
+		t.Error("got no error for a prefix not matching")
+	}
+}
@@ -0,0 +1,66 @@
+// Command gen generates Go constants from a C enum in a header generated by
+// cbindgen, so that the Go wrapper never drifts from the library.
+//
+// Usage:
+//
+//	gen -enum FduErrorCode -prefix FDU_ERROR_CODE_ -type ErrCode -pkg fdu -o errcodes_gen.go bindings.h
+//
+// For each enumerator FDU_ERROR_CODE_AUTH_FAILED = 3, it emits a typed
+// constant ErrCodeAuthFailed ErrCode = 3, plus a String method and a list of
+// all constants.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	enum := flag.String("enum", "", "name of the C enum")
+	prefix := flag.String("prefix", "", "prefix of the enumerators to strip")
+	typeName := flag.String("type", "", "name of the Go type to generate")
+	pkg := flag.String("pkg", "", "name of the Go package")
+	out := flag.String("o", "", "output file, or stdout if empty")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gen -enum name -prefix prefix -type name -pkg name [-o file] header.h\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *enum == "" || *typeName == "" || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *enum, *prefix, *typeName, *pkg, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(header, enum, prefix, typeName, pkg, out string) error {
+	src, err := os.ReadFile(header)
+	if err != nil {
+		return err
+	}
+	values, err := parseEnum(src, enum)
+	if err != nil {
+		return fmt.Errorf("%s: %w", header, err)
+	}
+	code, err := generate(values, generateConfig{
+		Source: filepath.Base(header),
+		Enum:   enum,
+		Prefix: prefix,
+		Type:   typeName,
+		Pkg:    pkg,
+	})
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(out, code, 0o644)
+}
@@ -0,0 +1,25 @@
+#ifndef FIXTURE_H
+#define FIXTURE_H
+
+#include <stdint.h>
+
+/* An unrelated enum with the same enumerator names. */
+enum Other {
+  FDU_ERROR_CODE_OK = 100,
+};
+
+enum FduErrorCode {
+  FDU_ERROR_CODE_OK = 0,
+  // Implicit values follow the previous one.
+  FDU_ERROR_CODE_UNKNOWN,
+  FDU_ERROR_CODE_NETWORK,
+  FDU_ERROR_CODE_AUTH_FAILED = 3,
+  /* A gap, then hex literals. */
+  FDU_ERROR_CODE_QR_DISABLED = 0x10,
+  FDU_ERROR_CODE_CERT_PIN_MISMATCH,
+  FDU_ERROR_CODE_RATE_LIMITED = 0X20U,
+  FDU_ERROR_CODE_INTERNAL = -1,
+};
+typedef int32_t FduErrorCode;
+
+#endif /* FIXTURE_H */
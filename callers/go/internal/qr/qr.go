@@ -0,0 +1,513 @@
+// Package qr encodes short texts, such as payment codes, as QR codes
+// (ISO/IEC 18004) and renders them as PNG images.
+//
+// Only what libfdu needs is supported: byte mode, versions 1 to 10, i.e. up
+// to 271 bytes at level L.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Level is an error correction level.
+type Level int
+
+const (
+	// L recovers about 7% of the codewords.
+	L Level = iota
+	// M recovers about 15% of the codewords.
+	M
+	// Q recovers about 25% of the codewords.
+	Q
+	// H recovers about 30% of the codewords.
+	H
+)
+
+// ErrTooLong is returned by Encode when the text does not fit in version 10.
+var ErrTooLong = errors.New("qr: text too long")
+
+// maxVersion is the largest supported version.
+const maxVersion = 10
+
+// QuietZone is the width in modules of the light border around the symbol
+// in the images of Code.PNG.
+const QuietZone = 4
+
+// blocks describes the error correction blocks of a version at a level:
+// ecLen error correction codewords per block, and n1 blocks of len1 data
+// codewords followed by n2 blocks of len1+1.
+type blocks struct {
+	ecLen, n1, len1, n2 int
+}
+
+// blockTable is indexed by version-1 and Level.
+var blockTable = [maxVersion][4]blocks{
+	{{7, 1, 19, 0}, {10, 1, 16, 0}, {13, 1, 13, 0}, {17, 1, 9, 0}},
+	{{10, 1, 34, 0}, {16, 1, 28, 0}, {22, 1, 22, 0}, {28, 1, 16, 0}},
+	{{15, 1, 55, 0}, {26, 1, 44, 0}, {18, 2, 17, 0}, {22, 2, 13, 0}},
+	{{20, 1, 80, 0}, {18, 2, 32, 0}, {26, 2, 24, 0}, {16, 4, 9, 0}},
+	{{26, 1, 108, 0}, {24, 2, 43, 0}, {18, 2, 15, 2}, {22, 2, 11, 2}},
+	{{18, 2, 68, 0}, {16, 4, 27, 0}, {24, 4, 19, 0}, {28, 4, 15, 0}},
+	{{20, 2, 78, 0}, {18, 4, 31, 0}, {18, 2, 14, 4}, {26, 4, 13, 1}},
+	{{24, 2, 97, 0}, {22, 2, 38, 2}, {22, 4, 18, 2}, {26, 4, 14, 2}},
+	{{30, 2, 116, 0}, {22, 3, 36, 2}, {20, 4, 16, 4}, {24, 4, 12, 4}},
+	{{18, 2, 68, 2}, {26, 4, 43, 1}, {24, 6, 19, 2}, {28, 6, 15, 2}},
+}
+
+// alignmentTable lists the centers of the alignment patterns, indexed by
+// version-1.
+var alignmentTable = [maxVersion][]int{
+	nil, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// formatLevelBits are the bits of each Level in the format information.
+var formatLevelBits = [4]int{L: 1, M: 0, Q: 3, H: 2}
+
+func (b blocks) dataLen() int {
+	return b.n1*b.len1 + b.n2*(b.len1+1)
+}
+
+// Code is an encoded QR code.
+type Code struct {
+	// Size is the number of modules on each side, without the quiet zone.
+	Size int
+	// Version is from 1 to 10.
+	Version int
+	// dark holds the modules, row by row.
+	dark []bool
+	// function marks the modules of the function patterns, which are not
+	// masked.
+	function []bool
+}
+
+// Dark reports whether the module at column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.dark[y*c.Size+x]
+}
+
+// Encode encodes text in byte mode at level, in the smallest version it fits
+// in, with the mask of lowest penalty.
+func Encode(text string, level Level) (*Code, error) {
+	data := []byte(text)
+	version := 1
+	for ; version <= maxVersion; version++ {
+		if 4+countBits(version)+8*len(data) <= 8*blockTable[version-1][level].dataLen() {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrTooLong
+	}
+
+	codewords := interleave(version, level, dataCodewords(version, level, data))
+	c := newCode(version)
+	c.drawFunctionPatterns()
+	c.drawCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(level, mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // Masking twice is a no-op.
+	}
+	c.applyMask(best)
+	c.drawFormat(level, best)
+	return c, nil
+}
+
+// PNG renders c with scale pixels per module and a quiet zone of QuietZone
+// modules, black on white.
+func (c *Code) PNG(scale int) ([]byte, error) {
+	if scale < 1 {
+		return nil, errors.New("qr: scale must be positive")
+	}
+	side := (c.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Dark(x, y) {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				row := img.Pix[((y+QuietZone)*scale+dy)*img.Stride:]
+				for dx := 0; dx < scale; dx++ {
+					row[(x+QuietZone)*scale+dx] = 1
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// countBits returns the length of the character count of byte mode.
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// bitWriter appends bits to a byte slice, most significant first.
+type bitWriter struct {
+	buf []byte
+	n   int
+}
+
+func (w *bitWriter) write(v, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v>>i&1 != 0 {
+			w.buf[w.n/8] |= 0x80 >> (w.n % 8)
+		}
+		w.n++
+	}
+}
+
+// dataCodewords returns the data codewords of data in byte mode, with the
+// terminator and the padding.
+func dataCodewords(version int, level Level, data []byte) []byte {
+	capacity := 8 * blockTable[version-1][level].dataLen()
+	var w bitWriter
+	w.write(0b0100, 4)
+	w.write(len(data), countBits(version))
+	for _, b := range data {
+		w.write(int(b), 8)
+	}
+	w.write(0, min(4, capacity-w.n))
+	w.write(0, (8-w.n%8)%8)
+	for pad := 0xEC; w.n < capacity; pad ^= 0xEC ^ 0x11 {
+		w.write(pad, 8)
+	}
+	return w.buf
+}
+
+// interleave splits the data codewords into blocks, appends the error
+// correction codewords of each block, and interleaves the blocks.
+func interleave(version int, level Level, data []byte) []byte {
+	b := blockTable[version-1][level]
+	divisor := rsDivisor(b.ecLen)
+	var dataBlocks, ecBlocks [][]byte
+	for i := 0; i < b.n1+b.n2; i++ {
+		n := b.len1
+		if i >= b.n1 {
+			n++
+		}
+		dataBlocks = append(dataBlocks, data[:n])
+		ecBlocks = append(ecBlocks, rsRemainder(data[:n], divisor))
+		data = data[n:]
+	}
+	var out []byte
+	for i := 0; i <= b.len1; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < b.ecLen; i++ {
+		for _, block := range ecBlocks {
+			out = append(out, block[i])
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		hi := z & 0x80
+		z <<= 1
+		if hi != 0 {
+			z ^= 0x1D
+		}
+		if y>>i&1 != 0 {
+			z ^= x
+		}
+	}
+	return z
+}
+
+// rsDivisor returns the coefficients of the Reed-Solomon generator
+// polynomial of the given degree, highest first, without the leading 1.
+func rsDivisor(degree int) []byte {
+	divisor := make([]byte, degree)
+	divisor[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range divisor {
+			divisor[j] = gfMul(divisor[j], root)
+			if j+1 < degree {
+				divisor[j] ^= divisor[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return divisor
+}
+
+// rsRemainder returns the error correction codewords of data.
+func rsRemainder(data, divisor []byte) []byte {
+	remainder := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[len(remainder)-1] = 0
+		for i, d := range divisor {
+			remainder[i] ^= gfMul(d, factor)
+		}
+	}
+	return remainder
+}
+
+func newCode(version int) *Code {
+	size := 17 + 4*version
+	return &Code{Size: size, Version: version, dark: make([]bool, size*size), function: make([]bool, size*size)}
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.dark[y*c.Size+x] = dark
+	c.function[y*c.Size+x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	centers := alignmentTable[c.Version-1]
+	last := len(centers) - 1
+	for i, x := range centers {
+		for j, y := range centers {
+			// The corners with a finder pattern have none.
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format information, drawn once the mask is known.
+	c.drawFormat(L, 0)
+	c.drawVersion()
+}
+
+// drawFinder draws a finder pattern centered at (x, y), with its separator.
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.Size || yy < 0 || yy >= c.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// formatBits returns the 15 bits of the format information.
+func formatBits(level Level, mask int) int {
+	data := formatLevelBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionBits returns the 18 bits of the version information.
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+func (c *Code) drawFormat(level Level, mask int) {
+	bits := formatBits(level, mask)
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+	// Around the top left finder pattern.
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+	// Split between the other two.
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionBits(c.Version)
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 != 0
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords in the zigzag order, in pairs of
+// columns from the right, skipping the function patterns. The remainder
+// bits are left light.
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// Skip the vertical timing pattern.
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if c.function[y*c.Size+x] || i >= 8*len(codewords) {
+					continue
+				}
+				c.dark[y*c.Size+x] = codewords[i/8]>>(7-i%8)&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+// maskFuncs are the data masks, given the column x and the row y.
+var maskFuncs = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+func (c *Code) applyMask(mask int) {
+	f := maskFuncs[mask]
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.function[y*c.Size+x] && f(x, y) {
+				c.dark[y*c.Size+x] = !c.dark[y*c.Size+x]
+			}
+		}
+	}
+}
+
+// finderLike are the runs penalized by the third rule: 1:1:3:1:1 with four
+// light modules on either side.
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penalty scores the symbol with the four rules of the standard, the lowest
+// score being the easiest to read.
+func (c *Code) penalty() int {
+	p := 0
+	line := make([]bool, c.Size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < c.Size; i++ {
+			for j := range line {
+				if vertical {
+					line[j] = c.Dark(i, j)
+				} else {
+					line[j] = c.Dark(j, i)
+				}
+			}
+			// Runs of 5 or more modules of the same color.
+			run := 1
+			for j := 1; j <= c.Size; j++ {
+				if j < c.Size && line[j] == line[j-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += 3 + run - 5
+				}
+				run = 1
+			}
+			for j := 0; j+11 <= c.Size; j++ {
+				for _, pattern := range finderLike {
+					if matches(line[j:j+11], pattern[:]) {
+						p += 40
+					}
+				}
+			}
+		}
+	}
+	// 2x2 blocks of the same color.
+	for y := 0; y+1 < c.Size; y++ {
+		for x := 0; x+1 < c.Size; x++ {
+			d := c.Dark(x, y)
+			if c.Dark(x+1, y) == d && c.Dark(x, y+1) == d && c.Dark(x+1, y+1) == d {
+				p += 3
+			}
+		}
+	}
+	// Balance of dark and light modules.
+	dark := 0
+	for _, d := range c.dark {
+		if d {
+			dark++
+		}
+	}
+	total := len(c.dark)
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return p + 10*k
+}
+
+func matches(line, pattern []bool) bool {
+	for i := range pattern {
+		if line[i] != pattern[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,179 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" at 1-M, from the worked example of the standard.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	for _, tc := range []struct {
+		level Level
+		mask  int
+		want  int
+	}{
+		{L, 0, 0b111011111000100},
+		{L, 4, 0b110011000101111},
+		{M, 0, 0b101010000010010},
+		{Q, 0, 0b011010101011111},
+		{H, 0, 0b001011010001001},
+	} {
+		if got := formatBits(tc.level, tc.mask); got != tc.want {
+			t.Errorf("formatBits(%d, %d) = %015b, want %015b", tc.level, tc.mask, got, tc.want)
+		}
+	}
+	if got, want := versionBits(7), 0b000111110010010100; got != want {
+		t.Errorf("versionBits(7) = %018b, want %018b", got, want)
+	}
+}
+
+// readFormat reads the format information around the top left finder
+// pattern of c.
+func readFormat(c *Code) int {
+	bits := 0
+	set := func(i int, dark bool) {
+		if dark {
+			bits |= 1 << i
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		set(i, c.Dark(8, i))
+	}
+	set(6, c.Dark(8, 7))
+	set(7, c.Dark(8, 8))
+	set(8, c.Dark(7, 8))
+	for i := 9; i < 15; i++ {
+		set(i, c.Dark(14-i, 8))
+	}
+	return bits
+}
+
+// readCodewords unmasks c and reads its codewords back.
+func readCodewords(c *Code, mask int) []byte {
+	var w bitWriter
+	// The number of codewords only depends on the version.
+	b := blockTable[c.Version-1][L]
+	total := b.dataLen() + (b.n1+b.n2)*b.ecLen
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for _, x := range []int{right, right - 1} {
+				if c.function[y*c.Size+x] || w.n >= 8*total {
+					continue
+				}
+				dark := c.Dark(x, y) != maskFuncs[mask](x, y)
+				if dark {
+					w.write(1, 1)
+				} else {
+					w.write(0, 1)
+				}
+			}
+		}
+	}
+	return w.buf
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		text    string
+		level   Level
+		version int
+	}{
+		{"SWL2", M, 1},
+		{"SWL2" + strings.Repeat("0123456789", 4), M, 4},
+		{strings.Repeat("x", 100), L, 5},
+		// Versions with version information and several block sizes.
+		{strings.Repeat("y", 130), Q, 9},
+		{strings.Repeat("z", 200), M, 10},
+	} {
+		c, err := Encode(tc.text, tc.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Version != tc.version || c.Size != 17+4*tc.version {
+			t.Errorf("%d bytes at level %d: got version %d and size %d, want version %d", len(tc.text), tc.level, c.Version, c.Size, tc.version)
+			continue
+		}
+
+		format := readFormat(c)
+		mask := -1
+		for m := 0; m < 8; m++ {
+			if formatBits(tc.level, m) == format {
+				mask = m
+			}
+		}
+		if mask < 0 {
+			t.Errorf("%d bytes: invalid format information %015b", len(tc.text), format)
+			continue
+		}
+		want := interleave(c.Version, tc.level, dataCodewords(c.Version, tc.level, []byte(tc.text)))
+		if got := readCodewords(c, mask); !bytes.Equal(got, want) {
+			t.Errorf("%d bytes: codewords read back differ", len(tc.text))
+		}
+	}
+
+	if _, err := Encode(strings.Repeat("x", 272), L); err != ErrTooLong {
+		t.Errorf("got %v, want ErrTooLong", err)
+	}
+}
+
+func TestDataCodewords(t *testing.T) {
+	// Byte mode, 2 characters, terminator, then the padding bytes.
+	got := dataCodewords(1, H, []byte("ab"))
+	want := []byte{0x40, 0x26, 0x16, 0x20, 0xEC, 0x11, 0xEC, 0x11, 0xEC}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	c, err := Encode("SWL2000123456789", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.PNG(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	side := (c.Size + 2*QuietZone) * 3
+	if b := img.Bounds(); b.Dx() != side || b.Dy() != side {
+		t.Fatalf("got %v, want %dx%d", b, side, side)
+	}
+	dark := func(x, y int) bool {
+		r, _, _, _ := img.At(x, y).RGBA()
+		return r == 0
+	}
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			mx, my := x/3-QuietZone, y/3-QuietZone
+			want := mx >= 0 && mx < c.Size && my >= 0 && my < c.Size && c.Dark(mx, my)
+			if dark(x, y) != want {
+				t.Fatalf("pixel (%d, %d) is not module (%d, %d)", x, y, mx, my)
+			}
+		}
+	}
+	if _, err := c.PNG(0); err == nil {
+		t.Error("scale 0 accepted")
+	}
+}
@@ -7,8 +7,17 @@ import utils
 
 DLL = ctypes.cdll.LoadLibrary("../../target/debug/libfdu.dll")
 
-DLL.hello_world.restype = ctypes.c_void_p
-DLL.free_string.argtypes = (ctypes.c_void_p,)
+
+class FduResult(ctypes.Structure):
+    _fields_ = [
+        ("value", ctypes.c_char_p),
+        ("code", ctypes.c_int32),
+        ("message", ctypes.c_char_p),
+    ]
+
+
+DLL.hello_world.restype = ctypes.POINTER(FduResult)
+DLL.free_result.argtypes = (ctypes.POINTER(FduResult),)
 
 
 class TestMain(unittest.TestCase):
@@ -20,10 +29,10 @@ class TestMain(unittest.TestCase):
     def test_hello_world(self):
         ptr = DLL.hello_world()
         try:
-            res = ctypes.cast(ptr, ctypes.c_char_p).value.decode()
+            self.assertEqual(ptr.contents.code, 0)
+            res = ptr.contents.value.decode()
         finally:
-            DLL.free_string(ptr)
-            pass
+            DLL.free_result(ptr)
         self.assertEqual(res, 'hello world')
 
     def test_memory_leak(self):
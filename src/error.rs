@@ -6,6 +6,16 @@ pub enum ErrorType {
     LoginError,
     ParseError,
     NetworkError,
+    ArgumentError,
+    CancelledError,
+    // UIS asks for a captcha, or the captcha answer was wrong.
+    CaptchaRequiredError,
+    // The account has QR payment (付款码) disabled.
+    QrDisabledError,
+    // No public key of the chain of a server is pinned, see `HttpConfig::pinned_spki_hashes`.
+    CertPinMismatchError,
+    // A request would wait for the rate limit of its host past the deadline of the call, see `ratelimit`.
+    RateLimitedError,
     NoneError,
     OtherError,
 }
@@ -22,6 +32,12 @@ impl Display for ErrorType {
             ErrorType::LoginError => write!(f, "LoginError"),
             ErrorType::ParseError => write!(f, "ParseError"),
             ErrorType::NetworkError => write!(f, "NetworkError"),
+            ErrorType::ArgumentError => write!(f, "ArgumentError"),
+            ErrorType::CancelledError => write!(f, "CancelledError"),
+            ErrorType::CaptchaRequiredError => write!(f, "CaptchaRequiredError"),
+            ErrorType::QrDisabledError => write!(f, "QrDisabledError"),
+            ErrorType::CertPinMismatchError => write!(f, "CertPinMismatchError"),
+            ErrorType::RateLimitedError => write!(f, "RateLimitedError"),
             ErrorType::NoneError => write!(f, "NoneError"),
             ErrorType::OtherError => write!(f, "OtherError"),
         }
@@ -33,10 +49,14 @@ pub struct SDKError {
     r#type: ErrorType,
     message: String,
     cause: Option<Box<dyn Display>>,
+    // Details of the error for the caller, returned as the value of the `FduResult`.
+    value: Option<String>,
 }
 
 impl SDKError {
     pub fn is_none_error(&self) -> bool { matches!(self.r#type, ErrorType::NoneError) }
+    pub fn error_type(&self) -> &ErrorType { &self.r#type }
+    pub fn value(&self) -> Option<&str> { self.value.as_deref() }
     pub fn none() -> Self { SDKError::with_type(ErrorType::NoneError, Default::default()) }
     pub fn new(message: String) -> Self {
         SDKError::with_type(ErrorType::NoneError, message)
@@ -46,6 +66,7 @@ impl SDKError {
             r#type,
             message,
             cause: None,
+            value: None,
         }
     }
     pub fn with_cause(r#type: ErrorType, message: String, cause: Box<dyn Display>) -> Self {
@@ -53,8 +74,18 @@ impl SDKError {
             r#type,
             message,
             cause: Some(cause),
+            value: None,
         }
     }
+    pub fn with_value(mut self, value: String) -> Self {
+        self.value = Some(value);
+        self
+    }
+    // Prefix the message with what was going on, e.g. "failed after 3 attempts", keeping the type and the cause.
+    pub fn context(mut self, prefix: &str) -> Self {
+        self.message = format!("{}: {}", prefix, self.message);
+        self
+    }
 }
 
 impl Display for SDKError {
@@ -74,7 +105,10 @@ impl std::error::Error for SDKError {}
 
 impl From<reqwest::Error> for SDKError {
     fn from(e: reqwest::Error) -> Self {
-        SDKError::with_cause(ErrorType::OtherError, "reqwest reported an error".to_string(), Box::new(e))
+        if let Some(host) = crate::fdu::tls::pin_mismatch(&e) {
+            return SDKError::with_type(ErrorType::CertPinMismatchError, format!("certificate pin mismatch for {}", host));
+        }
+        SDKError::with_cause(ErrorType::NetworkError, "reqwest reported an error".to_string(), Box::new(e))
     }
 }
 
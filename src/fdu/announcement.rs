@@ -0,0 +1,215 @@
+use std::collections::HashSet;
+
+use chrono::NaiveDate;
+use regex::Regex;
+use reqwest::Url;
+use scraper::{ElementRef, Html, Selector};
+use serde::Serialize;
+
+use super::prelude::*;
+
+// The announcement lists are public pages of the WebPlus sites of the offices: page 1 is list.htm, page n listn.htm.
+const JWC_LIST_URL: &str = "https://jwc.fudan.edu.cn/9397/list";
+const GS_LIST_URL: &str = "https://gs.fudan.edu.cn/tzgg/list";
+// How many pages are read at most to catch up with `since_id`.
+const MAX_PAGES: u32 = 5;
+
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub enum Source {
+    // 教务处
+    Jwc,
+    // 研究生院
+    Gs,
+}
+
+const SOURCES: &[(&str, Source)] = &[("jwc", Source::Jwc), ("gs", Source::Gs)];
+
+impl Source {
+    pub fn parse(name: &str) -> Result<Source> {
+        match SOURCES.iter().find(|(source_name, _)| *source_name == name) {
+            Some((_, source)) => Ok(*source),
+            None => {
+                let names: Vec<&str> = SOURCES.iter().map(|(source_name, _)| *source_name).collect();
+                Err(SDKError::with_type(ErrorType::ArgumentError,
+                                        format!("unknown announcement source {}, valid sources are {}", name, names.join(", "))))
+            }
+        }
+    }
+
+    fn list_url(self, page: u32) -> String {
+        let base = match self {
+            Source::Jwc => JWC_LIST_URL,
+            Source::Gs => GS_LIST_URL,
+        };
+        if page == 1 { format!("{}.htm", base) } else { format!("{}{}.htm", base, page) }
+    }
+
+    // The office publishing the list, for announcements which do not name one.
+    fn department(self) -> &'static str {
+        match self {
+            Source::Jwc => "教务处",
+            Source::Gs => "研究生院",
+        }
+    }
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Announcement {
+    // The number of the article in the site, which grows with each new one and stays when it is edited.
+    id: u64,
+    title: String,
+    url: String,
+    // e.g. 2024-03-01, which changes when the announcement is edited.
+    date: String,
+    // e.g. 教务处, or the office of the graduate school publishing it, e.g. 培养办
+    department: String,
+}
+
+fn element_text(element: ElementRef) -> String {
+    element.text().collect::<String>().trim().to_string()
+}
+
+// Parse a list page. Links out of the site, e.g. to WeChat articles, have no article number and are skipped.
+fn parse_announcements(html: &str, source: Source, page_url: &str) -> Result<Vec<Announcement>> {
+    let base = Url::parse(page_url).map_err(|e| SDKError::with_type(ErrorType::ParseError, e.to_string()))?;
+    let document = Html::parse_document(html);
+    let list_selector = Selector::parse("ul.news_list").unwrap();
+    let item_selector = Selector::parse("li.news").unwrap();
+    let link_selector = Selector::parse(".news_title a").unwrap();
+    let date_selector = Selector::parse(".news_meta").unwrap();
+    let department_selector = Selector::parse(".news_dept").unwrap();
+    let id_regex = Regex::new(r"/c\d+a(\d+)/page\.htm$").unwrap();
+
+    let list = document.select(&list_selector).next()
+        .ok_or(SDKError::with_type(ErrorType::ParseError, "announcement list not found".to_string()))?;
+    let mut announcements = Vec::new();
+    for item in list.select(&item_selector) {
+        let link = match item.select(&link_selector).next() {
+            Some(link) => link,
+            None => continue,
+        };
+        let href = link.value().attr("href").unwrap_or_default();
+        let url = match base.join(href) {
+            Ok(url) if url.host_str() == base.host_str() => url,
+            _ => continue,
+        };
+        let id = match id_regex.captures(url.path()).and_then(|captures| captures[1].parse::<u64>().ok()) {
+            Some(id) => id,
+            None => {
+                log::debug!("skipping announcement {} without an article number", url);
+                continue;
+            }
+        };
+        // The text of the link may be shortened, the title attribute is not.
+        let title = link.value().attr("title").map(|title| title.trim().to_string()).unwrap_or_else(|| element_text(link));
+        let date = item.select(&date_selector).next().map(element_text).unwrap_or_default();
+        if NaiveDate::parse_from_str(&date, "%Y-%m-%d").is_err() {
+            return Err(SDKError::with_type(ErrorType::ParseError, format!("announcement {}: invalid date {}", id, date)));
+        }
+        let department = item.select(&department_selector).next().map(element_text)
+            .filter(|department| !department.is_empty())
+            .unwrap_or_else(|| source.department().to_string());
+        announcements.push(Announcement { id, title, url: url.to_string(), date, department });
+    }
+    Ok(announcements)
+}
+
+// Keep the announcements newer than `since_id`, the first listing of each only, the newest first.
+//
+// An edited announcement may be listed again with a later date, so they are told apart by their number only.
+fn newer_than(announcements: Vec<Announcement>, since_id: u64) -> Vec<Announcement> {
+    let mut seen = HashSet::new();
+    let mut newer: Vec<Announcement> = announcements.into_iter()
+        .filter(|announcement| announcement.id > since_id && seen.insert(announcement.id))
+        .collect();
+    newer.sort_by(|a, b| b.id.cmp(&a.id));
+    newer
+}
+
+// Return the announcements of `source` newer than `since_id`, the newest first, or those of the first page if
+// `since_id` is 0. The lists are public, so no session is needed.
+pub fn get_announcements(source: Source, since_id: u64) -> Result<Vec<Announcement>> {
+    let client = Fdu::client_builder().build()?;
+    let mut announcements = Vec::new();
+    for page in 1..=MAX_PAGES {
+        let url = base_url::resolve(&source.list_url(page));
+        let items = parse_announcements(&client.get(&url).send()?.text()?, source, &url)?;
+        // Stop once the page reaches back to `since_id`.
+        let done = since_id == 0 || items.iter().map(|announcement| announcement.id).min().map_or(true, |min| min <= since_id);
+        announcements.extend(items);
+        if done {
+            break;
+        }
+    }
+    Ok(newer_than(announcements, since_id))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_source() {
+        assert_eq!(Source::parse("jwc").unwrap(), Source::Jwc);
+        assert_eq!(Source::parse("gs").unwrap(), Source::Gs);
+        let e = Source::parse("xxx").unwrap_err();
+        assert!(matches!(e.error_type(), ErrorType::ArgumentError));
+        assert!(e.to_string().contains("jwc, gs"));
+        assert_eq!(Source::Jwc.list_url(1), "https://jwc.fudan.edu.cn/9397/list.htm");
+        assert_eq!(Source::Gs.list_url(3), "https://gs.fudan.edu.cn/tzgg/list3.htm");
+    }
+
+    #[test]
+    fn test_parse_announcements_jwc() {
+        let html = include_str!("testdata/announcements_jwc.html");
+        let announcements = parse_announcements(html, Source::Jwc, &Source::Jwc.list_url(1)).unwrap();
+        assert_eq!(announcements, vec![
+            Announcement {
+                id: 138760,
+                title: "关于2024年春季学期第二轮选课的通知".to_string(),
+                url: "https://jwc.fudan.edu.cn/2024/0305/c9397a138760/page.htm".to_string(),
+                date: "2024-03-05".to_string(),
+                department: "教务处".to_string(),
+            },
+            Announcement {
+                id: 138702,
+                title: "2024年春季学期补考安排".to_string(),
+                url: "https://jwc.fudan.edu.cn/2024/0301/c9397a138702/page.htm".to_string(),
+                date: "2024-03-01".to_string(),
+                department: "教务处".to_string(),
+            },
+            // The WeChat article is skipped, and the title falls back to the text of the link.
+            Announcement {
+                id: 138655,
+                title: "关于开展2023-2024学年第二学期教学检查的通知".to_string(),
+                url: "https://jwc.fudan.edu.cn/2024/0226/c9397a138655/page.htm".to_string(),
+                date: "2024-02-26".to_string(),
+                department: "教务处".to_string(),
+            },
+        ]);
+    }
+
+    #[test]
+    fn test_parse_announcements_gs() {
+        let html = include_str!("testdata/announcements_gs.html");
+        let announcements = parse_announcements(html, Source::Gs, &Source::Gs.list_url(1)).unwrap();
+        assert_eq!(announcements.len(), 4);
+        assert_eq!(announcements[0].department, "培养办");
+        assert_eq!(announcements[3].department, "研究生院");
+
+        // The edited announcement is listed twice, and kept once with its latest date.
+        let newer = newer_than(announcements, 482101);
+        let ids: Vec<u64> = newer.iter().map(|announcement| announcement.id).collect();
+        assert_eq!(ids, vec![482210, 482180]);
+        assert_eq!(newer[1].date, "2024-03-02");
+        assert_eq!(newer[1].title, "关于研究生课程补退选的通知（更新）");
+    }
+
+    #[test]
+    fn test_parse_announcements_invalid() {
+        assert!(parse_announcements("<html><body>维护中</body></html>", Source::Jwc, JWC_LIST_URL).is_err());
+        let html = r#"<ul class="news_list"><li class="news"><span class="news_title"><a href="/2024/0301/c9397a1/page.htm">x</a></span>
+            <span class="news_meta">03-01</span></li></ul>"#;
+        assert!(parse_announcements(html, Source::Jwc, JWC_LIST_URL).is_err());
+    }
+}
@@ -0,0 +1,330 @@
+use std::collections::HashMap;
+
+use regex::Regex;
+use scraper::{ElementRef, Html, Selector};
+use serde::Serialize;
+
+use super::prelude::*;
+
+// The plan audit (培养计划完成情况) of jwfw nests a table per category of the training plan in a row of the table of
+// its parent. Each table starts with a row of the name and credits of the category, followed by the courses counted
+// under it, then the rows of its children.
+
+#[derive(Clone, Copy, Debug, Serialize, PartialEq)]
+#[serde(rename_all = "snake_case")]
+pub enum CourseStatus {
+    Passed,
+    InProgress,
+    Failed,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct AuditCourse {
+    // e.g. COMP130004, the course and not a class of it.
+    course_id: String,
+    name: String,
+    credit: f64,
+    // e.g. 2022-2023 1, empty for credits transferred from outside the university.
+    semester: String,
+    // e.g. A-, P, empty while in progress.
+    grade: String,
+    status: CourseStatus,
+    // Whether the credits were recognized from another major or university (学分认定, 学分转换) rather than earned in
+    // the plan.
+    transferred: bool,
+    // The ids of all the categories counting the course, in the order of the page, more than one for the courses
+    // counted twice by the rules of the plan.
+    counted_in: Vec<String>,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct AuditCategory {
+    // The names of the categories from the root, without their numbering like 一、 or （二）, joined by /, e.g.
+    // 通识教育课程/核心课程, so that the id stays the same from one audit to the next. The root has the empty id.
+    id: String,
+    // e.g. （二）核心课程, as written in the page.
+    name: String,
+    required_credits: f64,
+    earned_credits: f64,
+    in_progress_credits: f64,
+    courses: Vec<AuditCourse>,
+    children: Vec<AuditCategory>,
+}
+
+// The table holding `element`, if any.
+fn parent_table(element: ElementRef) -> Option<ElementRef> {
+    element.ancestors().filter_map(ElementRef::wrap).find(|ancestor| ancestor.value().name() == "table")
+}
+
+// The rows of `table`, without those of the tables nested in it. The parser puts them in an implicit tbody.
+fn rows(table: ElementRef) -> Vec<ElementRef> {
+    let mut rows = Vec::new();
+    for child in table.children().filter_map(ElementRef::wrap) {
+        match child.value().name() {
+            "tr" => rows.push(child),
+            "thead" | "tbody" | "tfoot" => {
+                rows.extend(child.children().filter_map(ElementRef::wrap).filter(|row| row.value().name() == "tr"))
+            }
+            _ => {}
+        }
+    }
+    rows
+}
+
+fn cells(row: ElementRef) -> Vec<String> {
+    row.children().filter_map(ElementRef::wrap).filter(|cell| matches!(cell.value().name(), "td" | "th"))
+        .map(|cell| cell.text().collect::<String>().trim().to_string()).collect()
+}
+
+fn has_class(row: ElementRef, class: &str) -> bool {
+    row.value().classes().any(|c| c == class)
+}
+
+fn parse_credit(text: &str, what: &str) -> Result<f64> {
+    text.parse::<f64>().ok().filter(|credit| credit.is_finite() && *credit >= 0.0)
+        .ok_or(SDKError::with_type(ErrorType::ParseError, format!("invalid credits {} of {}", text, what)))
+}
+
+// The course rows have columns: 课程代码, 课程名称, 学分, 学年学期, 成绩, 是否通过, 备注.
+fn parse_audit_course(row: &[String]) -> Result<AuditCourse> {
+    if row.len() < 6 {
+        return Err(SDKError::with_type(ErrorType::ParseError, format!("invalid course row {:?}", row)));
+    }
+    let course_id = row[0].clone();
+    let credit = parse_credit(&row[2], &course_id)?;
+    let (grade, status) = match (row[4].as_str(), row[5].as_str()) {
+        (grade, "是") => (grade.to_string(), CourseStatus::Passed),
+        (grade, "否") => (grade.to_string(), CourseStatus::Failed),
+        ("" | "在修" | "在读", _) => (String::new(), CourseStatus::InProgress),
+        (_, status) => {
+            return Err(SDKError::with_type(ErrorType::ParseError, format!("unknown status {} of {}", status, course_id)));
+        }
+    };
+    let remark = row.get(6).map(String::as_str).unwrap_or_default();
+    Ok(AuditCourse {
+        course_id,
+        name: row[1].clone(),
+        credit,
+        semester: row[3].clone(),
+        grade,
+        status,
+        transferred: ["认定", "转换", "转入"].iter().any(|word| remark.contains(word)),
+        counted_in: Vec::new(),
+    })
+}
+
+struct AuditParser {
+    numbering: Regex,
+    credits: Regex,
+    nested: Selector,
+}
+
+impl AuditParser {
+    fn new() -> Self {
+        AuditParser {
+            numbering: Regex::new(r"^(?:[（(][一二三四五六七八九十\d]+[)）]|[一二三四五六七八九十\d]+[、.．])\s*").unwrap(),
+            credits: Regex::new(r"^(要求学分|已获学分|完成学分|在修学分)[:：]\s*(\S+)$").unwrap(),
+            nested: Selector::parse("table.planAuditTable").unwrap(),
+        }
+    }
+
+    // Parse the category of `table`, a child of the category `parent_id`, or the root if None. `siblings` counts the
+    // ids of the categories of the same parent, since two of them may have the same name.
+    fn parse_category(&self, table: ElementRef, parent_id: Option<&str>, siblings: &mut HashMap<String, usize>)
+                      -> Result<AuditCategory> {
+        let rows = rows(table);
+        let head = rows.first().filter(|row| has_class(**row, "groupRow"))
+            .ok_or(SDKError::with_type(ErrorType::ParseError, "category without a name".to_string()))?;
+        let head = cells(*head);
+        let name = head.first().cloned().unwrap_or_default();
+        let (mut required, mut earned, mut in_progress) = (None, None, 0.0);
+        for cell in &head {
+            if let Some(captures) = self.credits.captures(cell) {
+                let credits = parse_credit(&captures[2], &name)?;
+                match &captures[1] {
+                    "要求学分" => required = Some(credits),
+                    "在修学分" => in_progress = credits,
+                    _ => earned = Some(credits),
+                }
+            }
+        }
+        let missing = |what: &str| SDKError::with_type(ErrorType::ParseError, format!("no {} in category {}", what, name));
+
+        let id = match parent_id {
+            None => String::new(),
+            Some(parent_id) => {
+                let mut id = self.numbering.replace(&name, "").trim().to_string();
+                if !parent_id.is_empty() {
+                    id = format!("{}/{}", parent_id, id);
+                }
+                // Two categories of the same name are numbered from the second one.
+                let count = siblings.entry(id.clone()).or_default();
+                *count += 1;
+                if *count > 1 { format!("{}#{}", id, count) } else { id }
+            }
+        };
+        let mut category = AuditCategory {
+            id,
+            required_credits: required.ok_or_else(|| missing("required credits"))?,
+            earned_credits: earned.ok_or_else(|| missing("earned credits"))?,
+            in_progress_credits: in_progress,
+            name,
+            courses: Vec::new(),
+            children: Vec::new(),
+        };
+
+        let mut children = HashMap::new();
+        for row in &rows[1..] {
+            if has_class(*row, "courseRow") {
+                category.courses.push(parse_audit_course(&cells(*row))?);
+                continue;
+            }
+            for child in row.select(&self.nested).filter(|child| parent_table(*child) == Some(table)) {
+                let child = self.parse_category(child, Some(&category.id), &mut children)?;
+                category.children.push(child);
+            }
+        }
+        Ok(category)
+    }
+}
+
+// Collect the ids of the categories counting each course, by course id.
+fn collect_counted_in(category: &AuditCategory, counted_in: &mut HashMap<String, Vec<String>>) {
+    for course in &category.courses {
+        let ids = counted_in.entry(course.course_id.clone()).or_default();
+        if !ids.contains(&category.id) {
+            ids.push(category.id.clone());
+        }
+    }
+    for child in &category.children {
+        collect_counted_in(child, counted_in);
+    }
+}
+
+fn set_counted_in(category: &mut AuditCategory, counted_in: &HashMap<String, Vec<String>>) {
+    for course in &mut category.courses {
+        course.counted_in = counted_in[&course.course_id].clone();
+    }
+    for child in &mut category.children {
+        set_counted_in(child, counted_in);
+    }
+}
+
+// Parse the plan audit into the tree of the categories of the plan, the root being the whole plan.
+pub(crate) fn parse_plan_audit(html: &str) -> Result<AuditCategory> {
+    let document = Html::parse_document(html);
+    let selector = Selector::parse("table.planAuditTable").unwrap();
+    let root = document.select(&selector).find(|table| parent_table(*table).is_none())
+        .ok_or(SDKError::with_type(ErrorType::ParseError, "plan audit not found".to_string()))?;
+    let mut plan = AuditParser::new().parse_category(root, None, &mut HashMap::new())?;
+
+    let mut counted_in = HashMap::new();
+    collect_counted_in(&plan, &mut counted_in);
+    set_counted_in(&mut plan, &counted_in);
+    Ok(plan)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn category<'a>(plan: &'a AuditCategory, id: &str) -> &'a AuditCategory {
+        fn find<'a>(category: &'a AuditCategory, id: &str) -> Option<&'a AuditCategory> {
+            if category.id == id {
+                return Some(category);
+            }
+            category.children.iter().find_map(|child| find(child, id))
+        }
+        find(plan, id).unwrap_or_else(|| panic!("no category {}", id))
+    }
+
+    #[test]
+    fn test_parse_plan_audit_freshman() {
+        let plan = parse_plan_audit(include_str!("testdata/jwfw_audit_freshman.html")).unwrap();
+        assert_eq!(plan.id, "");
+        assert_eq!(plan.name, "计算机科学与技术（2024级）");
+        assert_eq!((plan.required_credits, plan.earned_credits, plan.in_progress_credits), (40.0, 0.0, 10.0));
+        let ids: Vec<&str> = plan.children.iter().map(|child| child.id.as_str()).collect();
+        assert_eq!(ids, vec!["通识教育课程", "专业教育课程", "任意选修课", "创新创业"]);
+        let politics = category(&plan, "通识教育课程/思想政治理论课");
+        assert_eq!(politics.name, "（一）思想政治理论课");
+        assert_eq!(politics.courses, vec![AuditCourse {
+            course_id: "MARX110001".to_string(),
+            name: "思想道德与法治".to_string(),
+            credit: 3.0,
+            semester: "2024-2025 1".to_string(),
+            grade: String::new(),
+            status: CourseStatus::InProgress,
+            transferred: false,
+            counted_in: vec!["通识教育课程/思想政治理论课".to_string()],
+        }]);
+        assert!(category(&plan, "通识教育课程/核心课程").courses.is_empty());
+    }
+
+    #[test]
+    fn test_parse_plan_audit_senior() {
+        let plan = parse_plan_audit(include_str!("testdata/jwfw_audit_senior.html")).unwrap();
+        assert_eq!((plan.required_credits, plan.earned_credits, plan.in_progress_credits), (40.0, 34.0, 4.0));
+        let required = category(&plan, "专业教育课程/专业必修课");
+        let statuses: Vec<CourseStatus> = required.courses.iter().map(|course| course.status).collect();
+        assert_eq!(statuses, vec![CourseStatus::Passed, CourseStatus::Failed, CourseStatus::Passed, CourseStatus::Passed,
+                                  CourseStatus::InProgress]);
+        // A retaken course is counted once in its category.
+        assert_eq!(required.courses[1].counted_in, vec!["专业教育课程/专业必修课"]);
+
+        let twice = vec!["专业教育课程/专业选修课".to_string(), "创新创业".to_string()];
+        assert_eq!(category(&plan, "专业教育课程/专业选修课").courses[0].counted_in, twice);
+        assert_eq!(category(&plan, "创新创业").courses[0].counted_in, twice);
+    }
+
+    #[test]
+    fn test_parse_plan_audit_transfer() {
+        let plan = parse_plan_audit(include_str!("testdata/jwfw_audit_transfer.html")).unwrap();
+        let transferred: Vec<(&str, &str)> = [
+            "通识教育课程/大学英语", "专业教育课程/专业必修课", "专业教育课程/专业选修课", "任意选修课",
+        ].iter().flat_map(|id| &category(&plan, id).courses).filter(|course| course.transferred)
+            .map(|course| (course.course_id.as_str(), course.semester.as_str())).collect();
+        assert_eq!(transferred, vec![
+            ("ENGL110003", ""),
+            ("MATH120021", "2022-2023 1"),
+            ("MATH130005", "2022-2023 2"),
+            ("MATH120022", "2022-2023 2"),
+        ]);
+        // The page does not add the transferred credits to the earned credits of 专业教育课程, which is left to the
+        // callers to notice.
+        assert_eq!(category(&plan, "专业教育课程").earned_credits, 11.0);
+    }
+
+    #[test]
+    fn test_parse_plan_audit_invalid() {
+        assert!(matches!(parse_plan_audit("<html><body>请登录</body></html>").unwrap_err().error_type(),
+                         ErrorType::ParseError));
+        let table = |rows: &str| format!(r#"<table class="planAuditTable">{}</table>"#, rows);
+        let group = r#"<tr class="groupRow"><td class="groupName">全部</td><td>要求学分：40</td><td>已获学分：2</td></tr>"#;
+        for html in [
+            table(""),
+            table(r#"<tr class="groupRow"><td class="groupName">全部</td><td>要求学分：40</td></tr>"#),
+            table(r#"<tr class="groupRow"><td class="groupName">全部</td><td>要求学分：-1</td><td>已获学分：0</td></tr>"#),
+            table(&format!(r#"{}<tr class="courseRow"><td>A</td><td>a</td><td>二</td><td></td><td>A</td><td>是</td></tr>"#, group)),
+            table(&format!(r#"{}<tr class="courseRow"><td>A</td><td>a</td><td>2</td><td></td><td>A</td><td>缓考</td></tr>"#, group)),
+            table(&format!(r#"{}<tr class="courseRow"><td>A</td></tr>"#, group)),
+            table(&format!("{}<tr><td>{}</td></tr>", group, table("<tr><td>无</td></tr>"))),
+        ] {
+            assert!(parse_plan_audit(&html).is_err(), "{}", html);
+        }
+    }
+
+    #[test]
+    fn test_parse_plan_audit_ids() {
+        let table = |name: &str, rows: &str| format!(
+            r#"<table class="planAuditTable"><tr class="groupRow"><td class="groupName">{}</td><td>要求学分：4</td><td>完成学分：2.5</td></tr>{}</table>"#,
+            name, rows);
+        let child = |name: &str| format!("<tr><td>{}</td></tr>", table(name, ""));
+        let html = table("全部", &format!("{}{}{}", child("1. 选修课"), child("(2)选修课"), child("三、必修课")));
+        let plan = parse_plan_audit(&html).unwrap();
+        assert_eq!(plan.earned_credits, 2.5);
+        assert_eq!(plan.in_progress_credits, 0.0);
+        let ids: Vec<&str> = plan.children.iter().map(|child| child.id.as_str()).collect();
+        assert_eq!(ids, vec!["选修课", "选修课#2", "必修课"]);
+    }
+}
@@ -0,0 +1,124 @@
+// The servers of the university can be replaced by local ones, so that the test suites of callers run offline against
+// fake servers, see `fdu_set_base_urls()`.
+//
+// Every request goes through `resolve()`, by `HttpClient::get()` and `HttpClient::post()`. It is read on each request,
+// unlike the HTTP settings, so that it applies to the sessions already created too.
+use std::collections::HashMap;
+use std::sync::RwLock;
+
+use reqwest::Url;
+
+use super::prelude::*;
+
+// Pairs of a host, e.g. uis.fudan.edu.cn, and the base URL replacing `https://<host>`, without a trailing slash,
+// e.g. http://127.0.0.1:8080. Empty unless set by a test.
+static BASE_URLS: RwLock<Vec<(String, String)>> = RwLock::new(Vec::new());
+
+// Replace the base URLs, after checking them. An empty map restores the real servers.
+pub fn set(urls: HashMap<String, String>) -> Result<()> {
+    let bases = parse(urls)?;
+    *BASE_URLS.write().unwrap_or_else(|e| e.into_inner()) = bases;
+    Ok(())
+}
+
+// Return `url`, on the server replacing its host if any.
+pub fn resolve(url: &str) -> String {
+    rewrite(&BASE_URLS.read().unwrap_or_else(|e| e.into_inner()), url)
+}
+
+// Return `url` on its real server if it is on one replacing it, the inverse of `resolve()`, e.g. for the recordings of
+// the exchanges, see `record`.
+pub fn original(url: &str) -> String {
+    unresolve(&BASE_URLS.read().unwrap_or_else(|e| e.into_inner()), url)
+}
+
+// Return the host of `url`, which is the one replaced by the server of `url` if any, so that a request to the server
+// replacing jwfw counts as one to jwfw, e.g. for the rate limits.
+pub fn host_of(url: &Url) -> String {
+    real_host(&BASE_URLS.read().unwrap_or_else(|e| e.into_inner()), url.as_str())
+        .unwrap_or_else(|| url.host_str().unwrap_or_default().to_string())
+}
+
+fn parse(urls: HashMap<String, String>) -> Result<Vec<(String, String)>> {
+    let mut bases = Vec::new();
+    for (host, base) in urls {
+        if host.is_empty() || host.contains(['/', ':']) {
+            return Err(SDKError::with_type(ErrorType::ArgumentError, format!("invalid host {:?}", host)));
+        }
+        match Url::parse(&base) {
+            Ok(url) if matches!(url.scheme(), "http" | "https") && url.query().is_none() && url.fragment().is_none() => {}
+            _ => return Err(SDKError::with_type(ErrorType::ArgumentError, format!("{}: invalid base URL {:?}", host, base))),
+        }
+        bases.push((host, base.trim_end_matches('/').to_string()));
+    }
+    Ok(bases)
+}
+
+// URLs of other hosts, or which are not absolute, are returned as is.
+fn rewrite(bases: &[(String, String)], url: &str) -> String {
+    if let Some(rest) = url.strip_prefix("https://").or_else(|| url.strip_prefix("http://")) {
+        let (host, path) = rest.split_at(rest.find(['/', '?', '#']).unwrap_or(rest.len()));
+        if let Some((_, base)) = bases.iter().find(|(base_host, _)| base_host == host) {
+            return format!("{}{}", base, path);
+        }
+    }
+    url.to_string()
+}
+
+fn unresolve(bases: &[(String, String)], url: &str) -> String {
+    match real_host(bases, url) {
+        Some(host) => {
+            let base = &bases.iter().find(|(base_host, _)| *base_host == host).unwrap().1;
+            format!("https://{}{}", host, &url[base.len()..])
+        }
+        None => url.to_string(),
+    }
+}
+
+// The host whose base URL is the longest prefix of `url`, ending at a path segment.
+fn real_host(bases: &[(String, String)], url: &str) -> Option<String> {
+    bases.iter()
+        .filter(|(_, base)| url.strip_prefix(base.as_str()).is_some_and(|rest| rest.is_empty() || rest.starts_with(['/', '?', '#'])))
+        .max_by_key(|(_, base)| base.len())
+        .map(|(host, _)| host.clone())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    // The tests do not call `set()`: the base URLs would apply to the other tests running meanwhile.
+    #[test]
+    fn test_rewrite() {
+        const LOGIN_URL: &str = "https://uis.fudan.edu.cn/authserver/login?service=x";
+        assert_eq!(rewrite(&[], LOGIN_URL), LOGIN_URL);
+
+        let bases = parse(HashMap::from([
+            ("uis.fudan.edu.cn".to_string(), "http://127.0.0.1:8080/".to_string()),
+            ("jwfw.fudan.edu.cn".to_string(), "http://127.0.0.1:8080/jwfw".to_string()),
+        ])).unwrap();
+        assert_eq!(rewrite(&bases, LOGIN_URL), "http://127.0.0.1:8080/authserver/login?service=x");
+        assert_eq!(rewrite(&bases, "https://jwfw.fudan.edu.cn/eams/home.action"), "http://127.0.0.1:8080/jwfw/eams/home.action");
+        assert_eq!(rewrite(&bases, "https://jwfw.fudan.edu.cn"), "http://127.0.0.1:8080/jwfw");
+        // Only the host itself is replaced, not its subdomains.
+        assert_eq!(rewrite(&bases, "https://my.jwfw.fudan.edu.cn/"), "https://my.jwfw.fudan.edu.cn/");
+        assert_eq!(rewrite(&bases, "/eams/home.action"), "/eams/home.action");
+
+        assert_eq!(real_host(&bases, "http://127.0.0.1:8080/jwfw/eams/home.action").as_deref(), Some("jwfw.fudan.edu.cn"));
+        assert_eq!(real_host(&bases, "http://127.0.0.1:8080/jwfwx").as_deref(), Some("uis.fudan.edu.cn"));
+        assert_eq!(real_host(&bases, "http://127.0.0.1:8080").as_deref(), Some("uis.fudan.edu.cn"));
+        assert_eq!(real_host(&bases, "http://127.0.0.1:8081/"), None);
+
+        assert_eq!(unresolve(&bases, "http://127.0.0.1:8080/jwfw/eams/home.action?x=1"), "https://jwfw.fudan.edu.cn/eams/home.action?x=1");
+        assert_eq!(unresolve(&bases, &rewrite(&bases, LOGIN_URL)), LOGIN_URL);
+        assert_eq!(unresolve(&bases, "http://127.0.0.1:8081/"), "http://127.0.0.1:8081/");
+    }
+
+    #[test]
+    fn test_parse_invalid() {
+        assert!(parse(HashMap::from([("uis.fudan.edu.cn".to_string(), "ftp://127.0.0.1".to_string())])).is_err());
+        assert!(parse(HashMap::from([("uis.fudan.edu.cn".to_string(), "127.0.0.1:8080".to_string())])).is_err());
+        assert!(parse(HashMap::from([("uis.fudan.edu.cn:443".to_string(), "http://127.0.0.1".to_string())])).is_err());
+        assert!(parse(HashMap::from([("".to_string(), "http://127.0.0.1".to_string())])).is_err());
+    }
+}
@@ -0,0 +1,171 @@
+// The HTTP settings of the library, set by the caller with `fdu_set_http_config()`.
+//
+// A client reads them when it is built, i.e. when its session is created: changing them only affects the sessions
+// created afterwards, so that a request never sees them change while it is in flight.
+use std::collections::BTreeMap;
+use std::sync::RwLock;
+use std::time::Duration;
+
+use reqwest::blocking::ClientBuilder;
+use reqwest::{Certificate, Proxy};
+use serde::Deserialize;
+
+use super::prelude::*;
+use super::ratelimit::{self, Rate};
+use super::raw;
+use super::tls;
+
+// Zero values keep the defaults of reqwest, e.g. a request timeout of 30 seconds and the proxy from the environment
+// (HTTP_PROXY...).
+#[derive(Clone, Debug, Default, Deserialize, PartialEq)]
+#[serde(default, deny_unknown_fields)]
+pub struct HttpConfig {
+    pub connect_timeout_millis: u64,
+    pub request_timeout_millis: u64,
+    pub proxy_url: String,
+    pub user_agent: String,
+    // How many times a request failing to connect (or timing out, for GET requests) is sent again.
+    pub max_retries: u32,
+    // PEM certificates trusted as roots besides the usual ones, e.g. the one of a proxy re-signing TLS.
+    pub ca_cert_pem: String,
+    // Accept any certificate, which lets anyone on the network read the password: for debugging only.
+    pub insecure_skip_verify: bool,
+    // Pins of the public keys of the servers, see `tls::parse_pin()`: when set, a server must have a pinned key in
+    // its chain, or the request fails with a `CertPinMismatchError`.
+    pub pinned_spki_hashes: Vec<String>,
+    // The rate limits of the requests of a session to each host, e.g. jwfw.fudan.edu.cn, over the defaults of
+    // `ratelimit::DEFAULTS`. A zero rate lifts the limit of a host.
+    pub rate_limits: BTreeMap<String, Rate>,
+    // The hosts which the raw requests of `fdu_session_do()` may be sent to, besides those of the university, see
+    // `raw`. A leading `*.` matches the subdomains of a domain.
+    pub raw_allowed_hosts: Vec<String>,
+    // The limit of the body of a response to a raw request, `raw::DEFAULT_MAX_BODY_BYTES` if 0.
+    pub raw_max_body_bytes: u64,
+}
+
+static CONFIG: RwLock<HttpConfig> = RwLock::new(HttpConfig {
+    connect_timeout_millis: 0,
+    request_timeout_millis: 0,
+    proxy_url: String::new(),
+    user_agent: String::new(),
+    max_retries: 0,
+    ca_cert_pem: String::new(),
+    insecure_skip_verify: false,
+    pinned_spki_hashes: Vec::new(),
+    rate_limits: BTreeMap::new(),
+    raw_allowed_hosts: Vec::new(),
+    raw_max_body_bytes: 0,
+});
+
+pub fn current() -> HttpConfig {
+    CONFIG.read().unwrap_or_else(|e| e.into_inner()).clone()
+}
+
+// Replace the settings, after checking them: a bad value is reported with the name of its field.
+pub fn set(config: HttpConfig) -> Result<()> {
+    if !config.proxy_url.is_empty() {
+        Proxy::all(&config.proxy_url).map_err(|e| {
+            SDKError::with_type(ErrorType::ArgumentError, format!("proxy_url: invalid proxy {:?}: {}", config.proxy_url, e))
+        })?;
+    }
+    if !config.ca_cert_pem.is_empty() {
+        tls::parse_certs(&config.ca_cert_pem).map_err(|e| e.context("ca_cert_pem"))?;
+    }
+    for pin in &config.pinned_spki_hashes {
+        tls::parse_pin(pin).map_err(|e| e.context("pinned_spki_hashes"))?;
+    }
+    ratelimit::check(&config.rate_limits).map_err(|e| e.context("rate_limits"))?;
+    raw::check_hosts(&config.raw_allowed_hosts).map_err(|e| e.context("raw_allowed_hosts"))?;
+    if config.insecure_skip_verify && !config.pinned_spki_hashes.is_empty() {
+        Err(SDKError::with_type(ErrorType::ArgumentError,
+                                "insecure_skip_verify: pinned_spki_hashes need the certificates verified".to_string()))?
+    }
+    config.apply(ClientBuilder::new()).build().map_err(|e| {
+        SDKError::with_type(ErrorType::ArgumentError, format!("invalid http config: {}", e))
+    })?;
+    if config.insecure_skip_verify {
+        log::warn!("insecure_skip_verify is set: the certificates of the servers are NOT verified, and anyone on the \
+                    network can read the password and the cookies of the sessions created from now on");
+    }
+    if !config.raw_allowed_hosts.is_empty() {
+        log::warn!("raw_allowed_hosts is set: the raw requests of the sessions created from now on may be sent, with \
+                    whatever headers and body the caller gives, to {}, which are not servers of the university",
+                   config.raw_allowed_hosts.join(", "));
+    }
+    *CONFIG.write().unwrap_or_else(|e| e.into_inner()) = config;
+    Ok(())
+}
+
+impl HttpConfig {
+    pub fn apply(&self, mut builder: ClientBuilder) -> ClientBuilder {
+        if self.connect_timeout_millis > 0 {
+            builder = builder.connect_timeout(Duration::from_millis(self.connect_timeout_millis));
+        }
+        if self.request_timeout_millis > 0 {
+            builder = builder.timeout(Duration::from_millis(self.request_timeout_millis));
+        }
+        // An invalid proxy is rejected by `set()`, an empty one means none.
+        if let Ok(proxy) = Proxy::all(&self.proxy_url) {
+            builder = builder.proxy(proxy);
+        }
+        if !self.user_agent.is_empty() {
+            builder = builder.user_agent(&self.user_agent);
+        }
+        // Invalid certificates and pins are rejected by `set()` too.
+        let roots = tls::parse_certs(&self.ca_cert_pem).unwrap_or_default();
+        let pins: Vec<_> = self.pinned_spki_hashes.iter().filter_map(|pin| tls::parse_pin(pin).ok()).collect();
+        if !pins.is_empty() {
+            // Only rustls lets a verifier check the pins, with the roots of the verifier.
+            if let Ok(tls) = tls::pinned_config(&roots, pins) {
+                builder = builder.use_preconfigured_tls(tls);
+            }
+        } else {
+            for der in &roots {
+                if let Ok(cert) = Certificate::from_der(der) {
+                    builder = builder.add_root_certificate(cert);
+                }
+            }
+        }
+        if self.insecure_skip_verify {
+            builder = builder.danger_accept_invalid_certs(true);
+        }
+        builder
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_invalid_proxy() {
+        let config = HttpConfig { proxy_url: "http://[::1".to_string(), ..Default::default() };
+        let err = set(config).unwrap_err();
+        assert!(matches!(err.error_type(), ErrorType::ArgumentError));
+        assert!(err.to_string().starts_with("proxy_url: "), "{}", err);
+        assert_eq!(current(), HttpConfig::default());
+    }
+
+    #[test]
+    fn test_invalid_tls() {
+        for (config, field) in [
+            (HttpConfig { ca_cert_pem: "not a certificate".to_string(), ..Default::default() }, "ca_cert_pem: "),
+            (HttpConfig { pinned_spki_hashes: vec!["sha256/AAAA".to_string()], ..Default::default() }, "pinned_spki_hashes: "),
+            (HttpConfig {
+                insecure_skip_verify: true,
+                pinned_spki_hashes: vec![include_str!("testdata/tls_cert.pin").trim().to_string()],
+                ..Default::default()
+            }, "insecure_skip_verify: "),
+            (HttpConfig {
+                rate_limits: BTreeMap::from([("jwfw.fudan.edu.cn".to_string(), Rate { per_second: -1.0, burst: 1 })]),
+                ..Default::default()
+            }, "rate_limits: "),
+            (HttpConfig { raw_allowed_hosts: vec!["https://example.com".to_string()], ..Default::default() }, "raw_allowed_hosts: "),
+        ] {
+            let err = set(config).unwrap_err();
+            assert!(matches!(err.error_type(), ErrorType::ArgumentError));
+            assert!(err.to_string().starts_with(field), "{}", err);
+        }
+        assert_eq!(current(), HttpConfig::default());
+    }
+}
@@ -1,18 +1,254 @@
+use std::collections::HashMap;
+
+use regex::Regex;
 use scraper::{Html, Selector};
+use serde::Serialize;
+
 use super::prelude::*;
 
 impl ECardClient for Fdu {}
 
 const ECARD_QR_CODE_URL: &str = "https://ecard.fudan.edu.cn/epay/wxpage/fudan/zfm/qrcode";
+const ECARD_HOME_URL: &str = "https://ecard.fudan.edu.cn/epay/myepay/index";
+const ECARD_CONSUME_URL: &str = "https://ecard.fudan.edu.cn/epay/consume/index";
+const ECARD_CONSUME_QUERY_URL: &str = "https://ecard.fudan.edu.cn/epay/consume/query";
+const ECARD_ELECTRICITY_URL: &str = "https://ecard.fudan.edu.cn/epay/electric/load4electricbill";
+const ECARD_ELECTRICITY_QUERY_URL: &str = "https://ecard.fudan.edu.cn/epay/electric/queryelectricbill";
+
+// Number of transactions per page of ECARD_CONSUME_QUERY_URL
+const TRANSACTIONS_PER_PAGE: usize = 10;
+
+// How long a payment code is accepted by the card readers. The page refreshes it every minute.
+const PAYMENT_CODE_VALID_MILLIS: u64 = 60_000;
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct PaymentCode {
+    // The content of the QR code, e.g. SWL2...
+    code: String,
+    // How long the code is valid from the time it is fetched.
+    valid_millis: u64,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Transaction {
+    // e.g. 2023-01-03 12:00:00
+    time: String,
+    // e.g. 北区食堂
+    location: String,
+    // In cents, negative for spending and positive for top-ups.
+    amount: i64,
+    // The balance after the transaction, in cents.
+    balance: i64,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct TransactionPage {
+    // Counted from 1
+    page: usize,
+    total_pages: usize,
+    transactions: Vec<Transaction>,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Electricity {
+    // The electricity left in the dormitory room, in kWh.
+    kwh: f64,
+    // The money left, in cents.
+    balance: i64,
+    // e.g. 2024-03-01 12:30:05, empty if the room was never topped up.
+    last_top_up: String,
+}
+
+// Parse an amount of money like "-12.50", "+100" or "1,234.5" into cents, without going through floats.
+fn parse_cents(text: &str) -> Result<i64> {
+    let error = || SDKError::with_type(ErrorType::ParseError, format!("invalid amount {}", text));
+    let text = text.trim().replace(',', "");
+    let (negative, digits) = match text.strip_prefix('-') {
+        Some(rest) => (true, rest),
+        None => (false, text.strip_prefix('+').unwrap_or(&text)),
+    };
+    let (yuan, fen) = digits.split_once('.').unwrap_or((digits, ""));
+    if yuan.is_empty() || fen.len() > 2 || !yuan.chars().chain(fen.chars()).all(|c| c.is_ascii_digit()) {
+        return Err(error());
+    }
+    let cents = yuan.parse::<i64>().map_err(|_| error())? * 100 + format!("{:0<2}", fen).parse::<i64>().map_err(|_| error())?;
+    Ok(if negative { -cents } else { cents })
+}
+
+fn parse_payment_code(html: &str) -> Result<PaymentCode> {
+    let document = Html::parse_document(html);
+    let selector = Selector::parse("#myText").unwrap();
+    let code = document.select(&selector).next().and_then(|element| element.value().attr("value")).unwrap_or("");
+    if !code.is_empty() {
+        return Ok(PaymentCode { code: code.to_string(), valid_millis: PAYMENT_CODE_VALID_MILLIS });
+    }
+    // Without QR payment, the page asks to enable it instead.
+    if html.contains("未开通") {
+        return Err(SDKError::with_type(ErrorType::QrDisabledError, "QR payment is disabled for this account".to_string()));
+    }
+    Err(SDKError::with_type(ErrorType::ParseError, "payment code not found".to_string()))
+}
+
+fn parse_balance(html: &str) -> Result<i64> {
+    let document = Html::parse_document(html);
+    let selector = Selector::parse(".payway-box-bottom-item > p").unwrap();
+    let element = document.select(&selector).next()
+        .ok_or(SDKError::with_type(ErrorType::ParseError, "balance not found".to_string()))?;
+    parse_cents(&element.text().collect::<String>())
+}
+
+// Map the campus names used by the SDK to the areas of the electricity query, which only has the dormitories of
+// Handan and Jiangwan.
+fn electricity_area(campus: &str) -> Result<&'static str> {
+    match campus {
+        "handan" => Ok("1"),
+        "jiangwan" => Ok("2"),
+        _ => Err(SDKError::with_type(ErrorType::ArgumentError, format!("no dormitory electricity for campus {}", campus))),
+    }
+}
+
+// The page lists fields like <li><span class="label">剩余电量：</span><span class="value">123.45 度</span></li>, or says
+// 房间不存在 for a room unknown to the card system.
+fn parse_electricity(html: &str) -> Result<Electricity> {
+    if html.contains("房间不存在") || html.contains("未找到该房间") {
+        return Err(SDKError::with_type(ErrorType::ArgumentError, "room not found".to_string()));
+    }
+    let document = Html::parse_document(html);
+    let item_selector = Selector::parse(".electric-info li").unwrap();
+    let span_selector = Selector::parse("span").unwrap();
+    let fields: Vec<(String, String)> = document.select(&item_selector).filter_map(|li| {
+        let spans: Vec<String> = li.select(&span_selector).map(|span| span.text().collect::<String>().trim().to_string()).collect();
+        match &spans[..] {
+            [label, value] => Some((label.trim_end_matches(['：', ':']).to_string(), value.clone())),
+            _ => None,
+        }
+    }).collect();
+    let field = |label: &str| fields.iter().find(|(name, _)| name == label).map(|(_, value)| value.as_str())
+        .ok_or(SDKError::with_type(ErrorType::ParseError, format!("{} not found in the electricity page", label)));
+
+    let kwh_text = field("剩余电量")?;
+    let kwh = kwh_text.trim_end_matches('度').trim().parse::<f64>().ok().filter(|kwh| kwh.is_finite())
+        .ok_or(SDKError::with_type(ErrorType::ParseError, format!("invalid electricity {}", kwh_text)))?;
+    let balance = parse_cents(field("剩余金额")?.trim_end_matches('元'))?;
+    let last_top_up = match field("最近充值")? {
+        "" | "暂无" | "无" => String::new(),
+        time => {
+            chrono::NaiveDateTime::parse_from_str(time, "%Y-%m-%d %H:%M:%S")
+                .map_err(|_| SDKError::with_type(ErrorType::ParseError, format!("invalid top-up time {}", time)))?;
+            time.to_string()
+        }
+    };
+    Ok(Electricity { kwh, balance, last_top_up })
+}
+
+fn parse_csrf(html: &str) -> Result<String> {
+    let document = Html::parse_document(html);
+    let selector = Selector::parse(r#"meta[name="_csrf"]"#).unwrap();
+    document.select(&selector).next()
+        .and_then(|element| element.value().attr("content"))
+        .map(|csrf| csrf.to_string())
+        .ok_or(SDKError::with_type(ErrorType::ParseError, "csrf token not found".to_string()))
+}
+
+// Parse a page of ECARD_CONSUME_QUERY_URL. Each row has columns: 时间, 类型, 地点, 金额, 余额,
+// where the time is split into a date like 2023.01.03 and a time like 12:00:00.
+//
+// Transactions are kept as is: two identical rows in the same second are two payments, not a duplicate.
+fn parse_transaction_page(html: &str, page: usize) -> Result<TransactionPage> {
+    let document = Html::parse_document(html);
+    let row_selector = Selector::parse("#all tbody tr").unwrap();
+    let cell_selector = Selector::parse("td").unwrap();
+    let mut transactions = Vec::new();
+    for tr in document.select(&row_selector) {
+        let cells: Vec<Vec<&str>> = tr.select(&cell_selector).map(|td| {
+            td.text().map(|x| x.trim()).filter(|x| !x.is_empty()).collect()
+        }).collect();
+        if cells.len() < 5 {
+            continue;
+        }
+        transactions.push(Transaction {
+            time: cells[0].join(" ").replace('.', "-"),
+            location: cells[2].join(" "),
+            amount: parse_cents(&cells[3].concat())?,
+            balance: parse_cents(&cells[4].concat())?,
+        });
+    }
+
+    let regex = Regex::new(r"共\s*(\d+)\s*页").unwrap();
+    let total_pages = match regex.captures(html) {
+        Some(cap) => cap[1].parse::<usize>().unwrap(),
+        None if transactions.len() < TRANSACTIONS_PER_PAGE => page,
+        None => return Err(SDKError::with_type(ErrorType::ParseError, "page count not found".to_string())),
+    };
+    Ok(TransactionPage { page, total_pages, transactions })
+}
 
 pub trait ECardClient: Account {
-    fn get_qr_code(&self) -> reqwest::Result<String> {
-        let client = self.get_client();
-        let html = client.get(ECARD_QR_CODE_URL).send()?.text()?;
-        let document = Html::parse_document(html.as_str());
-        let selector = Selector::parse(r##"#myText"##).unwrap();
-        let element = document.select(&selector).next().unwrap();
-        Ok(element.value().attr("value").unwrap().to_string())
+    // Return the current payment code (付款码), which changes every minute.
+    fn get_payment_code(&self) -> Result<PaymentCode> {
+        let html = self.send_and_get_text(self.get(ECARD_QR_CODE_URL))?;
+        parse_payment_code(&html)
+    }
+
+    // Return the balance in cents.
+    fn get_balance(&self) -> Result<i64> {
+        let html = self.send_and_get_text(self.get(ECARD_HOME_URL))?;
+        parse_balance(&html)
+    }
+
+    // Return a page (counted from 1) of the transactions from `start_date` to `end_date`, both inclusive and like 2023-01-03.
+    fn get_transaction_page(&self, start_date: &str, end_date: &str, page: usize) -> Result<TransactionPage> {
+        if page == 0 {
+            Err(SDKError::with_type(ErrorType::ArgumentError, "page is counted from 1".to_string()))?
+        }
+        let csrf = parse_csrf(&self.send_and_get_text(self.get(ECARD_CONSUME_URL))?)?;
+
+        let page_no = page.to_string();
+        let offset = ((page - 1) * TRANSACTIONS_PER_PAGE).to_string();
+        let mut payload = HashMap::new();
+        payload.insert("pageNo", page_no.as_str());
+        payload.insert("tabNo", "1");
+        payload.insert("pager.offset", offset.as_str());
+        payload.insert("tradename", "");
+        payload.insert("starttime", start_date);
+        payload.insert("endtime", end_date);
+        payload.insert("timetype", "1");
+        payload.insert("_csrf", csrf.as_str());
+        let html = self.send_and_get_text(self.post(ECARD_CONSUME_QUERY_URL).form(&payload))?;
+        parse_transaction_page(&html, page)
+    }
+
+    // Return the electricity left in a dormitory room of `campus` (handan or jiangwan), e.g. building 南区10号楼 and
+    // room 302. Fails with `ArgumentError` if the card system does not know the room.
+    fn get_electricity(&self, campus: &str, building: &str, room: &str) -> Result<Electricity> {
+        let area = electricity_area(campus)?;
+        if building.is_empty() || room.is_empty() {
+            Err(SDKError::with_type(ErrorType::ArgumentError, "empty building or room".to_string()))?
+        }
+        let csrf = parse_csrf(&self.send_and_get_text(self.get(ECARD_ELECTRICITY_URL))?)?;
+
+        let mut payload = HashMap::new();
+        payload.insert("area", area);
+        payload.insert("building", building);
+        payload.insert("room", room);
+        payload.insert("_csrf", csrf.as_str());
+        let html = self.send_and_get_text(self.post(ECARD_ELECTRICITY_QUERY_URL).form(&payload))?;
+        parse_electricity(&html)
+    }
+
+    // Walk all pages of the transactions from `start_date` to `end_date`, calling `on_page` for each page.
+    fn get_transactions<F>(&self, start_date: &str, end_date: &str, mut on_page: F) -> Result<()>
+        where F: FnMut(Vec<Transaction>) -> Result<()> {
+        let mut page = 1;
+        loop {
+            let result = self.get_transaction_page(start_date, end_date, page)?;
+            let total_pages = result.total_pages;
+            on_page(result.transactions)?;
+            if page >= total_pages {
+                return Ok(());
+            }
+            page += 1;
+        }
     }
 }
 
@@ -28,7 +264,50 @@ mod tests {
 
         let mut fd = Fdu::new();
         fd.login(uid.as_str(), pwd.as_str()).expect("login error");
-        assert!(fd.get_qr_code().expect("qr code error").starts_with("SWL2"));
+        assert!(fd.get_payment_code().expect("qr code error").code.starts_with("SWL2"));
         fd.logout().expect("logout error");
     }
+
+    #[test]
+    fn test_parse_cents() {
+        assert_eq!(parse_cents("-12.50").unwrap(), -1250);
+        assert_eq!(parse_cents("+100").unwrap(), 10000);
+        assert_eq!(parse_cents("1,234.5").unwrap(), 123450);
+        assert_eq!(parse_cents(" 0.07 ").unwrap(), 7);
+        assert!(parse_cents("").is_err());
+        assert!(parse_cents("1.234").is_err());
+        assert!(parse_cents("12元").is_err());
+    }
+
+    #[test]
+    fn test_parse_payment_code() {
+        let html = r#"<html><body><input type="hidden" id="myText" value="SWL2000123456789"></body></html>"#;
+        assert_eq!(parse_payment_code(html).unwrap(), PaymentCode {
+            code: "SWL2000123456789".to_string(),
+            valid_millis: PAYMENT_CODE_VALID_MILLIS,
+        });
+        let disabled = r#"<html><body><p>您尚未开通付款码</p></body></html>"#;
+        assert!(matches!(parse_payment_code(disabled).unwrap_err().error_type(), ErrorType::QrDisabledError));
+        assert!(matches!(parse_payment_code("<html></html>").unwrap_err().error_type(), ErrorType::ParseError));
+    }
+
+    #[test]
+    fn test_parse_electricity() {
+        assert_eq!(parse_electricity(include_str!("testdata/ecard_electricity.html")).unwrap(), Electricity {
+            kwh: 123.45,
+            balance: 6790,
+            last_top_up: "2024-03-01 12:30:05".to_string(),
+        });
+        assert_eq!(parse_electricity(include_str!("testdata/ecard_electricity_zero.html")).unwrap(), Electricity {
+            kwh: 0.0,
+            balance: 0,
+            last_top_up: String::new(),
+        });
+        let e = parse_electricity(include_str!("testdata/ecard_electricity_not_found.html")).unwrap_err();
+        assert!(matches!(e.error_type(), ErrorType::ArgumentError));
+        assert!(matches!(parse_electricity("<html></html>").unwrap_err().error_type(), ErrorType::ParseError));
+
+        assert_eq!(electricity_area("jiangwan").unwrap(), "2");
+        assert!(electricity_area("fenglin").is_err());
+    }
 }
@@ -1,13 +1,19 @@
 use std::collections::HashMap;
-use std::sync::Arc;
+use std::sync::{Arc, OnceLock};
 use std::{thread, time::Duration};
 
 use reqwest::{header, Url};
-use reqwest::blocking::{Client, ClientBuilder, RequestBuilder};
+use reqwest::blocking::{Client, ClientBuilder, Request, RequestBuilder, Response};
 use reqwest::cookie::{CookieStore, Jar};
+use reqwest::redirect::Policy;
 use scraper::{Html, Selector};
 // It is good practice to use the prelude to import the commonly used traits and types in this crate.
 use super::prelude::*;
+use super::persist::{self, SessionData, SiteCookies};
+use super::ratelimit::{self, RateLimiter};
+use super::raw::{self, RawRequest, RawResponse};
+use super::tls;
+use super::trace::{self, Phase, Tracer};
 
 // `const` declares a constant, which will be replaced with its value during compilation.
 //
@@ -24,6 +30,20 @@ use super::prelude::*;
 const LOGIN_URL: &str = "https://uis.fudan.edu.cn/authserver/login";
 const LOGOUT_URL: &str = "https://uis.fudan.edu.cn/authserver/logout";
 const LOGIN_SUCCESS_URL: &str = "https://uis.fudan.edu.cn/authserver/index.do";
+const NEED_CAPTCHA_URL: &str = "https://uis.fudan.edu.cn/authserver/needCaptcha.html";
+const CAPTCHA_URL: &str = "https://uis.fudan.edu.cn/authserver/captcha.html";
+// The sites whose cookies make up a session. The paths matter: the jar only returns cookies set for a path under it.
+const SESSION_URLS: &[&str] = &[
+    "https://uis.fudan.edu.cn/authserver/",
+    "https://jwfw.fudan.edu.cn/eams/",
+    "https://ecard.fudan.edu.cn/epay/",
+    "https://my.fudan.edu.cn/",
+    "https://opac.fudan.edu.cn/",
+    "https://seat.lib.fudan.edu.cn/",
+    "https://tyb.fudan.edu.cn/",
+    "https://xk.fudan.edu.cn/xk/",
+    "https://yjsxt.fudan.edu.cn/gsapp/",
+];
 const UA: &str = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML like Gecko) Chrome/91.0.4472.114 Safari/537.36";
 
 
@@ -42,20 +62,93 @@ pub trait HttpClient {
         headers.insert("Connection", header::HeaderValue::from_static("keep-alive"));
         headers.insert("DNT", header::HeaderValue::from_static("1"));
 
-        Client::builder()
+        let builder = Client::builder()
             .cookie_store(true)
             .user_agent(UA)
-            .default_headers(headers)
+            .default_headers(headers);
+        // The settings of the caller come last, to override the defaults above.
+        config::current().apply(builder)
     }
 
     fn get_cookie_store(&self) -> &Arc<Jar>;
 
+    // Start a request to `url`, or to the server replacing its host in tests, see `base_url`.
+    fn get(&self, url: &str) -> RequestBuilder {
+        self.get_client().get(base_url::resolve(url))
+    }
+
+    fn post(&self, url: &str) -> RequestBuilder {
+        self.get_client().post(base_url::resolve(url))
+    }
+
+    // How many times `execute()` sends a failed request again, see `HttpConfig::max_retries`.
+    fn max_retries(&self) -> u32 {
+        0
+    }
+
+    // The rate limits of the requests sent by `execute()`, see `ratelimit`.
+    fn rate_limiter(&self) -> Option<&RateLimiter> {
+        None
+    }
+
+    // Shares the span of the call with the resolver of the client, see `trace`.
+    fn tracer(&self) -> Option<&Tracer> {
+        None
+    }
+
+    // Send a request, again if it fails to connect, or times out if it is a GET (which is safe to send twice).
+    fn execute(&self, req: Request) -> Result<Response> {
+        let mut retries = self.max_retries();
+        let mut req = req;
+        loop {
+            let host = base_url::host_of(req.url());
+            if let Some(limiter) = self.rate_limiter() {
+                limiter.acquire(&host)?;
+            }
+            if let Some(tracer) = self.tracer() {
+                tracer.share();
+            }
+            let retry = if retries > 0 { req.try_clone() } else { None };
+            let is_get = req.method() == reqwest::Method::GET;
+            let res = trace::timed(Phase::Request, &host, || self.send_once(req),
+                                   |res| res.as_ref().map_or(0, |res| res.status().as_u16() as i32));
+            match res {
+                // A pin mismatch fails to connect too, but would fail the same way again.
+                Err(e) if (e.is_connect() || (e.is_timeout() && is_get)) && retry.is_some() && tls::pin_mismatch(&e).is_none() => {
+                    log::warn!("retrying {}: {}", e.url().map(Url::as_str).unwrap_or_default(), e);
+                    retries -= 1;
+                    req = retry.unwrap();
+                }
+                res => {
+                    let res = res?;
+                    // A gateway error (e.g. of jwfw during course selection) is transient, and its page is not the
+                    // one asked for: report it as a network error, which a retry policy may retry.
+                    if matches!(res.status().as_u16(), 502 | 503 | 504) {
+                        return Err(SDKError::with_type(ErrorType::NetworkError, format!("{} from {}", res.status(), res.url())));
+                    }
+                    return Ok(res);
+                }
+            }
+        }
+    }
+
+    // Send a request once, as is, recording the exchange if recording is on, see `record`.
+    fn send_once(&self, req: Request) -> reqwest::Result<Response> {
+        let cookies = self.get_cookie_store().cookies(req.url());
+        record::exchange(req, cookies, |req| self.get_client().execute(req))
+    }
+
+    // Send a request once, without the rate limits and the retries of `execute()`, e.g. for the steps of the login.
+    fn send(&self, builder: RequestBuilder) -> Result<Response> {
+        Ok(self.send_once(builder.build()?)?)
+    }
+
     // safely send a request and get its text
     // automatically deal some common errors like repeat login and throttling
     fn send_and_get_text(&self, builder: RequestBuilder) -> Result<String> {
         let req = builder.build()?;
         if let Some(mut request) = req.try_clone() {  // copy!
-            let html = self.get_client().execute(req)?.text()?;
+            let html = self.execute(req)?.text()?;
 
             // sleep for a while
             // will be throttled if duration is 1 second
@@ -67,17 +160,17 @@ pub trait HttpClient {
                     if let Some(href) = a.value().attr("href") {
                         let url_ptr = request.url_mut();
                         *url_ptr = Url::parse(href).expect("");
-                        println!("repeat login, redirect to {}", request.url().as_str());
-                        return Ok(self.get_client().execute(request)?.text()?);
+                        log::info!("repeat login, redirect to {}", request.url().as_str());
+                        return Ok(self.execute(request)?.text()?);
                     }
                 }
             } else if html.contains("请不要过快点击") {
-                return Ok(self.get_client().execute(request)?.text()?);
+                return Ok(self.execute(request)?.text()?);
             }
 
             Ok(html)
         } else {
-            Ok(self.get_client().execute(req)?.text()?)
+            Ok(self.execute(req)?.text()?)
         }
     }
 }
@@ -122,14 +215,24 @@ pub trait Account: HttpClient {
     fn set_credentials(&mut self, uid: &str, pwd: &str);
 
     fn login(&mut self, uid: &str, pwd: &str) -> Result<()> {
+        self.login_with_captcha(uid, pwd, None)
+    }
+
+    // Log in, answering the captcha shown by `get_captcha_image()` if any.
+    //
+    // Fails with `CaptchaRequiredError` if UIS asks for a captcha but none is given, or if the answer is wrong.
+    // In both cases, fetch a new image with `get_captcha_image()` on the same instance and try again.
+    fn login_with_captcha(&mut self, uid: &str, pwd: &str, captcha: Option<&str>) -> Result<()> {
         self.set_credentials(uid, pwd);
+        record::secret(record::Kind::StudentId, uid);
+        record::secret(record::Kind::Password, pwd);
 
         let mut payload = HashMap::new();
         payload.insert("username", uid);
         payload.insert("password", pwd);
 
         // get some tokens
-        let html = self.get_client().get(LOGIN_URL).send()?.text()?;
+        let html = self.send(self.get(LOGIN_URL))?.text()?;
         let document = Html::parse_document(html.as_str());
         let selector = Selector::parse(r#"input[type="hidden"]"#).unwrap();
         for element in document.select(&selector) {
@@ -138,21 +241,52 @@ pub trait Account: HttpClient {
                 payload.insert(key, element.value().attr("value").unwrap_or_default());
             }
         }
+        log::debug!("login form of {} has fields {:?}", uid, payload.keys().collect::<Vec<_>>());
+
+        match captcha {
+            Some(answer) => { payload.insert("captchaResponse", answer); }
+            None => if self.need_captcha(uid)? {
+                log::info!("UIS asks {} for a captcha", uid);
+                return Err(SDKError::with_type(ErrorType::CaptchaRequiredError, "captcha required".to_string()));
+            }
+        }
 
         // send login request
-        let res = self.get_client().post(LOGIN_URL).form(&payload).send()?;
+        let res = self.send(self.post(LOGIN_URL).form(&payload))?;
 
         // check if login is successful
-        if res.url().as_str() == LOGIN_SUCCESS_URL {
+        log::debug!("login of {} redirected to {}", uid, res.url());
+        if res.url().as_str() == base_url::resolve(LOGIN_SUCCESS_URL) {
             Ok(())
+        } else if captcha.is_some() && res.text()?.contains("验证码") {
+            Err(SDKError::with_type(ErrorType::CaptchaRequiredError, "wrong captcha".to_string()))
         } else {
+            log::warn!("login of {} failed, UIS stays at {}", uid, res.url());
             Err(SDKError::with_type(ErrorType::LoginError, "login failed".to_string()))
         }
     }
 
+    // UIS asks for a captcha after several failed logins of an account.
+    fn need_captcha(&self, uid: &str) -> Result<bool> {
+        let text = self.send(self.get(NEED_CAPTCHA_URL).query(&[("username", uid)]))?.text()?;
+        Ok(text.trim() == "true")
+    }
+
+    // Fetch a new captcha image for the login of this instance, which replaces the previous one.
+    fn get_captcha_image(&self) -> Result<Vec<u8>> {
+        Ok(self.send(self.get(CAPTCHA_URL))?.bytes()?.to_vec())
+    }
+
+    // Check whether the session is still logged in to UIS, with a request which is cheap and has no side effect.
+    // An expired session is redirected to the login page.
+    fn is_logged_in(&self) -> Result<bool> {
+        let res = self.send(self.get(LOGIN_SUCCESS_URL))?;
+        Ok(res.url().as_str() == base_url::resolve(LOGIN_SUCCESS_URL))
+    }
+
     fn logout(&self) -> Result<()> {
         // TODO: logout service
-        let res = self.get_client().get(LOGOUT_URL).query(&[("service", "")]).send()?;
+        let res = self.send(self.get(LOGOUT_URL).query(&[("service", "")]))?;
 
         if res.status() != 200 {
             Err(SDKError::with_type(ErrorType::LoginError, "logout failed".to_string()))
@@ -166,6 +300,13 @@ pub trait Account: HttpClient {
 pub struct Fdu {
     client: Client,
     cookie_store: Arc<Jar>,
+    // Read from the config when the session is created, like the settings of the client.
+    max_retries: u32,
+    rate_limiter: RateLimiter,
+    raw_limits: raw::Limits,
+    // The client of the raw requests, built on the first one, see `send_raw()`.
+    raw_client: OnceLock<Client>,
+    tracer: Tracer,
     uid: Option<String>,
     pwd: Option<String>,
 }
@@ -178,6 +319,42 @@ impl HttpClient for Fdu {
     fn get_cookie_store(&self) -> &Arc<Jar> {
         &self.cookie_store
     }
+
+    fn max_retries(&self) -> u32 {
+        self.max_retries
+    }
+
+    fn rate_limiter(&self) -> Option<&RateLimiter> {
+        Some(&self.rate_limiter)
+    }
+
+    fn tracer(&self) -> Option<&Tracer> {
+        Some(&self.tracer)
+    }
+}
+
+// The session, with the client of the raw requests, which follows no redirect: `raw::send()` follows them itself.
+struct RawClient<'a> {
+    fdu: &'a Fdu,
+    client: &'a Client,
+}
+
+impl HttpClient for RawClient<'_> {
+    fn get_client(&self) -> &Client {
+        self.client
+    }
+
+    fn get_cookie_store(&self) -> &Arc<Jar> {
+        &self.fdu.cookie_store
+    }
+
+    fn rate_limiter(&self) -> Option<&RateLimiter> {
+        Some(&self.fdu.rate_limiter)
+    }
+
+    fn tracer(&self) -> Option<&Tracer> {
+        Some(&self.fdu.tracer)
+    }
 }
 
 impl Account for Fdu {
@@ -191,18 +368,78 @@ impl Fdu {
     // It is always recommended to use `new()` to create an instance of a struct.
     pub(crate) fn new() -> Self {
         let cookie_store = Arc::new(Jar::default());
+        let config = config::current();
+        let tracer = Tracer::default();
         let client = Self::client_builder()
             .cookie_provider(Arc::clone(&cookie_store))
+            .dns_resolver(tracer.resolver())
             .build()
             .expect("client build failed");
 
         Self {
             client,
             cookie_store,
+            max_retries: config.max_retries,
+            rate_limiter: RateLimiter::new(&config.rate_limits),
+            raw_limits: raw::Limits::new(&config),
+            raw_client: OnceLock::new(),
+            tracer,
             uid: None,
             pwd: None,
         }
     }
+
+    // The student id the session logged in with, if any.
+    pub(crate) fn uid(&self) -> Option<&str> {
+        self.uid.as_deref()
+    }
+
+    // The state of the rate limits of the session, see `ratelimit`.
+    pub(crate) fn rate_limit_stats(&self) -> ratelimit::Stats {
+        self.rate_limiter.stats()
+    }
+
+    // Send a raw request with the cookies of the session, see `raw`. `check` is called before each hop.
+    pub(crate) fn send_raw(&self, request: RawRequest, check: impl Fn() -> Result<()>) -> Result<RawResponse> {
+        let client = self.raw_client.get_or_init(|| {
+            Self::client_builder()
+                .cookie_provider(Arc::clone(&self.cookie_store))
+                .dns_resolver(self.tracer.resolver())
+                .redirect(Policy::none())
+                .build()
+                .expect("client build failed")
+        });
+        raw::send(&RawClient { fdu: self, client }, &self.raw_limits, request, check)
+    }
+
+    // Serialize the cookies of the session, so that it can be restored by `restore()` without logging in again.
+    // The password is not included.
+    pub(crate) fn export(&self) -> Result<Vec<u8>> {
+        let mut data = SessionData { uid: self.uid.clone(), cookies: Vec::new() };
+        for url in SESSION_URLS {
+            // The blob always names the real sites, even when a test replaces them.
+            let cookies = self.cookie_store.cookies(&Url::parse(&base_url::resolve(url)).unwrap());
+            if let Some(cookies) = cookies.as_ref().and_then(|value| value.to_str().ok()) {
+                data.cookies.push(SiteCookies { url: url.to_string(), cookies: cookies.to_string() });
+            }
+        }
+        persist::encode(&data)
+    }
+
+    // Reconstruct a session exported by `export()`. It does not check whether the session has expired.
+    pub(crate) fn restore(blob: &[u8]) -> Result<Self> {
+        let data = persist::decode(blob)?;
+        let mut fdu = Self::new();
+        for site in &data.cookies {
+            let url = Url::parse(&base_url::resolve(&site.url))
+                .map_err(|_| SDKError::with_type(ErrorType::ArgumentError, format!("invalid url {} in session blob", site.url)))?;
+            for cookie in site.cookies.split("; ").filter(|cookie| !cookie.is_empty()) {
+                fdu.cookie_store.add_cookie_str(cookie, &url);
+            }
+        }
+        fdu.uid = data.uid;
+        Ok(fdu)
+    }
 }
 
 
@@ -244,4 +481,40 @@ mod tests {
         let html = fd.send_and_get_text(fd.get_client().get("http://www.baidu.com")).unwrap();
         assert_ne!(html.len(), 0);
     }
-}
\ No newline at end of file
+
+    #[test]
+    fn test_export_and_restore() {
+        let mut fd = Fdu::new();
+        fd.set_credentials("20300000000", "secret");
+        let url = Url::parse(SESSION_URLS[0]).unwrap();
+        fd.cookie_store.add_cookie_str("CASTGC=TGT-1", &url);
+        fd.cookie_store.add_cookie_str("JSESSIONID=abc", &url);
+
+        let blob = fd.export().unwrap();
+        assert!(!String::from_utf8_lossy(&blob).contains("secret"));
+        let restored = Fdu::restore(&blob).unwrap();
+        assert_eq!(restored.uid.as_deref(), Some("20300000000"));
+        assert_eq!(restored.pwd, None);
+        let cookies = restored.cookie_store.cookies(&url).unwrap();
+        let cookies = cookies.to_str().unwrap();
+        assert!(cookies.contains("CASTGC=TGT-1") && cookies.contains("JSESSIONID=abc"), "{}", cookies);
+
+        assert!(Fdu::restore(&blob[..blob.len() / 2]).is_err());
+    }
+
+    #[test]
+    fn test_sessions_isolated() {
+        // Each session owns its jar, so that accounts logged in side by side never see the cookies of each other.
+        let a = Fdu::new();
+        let b = Fdu::new();
+        assert!(!Arc::ptr_eq(&a.cookie_store, &b.cookie_store));
+        let url = Url::parse(SESSION_URLS[0]).unwrap();
+        a.cookie_store.add_cookie_str("CASTGC=TGT-A", &url);
+        assert!(b.cookie_store.cookies(&url).is_none());
+
+        let restored = Fdu::restore(&a.export().unwrap()).unwrap();
+        assert!(!Arc::ptr_eq(&a.cookie_store, &restored.cookie_store));
+        restored.cookie_store.add_cookie_str("CASTGC=TGT-C", &url);
+        assert_eq!(a.cookie_store.cookies(&url).unwrap().to_str().unwrap(), "CASTGC=TGT-A");
+    }
+}
@@ -7,7 +7,7 @@ const GET_INFO_URL: &str = "https://zlapp.fudan.edu.cn/ncov/wap/fudan/get-info";
 
 
 pub fn get_history_info(fdu: &Fdu) -> Result<String> {
-    Ok(fdu.get_client().get(GET_INFO_URL).send()?.text()?)
+    Ok(fdu.send(fdu.get(GET_INFO_URL))?.text()?)
 }
 
 pub fn has_tick(fdu: &Fdu) -> Result<bool> {
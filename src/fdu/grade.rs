@@ -4,6 +4,7 @@ use std::sync::Arc;
 use reqwest::blocking::Client;
 use reqwest::cookie::Jar;
 use scraper::{Html, Selector};
+use serde::Serialize;
 
 use super::prelude::*;
 
@@ -46,24 +47,38 @@ struct CourseGrade {
     semester: String,
     credit: f64,
     grade: String,
-    point: f64,
+    // None for P/NP and other grades without a point
+    point: Option<f64>,
 }
 
-#[derive(Default)]
-struct GPA {
-    gpa: f64,
-    ranking: i32,
-    total: i32,
-    percentage: f64,
-    credits: f64,
+#[derive(Default, Debug, Serialize, PartialEq)]
+pub struct GPA {
+    pub(crate) gpa: f64,
+    // The ranking within the major, counted from 1, the number of students in the major and the ratio of both.
+    // None for graduate students, who are not ranked.
+    pub(crate) ranking: Option<i32>,
+    pub(crate) total: Option<i32>,
+    pub(crate) percentage: Option<f64>,
+    pub(crate) credits: f64,
+    pub(crate) major: String,
+    // The progress in the training plan (培养计划), only for graduate students, see yjsxt.rs.
+    pub(crate) progress: Option<PlanProgress>,
+}
+
+#[derive(Default, Debug, Serialize, PartialEq)]
+pub struct PlanProgress {
+    // The credits required by the training plan, and those earned so far.
+    pub(crate) required_credits: f64,
+    pub(crate) earned_credits: f64,
 }
 
 impl Display for GPA {
     fn fmt(&self, f: &mut Formatter<'_>) -> std::fmt::Result {
-        write!(
-            f, "gpa: {}, ranking: {}/{} {:.1}%, credits: {}",
-            self.gpa, self.ranking, self.total, self.percentage * 100.0, self.credits
-        )
+        write!(f, "gpa: {}", self.gpa)?;
+        if let (Some(ranking), Some(total), Some(percentage)) = (self.ranking, self.total, self.percentage) {
+            write!(f, ", ranking: {}/{} {:.1}%", ranking, total, percentage * 100.0)?;
+        }
+        write!(f, ", credits: {}", self.credits)
     }
 }
 
@@ -76,7 +91,7 @@ impl Grade {
         const GRADE_URL: &str = "https://my.fudan.edu.cn/list/bks_xx_cj";
         let mut grades: Vec<CourseGrade> = Vec::new();
 
-        let html = self.send_and_get_text(self.get_client().get(GRADE_URL))?;
+        let html = self.send_and_get_text(self.get(GRADE_URL))?;
         let document = Html::parse_document(html.as_str());
         for tr in document.select(&Selector::parse("tbody tr").unwrap()) {
             let v = tr.text().collect::<Vec<_>>();
@@ -116,13 +131,13 @@ impl Grade {
         if let Ok(gpa) = result {
             return gpa;
         }
-        println!("get gpa from jwfw failed, calculate manually");
+        log::warn!("get gpa from jwfw failed, calculate manually");
 
         let result = self.get_gpa_from_grades();
         if let Ok(gpa) = result {
             return gpa;
         }
-        println!("get gpa from grades failed");
+        log::error!("get gpa from grades failed");
         GPA::default()
     }
 
@@ -133,83 +148,85 @@ impl Grade {
         }
         let mut gpa = GPA::default();
         for grade in grades {
-            if grade.grade.eq("P") { // P isn't calculated
-                continue;
+            if let Some(point) = grade.point { // P/NP isn't calculated
+                gpa.gpa += point * grade.credit;
+                gpa.credits += grade.credit;
             }
-            gpa.gpa += grade.point * grade.credit;
-            gpa.credits += grade.credit;
         }
         gpa.gpa /= gpa.credits;
         Ok(gpa)
     }
 
     fn get_gpa_from_jwfw(&mut self) -> Result<GPA> {
-        let mut gpa = GPA::default();
-        let mut major = "";
-
         // get data
         const GPA_SEARCH_URL: &str = "https://jwfw.fudan.edu.cn/eams/myActualGpa!search.action";
         let html = self.send_and_get_text(
-            self.get_client().get(GPA_SEARCH_URL)
+            self.get(GPA_SEARCH_URL)
         )?;
-        let document = Html::parse_document(html.as_str());
-        let selector = Selector::parse("tbody tr").unwrap();
-
-        // it contains all majors in a school, so we have to find my major
-        for tr in document.select(&selector) {
-            let mut v = tr.text().collect::<Vec<_>>();
-            v.retain(|&x| x.trim() != "");
-            if !v[0].starts_with("*") { // it's me!
-                major = v[3];
-                gpa.gpa = v[5].parse::<f64>().expect("parse gpa error");
-                gpa.credits = v[6].parse::<f64>().expect("parse credits error");
-                break;
-            }
-        }
+        parse_gpa(&html)
+    }
+}
 
-        // find ranking, because records are in descending order
-        for tr in document.select(&selector) {
-            let mut v = tr.text().collect::<Vec<_>>();
-            v.retain(|&x| x.trim() != "");
-            if v[3] != major {
-                continue;
-            }
-            // my major
-            gpa.total += 1;
-            if !v[0].starts_with("*") { // it's me!
-                gpa.ranking = gpa.total
-            }
+// Parse the GPA and the ranking from myActualGpa!search.action.
+// The table has columns: 学号, 姓名, 年级, 专业, 院系, 绩点, 学分. Student ids of others are masked with *.
+pub(crate) fn parse_gpa(html: &str) -> Result<GPA> {
+    let mut gpa = GPA::default();
+    let parse_error = |msg: &str| SDKError::with_type(ErrorType::ParseError, msg.to_string());
+
+    let document = Html::parse_document(html);
+    let selector = Selector::parse("tbody tr").unwrap();
+    let rows: Vec<Vec<&str>> = document.select(&selector).map(|tr| {
+        let mut v = tr.text().collect::<Vec<_>>();
+        v.retain(|&x| x.trim() != "");
+        v.into_iter().map(|x| x.trim()).collect()
+    }).filter(|v: &Vec<&str>| v.len() >= 7).collect();
+
+    // it contains all majors in a school, so we have to find my major
+    let me = rows.iter().find(|v| !v[0].starts_with("*")) // it's me!
+        .ok_or(parse_error("own record not found in gpa table"))?;
+    gpa.major = me[3].to_string();
+    gpa.gpa = me[5].parse::<f64>().map_err(|_| parse_error("invalid gpa"))?;
+    gpa.credits = me[6].parse::<f64>().map_err(|_| parse_error("invalid credits"))?;
+
+    // find ranking, because records are in descending order
+    let (mut ranking, mut total) = (0, 0);
+    for v in rows.iter().filter(|v| v[3] == gpa.major) {
+        // my major
+        total += 1;
+        if !v[0].starts_with("*") { // it's me!
+            ranking = total
         }
+    }
 
-        if gpa.total != 0 { // calculate percentage
-            gpa.percentage = gpa.ranking as f64 / gpa.total as f64;
-        }
+    // there is at least one student in my major: me
+    gpa.ranking = Some(ranking);
+    gpa.total = Some(total);
+    gpa.percentage = Some(ranking as f64 / total as f64);
 
-        Ok(gpa)
-    }
+    Ok(gpa)
 }
 
-fn grade_to_point(grade: &str) -> f64 {
+// Returns None for grades without a point, e.g. P(pass) and NP(not pass), which are not counted in GPA.
+pub(crate) fn grade_to_point(grade: &str) -> Option<f64> {
     match grade {
-        "A" => 4.0,
-        "A-" => 3.7,
-        "B+" => 3.3,
-        "B" => 3.0,
-        "B-" => 2.7,
-        "C+" => 2.3,
-        "C" => 2.0,
-        "C-" => 1.7,
-        "D+" => 1.3,
-        "D" => 1.0,
-        "F" => 0.0,
-        "P" => 0.0,
+        "A" => Some(4.0),
+        "A-" => Some(3.7),
+        "B+" => Some(3.3),
+        "B" => Some(3.0),
+        "B-" => Some(2.7),
+        "C+" => Some(2.3),
+        "C" => Some(2.0),
+        "C-" => Some(1.7),
+        "D+" => Some(1.3),
+        "D" => Some(1.0),
+        "F" => Some(0.0),
+        "P" | "NP" => None,
         _ => {
-            println!("[W] unknown grade {}", grade);
-            0.0
+            log::warn!("unknown grade {}", grade);
+            None
         }
     }
 }
-
 #[cfg(test)]
 mod tests {
     use crate::fdu::fdu::Account;
@@ -252,4 +269,12 @@ mod tests {
 
         grade.fdu.logout().unwrap();
     }
+
+    #[test]
+    fn test_grade_to_point() {
+        assert_eq!(grade_to_point("A-"), Some(3.7));
+        assert_eq!(grade_to_point("F"), Some(0.0));
+        assert_eq!(grade_to_point("P"), None);
+        assert_eq!(grade_to_point("NP"), None);
+    }
 }
\ No newline at end of file
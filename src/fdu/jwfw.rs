@@ -2,30 +2,246 @@ use std::collections::HashMap;
 
 use regex::Regex;
 use scraper::{Html, Selector};
+use chrono::NaiveDate;
+use serde::{Deserialize, Serialize};
 
+use crate::error::*;
+use crate::fdu::audit::{parse_plan_audit, AuditCategory};
 use crate::fdu::fdu::{Account, Fdu};
+use crate::fdu::grade::{grade_to_point, parse_gpa, GPA};
+use crate::fdu::yjsxt::{Profile, StudentType};
 
 const JWFW_URL: &str = "https://jwfw.fudan.edu.cn/eams/home.action";
 const JWFW_COURSE_TABLE_QUERY_URL: &str = "https://jwfw.fudan.edu.cn/eams/courseTableForStd!courseTable.action";
 const JWFW_COURSE_TABLE_MAIN_URL: &str = "https://jwfw.fudan.edu.cn/eams/courseTableForStd.action";
+const JWFW_DATA_QUERY_URL: &str = "https://jwfw.fudan.edu.cn/eams/dataQuery.action";
+const JWFW_EXAM_TABLE_URL: &str = "https://jwfw.fudan.edu.cn/eams/stdExamTable!examTable.action";
+const JWFW_SCORE_URL: &str = "https://jwfw.fudan.edu.cn/eams/teach/grade/course/person!search.action";
+const JWFW_GPA_URL: &str = "https://jwfw.fudan.edu.cn/eams/myActualGpa!search.action";
+const JWFW_FREE_CLASSROOM_URL: &str = "https://jwfw.fudan.edu.cn/eams/classroom/apply/free!search.action";
+const JWFW_CALENDAR_URL: &str = "https://jwfw.fudan.edu.cn/eams/schoolCalendar!data.action";
+const JWFW_STD_DETAIL_URL: &str = "https://jwfw.fudan.edu.cn/eams/stdDetail.action";
+const JWFW_PLAN_AUDIT_URL: &str = "https://jwfw.fudan.edu.cn/eams/myPlanCompl.action";
 
 impl JwfwClient for Fdu {}
 
 // Parse the ids(a value related to student id) from courseTableForStd.action
-fn parse_ids(html: &String) -> String {
+fn parse_ids(html: &String) -> Result<String> {
     let regex = Regex::new(r##"bg.form.addInput\(form,"ids","(\d+)"\);"##).unwrap();
-    let cap = regex.captures_iter(html).next().unwrap();
-    cap[1].to_string()
+    let cap = regex.captures_iter(html).next()
+        .ok_or(SDKError::with_type(ErrorType::ParseError, "ids not found in course table page".to_string()))?;
+    Ok(cap[1].to_string())
 }
 
-#[derive(Debug)]
+// A lesson of a course, i.e. the course takes place at `weekday`, from `start_slot` to `end_slot` in each of `weeks`.
+// A course taking place several times a week is made of several lessons.
+#[derive(Debug, Serialize, PartialEq)]
 pub struct CourseData {
+    // e.g. COMP130004.03
+    pub(crate) course_id: String,
+    // e.g. 数据结构
+    pub(crate) name: String,
+    pub(crate) teacher: String,
+    // Empty if the course has no fixed classroom.
+    pub(crate) location: String,
+    // 1 for Monday, ..., 7 for Sunday
+    pub(crate) weekday: i32,
+    // Slots are counted from 1.
+    pub(crate) start_slot: i32,
+    pub(crate) end_slot: i32,
+    pub(crate) weeks: Vec<i32>,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Semester {
+    // The value of semester.id to query the course table, e.g. 385
+    pub(crate) id: String,
+    // e.g. 2022-2023
+    pub(crate) school_year: String,
+    // e.g. 1, 2, 暑期, 寒假
+    pub(crate) name: String,
+}
+
+// Dates are like 2023-09-11.
+#[derive(Debug, Serialize, PartialEq)]
+pub struct AcademicCalendar {
+    semesters: Vec<CalendarSemester>,
+    // Days without classes during semesters.
+    holidays: Vec<Holiday>,
+    // Days, usually on weekends, with the classes of another weekday to make up for holidays.
+    adjustments: Vec<DayAdjustment>,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct CalendarSemester {
+    // The same as `Semester::id`
     id: String,
-    teacher: String,
-    name_with_course_id: String,
-    classroom: String,
-    weeks: Vec<i32>,
-    time: Vec<(i32, i32)>,
+    school_year: String,
+    name: String,
+    // The first day of week 1, which may not be a Monday, and the last day of the semester.
+    start: String,
+    end: String,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Holiday {
+    date: String,
+    // e.g. 国庆节
+    name: String,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct DayAdjustment {
+    date: String,
+    // 1 for Monday, ..., 7 for Sunday
+    acts_as_weekday: i32,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Exam {
+    pub(crate) course_id: String,
+    pub(crate) name: String,
+    // e.g. 期末考试
+    pub(crate) r#type: String,
+    // e.g. 2023-01-03, empty if not scheduled yet
+    pub(crate) date: String,
+    // e.g. 08:30~10:30, empty if not scheduled yet
+    pub(crate) time: String,
+    pub(crate) location: String,
+    pub(crate) seat: String,
+    pub(crate) note: String,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Score {
+    // e.g. 2022-2023 1
+    pub(crate) semester: String,
+    pub(crate) course_id: String,
+    pub(crate) name: String,
+    pub(crate) credit: f64,
+    // e.g. A-, P, NP
+    pub(crate) grade: String,
+    // None for grades without a point, e.g. P/NP, so that they are not counted as 0 by mistake.
+    pub(crate) point: Option<f64>,
+}
+
+// Collect the trimmed text of every cell of every row in the first table body of the page.
+fn parse_table_rows(html: &str) -> Vec<Vec<String>> {
+    let document = Html::parse_document(html);
+    let row_selector = Selector::parse("table tbody tr").unwrap();
+    let cell_selector = Selector::parse("td").unwrap();
+    document.select(&row_selector).map(|tr| {
+        tr.select(&cell_selector).map(|td| td.text().collect::<String>().trim().to_string()).collect()
+    }).collect()
+}
+
+fn cell(row: &[String], i: usize) -> String {
+    row.get(i).cloned().unwrap_or_default()
+}
+
+// The exam table has columns: 课程序号, 课程名称, 考试类别, 考试日期, 考试安排, 考场, 座位号, 考试情况.
+// Not yet scheduled exams have "[考试时间未安排]" or similar placeholders, which are replaced by empty strings.
+fn parse_exams(html: &str) -> Vec<Exam> {
+    let unscheduled = |s: String| if s.contains("未安排") { String::new() } else { s };
+    parse_table_rows(html).into_iter().filter(|row| row.len() >= 2).map(|row| Exam {
+        course_id: cell(&row, 0),
+        name: cell(&row, 1),
+        r#type: cell(&row, 2),
+        date: unscheduled(cell(&row, 3)),
+        time: unscheduled(cell(&row, 4)),
+        location: unscheduled(cell(&row, 5)),
+        seat: unscheduled(cell(&row, 6)),
+        note: cell(&row, 7),
+    }).collect()
+}
+
+// The score table has columns: 学年学期, 课程代码, 课程序号, 课程名称, 课程类别, 学分, 最终, 绩点.
+fn parse_scores(html: &str) -> Result<Vec<Score>> {
+    let mut scores = Vec::new();
+    for row in parse_table_rows(html) {
+        if row.len() < 7 {
+            continue;
+        }
+        let credit = row[5].parse::<f64>()
+            .map_err(|_| SDKError::with_type(ErrorType::ParseError, format!("invalid credit {} of {}", row[5], row[3])))?;
+        scores.push(Score {
+            semester: cell(&row, 0),
+            course_id: cell(&row, 2),
+            name: cell(&row, 3),
+            credit,
+            point: grade_to_point(&row[6]),
+            grade: cell(&row, 6),
+        });
+    }
+    Ok(scores)
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Classroom {
+    // e.g. HGX101
+    name: String,
+    capacity: i32,
+    // The slots in the query when the room is free, in ascending order
+    free_slots: Vec<i32>,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct ClassroomBuilding {
+    // e.g. HGX, 第二教学楼
+    name: String,
+    rooms: Vec<Classroom>,
+}
+
+// Map the campus names used by the SDK to the values of classroom.campus.id.
+fn campus_id(campus: &str) -> Result<&'static str> {
+    match campus {
+        "handan" => Ok("1"),
+        "fenglin" => Ok("2"),
+        "zhangjiang" => Ok("3"),
+        "jiangwan" => Ok("4"),
+        _ => Err(SDKError::with_type(ErrorType::ArgumentError, format!("unknown campus {}", campus))),
+    }
+}
+
+// Parse the free classrooms in a slot. The table has columns: 序号, 名称, 教学楼, 校区, 教室类型, 容量.
+// Returns (building, room, capacity).
+fn parse_free_classrooms(html: &str) -> Vec<(String, String, i32)> {
+    parse_table_rows(html).into_iter().filter(|row| row.len() >= 6).map(|row| {
+        (cell(&row, 2), cell(&row, 1), row[5].parse::<i32>().unwrap_or(0))
+    }).collect()
+}
+
+// Group the free classrooms of each slot by building, keeping the order they first appear in.
+fn merge_free_classrooms(free_by_slot: Vec<(i32, Vec<(String, String, i32)>)>) -> Vec<ClassroomBuilding> {
+    let mut buildings: Vec<ClassroomBuilding> = Vec::new();
+    for (slot, rooms) in free_by_slot {
+        for (building_name, room_name, capacity) in rooms {
+            let building = match buildings.iter().position(|b| b.name == building_name) {
+                Some(i) => &mut buildings[i],
+                None => {
+                    buildings.push(ClassroomBuilding { name: building_name, rooms: Vec::new() });
+                    buildings.last_mut().unwrap()
+                }
+            };
+            match building.rooms.iter_mut().find(|r| r.name == room_name) {
+                Some(room) if !room.free_slots.contains(&slot) => room.free_slots.push(slot),
+                Some(_) => {}
+                None => building.rooms.push(Classroom { name: room_name, capacity, free_slots: vec![slot] }),
+            }
+        }
+    }
+    buildings
+}
+
+// Split "数据结构(COMP130004.03)" into ("数据结构", "COMP130004.03").
+fn split_name_with_course_id(name_with_course_id: &str) -> (String, String) {
+    match name_with_course_id.rfind('(') {
+        Some(i) if name_with_course_id.ends_with(')') => (
+            name_with_course_id[..i].to_string(),
+            name_with_course_id[i + 1..name_with_course_id.len() - 1].to_string(),
+        ),
+        _ => (name_with_course_id.to_string(), String::new()),
+    }
 }
 
 // Parse the course data from the javascript part of the raw html.
@@ -47,15 +263,20 @@ index =1*unitCount+9;
 table0.activities[index][table0.activities[index].length]=activity;
  */
 // the number in "index =2*unitCount+0;", "index =1*unitCount+8;", etc. implies the day and time for the course in the current week.
+// Both numbers are counted from 0, i.e. "index =2*unitCount+0;" is the first slot on Wednesday.
+//
+// Any field may be empty, e.g. the classroom of a course without a fixed room, so do not match them with \S+.
 fn parse_course_data(html: &String) -> Vec<CourseData> {
-    let regex_course = Regex::new(r##"activity = new TaskActivity\("(\d+)","(\S+)","\d+\(\w+.\w+\)","(\S+\(\w+.\w+\))","\d+","(\S+)","([01]+)"\);((?:\s*index =\d+\*unitCount\+\d+;\s*table0.activities\[index]\[table0.activities\[index].length]=activity;)+)"##).unwrap();
+    let regex_course = Regex::new(r##"activity = new TaskActivity\("[^"]*","([^"]*)","[^"]*","([^"]*)","[^"]*","([^"]*)","([01]+)"\);((?:\s*index =\d+\*unitCount\+\d+;\s*table0.activities\[index]\[table0.activities\[index].length]=activity;)+)"##).unwrap();
+    let regex_lesson = Regex::new(r##"index =(\d+)\*unitCount\+(\d+);"##).unwrap();
     let mut ret = Vec::new();
     for cap_course in regex_course.captures_iter(html.as_str()) {
 
         // Get the week info for the course
         // e.g. "01111111111011111000000000000000000000000000000000000"
         // The position with value 1 means there's a lesson in the week of its index.
-        let course_week_info = cap_course[5].to_string();
+        // Irregular weeks (e.g. 单周/双周) are just other patterns, like "01010101010101010...".
+        let course_week_info = cap_course[4].to_string();
 
         // Convert the week info to vector.
         // e.g. "01111111111011111000000000000000000000000000000000000" converts to vec![1,2,3,4,5,6,7,8,9,10,12,13,14,15,16]
@@ -79,60 +300,255 @@ fn parse_course_data(html: &String) -> Vec<CourseData> {
         */
 
         let mut time: Vec<(i32, i32)> = Vec::new();
-        let course_data = &cap_course[6];
-        let regex_lesson = Regex::new(r##"index =(\d+)\*unitCount\+(\d+);"##).unwrap();
-        for cap_lesson in regex_lesson.captures_iter(course_data) {
-            let day_number: &i32 = &cap_lesson[1].parse().unwrap();
-            let time_number: &i32 = &cap_lesson[2].parse().unwrap();
-            time.push((*day_number, *time_number));
+        for cap_lesson in regex_lesson.captures_iter(&cap_course[5]) {
+            let day_number: i32 = cap_lesson[1].parse().unwrap();
+            let time_number: i32 = cap_lesson[2].parse().unwrap();
+            time.push((day_number, time_number));
+        }
+        time.sort();
+
+        // Merge consecutive slots on the same day into a lesson.
+        let (name, course_id) = split_name_with_course_id(&cap_course[2]);
+        let mut i = 0;
+        while i < time.len() {
+            let (day, start) = time[i];
+            let mut end = start;
+            while i + 1 < time.len() && time[i + 1] == (day, end + 1) {
+                end += 1;
+                i += 1;
+            }
+            ret.push(CourseData {
+                course_id: course_id.clone(),
+                name: name.clone(),
+                teacher: cap_course[1].to_string(),
+                location: cap_course[3].to_string(),
+                weekday: day + 1,
+                start_slot: start + 1,
+                end_slot: end + 1,
+                weeks: weeks.clone(),
+            });
+            i += 1;
         }
-        ret.push(CourseData {
-            id: cap_course[1].to_string(),
-            teacher: cap_course[2].to_string(),
-            name_with_course_id: cap_course[3].to_string(),
-            classroom: cap_course[4].to_string(),
-            weeks,
-            time,
-        })
     }
     ret
 }
 
+// Parse the semesters from the response of dataQuery.action, which is a javascript object like
+// {yearDom:"...",termDom:"...",semesters:{y0:[{id:1,schoolYear:"2009-2010",name:"1"},...],...},yearIndex:"13",...}
+//
+// The *Dom fields contain HTML with colons and quotes in it, so we cannot simply normalize it into JSON.
+fn parse_semesters(text: &str) -> Vec<Semester> {
+    let regex = Regex::new(r##"\{id:(\d+),schoolYear:"([^"]*)",name:"([^"]*)"\}"##).unwrap();
+    regex.captures_iter(text).map(|cap| Semester {
+        id: cap[1].to_string(),
+        school_year: cap[2].to_string(),
+        name: cap[3].to_string(),
+    }).collect()
+}
+
+// The calendar data is like
+// {"semesters":[{"id":443,"schoolYear":"2023-2024","name":"1","startDate":"2023-09-11","endDate":"2024-01-14"}],
+//  "holidays":[{"date":"2023-09-29","name":"中秋节"}],"workdays":[{"date":"2023-10-07","weekday":4}]}
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RawCalendar {
+    semesters: Vec<RawCalendarSemester>,
+    #[serde(default)]
+    holidays: Vec<RawHoliday>,
+    #[serde(default)]
+    workdays: Vec<RawWorkday>,
+}
+
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RawCalendarSemester {
+    id: i64,
+    school_year: String,
+    name: String,
+    start_date: String,
+    end_date: String,
+}
+
+#[derive(Deserialize)]
+struct RawHoliday {
+    date: String,
+    #[serde(default)]
+    name: String,
+}
+
+#[derive(Deserialize)]
+struct RawWorkday {
+    date: String,
+    weekday: i32,
+}
+
+fn check_date(date: &str) -> Result<NaiveDate> {
+    NaiveDate::parse_from_str(date, "%Y-%m-%d")
+        .map_err(|_| SDKError::with_type(ErrorType::ParseError, format!("invalid date {:?} in the calendar", date)))
+}
+
+fn parse_calendar(text: &str) -> Result<AcademicCalendar> {
+    let raw: RawCalendar = serde_json::from_str(text)?;
+    let mut semesters = Vec::new();
+    for semester in raw.semesters {
+        if check_date(&semester.start_date)? > check_date(&semester.end_date)? {
+            Err(SDKError::with_type(ErrorType::ParseError, format!("semester {} ends before it starts", semester.id)))?
+        }
+        semesters.push(CalendarSemester {
+            id: semester.id.to_string(),
+            school_year: semester.school_year,
+            name: semester.name,
+            start: semester.start_date,
+            end: semester.end_date,
+        });
+    }
+    let mut holidays = Vec::new();
+    for holiday in raw.holidays {
+        check_date(&holiday.date)?;
+        holidays.push(Holiday { date: holiday.date, name: holiday.name });
+    }
+    let mut adjustments = Vec::new();
+    for workday in raw.workdays {
+        check_date(&workday.date)?;
+        if !(1..=7).contains(&workday.weekday) {
+            Err(SDKError::with_type(ErrorType::ParseError, format!("invalid weekday {} on {}", workday.weekday, workday.date)))?
+        }
+        adjustments.push(DayAdjustment { date: workday.date, acts_as_weekday: workday.weekday });
+    }
+    Ok(AcademicCalendar { semesters, holidays, adjustments })
+}
+
+// The student details page has a table of cells like <td class="title">姓名：</td><td>张三</td>.
+fn parse_profile(html: &str) -> Result<Profile> {
+    let document = Html::parse_document(html);
+    let selector = Selector::parse("td").unwrap();
+    let cells: Vec<String> = document.select(&selector).map(|td| td.text().collect::<String>().trim().to_string()).collect();
+    let field = |label: &str| {
+        cells.iter().position(|cell| cell.trim_end_matches(['：', ':']) == label)
+            .and_then(|i| cells.get(i + 1)).cloned().unwrap_or_default()
+    };
+    let profile = Profile {
+        name: field("姓名"),
+        student_id: field("学号"),
+        department: field("院系"),
+        student_type: StudentType::Undergraduate,
+    };
+    if profile.student_id.is_empty() {
+        Err(SDKError::with_type(ErrorType::ParseError, "student id not found in student details".to_string()))?
+    }
+    Ok(profile)
+}
+
 pub trait JwfwClient: Account {
-    fn get_jwfw_homepage(&self) -> reqwest::Result<String> {
-        let client = self.get_client();
-        let mut html = client.get(JWFW_URL).send()?.text()?;
+    fn get_jwfw_homepage(&self) -> Result<String> {
+        let mut html = self.send(self.get(JWFW_URL))?.text()?;
         let document = Html::parse_document(html.as_str());
         let selector = Selector::parse(r#"html > body > a"#).unwrap();
         for element in document.select(&selector) {
             if element.inner_html().as_str() == "点击此处" {
                 let href = element.value().attr("href");
                 if let Some(key) = href {
-                    html = client.get(key.to_string()).send()?.text()?
+                    html = self.send(self.get(key))?.text()?
                 }
             }
         }
         Ok(html)
     }
 
-    fn get_course_table(&self) -> reqwest::Result<Vec<CourseData>> {
-        let client = self.get_client();
+    fn get_semesters(&self) -> Result<Vec<Semester>> {
+        // The page sets the semester.calendar cookie we need
+        self.send(self.get(JWFW_COURSE_TABLE_MAIN_URL))?;
+
+        let mut payload = HashMap::new();
+        payload.insert("tagId", "semesterBar");
+        payload.insert("dataType", "semesterCalendar");
+        payload.insert("empty", "false");
+        let text = self.send(self.post(JWFW_DATA_QUERY_URL).form(&payload))?.text()?;
+        let semesters = parse_semesters(&text);
+        if semesters.is_empty() {
+            return Err(SDKError::with_type(ErrorType::ParseError, "no semester found".to_string()));
+        }
+        Ok(semesters)
+    }
 
+    fn get_course_table(&self, semester_id: &str) -> Result<Vec<CourseData>> {
         // First visit the courseTableForStd.action to get ids(a value related to student id)
-        let main_html = client.get(JWFW_COURSE_TABLE_MAIN_URL).send()?.text()?;
-        let ids = parse_ids(&main_html);
+        let main_html = self.send(self.get(JWFW_COURSE_TABLE_MAIN_URL))?.text()?;
+        let ids = parse_ids(&main_html)?;
 
         let mut payload = HashMap::new();
         payload.insert("ignoreHead", "1");
         payload.insert("setting.kind", "std");
         payload.insert("startWeek", "1");
         payload.insert("project.id", "1");
-        payload.insert("semester.id", "385");
+        payload.insert("semester.id", semester_id);
         payload.insert("ids", ids.as_str());
-        let query_html = client.post(JWFW_COURSE_TABLE_QUERY_URL).form(&payload).send()?.text()?;
-        let course_data = parse_course_data(&query_html);
-        println!("{:#?}", course_data);
-        Ok(course_data)
+        let query_html = self.send(self.post(JWFW_COURSE_TABLE_QUERY_URL).form(&payload))?.text()?;
+        Ok(parse_course_data(&query_html))
+    }
+
+    fn get_exams(&self, semester_id: &str) -> Result<Vec<Exam>> {
+        let html = self.send_and_get_text(
+            self.get(JWFW_EXAM_TABLE_URL).query(&[("semester.id", semester_id)])
+        )?;
+        Ok(parse_exams(&html))
+    }
+
+    fn get_scores(&self, semester_id: &str) -> Result<Vec<Score>> {
+        let html = self.send_and_get_text(
+            self.get(JWFW_SCORE_URL).query(&[("semesterId", semester_id)])
+        )?;
+        parse_scores(&html)
+    }
+
+    // Return the classrooms of `campus` (one of handan, jiangwan, fenglin and zhangjiang) which are free
+    // on `date` (like 2023-01-03) in any slot from `start_slot` to `end_slot`, both counted from 1 and inclusive.
+    //
+    // Slots are queried one by one, so that rooms free in only some of them are reported with those slots.
+    fn get_empty_classrooms(&self, campus: &str, date: &str, start_slot: i32, end_slot: i32) -> Result<Vec<ClassroomBuilding>> {
+        let campus_id = campus_id(campus)?;
+        if start_slot < 1 || start_slot > end_slot {
+            Err(SDKError::with_type(ErrorType::ArgumentError, format!("invalid slots {}-{}", start_slot, end_slot)))?
+        }
+
+        let mut free_by_slot = Vec::new();
+        for slot in start_slot..=end_slot {
+            let slot_str = slot.to_string();
+            let mut payload = HashMap::new();
+            payload.insert("classroom.campus.id", campus_id);
+            payload.insert("cycleTime.cycleCount", "1");
+            payload.insert("cycleTime.cycleType", "1");
+            payload.insert("cycleTime.dateBegin", date);
+            payload.insert("cycleTime.dateEnd", date);
+            payload.insert("roomApplyTimeType", "0");
+            payload.insert("timeBegin", slot_str.as_str());
+            payload.insert("timeEnd", slot_str.as_str());
+            payload.insert("pageSize", "1000");
+            let html = self.send_and_get_text(self.post(JWFW_FREE_CLASSROOM_URL).form(&payload))?;
+            free_by_slot.push((slot, parse_free_classrooms(&html)));
+        }
+        Ok(merge_free_classrooms(free_by_slot))
+    }
+
+    fn get_academic_calendar(&self) -> Result<AcademicCalendar> {
+        let text = self.send_and_get_text(self.get(JWFW_CALENDAR_URL))?;
+        parse_calendar(&text)
+    }
+
+    fn get_gpa(&self) -> Result<GPA> {
+        let html = self.send_and_get_text(self.get(JWFW_GPA_URL))?;
+        parse_gpa(&html)
+    }
+
+    fn get_profile(&self) -> Result<Profile> {
+        let html = self.send_and_get_text(self.get(JWFW_STD_DETAIL_URL))?;
+        parse_profile(&html)
+    }
+
+    // Return the training plan of the student, with the credits earned in each category, see `audit`.
+    fn get_plan_audit(&self) -> Result<AuditCategory> {
+        let html = self.send_and_get_text(self.get(JWFW_PLAN_AUDIT_URL))?;
+        parse_plan_audit(&html)
     }
 }
 
@@ -149,7 +565,130 @@ mod tests {
         let mut fd = Fdu::new();
         fd.login(uid.as_str(), pwd.as_str()).expect("login error");
         fd.get_jwfw_homepage().expect("jwfw error");
-        fd.get_course_table().expect("jwfw course table error");
+        let semesters = fd.get_semesters().expect("jwfw semesters error");
+        let course_table = fd.get_course_table(&semesters.last().unwrap().id).expect("jwfw course table error");
+        println!("{:#?}", course_table);
         fd.logout().expect("logout error");
     }
+
+    #[test]
+    fn test_parse_course_data() {
+        let html = r#"
+activity = new TaskActivity("155165","陈彤兵","42071(COMP130004.03)","数据结构(COMP130004.03)","320","HGX304","01010101010101010000000000000000000000000000000000000");
+index =2*unitCount+0;
+table0.activities[index][table0.activities[index].length]=activity;
+index =2*unitCount+1;
+table0.activities[index][table0.activities[index].length]=activity;
+activity = new TaskActivity("155166","","42072(PEDU110001.01)","体育(PEDU110001.01)","","","00000000000100000000000000000000000000000000000000000");
+index =4*unitCount+9;
+table0.activities[index][table0.activities[index].length]=activity;
+"#.to_string();
+        assert_eq!(parse_course_data(&html), vec![
+            CourseData {
+                course_id: "COMP130004.03".to_string(),
+                name: "数据结构".to_string(),
+                teacher: "陈彤兵".to_string(),
+                location: "HGX304".to_string(),
+                weekday: 3,
+                start_slot: 1,
+                end_slot: 2,
+                weeks: vec![1, 3, 5, 7, 9, 11, 13, 15],
+            },
+            CourseData {
+                course_id: "PEDU110001.01".to_string(),
+                name: "体育".to_string(),
+                teacher: "".to_string(),
+                location: "".to_string(),
+                weekday: 5,
+                start_slot: 10,
+                end_slot: 10,
+                weeks: vec![11],
+            },
+        ]);
+    }
+
+    #[test]
+    fn test_parse_semesters() {
+        let text = r#"{yearDom:"<tr><td class='calendar-bar-td-blankBorder' index='0'>2009-2010</td></tr>",semesters:{y0:[{id:1,schoolYear:"2009-2010",name:"1"},{id:2,schoolYear:"2009-2010",name:"2"}]},yearIndex:"0",termIndex:"1",semesterId:"2"}"#;
+        assert_eq!(parse_semesters(text), vec![
+            Semester { id: "1".to_string(), school_year: "2009-2010".to_string(), name: "1".to_string() },
+            Semester { id: "2".to_string(), school_year: "2009-2010".to_string(), name: "2".to_string() },
+        ]);
+    }
+
+    #[test]
+    fn test_parse_calendar() {
+        let text = r#"{"semesters":[{"id":443,"schoolYear":"2023-2024","name":"1","startDate":"2023-09-11","endDate":"2024-01-14"}],
+            "holidays":[{"date":"2023-09-29","name":"中秋节"}],"workdays":[{"date":"2023-10-07","weekday":4}]}"#;
+        assert_eq!(parse_calendar(text).unwrap(), AcademicCalendar {
+            semesters: vec![CalendarSemester {
+                id: "443".to_string(),
+                school_year: "2023-2024".to_string(),
+                name: "1".to_string(),
+                start: "2023-09-11".to_string(),
+                end: "2024-01-14".to_string(),
+            }],
+            holidays: vec![Holiday { date: "2023-09-29".to_string(), name: "中秋节".to_string() }],
+            adjustments: vec![DayAdjustment { date: "2023-10-07".to_string(), acts_as_weekday: 4 }],
+        });
+
+        for text in [
+            r#"{"semesters":[{"id":1,"schoolYear":"","name":"1","startDate":"2024-01-14","endDate":"2023-09-11"}]}"#,
+            r#"{"semesters":[],"holidays":[{"date":"2023-02-30"}]}"#,
+            r#"{"semesters":[],"workdays":[{"date":"2023-10-07","weekday":0}]}"#,
+        ] {
+            assert!(parse_calendar(text).is_err(), "{}", text);
+        }
+    }
+
+    #[test]
+    fn test_parse_scores() {
+        let html = r#"<table><thead><tr><th>学年学期</th></tr></thead><tbody>
+<tr><td>2022-2023 1</td><td>COMP130004</td><td>COMP130004.03</td><td>数据结构</td><td>专业必修</td><td>3</td><td>A-</td><td>3.7</td></tr>
+<tr><td>2022-2023 1</td><td>PEDU110001</td><td>PEDU110001.01</td><td>体育</td><td>体育</td><td>1</td><td>P</td><td></td></tr>
+</tbody></table>"#;
+        let scores = parse_scores(html).unwrap();
+        assert_eq!(scores.len(), 2);
+        assert_eq!(scores[0].point, Some(3.7));
+        assert_eq!(scores[1].grade, "P");
+        assert_eq!(scores[1].point, None);
+    }
+
+    #[test]
+    fn test_parse_profile() {
+        assert_eq!(parse_profile(include_str!("testdata/jwfw_profile.html")).unwrap(), Profile {
+            name: "张三".to_string(),
+            student_id: "20300000001".to_string(),
+            department: "计算机科学技术学院".to_string(),
+            student_type: StudentType::Undergraduate,
+        });
+        assert!(parse_profile("<table><tr><td>登录</td></tr></table>").is_err());
+    }
+
+    #[test]
+    fn test_merge_free_classrooms() {
+        let room = |building: &str, name: &str| (building.to_string(), name.to_string(), 60);
+        let buildings = merge_free_classrooms(vec![
+            (3, vec![room("HGX", "HGX101"), room("H2", "H2101"), room("HGX", "HGX102")]),
+            (4, vec![room("HGX", "HGX101"), room("H2", "H2102")]),
+        ]);
+        assert_eq!(buildings, vec![
+            ClassroomBuilding {
+                name: "HGX".to_string(),
+                rooms: vec![
+                    Classroom { name: "HGX101".to_string(), capacity: 60, free_slots: vec![3, 4] },
+                    Classroom { name: "HGX102".to_string(), capacity: 60, free_slots: vec![3] },
+                ],
+            },
+            ClassroomBuilding {
+                name: "H2".to_string(),
+                rooms: vec![
+                    Classroom { name: "H2101".to_string(), capacity: 60, free_slots: vec![3] },
+                    Classroom { name: "H2102".to_string(), capacity: 60, free_slots: vec![4] },
+                ],
+            },
+        ]);
+        assert!(campus_id("handan").is_ok());
+        assert!(campus_id("邯郸").is_err());
+    }
 }
@@ -0,0 +1,421 @@
+use chrono::NaiveDate;
+use serde::{Deserialize, Serialize};
+
+use super::prelude::*;
+
+impl LibraryClient for Fdu {}
+
+// Visiting it logs in the seat system through UIS.
+const LIBRARY_LOGIN_URL: &str = "https://seat.lib.fudan.edu.cn/cas/index.php";
+const LIBRARY_AREAS_URL: &str = "https://seat.lib.fudan.edu.cn/api.php/areas";
+const LIBRARY_SEATS_URL: &str = "https://seat.lib.fudan.edu.cn/api.php/spaces_old";
+// The loans are in the personal library, which has a separate UIS login.
+const MYLIB_LOGIN_URL: &str = "https://mylib.fudan.edu.cn/cas/login";
+const BORROW_HISTORY_URL: &str = "https://mylib.fudan.edu.cn/api/loan/history";
+const BORROW_HISTORY_PAGE_SIZE: u32 = 20;
+// The current loans and their renewal are in the OPAC (馆藏目录), which has another UIS login: UIS sends a ticket to
+// its CAS callback, which redirects to the OPAC.
+const OPAC_LOGIN_URL: &str = "https://uis.fudan.edu.cn/authserver/login?service=https%3A%2F%2Fopac.fudan.edu.cn%2Fcas%2Fcallback";
+const OPAC_URL: &str = "https://opac.fudan.edu.cn/";
+const OPAC_LOANS_URL: &str = "https://opac.fudan.edu.cn/api/reader/loans";
+const OPAC_RENEW_URL: &str = "https://opac.fudan.edu.cn/api/reader/renew";
+// Where UIS sends a session which is not logged in.
+const UIS_LOGIN_URL: &str = "https://uis.fudan.edu.cn/authserver/login";
+// The OPAC answers with the dates of the backend holding the book: the circulation system writes 2024-03-15, the
+// older one 2024年3月15日, and the self-service machines 2024/3/15.
+const OPAC_DATE_FORMATS: &[&str] = &["%Y-%m-%d", "%Y年%m月%d日", "%Y/%m/%d"];
+
+// Status of a seat which is free to book
+const SEAT_STATUS_FREE: i32 = 1;
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct LibraryArea {
+    id: i64,
+    // e.g. 三楼
+    name: String,
+    // e.g. 文科馆
+    building: String,
+    total: i32,
+    free: i32,
+    // The seat system reports no seat for areas under maintenance.
+    closed: bool,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct LibrarySeat {
+    id: i64,
+    // e.g. 001
+    name: String,
+    occupied: bool,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct BorrowRecord {
+    barcode: String,
+    title: String,
+    author: String,
+    // e.g. 2023-10-08
+    borrowed: String,
+    // None if the book is not returned yet.
+    returned: Option<String>,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Loan {
+    barcode: String,
+    title: String,
+    author: String,
+    // e.g. 2024-03-15, whatever the format of the OPAC.
+    due: String,
+    // How many times the loan was renewed.
+    renewals: u32,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+#[serde(rename_all = "snake_case")]
+pub enum RenewOutcome {
+    Renewed,
+    // The loan was renewed as many times as the library allows.
+    MaxRenewals,
+    // Another reader reserved the book.
+    Reserved,
+    // The loan is overdue, and must be returned.
+    Overdue,
+}
+
+// The outcome of a renewal, with the message of the OPAC.
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Renewal {
+    outcome: RenewOutcome,
+    // The new due date if renewed, e.g. 2024-04-15, and empty otherwise.
+    due: String,
+    message: String,
+}
+
+// All responses of the seat system are like {"status":1,"msg":"","data":{"list":[...]}}
+#[derive(Deserialize)]
+struct Response<T> {
+    status: i32,
+    #[serde(default)]
+    msg: String,
+    data: Option<ResponseData<T>>,
+}
+
+#[derive(Deserialize)]
+struct ResponseData<T> {
+    list: Vec<T>,
+}
+
+#[derive(Deserialize)]
+struct RawBuilding {
+    name: String,
+    #[serde(rename = "_child", default)]
+    children: Vec<RawArea>,
+}
+
+#[derive(Deserialize)]
+struct RawArea {
+    id: i64,
+    name: String,
+    #[serde(rename = "TotalCount", default)]
+    total: i32,
+    #[serde(rename = "UnavailableSpace", default)]
+    unavailable: i32,
+}
+
+#[derive(Deserialize)]
+struct RawSeat {
+    id: i64,
+    name: String,
+    status: i32,
+}
+
+fn parse_list<'a, T: Deserialize<'a>>(text: &'a str) -> Result<Vec<T>> {
+    let response: Response<T> = serde_json::from_str(text)?;
+    match response.data {
+        Some(data) if response.status == 1 => Ok(data.list),
+        _ => Err(SDKError::with_type(ErrorType::ParseError, format!("seat system reported an error: {}", response.msg))),
+    }
+}
+
+fn parse_areas(text: &str) -> Result<Vec<LibraryArea>> {
+    let buildings: Vec<RawBuilding> = parse_list(text)?;
+    Ok(buildings.into_iter().flat_map(|building| {
+        let building_name = building.name;
+        building.children.into_iter().map(move |area| LibraryArea {
+            id: area.id,
+            name: area.name,
+            building: building_name.clone(),
+            total: area.total,
+            free: (area.total - area.unavailable).max(0),
+            closed: area.total == 0,
+        })
+    }).collect())
+}
+
+fn parse_seats(text: &str) -> Result<Vec<LibrarySeat>> {
+    let seats: Vec<RawSeat> = parse_list(text)?;
+    Ok(seats.into_iter().map(|seat| LibrarySeat {
+        id: seat.id,
+        name: seat.name,
+        occupied: seat.status != SEAT_STATUS_FREE,
+    }).collect())
+}
+
+// Responses of the personal library are like {"code":0,"msg":"","data":{"total":42,"list":[...]}}
+#[derive(Deserialize)]
+struct MylibResponse {
+    code: i32,
+    #[serde(default)]
+    msg: String,
+    data: Option<MylibData>,
+}
+
+#[derive(Deserialize)]
+struct MylibData {
+    total: u64,
+    list: Vec<RawLoan>,
+}
+
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RawLoan {
+    barcode: String,
+    title: String,
+    #[serde(default)]
+    author: String,
+    loan_date: String,
+    #[serde(default)]
+    return_date: String,
+}
+
+fn parse_borrow_history(text: &str, number: u32) -> Result<Page<BorrowRecord>> {
+    let response: MylibResponse = serde_json::from_str(text)?;
+    let data = match response.data {
+        Some(data) if response.code == 0 => data,
+        _ => return Err(SDKError::with_type(ErrorType::ParseError, format!("library reported an error: {}", response.msg))),
+    };
+    let items = data.list.into_iter().map(|loan| BorrowRecord {
+        barcode: loan.barcode,
+        title: loan.title,
+        author: loan.author,
+        borrowed: loan.loan_date,
+        returned: Some(loan.return_date).filter(|date| !date.is_empty()),
+    }).collect();
+    Ok(Page::numbered(items, number, BORROW_HISTORY_PAGE_SIZE, data.total))
+}
+
+// Responses of the OPAC are like {"code":0,"msg":"","data":...}
+#[derive(Deserialize)]
+struct OpacResponse<T> {
+    code: i32,
+    #[serde(default)]
+    msg: String,
+    data: Option<T>,
+}
+
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RawOpacLoan {
+    barcode: String,
+    title: String,
+    #[serde(default)]
+    author: String,
+    due_date: String,
+    #[serde(default)]
+    renew_times: u32,
+}
+
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RawRenewal {
+    due_date: String,
+}
+
+// Normalize a date of the OPAC, in any of `OPAC_DATE_FORMATS`, to e.g. 2024-03-15.
+fn parse_opac_date(date: &str) -> Result<String> {
+    let date = date.trim();
+    OPAC_DATE_FORMATS.iter()
+        .find_map(|format| NaiveDate::parse_from_str(date, format).ok())
+        .map(|date| date.format("%Y-%m-%d").to_string())
+        .ok_or(SDKError::with_type(ErrorType::ParseError, format!("invalid OPAC date {}", date)))
+}
+
+fn parse_loans(text: &str) -> Result<Vec<Loan>> {
+    let response: OpacResponse<Vec<RawOpacLoan>> = serde_json::from_str(text)?;
+    let loans = match response.data {
+        Some(loans) if response.code == 0 => loans,
+        _ => return Err(SDKError::with_type(ErrorType::ParseError, format!("OPAC reported an error: {}", response.msg))),
+    };
+    loans.into_iter().map(|loan| Ok(Loan {
+        due: parse_opac_date(&loan.due_date)?,
+        barcode: loan.barcode,
+        title: loan.title,
+        author: loan.author,
+        renewals: loan.renew_times,
+    })).collect()
+}
+
+// Parse the answer to a renewal. The OPAC refuses one with a non-zero code and a message like "已达到最大续借次数",
+// which are matched like the messages of xk; other refusals, e.g. of an unknown barcode, are errors.
+fn parse_renewal(text: &str) -> Result<Renewal> {
+    let response: OpacResponse<RawRenewal> = serde_json::from_str(text)?;
+    let message = response.msg;
+    let outcome = match response.data {
+        Some(renewal) if response.code == 0 => {
+            return Ok(Renewal { outcome: RenewOutcome::Renewed, due: parse_opac_date(&renewal.due_date)?, message });
+        }
+        _ if message.contains("续借次数") || message.contains("续借上限") => RenewOutcome::MaxRenewals,
+        _ if message.contains("预约") => RenewOutcome::Reserved,
+        _ if message.contains("超期") || message.contains("逾期") => RenewOutcome::Overdue,
+        _ => Err(SDKError::with_type(ErrorType::OtherError, format!("OPAC: {}", message)))?,
+    };
+    Ok(Renewal { outcome, due: String::new(), message })
+}
+
+pub trait LibraryClient: Account {
+    // Log in the OPAC, through the CAS callback which UIS redirects to.
+    fn opac_login(&self) -> Result<()> {
+        let res = self.execute(self.get(OPAC_LOGIN_URL).build()?)?;
+        let url = res.url().as_str();
+        if url.starts_with(&base_url::resolve(UIS_LOGIN_URL)) {
+            return Err(SDKError::with_type(ErrorType::LoginError, "not logged in".to_string()));
+        }
+        if !url.starts_with(&base_url::resolve(OPAC_URL)) {
+            return Err(SDKError::with_type(ErrorType::LoginError, format!("OPAC login stopped at {}", url)));
+        }
+        Ok(())
+    }
+
+    // Return the books borrowed and not returned yet.
+    fn get_loans(&self) -> Result<Vec<Loan>> {
+        self.opac_login()?;
+        let text = self.send_and_get_text(self.get(OPAC_LOANS_URL))?;
+        parse_loans(&text)
+    }
+
+    // Renew the loan of the book with `barcode`.
+    fn renew(&self, barcode: &str) -> Result<Renewal> {
+        self.opac_login()?;
+        let text = self.send_and_get_text(self.post(OPAC_RENEW_URL).form(&[("barcode", barcode)]))?;
+        parse_renewal(&text)
+    }
+
+    fn get_library_areas(&self) -> Result<Vec<LibraryArea>> {
+        self.send_and_get_text(self.get(LIBRARY_LOGIN_URL))?;
+        let text = self.send_and_get_text(self.get(LIBRARY_AREAS_URL).query(&[("tree", "1")]))?;
+        parse_areas(&text)
+    }
+
+    // Return the seats of an area today.
+    fn get_library_seats(&self, area_id: i64) -> Result<Vec<LibrarySeat>> {
+        self.send_and_get_text(self.get(LIBRARY_LOGIN_URL))?;
+        let today = chrono::Local::now().format("%Y-%m-%d").to_string();
+        let text = self.send_and_get_text(
+            self.get(LIBRARY_SEATS_URL).query(&[("area", area_id.to_string()), ("day", today)])
+        )?;
+        parse_seats(&text)
+    }
+
+    // Return a page of the books borrowed, the most recent first, starting with an empty page token.
+    fn get_borrow_history_page(&self, page_token: &str) -> Result<Page<BorrowRecord>> {
+        let number = page_number(page_token)?;
+        self.send_and_get_text(self.get(MYLIB_LOGIN_URL))?;
+        let text = self.send_and_get_text(self.get(BORROW_HISTORY_URL).query(&[
+            ("page", number.to_string()),
+            ("size", BORROW_HISTORY_PAGE_SIZE.to_string()),
+        ]))?;
+        parse_borrow_history(&text, number)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_areas() {
+        let text = r#"{"status":1,"msg":"","data":{"list":[{"id":1,"name":"文科馆","_child":[
+            {"id":5,"name":"三楼","TotalCount":120,"UnavailableSpace":30},
+            {"id":6,"name":"四楼","TotalCount":0,"UnavailableSpace":0}]}]}}"#;
+        assert_eq!(parse_areas(text).unwrap(), vec![
+            LibraryArea { id: 5, name: "三楼".to_string(), building: "文科馆".to_string(), total: 120, free: 90, closed: false },
+            LibraryArea { id: 6, name: "四楼".to_string(), building: "文科馆".to_string(), total: 0, free: 0, closed: true },
+        ]);
+        assert!(parse_areas(r#"{"status":0,"msg":"未登录","data":null}"#).is_err());
+    }
+
+    #[test]
+    fn test_parse_seats() {
+        let text = r#"{"status":1,"data":{"list":[{"id":101,"no":"001","name":"001","status":1},{"id":102,"no":"002","name":"002","status":6}]}}"#;
+        assert_eq!(parse_seats(text).unwrap(), vec![
+            LibrarySeat { id: 101, name: "001".to_string(), occupied: false },
+            LibrarySeat { id: 102, name: "002".to_string(), occupied: true },
+        ]);
+    }
+
+    #[test]
+    fn test_parse_borrow_history() {
+        let text = r#"{"code":0,"msg":"","data":{"total":21,"list":[
+            {"barcode":"0001","title":"数学分析","author":"陈纪修","loanDate":"2023-10-08","returnDate":"2023-11-02"},
+            {"barcode":"0002","title":"线性代数","loanDate":"2023-10-09","returnDate":""}]}}"#;
+        let page = parse_borrow_history(text, 1).unwrap();
+        assert_eq!(page.items, vec![
+            BorrowRecord {
+                barcode: "0001".to_string(),
+                title: "数学分析".to_string(),
+                author: "陈纪修".to_string(),
+                borrowed: "2023-10-08".to_string(),
+                returned: Some("2023-11-02".to_string()),
+            },
+            BorrowRecord {
+                barcode: "0002".to_string(),
+                title: "线性代数".to_string(),
+                author: String::new(),
+                borrowed: "2023-10-09".to_string(),
+                returned: None,
+            },
+        ]);
+        assert_eq!(page.next, "2");
+        assert_eq!(parse_borrow_history(text, 2).unwrap().next, "");
+        assert!(parse_borrow_history(r#"{"code":401,"msg":"未登录","data":null}"#, 1).is_err());
+    }
+
+    #[test]
+    fn test_parse_opac_date() {
+        for date in ["2024-03-15", "2024年3月15日", "2024年03月15日", "2024/3/15", " 2024-03-15 "] {
+            assert_eq!(parse_opac_date(date).unwrap(), "2024-03-15", "{}", date);
+        }
+        for date in ["", "3月15日", "2024-02-30", "15/03/2024"] {
+            assert!(parse_opac_date(date).is_err(), "{}", date);
+        }
+    }
+
+    #[test]
+    fn test_parse_loans() {
+        let text = r#"{"code":0,"msg":"","data":[
+            {"barcode":"0001","title":"数学分析","author":"陈纪修","dueDate":"2024-03-15","renewTimes":1},
+            {"barcode":"0002","title":"线性代数","dueDate":"2024年4月2日"}]}"#;
+        assert_eq!(parse_loans(text).unwrap(), vec![
+            Loan { barcode: "0001".to_string(), title: "数学分析".to_string(), author: "陈纪修".to_string(), due: "2024-03-15".to_string(), renewals: 1 },
+            Loan { barcode: "0002".to_string(), title: "线性代数".to_string(), author: String::new(), due: "2024-04-02".to_string(), renewals: 0 },
+        ]);
+        assert!(parse_loans(r#"{"code":0,"data":[{"barcode":"0001","title":"数学分析","dueDate":"下周"}]}"#).is_err());
+        assert!(parse_loans(r#"{"code":401,"msg":"未登录","data":null}"#).is_err());
+    }
+
+    #[test]
+    fn test_parse_renewal() {
+        assert_eq!(parse_renewal(r#"{"code":0,"msg":"续借成功","data":{"dueDate":"2024年4月15日"}}"#).unwrap(), Renewal {
+            outcome: RenewOutcome::Renewed,
+            due: "2024-04-15".to_string(),
+            message: "续借成功".to_string(),
+        });
+        let outcome = |text| parse_renewal(text).unwrap().outcome;
+        assert_eq!(outcome(r#"{"code":1,"msg":"已达到最大续借次数","data":null}"#), RenewOutcome::MaxRenewals);
+        assert_eq!(outcome(r#"{"code":1,"msg":"该书已被他人预约，不能续借"}"#), RenewOutcome::Reserved);
+        assert_eq!(outcome(r#"{"code":1,"msg":"图书已超期，请先归还"}"#), RenewOutcome::Overdue);
+        let e = parse_renewal(r#"{"code":1,"msg":"条码不存在"}"#).unwrap_err();
+        assert!(matches!(e.error_type(), ErrorType::OtherError));
+    }
+}
@@ -1,8 +1,23 @@
+pub mod announcement;
+pub mod audit;
+pub mod base_url;
+pub mod config;
 pub mod fdu;
 pub mod fdu_daily;
 pub mod prelude;
 pub mod jwfw;
 pub mod ecard;
 pub mod grade;
+pub mod library;
 pub mod myfdu;
+pub mod page;
+pub mod pe;
+pub mod persist;
+pub mod probe;
+pub mod ratelimit;
+pub mod raw;
+pub mod record;
+pub mod tls;
+pub mod trace;
 pub mod xk;
+pub mod yjsxt;
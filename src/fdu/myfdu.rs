@@ -22,8 +22,7 @@ impl MyFduClient for Fdu {}
 
 pub trait MyFduClient: Account {
     fn get_myfdu_course_grade(&self) -> reqwest::Result<Vec<GradeData>> {
-        let client = self.get_client();
-        let html = client.get(COURSE_GRADE_URL).send()?.text()?;
+        let html = self.send(self.get(COURSE_GRADE_URL))?.text()?;
         let document = Html::parse_document(html.as_str());
         let selector = Selector::parse("#dataTable_BksXxCj>tbody>tr").unwrap();
         let mut grade_data: Vec<GradeData> = Vec::new();
@@ -0,0 +1,56 @@
+// Listings too long to fetch at once are returned one page at a time.
+//
+// The token of a page carries everything needed to fetch it (for now a page number), so that no cursor is kept
+// between calls: a caller which stops early has nothing to release.
+use serde::Serialize;
+
+use super::prelude::*;
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Page<T> {
+    pub items: Vec<T>,
+    // The token of the next page, empty after the last one.
+    pub next: String,
+}
+
+impl<T> Page<T> {
+    // The page `number` of a listing of `total` items in pages of `size` items.
+    pub fn numbered(items: Vec<T>, number: u32, size: u32, total: u64) -> Page<T> {
+        let fetched = number as u64 * size as u64;
+        let next = if items.is_empty() || fetched >= total { String::new() } else { (number + 1).to_string() };
+        Page { items, next }
+    }
+}
+
+// Return the page number of a token of `Page::numbered()`, the first one for an empty token.
+pub fn page_number(token: &str) -> Result<u32> {
+    if token.is_empty() {
+        return Ok(1);
+    }
+    match token.parse::<u32>() {
+        Ok(number) if number > 0 => Ok(number),
+        _ => Err(SDKError::with_type(ErrorType::ArgumentError, format!("invalid page token {:?}", token))),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_numbered() {
+        assert_eq!(Page::numbered(vec![1, 2], 1, 2, 5).next, "2");
+        assert_eq!(Page::numbered(vec![5], 3, 2, 5).next, "");
+        assert_eq!(Page::numbered(vec![3, 4], 2, 2, 4).next, "");
+        assert_eq!(Page::<i32>::numbered(vec![], 4, 2, 100).next, "");
+    }
+
+    #[test]
+    fn test_page_number() {
+        assert_eq!(page_number("").unwrap(), 1);
+        assert_eq!(page_number("3").unwrap(), 3);
+        for token in ["0", "-1", "x"] {
+            assert!(matches!(page_number(token).unwrap_err().error_type(), ErrorType::ArgumentError));
+        }
+    }
+}
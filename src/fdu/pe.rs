@@ -0,0 +1,277 @@
+use regex::Regex;
+use serde::{Deserialize, Serialize};
+
+use super::prelude::*;
+
+impl PEClient for Fdu {}
+
+// The PE system (体育部) has its own login: UIS sends a ticket to its SSO callback, which answers with a page
+// redirecting by script to the app, which in turn sets the session of the PE system.
+const PE_LOGIN_URL: &str = "https://uis.fudan.edu.cn/authserver/login?service=https%3A%2F%2Ftyb.fudan.edu.cn%2Fsso%2Fcallback";
+const PE_URL: &str = "https://tyb.fudan.edu.cn/";
+// The pages of the SSO, which redirect further; the login is done once out of them.
+const PE_SSO_URL: &str = "https://tyb.fudan.edu.cn/sso/";
+const PE_RECORDS_URL: &str = "https://tyb.fudan.edu.cn/api/exercise/records";
+const PE_TEST_SCORES_URL: &str = "https://tyb.fudan.edu.cn/api/fitness/scores";
+// Where UIS sends a session which is not logged in.
+const UIS_LOGIN_URL: &str = "https://uis.fudan.edu.cn/authserver/login";
+// How many script redirects are followed before giving up on the login.
+const PE_MAX_REDIRECTS: usize = 5;
+
+// Kinds of check-in of the PE system
+const CHECK_IN_MORNING: i32 = 1;
+const CHECK_IN_GYM: i32 = 2;
+// Status of a student exempted from the fitness test
+const FITNESS_EXEMPT: &str = "免测";
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct CheckIn {
+    // One of "morning" (早锻炼), "gym" (场馆) or "other".
+    kind: String,
+    // e.g. 江湾体育馆
+    place: String,
+    // e.g. 2023-10-09 07:12:33
+    time: String,
+    // Invalid check-ins, e.g. too short ones, are listed but not counted.
+    valid: bool,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct PERecords {
+    // The valid check-ins of each kind this semester, as counted by the PE system.
+    morning: u32,
+    gym: u32,
+    check_ins: Vec<CheckIn>,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct FitnessItem {
+    // e.g. 50米跑
+    name: String,
+    // The measure, e.g. 7.2, in `unit`, e.g. 秒.
+    result: String,
+    unit: String,
+    score: f64,
+    // e.g. 良好
+    level: String,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct FitnessScores {
+    // The school year of the test, e.g. 2023
+    year: String,
+    // Exempted students (免测) have no total and no item.
+    exempt: bool,
+    total: Option<f64>,
+    level: String,
+    items: Vec<FitnessItem>,
+}
+
+// Responses of the PE system are like {"code":0,"msg":"","data":{...}}
+#[derive(Deserialize)]
+struct PEResponse<T> {
+    code: i32,
+    #[serde(default)]
+    msg: String,
+    data: Option<T>,
+}
+
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RawRecords {
+    morning_count: u32,
+    gym_count: u32,
+    #[serde(default)]
+    list: Vec<RawCheckIn>,
+}
+
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RawCheckIn {
+    #[serde(rename = "type")]
+    kind: i32,
+    #[serde(default)]
+    place: String,
+    punch_time: String,
+    valid: bool,
+}
+
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RawFitness {
+    year: String,
+    status: String,
+    total_score: Option<f64>,
+    #[serde(default)]
+    level: String,
+    #[serde(default)]
+    items: Vec<RawFitnessItem>,
+}
+
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RawFitnessItem {
+    item_name: String,
+    result: String,
+    #[serde(default)]
+    unit: String,
+    score: f64,
+    #[serde(default)]
+    level: String,
+}
+
+fn parse_response<'a, T: Deserialize<'a>>(text: &'a str) -> Result<T> {
+    let response: PEResponse<T> = serde_json::from_str(text)?;
+    match response.data {
+        Some(data) if response.code == 0 => Ok(data),
+        _ => Err(SDKError::with_type(ErrorType::ParseError, format!("PE system reported an error: {}", response.msg))),
+    }
+}
+
+// Return where a page redirects to by script or meta refresh, if it does.
+fn page_redirect(html: &str) -> Option<String> {
+    let regex = Regex::new(r#"(?i)(?:location(?:\.href)?\s*=\s*|http-equiv="refresh"\s+content="\d+;\s*url=)["']?([^"';]+)"#).unwrap();
+    regex.captures(html).map(|captures| captures[1].to_string())
+}
+
+fn parse_records(text: &str) -> Result<PERecords> {
+    let raw: RawRecords = parse_response(text)?;
+    Ok(PERecords {
+        morning: raw.morning_count,
+        gym: raw.gym_count,
+        check_ins: raw.list.into_iter().map(|check_in| CheckIn {
+            kind: match check_in.kind {
+                CHECK_IN_MORNING => "morning",
+                CHECK_IN_GYM => "gym",
+                _ => "other",
+            }.to_string(),
+            place: check_in.place,
+            time: check_in.punch_time,
+            valid: check_in.valid,
+        }).collect(),
+    })
+}
+
+fn parse_fitness_scores(text: &str) -> Result<FitnessScores> {
+    let raw: RawFitness = parse_response(text)?;
+    if raw.status == FITNESS_EXEMPT {
+        return Ok(FitnessScores { year: raw.year, exempt: true, total: None, level: String::new(), items: Vec::new() });
+    }
+    Ok(FitnessScores {
+        year: raw.year,
+        exempt: false,
+        total: raw.total_score,
+        level: raw.level,
+        items: raw.items.into_iter().map(|item| FitnessItem {
+            name: item.item_name,
+            result: item.result,
+            unit: item.unit,
+            score: item.score,
+            level: item.level,
+        }).collect(),
+    })
+}
+
+pub trait PEClient: Account {
+    // Log in the PE system, following the redirects of its SSO up to the app.
+    fn pe_login(&self) -> Result<()> {
+        let (uis_login_url, pe_url, pe_sso_url) =
+            (base_url::resolve(UIS_LOGIN_URL), base_url::resolve(PE_URL), base_url::resolve(PE_SSO_URL));
+        let mut res = self.execute(self.get(PE_LOGIN_URL).build()?)?;
+        for _ in 0..PE_MAX_REDIRECTS {
+            let url = res.url().clone();
+            if url.as_str().starts_with(&uis_login_url) {
+                return Err(SDKError::with_type(ErrorType::LoginError, "not logged in".to_string()));
+            }
+            if url.as_str().starts_with(&pe_url) && !url.as_str().starts_with(&pe_sso_url) {
+                return Ok(());
+            }
+            let next = match page_redirect(&res.text()?) {
+                Some(next) => url.join(&next).map_err(|e| SDKError::with_type(ErrorType::ParseError, e.to_string()))?,
+                None => return Err(SDKError::with_type(ErrorType::LoginError, format!("PE login stopped at {}", url))),
+            };
+            log::debug!("PE login redirected to {}", next);
+            res = self.execute(self.get(next.as_str()).build()?)?;
+        }
+        Err(SDKError::with_type(ErrorType::LoginError, "PE login redirected too many times".to_string()))
+    }
+
+    // Return the check-ins of this semester.
+    fn get_pe_records(&self) -> Result<PERecords> {
+        self.pe_login()?;
+        let text = self.send_and_get_text(self.get(PE_RECORDS_URL))?;
+        parse_records(&text)
+    }
+
+    // Return the scores of the latest fitness test.
+    fn get_fitness_scores(&self) -> Result<FitnessScores> {
+        self.pe_login()?;
+        let text = self.send_and_get_text(self.get(PE_TEST_SCORES_URL))?;
+        parse_fitness_scores(&text)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_page_redirect() {
+        assert_eq!(page_redirect(r#"<script>window.location.href = "/app/#/home?token=abc";</script>"#),
+                   Some("/app/#/home?token=abc".to_string()));
+        assert_eq!(page_redirect(r#"<meta http-equiv="refresh" content="0; url=https://tyb.fudan.edu.cn/app/">"#),
+                   Some("https://tyb.fudan.edu.cn/app/".to_string()));
+        assert_eq!(page_redirect("<html><body>体育部</body></html>"), None);
+    }
+
+    #[test]
+    fn test_parse_records() {
+        let text = r#"{"code":0,"msg":"","data":{"morningCount":1,"gymCount":1,"list":[
+            {"type":1,"place":"江湾体育馆","punchTime":"2023-10-09 07:12:33","valid":true},
+            {"type":2,"place":"正大体育馆","punchTime":"2023-10-10 19:30:00","valid":true},
+            {"type":2,"place":"正大体育馆","punchTime":"2023-10-11 19:30:00","valid":false},
+            {"type":9,"punchTime":"2023-10-12 08:00:00","valid":true}]}}"#;
+        let records = parse_records(text).unwrap();
+        assert_eq!((records.morning, records.gym), (1, 1));
+        assert_eq!(records.check_ins[0], CheckIn {
+            kind: "morning".to_string(),
+            place: "江湾体育馆".to_string(),
+            time: "2023-10-09 07:12:33".to_string(),
+            valid: true,
+        });
+        let kinds: Vec<_> = records.check_ins.iter().map(|check_in| check_in.kind.as_str()).collect();
+        assert_eq!(kinds, vec!["morning", "gym", "gym", "other"]);
+        assert!(!records.check_ins[2].valid);
+        assert!(parse_records(r#"{"code":401,"msg":"未登录","data":null}"#).is_err());
+    }
+
+    #[test]
+    fn test_parse_fitness_scores() {
+        let text = r#"{"code":0,"msg":"","data":{"year":"2023","status":"正常","totalScore":82.5,"level":"良好","items":[
+            {"itemName":"50米跑","result":"7.2","unit":"秒","score":80,"level":"良好"},
+            {"itemName":"坐位体前屈","result":"15.3","unit":"厘米","score":85,"level":"良好"}]}}"#;
+        let scores = parse_fitness_scores(text).unwrap();
+        assert!(!scores.exempt);
+        assert_eq!(scores.total, Some(82.5));
+        assert_eq!(scores.items[0], FitnessItem {
+            name: "50米跑".to_string(),
+            result: "7.2".to_string(),
+            unit: "秒".to_string(),
+            score: 80.0,
+            level: "良好".to_string(),
+        });
+        assert_eq!(scores.items.len(), 2);
+    }
+
+    #[test]
+    fn test_parse_fitness_scores_exempt() {
+        let text = r#"{"code":0,"msg":"","data":{"year":"2023","status":"免测","totalScore":null,"level":"","items":[]}}"#;
+        assert_eq!(parse_fitness_scores(text).unwrap(), FitnessScores {
+            year: "2023".to_string(),
+            exempt: true,
+            total: None,
+            level: String::new(),
+            items: Vec::new(),
+        });
+    }
+}
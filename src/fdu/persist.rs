@@ -0,0 +1,98 @@
+// Encoding of exported sessions.
+//
+// A blob is MAGIC, a version byte, the FNV-1a hash of the payload (8 bytes, little endian) and the payload,
+// which is the JSON of `SessionData`. The hash catches truncated or corrupted blobs before we try to parse them,
+// since garbage in a cookie value would otherwise be accepted silently.
+use serde::{Deserialize, Serialize};
+
+use crate::error::*;
+
+const MAGIC: &[u8] = b"FDUS";
+const VERSION: u8 = 1;
+const HEADER_LEN: usize = MAGIC.len() + 1 + 8;
+
+#[derive(Debug, Default, Serialize, Deserialize, PartialEq)]
+pub struct SessionData {
+    pub uid: Option<String>,
+    pub cookies: Vec<SiteCookies>,
+}
+
+// The cookies sent to a url, as in the Cookie header, e.g. "a=1; b=2"
+#[derive(Debug, Serialize, Deserialize, PartialEq)]
+pub struct SiteCookies {
+    pub url: String,
+    pub cookies: String,
+}
+
+fn fnv1a(data: &[u8]) -> u64 {
+    let mut hash: u64 = 0xcbf29ce484222325;
+    for &byte in data {
+        hash ^= byte as u64;
+        hash = hash.wrapping_mul(0x100000001b3);
+    }
+    hash
+}
+
+fn invalid(message: &str) -> SDKError {
+    SDKError::with_type(ErrorType::ArgumentError, format!("invalid session blob: {}", message))
+}
+
+pub fn encode(data: &SessionData) -> Result<Vec<u8>> {
+    let payload = serde_json::to_vec(data)?;
+    let mut blob = Vec::with_capacity(HEADER_LEN + payload.len());
+    blob.extend_from_slice(MAGIC);
+    blob.push(VERSION);
+    blob.extend_from_slice(&fnv1a(&payload).to_le_bytes());
+    blob.extend_from_slice(&payload);
+    Ok(blob)
+}
+
+pub fn decode(blob: &[u8]) -> Result<SessionData> {
+    if blob.len() < HEADER_LEN || !blob.starts_with(MAGIC) {
+        return Err(invalid("not a session"));
+    }
+    if blob[MAGIC.len()] != VERSION {
+        return Err(invalid(&format!("unsupported version {}", blob[MAGIC.len()])));
+    }
+    let hash = u64::from_le_bytes(blob[MAGIC.len() + 1..HEADER_LEN].try_into().unwrap());
+    let payload = &blob[HEADER_LEN..];
+    if fnv1a(payload) != hash {
+        return Err(invalid("truncated or corrupted"));
+    }
+    serde_json::from_slice(payload).map_err(|_| invalid("malformed payload"))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn data() -> SessionData {
+        SessionData {
+            uid: Some("20300000000".to_string()),
+            cookies: vec![SiteCookies {
+                url: "https://uis.fudan.edu.cn/authserver/".to_string(),
+                cookies: "CASTGC=TGT-1; JSESSIONID=abc".to_string(),
+            }],
+        }
+    }
+
+    #[test]
+    fn test_round_trip() {
+        let blob = encode(&data()).unwrap();
+        assert_eq!(decode(&blob).unwrap(), data());
+    }
+
+    #[test]
+    fn test_invalid() {
+        let blob = encode(&data()).unwrap();
+        assert!(decode(&[]).is_err());
+        assert!(decode(b"not a session at all").is_err());
+        assert!(decode(&blob[..blob.len() - 1]).is_err());
+        assert!(decode(&blob[..HEADER_LEN]).is_err());
+        for i in 0..blob.len() {
+            let mut corrupted = blob.clone();
+            corrupted[i] ^= 0x20;
+            assert!(decode(&corrupted).is_err(), "flipping byte {} is not detected", i);
+        }
+    }
+}
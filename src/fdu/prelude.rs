@@ -1,7 +1,14 @@
+pub use super::announcement;
+pub use super::base_url;
+pub use super::config;
 pub use super::fdu_daily;
 pub use super::fdu::*;
 pub use super::jwfw;
 pub use super::ecard;
 pub use super::grade;
+pub use super::library;
 pub use super::myfdu;
+pub use super::page::*;
+pub use super::pe;
+pub use super::record;
 pub use crate::error::*;
\ No newline at end of file
@@ -0,0 +1,221 @@
+// Probes of the services of the university, to tell whether they are up before logging in to them. The probes need no
+// session: each is a GET of a public page of the service, without following redirects, e.g. jwfw redirects to the
+// login page of UIS, which would probe UIS instead.
+use std::io::Read;
+use std::thread;
+use std::time::{Duration, Instant};
+
+use reqwest::blocking::Client;
+use reqwest::redirect::Policy;
+use serde::Serialize;
+
+use super::prelude::*;
+
+// The timeout of a probe, unless the deadline of the call is sooner.
+const PROBE_TIMEOUT: Duration = Duration::from_secs(5);
+// How much of a page is read to look for a maintenance notice, which is at the top of the small page the services
+// show instead of their own while under maintenance.
+const MAX_PAGE_BYTES: u64 = 64 * 1024;
+// The notices of the maintenance pages, served with 200 or 503.
+const MAINTENANCE_NOTICES: &[&str] = &["系统维护中", "系统正在维护", "系统升级维护"];
+
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub enum Service {
+    // 统一身份认证
+    Uis,
+    // 教务系统
+    Jwfw,
+    // 校园卡
+    Ecard,
+    // 图书馆座位预约
+    Library,
+}
+
+const SERVICES: &[(&str, Service)] = &[
+    ("uis", Service::Uis),
+    ("jwfw", Service::Jwfw),
+    ("ecard", Service::Ecard),
+    ("library", Service::Library),
+];
+
+impl Service {
+    pub fn parse(name: &str) -> Result<Service> {
+        match SERVICES.iter().find(|(service_name, _)| *service_name == name) {
+            Some((_, service)) => Ok(*service),
+            None => {
+                let names: Vec<&str> = SERVICES.iter().map(|(service_name, _)| *service_name).collect();
+                Err(SDKError::with_type(ErrorType::ArgumentError,
+                                        format!("unknown service {}, valid services are {}", name, names.join(", "))))
+            }
+        }
+    }
+
+    // Parse the names of the services to probe, all of them if there is none.
+    pub fn parse_all(names: &[String]) -> Result<Vec<Service>> {
+        if names.is_empty() {
+            return Ok(SERVICES.iter().map(|(_, service)| *service).collect());
+        }
+        names.iter().map(|name| Service::parse(name)).collect()
+    }
+
+    fn name(self) -> &'static str {
+        SERVICES.iter().find(|(_, service)| *service == self).unwrap().0
+    }
+
+    fn url(self) -> &'static str {
+        match self {
+            Service::Uis => "https://uis.fudan.edu.cn/authserver/login",
+            Service::Jwfw => "https://jwfw.fudan.edu.cn/eams/home.action",
+            Service::Ecard => "https://ecard.fudan.edu.cn/epay/myepay/index",
+            Service::Library => "https://seat.lib.fudan.edu.cn/",
+        }
+    }
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct ProbeResult {
+    service: &'static str,
+    // Whether the service answered, whatever the status.
+    reachable: bool,
+    // The time to the answer, or until the probe failed.
+    latency_ms: u64,
+    // 0 if the service did not answer.
+    http_status: u16,
+    maintenance_page: bool,
+    // Why the service did not answer, empty if it did.
+    error: String,
+}
+
+// Probe `services` concurrently, each for `timeout` at most, `PROBE_TIMEOUT` if it is None or longer, or until
+// `deadline` if it is sooner. A service which does not answer in time is reported unreachable, so the call itself only
+// fails if no client can be built.
+pub fn probe(services: &[Service], timeout: Option<Duration>, deadline: Option<Instant>) -> Result<Vec<ProbeResult>> {
+    let mut timeout = timeout.map_or(PROBE_TIMEOUT, |timeout| timeout.min(PROBE_TIMEOUT));
+    if let Some(deadline) = deadline {
+        timeout = timeout.min(deadline.saturating_duration_since(Instant::now()));
+    }
+    let targets = services.iter().map(|service| (*service, base_url::resolve(service.url()))).collect();
+    probe_urls(targets, timeout)
+}
+
+fn probe_urls(targets: Vec<(Service, String)>, timeout: Duration) -> Result<Vec<ProbeResult>> {
+    let client = Fdu::client_builder().redirect(Policy::none()).build()?;
+    Ok(thread::scope(|scope| {
+        let probes: Vec<_> = targets.iter()
+            .map(|(service, url)| scope.spawn(|| probe_url(&client, *service, url, timeout)))
+            .collect();
+        probes.into_iter().map(|probe| probe.join().unwrap()).collect()
+    }))
+}
+
+fn probe_url(client: &Client, service: Service, url: &str, timeout: Duration) -> ProbeResult {
+    let mut result = ProbeResult {
+        service: service.name(),
+        reachable: false,
+        latency_ms: 0,
+        http_status: 0,
+        maintenance_page: false,
+        error: String::new(),
+    };
+    if timeout.is_zero() {
+        result.error = "deadline exceeded".to_string();
+        return result;
+    }
+    let start = Instant::now();
+    let response = client.get(url).timeout(timeout).send();
+    result.latency_ms = start.elapsed().as_millis() as u64;
+    match response {
+        Ok(response) => {
+            result.reachable = true;
+            result.http_status = response.status().as_u16();
+            // A page which cannot be read to the end is checked as far as it was read.
+            let mut page = Vec::new();
+            let _ = response.take(MAX_PAGE_BYTES).read_to_end(&mut page);
+            result.maintenance_page = is_maintenance_page(&String::from_utf8_lossy(&page));
+        }
+        Err(e) => {
+            log::debug!("probe of {}: {}", service.name(), e);
+            result.error = if e.is_timeout() { "timed out".to_string() } else { e.to_string() };
+        }
+    }
+    result
+}
+
+fn is_maintenance_page(page: &str) -> bool {
+    MAINTENANCE_NOTICES.iter().any(|notice| page.contains(notice))
+}
+
+#[cfg(test)]
+mod tests {
+    use std::io::Write;
+    use std::net::TcpListener;
+
+    use super::*;
+
+    // Serve `response` at the returned URL after `delay`, to every connection.
+    fn serve(response: &'static str, delay: Duration) -> String {
+        let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+        let url = format!("http://{}/", listener.local_addr().unwrap());
+        thread::spawn(move || {
+            for stream in listener.incoming() {
+                let mut stream = stream.unwrap();
+                thread::spawn(move || {
+                    let _ = stream.read(&mut [0; 4096]);
+                    thread::sleep(delay);
+                    let _ = stream.write_all(response.as_bytes());
+                });
+            }
+        });
+        url
+    }
+
+    #[test]
+    fn test_parse_services() {
+        assert_eq!(Service::parse("jwfw").unwrap(), Service::Jwfw);
+        assert_eq!(Service::parse_all(&[]).unwrap().len(), SERVICES.len());
+        assert_eq!(Service::parse_all(&["ecard".to_string(), "uis".to_string()]).unwrap(), vec![Service::Ecard, Service::Uis]);
+        let e = Service::parse_all(&["uis".to_string(), "xxx".to_string()]).unwrap_err();
+        assert!(matches!(e.error_type(), ErrorType::ArgumentError));
+        assert!(e.to_string().contains("unknown service xxx, valid services are uis, jwfw, ecard, library"));
+    }
+
+    #[test]
+    fn test_probe() {
+        let healthy = serve("HTTP/1.1 302 Found\r\nLocation: https://uis.fudan.edu.cn/\r\nContent-Length: 0\r\n\r\n", Duration::ZERO);
+        let unavailable = serve("HTTP/1.1 503 Service Unavailable\r\nContent-Length: 0\r\n\r\n", Duration::ZERO);
+        let slow = serve("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n", Duration::from_secs(3));
+        let maintenance = serve("HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\nConnection: close\r\n\r\n\
+                                 <html><body><h1>系统维护中</h1>请稍后访问</body></html>", Duration::ZERO);
+
+        let start = Instant::now();
+        let results = probe_urls(vec![
+            (Service::Jwfw, healthy),
+            (Service::Uis, unavailable),
+            (Service::Ecard, slow),
+            (Service::Library, maintenance),
+        ], Duration::from_millis(500)).unwrap();
+        // Concurrently, so the slow service holds up the others for its timeout only.
+        assert!(start.elapsed() < Duration::from_secs(2));
+
+        assert_eq!(results.iter().map(|result| result.service).collect::<Vec<_>>(), ["jwfw", "uis", "ecard", "library"]);
+        let (jwfw, uis, ecard, library) = (&results[0], &results[1], &results[2], &results[3]);
+        assert!(jwfw.reachable && jwfw.http_status == 302 && !jwfw.maintenance_page && jwfw.error.is_empty());
+        assert!(uis.reachable && uis.http_status == 503 && !uis.maintenance_page);
+        assert!(!ecard.reachable && ecard.http_status == 0 && ecard.error == "timed out");
+        assert!(ecard.latency_ms >= 500);
+        assert!(library.reachable && library.http_status == 200 && library.maintenance_page);
+    }
+
+    #[test]
+    fn test_probe_deadline_exceeded() {
+        let results = probe(&[Service::Uis], None, Some(Instant::now())).unwrap();
+        assert_eq!(results, vec![ProbeResult {
+            service: "uis",
+            reachable: false,
+            latency_ms: 0,
+            http_status: 0,
+            maintenance_page: false,
+            error: "deadline exceeded".to_string(),
+        }]);
+    }
+}
@@ -0,0 +1,275 @@
+// Rate limits of the requests of a session to each host, so that scripts calling the library in a loop do not get
+// the account flagged for hammering the servers, e.g. jwfw.
+//
+// Each session has a token bucket per host, filled at the rate of the host up to its burst. A request takes a token,
+// waiting for one if the bucket is empty, unless the call would miss its deadline: it then fails at once with a
+// `RateLimitedError` telling when the request could be sent. The limits are read from the HTTP settings when the
+// session is created, like them.
+use std::cell::Cell;
+use std::collections::{BTreeMap, HashMap};
+use std::sync::Mutex;
+use std::thread;
+use std::time::{Duration, Instant};
+
+use serde::{Deserialize, Serialize};
+
+use super::prelude::*;
+
+// How often a wait for a token checks whether the call is cancelled.
+const WAIT_STEP: Duration = Duration::from_millis(20);
+
+#[derive(Clone, Copy, Debug, Default, Deserialize, PartialEq, Serialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct Rate {
+    // Tokens added per second. 0 means no limit.
+    pub per_second: f64,
+    // The most tokens the bucket holds, i.e. the requests sent at once after a pause. 0 means 1.
+    pub burst: u32,
+}
+
+// The limits of the hosts missing from `HttpConfig::rate_limits`: a login takes 4 requests to UIS, and jwfw flags
+// the accounts sending more than a few requests per second.
+pub const DEFAULTS: [(&str, Rate); 2] = [
+    ("uis.fudan.edu.cn", Rate { per_second: 0.2, burst: 5 }),
+    ("jwfw.fudan.edu.cn", Rate { per_second: 2.0, burst: 4 }),
+];
+
+// Check the limits set by the caller, naming the host of a bad one.
+pub fn check(limits: &BTreeMap<String, Rate>) -> Result<()> {
+    for (host, rate) in limits {
+        if host.is_empty() || host.contains(['/', ':']) {
+            Err(SDKError::with_type(ErrorType::ArgumentError, format!("invalid host {:?}", host)))?
+        }
+        if !rate.per_second.is_finite() || rate.per_second < 0.0 {
+            Err(SDKError::with_type(ErrorType::ArgumentError, format!("{}: invalid rate {}", host, rate.per_second)))?
+        }
+    }
+    Ok(())
+}
+
+// The deadline and the cancellation of the call running on a thread, which the ffi layer sets with `enter()` so that
+// a wait for a token neither outlives the call nor ignores its cancellation.
+pub trait Call {
+    fn deadline(&self) -> Option<Instant>;
+    fn cancelled(&self) -> bool;
+}
+
+thread_local! {
+    static CURRENT: Cell<Option<*const dyn Call>> = const { Cell::new(None) };
+}
+
+// Restores the previous call of the thread when dropped.
+pub struct Scope(Option<*const dyn Call>);
+
+impl Drop for Scope {
+    fn drop(&mut self) {
+        CURRENT.with(|current| current.set(self.0));
+    }
+}
+
+// Make `call` the one of the current thread until the scope is dropped. The caller keeps `call` alive meanwhile.
+pub fn enter(call: *const dyn Call) -> Scope {
+    Scope(CURRENT.with(|current| current.replace(Some(call))))
+}
+
+fn current<T>(f: impl FnOnce(&dyn Call) -> T) -> Option<T> {
+    // The pointer is alive while its scope is, see `enter()`.
+    CURRENT.with(|current| current.get()).map(|call| f(unsafe { &*call }))
+}
+
+struct Bucket {
+    rate: Rate,
+    // May be negative: the requests waiting have taken the tokens to come.
+    tokens: f64,
+    updated: Instant,
+}
+
+impl Bucket {
+    fn burst(&self) -> f64 {
+        self.rate.burst.max(1) as f64
+    }
+
+    fn refill(&mut self, now: Instant) {
+        let elapsed = now.duration_since(self.updated).as_secs_f64();
+        self.tokens = (self.tokens + elapsed * self.rate.per_second).min(self.burst());
+        self.updated = now;
+    }
+}
+
+#[derive(Debug, Default, PartialEq, Serialize)]
+pub struct HostStats {
+    // The tokens left, negative while requests wait.
+    pub tokens: f64,
+    pub per_second: f64,
+    pub burst: u32,
+}
+
+#[derive(Debug, Default, PartialEq, Serialize)]
+pub struct Stats {
+    pub hosts: BTreeMap<String, HostStats>,
+    // The requests which waited for a token, and how long in total.
+    pub throttled_requests: u64,
+    pub throttled_millis: u64,
+}
+
+struct State {
+    buckets: HashMap<String, Bucket>,
+    throttled_requests: u64,
+    throttled: Duration,
+}
+
+pub struct RateLimiter {
+    state: Mutex<State>,
+}
+
+impl RateLimiter {
+    // A limiter with `limits`, and the defaults for the other hosts. A zero rate lifts the limit of a host.
+    pub fn new(limits: &BTreeMap<String, Rate>) -> Self {
+        let now = Instant::now();
+        let mut rates: HashMap<String, Rate> = DEFAULTS.iter().map(|(host, rate)| (host.to_string(), *rate)).collect();
+        rates.extend(limits.iter().map(|(host, rate)| (host.clone(), *rate)));
+        let buckets = rates.into_iter()
+            .filter(|(_, rate)| rate.per_second > 0.0)
+            .map(|(host, rate)| (host, Bucket { rate, tokens: rate.burst.max(1) as f64, updated: now }))
+            .collect();
+        Self { state: Mutex::new(State { buckets, throttled_requests: 0, throttled: Duration::ZERO }) }
+    }
+
+    fn lock(&self) -> std::sync::MutexGuard<'_, State> {
+        self.state.lock().unwrap_or_else(|e| e.into_inner())
+    }
+
+    // Take a token for a request to `host`, waiting for it if needed.
+    pub fn acquire(&self, host: &str) -> Result<()> {
+        let wait = {
+            let mut state = self.lock();
+            let now = Instant::now();
+            let Some(bucket) = state.buckets.get_mut(host) else { return Ok(()) };
+            bucket.refill(now);
+            let wait = if bucket.tokens >= 1.0 {
+                Duration::ZERO
+            } else {
+                Duration::from_secs_f64((1.0 - bucket.tokens) / bucket.rate.per_second)
+            };
+            if let Some(deadline) = current(|call| call.deadline()).flatten() {
+                if now + wait > deadline {
+                    let message = format!("rate limited: the next request to {} can be sent in {:?}, after the deadline",
+                                          host, wait);
+                    let value = serde_json::json!({"host": host, "retry_after_millis": wait.as_millis() as u64});
+                    return Err(SDKError::with_type(ErrorType::RateLimitedError, message).with_value(value.to_string()));
+                }
+            }
+            bucket.tokens -= 1.0;
+            if !wait.is_zero() {
+                state.throttled_requests += 1;
+                state.throttled += wait;
+            }
+            wait
+        };
+        if wait.is_zero() {
+            return Ok(());
+        }
+        log::info!("slowing down the requests to {}: waiting {:?}", host, wait);
+        let end = Instant::now() + wait;
+        loop {
+            if current(|call| call.cancelled()).unwrap_or(false) {
+                // Give the token back to the requests after this one.
+                if let Some(bucket) = self.lock().buckets.get_mut(host) {
+                    bucket.tokens += 1.0;
+                }
+                Err(SDKError::with_type(ErrorType::CancelledError, "cancelled".to_string()))?
+            }
+            let now = Instant::now();
+            if now >= end {
+                return Ok(());
+            }
+            thread::sleep((end - now).min(WAIT_STEP));
+        }
+    }
+
+    pub fn stats(&self) -> Stats {
+        let mut state = self.lock();
+        let now = Instant::now();
+        let hosts = state.buckets.iter_mut().map(|(host, bucket)| {
+            bucket.refill(now);
+            (host.clone(), HostStats { tokens: bucket.tokens, per_second: bucket.rate.per_second, burst: bucket.rate.burst })
+        }).collect();
+        Stats { hosts, throttled_requests: state.throttled_requests, throttled_millis: state.throttled.as_millis() as u64 }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    struct TestCall {
+        deadline: Option<Instant>,
+        cancelled: bool,
+    }
+
+    impl Call for TestCall {
+        fn deadline(&self) -> Option<Instant> {
+            self.deadline
+        }
+
+        fn cancelled(&self) -> bool {
+            self.cancelled
+        }
+    }
+
+    fn limiter(per_second: f64, burst: u32) -> RateLimiter {
+        RateLimiter::new(&BTreeMap::from([("test.invalid".to_string(), Rate { per_second, burst })]))
+    }
+
+    #[test]
+    fn test_acquire() {
+        let limiter = limiter(20.0, 2);
+        let start = Instant::now();
+        for _ in 0..6 {
+            limiter.acquire("test.invalid").unwrap();
+        }
+        // The burst goes at once, then a request every 50 ms.
+        let elapsed = start.elapsed();
+        assert!(elapsed >= Duration::from_millis(190) && elapsed < Duration::from_millis(400), "{:?}", elapsed);
+        let stats = limiter.stats();
+        assert_eq!(stats.throttled_requests, 4);
+        assert!(stats.throttled_millis >= 190, "{:?}", stats);
+        // Other hosts are not limited, and the defaults stay.
+        limiter.acquire("other.invalid").unwrap();
+        assert_eq!(stats.hosts["uis.fudan.edu.cn"].burst, 5);
+    }
+
+    #[test]
+    fn test_deadline() {
+        let limiter = limiter(1.0, 1);
+        limiter.acquire("test.invalid").unwrap();
+        let call = TestCall { deadline: Some(Instant::now() + Duration::from_millis(100)), cancelled: false };
+        let _scope = enter(&call);
+        let err = limiter.acquire("test.invalid").unwrap_err();
+        assert!(matches!(err.error_type(), ErrorType::RateLimitedError), "{}", err);
+        let value: serde_json::Value = serde_json::from_str(err.value().unwrap()).unwrap();
+        assert_eq!(value["host"], "test.invalid");
+        assert!(value["retry_after_millis"].as_u64().unwrap() > 900, "{}", value);
+        // The request was not counted.
+        assert!(limiter.stats().hosts["test.invalid"].tokens > -0.5);
+    }
+
+    #[test]
+    fn test_cancelled() {
+        let limiter = limiter(1.0, 1);
+        limiter.acquire("test.invalid").unwrap();
+        let call = TestCall { deadline: None, cancelled: true };
+        let _scope = enter(&call);
+        let err = limiter.acquire("test.invalid").unwrap_err();
+        assert!(matches!(err.error_type(), ErrorType::CancelledError), "{}", err);
+    }
+
+    #[test]
+    fn test_check() {
+        assert!(check(&BTreeMap::from([("jwfw.fudan.edu.cn".to_string(), Rate { per_second: 0.0, burst: 0 })])).is_ok());
+        for (host, per_second) in [("", 1.0), ("127.0.0.1:8080", 1.0), ("jwfw.fudan.edu.cn", -1.0), ("jwfw.fudan.edu.cn", f64::NAN)] {
+            let limits = BTreeMap::from([(host.to_string(), Rate { per_second, burst: 1 })]);
+            assert!(matches!(check(&limits).unwrap_err().error_type(), ErrorType::ArgumentError), "{} {}", host, per_second);
+        }
+    }
+}
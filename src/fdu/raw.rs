@@ -0,0 +1,363 @@
+// Requests to the endpoints which libfdu has no export for yet, sent as given with the cookies of a session, see
+// `fdu_session_do()`, so that callers can try out a new service of the university before it gets a proper binding.
+//
+// They are limited to the hosts of the university, and to those of `HttpConfig::raw_allowed_hosts`, which the
+// redirects are checked against too: the redirects are followed here rather than by reqwest, hop by hop, up to
+// `MAX_REDIRECTS`, e.g. through UIS when a service logs the session in. The body of the response is limited to
+// `HttpConfig::raw_max_body_bytes`, since it is copied whole to the caller.
+use std::io::Read;
+
+use reqwest::blocking::Response;
+use reqwest::header::{self, HeaderMap, HeaderName, HeaderValue};
+use reqwest::{Method, StatusCode, Url};
+use serde::{Deserialize, Serialize};
+
+use super::config::HttpConfig;
+use super::prelude::*;
+
+// The hosts allowed to everyone, a leading `*.` matching any subdomain.
+const DEFAULT_ALLOWED_HOSTS: &[&str] = &["fudan.edu.cn", "*.fudan.edu.cn"];
+pub const DEFAULT_MAX_BODY_BYTES: u64 = 8 << 20;
+const MAX_REDIRECTS: usize = 10;
+
+// The request, as JSON, except for the body which is passed as bytes.
+#[derive(Debug, Default, Deserialize)]
+#[serde(default, deny_unknown_fields)]
+pub struct RawRequest {
+    // GET if empty.
+    pub method: String,
+    // An absolute http or https URL.
+    pub url: String,
+    // Pairs of a name and a value, sent in this order, after the default headers of the session.
+    pub headers: Vec<(String, String)>,
+    #[serde(skip)]
+    pub body: Vec<u8>,
+}
+
+// The response, as JSON, except for the body which is returned as bytes.
+#[derive(Debug, Serialize)]
+pub struct RawResponse {
+    pub status: u16,
+    // The URL of the response, after the redirects; on the real host even if a test replaced it.
+    pub url: String,
+    // Pairs of a name, in lowercase, and a value, in the order of the response.
+    pub headers: Vec<(String, String)>,
+    #[serde(skip)]
+    pub body: Vec<u8>,
+}
+
+// The limits of the raw requests of a session, read from the config when the session is created.
+#[derive(Clone, Debug)]
+pub struct Limits {
+    allowed_hosts: Vec<String>,
+    max_body_bytes: u64,
+}
+
+impl Limits {
+    pub fn new(config: &HttpConfig) -> Self {
+        let mut allowed_hosts: Vec<String> = DEFAULT_ALLOWED_HOSTS.iter().map(|host| host.to_string()).collect();
+        allowed_hosts.extend(config.raw_allowed_hosts.iter().map(|host| host.to_ascii_lowercase()));
+        let max_body_bytes = if config.raw_max_body_bytes == 0 { DEFAULT_MAX_BODY_BYTES } else { config.raw_max_body_bytes };
+        Self { allowed_hosts, max_body_bytes }
+    }
+
+    fn allows(&self, host: &str) -> bool {
+        let host = host.to_ascii_lowercase();
+        self.allowed_hosts.iter().any(|allowed| match allowed.strip_prefix("*.") {
+            Some(domain) => host.strip_suffix(domain).is_some_and(|sub| sub.len() > 1 && sub.ends_with('.')),
+            None => host == *allowed,
+        })
+    }
+}
+
+// Check the hosts of `HttpConfig::raw_allowed_hosts`: names like tac.fudan.edu.cn, or *.example.com for the
+// subdomains of example.com.
+pub fn check_hosts(hosts: &[String]) -> Result<()> {
+    for host in hosts {
+        let name = host.strip_prefix("*.").unwrap_or(host);
+        let valid = !name.is_empty()
+            && name.split('.').all(|label| !label.is_empty() && label.chars().all(|c| c.is_ascii_alphanumeric() || c == '-'));
+        if !valid {
+            Err(SDKError::with_type(ErrorType::ArgumentError, format!("invalid host {:?}", host)))?
+        }
+    }
+    Ok(())
+}
+
+// Send `request` with `client`, which must not follow redirects itself, calling `check` before each hop, e.g. to stop
+// if the call is cancelled.
+pub fn send(client: &impl HttpClient, limits: &Limits, request: RawRequest, check: impl Fn() -> Result<()>) -> Result<RawResponse> {
+    let mut method = if request.method.is_empty() {
+        Method::GET
+    } else {
+        Method::from_bytes(request.method.as_bytes())
+            .map_err(|_| SDKError::with_type(ErrorType::ArgumentError, format!("invalid method {:?}", request.method)))?
+    };
+    let mut headers = HeaderMap::new();
+    for (name, value) in &request.headers {
+        let name = HeaderName::from_bytes(name.as_bytes())
+            .map_err(|_| SDKError::with_type(ErrorType::ArgumentError, format!("invalid header name {:?}", name)))?;
+        let value = HeaderValue::from_str(value)
+            .map_err(|_| SDKError::with_type(ErrorType::ArgumentError, format!("invalid value of header {}", name)))?;
+        headers.append(name, value);
+    }
+    let mut url = parse_url(&request.url)?;
+    let mut body = request.body;
+
+    for _ in 0..=MAX_REDIRECTS {
+        check()?;
+        let host = base_url::host_of(&url);
+        if !limits.allows(&host) {
+            Err(SDKError::with_type(ErrorType::ArgumentError,
+                                    format!("{} is not an allowed host, see raw_allowed_hosts", host)))?
+        }
+        let mut req = client.get_client().request(method.clone(), url.clone()).headers(headers.clone());
+        if !body.is_empty() {
+            req = req.body(body.clone());
+        }
+        let res = client.execute(req.build()?)?;
+        let location = res.headers().get(header::LOCATION).and_then(|location| location.to_str().ok());
+        let Some(next) = location.filter(|_| res.status().is_redirection()) else {
+            return read_response(res, limits.max_body_bytes);
+        };
+        let next = res.url().join(next)
+            .map_err(|_| SDKError::with_type(ErrorType::NetworkError, format!("invalid redirect from {} to {:?}", res.url(), next)))?;
+        log::debug!("raw request redirected from {} to {}", base_url::original(res.url().as_str()), next);
+        // Like browsers, and reqwest: only 307 and 308 send the body again.
+        if matches!(res.status(), StatusCode::MOVED_PERMANENTLY | StatusCode::FOUND | StatusCode::SEE_OTHER) && method != Method::HEAD {
+            method = Method::GET;
+            body = Vec::new();
+            headers.remove(header::CONTENT_TYPE);
+            headers.remove(header::CONTENT_LENGTH);
+        }
+        let next = parse_url(next.as_str())?;
+        if base_url::host_of(&next) != host {
+            headers.remove(header::AUTHORIZATION);
+        }
+        url = next;
+    }
+    Err(SDKError::with_type(ErrorType::NetworkError, format!("more than {} redirects from {}", MAX_REDIRECTS, request.url)))
+}
+
+// Parse `url`, on the server replacing its host if any, see `base_url`.
+fn parse_url(url: &str) -> Result<Url> {
+    match Url::parse(&base_url::resolve(url)) {
+        Ok(parsed) if matches!(parsed.scheme(), "http" | "https") && parsed.host_str().is_some() => Ok(parsed),
+        _ => Err(SDKError::with_type(ErrorType::ArgumentError, format!("invalid url {:?}", url))),
+    }
+}
+
+fn read_response(res: Response, max_body_bytes: u64) -> Result<RawResponse> {
+    let too_large = SDKError::with_type(ErrorType::NetworkError,
+                                        format!("the body of {} is over {} bytes, see raw_max_body_bytes",
+                                                base_url::original(res.url().as_str()), max_body_bytes));
+    if res.content_length().is_some_and(|len| len > max_body_bytes) {
+        return Err(too_large);
+    }
+    let mut response = RawResponse {
+        status: res.status().as_u16(),
+        url: base_url::original(res.url().as_str()),
+        headers: res.headers().iter()
+            .map(|(name, value)| (name.to_string(), String::from_utf8_lossy(value.as_bytes()).into_owned()))
+            .collect(),
+        body: Vec::new(),
+    };
+    res.take(max_body_bytes + 1).read_to_end(&mut response.body)
+        .map_err(|e| SDKError::with_cause(ErrorType::NetworkError, "failed to read the body".to_string(), Box::new(e)))?;
+    if response.body.len() as u64 > max_body_bytes {
+        return Err(too_large);
+    }
+    Ok(response)
+}
+
+#[cfg(test)]
+mod tests {
+    use std::io::{BufRead, BufReader, Write};
+    use std::net::TcpListener;
+    use std::sync::{Arc, Mutex};
+    use std::thread;
+
+    use reqwest::blocking::Client;
+    use reqwest::cookie::Jar;
+    use reqwest::redirect::Policy;
+
+    use super::*;
+
+    struct TestClient(Client, Arc<Jar>);
+
+    impl HttpClient for TestClient {
+        fn get_client(&self) -> &Client {
+            &self.0
+        }
+
+        fn get_cookie_store(&self) -> &Arc<Jar> {
+            &self.1
+        }
+    }
+
+    fn client() -> TestClient {
+        let jar = Arc::new(Jar::default());
+        TestClient(Client::builder().cookie_provider(Arc::clone(&jar)).redirect(Policy::none()).build().unwrap(), jar)
+    }
+
+    fn limits() -> Limits {
+        Limits::new(&HttpConfig { raw_allowed_hosts: vec!["127.0.0.1".to_string()], ..Default::default() })
+    }
+
+    // Serve the responses of `respond` to the request lines, e.g. "GET /a HTTP/1.1", at the returned URL, and
+    // collect the requests.
+    fn serve(respond: fn(&str) -> String) -> (String, Arc<Mutex<Vec<String>>>) {
+        let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+        let url = format!("http://{}", listener.local_addr().unwrap());
+        let requests = Arc::new(Mutex::new(Vec::new()));
+        let seen = Arc::clone(&requests);
+        thread::spawn(move || {
+            for stream in listener.incoming() {
+                let mut stream = stream.unwrap();
+                let seen = Arc::clone(&seen);
+                // The client keeps the connection for the next hops.
+                thread::spawn(move || {
+                    let mut reader = BufReader::new(stream.try_clone().unwrap());
+                    loop {
+                        let mut request = String::new();
+                        let mut length = 0;
+                        loop {
+                            let mut line = String::new();
+                            if reader.read_line(&mut line).unwrap_or(0) == 0 {
+                                return;
+                            }
+                            if let Some((name, value)) = line.split_once(':') {
+                                if name.eq_ignore_ascii_case("content-length") {
+                                    length = value.trim().parse().unwrap();
+                                }
+                            }
+                            request.push_str(&line);
+                            if line == "\r\n" {
+                                break;
+                            }
+                        }
+                        let mut body = vec![0; length];
+                        if reader.read_exact(&mut body).is_err() {
+                            return;
+                        }
+                        request.push_str(&String::from_utf8_lossy(&body));
+                        let line = request.lines().next().unwrap_or_default().to_string();
+                        seen.lock().unwrap().push(request);
+                        if stream.write_all(respond(&line).as_bytes()).is_err() {
+                            return;
+                        }
+                    }
+                });
+            }
+        });
+        (url, requests)
+    }
+
+    fn get(url: &str) -> RawRequest {
+        RawRequest { url: url.to_string(), ..Default::default() }
+    }
+
+    #[test]
+    fn test_allowed_hosts() {
+        let limits = limits();
+        for host in ["fudan.edu.cn", "jwfw.fudan.edu.cn", "a.b.FUDAN.edu.cn", "127.0.0.1"] {
+            assert!(limits.allows(host), "{}", host);
+        }
+        for host in ["evilfudan.edu.cn", "fudan.edu.cn.example.com", "example.com", ".fudan.edu.cn", "127.0.0.2"] {
+            assert!(!limits.allows(host), "{}", host);
+        }
+        let limits = Limits::new(&HttpConfig { raw_allowed_hosts: vec!["*.Example.com".to_string()], ..Default::default() });
+        assert!(limits.allows("api.example.com") && !limits.allows("example.com"));
+
+        assert!(check_hosts(&["tac.fudan.edu.cn".to_string(), "*.example.com".to_string(), "127.0.0.1".to_string()]).is_ok());
+        for host in ["", "*.", "*", "exa mple.com", "example..com", "https://example.com", "example.com:8080"] {
+            assert!(check_hosts(&[host.to_string()]).is_err(), "{}", host);
+        }
+    }
+
+    #[test]
+    fn test_send_rejects_hosts() {
+        let (url, requests) = serve(|_| "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n".to_string());
+        let client = client();
+        // The server is not allowed by default.
+        let e = send(&client, &Limits::new(&HttpConfig::default()), get(&url), || Ok(())).unwrap_err();
+        assert!(matches!(e.error_type(), ErrorType::ArgumentError));
+        assert!(e.to_string().contains("127.0.0.1 is not an allowed host"), "{}", e);
+        for url in ["https://example.com/", "/relative", "ftp://ftp.fudan.edu.cn/"] {
+            let e = send(&client, &limits(), get(url), || Ok(())).unwrap_err();
+            assert!(matches!(e.error_type(), ErrorType::ArgumentError), "{}: {}", url, e);
+        }
+        let bad = RawRequest { method: "G T".to_string(), ..get(&url) };
+        assert!(matches!(send(&client, &limits(), bad, || Ok(())).unwrap_err().error_type(), ErrorType::ArgumentError));
+        let bad = RawRequest { headers: vec![("X-A".to_string(), "a\nb".to_string())], ..get(&url) };
+        assert!(matches!(send(&client, &limits(), bad, || Ok(())).unwrap_err().error_type(), ErrorType::ArgumentError));
+        assert!(requests.lock().unwrap().is_empty());
+
+        // Nor are the redirects out of the allowed hosts.
+        let (url, requests) = serve(|_| "HTTP/1.1 302 Found\r\nLocation: https://example.com/\r\nContent-Length: 0\r\n\r\n".to_string());
+        let e = send(&client, &limits(), get(&url), || Ok(())).unwrap_err();
+        assert!(e.to_string().contains("example.com is not an allowed host"), "{}", e);
+        assert_eq!(requests.lock().unwrap().len(), 1);
+    }
+
+    #[test]
+    fn test_send_redirects() {
+        let (url, requests) = serve(|line| match line.split(' ').nth(1).unwrap_or_default() {
+            "/login" => "HTTP/1.1 307 Temporary Redirect\r\nLocation: /sso?step=1\r\nContent-Length: 0\r\n\r\n".to_string(),
+            "/sso?step=1" => "HTTP/1.1 303 See Other\r\nLocation: /app\r\nSet-Cookie: ticket=ST-1; Path=/\r\nContent-Length: 0\r\n\r\n".to_string(),
+            "/app" => "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nX-Step: 1\r\nX-Step: 2\r\nContent-Length: 5\r\n\r\nhello".to_string(),
+            "/loop" => "HTTP/1.1 302 Found\r\nLocation: /loop\r\nContent-Length: 0\r\n\r\n".to_string(),
+            _ => "HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n".to_string(),
+        });
+        let client = client();
+        let request = RawRequest {
+            method: "POST".to_string(),
+            url: format!("{}/login", url),
+            headers: vec![("Content-Type".to_string(), "text/plain".to_string())],
+            body: b"form".to_vec(),
+        };
+        let res = send(&client, &limits(), request, || Ok(())).unwrap();
+        assert_eq!((res.status, res.url.as_str(), res.body.as_slice()), (200, format!("{}/app", url).as_str(), &b"hello"[..]));
+        assert!(res.headers.contains(&("content-type".to_string(), "text/plain".to_string())));
+        let steps: Vec<_> = res.headers.iter().filter(|(name, _)| name == "x-step").map(|(_, value)| value.as_str()).collect();
+        assert_eq!(steps, ["1", "2"]);
+
+        let requests = requests.lock().unwrap().clone();
+        assert_eq!(requests.len(), 3);
+        // 307 sends the body again, 303 turns the request into a GET, with the cookie set on the way.
+        assert!(requests[1].starts_with("POST /sso?step=1 ") && requests[1].ends_with("form"), "{}", requests[1]);
+        assert!(requests[2].starts_with("GET /app ") && !requests[2].to_lowercase().contains("content-"), "{}", requests[2]);
+        assert!(requests[2].contains("ticket=ST-1"), "{}", requests[2]);
+
+        let e = send(&client, &limits(), get(&format!("{}/loop", url)), || Ok(())).unwrap_err();
+        assert!(matches!(e.error_type(), ErrorType::NetworkError));
+        assert!(e.to_string().contains("more than 10 redirects"), "{}", e);
+
+        // A 404 is a response like any other.
+        assert_eq!(send(&client, &limits(), get(&format!("{}/missing", url)), || Ok(())).unwrap().status, 404);
+
+        // The check runs before each hop.
+        let e = send(&client, &limits(), get(&format!("{}/loop", url)), || {
+            Err(SDKError::with_type(ErrorType::CancelledError, "cancelled".to_string()))
+        }).unwrap_err();
+        assert!(matches!(e.error_type(), ErrorType::CancelledError));
+    }
+
+    #[test]
+    fn test_send_max_body() {
+        let (url, _) = serve(|line| match line.split(' ').nth(1).unwrap_or_default() {
+            "/declared" => format!("HTTP/1.1 200 OK\r\nContent-Length: 5000\r\n\r\n{}", "x".repeat(5000)),
+            "/chunked" => format!("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n1388\r\n{}\r\n0\r\n\r\n", "x".repeat(5000)),
+            _ => format!("HTTP/1.1 200 OK\r\nContent-Length: 4096\r\n\r\n{}", "x".repeat(4096)),
+        });
+        let client = client();
+        let limits = Limits { max_body_bytes: 4096, ..limits() };
+        assert_eq!(send(&client, &limits, get(&format!("{}/fits", url)), || Ok(())).unwrap().body.len(), 4096);
+        for path in ["/declared", "/chunked"] {
+            let e = send(&client, &limits, get(&format!("{}{}", url, path)), || Ok(())).unwrap_err();
+            assert!(matches!(e.error_type(), ErrorType::NetworkError), "{}", path);
+            assert!(e.to_string().contains("is over 4096 bytes"), "{}: {}", path, e);
+        }
+        assert_eq!(Limits::new(&HttpConfig::default()).max_body_bytes, DEFAULT_MAX_BODY_BYTES);
+    }
+}
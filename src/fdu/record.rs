@@ -0,0 +1,561 @@
+// Recording of the HTTP exchanges of the sessions, for bug reports, see `fdu_set_recording()`: while it is on, every
+// request sent by `HttpClient::execute()` or `HttpClient::send()` is written with its response to a JSON file of the
+// recording directory, one per exchange, like the entries of a HAR file. The URLs are the ones of the real servers,
+// also when a test replaces them, see `base_url`, so that a recording can be replayed against other servers.
+//
+// Unless the caller asks otherwise, the files are redacted as they are written: the credentials given to the login,
+// the cookies, the CAS tickets, the student IDs and the names are replaced by placeholders, the same one for the same
+// value, so that a replay behaves like the exchanges recorded. A value found in a later exchange, e.g. a cookie set by
+// a redirect, is also replaced in the files written before.
+use std::collections::{HashMap, HashSet};
+use std::fs;
+use std::path::PathBuf;
+use std::sync::{Arc, Mutex, RwLock};
+use std::time::Instant;
+
+use base64::Engine;
+use base64::engine::general_purpose::STANDARD;
+use regex::Regex;
+use reqwest::blocking::{Request, Response};
+use reqwest::header::{HeaderMap, HeaderValue};
+use reqwest::ResponseBuilderExt;
+use serde::Serialize;
+
+use super::prelude::*;
+
+// The shortest values registered as secrets: a shorter one, e.g. the cookie `lang=zh`, would replace too much.
+const MIN_SECRET_CHARS: usize = 6;
+// A name has 2 characters at least, e.g. 张三.
+const MIN_NAME_CHARS: usize = 2;
+
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub enum Redact {
+    // The credentials, the cookies, the tickets, the student IDs and the names.
+    Standard,
+    // Also every number of 8 digits or more and the email addresses, and the request bodies are dropped.
+    Strict,
+    // Nothing, for the recordings which never leave the machine.
+    None,
+}
+
+impl Redact {
+    pub fn from_level(level: u32) -> Result<Redact> {
+        match level {
+            0 => Ok(Redact::Standard),
+            1 => Ok(Redact::Strict),
+            2 => Ok(Redact::None),
+            _ => Err(SDKError::with_type(ErrorType::ArgumentError, format!("invalid redaction level {}", level))),
+        }
+    }
+}
+
+// What a secret is, which decides its placeholder.
+#[derive(Clone, Copy, Debug, Eq, Hash, PartialEq)]
+pub enum Kind {
+    Password,
+    StudentId,
+    Name,
+    Cookie,
+    // The value of an Authorization header.
+    Token,
+    Ticket,
+    // A number of 8 digits or more, with `Redact::Strict`.
+    Number,
+    Email,
+}
+
+static RECORDER: RwLock<Option<Arc<Recorder>>> = RwLock::new(None);
+
+// Record the exchanges into `dir`, created if needed, or stop recording if it is None. The exchanges in flight are
+// recorded by the recorder they started with.
+pub fn set(dir: Option<PathBuf>, redact: Redact) -> Result<()> {
+    let recorder = match dir {
+        Some(dir) => Some(Arc::new(Recorder::new(dir, redact)?)),
+        None => None,
+    };
+    *RECORDER.write().unwrap_or_else(|e| e.into_inner()) = recorder;
+    Ok(())
+}
+
+fn current() -> Option<Arc<Recorder>> {
+    RECORDER.read().unwrap_or_else(|e| e.into_inner()).clone()
+}
+
+// Register a value which must not be recorded, e.g. the password given to a login.
+pub fn secret(kind: Kind, value: &str) {
+    if let Some(recorder) = current() {
+        recorder.secret(kind, value);
+    }
+}
+
+// Send `req` with `send`, recording the exchange if recording is on. `cookies` is the Cookie header the client adds
+// to the request, which is not in `req`.
+pub fn exchange(req: Request, cookies: Option<HeaderValue>, send: impl FnOnce(Request) -> reqwest::Result<Response>)
+                -> reqwest::Result<Response> {
+    match current() {
+        Some(recorder) => recorder.exchange(req, cookies, send),
+        None => send(req),
+    }
+}
+
+#[derive(Serialize)]
+struct Entry {
+    // When the request was sent, in RFC 3339.
+    started: String,
+    time_ms: u64,
+    request: RequestEntry,
+    // None if the request failed, see `error`.
+    response: Option<ResponseEntry>,
+    #[serde(skip_serializing_if = "String::is_empty")]
+    error: String,
+}
+
+#[derive(Serialize)]
+struct RequestEntry {
+    method: String,
+    url: String,
+    headers: Vec<Header>,
+    body: String,
+}
+
+#[derive(Serialize)]
+struct ResponseEntry {
+    status: u16,
+    // The URL of the response, after the redirects.
+    url: String,
+    headers: Vec<Header>,
+    body: String,
+    // "base64" if the body is not text, e.g. a captcha image.
+    #[serde(skip_serializing_if = "String::is_empty")]
+    encoding: String,
+}
+
+#[derive(Serialize)]
+struct Header {
+    name: String,
+    value: String,
+}
+
+struct Recorder {
+    dir: PathBuf,
+    redact: Redact,
+    patterns: Patterns,
+    // Locked while a file is redacted and written, so that a secret registered meanwhile is replaced in it too.
+    state: Mutex<State>,
+}
+
+#[derive(Default)]
+struct State {
+    // The forms of the secrets as they appear in the files, e.g. form-encoded, with their placeholders, the longest
+    // first so that a secret containing another is replaced whole.
+    secrets: Vec<(String, String)>,
+    // The placeholders given, which are not secrets themselves.
+    given: HashSet<String>,
+    counts: HashMap<&'static str, u32>,
+    files: Vec<PathBuf>,
+    next: u64,
+}
+
+// The patterns of the secrets found in the exchanges. Each has the secret as its first group.
+struct Patterns {
+    standard: Vec<(Kind, Regex)>,
+    strict: Vec<(Kind, Regex)>,
+}
+
+impl Patterns {
+    fn new() -> Self {
+        let compile = |patterns: &[(Kind, &str)]| patterns.iter().map(|(kind, re)| (*kind, Regex::new(re).unwrap())).collect();
+        Patterns {
+            standard: compile(&[
+                // The CAS tickets in the URLs of the redirects of the login.
+                (Kind::Ticket, r"\bticket=([^&#\s\x22']+)"),
+                (Kind::Password, r"(?:^|&)(?:password|pwd|passwd)=([^&]+)"),
+                // A table cell like <td class="title">姓名：</td><td>张三</td>, or text like 姓名：张三.
+                (Kind::Name, r"姓名[:：]\s*(?:<[^>]*>\s*)*([^<>\s\x22'：:]+)"),
+                (Kind::Name, r#""(?:XM|xm)"\s*:\s*"([^"]+)""#),
+                (Kind::StudentId, r"学号[:：]\s*(?:<[^>]*>\s*)*(\d{6,})"),
+                (Kind::StudentId, r#""(?:XH|xh)"\s*:\s*"(\d{6,})""#),
+            ]),
+            strict: compile(&[
+                (Kind::Number, r"(?:^|\D)(\d{8,})"),
+                (Kind::Email, r"([\w.+-]+@[\w-]+(?:\.[\w-]+)+)"),
+            ]),
+        }
+    }
+}
+
+impl Recorder {
+    fn new(dir: PathBuf, redact: Redact) -> Result<Self> {
+        fs::create_dir_all(&dir)
+            .map_err(|e| SDKError::with_type(ErrorType::ArgumentError, format!("cannot create {}: {}", dir.display(), e)))?;
+        // A directory used before keeps its files: the numbers go on after them.
+        let next = fs::read_dir(&dir)
+            .map_err(|e| SDKError::with_type(ErrorType::ArgumentError, format!("cannot read {}: {}", dir.display(), e)))?
+            .filter_map(|entry| entry.ok()?.file_name().to_str()?.strip_suffix(".json")?.parse::<u64>().ok())
+            .max().map_or(1, |n| n + 1);
+        Ok(Recorder { dir, redact, patterns: Patterns::new(), state: Mutex::new(State { next, ..Default::default() }) })
+    }
+
+    fn exchange(&self, req: Request, cookies: Option<HeaderValue>, send: impl FnOnce(Request) -> reqwest::Result<Response>)
+                -> reqwest::Result<Response> {
+        let mut headers = entry_headers(req.headers());
+        if let Some(cookies) = cookies {
+            headers.push(Header { name: "cookie".to_string(), value: String::from_utf8_lossy(cookies.as_bytes()).into_owned() });
+        }
+        let request = RequestEntry {
+            method: req.method().to_string(),
+            url: base_url::original(req.url().as_str()),
+            headers,
+            body: req.body().and_then(|body| body.as_bytes()).map(|body| String::from_utf8_lossy(body).into_owned()).unwrap_or_default(),
+        };
+        let started = chrono::Local::now().to_rfc3339();
+        let start = Instant::now();
+        let (res, response, error) = match send(req) {
+            Ok(res) => {
+                // The body is read here to be recorded, and handed to the caller in a response built again.
+                let (status, version, url, headers) = (res.status(), res.version(), res.url().clone(), res.headers().clone());
+                let body = res.bytes()?;
+                let (text, encoding) = match std::str::from_utf8(&body) {
+                    Ok(text) => (text.to_string(), String::new()),
+                    Err(_) => (STANDARD.encode(&body), "base64".to_string()),
+                };
+                let response = ResponseEntry {
+                    status: status.as_u16(),
+                    url: base_url::original(url.as_str()),
+                    headers: entry_headers(&headers),
+                    body: text,
+                    encoding,
+                };
+                let mut builder = http::Response::builder().status(status).version(version).url(url);
+                *builder.headers_mut().unwrap() = headers;
+                (Ok(Response::from(builder.body(body.to_vec()).unwrap())), Some(response), String::new())
+            }
+            Err(e) => {
+                let error = e.to_string();
+                (Err(e), None, error)
+            }
+        };
+        let entry = Entry { started, time_ms: start.elapsed().as_millis() as u64, request, response, error };
+        if let Err(e) = self.write(entry) {
+            log::warn!("cannot record an exchange in {}: {}", self.dir.display(), e);
+        }
+        res
+    }
+
+    fn secret(&self, kind: Kind, value: &str) {
+        let mut state = self.state.lock().unwrap_or_else(|e| e.into_inner());
+        let added = self.register(&mut state, kind, value);
+        if !added.is_empty() {
+            Self::scrub_files(&state, &added);
+        }
+    }
+
+    fn write(&self, mut entry: Entry) -> std::io::Result<()> {
+        let mut state = self.state.lock().unwrap_or_else(|e| e.into_inner());
+        let mut added = Vec::new();
+        if self.redact != Redact::None {
+            if self.redact == Redact::Strict {
+                entry.request.body.clear();
+            }
+            for (kind, value) in self.find_secrets(&entry) {
+                added.extend(self.register(&mut state, kind, &value));
+            }
+        }
+        // The files written before may have the secrets found in this one.
+        Self::scrub_files(&state, &added);
+
+        let json = serde_json::to_string_pretty(&entry).unwrap();
+        let path = self.dir.join(format!("{:06}.json", state.next));
+        fs::write(&path, Self::scrub(&state.secrets, &json))?;
+        state.next += 1;
+        state.files.push(path);
+        Ok(())
+    }
+
+    // The secrets of an exchange: the values of its cookies and its Authorization header, and those matching the
+    // patterns.
+    fn find_secrets(&self, entry: &Entry) -> Vec<(Kind, String)> {
+        let mut secrets = Vec::new();
+        let mut headers: Vec<&Header> = entry.request.headers.iter().collect();
+        let mut texts = vec![&entry.request.url, &entry.request.body];
+        if let Some(response) = &entry.response {
+            headers.extend(&response.headers);
+            texts.push(&response.url);
+            if response.encoding.is_empty() {
+                texts.push(&response.body);
+            }
+        }
+        for header in headers {
+            match header.name.as_str() {
+                "cookie" => for pair in header.value.split(';') {
+                    if let Some((_, value)) = pair.split_once('=') {
+                        secrets.push((Kind::Cookie, value.trim().to_string()));
+                    }
+                },
+                "set-cookie" => if let Some((_, value)) = header.value.split(';').next().and_then(|pair| pair.split_once('=')) {
+                    secrets.push((Kind::Cookie, value.trim().to_string()));
+                },
+                "authorization" => secrets.push((Kind::Token, header.value.clone())),
+                _ => {}
+            }
+        }
+        let strict: &[(Kind, Regex)] = if self.redact == Redact::Strict { &self.patterns.strict } else { &[] };
+        for text in texts {
+            for (kind, re) in self.patterns.standard.iter().chain(strict) {
+                secrets.extend(re.captures_iter(text).map(|captures| (*kind, captures[1].to_string())));
+            }
+        }
+        secrets
+    }
+
+    // Register `value` with a placeholder, and return the forms added, which are to be replaced in the files written
+    // before.
+    fn register(&self, state: &mut State, kind: Kind, value: &str) -> Vec<(String, String)> {
+        let min_chars = if kind == Kind::Name { MIN_NAME_CHARS } else { MIN_SECRET_CHARS };
+        if self.redact == Redact::None || value.chars().count() < min_chars || state.given.contains(value)
+            || state.secrets.iter().any(|(secret, _)| secret == value) {
+            return Vec::new();
+        }
+        let placeholder = Self::placeholder(state, kind, value);
+        state.given.insert(placeholder.clone());
+
+        // The value as it appears in the JSON of the files, in a query or in a form.
+        let mut forms = vec![value.to_string(), json_escape(value), percent_encode(value, "%20"), percent_encode(value, "+")];
+        forms.sort();
+        forms.dedup();
+        let added: Vec<(String, String)> = forms.into_iter()
+            .filter(|form| !state.secrets.iter().any(|(secret, _)| secret == form))
+            .map(|form| (form, placeholder.clone()))
+            .collect();
+        state.secrets.extend(added.iter().cloned());
+        state.secrets.sort_by(|(a, _), (b, _)| b.len().cmp(&a.len()));
+        added
+    }
+
+    fn placeholder(state: &mut State, kind: Kind, value: &str) -> String {
+        let counter = match kind {
+            // The numbers keep their length and stay digits, so that they parse as before.
+            Kind::StudentId | Kind::Number => "digits",
+            Kind::Password => "password",
+            Kind::Name => "name",
+            Kind::Cookie => "cookie",
+            Kind::Token => "token",
+            Kind::Ticket => "ticket",
+            Kind::Email => "email",
+        };
+        let count = state.counts.entry(counter).or_default();
+        *count += 1;
+        match kind {
+            Kind::StudentId | Kind::Number => format!("{:0>width$}", count, width = value.len()),
+            Kind::Ticket => format!("ST-REDACTED-{}", count),
+            Kind::Email => format!("redacted-{}@example.com", count),
+            _ => format!("REDACTED-{}-{}", counter.to_uppercase(), count),
+        }
+    }
+
+    fn scrub(secrets: &[(String, String)], text: &str) -> String {
+        let mut text = text.to_string();
+        for (secret, placeholder) in secrets {
+            if text.contains(secret.as_str()) {
+                text = text.replace(secret.as_str(), placeholder);
+            }
+        }
+        text
+    }
+
+    fn scrub_files(state: &State, secrets: &[(String, String)]) {
+        if secrets.is_empty() {
+            return;
+        }
+        for path in &state.files {
+            let result = fs::read_to_string(path).and_then(|text| {
+                let scrubbed = Self::scrub(secrets, &text);
+                if scrubbed == text { Ok(()) } else { fs::write(path, scrubbed) }
+            });
+            if let Err(e) = result {
+                log::warn!("cannot redact {}: {}", path.display(), e);
+            }
+        }
+    }
+}
+
+fn entry_headers(headers: &HeaderMap) -> Vec<Header> {
+    headers.iter()
+        .map(|(name, value)| Header { name: name.as_str().to_string(), value: String::from_utf8_lossy(value.as_bytes()).into_owned() })
+        .collect()
+}
+
+fn json_escape(value: &str) -> String {
+    let json = serde_json::to_string(value).unwrap();
+    json[1..json.len() - 1].to_string()
+}
+
+// Percent-encode `value` like a query, or like a form if `space` is "+".
+fn percent_encode(value: &str, space: &str) -> String {
+    value.bytes().map(|b| match b {
+        b'a'..=b'z' | b'A'..=b'Z' | b'0'..=b'9' | b'-' | b'.' | b'_' | b'*' => (b as char).to_string(),
+        b' ' => space.to_string(),
+        _ => format!("%{:02X}", b),
+    }).collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use std::io::{Read, Write};
+    use std::net::{TcpListener, TcpStream};
+    use std::thread;
+
+    use reqwest::blocking::Client;
+
+    use super::*;
+
+    const UID: &str = "20300000001";
+    const PASSWORD: &str = "s3cret pa$$word";
+    const NAME: &str = "张三";
+    const TGT: &str = "TGT-28113-bcf2a0c0e6d1";
+    const TICKET: &str = "ST-98765-a1b2c3d4e5";
+    const SESSION_ID: &str = "5F0A3C1E9B7D2468";
+
+    // Serve a login to UIS, redirected to jwfw with a ticket, and the courses of jwfw, at the returned URL.
+    fn serve() -> String {
+        let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+        let url = format!("http://{}", listener.local_addr().unwrap());
+        let base = url.clone();
+        thread::spawn(move || {
+            for stream in listener.incoming() {
+                let base = base.clone();
+                thread::spawn(move || respond(stream.unwrap(), &base));
+            }
+        });
+        url
+    }
+
+    fn respond(mut stream: TcpStream, base: &str) {
+        let mut request = Vec::new();
+        let mut buf = [0; 4096];
+        // The headers, then the body of the length they give.
+        while !request.windows(4).any(|w| w == b"\r\n\r\n") {
+            let n = stream.read(&mut buf).unwrap();
+            if n == 0 {
+                return;
+            }
+            request.extend_from_slice(&buf[..n]);
+        }
+        let text = String::from_utf8_lossy(&request).into_owned();
+        let head_len = text.find("\r\n\r\n").unwrap() + 4;
+        let length: usize = text.lines()
+            .find_map(|line| line.to_ascii_lowercase().strip_prefix("content-length:").map(|n| n.trim().parse().unwrap()))
+            .unwrap_or(0);
+        while request.len() < head_len + length {
+            let n = stream.read(&mut buf).unwrap();
+            request.extend_from_slice(&buf[..n]);
+        }
+        let line = text.lines().next().unwrap().to_string();
+        let (status, headers, body) = match line.split(' ').take(2).collect::<Vec<_>>()[..] {
+            ["GET", "/authserver/login"] => ("200 OK", format!("Set-Cookie: JSESSIONID={}; Path=/authserver\r\n", SESSION_ID),
+                                              format!(r#"<form><input name="username" value="{}"><input type="hidden" name="lt" value="LT-1"></form>"#, UID)),
+            ["POST", "/authserver/login"] => ("302 Found",
+                                              format!("Set-Cookie: CASTGC={}; Path=/authserver\r\nLocation: {}/eams/home.action?ticket={}\r\n", TGT, base, TICKET),
+                                              String::new()),
+            ["GET", path] if path.starts_with("/eams/home.action") =>
+                ("200 OK", String::new(), format!("<table><tr><td>学号：</td><td>{}</td><td>姓名：</td><td>{}</td></tr></table>", UID, NAME)),
+            ["POST", "/eams/dataQuery.action"] =>
+                ("200 OK", "Content-Type: application/json\r\n".to_string(), format!(r#"{{"XH":"{}","XM":"{}","courses":["COMP130004.03"]}}"#, UID, NAME)),
+            _ => ("404 Not Found", String::new(), String::new()),
+        };
+        let _ = write!(stream, "HTTP/1.1 {}\r\n{}Content-Length: {}\r\nConnection: close\r\n\r\n{}", status, headers, body.len(), body);
+    }
+
+    fn read_files(dir: &PathBuf) -> Vec<String> {
+        let mut paths: Vec<_> = fs::read_dir(dir).unwrap().map(|entry| entry.unwrap().path()).collect();
+        paths.sort();
+        paths.iter().map(|path| fs::read_to_string(path).unwrap()).collect()
+    }
+
+    fn temp_dir(name: &str) -> PathBuf {
+        let dir = std::env::temp_dir().join(format!("libfdu-record-{}-{}", name, std::process::id()));
+        let _ = fs::remove_dir_all(&dir);
+        dir
+    }
+
+    // Log in and fetch the courses through `recorder`, passing the cookies of the session by hand since the client
+    // has no cookie store.
+    fn login_and_courses(recorder: &Recorder, url: &str) {
+        let client = Client::new();
+        let send = |req: Request| client.execute(req);
+        // A session registers the password as it logs in.
+        recorder.secret(Kind::Password, PASSWORD);
+
+        let res = recorder.exchange(client.get(format!("{}/authserver/login", url)).build().unwrap(), None, send).unwrap();
+        assert!(res.text().unwrap().contains("LT-1"));
+        let login = client.post(format!("{}/authserver/login", url))
+            .form(&[("username", UID), ("password", PASSWORD), ("lt", "LT-1")]).build().unwrap();
+        let res = recorder.exchange(login, Some(HeaderValue::from_str(&format!("JSESSIONID={}", SESSION_ID)).unwrap()), send).unwrap();
+        // The response built again is the one of the server, after the redirect.
+        assert!(res.url().as_str().contains(TICKET));
+        assert!(res.text().unwrap().contains(NAME));
+        let courses = client.post(format!("{}/eams/dataQuery.action", url)).form(&[("semester.id", "425")]).build().unwrap();
+        let res = recorder.exchange(courses, Some(HeaderValue::from_str(&format!("CASTGC={}", TGT)).unwrap()), send).unwrap();
+        assert_eq!(res.headers()["content-type"], "application/json");
+        assert!(res.text().unwrap().contains("COMP130004.03"));
+    }
+
+    #[test]
+    fn test_record_redacted() {
+        let url = serve();
+        let dir = temp_dir("redacted");
+        let recorder = Recorder::new(dir.clone(), Redact::Standard).unwrap();
+        login_and_courses(&recorder, &url);
+
+        let files = read_files(&dir);
+        assert_eq!(files.len(), 3);
+        for (i, file) in files.iter().enumerate() {
+            for secret in [UID, PASSWORD, "s3cret+pa%24%24word", "s3cret", NAME, TGT, "TGT-", TICKET, SESSION_ID] {
+                assert!(!file.contains(secret), "{} found in file {}:\n{}", secret, i + 1, file);
+            }
+        }
+        // The same value has the same placeholder in all of the files.
+        assert!(files[1].contains("REDACTED-NAME-1") && files[2].contains("REDACTED-NAME-1"));
+        assert!(files[1].contains("REDACTED-PASSWORD-1"));
+        assert!(files[1].contains("ticket=ST-REDACTED-1"));
+        // The student ID on the login page is only known to be one on the next page, which redacts the first file.
+        assert!(files[0].contains("00000000001") && files[2].contains("00000000001"));
+        let entry: serde_json::Value = serde_json::from_str(&files[2]).unwrap();
+        assert_eq!(entry["request"]["method"], "POST");
+        assert_eq!(entry["request"]["body"], "semester.id=425");
+        assert_eq!(entry["response"]["status"], 200);
+        fs::remove_dir_all(&dir).unwrap();
+    }
+
+    #[test]
+    fn test_record_levels() {
+        let url = serve();
+        let dir = temp_dir("strict");
+        let recorder = Recorder::new(dir.clone(), Redact::Strict).unwrap();
+        login_and_courses(&recorder, &url);
+        let files = read_files(&dir);
+        let entry: serde_json::Value = serde_json::from_str(&files[2]).unwrap();
+        assert_eq!(entry["request"]["body"], "");
+        assert!(!files.iter().any(|file| file.contains(UID) || file.contains(NAME)));
+        fs::remove_dir_all(&dir).unwrap();
+
+        let dir = temp_dir("none");
+        let recorder = Recorder::new(dir.clone(), Redact::None).unwrap();
+        login_and_courses(&recorder, &url);
+        let files = read_files(&dir);
+        assert!(files[1].contains(TICKET) && files[2].contains(NAME));
+        // A recorder in a directory used before numbers its files after the ones there.
+        let recorder = Recorder::new(dir.clone(), Redact::None).unwrap();
+        assert_eq!(recorder.state.lock().unwrap().next, 4);
+        fs::remove_dir_all(&dir).unwrap();
+
+        assert!(matches!(Redact::from_level(0), Ok(Redact::Standard)));
+        assert!(Redact::from_level(3).is_err());
+    }
+
+    #[test]
+    fn test_percent_encode() {
+        assert_eq!(percent_encode("a b$_", "+"), "a+b%24_");
+        assert_eq!(percent_encode("张", "%20"), "%E5%BC%A0");
+    }
+}
@@ -0,0 +1,3 @@
+/*sc 当前人数, lc 人数上限*/
+window.lessonJSONs = [{id:698241,no:'ECON130003.01',name:'国际金融',teachDepartName:'经济学院',code:'ECON130003',credits:3.0,courseId:38081,examTime:'2022-12-27 08:30-10:30 第17周 星期二',examFormName:'闭卷',startWeek:1,endWeek:16,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'郑辉',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:2,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'1-16',rooms:'H3208'}]},{id:698246,no:'ECON130004.02',name:'国际贸易',teachDepartName:'经济学院',code:'ECON130004',credits:3.0,courseId:38082,examTime:'2023-01-03 13:00-15:00 第18周 星期二',examFormName:'闭卷',startWeek:1,endWeek:16,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'程大中',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:1,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'1-16',rooms:'H5102'}]},{id:698257,no:'ECON130022.01',name:'货币经济学',teachDepartName:'经济学院',code:'ECON130022',credits:3.0,courseId:38100,examTime:'2022-12-29 08:30-10:30 第17周 星期四',examFormName:'闭卷',startWeek:1,endWeek:16,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'田素华',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:4,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'1-16',rooms:'HGX509'}]},{id:698251,no:'ECON130010.01',name:'当代中国经济',teachDepartName:'经济学院',code:'ECON130010',credits:3.0,courseId:38088,examTime:'2022-12-28 08:30-10:30 第17周 星期三',examFormName:'闭卷',startWeek:1,endWeek:16,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'陈钊,王永钦,张晏',campusCode:'H',campusName:'邯郸校区',remark:'国家级一流本科课程',arrangeInfo:[{weekDay:3,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'1-16',rooms:'H4305'}]},{id:698260,no:'ECON130042.01',name:'税收学',teachDepartName:'经济学院',code:'ECON130042',credits:3.0,courseId:38120,examTime:'2022-12-28 13:00-15:00 第17周 星期三',examFormName:'闭卷',startWeek:1,endWeek:18,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'余显财',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:5,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'1-16',rooms:'H6108'}]},{id:698275,no:'ECON130128.01',name:'制度经济学',teachDepartName:'经济学院',code:'ECON130128',credits:2.0,courseId:38206,examTime:'2022-12-30 15:30-17:30 第17周 星期五',examFormName:'开卷',startWeek:1,endWeek:16,courseTypeId:12,courseTypeName:'专业选修课程',courseTypeCode:'03_02',scheduled:true,hasTextBook:false,period:36,weekHour:2.0,withdrawable:true,textbooks:'',teachers:'方钦',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:5,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:6,endUnit:7,weekStateDigest:'1-16',rooms:'H6306'}]},{id:698280,no:'MATH120044.02',name:'数学分析BII',teachDepartName:'数学科学学院',code:'MATH120044',credits:5.0,courseId:38311,examTime:'',examFormName:'闭卷',startWeek:1,endWeek:16,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:true,period:90,weekHour:5.0,withdrawable:false,textbooks:'数学分析(第二版)',teachers:'严金海',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:2,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:1,endUnit:2,weekStateDigest:'1-16',rooms:'H2220'},{weekDay:4,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:1,endUnit:3,weekStateDigest:'1-16',rooms:'H2220'}]}];
+window.lessonId2Counts = {'698241':{sc:70,lc:100},'698246':{sc:89,lc:100},'698257':{sc:85,lc:85},'698251':{sc:84,lc:85},'698260':{sc:39,lc:40},'698275':{sc:32,lc:32},'698280':{sc:120,lc:150}};
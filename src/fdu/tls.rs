@@ -0,0 +1,216 @@
+// The TLS settings of `HttpConfig`: extra roots to trust, and pins of the public keys of the servers.
+//
+// Pins are checked by a rustls verifier wrapping the one of webpki, so that a pinned connection is also verified
+// against the roots as usual, and reported as a `CertPinMismatchError` naming the host when no key of its chain is
+// pinned.
+use std::error::Error;
+use std::sync::Arc;
+use std::time::SystemTime;
+
+use base64::Engine;
+use base64::engine::general_purpose::STANDARD;
+use rustls::{Certificate, ClientConfig, OwnedTrustAnchor, RootCertStore, ServerName};
+use rustls::client::{ServerCertVerified, ServerCertVerifier, WebPkiVerifier};
+use sha2::{Digest, Sha256};
+
+use super::prelude::*;
+
+// The prefix of the pins, which are the SHA-256 hashes of the DER SubjectPublicKeyInfo of a certificate, in base64,
+// as printed by `openssl x509 -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64`.
+const PIN_PREFIX: &str = "sha256/";
+
+// The message of a pin mismatch, followed by the host. It goes through rustls and reqwest as text, and is recognized
+// by `pin_mismatch()`.
+const PIN_MISMATCH: &str = "certificate pin mismatch for ";
+
+pub type Pin = [u8; 32];
+
+pub fn parse_pin(pin: &str) -> Result<Pin> {
+    let invalid = |reason: &str| SDKError::with_type(ErrorType::ArgumentError, format!("invalid pin {:?}: {}", pin, reason));
+    let hash = pin.strip_prefix(PIN_PREFIX).ok_or_else(|| invalid("not of the form sha256/<base64>"))?;
+    let hash = STANDARD.decode(hash).map_err(|e| invalid(&e.to_string()))?;
+    hash.try_into().map_err(|hash: Vec<u8>| invalid(&format!("{} bytes, a SHA-256 hash has 32", hash.len())))
+}
+
+// Parse the DER certificates of a PEM bundle, which must hold at least one.
+pub fn parse_certs(pem: &str) -> Result<Vec<Vec<u8>>> {
+    let invalid = |reason: String| SDKError::with_type(ErrorType::ArgumentError, format!("invalid PEM: {}", reason));
+    let mut certs = Vec::new();
+    let mut rest = pem;
+    while let Some(begin) = rest.find("-----BEGIN CERTIFICATE-----") {
+        rest = &rest[begin + "-----BEGIN CERTIFICATE-----".len()..];
+        let end = rest.find("-----END CERTIFICATE-----").ok_or_else(|| invalid("unterminated certificate".to_string()))?;
+        let body: String = rest[..end].chars().filter(|c| !c.is_whitespace()).collect();
+        let der = STANDARD.decode(body).map_err(|e| invalid(e.to_string()))?;
+        if spki(&der).is_none() {
+            Err(invalid(format!("certificate {} is not DER", certs.len() + 1)))?
+        }
+        certs.push(der);
+        rest = &rest[end + "-----END CERTIFICATE-----".len()..];
+    }
+    if certs.is_empty() {
+        Err(invalid("no certificate".to_string()))?
+    }
+    Ok(certs)
+}
+
+// Read a DER element at the start of `der`, returning its tag, its content and what follows it.
+fn der_element(der: &[u8]) -> Option<(u8, &[u8], &[u8])> {
+    let (&tag, der) = der.split_first()?;
+    let (&first, mut der) = der.split_first()?;
+    let len = if first < 0x80 {
+        first as usize
+    } else {
+        // The long form: the low bits count the bytes of the length, which fits a usize in a certificate.
+        let n = (first & 0x7f) as usize;
+        if n == 0 || n > 4 || der.len() < n {
+            return None;
+        }
+        let len = der[..n].iter().fold(0, |len, &b| len << 8 | b as usize);
+        der = &der[n..];
+        len
+    };
+    if der.len() < len {
+        return None;
+    }
+    Some((tag, &der[..len], &der[len..]))
+}
+
+// Find the SubjectPublicKeyInfo of a DER certificate, with its tag and length, which is what pins hash:
+// Certificate ::= SEQUENCE { tbsCertificate SEQUENCE { [0] version OPTIONAL, serialNumber, signature, issuer,
+// validity, subject, subjectPublicKeyInfo, ... }, ... }
+fn spki(cert: &[u8]) -> Option<&[u8]> {
+    const SEQUENCE: u8 = 0x30;
+    let (tag, cert, _) = der_element(cert)?;
+    if tag != SEQUENCE {
+        return None;
+    }
+    let (tag, tbs, _) = der_element(cert)?;
+    if tag != SEQUENCE {
+        return None;
+    }
+    let mut rest = tbs;
+    let (tag, _, after) = der_element(rest)?;
+    if tag == 0xa0 {
+        rest = after;
+    }
+    // serialNumber, signature, issuer, validity and subject.
+    for _ in 0..5 {
+        rest = der_element(rest)?.2;
+    }
+    let (tag, _, after) = der_element(rest)?;
+    if tag != SEQUENCE {
+        return None;
+    }
+    Some(&rest[..rest.len() - after.len()])
+}
+
+pub fn spki_hash(cert: &[u8]) -> Option<Pin> {
+    Some(Sha256::digest(spki(cert)?).into())
+}
+
+// Return the host of a pin mismatch anywhere in the causes of `e`. The verifier can only report a rustls error,
+// which reaches reqwest wrapped in an `io::Error` whose `source()` skips it, so the causes are matched as text.
+pub fn pin_mismatch(e: &(dyn Error + 'static)) -> Option<String> {
+    let mut cause = Some(e);
+    while let Some(e) = cause {
+        let message = e.to_string();
+        if let Some(i) = message.find(PIN_MISMATCH) {
+            let host = &message[i + PIN_MISMATCH.len()..];
+            let end = host.find(|c: char| c.is_whitespace() || "\"'(),".contains(c)).unwrap_or(host.len());
+            return Some(host[..end].to_string());
+        }
+        cause = e.source();
+    }
+    None
+}
+
+// Verifies the chain against the roots, then that the key of one of its certificates is pinned: pinning the key of
+// an intermediate authority lets the server renew its certificate.
+struct PinnedVerifier {
+    inner: WebPkiVerifier,
+    pins: Vec<Pin>,
+}
+
+impl ServerCertVerifier for PinnedVerifier {
+    fn verify_server_cert(&self,
+                          end_entity: &Certificate,
+                          intermediates: &[Certificate],
+                          server_name: &ServerName,
+                          scts: &mut dyn Iterator<Item=&[u8]>,
+                          ocsp_response: &[u8],
+                          now: SystemTime) -> std::result::Result<ServerCertVerified, rustls::Error> {
+        let verified = self.inner.verify_server_cert(end_entity, intermediates, server_name, scts, ocsp_response, now)?;
+        let pinned = std::iter::once(end_entity).chain(intermediates)
+            .filter_map(|cert| spki_hash(&cert.0))
+            .any(|hash| self.pins.contains(&hash));
+        if !pinned {
+            let host = match server_name {
+                ServerName::DnsName(name) => name.as_ref().to_string(),
+                ServerName::IpAddress(ip) => ip.to_string(),
+                _ => "an unknown host".to_string(),
+            };
+            log::error!("{}{}: none of the {} certificates is pinned", PIN_MISMATCH, host, intermediates.len() + 1);
+            return Err(rustls::Error::General(format!("{}{}", PIN_MISMATCH, host)));
+        }
+        Ok(verified)
+    }
+}
+
+// Build the TLS config of a client checking `pins`, trusting the roots of webpki and `roots`, parsed by
+// `parse_certs()`.
+pub fn pinned_config(roots: &[Vec<u8>], pins: Vec<Pin>) -> Result<ClientConfig> {
+    let mut store = RootCertStore::empty();
+    store.add_trust_anchors(webpki_roots::TLS_SERVER_ROOTS.iter().map(|ta| {
+        OwnedTrustAnchor::from_subject_spki_name_constraints(ta.subject, ta.spki, ta.name_constraints)
+    }));
+    for der in roots {
+        store.add(&Certificate(der.clone())).map_err(|e| {
+            SDKError::with_type(ErrorType::ArgumentError, format!("invalid root certificate: {}", e))
+        })?;
+    }
+    let verifier = PinnedVerifier { inner: WebPkiVerifier::new(store, None), pins };
+    let mut config = ClientConfig::builder()
+        .with_safe_defaults()
+        .with_custom_certificate_verifier(Arc::new(verifier))
+        .with_no_client_auth();
+    config.alpn_protocols = vec![b"http/1.1".to_vec()];
+    Ok(config)
+}
+
+#[cfg(test)]
+mod tests {
+    use std::io;
+
+    use super::*;
+
+    // The certificate of testdata/tls_cert.pem, and its pin.
+    const CERT: &str = include_str!("testdata/tls_cert.pem");
+    const CERT_PIN: &str = include_str!("testdata/tls_cert.pin");
+
+    #[test]
+    fn test_parse_pin() {
+        let pin = parse_pin(CERT_PIN.trim()).unwrap();
+        assert_eq!(parse_certs(CERT).unwrap().iter().map(|der| spki_hash(der)).collect::<Vec<_>>(), [Some(pin)]);
+        for pin in ["", "sha1/AAAA", "sha256/not base64!", "sha256/AAAA"] {
+            let err = parse_pin(pin).unwrap_err();
+            assert!(matches!(err.error_type(), ErrorType::ArgumentError), "{}", pin);
+        }
+    }
+
+    #[test]
+    fn test_parse_certs() {
+        assert_eq!(parse_certs(&format!("{}\n{}", CERT, CERT)).unwrap().len(), 2);
+        for pem in ["", "-----BEGIN CERTIFICATE-----\nAAAA", "-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----"] {
+            assert!(matches!(parse_certs(pem).unwrap_err().error_type(), ErrorType::ArgumentError), "{}", pem);
+        }
+    }
+
+    #[test]
+    fn test_pin_mismatch() {
+        let e = io::Error::new(io::ErrorKind::InvalidData, format!("unexpected error: {}uis.fudan.edu.cn", PIN_MISMATCH));
+        assert_eq!(pin_mismatch(&e).as_deref(), Some("uis.fudan.edu.cn"));
+        let e = io::Error::new(io::ErrorKind::InvalidData, "invalid peer certificate: UnknownIssuer");
+        assert_eq!(pin_mismatch(&e), None);
+    }
+}
@@ -0,0 +1,160 @@
+// Timings of the requests of the calls, sent as events to a sink set by the ffi layer, so that callers can trace the
+// calls in their own observability tools, e.g. to tell a slow DNS from a slow jwfw.
+//
+// The ffi layer makes a `Span` the one of the thread running a call with `enter()`, like the calls of `ratelimit`,
+// and the events of the thread carry its request id and trace id. DNS lookups run on the runtime of the client
+// instead, so each session shares the span of its call in flight with its resolver through a `Tracer`, which is
+// right since a session runs one call at a time.
+//
+// reqwest does not expose the TCP and TLS handshakes, so there are no `Connect` and `Tls` events yet: their time is
+// part of the `Request` event of the request opening the connection.
+use std::cell::RefCell;
+use std::net::ToSocketAddrs;
+use std::sync::{Arc, Mutex, RwLock};
+use std::time::{Duration, Instant, SystemTime};
+
+use reqwest::dns::{Addrs, Name, Resolve, Resolving};
+
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum Phase {
+    Dns,
+    Connect,
+    Tls,
+    // From sending a request to the headers of its response.
+    Request,
+    // From the last response of a call to its result: reading the body, parsing it and encoding the result.
+    Parse,
+}
+
+// The call an event belongs to.
+#[derive(Clone, Debug, Default, PartialEq)]
+pub struct Span {
+    // The request id of the `_async` export running the call, 0 for the others.
+    pub request_id: u64,
+    // Set by the caller on the token of the call, empty if not.
+    pub trace_id: String,
+}
+
+pub struct Event<'a> {
+    pub span: &'a Span,
+    pub phase: Phase,
+    pub host: &'a str,
+    pub start: SystemTime,
+    pub duration: Duration,
+    // The HTTP status of a `Request`, 0 if there was no response; the number of addresses found by a `Dns` lookup,
+    // 0 if it failed; the code of the result of the call for `Parse`, see `finish()`.
+    pub status: i32,
+}
+
+pub type Sink = fn(&Event);
+
+static SINK: RwLock<Option<Sink>> = RwLock::new(None);
+
+// Send the events to `sink`, or stop them if it is None.
+pub fn set_sink(sink: Option<Sink>) {
+    *SINK.write().unwrap_or_else(|e| e.into_inner()) = sink;
+}
+
+fn sink() -> Option<Sink> {
+    *SINK.read().unwrap_or_else(|e| e.into_inner())
+}
+
+struct State {
+    span: Span,
+    // When the last response of the call arrived, and from which host, the start of its `Parse`.
+    last_response: Option<(SystemTime, Instant, String)>,
+}
+
+thread_local! {
+    static CURRENT: RefCell<Option<State>> = const { RefCell::new(None) };
+}
+
+// Restores the previous span of the thread when dropped.
+pub struct Scope(Option<State>);
+
+impl Drop for Scope {
+    fn drop(&mut self) {
+        CURRENT.with(|current| *current.borrow_mut() = self.0.take());
+    }
+}
+
+// Make `span` the one of the current thread until the scope is dropped.
+pub fn enter(span: Span) -> Scope {
+    Scope(CURRENT.with(|current| current.replace(Some(State { span, last_response: None }))))
+}
+
+// The span of the current thread, the default one outside of a call.
+pub fn current() -> Span {
+    CURRENT.with(|current| current.borrow().as_ref().map(|state| state.span.clone())).unwrap_or_default()
+}
+
+fn send(sink: Sink, span: &Span, phase: Phase, host: &str, start: (SystemTime, Instant), status: i32) {
+    let duration = start.1.elapsed();
+    sink(&Event { span, phase, host, start: start.0, duration, status });
+}
+
+// Run `f` as the `phase` of a request to `host` of the call of the current thread, with the status given by
+// `status` from the result of `f`.
+pub fn timed<T>(phase: Phase, host: &str, f: impl FnOnce() -> T, status: impl FnOnce(&T) -> i32) -> T {
+    let Some(sink) = sink() else { return f() };
+    let start = (SystemTime::now(), Instant::now());
+    let r = f();
+    send(sink, &current(), phase, host, start, status(&r));
+    if phase == Phase::Request {
+        CURRENT.with(|current| if let Some(state) = current.borrow_mut().as_mut() {
+            state.last_response = Some((SystemTime::now(), Instant::now(), host.to_string()));
+        });
+    }
+    r
+}
+
+// End the call of the current thread with the code of its result, sending its `Parse` if it sent a request.
+pub fn finish(status: i32) {
+    let Some(sink) = sink() else { return };
+    let Some((span, Some((start, begin, host)))) = CURRENT.with(|current| {
+        current.borrow_mut().as_mut().map(|state| (state.span.clone(), state.last_response.take()))
+    }) else { return };
+    send(sink, &span, Phase::Parse, &host, (start, begin), status);
+}
+
+// Shares the span of the call in flight on a session with the resolver of its client.
+#[derive(Clone, Default)]
+pub struct Tracer(Arc<Mutex<Span>>);
+
+impl Tracer {
+    // The resolver of the client of the session, timing its lookups.
+    pub fn resolver(&self) -> Arc<Resolver> {
+        Arc::new(Resolver(Arc::clone(&self.0)))
+    }
+
+    // Hand the span of the current thread to the resolver, before sending a request.
+    pub fn share(&self) {
+        if sink().is_some() {
+            *self.0.lock().unwrap_or_else(|e| e.into_inner()) = current();
+        }
+    }
+}
+
+// Resolves names with the system resolver, like the default one of reqwest, on a blocking thread of the runtime.
+pub struct Resolver(Arc<Mutex<Span>>);
+
+impl Resolve for Resolver {
+    fn resolve(&self, name: Name) -> Resolving {
+        let span = Arc::clone(&self.0);
+        let host = name.as_str().to_string();
+        Box::pin(async move {
+            let lookup = tokio::task::spawn_blocking(move || {
+                let start = (SystemTime::now(), Instant::now());
+                let addrs = (host.as_str(), 0).to_socket_addrs().map(|addrs| addrs.collect::<Vec<_>>());
+                if let Some(sink) = sink() {
+                    let span = span.lock().unwrap_or_else(|e| e.into_inner()).clone();
+                    send(sink, &span, Phase::Dns, &host, start, addrs.as_ref().map_or(0, |addrs| addrs.len() as i32));
+                }
+                addrs
+            });
+            let addrs = lookup.await??;
+            Ok(Box::new(addrs.into_iter()) as Addrs)
+        })
+    }
+}
+
@@ -1,306 +1,315 @@
 use std::collections::HashMap;
-use std::sync::Arc;
-use std::thread;
-use std::time::Duration;
 
 use regex::Regex;
-use reqwest::blocking::Client;
-use reqwest::cookie::Jar;
+use reqwest::blocking::RequestBuilder;
 use scraper::{Html, Selector};
 use serde::{Deserialize, Serialize};
 
-use crate::error::{ErrorType, Result, SDKError};
+use super::prelude::*;
 
-use super::fdu::*;
+impl XkClient for Fdu {}
 
-struct XK {
-    fdu: Fdu,
-    profile_id: i32,
-    courses: Vec<Course>,
-}
+// Visiting it logs in xk through UIS. xk then has its own token, which expires independently of the UIS session.
+const XK_LOGIN_URL: &str = "https://xk.fudan.edu.cn/xk/login.action";
+const XK_HOME_URL: &str = "https://xk.fudan.edu.cn/xk/home.action";
+const XK_ELECT_URL: &str = "https://xk.fudan.edu.cn/xk/stdElectCourse!defaultPage.action";
+const XK_QUERY_LESSON_URL: &str = "https://xk.fudan.edu.cn/xk/stdElectCourse!queryLesson.action";
+const XK_OPERATE_URL: &str = "https://xk.fudan.edu.cn/xk/stdElectCourse!batchOperator.action";
 
-impl XK {
-    fn new() -> Self {
-        Self {
-            fdu: Fdu::new(),
-            profile_id: 0,
-            courses: Vec::new(),
-        }
-    }
-
-    fn new_from_fdu(fdu: Fdu) -> Self {
-        Self {
-            fdu,
-            profile_id: 0,
-            courses: Vec::new(),
-        }
-    }
+#[derive(Debug, Serialize, PartialEq)]
+pub struct SelectableCourse {
+    // The id to enroll with, e.g. 698241
+    lesson_id: i64,
+    // e.g. ECON130003.01
+    course_id: String,
+    // e.g. 国际金融
+    name: String,
+    // e.g. 经济学院
+    department: String,
+    // e.g. 陈钊,王永钦
+    teachers: String,
+    credits: f64,
+    // e.g. 邯郸校区
+    campus: String,
+    // The seats of the course and how many are taken.
+    capacity: i32,
+    enrolled: i32,
 }
 
-impl HttpClient for XK {
-    fn get_client(&self) -> &Client {
-        &self.fdu.get_client()
-    }
-
-    fn get_cookie_store(&self) -> &Arc<Jar> {
-        &self.fdu.get_cookie_store()
-    }
+// Filters of the selectable courses, all optional. `keyword` matches the name, the course id or the teachers.
+// The credits are inclusive bounds, and `max_credits` is unbounded if 0.
+#[derive(Debug, Default, Deserialize, PartialEq)]
+#[serde(default, deny_unknown_fields)]
+pub struct CourseQuery {
+    department: String,
+    keyword: String,
+    min_credits: f64,
+    max_credits: f64,
 }
 
-impl Account for XK {
-    fn set_credentials(&mut self, uid: &str, pwd: &str) {
-        &self.fdu.set_credentials(uid, pwd);
-    }
-
-    fn login(&mut self, uid: &str, pwd: &str) -> Result<()> {
-        self.set_credentials(uid, pwd);
-
-        const LOGIN_URL: &str = "https://xk.fudan.edu.cn/xk/login.action";
-        const LOGIN_SUCCESS_URL: &str = "https://xk.fudan.edu.cn/xk/home.action";
-
-        // login
-        let mut payload = HashMap::new();
-        payload.insert("username", uid);
-        payload.insert("password", pwd);
-        let res = self.get_client().post(LOGIN_URL).form(&payload).send()?;
-        if !res.url().as_str().starts_with(LOGIN_SUCCESS_URL) {
-            return Err(SDKError::with_type(ErrorType::LoginError, "login error".to_string()));
-        }
-
-        // sleep
-        thread::sleep(Duration::from_millis(1500));
-
-        // get profile id
-        const XK_URL: &str = "https://xk.fudan.edu.cn/xk/stdElectCourse!defaultPage.action";
-        let html = self.get_client().get(XK_URL).send()?.text()?;
-        let document = Html::parse_document(html.as_str());
-        let selector = Selector::parse(r#"input[type="hidden"]"#).unwrap();
-        if let Some(element) = document.select(&selector).next() {
-            self.profile_id = element.value().attr("value").unwrap_or_default().parse::<i32>().unwrap_or_default();
-            if self.profile_id == 0 {
-                return Err(SDKError::with_type(ErrorType::ParseError, "get profile id error".to_string()));
-            }
-        } else {
-            return Err(SDKError::with_type(ErrorType::ParseError, "get profile id error".to_string()));
-        }
-
-        // sleep
-        thread::sleep(Duration::from_millis(1500));
-
-        // access XK_URL otherwise we couldn't get courses
-        let mut payload = HashMap::new();
-        payload.insert("electionProfile.id", self.profile_id);
-        let res = self.get_client().post(XK_URL).form(&payload).send()?;
-        if res.status() != 200 {
-            return Err(SDKError::with_type(ErrorType::LoginError, "access xk page error".to_string()));
-        }
-        Ok(())
+impl CourseQuery {
+    fn matches(&self, course: &SelectableCourse) -> bool {
+        (self.department.is_empty() || course.department == self.department)
+            && (self.keyword.is_empty() || [&course.name, &course.course_id, &course.teachers].iter().any(|s| s.contains(&self.keyword)))
+            && course.credits >= self.min_credits
+            && (self.max_credits == 0.0 || course.credits <= self.max_credits)
     }
+}
 
-    fn logout(&self) -> Result<()> {
-        const LOGOUT_URL: &str = "https://xk.fudan.edu.cn/xk/logout.action";
-        let res = self.get_client().get(LOGOUT_URL).send()?;
-        if res.status() != 200 {
-            return Err(SDKError::with_type(ErrorType::LoginError, "logout failed".to_string()));
-        }
-        Ok(())
-    }
+#[derive(Debug, Serialize, PartialEq)]
+#[serde(rename_all = "snake_case")]
+pub enum Outcome {
+    Enrolled,
+    // Enrolled on the waiting list of the course, or in its lottery.
+    Waitlisted,
+    Full,
+    TimeConflict,
+    CreditLimit,
+    Dropped,
 }
 
-#[derive(Serialize, Default)]
-struct CourseQuery {
-    #[serde(rename = "lessonNo")]
-    no: String,
-    // eg. ECON130213.01
-    #[serde(rename = "courseCode")]
-    code: String,
-    // eg. ECON130213
-    #[serde(rename = "courseName")]
-    name: String, // eg. 计量经济学
+// The outcome of an enrollment or a drop, with the message of xk.
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Selection {
+    outcome: Outcome,
+    message: String,
+    // Set when xk reports the course full while its quota is being refreshed, so that seats may free up shortly.
+    retry_later: bool,
 }
 
-#[derive(Deserialize, Default, Debug, Clone)]
-struct Course {
-    id: i32,
-    // eg. 123456
+// The lessons of queryLesson.action are in a javascript array of objects, followed by an object of the seats by
+// lesson id, both with unquoted keys and single-quoted strings:
+// [{id:698241,no:'ECON130003.01',name:'国际金融',teachDepartName:'经济学院',...}] ... {'698241':{sc:70,lc:100}}
+#[derive(Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RawLesson {
+    id: i64,
     no: String,
-    // eg. ECON130213.01
-    code: String,
-    // eg. ECON130213
     name: String,
-    // eg. 计量经济学
     #[serde(default)]
-    amount: AmountInfo,
+    teach_depart_name: String,
+    #[serde(default)]
+    teachers: String,
+    #[serde(default)]
+    credits: f64,
+    #[serde(default)]
+    campus_name: String,
 }
 
-#[derive(Deserialize, Default, Debug, Clone)]
-struct AmountInfo {
-    #[serde(rename = "lc")]
-    total: i32,
-    #[serde(rename = "sc")]
-    selected: i32,
+#[derive(Deserialize, Default, Clone)]
+struct RawSeats {
+    // 限选人数 and 已选人数
+    lc: i32,
+    sc: i32,
 }
 
-impl XK {
-    fn query_course(&self, query: &CourseQuery) -> Result<Vec<Course>> {
-        const QUERY_COURSE_URL: &str = "https://xk.fudan.edu.cn/xk/stdElectCourse!queryLesson.action";
-        let res = self.get_client().
-            post(QUERY_COURSE_URL).
-            query(&[("profileId", self.profile_id)]).
-            form(query).
-            send()?;
-        let status_code = res.status();
-        let html = res.text()?;
-        if status_code != 200 {
-            return Err(SDKError::with_type(ErrorType::NetworkError, format!("status code: {}\ntext: {}", status_code, html)));
+// Quote the keys of a javascript object literal and replace its single quotes, to parse it as JSON.
+fn normalize_json(json: &str) -> String {
+    let r1 = Regex::new(r"([a-zA-Z]+?):").unwrap();
+    let mut result = r1.replace_all(json, "\"${1}\":").to_string();
+    result = result.replace("'", "\"");
+    result
+}
+
+fn parse_lessons(text: &str) -> Result<Vec<SelectableCourse>> {
+    let regex = Regex::new(r"(\[.+])[\s\S]*?(\{.+})").unwrap();
+    let cap = regex.captures(text)
+        .ok_or(SDKError::with_type(ErrorType::ParseError, "no lesson found in the response of xk".to_string()))?;
+    let lessons: Vec<RawLesson> = serde_json::from_str(&normalize_json(&cap[1]))?;
+    let seats: HashMap<String, RawSeats> = serde_json::from_str(&normalize_json(&cap[2]))?;
+    Ok(lessons.into_iter().map(|lesson| {
+        let seats = seats.get(&lesson.id.to_string()).cloned().unwrap_or_default();
+        SelectableCourse {
+            lesson_id: lesson.id,
+            course_id: lesson.no,
+            name: lesson.name,
+            department: lesson.teach_depart_name,
+            teachers: lesson.teachers,
+            credits: lesson.credits,
+            campus: lesson.campus_name,
+            capacity: seats.lc,
+            enrolled: seats.sc,
         }
+    }).collect())
+}
 
-        let r = Regex::new(r"(\[.+])[\s\S]*?(\{.+})").unwrap();
-        let cap = r.captures(html.as_str()).ok_or(SDKError::with_type(ErrorType::ParseError, "parse course error".to_string()))?;
-        let courses_str = normalize_json(
-            cap.get(1).ok_or(SDKError::with_type(ErrorType::ParseError, "course_str does not exist".to_string()))?.as_str()
-        );
-        let amounts_str = normalize_json(
-            cap.get(2).ok_or(SDKError::with_type(ErrorType::ParseError, "amounts_str does not exist".to_string()))?.as_str()
-        );
+// Parse the profile id of the course selection open to the student from the hidden input of XK_ELECT_URL.
+fn parse_profile_id(html: &str) -> Result<i64> {
+    let document = Html::parse_document(html);
+    let selector = Selector::parse(r#"input[type="hidden"]"#).unwrap();
+    document.select(&selector).next()
+        .and_then(|element| element.value().attr("value"))
+        .and_then(|value| value.parse::<i64>().ok())
+        .filter(|&id| id > 0)
+        .ok_or(SDKError::with_type(ErrorType::ParseError, "no course selection open".to_string()))
+}
 
-        let mut courses: Vec<Course> = serde_json::from_str(courses_str.as_str())?;
-        let amounts: HashMap<&str, AmountInfo> = serde_json::from_str(amounts_str.as_str())?;
-        for course in &mut courses {
-            if let Some(amount) = amounts.get(course.id.to_string().as_str()) {
-                course.amount = amount.clone();
-            }
-        }
-        Ok(courses)
-    }
+// Parse the response of XK_OPERATE_URL, whose first div holds a message like "国际金融(ECON130003.01) 选课成功".
+// The messages are matched from the most specific, e.g. a waiting list is entered with a successful message.
+fn parse_selection(html: &str, enroll: bool) -> Result<Selection> {
+    let document = Html::parse_document(html);
+    let selector = Selector::parse("div").unwrap();
+    let mut message: String = document.select(&selector).next()
+        .ok_or(SDKError::with_type(ErrorType::ParseError, "no message in the response of xk".to_string()))?
+        .text().collect();
+    message.retain(|c| !c.is_whitespace());
+
+    let outcome = if message.contains("冲突") {
+        Outcome::TimeConflict
+    } else if message.contains("学分") && (message.contains("上限") || message.contains("超过")) {
+        Outcome::CreditLimit
+    } else if message.contains("已满") {
+        Outcome::Full
+    } else if message.contains("候补") || message.contains("待抽签") {
+        Outcome::Waitlisted
+    } else if message.contains("成功") {
+        if enroll { Outcome::Enrolled } else { Outcome::Dropped }
+    } else {
+        // e.g. outside the selection period, or dropping a course not enrolled in.
+        Err(SDKError::with_type(ErrorType::OtherError, format!("xk: {}", message)))?
+    };
+    let retry_later = outcome == Outcome::Full && message.contains("刷新");
+    Ok(Selection { outcome, message, retry_later })
+}
 
-    fn get_courses(&mut self) -> Result<Vec<Course>> {
-        if self.courses.len() > 0 {
-            return Ok(self.courses.clone());
+pub trait XkClient: Account {
+    // Send a request to xk, and return its page, unless xk redirected it because its token expired or was never set:
+    // xk then logs in again through UIS and lands on its home page, or on the login page of UIS.
+    fn send_to_xk(&self, builder: RequestBuilder) -> Result<String> {
+        let request = builder.build()?;
+        let path = request.url().path().to_string();
+        let res = self.execute(request)?;
+        if res.url().path() != path {
+            Err(SDKError::with_type(ErrorType::LoginError, "not logged in xk".to_string()))?
         }
-        let courses = self.query_course(&CourseQuery::default())?;
-        self.courses = courses;
-        Ok(self.courses.clone())
+        Ok(res.text()?)
     }
 
-    fn get_id(&mut self, query: &CourseQuery, courses: Vec<Course>) -> Result<i32> {
-        for course in courses {
-            if course.no == query.no || course.code == query.code || course.name == query.name {
-                return Ok(course.id);
-            }
+    // Log in xk and open the course selection, returning the id of its profile, which the other methods take.
+    fn enter_xk(&self) -> Result<i64> {
+        let res = self.execute(self.get(XK_LOGIN_URL).build()?)?;
+        if !res.url().as_str().starts_with(&base_url::resolve(XK_HOME_URL)) {
+            Err(SDKError::with_type(ErrorType::LoginError, "xk login failed".to_string()))?
         }
-        Err(SDKError::with_type(ErrorType::OtherError, "id not found".to_string()))
+        let profile_id = parse_profile_id(&self.send_to_xk(self.get(XK_ELECT_URL))?)?;
+        // xk only answers the queries of a profile once its page is open.
+        self.send_to_xk(self.post(XK_ELECT_URL).form(&[("electionProfile.id", profile_id)]))?;
+        Ok(profile_id)
     }
 
-    fn operate_course(&self, id: i32, select: bool) -> Result<bool> {
-        // select: true -> select, false -> drop
-
-        const OPERATE_COURSE_URL: &str = "https://xk.fudan.edu.cn/xk/stdElectCourse!batchOperator.action";
-        let mut payload = HashMap::new();
-        let mut operator0 = String::new();
-        if select {
-            payload.insert("optype", "true");
-            operator0 = format!("{}:true:0", id);
-        } else {
-            payload.insert("optype", "false");
-            operator0 = format!("{}:false", id);
-        }
-        payload.insert("operator0", operator0.as_str());
-
-        let mut html = self.get_client().
-            post(OPERATE_COURSE_URL).
-            query(&[("profileId", self.profile_id)]).
-            form(&payload).
-            send()?.text()?;
-
-        let document = Html::parse_document(html.as_str());
-        let selector = Selector::parse("div").unwrap();
-        html = document.select(&selector).next().
-            ok_or(SDKError::with_type(ErrorType::ParseError, "operate course result".to_string()))?.
-            text().collect();
-        html.retain(|c| !c.is_whitespace());
-
-        println!("{}", html);
-        Ok(html.contains("成功"))
+    fn get_selectable_courses(&self, profile_id: i64, query: &CourseQuery) -> Result<Vec<SelectableCourse>> {
+        let text = self.send_to_xk(self.post(XK_QUERY_LESSON_URL).query(&[("profileId", profile_id)]).form(&[
+            ("lessonNo", ""),
+            ("courseCode", ""),
+            ("courseName", ""),
+        ]))?;
+        let mut courses = parse_lessons(&text)?;
+        courses.retain(|course| query.matches(course));
+        Ok(courses)
     }
 
-    fn single_select(&mut self, query: &CourseQuery, select: bool) -> Result<bool> {
-        let courses = self.query_course(query)?;
-        let id = self.get_id(query, courses)?;
-        self.operate_course(id, select)
+    // Enroll in a lesson, or drop it if `enroll` is false.
+    fn select_course(&self, profile_id: i64, lesson_id: i64, enroll: bool) -> Result<Selection> {
+        let operator = if enroll { format!("{}:true:0", lesson_id) } else { format!("{}:false", lesson_id) };
+        let html = self.send_to_xk(self.post(XK_OPERATE_URL).query(&[("profileId", profile_id)]).form(&[
+            ("optype", if enroll { "true" } else { "false" }),
+            ("operator0", operator.as_str()),
+        ]))?;
+        parse_selection(&html, enroll)
     }
 }
 
-fn normalize_json(json: &str) -> String {
-    let r1 = Regex::new(r"([a-zA-Z]+?):").unwrap();
-    let mut result = r1.replace_all(json, "\"${1}\":").to_string();
-    result = result.replace("'", "\"");
-    result
-}
-
 #[cfg(test)]
 mod tests {
-    use crate::fdu::jwfw::JwfwClient;
-
     use super::*;
 
     #[test]
-    fn test_login_and_out() {
+    fn test_select() {
         dotenv::dotenv().ok();  // load env from .env file
         let uid = std::env::var("UID").expect("environment variable UID not set");
         let pwd = std::env::var("PWD").expect("environment variable PWD not set");
 
-        let mut xk = XK::new();
-        xk.login(uid.as_str(), pwd.as_str()).expect("login error");
-        xk.logout().expect("logout error");
+        let mut fd = Fdu::new();
+        fd.login(uid.as_str(), pwd.as_str()).expect("login error");
+        let profile_id = fd.enter_xk().expect("xk login error");
+        let query = CourseQuery { keyword: "中国史前考古".to_string(), ..Default::default() };
+        let courses = fd.get_selectable_courses(profile_id, &query).expect("query course error");
+        println!("{:?}", courses);
+        let lesson_id = courses.first().expect("course not found").lesson_id;
+        println!("{:?}", fd.select_course(profile_id, lesson_id, true).expect("select course error"));
+        println!("{:?}", fd.select_course(profile_id, lesson_id, false).expect("drop course error"));
+        fd.logout().expect("logout error");
     }
 
     #[test]
-    fn test_get_course() {
-        dotenv::dotenv().ok();  // load env from .env file
-        let uid = std::env::var("UID").expect("environment variable UID not set");
-        let pwd = std::env::var("PWD").expect("environment variable PWD not set");
-
-        let mut xk = XK::new();
-        xk.login(uid.as_str(), pwd.as_str()).expect("login error");
-
-        let courses = xk.get_courses().expect("query course error");
-        println!("{:?}", courses);
-
-        xk.logout().expect("logout error");
+    fn test_parse_lessons() {
+        let courses = parse_lessons(include_str!("testdata/xk_lessons.js")).unwrap();
+        assert_eq!(courses.len(), 7);
+        assert_eq!(courses[0], SelectableCourse {
+            lesson_id: 698241,
+            course_id: "ECON130003.01".to_string(),
+            name: "国际金融".to_string(),
+            department: "经济学院".to_string(),
+            teachers: "郑辉".to_string(),
+            credits: 3.0,
+            campus: "邯郸校区".to_string(),
+            capacity: 100,
+            enrolled: 70,
+        });
+        assert_eq!(courses[3].teachers, "陈钊,王永钦,张晏");
+        assert!(parse_lessons("<html>系统繁忙</html>").is_err());
     }
 
     #[test]
-    fn test_select() {
-        dotenv::dotenv().ok();  // load env from .env file
-        let uid = std::env::var("UID").expect("environment variable UID not set");
-        let pwd = std::env::var("PWD").expect("environment variable PWD not set");
+    fn test_course_query() {
+        let courses = parse_lessons(include_str!("testdata/xk_lessons.js")).unwrap();
+        let matching = |query: CourseQuery| -> Vec<i64> {
+            courses.iter().filter(|course| query.matches(course)).map(|course| course.lesson_id).collect()
+        };
+        assert_eq!(matching(CourseQuery::default()).len(), 7);
+        assert_eq!(matching(CourseQuery { keyword: "王永钦".to_string(), ..Default::default() }), [698251]);
+        assert_eq!(matching(CourseQuery { keyword: "ECON13000".to_string(), ..Default::default() }), [698241, 698246]);
+        assert_eq!(matching(CourseQuery { department: "数学科学学院".to_string(), ..Default::default() }), [698280]);
+        assert_eq!(matching(CourseQuery { min_credits: 4.0, ..Default::default() }), [698280]);
+        assert_eq!(matching(CourseQuery { max_credits: 2.0, ..Default::default() }), [698275]);
+        let query: CourseQuery = serde_json::from_str(r#"{"department": "经济学院", "min_credits": 3}"#).unwrap();
+        assert_eq!(matching(query).len(), 5);
+        assert!(serde_json::from_str::<CourseQuery>(r#"{"credits": 3}"#).is_err());
+    }
 
-        let mut xk = XK::new();
-        xk.login(uid.as_str(), pwd.as_str()).expect("login error");
+    #[test]
+    fn test_parse_profile_id() {
+        let html = r#"<form><input type="hidden" name="electionProfile.id" value="2071"/></form>"#;
+        assert_eq!(parse_profile_id(html).unwrap(), 2071);
+        assert!(parse_profile_id("<p>选课未开放</p>").is_err());
+    }
 
-        let query = CourseQuery {
-            name: "中国史前考古".to_string(),
-            ..Default::default()
+    #[test]
+    fn test_parse_selection() {
+        let selection = |html: &str, enroll: bool| {
+            let s = parse_selection(html, enroll).unwrap();
+            (s.outcome, s.retry_later)
         };
-        xk.single_select(&query, true).expect("select course error");
-        thread::sleep(Duration::from_millis(1500));
-        xk.single_select(&query, false).expect("select course error");
-
-        xk.logout().expect("logout error");
+        assert_eq!(selection(include_str!("testdata/xk_enrolled.html"), true), (Outcome::Enrolled, false));
+        assert_eq!(selection(include_str!("testdata/xk_waitlisted.html"), true), (Outcome::Waitlisted, false));
+        assert_eq!(selection(include_str!("testdata/xk_full.html"), true), (Outcome::Full, false));
+        assert_eq!(selection(include_str!("testdata/xk_full_refreshing.html"), true), (Outcome::Full, true));
+        assert_eq!(selection(include_str!("testdata/xk_time_conflict.html"), true), (Outcome::TimeConflict, false));
+        assert_eq!(selection(include_str!("testdata/xk_credit_limit.html"), true), (Outcome::CreditLimit, false));
+        assert_eq!(selection(include_str!("testdata/xk_dropped.html"), false), (Outcome::Dropped, false));
+
+        let s = parse_selection(include_str!("testdata/xk_enrolled.html"), true).unwrap();
+        assert_eq!(s.message, "国际金融(ECON130003.01)选课成功");
+        let e = parse_selection(include_str!("testdata/xk_closed.html"), true).unwrap_err();
+        assert_eq!(e.to_string(), "xk: 不在选课时间内");
+        assert!(parse_selection("<html></html>", true).is_err());
     }
 
     #[test]
     fn test_normalize_json() {
-        const COURSE: &str = "[{id:698241,no:'ECON130003.01',name:'国际金融',teachDepartName:'经济学院',code:'ECON130003',credits:3.0,courseId:38081,examTime:'2022-12-27 08:30-10:30 第17周 星期二',examFormName:'闭卷',startWeek:1,endWeek:16,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'郑辉',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:2,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'1-16',rooms:'H3208'}]},{id:698246,no:'ECON130004.02',name:'国际贸易',teachDepartName:'经济学院',code:'ECON130004',credits:3.0,courseId:38082,examTime:'2023-01-03 13:00-15:00 第18周 星期二',examFormName:'闭卷',startWeek:1,endWeek:16,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'程大中',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:1,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'1-16',rooms:'H5102'}]},{id:698257,no:'ECON130022.01',name:'货币经济学',teachDepartName:'经济学院',code:'ECON130022',credits:3.0,courseId:38100,examTime:'2022-12-29 08:30-10:30 第17周 星期四',examFormName:'闭卷',startWeek:1,endWeek:16,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'田素华',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:4,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'1-16',rooms:'HGX509'}]},{id:698251,no:'ECON130010.01',name:'当代中国经济',teachDepartName:'经济学院',code:'ECON130010',credits:3.0,courseId:38088,examTime:'2022-12-28 08:30-10:30 第17周 星期三',examFormName:'闭卷',startWeek:11,endWeek:16,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'陈钊,王永钦,张晏',campusCode:'H',campusName:'邯郸校区',remark:'国家级一流本科课程',arrangeInfo:[{weekDay:3,weekState:'00000011111000000000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'6-10',rooms:'H4305'},{weekDay:3,weekState:'01111100000000000000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'1-5',rooms:'H4305'},{weekDay:3,weekState:'00000000000111111000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'11-16',rooms:'H4305'}]},{id:698260,no:'ECON130042.01',name:'税收学',teachDepartName:'经济学院',code:'ECON130042',credits:3.0,courseId:38120,examTime:'2022-12-28 13:00-15:00 第17周 星期三',examFormName:'闭卷',startWeek:1,endWeek:18,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'余显财',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:5,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:3,endUnit:5,weekStateDigest:'1-16',rooms:'H6108'}]},{id:698266,no:'ECON130064.01',name:'博弈论',teachDepartName:'经济学院',code:'ECON130064',credits:3.0,courseId:38142,examTime:'2023-01-05 13:00-15:00 第18周 星期四',examFormName:'闭卷',startWeek:1,endWeek:16,courseTypeId:7,courseTypeName:'专业必修课程',courseTypeCode:'03_01',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'朱弘鑫',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:4,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:6,endUnit:8,weekStateDigest:'1-16',rooms:'H6212'}]},{id:698275,no:'ECON130128.01',name:'制度经济学',teachDepartName:'经济学院',code:'ECON130128',credits:3.0,courseId:38206,examTime:'2022-12-30 15:30-17:30 第17周 星期五',examFormName:'开卷',startWeek:1,endWeek:16,courseTypeId:12,courseTypeName:'专业选修课程',courseTypeCode:'03_02',scheduled:true,hasTextBook:false,period:54,weekHour:3.0,withdrawable:true,textbooks:'',teachers:'方钦',campusCode:'H',campusName:'邯郸校区',remark:'',arrangeInfo:[{weekDay:5,weekState:'01111111111111111000000000000000000000000000000000000',startUnit:6,endUnit:8,weekStateDigest:'1-16',rooms:'H6306'}]}]";
-        const AMOUNT: &str = "{'698241':{sc:70,lc:100},'698246':{sc:89,lc:100},'698257':{sc:74,lc:85},'698251':{sc:85,lc:85},'698260':{sc:39,lc:40},'698266':{sc:93,lc:93},'698275':{sc:32,lc:32}}";
-        let course_str = normalize_json(COURSE);
-        let amount_str = normalize_json(AMOUNT);
-        println!("{}\n{}", course_str, amount_str);
-
-        let course: Vec<Course> = serde_json::from_str(&course_str).unwrap();
-        let amount: HashMap<String, AmountInfo> = serde_json::from_str(&amount_str).unwrap();
-        println!("{:?}", course);
-        println!("{:?}", amount);
+        let json = normalize_json("[{id:698241,no:'ECON130003.01',examTime:'2022-12-27 08:30-10:30'}]");
+        assert_eq!(json, r#"[{"id":698241,"no":"ECON130003.01","examTime":"2022-12-27 08:30-10:30"}]"#);
+        let seats: HashMap<String, RawSeats> = serde_json::from_str(&normalize_json("{'698241':{sc:70,lc:100}}")).unwrap();
+        assert_eq!((seats["698241"].sc, seats["698241"].lc), (70, 100));
     }
-}
\ No newline at end of file
+}
@@ -0,0 +1,412 @@
+// The graduate school system (研究生系统), yjsxt, which has the course table, the exams and the scores of graduate
+// students, as jwfw has those of undergraduates. The exports of the academic system go to one or the other by the
+// `StudentType` of the session, and return the same JSON for both, see ffi/jwfw.rs.
+//
+// yjsxt is made of apps answering JSON like {"code": "0", "datas": {"<query>": {"rows": [...]}}}, with the
+// pinyin abbreviations of the fields as keys, e.g. KCMC for 课程名称.
+use std::collections::HashMap;
+
+use serde::{Deserialize, Serialize};
+use serde::de::DeserializeOwned;
+
+use super::grade::{grade_to_point, PlanProgress, GPA};
+use super::jwfw::{CourseData, Exam, Score, Semester};
+use super::prelude::*;
+
+impl GraduateClient for Fdu {}
+
+// Visiting it logs in yjsxt through UIS.
+const YJSXT_HOME_URL: &str = "https://yjsxt.fudan.edu.cn/gsapp/sys/yjsemaphome/portal/index.do";
+const YJSXT_USER_URL: &str = "https://yjsxt.fudan.edu.cn/gsapp/sys/yjsemaphome/modules/pubWork/getUserInfo.do";
+const YJSXT_SEMESTERS_URL: &str = "https://yjsxt.fudan.edu.cn/gsapp/sys/wdkbapp/modules/xskcb/xnxqcx.do";
+const YJSXT_COURSES_URL: &str = "https://yjsxt.fudan.edu.cn/gsapp/sys/wdkbapp/modules/xskcb/xspkjgcx.do";
+const YJSXT_EXAMS_URL: &str = "https://yjsxt.fudan.edu.cn/gsapp/sys/wdksapp/modules/wdks/wdkscx.do";
+const YJSXT_SCORES_URL: &str = "https://yjsxt.fudan.edu.cn/gsapp/sys/wdcjapp/modules/wdcj/xscjcx.do";
+const YJSXT_PROGRESS_URL: &str = "https://yjsxt.fudan.edu.cn/gsapp/sys/pyjhapp/modules/pyjhwcqk/xspyjhwcqk.do";
+
+#[derive(Clone, Copy, Debug, Deserialize, PartialEq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum StudentType {
+    Undergraduate,
+    // Master and doctoral students alike.
+    Graduate,
+}
+
+impl StudentType {
+    // Tell the type of a student from the student id, e.g. 23210240001, whose third digit is 3 for undergraduates,
+    // 2 for master students and 1 for doctoral students. Other ids are taken as undergraduates, which callers can
+    // override with `fdu_session_set_student_type()`.
+    pub fn of(student_id: &str) -> Self {
+        let graduate = student_id.len() == 11
+            && student_id.bytes().all(|b| b.is_ascii_digit())
+            && matches!(student_id.as_bytes()[2], b'1' | b'2');
+        if graduate { StudentType::Graduate } else { StudentType::Undergraduate }
+    }
+
+    pub fn parse(s: &str) -> Result<Self> {
+        match s {
+            "undergraduate" => Ok(StudentType::Undergraduate),
+            "graduate" => Ok(StudentType::Graduate),
+            _ => Err(SDKError::with_type(ErrorType::ArgumentError, format!("unknown student type {:?}", s))),
+        }
+    }
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+pub struct Profile {
+    pub(crate) name: String,
+    pub(crate) student_id: String,
+    // e.g. 计算机科学技术学院
+    pub(crate) department: String,
+    pub(crate) student_type: StudentType,
+}
+
+#[derive(Deserialize)]
+struct Response<T> {
+    code: String,
+    #[serde(default)]
+    msg: String,
+    #[serde(default = "HashMap::new")]
+    datas: HashMap<String, Rows<T>>,
+}
+
+#[derive(Deserialize)]
+struct Rows<T> {
+    rows: Vec<T>,
+}
+
+// Return the rows of a query, whatever its name, or the message of yjsxt if the query failed.
+fn parse_rows<T: DeserializeOwned>(text: &str) -> Result<Vec<T>> {
+    let response: Response<T> = serde_json::from_str(text)
+        .map_err(|e| SDKError::with_type(ErrorType::ParseError, format!("unexpected answer of yjsxt: {}", e)))?;
+    if response.code != "0" {
+        Err(SDKError::with_type(ErrorType::ParseError, format!("yjsxt failed with code {}: {}", response.code, response.msg)))?
+    }
+    Ok(response.datas.into_values().flat_map(|rows| rows.rows).collect())
+}
+
+#[derive(Deserialize)]
+struct RawUser {
+    #[serde(rename = "XH")]
+    student_id: String,
+    #[serde(rename = "XM")]
+    name: String,
+    #[serde(rename = "YXMC", default)]
+    department: String,
+}
+
+fn parse_profile(text: &str) -> Result<Profile> {
+    #[derive(Deserialize)]
+    struct UserResponse {
+        code: String,
+        data: Option<RawUser>,
+    }
+    let response: UserResponse = serde_json::from_str(text)
+        .map_err(|e| SDKError::with_type(ErrorType::ParseError, format!("unexpected user info of yjsxt: {}", e)))?;
+    match response.data {
+        Some(user) if response.code == "0" => Ok(Profile {
+            name: user.name,
+            student_id: user.student_id,
+            department: user.department,
+            student_type: StudentType::Graduate,
+        }),
+        _ => Err(SDKError::with_type(ErrorType::ParseError, format!("no user info from yjsxt, code {}", response.code))),
+    }
+}
+
+// Split a semester code, e.g. 20231 for the first term of 2023-2024, into the school year and the name of the term
+// as jwfw has them, so that both systems describe semesters alike.
+fn split_semester(code: &str) -> Result<(String, String)> {
+    let invalid = || SDKError::with_type(ErrorType::ParseError, format!("invalid semester {:?}", code));
+    if code.len() != 5 || !code.bytes().all(|b| b.is_ascii_digit()) {
+        return Err(invalid());
+    }
+    let year: i32 = code[..4].parse().map_err(|_| invalid())?;
+    let name = match &code[4..] {
+        "1" => "1",
+        "2" => "2",
+        "3" => "暑期",
+        _ => return Err(invalid()),
+    };
+    Ok((format!("{}-{}", year, year + 1), name.to_string()))
+}
+
+#[derive(Deserialize)]
+struct RawSemester {
+    #[serde(rename = "XNXQDM")]
+    code: String,
+}
+
+// The codes of the semesters are their ids, e.g. 20231, which are the valid values of `semester_id` for graduate
+// students.
+fn parse_semesters(text: &str) -> Result<Vec<Semester>> {
+    let mut semesters = Vec::new();
+    for raw in parse_rows::<RawSemester>(text)? {
+        let (school_year, name) = split_semester(&raw.code)?;
+        semesters.push(Semester { id: raw.code, school_year, name });
+    }
+    Ok(semesters)
+}
+
+#[derive(Deserialize)]
+struct RawCourse {
+    #[serde(rename = "BJDM")]
+    course_id: String,
+    #[serde(rename = "KCMC")]
+    name: String,
+    #[serde(rename = "JSXM", default)]
+    teacher: String,
+    #[serde(rename = "JASMC", default)]
+    location: String,
+    #[serde(rename = "XQ")]
+    weekday: i32,
+    #[serde(rename = "KSJCDM")]
+    start_slot: i32,
+    #[serde(rename = "JSJCDM")]
+    end_slot: i32,
+    // The weeks as 0 and 1, the first for week 1, unlike the ones of jwfw.
+    #[serde(rename = "ZCBH")]
+    weeks: String,
+}
+
+// Each row is a lesson already, with consecutive slots merged.
+fn parse_courses(text: &str) -> Result<Vec<CourseData>> {
+    let mut courses = Vec::new();
+    for raw in parse_rows::<RawCourse>(text)? {
+        if !(1..=7).contains(&raw.weekday) || raw.start_slot < 1 || raw.start_slot > raw.end_slot {
+            Err(SDKError::with_type(ErrorType::ParseError, format!("course {}: invalid time {} {}-{}",
+                                                                   raw.course_id, raw.weekday, raw.start_slot, raw.end_slot)))?
+        }
+        let weeks = raw.weeks.chars().enumerate().filter(|&(_, c)| c == '1').map(|(i, _)| i as i32 + 1).collect();
+        courses.push(CourseData {
+            course_id: raw.course_id,
+            name: raw.name,
+            teacher: raw.teacher,
+            location: raw.location,
+            weekday: raw.weekday,
+            start_slot: raw.start_slot,
+            end_slot: raw.end_slot,
+            weeks,
+        });
+    }
+    Ok(courses)
+}
+
+#[derive(Deserialize)]
+struct RawExam {
+    #[serde(rename = "BJDM")]
+    course_id: String,
+    #[serde(rename = "KCMC")]
+    name: String,
+    #[serde(rename = "KSLXMC", default)]
+    r#type: String,
+    // e.g. 2024-01-10 and 08:30-10:30, empty if not scheduled yet.
+    #[serde(rename = "KSRQ", default)]
+    date: String,
+    #[serde(rename = "KSSJ", default)]
+    time: String,
+    #[serde(rename = "JASMC", default)]
+    location: String,
+    #[serde(rename = "ZWH", default)]
+    seat: String,
+    #[serde(rename = "BZ", default)]
+    note: String,
+}
+
+fn parse_exams(text: &str) -> Result<Vec<Exam>> {
+    Ok(parse_rows::<RawExam>(text)?.into_iter().map(|raw| Exam {
+        course_id: raw.course_id,
+        name: raw.name,
+        r#type: raw.r#type,
+        date: raw.date,
+        // jwfw separates the start and the end with ~.
+        time: raw.time.replacen('-', "~", 1),
+        location: raw.location,
+        seat: raw.seat,
+        note: raw.note,
+    }).collect())
+}
+
+#[derive(Deserialize)]
+struct RawScore {
+    #[serde(rename = "XNXQDM")]
+    semester: String,
+    #[serde(rename = "BJDM")]
+    course_id: String,
+    #[serde(rename = "KCMC")]
+    name: String,
+    #[serde(rename = "XF")]
+    credit: f64,
+    #[serde(rename = "CJ")]
+    grade: String,
+}
+
+fn parse_scores(text: &str) -> Result<Vec<Score>> {
+    let mut scores = Vec::new();
+    for raw in parse_rows::<RawScore>(text)? {
+        let (school_year, term) = split_semester(&raw.semester)?;
+        scores.push(Score {
+            semester: format!("{} {}", school_year, term),
+            course_id: raw.course_id,
+            name: raw.name,
+            credit: raw.credit,
+            point: grade_to_point(&raw.grade),
+            grade: raw.grade,
+        });
+    }
+    Ok(scores)
+}
+
+#[derive(Deserialize)]
+struct RawProgress {
+    #[serde(rename = "ZYMC", default)]
+    major: String,
+    #[serde(rename = "YQZXF")]
+    required_credits: f64,
+    #[serde(rename = "YHZXF")]
+    earned_credits: f64,
+    // 平均学分绩, the GPA.
+    #[serde(rename = "PJXFJ")]
+    gpa: f64,
+}
+
+// Graduate students are not ranked: the GPA comes with the progress in the training plan instead.
+fn parse_gpa(text: &str) -> Result<GPA> {
+    let raw = parse_rows::<RawProgress>(text)?.into_iter().next()
+        .ok_or(SDKError::with_type(ErrorType::ParseError, "no training plan found".to_string()))?;
+    Ok(GPA {
+        gpa: raw.gpa,
+        credits: raw.earned_credits,
+        major: raw.major,
+        progress: Some(PlanProgress { required_credits: raw.required_credits, earned_credits: raw.earned_credits }),
+        ..GPA::default()
+    })
+}
+
+pub trait GraduateClient: Account {
+    fn get_yjsxt_homepage(&self) -> Result<()> {
+        self.execute(self.get(YJSXT_HOME_URL).build()?)?;
+        Ok(())
+    }
+
+    fn get_graduate_profile(&self) -> Result<Profile> {
+        parse_profile(&self.send_and_get_text(self.post(YJSXT_USER_URL))?)
+    }
+
+    fn get_graduate_semesters(&self) -> Result<Vec<Semester>> {
+        let semesters = parse_semesters(&self.send_and_get_text(self.post(YJSXT_SEMESTERS_URL))?)?;
+        if semesters.is_empty() {
+            return Err(SDKError::with_type(ErrorType::ParseError, "no semester found".to_string()));
+        }
+        Ok(semesters)
+    }
+
+    fn get_graduate_courses(&self, semester_id: &str) -> Result<Vec<CourseData>> {
+        parse_courses(&self.send_and_get_text(self.post(YJSXT_COURSES_URL).form(&[("XNXQDM", semester_id)]))?)
+    }
+
+    fn get_graduate_exams(&self, semester_id: &str) -> Result<Vec<Exam>> {
+        parse_exams(&self.send_and_get_text(self.post(YJSXT_EXAMS_URL).form(&[("XNXQDM", semester_id)]))?)
+    }
+
+    fn get_graduate_scores(&self, semester_id: &str) -> Result<Vec<Score>> {
+        parse_scores(&self.send_and_get_text(self.post(YJSXT_SCORES_URL).form(&[("XNXQDM", semester_id)]))?)
+    }
+
+    fn get_graduate_gpa(&self) -> Result<GPA> {
+        parse_gpa(&self.send_and_get_text(self.post(YJSXT_PROGRESS_URL))?)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_student_type() {
+        assert_eq!(StudentType::of("20300000001"), StudentType::Undergraduate);
+        assert_eq!(StudentType::of("23210240001"), StudentType::Graduate);
+        assert_eq!(StudentType::of("22110240003"), StudentType::Graduate);
+        for id in ["", "123", "2321024000x", "232102400011"] {
+            assert_eq!(StudentType::of(id), StudentType::Undergraduate, "{}", id);
+        }
+        assert_eq!(StudentType::parse("graduate").unwrap(), StudentType::Graduate);
+        assert!(matches!(StudentType::parse("master").unwrap_err().error_type(), ErrorType::ArgumentError));
+    }
+
+    #[test]
+    fn test_parse_profile() {
+        assert_eq!(parse_profile(include_str!("testdata/yjsxt_profile.json")).unwrap(), Profile {
+            name: "李四".to_string(),
+            student_id: "23210240001".to_string(),
+            department: "计算机科学技术学院".to_string(),
+            student_type: StudentType::Graduate,
+        });
+        assert!(parse_profile(r#"{"code":"-1","data":null}"#).is_err());
+    }
+
+    #[test]
+    fn test_parse_semesters() {
+        assert_eq!(parse_semesters(include_str!("testdata/yjsxt_semesters.json")).unwrap(), vec![
+            Semester { id: "20231".to_string(), school_year: "2023-2024".to_string(), name: "1".to_string() },
+            Semester { id: "20232".to_string(), school_year: "2023-2024".to_string(), name: "2".to_string() },
+        ]);
+        assert!(split_semester("20234").is_err());
+        assert!(split_semester("385").is_err());
+    }
+
+    #[test]
+    fn test_parse_courses() {
+        let courses = parse_courses(include_str!("testdata/yjsxt_courses.json")).unwrap();
+        assert_eq!(courses[0], CourseData {
+            course_id: "COMP620001.01".to_string(),
+            name: "高级算法".to_string(),
+            teacher: "张老师".to_string(),
+            location: "H3209".to_string(),
+            weekday: 2,
+            start_slot: 6,
+            end_slot: 8,
+            weeks: (1..=16).collect(),
+        });
+        assert_eq!(courses[1].weeks, vec![1, 3, 5, 7, 9, 11, 13, 15]);
+        assert_eq!(courses[1].location, "");
+    }
+
+    #[test]
+    fn test_parse_exams() {
+        let exams = parse_exams(include_str!("testdata/yjsxt_exams.json")).unwrap();
+        assert_eq!(exams.len(), 2);
+        assert_eq!((exams[0].date.as_str(), exams[0].time.as_str()), ("2024-01-10", "08:30~10:30"));
+        assert_eq!((exams[1].date.as_str(), exams[1].time.as_str()), ("", ""));
+    }
+
+    #[test]
+    fn test_parse_scores() {
+        let scores = parse_scores(include_str!("testdata/yjsxt_scores.json")).unwrap();
+        assert_eq!(scores.len(), 3);
+        assert_eq!(scores[0], Score {
+            semester: "2023-2024 1".to_string(),
+            course_id: "COMP620001.01".to_string(),
+            name: "高级算法".to_string(),
+            credit: 3.0,
+            grade: "A".to_string(),
+            point: Some(4.0),
+        });
+        assert_eq!(scores[2].point, None);
+        assert!(parse_scores(r#"{"code":"-1","msg":"会话已失效"}"#).is_err());
+    }
+
+    #[test]
+    fn test_parse_gpa() {
+        let gpa = parse_gpa(include_str!("testdata/yjsxt_progress.json")).unwrap();
+        assert_eq!(gpa, GPA {
+            gpa: 3.71,
+            ranking: None,
+            total: None,
+            percentage: None,
+            credits: 21.0,
+            major: "计算机科学与技术".to_string(),
+            progress: Some(PlanProgress { required_credits: 32.0, earned_credits: 21.0 }),
+        });
+    }
+}
@@ -0,0 +1,93 @@
+#[cfg(feature = "alloc-stats")]
+use std::alloc::{GlobalAlloc, Layout, System};
+use std::sync::atomic::{AtomicI64, Ordering};
+
+use serde::Serialize;
+
+use super::result::*;
+
+static LIVE_ALLOCATIONS: AtomicI64 = AtomicI64::new(0);
+static LIVE_BYTES: AtomicI64 = AtomicI64::new(0);
+static TOTAL_ALLOCATIONS: AtomicI64 = AtomicI64::new(0);
+
+// The system allocator, counting the allocations of the library so that callers can check that they free everything
+// they are given (strings, results, buffers...), which their own memory statistics cannot see.
+//
+// It is only the global allocator with the `alloc-stats` feature, since every allocation then updates the counters.
+#[cfg(feature = "alloc-stats")]
+struct Counting;
+
+#[cfg(feature = "alloc-stats")]
+unsafe impl GlobalAlloc for Counting {
+    unsafe fn alloc(&self, layout: Layout) -> *mut u8 {
+        let p = System.alloc(layout);
+        if !p.is_null() {
+            allocated(layout.size());
+        }
+        p
+    }
+
+    unsafe fn alloc_zeroed(&self, layout: Layout) -> *mut u8 {
+        let p = System.alloc_zeroed(layout);
+        if !p.is_null() {
+            allocated(layout.size());
+        }
+        p
+    }
+
+    unsafe fn dealloc(&self, ptr: *mut u8, layout: Layout) {
+        System.dealloc(ptr, layout);
+        LIVE_ALLOCATIONS.fetch_sub(1, Ordering::Relaxed);
+        LIVE_BYTES.fetch_sub(layout.size() as i64, Ordering::Relaxed);
+    }
+
+    // A reallocation moves the same allocation, so only its size changes.
+    unsafe fn realloc(&self, ptr: *mut u8, layout: Layout, new_size: usize) -> *mut u8 {
+        let p = System.realloc(ptr, layout, new_size);
+        if !p.is_null() {
+            LIVE_BYTES.fetch_add(new_size as i64 - layout.size() as i64, Ordering::Relaxed);
+        }
+        p
+    }
+}
+
+#[cfg(feature = "alloc-stats")]
+fn allocated(size: usize) {
+    LIVE_ALLOCATIONS.fetch_add(1, Ordering::Relaxed);
+    LIVE_BYTES.fetch_add(size as i64, Ordering::Relaxed);
+    TOTAL_ALLOCATIONS.fetch_add(1, Ordering::Relaxed);
+}
+
+#[cfg(feature = "alloc-stats")]
+#[global_allocator]
+static ALLOCATOR: Counting = Counting;
+
+#[derive(Serialize)]
+pub(crate) struct AllocStats {
+    live_allocations: i64,
+    live_bytes: i64,
+    total_allocations: i64,
+}
+
+// The statistics so far, None unless the library is built with the `alloc-stats` feature.
+pub(crate) fn stats() -> Option<AllocStats> {
+    cfg!(feature = "alloc-stats").then(|| AllocStats {
+        live_allocations: LIVE_ALLOCATIONS.load(Ordering::Relaxed),
+        live_bytes: LIVE_BYTES.load(Ordering::Relaxed),
+        total_allocations: TOTAL_ALLOCATIONS.load(Ordering::Relaxed),
+    })
+}
+
+// Return the allocation statistics of the library as a JSON object `{"live_allocations", "live_bytes",
+// "total_allocations"}`, where `live_*` are the allocations not freed yet and `total_allocations` counts every
+// allocation so far. They are read before the result is allocated, so that the result of a previous call, once freed,
+// does not count.
+//
+// The value is NULL unless the library is built with the `alloc-stats` feature.
+#[no_mangle]
+pub extern "C" fn fdu_alloc_stats() -> *mut FduResult {
+    guard(|| match stats() {
+        Some(stats) => FduResult::from_json(Ok(stats)),
+        None => FduResult::empty(),
+    })
+}
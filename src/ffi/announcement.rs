@@ -0,0 +1,40 @@
+use libc::*;
+
+use crate::fdu::announcement::{self, Source};
+
+use super::cancel::*;
+use super::jobs::{self, *};
+use super::result::*;
+use super::retry::{self, retried};
+
+// Return the announcements of a public feed newer than `since_id`, the newest first, as a JSON array of
+// `{"id", "title", "url", "date", "department"}`. With a `since_id` of 0, return those of the first page.
+// No session is needed. `source` is one of "jwc" (教务处) or "gs" (研究生院); others fail with
+// `FduErrorCode::InvalidArgument`, whose message lists the valid ones.
+//
+// `id` grows with each new announcement and stays when it is edited and re-dated, so pass the largest one seen.
+#[no_mangle]
+pub extern "C" fn fdu_announcements(source: *const c_char, since_id: u64, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::current(), token, || try {
+        let source = Source::parse(borrow_str(source, "source")?)?;
+        FduCancelToken::check(token)?;
+        announcement::get_announcements(source, since_id)?
+    })))
+}
+
+// The `_async` variant of `fdu_announcements()`. An unknown source is rejected at once.
+#[no_mangle]
+pub extern "C" fn fdu_announcements_async(source: *const c_char,
+                                          since_id: u64,
+                                          token: *const FduCancelToken,
+                                          request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let source = owned_str(source, "source")?;
+        Source::parse(source.to_str().unwrap())?;
+        // Tokens are `Sync`, so sending the pointer is fine while the caller keeps it alive.
+        let token = token as usize;
+        jobs::spawn(request_id, token as *const FduCancelToken, move || {
+            fdu_announcements(source.as_ptr(), since_id, token as *const FduCancelToken)
+        });
+    }))
+}
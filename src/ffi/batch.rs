@@ -0,0 +1,228 @@
+use std::ffi::{CStr, CString};
+use std::thread;
+
+use libc::*;
+use serde::{Deserialize, Serialize};
+use serde_json::Value;
+
+use crate::error::*;
+
+use super::cancel::*;
+use super::ecard::*;
+use super::jobs::{self, *};
+use super::jwfw::*;
+use super::library::*;
+use super::pe::*;
+use super::result::*;
+use super::session::*;
+
+// Most requests a batch may hold, since each of them runs on its own thread.
+const MAX_REQUESTS: usize = 32;
+
+// A request of `fdu_batch()`: the export it calls, without the `fdu_` prefix, and the arguments of that export.
+#[derive(Debug, PartialEq, Deserialize)]
+#[serde(tag = "method", content = "args", rename_all = "snake_case", deny_unknown_fields)]
+enum BatchRequest {
+    CardBalance,
+    Semesters,
+    AcademicCalendar,
+    Courses(SemesterArgs),
+    Exams(SemesterArgs),
+    Scores(SemesterArgs),
+    Gpa,
+    EmptyClassrooms(ClassroomArgs),
+    LibraryAreas,
+    LibrarySeats(AreaArgs),
+    PeRecords,
+    PeTestScores,
+}
+
+#[derive(Debug, PartialEq, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct SemesterArgs {
+    semester_id: String,
+}
+
+#[derive(Debug, PartialEq, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct ClassroomArgs {
+    campus: String,
+    date: String,
+    start_slot: i32,
+    end_slot: i32,
+}
+
+#[derive(Debug, PartialEq, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct AreaArgs {
+    area_id: i64,
+}
+
+// The result of a request, like an `FduResult` whose value is embedded as JSON.
+#[derive(Serialize)]
+struct BatchResult {
+    code: i32,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    value: Option<Value>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    message: Option<String>,
+}
+
+impl BatchResult {
+    fn err(e: SDKError) -> Self {
+        BatchResult { code: FduErrorCode::from(e.error_type()) as i32, value: None, message: Some(e.to_string()) }
+    }
+
+    // Take the result of an export, which is freed.
+    fn take(r: *mut FduResult) -> Self {
+        let string = |s: *mut c_char| (!s.is_null()).then(|| unsafe { CStr::from_ptr(s) }.to_string_lossy().into_owned());
+        let (code, value, message) = unsafe { ((*r).code, string((*r).value), string((*r).message)) };
+        free_result(r);
+        if code != FduErrorCode::Ok as i32 {
+            return BatchResult { code, value: None, message };
+        }
+        match value.map(|v| serde_json::from_str(&v)).transpose() {
+            Ok(value) => BatchResult { code, value, message: None },
+            Err(e) => BatchResult { code: FduErrorCode::Parse as i32, value: None, message: Some(e.to_string()) },
+        }
+    }
+}
+
+fn parse_request(request: Value) -> Result<BatchRequest> {
+    serde_json::from_value(request)
+        .map_err(|e| SDKError::with_type(ErrorType::ArgumentError, format!("invalid request: {}", e)))
+}
+
+fn c_arg(s: &str, name: &str) -> Result<CString> {
+    CString::new(s).map_err(|_| SDKError::with_type(ErrorType::ArgumentError, format!("{} contains NUL", name)))
+}
+
+// Run a request through the export it names, so that it behaves exactly like a call of that export.
+fn run(session: *const FduSession, request: BatchRequest, token: *const FduCancelToken) -> Result<*mut FduResult> {
+    Ok(match request {
+        BatchRequest::CardBalance => fdu_card_balance(session, token),
+        BatchRequest::Semesters => fdu_semesters(session, token),
+        BatchRequest::AcademicCalendar => fdu_academic_calendar(session, token),
+        BatchRequest::Courses(args) => fdu_courses(session, c_arg(&args.semester_id, "semester_id")?.as_ptr(), token),
+        BatchRequest::Exams(args) => fdu_exams(session, c_arg(&args.semester_id, "semester_id")?.as_ptr(), token),
+        BatchRequest::Scores(args) => fdu_scores(session, c_arg(&args.semester_id, "semester_id")?.as_ptr(), token),
+        BatchRequest::Gpa => fdu_gpa(session, token),
+        BatchRequest::EmptyClassrooms(args) => {
+            let campus = c_arg(&args.campus, "campus")?;
+            let date = c_arg(&args.date, "date")?;
+            fdu_empty_classrooms(session, campus.as_ptr(), date.as_ptr(), args.start_slot, args.end_slot, token)
+        }
+        BatchRequest::LibraryAreas => fdu_library_areas(session, token),
+        BatchRequest::LibrarySeats(args) => fdu_library_seats(session, args.area_id, token),
+        BatchRequest::PeRecords => fdu_pe_records(session, token),
+        BatchRequest::PeTestScores => fdu_pe_test_scores(session, token),
+    })
+}
+
+// Run several requests at once, given as a JSON array of `{"method", "args"}`, where `method` names the export to
+// call without the `fdu_` prefix (e.g. "courses") and `args` is an object of its arguments but the session and the
+// token (e.g. `{"semester_id": "443"}`), omitted if there is none. At most 32 requests are accepted.
+//
+// The requests run concurrently, and the value is a JSON array of their results in the same order, each a JSON
+// object `{"code", "value", "message"}` like an `FduResult`, except that `value` is the JSON returned by the export
+// instead of a string of it. A failed request, including one with an unknown method or invalid arguments, only has
+// its own result failed: the whole batch only fails if `requests` is not a JSON array.
+//
+// The requests share the session, which is not thread safe in general but only read by the exports of a batch, so
+// the caller still serializes the calls on the session as usual.
+#[no_mangle]
+pub extern "C" fn fdu_batch(session: *const FduSession, requests: *const c_char, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(try {
+        FduSession::borrow(session)?;
+        let requests: Vec<Value> = serde_json::from_str(borrow_str(requests, "requests")?)
+            .map_err(|e| SDKError::with_type(ErrorType::ArgumentError, format!("invalid requests: {}", e)))?;
+        if requests.len() > MAX_REQUESTS {
+            Err(SDKError::with_type(ErrorType::ArgumentError,
+                                    format!("{} requests, at most {} are accepted", requests.len(), MAX_REQUESTS)))?;
+        }
+        // The session only holds `Sync` state as far as the exports of a batch are concerned, and tokens are `Sync`.
+        let (session, token) = (session as usize, token as usize);
+        thread::scope(|scope| {
+            let threads: Vec<_> = requests.into_iter().map(|request| scope.spawn(move || {
+                // The requests share the deadline of the batch.
+                let _call = FduCancelToken::enter(token as *const FduCancelToken);
+                match parse_request(request).and_then(|request| {
+                    run(session as *const FduSession, request, token as *const FduCancelToken)
+                }) {
+                    Ok(r) => BatchResult::take(r),
+                    Err(e) => BatchResult::err(e),
+                }
+            })).collect();
+            // The exports never panic, they return `FduErrorCode::Panic`.
+            threads.into_iter().map(|t| t.join().unwrap()).collect::<Vec<_>>()
+        })
+    }))
+}
+
+// The `_async` variant of `fdu_batch()`.
+#[no_mangle]
+pub extern "C" fn fdu_batch_async(session: *const FduSession,
+                                  requests: *const c_char,
+                                  token: *const FduCancelToken,
+                                  request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        let requests = owned_str(requests, "requests")?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_batch(handles.session(), requests.as_ptr(), handles.token())
+        });
+    }))
+}
+
+#[cfg(test)]
+mod tests {
+    use serde_json::json;
+
+    use crate::fdu::prelude::*;
+
+    use super::*;
+
+    #[test]
+    fn test_parse_request() {
+        assert_eq!(parse_request(json!({"method": "card_balance"})).unwrap(), BatchRequest::CardBalance);
+        assert_eq!(parse_request(json!({"method": "courses", "args": {"semester_id": "443"}})).unwrap(),
+                   BatchRequest::Courses(SemesterArgs { semester_id: "443".to_string() }));
+        assert_eq!(parse_request(json!({"method": "library_seats", "args": {"area_id": 7}})).unwrap(),
+                   BatchRequest::LibrarySeats(AreaArgs { area_id: 7 }));
+        for invalid in [json!({"method": "login"}),
+                        json!({"method": "courses"}),
+                        json!({"method": "courses", "args": {"semester_id": 443}}),
+                        json!({"method": "courses", "args": {"semester_id": "443", "page": 1}}),
+                        json!({"method": "gpa", "extra": true}),
+                        json!("gpa")] {
+            let e = parse_request(invalid.clone()).unwrap_err();
+            assert_eq!(FduErrorCode::from(e.error_type()), FduErrorCode::InvalidArgument, "{}", invalid);
+        }
+    }
+
+    #[test]
+    fn test_batch() {
+        let session = Box::into_raw(Box::new(FduSession::new(Fdu::new())));
+        // A cancelled token fails the requests before they reach the network.
+        let token = fdu_cancel_token_new();
+        fdu_cancel(token);
+        let requests = CString::new(r#"[{"method": "card_balance"}, {"method": "nope"}, {"method": "scores"}]"#).unwrap();
+        let r = fdu_batch(session, requests.as_ptr(), token);
+        let results: Value = unsafe {
+            assert_eq!((*r).code, FduErrorCode::Ok as i32);
+            serde_json::from_str(CStr::from_ptr((*r).value).to_str().unwrap()).unwrap()
+        };
+        free_result(r);
+        let codes: Vec<_> = results.as_array().unwrap().iter().map(|r| r["code"].as_i64().unwrap()).collect();
+        assert_eq!(codes, [FduErrorCode::Cancelled as i64, FduErrorCode::InvalidArgument as i64, FduErrorCode::InvalidArgument as i64]);
+        assert!(results[0].get("value").is_none());
+
+        let invalid = CString::new(r#"{"method": "gpa"}"#).unwrap();
+        let r = fdu_batch(session, invalid.as_ptr(), token);
+        assert_eq!(unsafe { (*r).code }, FduErrorCode::InvalidArgument as i32);
+        free_result(r);
+
+        fdu_cancel_token_free(token);
+        fdu_session_free(session);
+    }
+}
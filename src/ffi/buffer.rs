@@ -0,0 +1,84 @@
+use std::ptr;
+use std::sync::atomic::{AtomicI64, Ordering};
+
+use crate::error::*;
+
+use super::result::*;
+
+// Number of buffers not freed yet. Tests use it to check that callers do not leak buffers.
+pub(crate) static LIVE_BUFFERS: AtomicI64 = AtomicI64::new(0);
+
+// Binary data returned to the caller, e.g. an image. Unlike strings, it may contain NUL bytes and is not terminated.
+//
+// Exports return buffers through an out parameter `FduBuffer **out`, next to the usual `FduResult`,
+// and the caller must release them with `free_buffer()`. `data` is NULL if `len` is 0.
+#[repr(C)]
+pub struct FduBuffer {
+    pub data: *mut u8,
+    pub len: usize,
+}
+
+impl FduBuffer {
+    pub(crate) fn from_vec(v: Vec<u8>) -> *mut FduBuffer {
+        LIVE_BUFFERS.fetch_add(1, Ordering::Relaxed);
+        let len = v.len();
+        let data = if len == 0 {
+            ptr::null_mut()
+        } else {
+            // A boxed slice has no spare capacity, so it can be rebuilt from (data, len) alone.
+            Box::into_raw(v.into_boxed_slice()) as *mut u8
+        };
+        Box::into_raw(Box::new(FduBuffer { data, len }))
+    }
+
+    // Store `v` into the out parameter `out` of an export.
+    pub(crate) fn write_to(out: *mut *mut FduBuffer, v: Vec<u8>) -> Result<()> {
+        if out.is_null() {
+            return Err(SDKError::with_type(ErrorType::ArgumentError, "out is NULL".to_string()));
+        }
+        unsafe { *out = FduBuffer::from_vec(v) };
+        Ok(())
+    }
+}
+
+// Borrow binary data passed in by the caller, as a pointer and a length. `data` may be NULL if `len` is 0.
+pub(crate) fn borrow_bytes<'a>(data: *const u8, len: usize, name: &str) -> Result<&'a [u8]> {
+    if len == 0 {
+        return Ok(&[]);
+    }
+    if data.is_null() {
+        return Err(SDKError::with_type(ErrorType::ArgumentError, format!("{} is NULL", name)));
+    }
+    Ok(unsafe { std::slice::from_raw_parts(data, len) })
+}
+
+#[no_mangle]
+pub extern "C" fn free_buffer(buf: *mut FduBuffer) {
+    guard_or((), || {
+        if buf.is_null() {
+            return;
+        }
+        let buf = unsafe { Box::from_raw(buf) };
+        if !buf.data.is_null() {
+            drop(unsafe { Box::from_raw(ptr::slice_from_raw_parts_mut(buf.data, buf.len)) });
+        }
+        LIVE_BUFFERS.fetch_sub(1, Ordering::Relaxed);
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_buffer_round_trip() {
+        for v in [vec![], vec![0u8, 1, 0, 2, 0], vec![7u8; 1 << 20]] {
+            let buf = FduBuffer::from_vec(v.clone());
+            unsafe {
+                assert_eq!((*buf).len, v.len());
+                assert_eq!(borrow_bytes((*buf).data, (*buf).len, "data").unwrap(), &v[..]);
+            }
+            free_buffer(buf);
+        }
+    }
+}
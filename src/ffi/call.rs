@@ -0,0 +1,232 @@
+// A fast path for the exports returning small values at once: `fdu_call_into()` writes the value into a buffer of the
+// caller instead of returning an `FduResult`, so that the common case is one call across the boundary, without the
+// `free_result()` call nor any allocation of the result.
+use std::io::{self, Write};
+use std::{ptr, slice};
+
+use libc::*;
+
+use crate::error::*;
+
+use super::alloc;
+use super::result::*;
+use super::session::*;
+use super::testing;
+
+// The exports which `fdu_call_into()` can call, in its `call` argument. Each call returns the same value as its
+// export.
+#[repr(i32)]
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum FduCall {
+    // `fdu_alloc_stats()`.
+    AllocStats = 1,
+    // `fdu_session_rate_limit_stats()`.
+    SessionRateLimitStats = 2,
+    // `fdu_session_uid()`.
+    SessionUid = 3,
+    // `fdu_test_session_ping()`.
+    TestSessionPing = 4,
+    // Return `arg` as is, only in debug builds, for callers to test their buffers.
+    TestEcho = 5,
+}
+
+impl TryFrom<i32> for FduCall {
+    type Error = SDKError;
+
+    fn try_from(call: i32) -> Result<Self> {
+        Ok(match call {
+            1 => FduCall::AllocStats,
+            2 => FduCall::SessionRateLimitStats,
+            3 => FduCall::SessionUid,
+            4 => FduCall::TestSessionPing,
+            5 => FduCall::TestEcho,
+            _ => Err(SDKError::with_type(ErrorType::ArgumentError, format!("unknown call {}", call)))?,
+        })
+    }
+}
+
+// Where a call writes its value: into the buffer of the caller while it fits, and then into `spill`, which gets all
+// of it for the fallback.
+struct Output<'a> {
+    buf: &'a mut [u8],
+    len: usize,
+    spill: Option<Vec<u8>>,
+}
+
+impl Output<'_> {
+    fn push(&mut self, data: &[u8]) {
+        match &mut self.spill {
+            Some(spill) => spill.extend_from_slice(data),
+            None if data.len() <= self.buf.len() - self.len => {
+                self.buf[self.len..self.len + data.len()].copy_from_slice(data);
+                self.len += data.len();
+            }
+            None => {
+                let mut spill = Vec::with_capacity(2 * (self.len + data.len()));
+                spill.extend_from_slice(&self.buf[..self.len]);
+                spill.extend_from_slice(data);
+                self.spill = Some(spill);
+            }
+        }
+    }
+}
+
+impl Write for Output<'_> {
+    fn write(&mut self, data: &[u8]) -> io::Result<usize> {
+        self.push(data);
+        Ok(data.len())
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        Ok(())
+    }
+}
+
+fn check_debug_build() -> Result<()> {
+    if !testing::ENABLED {
+        Err(SDKError::with_type(ErrorType::OtherError, testing::RELEASE_BUILD.to_string()))?
+    }
+    Ok(())
+}
+
+fn run(call: FduCall, session: *const FduSession, arg: *const c_char, output: &mut Output) -> Result<()> {
+    match call {
+        FduCall::AllocStats => {
+            if let Some(stats) = alloc::stats() {
+                serde_json::to_writer(output, &stats)?
+            }
+        }
+        FduCall::SessionRateLimitStats => serde_json::to_writer(output, &FduSession::borrow(session)?.fdu.rate_limit_stats())?,
+        FduCall::SessionUid => serde_json::to_writer(output, FduSession::borrow(session)?.fdu.uid().unwrap_or_default())?,
+        FduCall::TestSessionPing => {
+            check_debug_build()?;
+            let session = FduSession::borrow(session)?;
+            session.pings.set(session.pings.get() + 1);
+            serde_json::to_writer(output, &session.pings.get())?
+        }
+        FduCall::TestEcho => {
+            check_debug_build()?;
+            let arg = if arg.is_null() { "" } else { borrow_str(arg, "arg")? };
+            output.push(arg.as_bytes())
+        }
+    }
+    Ok(())
+}
+
+// Make the call `call`, an `FduCall`, e.g. `FduCall::SessionUid` on `session`, and write its value into `buf` of `cap`
+// bytes, without a NUL terminator. `session` and `arg` are only used by the calls which take them, and may be NULL
+// otherwise; a NULL `arg` is the empty string.
+//
+// Return the length of the value. If it fits in `cap` bytes, it is written into `buf` and `*out` is set to NULL.
+// Otherwise `*out` is set to the result of the call, as returned by its export, which the caller frees with
+// `free_result()` as usual: the value did not fit, or the call failed, in which case 0 is returned. A call without a
+// value, like `fdu_alloc_stats()` without the `alloc-stats` feature, returns 0 with `*out` NULL. `out` must not be
+// NULL.
+#[no_mangle]
+pub extern "C" fn fdu_call_into(call: i32,
+                                session: *const FduSession,
+                                arg: *const c_char,
+                                buf: *mut u8,
+                                cap: size_t,
+                                out: *mut *mut FduResult) -> size_t {
+    let mut len = 0;
+    let r = guard(|| {
+        let buf = if buf.is_null() || cap == 0 { &mut [][..] } else { unsafe { slice::from_raw_parts_mut(buf, cap) } };
+        let mut output = Output { buf, len: 0, spill: None };
+        match FduCall::try_from(call).and_then(|call| run(call, session, arg, &mut output)) {
+            Err(e) => FduResult::from_error(e),
+            Ok(()) => match output.spill {
+                None => {
+                    len = output.len;
+                    ptr::null_mut()
+                }
+                Some(spill) => {
+                    len = spill.len();
+                    // Only JSON and the UTF-8 `arg` are ever written.
+                    FduResult::ok(String::from_utf8(spill).unwrap())
+                }
+            },
+        }
+    });
+    if out.is_null() {
+        free_result(r);
+    } else {
+        unsafe { *out = r };
+    }
+    len
+}
+
+#[cfg(test)]
+mod tests {
+    use std::ffi::{CStr, CString};
+
+    use super::super::testing::*;
+    use super::*;
+
+    // Call `call` with a buffer of `cap` bytes, and return the value, whether it came through the buffer, and the
+    // code of the result otherwise.
+    fn call_into(call: i32, session: *const FduSession, arg: &str, cap: usize) -> (String, bool, i32) {
+        let arg = CString::new(arg).unwrap();
+        let mut buf = vec![0u8; cap];
+        let mut out = ptr::null_mut();
+        let len = fdu_call_into(call, session, arg.as_ptr(), buf.as_mut_ptr(), cap, &mut out);
+        if out.is_null() {
+            return (String::from_utf8(buf[..len].to_vec()).unwrap(), true, 0);
+        }
+        let (value, code) = unsafe {
+            let value = (*out).value;
+            ((!value.is_null()).then(|| CStr::from_ptr(value).to_str().unwrap().to_string()).unwrap_or_default(), (*out).code)
+        };
+        free_result(out);
+        if code == FduErrorCode::Ok as i32 {
+            assert_eq!(len, value.len());
+        } else {
+            assert_eq!(len, 0);
+        }
+        (value, false, code)
+    }
+
+    #[test]
+    fn test_call_into() {
+        let echo = FduCall::TestEcho as i32;
+        let value = "x".repeat(64);
+        assert_eq!(call_into(echo, ptr::null(), &value, 64), (value.clone(), true, 0));
+        assert_eq!(call_into(echo, ptr::null(), &value, 63), (value.clone(), false, 0));
+        assert_eq!(call_into(echo, ptr::null(), &value, 0), (value.clone(), false, 0));
+        assert_eq!(call_into(echo, ptr::null(), "", 0), (String::new(), true, 0));
+        assert_eq!(call_into(echo, ptr::null(), "", 16), (String::new(), true, 0));
+        let mut out = ptr::null_mut();
+        assert_eq!(fdu_call_into(echo, ptr::null(), ptr::null(), ptr::null_mut(), 0, &mut out), 0);
+        assert!(out.is_null());
+
+        let mut session = ptr::null_mut();
+        free_result(fdu_test_session_new(&mut session));
+        let ping = FduCall::TestSessionPing as i32;
+        assert_eq!(call_into(ping, session, "", 16), ("1".to_string(), true, 0));
+        // The value did not fit, but the call was made once all the same.
+        assert_eq!(call_into(ping, session, "", 0), ("2".to_string(), false, 0));
+        assert_eq!(call_into(FduCall::SessionUid as i32, session, "", 16), (r#""""#.to_string(), true, 0));
+        fdu_session_free(session);
+
+        let invalid = FduErrorCode::InvalidArgument as i32;
+        assert_eq!(call_into(ping, ptr::null(), "", 16), (String::new(), false, invalid));
+        assert_eq!(call_into(0, ptr::null(), "", 16), (String::new(), false, invalid));
+        assert_eq!(call_into(42, ptr::null(), "", 16), (String::new(), false, invalid));
+
+        // The result is freed rather than leaked without `out`.
+        let arg = CString::new("abc").unwrap();
+        assert_eq!(fdu_call_into(echo, ptr::null(), arg.as_ptr(), ptr::null_mut(), 0, ptr::null_mut()), 3);
+    }
+
+    #[test]
+    fn test_output() {
+        let mut buf = [0u8; 4];
+        let mut output = Output { buf: &mut buf, len: 0, spill: None };
+        output.push(b"ab");
+        output.push(b"cd");
+        assert_eq!((output.len, output.spill.is_none()), (4, true));
+        output.push(b"e");
+        output.push(b"");
+        assert_eq!(output.spill.as_deref(), Some(&b"abcde"[..]));
+    }
+}
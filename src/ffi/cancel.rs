@@ -0,0 +1,136 @@
+use std::sync::Mutex;
+use std::sync::atomic::{AtomicBool, AtomicI64, Ordering};
+use std::time::{Duration, Instant};
+
+use libc::*;
+
+use crate::error::*;
+use crate::fdu::ratelimit::{self, Call};
+
+use super::result::*;
+
+// Number of tokens not freed yet. Tests use it to check that callers do not leak tokens.
+pub(crate) static LIVE_TOKENS: AtomicI64 = AtomicI64::new(0);
+
+// A cancellation token, created by `fdu_cancel_token_new()` and freed by `fdu_cancel_token_free()`.
+//
+// Pass it to a slow export, and call `fdu_cancel()` from another thread to ask the export to stop. The export checks
+// the token between its steps (it cannot interrupt a request in flight) and returns `FduErrorCode::Cancelled`.
+// The token must not be freed before the export using it returns.
+//
+// Every export taking a token also accepts NULL, which means the call cannot be cancelled.
+//
+// A token may also carry a deadline, set by `fdu_cancel_token_set_deadline()`, before which the `_async` exports fail
+// rather than wait for the rate limits, and a trace id, set by `fdu_cancel_token_set_trace_id()`, carried by the
+// trace events of the `_async` exports, see trace.rs.
+pub struct FduCancelToken {
+    cancelled: AtomicBool,
+    deadline: Mutex<Option<Instant>>,
+    trace_id: Mutex<String>,
+}
+
+impl FduCancelToken {
+    // Return an error if the token passed in by the caller has been cancelled.
+    pub(crate) fn check(token: *const FduCancelToken) -> Result<()> {
+        if !token.is_null() && unsafe { &*token }.cancelled.load(Ordering::SeqCst) {
+            return Err(SDKError::with_type(ErrorType::CancelledError, "cancelled".to_string()));
+        }
+        Ok(())
+    }
+
+    // Make the token the one of the call running on the current thread, see `ratelimit::enter()`.
+    pub(crate) fn enter(token: *const FduCancelToken) -> Option<ratelimit::Scope> {
+        (!token.is_null()).then(|| ratelimit::enter(token as *const dyn Call))
+    }
+
+    // The deadline of the calls using the token, if there is a token with one.
+    pub(crate) fn deadline_of(token: *const FduCancelToken) -> Option<Instant> {
+        if token.is_null() {
+            return None;
+        }
+        unsafe { &*token }.deadline()
+    }
+
+    // The trace id of the calls using the token, empty if there is no token or no id.
+    pub(crate) fn trace_id(token: *const FduCancelToken) -> String {
+        if token.is_null() {
+            return String::new();
+        }
+        unsafe { &*token }.trace_id.lock().unwrap_or_else(|e| e.into_inner()).clone()
+    }
+}
+
+impl Call for FduCancelToken {
+    fn deadline(&self) -> Option<Instant> {
+        *self.deadline.lock().unwrap_or_else(|e| e.into_inner())
+    }
+
+    fn cancelled(&self) -> bool {
+        self.cancelled.load(Ordering::SeqCst)
+    }
+}
+
+impl Drop for FduCancelToken {
+    fn drop(&mut self) {
+        LIVE_TOKENS.fetch_sub(1, Ordering::Relaxed);
+    }
+}
+
+#[no_mangle]
+pub extern "C" fn fdu_cancel_token_new() -> *mut FduCancelToken {
+    guard_or(std::ptr::null_mut(), || {
+        LIVE_TOKENS.fetch_add(1, Ordering::Relaxed);
+        Box::into_raw(Box::new(FduCancelToken {
+            cancelled: AtomicBool::new(false),
+            deadline: Mutex::new(None),
+            trace_id: Mutex::new(String::new()),
+        }))
+    })
+}
+
+// Cancel the calls using the token. It is safe to call it concurrently with those calls, and more than once.
+#[no_mangle]
+pub extern "C" fn fdu_cancel(token: *const FduCancelToken) {
+    guard_or((), || {
+        if token.is_null() {
+            return;
+        }
+        unsafe { &*token }.cancelled.store(true, Ordering::SeqCst);
+    })
+}
+
+// Set the deadline of the calls using the token to `timeout_millis` milliseconds from now. Set it before passing the
+// token to an export.
+#[no_mangle]
+pub extern "C" fn fdu_cancel_token_set_deadline(token: *const FduCancelToken, timeout_millis: u64) {
+    guard_or((), || {
+        if token.is_null() {
+            return;
+        }
+        let deadline = Instant::now().checked_add(Duration::from_millis(timeout_millis));
+        *unsafe { &*token }.deadline.lock().unwrap_or_else(|e| e.into_inner()) = deadline;
+    })
+}
+
+// Set the trace id of the calls using the token, which their trace events carry, e.g. the id of the request of a
+// service making the call. Set it before passing the token to an export.
+#[no_mangle]
+pub extern "C" fn fdu_cancel_token_set_trace_id(token: *const FduCancelToken, trace_id: *const c_char) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        if token.is_null() {
+            Err(SDKError::with_type(ErrorType::ArgumentError, "token is NULL".to_string()))?
+        }
+        let trace_id = borrow_str(trace_id, "trace_id")?.to_string();
+        *unsafe { &*token }.trace_id.lock().unwrap_or_else(|e| e.into_inner()) = trace_id;
+    }))
+}
+
+#[no_mangle]
+pub extern "C" fn fdu_cancel_token_free(token: *mut FduCancelToken) {
+    guard_or((), || {
+        if token.is_null() {
+            return;
+        }
+        drop(unsafe { Box::from_raw(token) });
+    })
+}
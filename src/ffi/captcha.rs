@@ -0,0 +1,271 @@
+// Logins interrupted by a captcha.
+//
+// When UIS asks for a captcha, `fdu_login()` keeps the half-done login here and returns its continuation token
+// along with `FduErrorCode::CaptchaRequired`. The caller shows the image from `fdu_captcha_image()` to the user and
+// finishes with `fdu_login_with_captcha()`. A continuation can only be used once and expires after CAPTCHA_TIMEOUT;
+// a wrong answer yields a new continuation with a fresh image.
+use std::collections::hash_map::RandomState;
+use std::collections::HashMap;
+use std::hash::{BuildHasher, Hasher};
+use std::ptr;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::{Mutex, OnceLock};
+use std::time::{Duration, Instant};
+
+use libc::*;
+
+use crate::fdu::prelude::*;
+
+use super::buffer::*;
+use super::cancel::*;
+use super::result::*;
+use super::session::*;
+
+const CAPTCHA_TIMEOUT: Duration = Duration::from_secs(5 * 60);
+
+// The answer to the captcha of fake logins started by `fdu_test_login_captcha()`.
+pub(crate) const TEST_CAPTCHA_ANSWER: &str = "1234";
+// A 1x1 PNG, the captcha image of fake logins.
+pub(crate) const TEST_CAPTCHA_IMAGE: &[u8] = &[
+    0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x48, 0x44, 0x52,
+    0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4,
+    0x89, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x44, 0x41, 0x54, 0x78, 0x9c, 0x63, 0xf8, 0xcf, 0xc0, 0xf0,
+    0x1f, 0x00, 0x05, 0x00, 0x01, 0xff, 0x89, 0x99, 0x3d, 0x1d, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45,
+    0x4e, 0x44, 0xae, 0x42, 0x60, 0x82,
+];
+
+pub(crate) enum Challenge {
+    // A real login, whose client holds the cookies the captcha is bound to.
+    Uis(Fdu),
+    // A fake login for tests, see `fdu_test_login_captcha()`.
+    Test,
+}
+
+struct PendingLogin {
+    challenge: Challenge,
+    username: String,
+    password: String,
+    image: Vec<u8>,
+    expires: Instant,
+}
+
+fn pending() -> &'static Mutex<HashMap<String, PendingLogin>> {
+    static PENDING: OnceLock<Mutex<HashMap<String, PendingLogin>>> = OnceLock::new();
+    PENDING.get_or_init(|| Mutex::new(HashMap::new()))
+}
+
+// Drop all pending logins, e.g. on `fdu_shutdown()`: their continuations are rejected as expired afterwards.
+pub(crate) fn discard_all() {
+    let logins = std::mem::take(&mut *pending().lock().unwrap_or_else(|e| e.into_inner()));
+    // Drop the clients outside of the lock.
+    drop(logins);
+}
+
+// Continuation tokens must not be guessable by other users of the process, so mix a counter with random keys.
+fn new_continuation() -> String {
+    static COUNTER: AtomicU64 = AtomicU64::new(0);
+    let mut hasher = RandomState::new().build_hasher();
+    hasher.write_u64(COUNTER.fetch_add(1, Ordering::Relaxed));
+    let mut other = RandomState::new().build_hasher();
+    other.write_u64(hasher.finish());
+    format!("{:016x}{:016x}", hasher.finish(), other.finish())
+}
+
+// Keep the login for `ttl` and return the result handing its continuation to the caller.
+pub(crate) fn suspend(challenge: Challenge, username: &str, password: &str, image: Vec<u8>, ttl: Duration, message: String) -> *mut FduResult {
+    let continuation = new_continuation();
+    let mut pending = pending().lock().unwrap_or_else(|e| e.into_inner());
+    let now = Instant::now();
+    pending.retain(|_, login| login.expires > now);
+    pending.insert(continuation.clone(), PendingLogin {
+        challenge,
+        username: username.to_string(),
+        password: password.to_string(),
+        image,
+        expires: now + ttl,
+    });
+    Box::into_raw(Box::new(FduResult {
+        value: to_c_string(continuation),
+        code: FduErrorCode::CaptchaRequired as i32,
+        message: to_c_string(message),
+    }))
+}
+
+fn expired() -> SDKError {
+    SDKError::with_type(ErrorType::ArgumentError, "captcha continuation expired or already used".to_string())
+}
+
+// Start a real login, suspending it if UIS asks for a captcha. `token` is checked before handing out the session.
+pub(crate) fn login(username: &str, password: &str, token: *const FduCancelToken, out: *mut *mut FduSession) -> *mut FduResult {
+    let mut fdu = Fdu::new();
+    finish(fdu.login(username, password), Challenge::Uis(fdu), username, password, token, out)
+}
+
+// Convert the outcome of a login attempt into the result of the export.
+fn finish(r: Result<()>, challenge: Challenge, username: &str, password: &str, token: *const FduCancelToken, out: *mut *mut FduSession) -> *mut FduResult {
+    let r: Result<()> = try {
+        r?;
+        // Nobody is waiting for the session if the call has been cancelled in the meantime, so do not hand it out.
+        FduCancelToken::check(token)?;
+    };
+    match r {
+        Ok(()) => {
+            let fdu = match challenge {
+                Challenge::Uis(fdu) => fdu,
+                Challenge::Test => Fdu::new(),
+            };
+            unsafe { *out = Box::into_raw(Box::new(FduSession::new(fdu))) };
+            FduResult::empty()
+        }
+        Err(e) if matches!(e.error_type(), ErrorType::CaptchaRequiredError) => {
+            let image = match &challenge {
+                Challenge::Uis(fdu) => fdu.get_captcha_image(),
+                Challenge::Test => Ok(TEST_CAPTCHA_IMAGE.to_vec()),
+            };
+            match image {
+                Ok(image) => suspend(challenge, username, password, image, CAPTCHA_TIMEOUT, e.to_string()),
+                Err(e) => FduResult::from_unit(Err(e)),
+            }
+        }
+        Err(e) => FduResult::from_unit(Err(e)),
+    }
+}
+
+// Copy the captcha image of a suspended login into `*out`. It is usually a PNG or a JPEG.
+#[no_mangle]
+pub extern "C" fn fdu_captcha_image(continuation: *const c_char, out: *mut *mut FduBuffer) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let continuation = borrow_str(continuation, "continuation")?;
+        let image = {
+            let pending = pending().lock().unwrap_or_else(|e| e.into_inner());
+            match pending.get(continuation) {
+                Some(login) if login.expires > Instant::now() => login.image.clone(),
+                _ => Err(expired())?,
+            }
+        };
+        FduBuffer::write_to(out, image)?
+    }))
+}
+
+// Resume a login suspended by `fdu_login()` with the answer to its captcha. On success, `*out` is set to a new
+// session, otherwise it is set to NULL. The continuation is consumed either way: a wrong answer returns
+// `FduErrorCode::CaptchaRequired` again with a new continuation, and an expired or used one is rejected with
+// `FduErrorCode::InvalidArgument`.
+#[no_mangle]
+pub extern "C" fn fdu_login_with_captcha(
+    continuation: *const c_char,
+    answer: *const c_char,
+    token: *const FduCancelToken,
+    out: *mut *mut FduSession,
+) -> *mut FduResult {
+    guard(|| {
+        let r: Result<(PendingLogin, &str)> = try {
+            if out.is_null() {
+                Err(SDKError::with_type(ErrorType::ArgumentError, "out is NULL".to_string()))?
+            }
+            unsafe { *out = ptr::null_mut() };
+            let continuation = borrow_str(continuation, "continuation")?;
+            let answer = borrow_str(answer, "answer")?;
+            FduCancelToken::check(token)?;
+            let login = pending().lock().unwrap_or_else(|e| e.into_inner()).remove(continuation);
+            match login {
+                Some(login) if login.expires > Instant::now() => (login, answer),
+                _ => Err(expired())?,
+            }
+        };
+        let _call = FduCancelToken::enter(token);
+        let (login, answer) = match r {
+            Ok(v) => v,
+            Err(e) => return FduResult::from_unit(Err(e)),
+        };
+        let PendingLogin { challenge, username, password, .. } = login;
+        match challenge {
+            Challenge::Uis(mut fdu) => {
+                let r = fdu.login_with_captcha(&username, &password, Some(answer));
+                finish(r, Challenge::Uis(fdu), &username, &password, token, out)
+            }
+            Challenge::Test => {
+                let r = if answer == TEST_CAPTCHA_ANSWER {
+                    Ok(())
+                } else {
+                    Err(SDKError::with_type(ErrorType::CaptchaRequiredError, "wrong captcha".to_string()))
+                };
+                finish(r, Challenge::Test, &username, &password, token, out)
+            }
+        }
+    })
+}
+
+// Start a fake login which always asks for a captcha, whose answer is TEST_CAPTCHA_ANSWER, and whose continuations
+// expire after `ttl_millis`. It never talks to UIS.
+pub(crate) fn test_login(ttl_millis: u64) -> *mut FduResult {
+    suspend(Challenge::Test, "test", "", TEST_CAPTCHA_IMAGE.to_vec(), Duration::from_millis(ttl_millis), "captcha required".to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use std::ffi::{CStr, CString};
+    use std::thread;
+
+    use super::*;
+
+    // Take the continuation out of a CaptchaRequired result and free it.
+    fn take_continuation(r: *mut FduResult) -> CString {
+        let result = unsafe { &*r };
+        assert_eq!(result.code, FduErrorCode::CaptchaRequired as i32);
+        let continuation = unsafe { CStr::from_ptr(result.value) }.to_owned();
+        free_result(r);
+        continuation
+    }
+
+    fn resume(continuation: &CString, answer: &str) -> (*mut FduResult, *mut FduSession) {
+        let answer = CString::new(answer).unwrap();
+        let mut session = ptr::null_mut();
+        let r = fdu_login_with_captcha(continuation.as_ptr(), answer.as_ptr(), ptr::null(), &mut session);
+        (r, session)
+    }
+
+    #[test]
+    fn test_captcha_flow() {
+        let continuation = take_continuation(test_login(60_000));
+
+        let mut image = ptr::null_mut();
+        let r = fdu_captcha_image(continuation.as_ptr(), &mut image);
+        assert_eq!(unsafe { &*r }.code, FduErrorCode::Ok as i32);
+        assert_eq!(unsafe { (*image).len }, TEST_CAPTCHA_IMAGE.len());
+        free_result(r);
+        free_buffer(image);
+
+        // A wrong answer gives a new continuation, and consumes the old one.
+        let (r, session) = resume(&continuation, "0000");
+        assert!(session.is_null());
+        let retry = take_continuation(r);
+        assert_ne!(retry, continuation);
+        let (r, session) = resume(&continuation, TEST_CAPTCHA_ANSWER);
+        assert!(session.is_null());
+        assert_eq!(unsafe { &*r }.code, FduErrorCode::InvalidArgument as i32);
+        free_result(r);
+
+        let (r, session) = resume(&retry, TEST_CAPTCHA_ANSWER);
+        assert_eq!(unsafe { &*r }.code, FduErrorCode::Ok as i32);
+        assert!(!session.is_null());
+        free_result(r);
+        fdu_session_free(session);
+
+        // Used once already
+        let (r, session) = resume(&retry, TEST_CAPTCHA_ANSWER);
+        assert!(session.is_null());
+        assert_eq!(unsafe { &*r }.code, FduErrorCode::InvalidArgument as i32);
+        free_result(r);
+    }
+
+    #[test]
+    fn test_captcha_timeout() {
+        let continuation = take_continuation(test_login(10));
+        thread::sleep(Duration::from_millis(20));
+        let (r, session) = resume(&continuation, TEST_CAPTCHA_ANSWER);
+        assert!(session.is_null());
+        assert_eq!(unsafe { &*r }.code, FduErrorCode::InvalidArgument as i32);
+        free_result(r);
+    }
+}
@@ -0,0 +1,67 @@
+use libc::*;
+
+use std::path::PathBuf;
+
+use crate::fdu::config::{self, HttpConfig};
+use crate::fdu::prelude::*;
+use crate::fdu::record::Redact;
+
+use super::result::*;
+
+// Set the HTTP settings from a JSON object of
+// `{"connect_timeout_millis", "request_timeout_millis", "proxy_url", "user_agent", "max_retries", "ca_cert_pem",
+// "insecure_skip_verify", "pinned_spki_hashes", "rate_limits"}`, where missing fields keep the defaults of the
+// library, see `HttpConfig`, and `rate_limits` maps hosts to `{"per_second", "burst"}`. A server failing the pins
+// fails the call with `FduErrorCode::CertPinMismatch`. They apply to the sessions created afterwards: the sessions
+// alive and the calls in flight keep the settings they were created with.
+#[no_mangle]
+pub extern "C" fn fdu_set_http_config(json: *const c_char) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let json = borrow_str(json, "json")?;
+        let config: HttpConfig = serde_json::from_str(json)
+            .map_err(|e| SDKError::with_type(ErrorType::ArgumentError, format!("invalid http config: {}", e)))?;
+        config::set(config)?
+    }))
+}
+
+// Record every HTTP exchange of the sessions into a JSON file of the directory `dir`, created if needed, or stop
+// recording if `dir` is empty, to reproduce a bug offline. `redact` is the redaction of the files: 0 for the
+// credentials, the cookies, the tickets, the student IDs and the names, 1 also for the long numbers and the email
+// addresses and without the request bodies, 2 for none, see `record`. It applies to the sessions alive too.
+#[no_mangle]
+pub extern "C" fn fdu_set_recording(dir: *const c_char, redact: u32) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let dir = borrow_str(dir, "dir")?;
+        let redact = Redact::from_level(redact)?;
+        record::set((!dir.is_empty()).then(|| PathBuf::from(dir)), redact)?
+    }))
+}
+
+#[cfg(test)]
+mod tests {
+    use std::ffi::CString;
+
+    use super::*;
+
+    #[test]
+    fn test_set_http_config() {
+        for json in ["{\"proxy_url\": \"http://[::1\"}", "{\"timeout\": 1}", "null", "{\"pinned_spki_hashes\": [\"sha1/AAAA\"]}",
+                     "{\"rate_limits\": {\"jwfw.fudan.edu.cn\": {\"per_second\": 2, \"bucket\": 4}}}"] {
+            let json = CString::new(json).unwrap();
+            let r = fdu_set_http_config(json.as_ptr());
+            assert_eq!(unsafe { (*r).code }, FduErrorCode::InvalidArgument as i32);
+            unsafe { free_result(r) };
+        }
+    }
+
+    #[test]
+    fn test_set_recording() {
+        let dir = CString::new("").unwrap();
+        let r = fdu_set_recording(dir.as_ptr(), 3);
+        assert_eq!(unsafe { (*r).code }, FduErrorCode::InvalidArgument as i32);
+        unsafe { free_result(r) };
+        let r = fdu_set_recording(dir.as_ptr(), 0);
+        assert_eq!(unsafe { (*r).code }, 0);
+        unsafe { free_result(r) };
+    }
+}
@@ -0,0 +1,125 @@
+use libc::*;
+
+use crate::fdu::ecard::ECardClient;
+
+use super::cancel::*;
+use super::jobs::{self, *};
+use super::result::*;
+use super::retry::{self, retried};
+use super::session::*;
+
+// Return the balance of the campus card in cents, as a JSON integer.
+#[no_mangle]
+pub extern "C" fn fdu_card_balance(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        FduCancelToken::check(token)?;
+        fdu.get_balance()?
+    })))
+}
+
+// The `_async` variant of `fdu_card_balance()`.
+#[no_mangle]
+pub extern "C" fn fdu_card_balance_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_card_balance(handles.session(), handles.token()));
+    }))
+}
+
+// Return the current payment code of the campus card (付款码) as a JSON object `{"code", "valid_millis"}`, where
+// `code` is the content of the QR code to show to card readers and `valid_millis` how long it is accepted from the
+// time of the call. Fails with `FduErrorCode::QrDisabled` if the account has QR payment disabled.
+#[no_mangle]
+pub extern "C" fn fdu_card_payment_code(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        FduCancelToken::check(token)?;
+        fdu.get_payment_code()?
+    }))
+}
+
+// The `_async` variant of `fdu_card_payment_code()`.
+#[no_mangle]
+pub extern "C" fn fdu_card_payment_code_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_card_payment_code(handles.session(), handles.token()));
+    }))
+}
+
+// Return a page (counted from 1) of the campus card transactions from `start_date` to `end_date`,
+// both inclusive and like 2023-01-03, as a JSON object of
+// `{"page", "total_pages", "transactions": [{"time", "location", "amount", "balance"}]}`.
+// Amounts and balances are in cents.
+#[no_mangle]
+pub extern "C" fn fdu_card_transactions(session: *const FduSession,
+                                        start_date: *const c_char,
+                                        end_date: *const c_char,
+                                        page: size_t,
+                                        token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        let start_date = borrow_str(start_date, "start_date")?;
+        let end_date = borrow_str(end_date, "end_date")?;
+        FduCancelToken::check(token)?;
+        fdu.get_transaction_page(start_date, end_date, page)?
+    })))
+}
+
+// The `_async` variant of `fdu_card_transactions()`.
+#[no_mangle]
+pub extern "C" fn fdu_card_transactions_async(session: *const FduSession,
+                                              start_date: *const c_char,
+                                              end_date: *const c_char,
+                                              page: size_t,
+                                              token: *const FduCancelToken,
+                                              request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        let start_date = owned_str(start_date, "start_date")?;
+        let end_date = owned_str(end_date, "end_date")?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_card_transactions(handles.session(), start_date.as_ptr(), end_date.as_ptr(), page, handles.token())
+        });
+    }))
+}
+
+// Return the electricity left in a dormitory room as a JSON object `{"kwh", "balance", "last_top_up"}`, where
+// `balance` is in cents and `last_top_up` like 2024-03-01 12:30:05, or empty if the room was never topped up.
+// `campus` is handan or jiangwan, `building` like 南区10号楼 and `room` like 302. Fails with
+// `FduErrorCode::ArgumentError` if the room is unknown.
+#[no_mangle]
+pub extern "C" fn fdu_electricity_balance(session: *const FduSession,
+                                          campus: *const c_char,
+                                          building: *const c_char,
+                                          room: *const c_char,
+                                          token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        let campus = borrow_str(campus, "campus")?;
+        let building = borrow_str(building, "building")?;
+        let room = borrow_str(room, "room")?;
+        FduCancelToken::check(token)?;
+        fdu.get_electricity(campus, building, room)?
+    })))
+}
+
+// The `_async` variant of `fdu_electricity_balance()`.
+#[no_mangle]
+pub extern "C" fn fdu_electricity_balance_async(session: *const FduSession,
+                                                campus: *const c_char,
+                                                building: *const c_char,
+                                                room: *const c_char,
+                                                token: *const FduCancelToken,
+                                                request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        let campus = owned_str(campus, "campus")?;
+        let building = owned_str(building, "building")?;
+        let room = owned_str(room, "room")?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_electricity_balance(handles.session(), campus.as_ptr(), building.as_ptr(), room.as_ptr(), handles.token())
+        });
+    }))
+}
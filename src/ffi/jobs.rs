@@ -0,0 +1,190 @@
+// Jobs run the slow exports on a pool of threads owned by the library, so that callers with many calls in flight do
+// not need a thread of their own for each: the `_async` variant of an export takes a `request_id` chosen by the
+// caller and returns at once, and the result of the job is collected later with `fdu_poll_completions()`.
+//
+// The result returned by an `_async` export only tells whether the job was submitted. Once it was, exactly one
+// completion is delivered for the request, even if the job is cancelled through its token. The session and token
+// passed in must stay alive until then, and the caller must not start another call on the session in the meantime.
+use std::collections::VecDeque;
+use std::ffi::CString;
+use std::sync::{Condvar, Mutex, MutexGuard};
+use std::thread;
+use std::time::{Duration, Instant};
+
+use libc::*;
+
+use crate::fdu::prelude::*;
+use crate::fdu::trace;
+
+use super::cancel::*;
+use super::result::*;
+use super::session::*;
+
+// Most jobs wait for the network, so the pool is large; idle threads exit after a while.
+const MAX_WORKERS: usize = 64;
+const IDLE_TIMEOUT: Duration = Duration::from_secs(30);
+
+#[repr(C)]
+pub struct FduCompletion {
+    pub request_id: u64,
+    // The result of the job, to be released with `free_result()`.
+    pub result: *mut FduResult,
+}
+
+// A completion owns its result until it is handed to the caller.
+unsafe impl Send for FduCompletion {}
+
+type Job = Box<dyn FnOnce() -> *mut FduResult + Send>;
+
+struct Pool {
+    queue: VecDeque<(u64, Job)>,
+    workers: usize,
+    idle: usize,
+}
+
+static POOL: Mutex<Pool> = Mutex::new(Pool { queue: VecDeque::new(), workers: 0, idle: 0 });
+static WORK: Condvar = Condvar::new();
+static COMPLETIONS: Mutex<VecDeque<FduCompletion>> = Mutex::new(VecDeque::new());
+static COMPLETED: Condvar = Condvar::new();
+
+// Jobs never panic (they are guarded exports), so a poisoned lock only means a panic elsewhere: carry on.
+fn lock<T>(mutex: &Mutex<T>) -> MutexGuard<'_, T> {
+    mutex.lock().unwrap_or_else(|e| e.into_inner())
+}
+
+// The handles of an `_async` export, moved to the thread running its job. The caller keeps them alive until the
+// completion, see above.
+pub(crate) struct Handles {
+    session: *const FduSession,
+    token: *const FduCancelToken,
+}
+
+unsafe impl Send for Handles {}
+
+impl Handles {
+    pub(crate) fn new(session: *const FduSession, token: *const FduCancelToken) -> Result<Self> {
+        FduSession::borrow(session)?;
+        Ok(Self { session, token })
+    }
+
+    // Accessors rather than fields, so that closures capture the whole `Send` struct.
+    pub(crate) fn session(&self) -> *const FduSession {
+        self.session
+    }
+
+    pub(crate) fn token(&self) -> *const FduCancelToken {
+        self.token
+    }
+}
+
+// Copy a string passed to an `_async` export, which the caller may free as soon as the export returns.
+pub(crate) fn owned_str(s: *const c_char, name: &str) -> Result<CString> {
+    Ok(CString::new(borrow_str(s, name)?).unwrap())
+}
+
+// Run `job` on the pool, delivering its result as the completion of `request_id`. The deadline and the cancellation
+// of `token`, the one of the job, apply to the waits of the job, e.g. for the rate limits, and its trace events carry
+// `request_id` and the trace id of `token`.
+pub(crate) fn spawn<F: FnOnce() -> *mut FduResult + Send + 'static>(request_id: u64, token: *const FduCancelToken, job: F) {
+    let trace_id = FduCancelToken::trace_id(token);
+    // Tokens are `Sync`, so sending the pointer is fine while the caller keeps it alive.
+    let token = token as usize;
+    let job = move || {
+        let _call = FduCancelToken::enter(token as *const FduCancelToken);
+        let _span = trace::enter(trace::Span { request_id, trace_id });
+        let result = job();
+        // Before the completion, so that the caller has all the events of the call once it has its result.
+        trace::finish(unsafe { (*result).code });
+        result
+    };
+    let mut pool = lock(&POOL);
+    pool.queue.push_back((request_id, Box::new(job)));
+    if pool.queue.len() > pool.idle && pool.workers < MAX_WORKERS {
+        pool.workers += 1;
+        thread::spawn(work);
+    } else {
+        WORK.notify_one();
+    }
+}
+
+fn work() {
+    let mut pool = lock(&POOL);
+    loop {
+        if let Some((request_id, job)) = pool.queue.pop_front() {
+            drop(pool);
+            let result = job();
+            lock(&COMPLETIONS).push_back(FduCompletion { request_id, result });
+            COMPLETED.notify_one();
+            pool = lock(&POOL);
+            continue;
+        }
+        pool.idle += 1;
+        let (guard, timeout) = WORK.wait_timeout(pool, IDLE_TIMEOUT).unwrap_or_else(|e| e.into_inner());
+        pool = guard;
+        pool.idle -= 1;
+        if timeout.timed_out() && pool.queue.is_empty() {
+            pool.workers -= 1;
+            return;
+        }
+    }
+}
+
+// Wait up to `timeout_millis` for completed jobs, and move up to `n` of them into `buf`. Return the number of
+// completions written, 0 on timeout. A single caller thread is expected to poll and dispatch the completions.
+#[no_mangle]
+pub extern "C" fn fdu_poll_completions(buf: *mut FduCompletion, n: size_t, timeout_millis: u64) -> size_t {
+    guard_or(0, || {
+        if buf.is_null() || n == 0 {
+            return 0;
+        }
+        let deadline = Instant::now() + Duration::from_millis(timeout_millis);
+        let mut completions = lock(&COMPLETIONS);
+        while completions.is_empty() {
+            let now = Instant::now();
+            if now >= deadline {
+                return 0;
+            }
+            completions = COMPLETED.wait_timeout(completions, deadline - now).unwrap_or_else(|e| e.into_inner()).0;
+        }
+        let count = completions.len().min(n);
+        for (i, completion) in completions.drain(..count).enumerate() {
+            unsafe { buf.add(i).write(completion) };
+        }
+        count
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use std::collections::HashSet;
+    use std::ffi::CStr;
+    use std::ptr;
+
+    use super::*;
+
+    #[test]
+    fn test_jobs() {
+        const JOBS: u64 = 200;
+        for id in 0..JOBS {
+            spawn(id, ptr::null(), move || {
+                thread::sleep(Duration::from_millis(10));
+                FduResult::ok(id.to_string())
+            });
+        }
+
+        let mut seen = HashSet::new();
+        let mut buf: Vec<FduCompletion> = (0..16).map(|_| FduCompletion { request_id: 0, result: ptr::null_mut() }).collect();
+        let deadline = Instant::now() + Duration::from_secs(10);
+        while seen.len() < JOBS as usize && Instant::now() < deadline {
+            let n = fdu_poll_completions(buf.as_mut_ptr(), buf.len(), 100);
+            for completion in &buf[..n] {
+                let value = unsafe { CStr::from_ptr((*completion.result).value) }.to_str().unwrap().to_string();
+                assert_eq!(value, completion.request_id.to_string());
+                assert!(seen.insert(completion.request_id));
+                unsafe { free_result(completion.result) };
+            }
+        }
+        assert_eq!(seen.len(), JOBS as usize);
+        assert!(lock(&POOL).workers <= MAX_WORKERS);
+    }
+}
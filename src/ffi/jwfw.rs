@@ -0,0 +1,275 @@
+use libc::*;
+
+use crate::fdu::jwfw::JwfwClient;
+use crate::fdu::prelude::*;
+use crate::fdu::yjsxt::{GraduateClient, StudentType};
+
+use super::cancel::*;
+use super::jobs::{self, *};
+use super::result::*;
+use super::retry::{self, retried};
+use super::session::*;
+
+// The course table, the exams, the scores and the GPA are those of jwfw for undergraduates, and of yjsxt for graduate
+// students, with the same JSON, see `FduSession::student_type()`. The semester ids of both systems differ.
+
+// Log in the academic system of the student, returning the student type which tells the one.
+fn enter_system(session: &FduSession, token: *const FduCancelToken) -> Result<StudentType> {
+    let student_type = session.student_type();
+    FduCancelToken::check(token)?;
+    match student_type {
+        StudentType::Undergraduate => drop(session.fdu.get_jwfw_homepage()?),
+        StudentType::Graduate => session.fdu.get_yjsxt_homepage()?,
+    }
+    FduCancelToken::check(token)?;
+    Ok(student_type)
+}
+
+// Return the profile of the student as a JSON object `{"name", "student_id", "department", "student_type"}`, where
+// `student_type` is "undergraduate" or "graduate".
+#[no_mangle]
+pub extern "C" fn fdu_profile(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let session = FduSession::borrow(session)?;
+        match enter_system(session, token)? {
+            StudentType::Undergraduate => session.fdu.get_profile()?,
+            StudentType::Graduate => session.fdu.get_graduate_profile()?,
+        }
+    })))
+}
+
+// The `_async` variant of `fdu_profile()`.
+#[no_mangle]
+pub extern "C" fn fdu_profile_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_profile(handles.session(), handles.token()));
+    }))
+}
+
+// Return the semesters as a JSON array of `{"id", "school_year", "name"}`.
+// The ids are the valid values of `semester_id` for `fdu_courses()`, e.g. 385 for jwfw and 20231 for yjsxt.
+#[no_mangle]
+pub extern "C" fn fdu_semesters(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let session = FduSession::borrow(session)?;
+        match enter_system(session, token)? {
+            StudentType::Undergraduate => session.fdu.get_semesters()?,
+            StudentType::Graduate => session.fdu.get_graduate_semesters()?,
+        }
+    })))
+}
+
+// The `_async` variant of `fdu_semesters()`.
+#[no_mangle]
+pub extern "C" fn fdu_semesters_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_semesters(handles.session(), handles.token()));
+    }))
+}
+
+// Return the academic calendar as a JSON object `{"semesters", "holidays", "adjustments"}`, where
+// `semesters` is an array of `{"id", "school_year", "name", "start", "end"}`, `holidays` of `{"date", "name"}` and
+// `adjustments` of `{"date", "acts_as_weekday"}` for the makeup days. Dates are like 2023-09-11.
+#[no_mangle]
+pub extern "C" fn fdu_academic_calendar(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        FduCancelToken::check(token)?;
+        fdu.get_jwfw_homepage()?;
+        FduCancelToken::check(token)?;
+        fdu.get_academic_calendar()?
+    })))
+}
+
+// The `_async` variant of `fdu_academic_calendar()`.
+#[no_mangle]
+pub extern "C" fn fdu_academic_calendar_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_academic_calendar(handles.session(), handles.token()));
+    }))
+}
+
+// Return the lessons of the course table in a semester as a JSON array of
+// `{"course_id", "name", "teacher", "location", "weekday", "start_slot", "end_slot", "weeks"}`.
+#[no_mangle]
+pub extern "C" fn fdu_courses(session: *const FduSession, semester_id: *const c_char, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let session = FduSession::borrow(session)?;
+        let semester_id = borrow_str(semester_id, "semester_id")?;
+        match enter_system(session, token)? {
+            StudentType::Undergraduate => session.fdu.get_course_table(semester_id)?,
+            StudentType::Graduate => session.fdu.get_graduate_courses(semester_id)?,
+        }
+    })))
+}
+
+// The `_async` variant of `fdu_courses()`.
+#[no_mangle]
+pub extern "C" fn fdu_courses_async(session: *const FduSession,
+                                    semester_id: *const c_char,
+                                    token: *const FduCancelToken,
+                                    request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        let semester_id = owned_str(semester_id, "semester_id")?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_courses(handles.session(), semester_id.as_ptr(), handles.token())
+        });
+    }))
+}
+
+// Return the exams in a semester as a JSON array of
+// `{"course_id", "name", "type", "date", "time", "location", "seat", "note"}`.
+// `date`, `time`, `location` and `seat` are empty if not scheduled yet.
+#[no_mangle]
+pub extern "C" fn fdu_exams(session: *const FduSession, semester_id: *const c_char, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let session = FduSession::borrow(session)?;
+        let semester_id = borrow_str(semester_id, "semester_id")?;
+        match enter_system(session, token)? {
+            StudentType::Undergraduate => session.fdu.get_exams(semester_id)?,
+            StudentType::Graduate => session.fdu.get_graduate_exams(semester_id)?,
+        }
+    })))
+}
+
+// The `_async` variant of `fdu_exams()`.
+#[no_mangle]
+pub extern "C" fn fdu_exams_async(session: *const FduSession,
+                                  semester_id: *const c_char,
+                                  token: *const FduCancelToken,
+                                  request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        let semester_id = owned_str(semester_id, "semester_id")?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_exams(handles.session(), semester_id.as_ptr(), handles.token())
+        });
+    }))
+}
+
+// Return the scores in a semester as a JSON array of
+// `{"semester", "course_id", "name", "credit", "grade", "point"}`.
+// `point` is null for P/NP courses.
+#[no_mangle]
+pub extern "C" fn fdu_scores(session: *const FduSession, semester_id: *const c_char, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let session = FduSession::borrow(session)?;
+        let semester_id = borrow_str(semester_id, "semester_id")?;
+        match enter_system(session, token)? {
+            StudentType::Undergraduate => session.fdu.get_scores(semester_id)?,
+            StudentType::Graduate => session.fdu.get_graduate_scores(semester_id)?,
+        }
+    })))
+}
+
+// The `_async` variant of `fdu_scores()`.
+#[no_mangle]
+pub extern "C" fn fdu_scores_async(session: *const FduSession,
+                                   semester_id: *const c_char,
+                                   token: *const FduCancelToken,
+                                   request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        let semester_id = owned_str(semester_id, "semester_id")?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_scores(handles.session(), semester_id.as_ptr(), handles.token())
+        });
+    }))
+}
+
+// Return the GPA as a JSON object of `{"gpa", "ranking", "total", "percentage", "credits", "major", "progress"}`,
+// where `ranking` is counted from 1 among the `total` students of the major. Graduate students are not ranked:
+// `ranking`, `total` and `percentage` are null for them, and `progress` is `{"required_credits", "earned_credits"}`
+// of their training plan, null for undergraduates.
+#[no_mangle]
+pub extern "C" fn fdu_gpa(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let session = FduSession::borrow(session)?;
+        match enter_system(session, token)? {
+            StudentType::Undergraduate => session.fdu.get_gpa()?,
+            StudentType::Graduate => session.fdu.get_graduate_gpa()?,
+        }
+    })))
+}
+
+// The `_async` variant of `fdu_gpa()`.
+#[no_mangle]
+pub extern "C" fn fdu_gpa_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_gpa(handles.session(), handles.token()));
+    }))
+}
+
+// Return the training plan (培养方案) of an undergraduate with the progress in it, as the JSON object of its root
+// category, where a category is `{"id", "name", "required_credits", "earned_credits", "in_progress_credits",
+// "courses", "children"}` and a course `{"course_id", "name", "credit", "semester", "grade", "status", "transferred",
+// "counted_in"}`. `id` is made of the names of the categories from the root, like 通识教育课程/核心课程, and is empty
+// for the root. `status` is "passed", "in_progress" or "failed", and `counted_in` lists the ids of the categories
+// counting the course, more than one for a course counted twice. The credits are those shown by jwfw, which may not
+// add up. Fails with `FduErrorCode::ArgumentError` for graduate students, whose progress is that of `fdu_gpa()`.
+#[no_mangle]
+pub extern "C" fn fdu_degree_audit(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let session = FduSession::borrow(session)?;
+        if session.student_type() == StudentType::Graduate {
+            Err(SDKError::with_type(ErrorType::ArgumentError, "no degree audit for graduate students".to_string()))?
+        }
+        enter_system(session, token)?;
+        session.fdu.get_plan_audit()?
+    })))
+}
+
+// The `_async` variant of `fdu_degree_audit()`.
+#[no_mangle]
+pub extern "C" fn fdu_degree_audit_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_degree_audit(handles.session(), handles.token()));
+    }))
+}
+
+// Return the classrooms of `campus` (one of handan, jiangwan, fenglin and zhangjiang) free on `date`
+// (like 2023-01-03) in any slot from `start_slot` to `end_slot`, as a JSON array of
+// `{"name", "rooms": [{"name", "capacity", "free_slots"}]}` grouped by building.
+// `free_slots` lists the slots when the room is free, which may be only some of the queried ones.
+#[no_mangle]
+pub extern "C" fn fdu_empty_classrooms(session: *const FduSession,
+                                       campus: *const c_char,
+                                       date: *const c_char,
+                                       start_slot: i32,
+                                       end_slot: i32,
+                                       token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        let campus = borrow_str(campus, "campus")?;
+        let date = borrow_str(date, "date")?;
+        FduCancelToken::check(token)?;
+        fdu.get_jwfw_homepage()?;
+        FduCancelToken::check(token)?;
+        fdu.get_empty_classrooms(campus, date, start_slot, end_slot)?
+    })))
+}
+
+// The `_async` variant of `fdu_empty_classrooms()`.
+#[no_mangle]
+pub extern "C" fn fdu_empty_classrooms_async(session: *const FduSession,
+                                             campus: *const c_char,
+                                             date: *const c_char,
+                                             start_slot: i32,
+                                             end_slot: i32,
+                                             token: *const FduCancelToken,
+                                             request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        let campus = owned_str(campus, "campus")?;
+        let date = owned_str(date, "date")?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_empty_classrooms(handles.session(), campus.as_ptr(), date.as_ptr(), start_slot, end_slot, handles.token())
+        });
+    }))
+}
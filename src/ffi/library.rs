@@ -0,0 +1,141 @@
+use libc::*;
+
+use crate::error::*;
+use crate::fdu::library::LibraryClient;
+
+use super::cancel::*;
+use super::jobs::{self, *};
+use super::result::*;
+use super::retry::{self, retried};
+use super::session::*;
+
+// Return the areas of the library seat system as a JSON array of
+// `{"id", "name", "building", "total", "free", "closed"}`.
+// Areas under maintenance are included with `closed` set and no seat.
+#[no_mangle]
+pub extern "C" fn fdu_library_areas(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        FduCancelToken::check(token)?;
+        fdu.get_library_areas()?
+    })))
+}
+
+// The `_async` variant of `fdu_library_areas()`.
+#[no_mangle]
+pub extern "C" fn fdu_library_areas_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_library_areas(handles.session(), handles.token()));
+    }))
+}
+
+// Return the seats of an area today as a JSON array of `{"id", "name", "occupied"}`.
+#[no_mangle]
+pub extern "C" fn fdu_library_seats(session: *const FduSession, area_id: i64, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        FduCancelToken::check(token)?;
+        fdu.get_library_seats(area_id)?
+    })))
+}
+
+// The `_async` variant of `fdu_library_seats()`.
+#[no_mangle]
+pub extern "C" fn fdu_library_seats_async(session: *const FduSession,
+                                          area_id: i64,
+                                          token: *const FduCancelToken,
+                                          request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_library_seats(handles.session(), area_id, handles.token())
+        });
+    }))
+}
+
+// Return a page of the books borrowed, the most recent first, as a JSON object
+// `{"items": [{"barcode", "title", "author", "borrowed", "returned"}], "next"}`.
+// The first page has an empty `page_token`, the next ones the `next` of the previous page, which is empty after the
+// last one. Nothing is kept between pages, so a caller may stop at any page.
+#[no_mangle]
+pub extern "C" fn fdu_library_borrow_history(session: *const FduSession,
+                                             page_token: *const c_char,
+                                             token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        let page_token = borrow_str(page_token, "page_token")?;
+        FduCancelToken::check(token)?;
+        fdu.get_borrow_history_page(page_token)?
+    })))
+}
+
+// The `_async` variant of `fdu_library_borrow_history()`.
+#[no_mangle]
+pub extern "C" fn fdu_library_borrow_history_async(session: *const FduSession,
+                                                   page_token: *const c_char,
+                                                   token: *const FduCancelToken,
+                                                   request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        let page_token = owned_str(page_token, "page_token")?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_library_borrow_history(handles.session(), page_token.as_ptr(), handles.token())
+        });
+    }))
+}
+
+// Return the books borrowed and not returned yet as a JSON array of `{"barcode", "title", "author", "due", "renewals"}`,
+// where `due` is e.g. 2024-03-15. Each call logs in the OPAC first, like those of the PE system.
+#[no_mangle]
+pub extern "C" fn fdu_library_loans(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        FduCancelToken::check(token)?;
+        fdu.get_loans()?
+    })))
+}
+
+// The `_async` variant of `fdu_library_loans()`.
+#[no_mangle]
+pub extern "C" fn fdu_library_loans_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_library_loans(handles.session(), handles.token()));
+    }))
+}
+
+// Renew the loan of the book with `barcode`, and return the outcome as a JSON object `{"outcome", "due", "message"}`,
+// where `outcome` is one of renewed, max_renewals, reserved and overdue, `due` the new due date if renewed, and
+// `message` the one of the OPAC. Other failures, e.g. an unknown barcode, are errors.
+//
+// This changes the loans of the student, and is never retried.
+#[no_mangle]
+pub extern "C" fn fdu_library_renew(session: *const FduSession,
+                                    barcode: *const c_char,
+                                    token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        let barcode = borrow_str(barcode, "barcode")?;
+        if barcode.is_empty() {
+            Err(SDKError::with_type(ErrorType::ArgumentError, "barcode is empty".to_string()))?
+        }
+        FduCancelToken::check(token)?;
+        fdu.renew(barcode)?
+    }))
+}
+
+// The `_async` variant of `fdu_library_renew()`.
+#[no_mangle]
+pub extern "C" fn fdu_library_renew_async(session: *const FduSession,
+                                          barcode: *const c_char,
+                                          token: *const FduCancelToken,
+                                          request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        let barcode = owned_str(barcode, "barcode")?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_library_renew(handles.session(), barcode.as_ptr(), handles.token())
+        });
+    }))
+}
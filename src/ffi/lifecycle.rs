@@ -0,0 +1,59 @@
+use std::ffi::CStr;
+
+use libc::*;
+
+use crate::fdu::prelude::*;
+
+use super::captcha;
+use super::result::*;
+
+// Version of the C ABI, bumped on every incompatible change of bindings.h: a removed or changed export, a renumbered
+// error code, a different layout of a struct... Callers compare it with the version they are built against.
+pub const FDU_ABI_VERSION: u32 = 1;
+
+static VERSION: &CStr = match CStr::from_bytes_with_nul(concat!(env!("CARGO_PKG_VERSION"), "\0").as_bytes()) {
+    Ok(version) => version,
+    Err(_) => panic!("invalid package version"),
+};
+
+// Return `FDU_ABI_VERSION` of the library, which may differ from the one in the header of the caller.
+#[no_mangle]
+pub extern "C" fn fdu_abi_version() -> u32 {
+    FDU_ABI_VERSION
+}
+
+// Return the version of the library, e.g. "0.1.0". The string is static and must not be freed.
+#[no_mangle]
+pub extern "C" fn fdu_version() -> *const c_char {
+    VERSION.as_ptr()
+}
+
+// Set up the global state of the library. Callers should call it once before anything else, and may call it again
+// after `fdu_shutdown()`; calling it twice in a row is harmless.
+#[no_mangle]
+pub extern "C" fn fdu_init() -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        // Building a client initializes the TLS backend and loads the root certificates, so that a broken setup is
+        // reported here instead of by the first request. The blocking runtime of reqwest is owned by each client.
+        Fdu::client_builder().build()?;
+    }))
+}
+
+// Tear down the global state set up by `fdu_init()`, i.e. drop the logins waiting for a captcha. Sessions are not
+// affected and must still be freed with `fdu_session_free()`.
+#[no_mangle]
+pub extern "C" fn fdu_shutdown() {
+    guard_or((), captcha::discard_all)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_version() {
+        let version = unsafe { CStr::from_ptr(fdu_version()) };
+        assert_eq!(version.to_str().unwrap(), env!("CARGO_PKG_VERSION"));
+        assert_eq!(fdu_abi_version(), FDU_ABI_VERSION);
+    }
+}
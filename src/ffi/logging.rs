@@ -0,0 +1,108 @@
+// Forward the records of the `log` crate, from this crate and its dependencies (e.g. reqwest), to a callback of the
+// caller, so that they end up in the logger of the application instead of nowhere.
+use std::ffi::CString;
+use std::sync::RwLock;
+
+use libc::*;
+use log::{LevelFilter, Log, Metadata, Record};
+
+use crate::fdu::prelude::*;
+
+use super::result::*;
+
+// The verbosity of the logs, each level including the ones before it.
+#[repr(i32)]
+pub enum FduLogLevel {
+    Off = 0,
+    Error = 1,
+    Warn = 2,
+    Info = 3,
+    Debug = 4,
+    Trace = 5,
+}
+
+// Receive a record at `level` (an `FduLogLevel`). The callback may be called from any thread, possibly from several
+// at the same time, and `message` is only valid during the call.
+pub type FduLogCallback = Option<extern "C" fn(level: i32, message: *const c_char)>;
+
+static CALLBACK: RwLock<FduLogCallback> = RwLock::new(None);
+
+struct Bridge;
+
+impl Log for Bridge {
+    fn enabled(&self, metadata: &Metadata) -> bool {
+        metadata.level() <= log::max_level()
+    }
+
+    fn log(&self, record: &Record) {
+        if !self.enabled(record.metadata()) {
+            return;
+        }
+        // Do not hold the lock during the call, so that the callback may replace itself.
+        let Some(callback) = *CALLBACK.read().unwrap_or_else(|e| e.into_inner()) else {
+            return;
+        };
+        let message = format!("{}: {}", record.target(), record.args()).replace('\0', "\\0");
+        let message = CString::new(message).unwrap();
+        callback(record.level() as i32, message.as_ptr());
+    }
+
+    fn flush(&self) {}
+}
+
+static BRIDGE: Bridge = Bridge;
+
+// Send the records up to `level` to `callback`, replacing the previous one. A NULL callback or
+// `FDU_LOG_LEVEL_OFF` stops the logs.
+#[no_mangle]
+pub extern "C" fn fdu_set_log_callback(level: i32, callback: FduLogCallback) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let filter = match level {
+            0 => LevelFilter::Off,
+            1 => LevelFilter::Error,
+            2 => LevelFilter::Warn,
+            3 => LevelFilter::Info,
+            4 => LevelFilter::Debug,
+            5 => LevelFilter::Trace,
+            _ => Err(SDKError::with_type(ErrorType::ArgumentError, format!("invalid log level {}", level)))?,
+        };
+        // It only fails if a logger is already set, which can only be this one.
+        let _ = log::set_logger(&BRIDGE);
+        *CALLBACK.write().unwrap_or_else(|e| e.into_inner()) = callback;
+        log::set_max_level(if callback.is_some() { filter } else { LevelFilter::Off });
+    }))
+}
+
+#[cfg(test)]
+mod tests {
+    use std::ffi::CStr;
+    use std::sync::Mutex;
+
+    use super::*;
+
+    static RECORDS: Mutex<Vec<(i32, String)>> = Mutex::new(Vec::new());
+
+    extern "C" fn record(level: i32, message: *const c_char) {
+        let message = unsafe { CStr::from_ptr(message) }.to_str().unwrap().to_string();
+        // Other tests log in parallel, keep only ours.
+        if message.contains("test_log_callback") {
+            RECORDS.lock().unwrap().push((level, message));
+        }
+    }
+
+    #[test]
+    fn test_log_callback() {
+        unsafe { free_result(fdu_set_log_callback(FduLogLevel::Info as i32, Some(record))) };
+        log::warn!("test_log_callback warn");
+        log::debug!("test_log_callback debug");
+        unsafe { free_result(fdu_set_log_callback(FduLogLevel::Info as i32, None)) };
+        log::error!("test_log_callback error");
+
+        let records = RECORDS.lock().unwrap();
+        assert_eq!(*records, vec![(FduLogLevel::Warn as i32, format!("{}: test_log_callback warn", module_path!()))]);
+
+        let r = fdu_set_log_callback(42, Some(record));
+        assert_eq!(unsafe { (*r).code }, FduErrorCode::InvalidArgument as i32);
+        unsafe { free_result(r) };
+    }
+}
@@ -0,0 +1,33 @@
+// Everything crossing the C ABI lives in this module, except the examples in lib.rs.
+//
+// Conventions for exports:
+// - Functions that may fail return a heap-allocated `FduResult`, which the caller must release with `free_result()`.
+// - Strings passed in are borrowed NUL-terminated UTF-8 `const char *` and are never freed by us.
+// - Binary data is passed in as a (`const uint8_t *`, `size_t`) pair, and returned as an `FduBuffer`.
+// - Structured values are returned as JSON in `FduResult::value`.
+// - The small values of a few exports can also be written into a buffer of the caller, see `fdu_call_into()` in call.rs.
+// - Callers call `fdu_init()` before anything else, after checking `fdu_abi_version()`.
+// - Exports which may be slow (i.e. those doing network requests) take an optional `FduCancelToken`, and have an `_async` variant run as a job, see jobs.rs.
+pub mod alloc;
+pub mod announcement;
+pub mod batch;
+pub mod buffer;
+pub mod call;
+pub mod cancel;
+pub mod captcha;
+pub mod config;
+pub mod ecard;
+pub mod jobs;
+pub mod jwfw;
+pub mod library;
+pub mod lifecycle;
+pub mod logging;
+pub mod pe;
+pub mod probe;
+pub mod raw;
+pub mod result;
+pub mod retry;
+pub mod session;
+pub mod testing;
+pub mod trace;
+pub mod xk;
@@ -0,0 +1,49 @@
+use crate::fdu::pe::PEClient;
+
+use super::cancel::*;
+use super::jobs::{self, *};
+use super::result::*;
+use super::retry::{self, retried};
+use super::session::*;
+
+// Return the PE check-ins (体锻打卡) of this semester as a JSON object
+// `{"morning", "gym", "check_ins": [{"kind", "place", "time", "valid"}]}`, where `morning` and `gym` are the numbers of
+// valid check-ins counted by the PE system and `kind` is one of "morning", "gym" or "other".
+#[no_mangle]
+pub extern "C" fn fdu_pe_records(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        FduCancelToken::check(token)?;
+        fdu.get_pe_records()?
+    })))
+}
+
+// The `_async` variant of `fdu_pe_records()`.
+#[no_mangle]
+pub extern "C" fn fdu_pe_records_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_pe_records(handles.session(), handles.token()));
+    }))
+}
+
+// Return the scores of the latest fitness test (体测) as a JSON object
+// `{"year", "exempt", "total", "level", "items": [{"name", "result", "unit", "score", "level"}]}`.
+// Exempted students (免测) have `exempt` set, a null `total` and no item.
+#[no_mangle]
+pub extern "C" fn fdu_pe_test_scores(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        FduCancelToken::check(token)?;
+        fdu.get_fitness_scores()?
+    })))
+}
+
+// The `_async` variant of `fdu_pe_test_scores()`.
+#[no_mangle]
+pub extern "C" fn fdu_pe_test_scores_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_pe_test_scores(handles.session(), handles.token()));
+    }))
+}
@@ -0,0 +1,52 @@
+use std::time::Duration;
+
+use libc::*;
+
+use crate::error::*;
+use crate::fdu::probe::{self, Service};
+
+use super::cancel::*;
+use super::jobs::{self, *};
+use super::result::*;
+
+fn parse_services(services: *const c_char) -> Result<Vec<Service>> {
+    let names: Vec<String> = serde_json::from_str(borrow_str(services, "services")?)
+        .map_err(|e| SDKError::with_type(ErrorType::ArgumentError, format!("invalid services: {}", e)))?;
+    Service::parse_all(&names)
+}
+
+// Probe the services of the university concurrently, and return how each did as a JSON array of
+// `{"service", "reachable", "latency_ms", "http_status", "maintenance_page", "error"}`, in the order of `services`.
+// No session is needed. `services` is a JSON array of "uis", "jwfw", "ecard" or "library", all of them if it is empty;
+// others fail with `FduErrorCode::InvalidArgument`, whose message lists the valid ones.
+//
+// A service is reachable if it answers, whatever the status: a 503 or a maintenance page is reachable. Each probe
+// lasts `timeout_millis` at most, 5 seconds if it is 0 or longer, and ends by the deadline of `token` anyway, after
+// which the service is unreachable. Callers waiting for the deadline should pass a shorter timeout, to get the results
+// by then. Probes are never retried, so that the latencies are those of a single request.
+#[no_mangle]
+pub extern "C" fn fdu_probe(services: *const c_char, timeout_millis: u64, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(try {
+        let services = parse_services(services)?;
+        FduCancelToken::check(token)?;
+        let timeout = (timeout_millis > 0).then(|| Duration::from_millis(timeout_millis));
+        probe::probe(&services, timeout, FduCancelToken::deadline_of(token))?
+    }))
+}
+
+// The `_async` variant of `fdu_probe()`. Unknown services are rejected at once.
+#[no_mangle]
+pub extern "C" fn fdu_probe_async(services: *const c_char,
+                                  timeout_millis: u64,
+                                  token: *const FduCancelToken,
+                                  request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let services = owned_str(services, "services")?;
+        parse_services(services.as_ptr())?;
+        // Tokens are `Sync`, so sending the pointer is fine while the caller keeps it alive.
+        let token = token as usize;
+        jobs::spawn(request_id, token as *const FduCancelToken, move || {
+            fdu_probe(services.as_ptr(), timeout_millis, token as *const FduCancelToken)
+        });
+    }))
+}
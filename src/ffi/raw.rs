@@ -0,0 +1,74 @@
+use std::ptr;
+
+use libc::*;
+
+use crate::fdu::prelude::*;
+use crate::fdu::raw::RawRequest;
+
+use super::buffer::*;
+use super::cancel::*;
+use super::result::*;
+use super::session::*;
+
+// Send an HTTP request with the cookies of `session`, e.g. to an endpoint libfdu has no export for yet, and return
+// the response as a JSON object `{"status", "url", "headers": [[name, value]]}`, with its body in `*out`, which the
+// caller frees with `free_buffer()`. `*out` is NULL if the call fails.
+//
+// `request` is a JSON object `{"method", "url", "headers": [[name, value]]}`, where an empty method is GET, and `body`
+// of `body_len` bytes its body. The redirects are followed, up to 10, and `url` is the one of the last response.
+// Requests, and redirects, to hosts other than those of the university (fudan.edu.cn and its subdomains) and of
+// `raw_allowed_hosts` of the HTTP config fail with `FduErrorCode::InvalidArgument`; a body over `raw_max_body_bytes`,
+// 8 MiB by default, fails with `FduErrorCode::Network`. Like the other calls, a 502, 503 or 504 fails with
+// `FduErrorCode::Network` too, while any other status is returned. The request is never retried.
+//
+// There is no `_async` variant, since jobs cannot return the buffer: the call runs on the thread of the caller, like
+// `fdu_login()`.
+#[no_mangle]
+pub extern "C" fn fdu_session_do(session: *const FduSession,
+                                 request: *const c_char,
+                                 body: *const u8,
+                                 body_len: size_t,
+                                 token: *const FduCancelToken,
+                                 out: *mut *mut FduBuffer) -> *mut FduResult {
+    guard(|| FduResult::from_json(try {
+        if out.is_null() {
+            Err(SDKError::with_type(ErrorType::ArgumentError, "out is NULL".to_string()))?
+        }
+        unsafe { *out = ptr::null_mut() };
+        let fdu = &FduSession::borrow(session)?.fdu;
+        let mut request: RawRequest = serde_json::from_str(borrow_str(request, "request")?)
+            .map_err(|e| SDKError::with_type(ErrorType::ArgumentError, format!("invalid request: {}", e)))?;
+        request.body = borrow_bytes(body, body_len, "body")?.to_vec();
+        let _call = FduCancelToken::enter(token);
+        let mut response = fdu.send_raw(request, || FduCancelToken::check(token))?;
+        FduBuffer::write_to(out, std::mem::take(&mut response.body))?;
+        response
+    }))
+}
+
+#[cfg(test)]
+mod tests {
+    use std::ffi::CString;
+
+    use super::super::testing::*;
+    use super::*;
+
+    #[test]
+    fn test_session_do_invalid() {
+        let mut session = ptr::null_mut();
+        free_result(fdu_test_session_new(&mut session));
+        for request in ["", "{", r#"{"url": "https://jwfw.fudan.edu.cn/", "body": "x"}"#, r#"{"url": "https://example.com/"}"#] {
+            let request = CString::new(request).unwrap();
+            let mut out = ptr::null_mut();
+            let r = fdu_session_do(session, request.as_ptr(), ptr::null(), 0, ptr::null(), &mut out);
+            assert_eq!(unsafe { (*r).code }, FduErrorCode::InvalidArgument as i32);
+            assert!(out.is_null());
+            free_result(r);
+        }
+        let request = CString::new(r#"{"url": "https://jwfw.fudan.edu.cn/"}"#).unwrap();
+        let r = fdu_session_do(session, request.as_ptr(), ptr::null(), 0, ptr::null(), ptr::null_mut());
+        assert_eq!(unsafe { (*r).code }, FduErrorCode::InvalidArgument as i32);
+        free_result(r);
+        fdu_session_free(session);
+    }
+}
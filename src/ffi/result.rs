@@ -0,0 +1,236 @@
+use std::any::Any;
+use std::ffi::{CStr, CString};
+use std::panic::{catch_unwind, AssertUnwindSafe};
+use std::ptr;
+
+use libc::*;
+use serde::Serialize;
+
+use crate::error::*;
+
+// Error codes stored in `FduResult::code`.
+//
+// The numbers are part of the ABI: callers (e.g. the Go binding) switch on them, so never renumber an existing code.
+#[repr(i32)]
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum FduErrorCode {
+    Ok = 0,
+    Unknown = 1,
+    Network = 2,
+    AuthFailed = 3,
+    Parse = 4,
+    InvalidArgument = 5,
+    // The library panicked. `message` holds the panic message.
+    Panic = 6,
+    // The call was cancelled through its `FduCancelToken`.
+    Cancelled = 7,
+    // Login needs a captcha, or the answer to it was wrong. Unlike other errors, `value` holds the continuation
+    // token to pass to `fdu_captcha_image()` and `fdu_login_with_captcha()`.
+    CaptchaRequired = 8,
+    // The account has QR payment disabled, see `fdu_card_payment_code()`.
+    QrDisabled = 9,
+    // A server has none of the pinned keys in its chain, see `fdu_set_http_config()`. `message` names its host.
+    CertPinMismatch = 10,
+    // A request would have waited for the rate limit of its host past the deadline of the call, see
+    // `fdu_cancel_token_set_deadline()`. `value` holds a JSON object `{"host", "retry_after_millis"}`: the request
+    // could be sent in `retry_after_millis`.
+    RateLimited = 11,
+}
+
+impl From<&ErrorType> for FduErrorCode {
+    fn from(t: &ErrorType) -> Self {
+        match t {
+            ErrorType::LoginError => FduErrorCode::AuthFailed,
+            ErrorType::ParseError => FduErrorCode::Parse,
+            ErrorType::NetworkError => FduErrorCode::Network,
+            ErrorType::ArgumentError => FduErrorCode::InvalidArgument,
+            ErrorType::CancelledError => FduErrorCode::Cancelled,
+            ErrorType::CaptchaRequiredError => FduErrorCode::CaptchaRequired,
+            ErrorType::QrDisabledError => FduErrorCode::QrDisabled,
+            ErrorType::CertPinMismatchError => FduErrorCode::CertPinMismatch,
+            ErrorType::RateLimitedError => FduErrorCode::RateLimited,
+            ErrorType::NoneError | ErrorType::OtherError => FduErrorCode::Unknown,
+        }
+    }
+}
+
+// The result of a fallible export.
+//
+// On success, `code` is 0, `value` holds the returned string (may be NULL if there is nothing to return) and `message` is NULL.
+// On failure, `code` is one of `FduErrorCode` and `message` describes the error. `value` is NULL, except for
+// `FduErrorCode::CaptchaRequired` and `FduErrorCode::RateLimited`.
+#[repr(C)]
+pub struct FduResult {
+    pub value: *mut c_char,
+    pub code: i32,
+    pub message: *mut c_char,
+}
+
+impl FduResult {
+    pub fn ok(value: String) -> *mut FduResult {
+        Box::into_raw(Box::new(FduResult {
+            value: to_c_string(value),
+            code: FduErrorCode::Ok as i32,
+            message: ptr::null_mut(),
+        }))
+    }
+
+    pub fn empty() -> *mut FduResult {
+        Box::into_raw(Box::new(FduResult {
+            value: ptr::null_mut(),
+            code: FduErrorCode::Ok as i32,
+            message: ptr::null_mut(),
+        }))
+    }
+
+    pub fn err(code: FduErrorCode, message: String) -> *mut FduResult {
+        Box::into_raw(Box::new(FduResult {
+            value: ptr::null_mut(),
+            code: code as i32,
+            message: to_c_string(message),
+        }))
+    }
+
+    // The result of an error, with its value if it has one.
+    pub fn from_error(e: SDKError) -> *mut FduResult {
+        Box::into_raw(Box::new(FduResult {
+            value: e.value().map_or(ptr::null_mut(), |value| to_c_string(value.to_string())),
+            code: FduErrorCode::from(e.error_type()) as i32,
+            message: to_c_string(e.to_string()),
+        }))
+    }
+
+    pub fn from_result(r: Result<String>) -> *mut FduResult {
+        match r {
+            Ok(value) => FduResult::ok(value),
+            Err(e) => FduResult::from_error(e),
+        }
+    }
+
+    // Serialize the value into JSON, which is the format of every structured value we return.
+    pub fn from_json<T: Serialize>(r: Result<T>) -> *mut FduResult {
+        FduResult::from_result(r.and_then(|v| Ok(serde_json::to_string(&v)?)))
+    }
+
+    pub fn from_unit(r: Result<()>) -> *mut FduResult {
+        match r {
+            Ok(()) => FduResult::empty(),
+            Err(e) => FduResult::from_error(e),
+        }
+    }
+}
+
+// Convert a Rust String into an owned C string, which should be freed by `free_string()`.
+//
+// A NUL byte would end the C string early, so NULs (e.g. in a page scraped from a server) are escaped as "\\0",
+// like in log messages, rather than losing the rest of the string.
+pub(crate) fn to_c_string(s: String) -> *mut c_char {
+    CString::new(s)
+        .unwrap_or_else(|e| {
+            let s = String::from_utf8(e.into_vec()).unwrap();
+            CString::new(s.replace('\0', "\\0")).unwrap()
+        })
+        .into_raw()
+}
+
+// Borrow a string argument passed in by the caller. `name` is only used in the error message.
+pub(crate) fn borrow_str<'a>(s: *const c_char, name: &str) -> Result<&'a str> {
+    if s.is_null() {
+        return Err(SDKError::with_type(ErrorType::ArgumentError, format!("{} is NULL", name)));
+    }
+    unsafe { CStr::from_ptr(s) }.to_str().map_err(|e| {
+        SDKError::with_type(ErrorType::ArgumentError,
+                            format!("{} is not valid UTF-8 at byte {}", name, e.valid_up_to()))
+    })
+}
+
+// Unwinding across the C ABI is undefined behavior, so every export runs its body inside `guard()`,
+// which turns a panic into an `FduErrorCode::Panic` result.
+//
+// The closure is asserted to be unwind safe: most exports capture raw pointers, which the compiler cannot reason about.
+// A panic only leaves a half-updated handle behind in the worst case, and the caller has been told about the failure.
+//
+// It also fails the exports called from the trace callback, which would otherwise wait for the call being traced,
+// see trace.rs.
+pub(crate) fn guard<F: FnOnce() -> *mut FduResult>(f: F) -> *mut FduResult {
+    if super::trace::in_callback() {
+        return FduResult::err(FduErrorCode::InvalidArgument, "the trace callback must not call the library".to_string());
+    }
+    catch_unwind(AssertUnwindSafe(f)).unwrap_or_else(|payload| FduResult::err(FduErrorCode::Panic, panic_message(payload)))
+}
+
+// Same as `guard()` for exports which do not return an `FduResult`; `default` is returned on panic.
+pub(crate) fn guard_or<T, F: FnOnce() -> T>(default: T, f: F) -> T {
+    catch_unwind(AssertUnwindSafe(f)).unwrap_or(default)
+}
+
+fn panic_message(payload: Box<dyn Any + Send>) -> String {
+    // panic!() with a literal carries a &str, and with a format string carries a String.
+    if let Some(s) = payload.downcast_ref::<&str>() {
+        s.to_string()
+    } else if let Some(s) = payload.downcast_ref::<String>() {
+        s.clone()
+    } else {
+        "unknown panic".to_string()
+    }
+}
+
+#[no_mangle]
+pub extern "C" fn free_result(r: *mut FduResult) {
+    guard_or((), || {
+        if r.is_null() {
+            return;
+        }
+        let r = unsafe { Box::from_raw(r) };
+        crate::free_string(r.value);
+        crate::free_string(r.message);
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_guard_catches_panic() {
+        let r = guard(|| panic!("boom {}", 42));
+        unsafe {
+            assert_eq!((*r).code, FduErrorCode::Panic as i32);
+            assert_eq!(CStr::from_ptr((*r).message).to_str().unwrap(), "boom 42");
+        }
+        free_result(r);
+        assert_eq!(guard_or(-1, || -> i32 { panic!("boom") }), -1);
+    }
+
+    #[test]
+    fn test_from_error() {
+        let r = FduResult::from_unit(Err(SDKError::with_type(ErrorType::ParseError, "no table".to_string())));
+        assert!(unsafe { (*r).value }.is_null());
+        free_result(r);
+        let e = SDKError::with_type(ErrorType::RateLimitedError, "rate limited".to_string()).with_value("{}".to_string());
+        let r = FduResult::from_json::<()>(Err(e));
+        unsafe {
+            assert_eq!((*r).code, FduErrorCode::RateLimited as i32);
+            assert_eq!(CStr::from_ptr((*r).value).to_str().unwrap(), "{}");
+            assert_eq!(CStr::from_ptr((*r).message).to_str().unwrap(), "rate limited");
+        }
+        free_result(r);
+    }
+
+    #[test]
+    fn test_c_strings() {
+        for s in ["", "数据结构", "👩‍💻 ok", &"长".repeat(1 << 20)] {
+            let c = to_c_string(s.to_string());
+            assert_eq!(borrow_str(c, "s").unwrap(), s);
+            crate::free_string(c);
+        }
+        let c = to_c_string("a\0b\0".to_string());
+        assert_eq!(borrow_str(c, "s").unwrap(), "a\\0b\\0");
+        crate::free_string(c);
+
+        let invalid = b"ok\xff\0";
+        let e = borrow_str(invalid.as_ptr() as *const c_char, "password").unwrap_err();
+        assert_eq!(FduErrorCode::from(e.error_type()), FduErrorCode::InvalidArgument);
+        assert!(e.to_string().contains("password is not valid UTF-8 at byte 2"), "{}", e);
+    }
+}
@@ -0,0 +1,237 @@
+use std::collections::hash_map::RandomState;
+use std::hash::{BuildHasher, Hasher};
+use std::sync::RwLock;
+use std::thread;
+use std::time::{Duration, Instant};
+
+use libc::*;
+use serde::Deserialize;
+
+use crate::error::*;
+
+use super::cancel::*;
+use super::result::*;
+use super::session::*;
+
+// The most attempts a policy may ask for: the delays grow exponentially, and a call retried longer than that is
+// better reported to the user.
+const MAX_ATTEMPTS: u32 = 10;
+
+// How often a sleep between attempts checks the cancellation token.
+const SLEEP_STEP: Duration = Duration::from_millis(20);
+
+// How a failed call is made again, set with `fdu_set_retry_policy()`.
+//
+// Only the exports which read from the servers retry, since they are safe to send twice: login, logout, the payment
+// code and anything changing the state of the account are made once, whatever the policy.
+#[derive(Clone, Debug, Default, Deserialize, PartialEq)]
+#[serde(default, deny_unknown_fields)]
+pub struct RetryPolicy {
+    // The most attempts of a call, including the first one: 0 and 1 mean no retry.
+    pub max_attempts: u32,
+    // The delay before the second attempt, doubled before each of the next ones up to `max_delay_millis` (unbounded
+    // if 0). Each delay is drawn between its half and itself, so that clients failing together do not retry together.
+    pub base_delay_millis: u64,
+    pub max_delay_millis: u64,
+    // The `FduErrorCode`s of the failures to retry, only `Network` if empty.
+    pub retry_on: Vec<i32>,
+}
+
+// The policy of the sessions created afterwards, and of the exports without a session.
+static DEFAULT: RwLock<RetryPolicy> = RwLock::new(RetryPolicy {
+    max_attempts: 0,
+    base_delay_millis: 0,
+    max_delay_millis: 0,
+    retry_on: Vec::new(),
+});
+
+pub(crate) fn current() -> RetryPolicy {
+    DEFAULT.read().unwrap_or_else(|e| e.into_inner()).clone()
+}
+
+impl RetryPolicy {
+    fn parse(json: &str) -> Result<Self> {
+        let policy: RetryPolicy = serde_json::from_str(json)
+            .map_err(|e| SDKError::with_type(ErrorType::ArgumentError, format!("invalid retry policy: {}", e)))?;
+        if policy.max_attempts > MAX_ATTEMPTS {
+            Err(SDKError::with_type(ErrorType::ArgumentError,
+                                    format!("max_attempts: {} attempts, at most {} are allowed", policy.max_attempts, MAX_ATTEMPTS)))?;
+        }
+        for &code in &policy.retry_on {
+            // A call cancelled, or with invalid arguments, would fail the same way again.
+            let retryable = [FduErrorCode::Unknown, FduErrorCode::Network, FduErrorCode::AuthFailed, FduErrorCode::Parse,
+                             FduErrorCode::QrDisabled].iter().any(|&c| c as i32 == code);
+            if !retryable {
+                Err(SDKError::with_type(ErrorType::ArgumentError, format!("retry_on: code {} cannot be retried", code)))?;
+            }
+        }
+        Ok(policy)
+    }
+
+    fn retries(&self, e: &SDKError) -> bool {
+        let code = FduErrorCode::from(e.error_type());
+        if self.retry_on.is_empty() {
+            return code == FduErrorCode::Network;
+        }
+        self.retry_on.contains(&(code as i32))
+    }
+
+    // The delay after the given number of failed attempts, counted from 1, before the jitter.
+    fn backoff(&self, failed: u32) -> Duration {
+        let mut millis = self.base_delay_millis.saturating_mul(1 << (failed - 1).min(32));
+        if self.max_delay_millis > 0 {
+            millis = millis.min(self.max_delay_millis);
+        }
+        Duration::from_millis(millis)
+    }
+
+    fn delay(&self, failed: u32) -> Duration {
+        let max = self.backoff(failed);
+        let random = RandomState::new().build_hasher().finish();
+        max / 2 + Duration::from_nanos(random % (max.as_nanos() as u64 / 2 + 1))
+    }
+}
+
+// The policy of the calls on `session`, or the default one for a NULL session.
+pub(crate) fn policy(session: *const FduSession) -> RetryPolicy {
+    match FduSession::borrow(session) {
+        Ok(session) => session.retry_policy(),
+        Err(_) => current(),
+    }
+}
+
+// Run `f`, the body of an export which only reads from the servers, again while it fails with an error `policy`
+// retries and attempts are left. If the last attempt fails after some others, its error tells how many were made.
+pub(crate) fn retried<T>(policy: &RetryPolicy, token: *const FduCancelToken, mut f: impl FnMut() -> Result<T>) -> Result<T> {
+    let mut attempt = 1;
+    loop {
+        match f() {
+            Err(e) if attempt < policy.max_attempts && policy.retries(&e) => {
+                let delay = policy.delay(attempt);
+                log::warn!("attempt {} of {} failed, retrying in {:?}: {}", attempt, policy.max_attempts, delay, e);
+                sleep(delay, token)?;
+                attempt += 1;
+            }
+            Err(e) if attempt > 1 => return Err(e.context(&format!("failed after {} attempts", attempt))),
+            r => return r,
+        }
+    }
+}
+
+// Sleep for `delay`, unless the call is cancelled in the meantime.
+fn sleep(delay: Duration, token: *const FduCancelToken) -> Result<()> {
+    let end = Instant::now() + delay;
+    loop {
+        FduCancelToken::check(token)?;
+        let now = Instant::now();
+        if now >= end {
+            return Ok(());
+        }
+        thread::sleep((end - now).min(SLEEP_STEP));
+    }
+}
+
+// Set the retry policy from a JSON object `{"max_attempts", "base_delay_millis", "max_delay_millis", "retry_on"}`, see
+// `RetryPolicy`, where missing fields are zero, i.e. no retry.
+//
+// With a NULL session, it is the policy of the sessions created afterwards and of the exports without a session, like
+// `fdu_set_http_config()`. With a session, it replaces the policy of its next calls, and a NULL or empty `json`
+// restores the policy the session was created with: the caller sets it before a call to retry that call only.
+#[no_mangle]
+pub extern "C" fn fdu_set_retry_policy(session: *const FduSession, json: *const c_char) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        if session.is_null() {
+            let policy = RetryPolicy::parse(borrow_str(json, "json")?)?;
+            *DEFAULT.write().unwrap_or_else(|e| e.into_inner()) = policy;
+        } else {
+            let session = FduSession::borrow(session)?;
+            let json = if json.is_null() { "" } else { borrow_str(json, "json")? };
+            session.set_retry_policy(if json.is_empty() { None } else { Some(RetryPolicy::parse(json)?) });
+        }
+    }))
+}
+
+#[cfg(test)]
+mod tests {
+    use std::cell::Cell;
+
+    use super::*;
+
+    fn network_error() -> SDKError {
+        SDKError::with_type(ErrorType::NetworkError, "502 Bad Gateway".to_string())
+    }
+
+    #[test]
+    fn test_parse() {
+        let policy = RetryPolicy::parse(r#"{"max_attempts": 4, "base_delay_millis": 100, "retry_on": [2, 4]}"#).unwrap();
+        assert_eq!(policy, RetryPolicy { max_attempts: 4, base_delay_millis: 100, max_delay_millis: 0, retry_on: vec![2, 4] });
+        assert_eq!(RetryPolicy::parse("{}").unwrap(), RetryPolicy::default());
+        for json in [r#"{"max_attempts": 11}"#, r#"{"retry_on": [7]}"#, r#"{"retry_on": [0]}"#, r#"{"attempts": 3}"#, "null"] {
+            let e = RetryPolicy::parse(json).unwrap_err();
+            assert_eq!(FduErrorCode::from(e.error_type()), FduErrorCode::InvalidArgument, "{}", json);
+        }
+    }
+
+    #[test]
+    fn test_backoff() {
+        let policy = RetryPolicy { max_attempts: 10, base_delay_millis: 100, max_delay_millis: 1000, ..Default::default() };
+        let delays: Vec<_> = (1..=6).map(|n| policy.backoff(n).as_millis()).collect();
+        assert_eq!(delays, [100, 200, 400, 800, 1000, 1000]);
+        for n in 1..=6 {
+            let delay = policy.delay(n);
+            assert!(policy.backoff(n) / 2 <= delay && delay <= policy.backoff(n), "{:?}", delay);
+        }
+        let unbounded = RetryPolicy { base_delay_millis: 1, ..Default::default() };
+        assert_eq!(unbounded.backoff(64), Duration::from_millis(1 << 32));
+    }
+
+    #[test]
+    fn test_retried() {
+        // Two failures, then a success: the delays are 20-40ms, then 40-80ms.
+        let policy = RetryPolicy { max_attempts: 4, base_delay_millis: 40, max_delay_millis: 80, ..Default::default() };
+        let calls = Cell::new(0);
+        let start = Instant::now();
+        let r = retried(&policy, std::ptr::null(), || {
+            calls.set(calls.get() + 1);
+            if calls.get() <= 2 { Err(network_error()) } else { Ok(calls.get()) }
+        });
+        let elapsed = start.elapsed();
+        assert_eq!(r.unwrap(), 3);
+        assert!(Duration::from_millis(60) <= elapsed && elapsed < Duration::from_millis(120 + 50), "{:?}", elapsed);
+
+        // The attempts run out.
+        calls.set(0);
+        let e = retried(&policy, std::ptr::null(), || -> Result<()> {
+            calls.set(calls.get() + 1);
+            Err(network_error())
+        }).unwrap_err();
+        assert_eq!(calls.get(), 4);
+        assert!(matches!(e.error_type(), ErrorType::NetworkError));
+        assert_eq!(e.to_string(), "failed after 4 attempts: 502 Bad Gateway");
+
+        // Other errors are not retried.
+        calls.set(0);
+        let e = retried(&policy, std::ptr::null(), || -> Result<()> {
+            calls.set(calls.get() + 1);
+            Err(SDKError::with_type(ErrorType::ParseError, "no table".to_string()))
+        }).unwrap_err();
+        assert_eq!((calls.get(), e.to_string()), (1, "no table".to_string()));
+    }
+
+    #[test]
+    fn test_retried_cancelled() {
+        let policy = RetryPolicy { max_attempts: 3, base_delay_millis: 10_000, ..Default::default() };
+        let token = fdu_cancel_token_new();
+        let cancel = token as usize;
+        let canceller = thread::spawn(move || {
+            thread::sleep(Duration::from_millis(50));
+            fdu_cancel(cancel as *const FduCancelToken);
+        });
+        let start = Instant::now();
+        let e = retried(&policy, token, || -> Result<()> { Err(network_error()) }).unwrap_err();
+        assert!(matches!(e.error_type(), ErrorType::CancelledError));
+        assert!(start.elapsed() < Duration::from_secs(1));
+        canceller.join().unwrap();
+        fdu_cancel_token_free(token);
+    }
+}
@@ -0,0 +1,219 @@
+use std::cell::Cell;
+use std::ptr;
+use std::sync::atomic::{AtomicI64, Ordering};
+use std::sync::Mutex;
+
+use libc::*;
+
+use crate::fdu::prelude::*;
+use crate::fdu::yjsxt::StudentType;
+
+use super::buffer::*;
+use super::cancel::*;
+use super::captcha;
+use super::jobs::{self, *};
+use super::result::*;
+use super::retry::{self, retried, RetryPolicy};
+
+// Number of sessions not freed yet. Tests use it to check that callers do not leak sessions.
+pub(crate) static LIVE_SESSIONS: AtomicI64 = AtomicI64::new(0);
+
+// An opaque handle of a UIS session, created by `fdu_login()` and freed by `fdu_session_free()`.
+//
+// Thread safety: different sessions share no state and may be used from different threads at the same time.
+// A single session is NOT thread safe (e.g. login mutates it, and the cell below is not `Sync`), so callers
+// must serialize the calls on the same session. The Go binding does it with a lock per session.
+pub struct FduSession {
+    pub(crate) fdu: Fdu,
+    // Number of calls of `fdu_test_session_ping()`, used to check that callers serialize calls.
+    pub(crate) pings: Cell<u64>,
+    // The retry policy when the session was created, and the one set for its next calls, if any. The lock is only
+    // there for the exports of a batch, which run concurrently.
+    default_retry: RetryPolicy,
+    retry: Mutex<Option<RetryPolicy>>,
+    // The profile of the course selection, once logged in xk, see xk.rs.
+    pub(crate) xk_profile: Mutex<Option<i64>>,
+    // The student type set by `fdu_session_set_student_type()`, if any, see `student_type()`.
+    student_type: Mutex<Option<StudentType>>,
+}
+
+impl FduSession {
+    pub(crate) fn new(fdu: Fdu) -> Self {
+        LIVE_SESSIONS.fetch_add(1, Ordering::Relaxed);
+        Self {
+            fdu,
+            pings: Cell::new(0),
+            default_retry: retry::current(),
+            retry: Mutex::new(None),
+            xk_profile: Mutex::new(None),
+            student_type: Mutex::new(None),
+        }
+    }
+
+    // The type of the student, which tells the academic system of the session, jwfw or yjsxt: the one set by the
+    // caller, or else the one of the student id.
+    pub(crate) fn student_type(&self) -> StudentType {
+        let set = *self.student_type.lock().unwrap_or_else(|e| e.into_inner());
+        set.unwrap_or_else(|| StudentType::of(self.fdu.uid().unwrap_or_default()))
+    }
+
+    pub(crate) fn retry_policy(&self) -> RetryPolicy {
+        let retry = self.retry.lock().unwrap_or_else(|e| e.into_inner());
+        retry.clone().unwrap_or_else(|| self.default_retry.clone())
+    }
+
+    pub(crate) fn set_retry_policy(&self, policy: Option<RetryPolicy>) {
+        *self.retry.lock().unwrap_or_else(|e| e.into_inner()) = policy;
+    }
+
+    // Borrow the session behind a handle passed in by the caller.
+    pub(crate) fn borrow<'a>(session: *const FduSession) -> Result<&'a FduSession> {
+        if session.is_null() {
+            return Err(SDKError::with_type(ErrorType::ArgumentError, "session is NULL".to_string()));
+        }
+        Ok(unsafe { &*session })
+    }
+}
+
+impl Drop for FduSession {
+    fn drop(&mut self) {
+        LIVE_SESSIONS.fetch_sub(1, Ordering::Relaxed);
+    }
+}
+
+// Log in to UIS. On success, `*out` is set to a new session, otherwise it is set to NULL.
+//
+// If UIS asks for a captcha, the result has the code `FduErrorCode::CaptchaRequired` and its value is a continuation
+// token: get the image with `fdu_captcha_image()` and finish with `fdu_login_with_captcha()`.
+#[no_mangle]
+pub extern "C" fn fdu_login(
+    username: *const c_char,
+    password: *const c_char,
+    token: *const FduCancelToken,
+    out: *mut *mut FduSession,
+) -> *mut FduResult {
+    guard(|| {
+        let r: Result<(&str, &str)> = try {
+            if out.is_null() {
+                Err(SDKError::with_type(ErrorType::ArgumentError, "out is NULL".to_string()))?
+            }
+            unsafe { *out = ptr::null_mut() };
+            let username = borrow_str(username, "username")?;
+            let password = borrow_str(password, "password")?;
+            FduCancelToken::check(token)?;
+            (username, password)
+        };
+        // Logins run on the thread of the caller, rather than as jobs.
+        let _call = FduCancelToken::enter(token);
+        match r {
+            Ok((username, password)) => captcha::login(username, password, token, out),
+            Err(e) => FduResult::from_unit(Err(e)),
+        }
+    })
+}
+
+#[no_mangle]
+pub extern "C" fn fdu_session_logout(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        FduCancelToken::check(token)?;
+        FduSession::borrow(session)?.fdu.logout()?
+    }))
+}
+
+// The `_async` variant of `fdu_session_logout()`.
+#[no_mangle]
+pub extern "C" fn fdu_session_logout_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_session_logout(handles.session(), handles.token()));
+    }))
+}
+
+// Serialize the session into `*out`, to be restored later by `fdu_session_restore()`.
+// The blob contains the cookies of the session, which grant access to the account: keep it secret.
+#[no_mangle]
+pub extern "C" fn fdu_session_export(session: *const FduSession, out: *mut *mut FduBuffer) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let blob = FduSession::borrow(session)?.fdu.export()?;
+        FduBuffer::write_to(out, blob)?
+    }))
+}
+
+// Restore a session exported by `fdu_session_export()` without logging in to UIS. On success, `*out` is set to a
+// new session, otherwise it is set to NULL. A truncated or corrupted blob is rejected with
+// `FDU_ERROR_CODE_INVALID_ARGUMENT`, whereas an expired session is restored fine: check it with `fdu_session_valid()`.
+#[no_mangle]
+pub extern "C" fn fdu_session_restore(data: *const u8, len: size_t, out: *mut *mut FduSession) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        if out.is_null() {
+            Err(SDKError::with_type(ErrorType::ArgumentError, "out is NULL".to_string()))?
+        }
+        unsafe { *out = ptr::null_mut() };
+        let fdu = Fdu::restore(borrow_bytes(data, len, "data")?)?;
+        unsafe { *out = Box::into_raw(Box::new(FduSession::new(fdu))) };
+    }))
+}
+
+// Check whether the session is still logged in, as a JSON boolean.
+#[no_mangle]
+pub extern "C" fn fdu_session_valid(session: *const FduSession, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let fdu = &FduSession::borrow(session)?.fdu;
+        FduCancelToken::check(token)?;
+        fdu.is_logged_in()?
+    })))
+}
+
+// The `_async` variant of `fdu_session_valid()`.
+#[no_mangle]
+pub extern "C" fn fdu_session_valid_async(session: *const FduSession, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_session_valid(handles.session(), handles.token()));
+    }))
+}
+
+// Return the rate limits of the session as a JSON object
+// `{"hosts": {<host>: {"tokens", "per_second", "burst"}}, "throttled_requests", "throttled_millis"}`, where `tokens`
+// is the number of requests the host can be sent at once (negative while requests wait for it), and the requests
+// which waited for a token took `throttled_millis` in total. Unlike the other exports, it may run while a call is in
+// flight on the session, e.g. to report why the call is slow.
+#[no_mangle]
+pub extern "C" fn fdu_session_rate_limit_stats(session: *const FduSession) -> *mut FduResult {
+    guard(|| FduResult::from_json(try {
+        FduSession::borrow(session)?.fdu.rate_limit_stats()
+    }))
+}
+
+// Set the type of the student, "undergraduate" or "graduate", for the student ids which do not tell it. An empty
+// string goes back to telling it from the student id. It is not exported with the session.
+#[no_mangle]
+pub extern "C" fn fdu_session_set_student_type(session: *const FduSession, student_type: *const c_char) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let session = FduSession::borrow(session)?;
+        let student_type = match borrow_str(student_type, "student_type")? {
+            "" => None,
+            s => Some(StudentType::parse(s)?),
+        };
+        *session.student_type.lock().unwrap_or_else(|e| e.into_inner()) = student_type;
+    }))
+}
+
+// Return the student id the session logged in with as a JSON string, "" if it has none, e.g. a test session. Callers
+// can key the data they keep for the account with it.
+#[no_mangle]
+pub extern "C" fn fdu_session_uid(session: *const FduSession) -> *mut FduResult {
+    guard(|| FduResult::from_json(try {
+        FduSession::borrow(session)?.fdu.uid().unwrap_or_default().to_string()
+    }))
+}
+
+#[no_mangle]
+pub extern "C" fn fdu_session_free(session: *mut FduSession) {
+    guard_or((), || {
+        if session.is_null() {
+            return;
+        }
+        drop(unsafe { Box::from_raw(session) });
+    })
+}
@@ -0,0 +1,382 @@
+// Exports used by the test suites of callers to reach code paths that are hard to trigger against the real servers.
+//
+// They are always exported so that debug and release libraries have the same symbols, but only do their job in debug builds
+// or with the `test-exports` feature, e.g. to run the tests of the Go binding against the static release library.
+use std::collections::HashMap;
+use std::ffi::{c_char, CString};
+use std::sync::atomic::Ordering;
+use std::thread;
+use std::time::{Duration, Instant};
+
+use serde::Serialize;
+
+use crate::fdu::prelude::*;
+use crate::fdu::trace;
+
+use super::buffer::*;
+use super::cancel::*;
+use super::captcha;
+use super::jobs::{self, *};
+use super::result::*;
+use super::session::*;
+
+// Whether the test exports do their job, and the message of their error otherwise.
+pub(crate) const ENABLED: bool = cfg!(any(debug_assertions, feature = "test-exports"));
+pub(crate) const RELEASE_BUILD: &str = "test exports are only available in debug builds or with the test-exports feature";
+
+fn release_build() -> *mut FduResult {
+    FduResult::err(FduErrorCode::Unknown, RELEASE_BUILD.to_string())
+}
+
+// Return a result carrying `code`. A code of 0 returns a result whose fields are all NULL.
+#[no_mangle]
+pub extern "C" fn fdu_test_error(code: i32) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        if code == FduErrorCode::Ok as i32 {
+            return FduResult::empty();
+        }
+        Box::into_raw(Box::new(FduResult {
+            value: std::ptr::null_mut(),
+            code,
+            message: to_c_string(format!("test error {}", code)),
+        }))
+    })
+}
+
+// Panic unconditionally, to check that panics are reported as errors instead of crashing the caller.
+#[no_mangle]
+pub extern "C" fn fdu_test_panic() -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        panic!("test panic");
+    })
+}
+
+// Log a record at each level from error to trace, to check that callers receive the logs in order. The records are
+// logged from a new thread, unknown to the caller like those of reqwest.
+#[no_mangle]
+pub extern "C" fn fdu_test_log() -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        thread::spawn(|| {
+            for level in log::Level::iter() {
+                log::log!(level, "test {}", level.as_str().to_lowercase());
+            }
+        }).join().unwrap();
+        FduResult::empty()
+    })
+}
+
+// Create a session without logging in, so that callers can test the lifecycle of sessions offline.
+#[no_mangle]
+pub extern "C" fn fdu_test_session_new(out: *mut *mut FduSession) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        if out.is_null() {
+            return FduResult::err(FduErrorCode::InvalidArgument, "out is NULL".to_string());
+        }
+        unsafe { *out = Box::into_raw(Box::new(FduSession::new(Fdu::new()))) };
+        FduResult::empty()
+    })
+}
+
+// Start a fake login which always asks for a captcha: the result has the code `FduErrorCode::CaptchaRequired` and
+// a continuation for `fdu_captcha_image()` and `fdu_login_with_captcha()`, which expires after `ttl_millis`.
+// The answer is "1234", and the session is like the one from `fdu_test_session_new()`.
+#[no_mangle]
+pub extern "C" fn fdu_test_login_captcha(ttl_millis: u64) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        captcha::test_login(ttl_millis)
+    })
+}
+
+// Sleep for `millis` milliseconds, checking the token every millisecond.
+#[no_mangle]
+pub extern "C" fn fdu_test_sleep(millis: u64, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        let deadline = Instant::now() + Duration::from_millis(millis);
+        FduResult::from_unit(try {
+            while Instant::now() < deadline {
+                FduCancelToken::check(token)?;
+                thread::sleep(Duration::from_millis(1));
+            }
+        })
+    })
+}
+
+// The `_async` variant of `fdu_test_sleep()`, to test jobs without a session.
+#[no_mangle]
+pub extern "C" fn fdu_test_sleep_async(millis: u64, token: *const FduCancelToken, request_id: u64) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        // Tokens are `Sync`, so sending the pointer is fine while the caller keeps it alive.
+        let token = token as usize;
+        jobs::spawn(request_id, token as *const FduCancelToken, move || {
+            fdu_test_sleep(millis, token as *const FduCancelToken)
+        });
+        FduResult::from_unit(Ok(()))
+    })
+}
+
+const TEST_PAGES: u32 = 5;
+const TEST_PAGE_SIZE: u32 = 20;
+
+#[derive(Serialize)]
+struct TestItem {
+    id: u32,
+}
+
+// Return a page of a fake listing of 5 pages of 20 items `{"id"}`, numbered from 0, like `fdu_library_borrow_history()`.
+// Each page takes 10 milliseconds, checking the token every millisecond, and page `fail_page` (counting from 1) fails
+// with a network error, unless it is 0.
+#[no_mangle]
+pub extern "C" fn fdu_test_pages(session: *const FduSession,
+                                 page_token: *const c_char,
+                                 fail_page: u32,
+                                 token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        FduResult::from_json(try {
+            FduSession::borrow(session)?;
+            let number = page_number(borrow_str(page_token, "page_token")?)?;
+            for _ in 0..10 {
+                FduCancelToken::check(token)?;
+                thread::sleep(Duration::from_millis(1));
+            }
+            if number == fail_page {
+                Err(SDKError::with_type(ErrorType::NetworkError, format!("test error on page {}", number)))?;
+            }
+            let items = if number > TEST_PAGES {
+                vec![]
+            } else {
+                let first = (number - 1) * TEST_PAGE_SIZE;
+                (first..first + TEST_PAGE_SIZE).map(|id| TestItem { id }).collect()
+            };
+            Page::numbered(items, number, TEST_PAGE_SIZE, (TEST_PAGES * TEST_PAGE_SIZE) as u64)
+        })
+    })
+}
+
+// The `_async` variant of `fdu_test_pages()`.
+#[no_mangle]
+pub extern "C" fn fdu_test_pages_async(session: *const FduSession,
+                                       page_token: *const c_char,
+                                       fail_page: u32,
+                                       token: *const FduCancelToken,
+                                       request_id: u64) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        FduResult::from_unit(try {
+            let handles = Handles::new(session, token)?;
+            let page_token = owned_str(page_token, "page_token")?;
+            jobs::spawn(request_id, handles.token(), move || {
+                fdu_test_pages(handles.session(), page_token.as_ptr(), fail_page, handles.token())
+            });
+        })
+    })
+}
+
+// Complete a job after `millis` milliseconds with a result carrying `value` and `code`, so that callers can stub the
+// `_async` variant of any export. `value` may be NULL, and the message of an error is "test error <code>".
+// The job fails with `FduErrorCode::Cancelled` instead if the token is cancelled in the meantime.
+#[no_mangle]
+pub extern "C" fn fdu_test_result_async(value: *const c_char,
+                                        code: i32,
+                                        millis: u64,
+                                        token: *const FduCancelToken,
+                                        request_id: u64) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        FduResult::from_unit(try {
+            let value = if value.is_null() { None } else { Some(owned_str(value, "value")?) };
+            // Tokens are `Sync`, so sending the pointer is fine while the caller keeps it alive.
+            let token = token as usize;
+            jobs::spawn(request_id, token as *const FduCancelToken, move || {
+                let slept = fdu_test_sleep(millis, token as *const FduCancelToken);
+                if unsafe { (*slept).code } != FduErrorCode::Ok as i32 {
+                    return slept;
+                }
+                free_result(slept);
+                test_result(value, code)
+            });
+        })
+    })
+}
+
+fn test_result(value: Option<CString>, code: i32) -> *mut FduResult {
+    let message = if code == FduErrorCode::Ok as i32 {
+        std::ptr::null_mut()
+    } else {
+        to_c_string(format!("test error {}", code))
+    };
+    Box::into_raw(Box::new(FduResult {
+        value: value.map_or(std::ptr::null_mut(), |value| value.into_raw()),
+        code,
+        message,
+    }))
+}
+
+// Return a result carrying `value` and `code` like `fdu_test_result_async()`, so that callers can stub the exports
+// without an `_async` variant.
+#[no_mangle]
+pub extern "C" fn fdu_test_result(value: *const c_char, code: i32) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        let value = if value.is_null() {
+            None
+        } else {
+            match owned_str(value, "value") {
+                Ok(value) => Some(value),
+                Err(e) => return FduResult::from_error(e),
+            }
+        };
+        test_result(value, code)
+    })
+}
+
+// Complete a job with `value` like `fdu_test_result_async()`, after sending the trace events of a request to `host`:
+// a DNS lookup finding an address, a request answered by a 200, then the parse of the result, so that callers can
+// test their trace callback offline.
+#[no_mangle]
+pub extern "C" fn fdu_test_trace_async(host: *const c_char,
+                                       value: *const c_char,
+                                       token: *const FduCancelToken,
+                                       request_id: u64) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        FduResult::from_unit(try {
+            let host = borrow_str(host, "host")?.to_string();
+            let value = owned_str(value, "value")?;
+            jobs::spawn(request_id, token, move || {
+                let step = || thread::sleep(Duration::from_millis(1));
+                trace::timed(trace::Phase::Dns, &host, step, |_| 1);
+                trace::timed(trace::Phase::Request, &host, step, |_| 200);
+                FduResult::ok(value.into_string().unwrap())
+            });
+        })
+    })
+}
+
+// Copy the bytes passed in into a new buffer.
+#[no_mangle]
+pub extern "C" fn fdu_test_echo_bytes(data: *const u8, len: usize, out: *mut *mut FduBuffer) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        FduResult::from_unit(try {
+            let data = borrow_bytes(data, len, "data")?;
+            FduBuffer::write_to(out, data.to_vec())?
+        })
+    })
+}
+
+// Leak `count` allocations, so that callers can check that their leak detection, e.g. with `fdu_alloc_stats()`,
+// catches leaks.
+#[no_mangle]
+pub extern "C" fn fdu_test_leak(count: u32) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        for _ in 0..count {
+            std::hint::black_box(Box::leak(vec![0u8; 64].into_boxed_slice()));
+        }
+        FduResult::empty()
+    })
+}
+
+// Return the number of buffers not freed yet, or -1 in release builds.
+#[no_mangle]
+pub extern "C" fn fdu_test_live_buffers() -> i64 {
+    guard_or(-1, || {
+        if !ENABLED {
+            return -1;
+        }
+        LIVE_BUFFERS.load(Ordering::Relaxed)
+    })
+}
+
+// Return the number of cancellation tokens not freed yet, or -1 in release builds.
+#[no_mangle]
+pub extern "C" fn fdu_test_live_tokens() -> i64 {
+    guard_or(-1, || {
+        if !ENABLED {
+            return -1;
+        }
+        LIVE_TOKENS.load(Ordering::Relaxed)
+    })
+}
+
+// Return the number of sessions not freed yet, or -1 in release builds.
+#[no_mangle]
+pub extern "C" fn fdu_test_live_sessions() -> i64 {
+    guard_or(-1, || {
+        if !ENABLED {
+            return -1;
+        }
+        LIVE_SESSIONS.load(Ordering::Relaxed)
+    })
+}
+
+// Increase the ping counter of the session, and return the new value.
+// The counter is deliberately not atomic: concurrent calls on the same session may lose updates.
+#[no_mangle]
+pub extern "C" fn fdu_test_session_ping(session: *const FduSession) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        FduResult::from_result(try {
+            let session = FduSession::borrow(session)?;
+            session.pings.set(session.pings.get() + 1);
+            session.pings.get().to_string()
+        })
+    })
+}
+
+// Point hosts of the university at local servers, from a JSON object mapping each host to the base URL replacing
+// `https://<host>`, e.g. `{"uis.fudan.edu.cn": "http://127.0.0.1:8080"}`, so that callers can test their bindings end
+// to end against fake servers. The base URLs apply to the requests sent afterwards, of all sessions, and `{}` restores
+// the real servers.
+#[no_mangle]
+pub extern "C" fn fdu_set_base_urls(json: *const c_char) -> *mut FduResult {
+    guard(|| {
+        if !ENABLED {
+            return release_build();
+        }
+        FduResult::from_unit(try {
+            let json = borrow_str(json, "json")?;
+            let urls: HashMap<String, String> = serde_json::from_str(json)
+                .map_err(|e| SDKError::with_type(ErrorType::ArgumentError, format!("invalid base URLs: {}", e)))?;
+            base_url::set(urls)?
+        })
+    })
+}
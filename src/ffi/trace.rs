@@ -0,0 +1,153 @@
+// Forward the trace events of the requests, see fdu/trace.rs, to a callback of the caller, like the logs (see
+// logging.rs) but structured, so that the caller can time the calls in its own tracing.
+use std::cell::Cell;
+use std::ffi::CString;
+use std::sync::RwLock;
+use std::time::UNIX_EPOCH;
+
+use libc::*;
+
+use crate::fdu::trace::{self, Event, Phase};
+
+use super::result::*;
+
+// The step of a request timed by an event.
+#[repr(i32)]
+pub enum FduTracePhase {
+    Dns = 1,
+    // Not sent yet, see fdu/trace.rs: the TCP and TLS handshakes are part of the request opening the connection.
+    Connect = 2,
+    Tls = 3,
+    // From sending a request to the headers of its response.
+    Request = 4,
+    // From the last response of a call to its result.
+    Parse = 5,
+}
+
+impl From<Phase> for FduTracePhase {
+    fn from(phase: Phase) -> Self {
+        match phase {
+            Phase::Dns => FduTracePhase::Dns,
+            Phase::Connect => FduTracePhase::Connect,
+            Phase::Tls => FduTracePhase::Tls,
+            Phase::Request => FduTracePhase::Request,
+            Phase::Parse => FduTracePhase::Parse,
+        }
+    }
+}
+
+#[repr(C)]
+pub struct FduTraceEvent {
+    // The request id of the `_async` export making the request, 0 for the other exports.
+    pub request_id: u64,
+    pub start_unix_nanos: i64,
+    pub duration_nanos: u64,
+    // The trace id of the token of the call, see `fdu_cancel_token_set_trace_id()`, or "".
+    pub trace_id: *const c_char,
+    // The host of the request, e.g. "jwfw.fudan.edu.cn", also when a test replaces it, see `fdu_set_base_urls()`.
+    pub host: *const c_char,
+    // An `FduTracePhase`.
+    pub phase: i32,
+    // The HTTP status of a request, 0 without a response; the number of addresses found by a DNS lookup, 0 if it
+    // failed; the `FduErrorCode` of the result of the call for the parse.
+    pub status: i32,
+}
+
+// Receive an event, which is only valid during the call. The callback is called from the threads of the library,
+// possibly from several at the same time, and must not call the library: the exports returning an `FduResult` fail
+// with `FduErrorCode::InvalidArgument` when it does.
+pub type FduTraceCallback = Option<extern "C" fn(event: *const FduTraceEvent)>;
+
+static CALLBACK: RwLock<FduTraceCallback> = RwLock::new(None);
+
+thread_local! {
+    static IN_CALLBACK: Cell<bool> = const { Cell::new(false) };
+}
+
+// Whether the current thread is running the callback, see `guard()`.
+pub(crate) fn in_callback() -> bool {
+    IN_CALLBACK.with(Cell::get)
+}
+
+fn send(event: &Event) {
+    // Do not hold the lock during the call, like the logs.
+    let Some(callback) = *CALLBACK.read().unwrap_or_else(|e| e.into_inner()) else {
+        return;
+    };
+    let trace_id = CString::new(event.span.trace_id.replace('\0', "\\0")).unwrap();
+    let host = CString::new(event.host).unwrap_or_default();
+    let start = event.start.duration_since(UNIX_EPOCH).unwrap_or_default();
+    let c_event = FduTraceEvent {
+        request_id: event.span.request_id,
+        start_unix_nanos: start.as_nanos() as i64,
+        duration_nanos: event.duration.as_nanos() as u64,
+        trace_id: trace_id.as_ptr(),
+        host: host.as_ptr(),
+        phase: FduTracePhase::from(event.phase) as i32,
+        status: event.status,
+    };
+    IN_CALLBACK.with(|in_callback| in_callback.set(true));
+    callback(&c_event);
+    IN_CALLBACK.with(|in_callback| in_callback.set(false));
+}
+
+// Send the trace events to `callback`, replacing the previous one. A NULL callback stops them.
+#[no_mangle]
+pub extern "C" fn fdu_set_trace_callback(callback: FduTraceCallback) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        *CALLBACK.write().unwrap_or_else(|e| e.into_inner()) = callback;
+        trace::set_sink(callback.map(|_| send as trace::Sink));
+    }))
+}
+
+// Return whether the current thread is running the trace callback, so that a caller can fail its own calls to the
+// library from the callback before taking its locks.
+#[no_mangle]
+pub extern "C" fn fdu_in_trace_callback() -> bool {
+    guard_or(false, in_callback)
+}
+
+#[cfg(test)]
+mod tests {
+    use std::ffi::CStr;
+    use std::sync::Mutex;
+
+    use super::*;
+
+    // The request id, phase, host and status of the events, with the code of a call to the library from the callback.
+    static EVENTS: Mutex<Vec<(u64, i32, String, i32, i32)>> = Mutex::new(Vec::new());
+
+    extern "C" fn record(event: *const FduTraceEvent) {
+        let event = unsafe { &*event };
+        // Other tests trace in parallel, keep only ours.
+        if unsafe { CStr::from_ptr(event.trace_id) }.to_str().unwrap() != "test_trace_callback" {
+            return;
+        }
+        let host = unsafe { CStr::from_ptr(event.host) }.to_str().unwrap().to_string();
+        let nested = fdu_set_trace_callback(None);
+        let code = unsafe { (*nested).code };
+        free_result(nested);
+        EVENTS.lock().unwrap().push((event.request_id, event.phase, host, event.status, code));
+    }
+
+    #[test]
+    fn test_trace_callback() {
+        free_result(fdu_set_trace_callback(Some(record)));
+        {
+            let _span = trace::enter(trace::Span { request_id: 7, trace_id: "test_trace_callback".to_string() });
+            trace::timed(Phase::Request, "jwfw.fudan.edu.cn", || 200, |status| *status);
+            trace::finish(FduErrorCode::Parse as i32);
+            // The parse of a call is only sent once.
+            trace::finish(FduErrorCode::Ok as i32);
+        }
+        assert!(!in_callback());
+        free_result(fdu_set_trace_callback(None));
+
+        // Calling the library from the callback failed, and did not stop the events.
+        let invalid = FduErrorCode::InvalidArgument as i32;
+        assert_eq!(*EVENTS.lock().unwrap(), vec![
+            (7, FduTracePhase::Request as i32, "jwfw.fudan.edu.cn".to_string(), 200, invalid),
+            (7, FduTracePhase::Parse as i32, "jwfw.fudan.edu.cn".to_string(), FduErrorCode::Parse as i32, invalid),
+        ]);
+    }
+}
@@ -0,0 +1,113 @@
+use libc::*;
+
+use crate::fdu::prelude::*;
+use crate::fdu::xk::{CourseQuery, XkClient};
+
+use super::cancel::*;
+use super::jobs::{self, *};
+use super::result::*;
+use super::retry::{self, retried};
+use super::session::*;
+
+// Log in xk and remember the profile of the course selection in the session.
+fn enter_xk(session: &FduSession, token: *const FduCancelToken) -> Result<i64> {
+    FduCancelToken::check(token)?;
+    let profile_id = session.fdu.enter_xk()?;
+    *session.xk_profile.lock().unwrap_or_else(|e| e.into_inner()) = Some(profile_id);
+    Ok(profile_id)
+}
+
+// Run `f` with the profile of the course selection, logging in xk the first time. xk has its own token, which may
+// expire before the UIS session: then `f` fails before xk does anything, so it runs again after logging in xk again.
+fn with_xk<T>(session: *const FduSession, token: *const FduCancelToken, f: impl Fn(&Fdu, i64) -> Result<T>) -> Result<T> {
+    let session = FduSession::borrow(session)?;
+    let cached = *session.xk_profile.lock().unwrap_or_else(|e| e.into_inner());
+    let profile_id = match cached {
+        Some(profile_id) => profile_id,
+        None => enter_xk(session, token)?,
+    };
+    FduCancelToken::check(token)?;
+    match f(&session.fdu, profile_id) {
+        Err(e) if cached.is_some() && matches!(e.error_type(), ErrorType::LoginError) => {
+            log::info!("the xk token expired, logging in xk again");
+            let profile_id = enter_xk(session, token)?;
+            FduCancelToken::check(token)?;
+            f(&session.fdu, profile_id)
+        }
+        r => r,
+    }
+}
+
+// Return the courses open to selection as a JSON array of
+// `{"lesson_id", "course_id", "name", "department", "teachers", "credits", "campus", "capacity", "enrolled"}`,
+// filtered by `query`, a JSON object `{"department", "keyword", "min_credits", "max_credits"}` whose fields are all
+// optional, see `CourseQuery`.
+#[no_mangle]
+pub extern "C" fn fdu_selectable_courses(session: *const FduSession,
+                                         query: *const c_char,
+                                         token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(retried(&retry::policy(session), token, || try {
+        let query: CourseQuery = serde_json::from_str(borrow_str(query, "query")?)
+            .map_err(|e| SDKError::with_type(ErrorType::ArgumentError, format!("invalid query: {}", e)))?;
+        with_xk(session, token, |fdu, profile_id| fdu.get_selectable_courses(profile_id, &query))?
+    })))
+}
+
+// The `_async` variant of `fdu_selectable_courses()`.
+#[no_mangle]
+pub extern "C" fn fdu_selectable_courses_async(session: *const FduSession,
+                                               query: *const c_char,
+                                               token: *const FduCancelToken,
+                                               request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        let query = owned_str(query, "query")?;
+        jobs::spawn(request_id, handles.token(), move || {
+            fdu_selectable_courses(handles.session(), query.as_ptr(), handles.token())
+        });
+    }))
+}
+
+// Enroll in the lesson `lesson_id` of `fdu_selectable_courses()`, and return the outcome as a JSON object
+// `{"outcome", "message", "retry_later"}`, where `outcome` is one of enrolled, waitlisted, full, time_conflict and
+// credit_limit, and `message` is the one of xk. Other failures, e.g. outside the selection period, are errors.
+//
+// This changes the enrollment of the student, and is never retried.
+#[no_mangle]
+pub extern "C" fn fdu_enroll(session: *const FduSession, lesson_id: i64, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(with_xk(session, token, |fdu, profile_id| {
+        fdu.select_course(profile_id, lesson_id, true)
+    })))
+}
+
+// The `_async` variant of `fdu_enroll()`.
+#[no_mangle]
+pub extern "C" fn fdu_enroll_async(session: *const FduSession,
+                                   lesson_id: i64,
+                                   token: *const FduCancelToken,
+                                   request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_enroll(handles.session(), lesson_id, handles.token()));
+    }))
+}
+
+// Drop the lesson `lesson_id`, like `fdu_enroll()`, where the outcome is dropped.
+#[no_mangle]
+pub extern "C" fn fdu_drop(session: *const FduSession, lesson_id: i64, token: *const FduCancelToken) -> *mut FduResult {
+    guard(|| FduResult::from_json(with_xk(session, token, |fdu, profile_id| {
+        fdu.select_course(profile_id, lesson_id, false)
+    })))
+}
+
+// The `_async` variant of `fdu_drop()`.
+#[no_mangle]
+pub extern "C" fn fdu_drop_async(session: *const FduSession,
+                                 lesson_id: i64,
+                                 token: *const FduCancelToken,
+                                 request_id: u64) -> *mut FduResult {
+    guard(|| FduResult::from_unit(try {
+        let handles = Handles::new(session, token)?;
+        jobs::spawn(request_id, handles.token(), move || fdu_drop(handles.session(), lesson_id, handles.token()));
+    }))
+}
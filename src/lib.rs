@@ -1,12 +1,17 @@
 #![feature(try_blocks)]
 mod fdu;
 mod error;
+mod ffi;
 
 use std::ffi::{CStr, CString};
 
 // Provides a lot of C-equivalent types.
 use libc::*;
 
+use crate::error::*;
+use crate::fdu::fdu::{Fdu, HttpClient};
+use crate::ffi::result::*;
+
 // no_mangle tells Rust compiler not to mangle the name of the function and keep the original name.
 //
 // A flag starting with # is a macro. You can roughly think of it as a preprocessor directive, like
@@ -19,30 +24,40 @@ use libc::*;
 // The `mut` means the caller is able to change the value of pointer. To know more about mutability, see: https://doc.rust-lang.org/book/ch10-03-mutability.html
 //
 // Roughly speaking, `*mut c_char` is an equivalent of `char *` in C.
-pub extern "C" fn hello_world() -> *mut c_char {
+pub extern "C" fn hello_world() -> *mut FduResult {
     // You can use CString::new to create a C-type String from a Rust String.
     // This is useful when you want to pass a String to a C function.
     // The CString will not be automatically freed by rust allocator, so you must do it manually in the C code.
     // p.s. The free functions for C are different in different platforms.
     //      For example, on Linux, it is free() and on Windows it is HeapFree().
-    CString::new("hello world").unwrap().into_raw()
+    //
+    // Here the CString is wrapped in an `FduResult` (see ffi/result.rs), which tells the caller whether the call succeeded.
+    // The caller frees both of them at once with `free_result()`.
+    //
+    // `guard` catches any panic inside the closure and reports it as an error, see ffi/result.rs for why.
+    guard(|| FduResult::ok("hello world".to_string()))
 }
 
 #[no_mangle]
 pub extern "C" fn free_string(s: *mut c_char) {
-    unsafe {
+    guard_or((), || unsafe {
         if s.is_null() { return; }
-        CString::from_raw(s)
-    };
+        drop(CString::from_raw(s));
+    })
 }
 
 #[no_mangle]
 pub extern "C" fn add(a: c_int, b: c_int) -> c_int {
-    a + b
+    // Overflow panics in debug builds.
+    guard_or(0, || a + b)
 }
 
 #[no_mangle]
-pub extern "C" fn get_url(url: *const c_char) -> *mut c_char {
+pub extern "C" fn get_url(url: *const c_char) -> *mut FduResult {
+    guard(|| FduResult::from_result(fetch_url(url)))
+}
+
+fn fetch_url(url: *const c_char) -> Result<String> {
     // from_ptr() is an unsafe function! It operates a raw pointer and parses it into a &CStr.
     // So, you have to put it in an unsafe block. Otherwise, you will have to tag the whole function as unsafe.
     let c_str = unsafe {
@@ -52,27 +67,27 @@ pub extern "C" fn get_url(url: *const c_char) -> *mut c_char {
         assert!(!url.is_null());
         CStr::from_ptr(url)
     };
-    // .to_str() will return an Option<&str>.
+    // .to_str() will return a Result<&str, Utf8Error>.
     //
     // &str is a reference to a str. A str is stored in the heap, and &str seems like a pointer to it.
     // You can never hold a str because str is a DST (dynamic sized type), which means we do not know
     // how much memory it will take. So you cannot hold a str in a variable, since variables are
     // always allocated on the stack, not heap. But hold its reference (&str) is okay.
     //
-    // unwrap() is a method of Option<T>. You can view Option<T> as a nullable type T? in other languages.
-    // The method will return the value inside the Option if it is Some(T),
-    // or just panic if it is None. If a Rust program panics, the program will be terminated immediately.
+    // map_err() converts the error into our own error type, and the `?` operator returns it to the caller
+    // immediately if it is an Err. Compare it with unwrap(), which would panic instead:
+    // If a Rust program panics, the program will be terminated immediately.
     // So do not use unwrap() until you know what you are doing!
     //
     // There are many helper methods like unwrap(), such as expect(), unwrap_or(), unwrap_or_else() in std::option module.
-    let url = c_str.to_str().unwrap();
+    let url = c_str.to_str().map_err(|_| SDKError::with_type(ErrorType::ParseError, "url is not valid UTF-8".to_string()))?;
     // Use blocking http client to get the content of the url.
     // Obviously, you cannot use async http client in the C code, so we drop any kind of async features in this project.
-    let body = reqwest::blocking::get(url).unwrap().text().unwrap();
-    CString::new(body).unwrap().into_raw()
+    // The client is built for each call, with the HTTP settings of the caller at the time (see fdu/config.rs).
+    let client = Fdu::client_builder().build()?;
+    Ok(client.get(url).send()?.text()?)
 }
 
-
 // Test is an important part of the project.
 // You can run all the tests by running `cargo test`.
 //
@@ -93,7 +108,10 @@ mod tests {
         unsafe {
             // The `assert_eq!` macro is used to compare two values.
             // If the two values are not equal, the test will fail, and panic! will be called.
-            assert_eq!(CString::from_raw(hello_world()), CString::new("hello world").unwrap());
+            let result = hello_world();
+            assert_eq!((*result).code, FduErrorCode::Ok as i32);
+            assert_eq!(CStr::from_ptr((*result).value).to_str().unwrap(), "hello world");
+            free_result(result);
             assert_eq!(add(1, 2), 3);
         }
     }